@@ -1,3 +1,35 @@
+// Package auth authenticates calls between services. A service gets a
+// short-lived access token for its Keycloak client with the OAuth 2.0 client
+// credentials grant and sends it with every gRPC call; the called service
+// verifies it and puts the caller's name in the context, where handlers can
+// check it with RequireService. Calls made for a user can also carry the
+// user's own access token, which handlers verify with VerifiedUser.
 package auth
 
-// Placeholder package for auth utilities
+import (
+	"context"
+	"errors"
+)
+
+var (
+	// ErrInvalidToken is returned for tokens that are malformed, badly signed,
+	// expired or not issued to the kind of caller expected
+	ErrInvalidToken = errors.New("invalid token")
+	// ErrUnavailable is returned when tokens can't be issued or verified at the
+	// moment, e.g. because the signing keys can't be fetched
+	ErrUnavailable = errors.New("service authentication unavailable")
+)
+
+type serviceKey struct{}
+
+// WithService returns a copy of ctx carrying the name of the calling service
+func WithService(ctx context.Context, name string) context.Context {
+	return context.WithValue(ctx, serviceKey{}, name)
+}
+
+// ServiceFromContext returns the name of the service that made the call
+// being handled, if it was authenticated
+func ServiceFromContext(ctx context.Context) (string, bool) {
+	name, ok := ctx.Value(serviceKey{}).(string)
+	return name, ok && name != ""
+}
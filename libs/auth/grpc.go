@@ -0,0 +1,173 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// authorizationHeader carries the bearer token in gRPC metadata
+const authorizationHeader = "authorization"
+
+// UserTokenHeader carries the access token of the user a call is made for,
+// next to the calling service's own token
+const UserTokenHeader = "x-user-token"
+
+// TokenSource supplies the access token sent with outgoing calls
+type TokenSource interface {
+	Token(ctx context.Context) (string, error)
+}
+
+// TokenVerifier returns the name of the service a token was issued to. It
+// returns ErrInvalidToken for rejected tokens and ErrUnavailable when the
+// token can't be checked.
+type TokenVerifier interface {
+	VerifyServiceToken(ctx context.Context, token string) (string, error)
+}
+
+// UserTokenVerifier returns the subject of a user's access token. It returns
+// ErrInvalidToken for rejected tokens and ErrUnavailable when the token can't
+// be checked.
+type UserTokenVerifier interface {
+	VerifyUserToken(ctx context.Context, token string) (string, error)
+}
+
+// UnaryClientInterceptor sends the source's token with every unary call
+func UnaryClientInterceptor(source TokenSource) grpc.UnaryClientInterceptor {
+	return func(ctx context.Context, method string, req any, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+		ctx, err := withToken(ctx, source)
+		if err != nil {
+			return err
+		}
+		return invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+// StreamClientInterceptor sends the source's token with every stream
+func StreamClientInterceptor(source TokenSource) grpc.StreamClientInterceptor {
+	return func(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+		ctx, err := withToken(ctx, source)
+		if err != nil {
+			return nil, err
+		}
+		return streamer(ctx, desc, cc, method, opts...)
+	}
+}
+
+func withToken(ctx context.Context, source TokenSource) (context.Context, error) {
+	token, err := source.Token(ctx)
+	if err != nil {
+		return nil, status.Errorf(codes.Unavailable, "failed to get service token: %v", err)
+	}
+	return metadata.AppendToOutgoingContext(ctx, authorizationHeader, "Bearer "+token), nil
+}
+
+// UnaryServerInterceptor rejects unary calls without a valid service token
+// and stores the calling service's name in the handler context. Methods in
+// public are let through without a token; entries ending in a slash, like
+// "/grpc.reflection.v1.ServerReflection/", cover every method of a service.
+func UnaryServerInterceptor(verifier TokenVerifier, public ...string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, err := authenticate(ctx, verifier, info.FullMethod, public)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor is UnaryServerInterceptor for streaming calls
+func StreamServerInterceptor(verifier TokenVerifier, public ...string) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, err := authenticate(ss.Context(), verifier, info.FullMethod, public)
+		if err != nil {
+			return err
+		}
+		return handler(srv, &authenticatedStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+type authenticatedStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *authenticatedStream) Context() context.Context {
+	return s.ctx
+}
+
+func authenticate(ctx context.Context, verifier TokenVerifier, method string, public []string) (context.Context, error) {
+	if isPublic(method, public) {
+		return ctx, nil
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get(authorizationHeader)
+	if len(values) == 0 {
+		return nil, status.Error(codes.Unauthenticated, "missing service token")
+	}
+
+	scheme, token, ok := strings.Cut(values[0], " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") || token == "" {
+		return nil, status.Error(codes.Unauthenticated, "malformed authorization metadata")
+	}
+
+	name, err := verifier.VerifyServiceToken(ctx, token)
+	if errors.Is(err, ErrUnavailable) {
+		return nil, status.Error(codes.Unavailable, "service tokens can't be verified at the moment")
+	}
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, "invalid service token")
+	}
+
+	return WithService(ctx, name), nil
+}
+
+func isPublic(method string, public []string) bool {
+	for _, p := range public {
+		if method == p || strings.HasSuffix(p, "/") && strings.HasPrefix(method, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// RequireService returns a PermissionDenied status unless the call being
+// handled was made by one of the named services, and an Unauthenticated one
+// if it wasn't authenticated at all
+func RequireService(ctx context.Context, names ...string) error {
+	caller, ok := ServiceFromContext(ctx)
+	if !ok {
+		return status.Error(codes.Unauthenticated, "call was not made by an authenticated service")
+	}
+	if !slices.Contains(names, caller) {
+		return status.Errorf(codes.PermissionDenied, "service %q may not make this call", caller)
+	}
+	return nil
+}
+
+// VerifiedUser returns the subject of the user token sent with the call being
+// handled. ok is false when the call carries no user token; a token that
+// can't be verified is an Unauthenticated or Unavailable status.
+func VerifiedUser(ctx context.Context, verifier UserTokenVerifier) (subject string, ok bool, err error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get(UserTokenHeader)
+	if len(values) == 0 {
+		return "", false, nil
+	}
+
+	subject, err = verifier.VerifyUserToken(ctx, values[0])
+	if errors.Is(err, ErrUnavailable) {
+		return "", false, status.Error(codes.Unavailable, "user tokens can't be verified at the moment")
+	}
+	if err != nil {
+		return "", false, status.Error(codes.Unauthenticated, "invalid user token")
+	}
+	return subject, true, nil
+}
@@ -0,0 +1,163 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// verifierFunc adapts a function to TokenVerifier
+type verifierFunc func(string) (string, error)
+
+func (f verifierFunc) VerifyServiceToken(_ context.Context, token string) (string, error) {
+	return f(token)
+}
+
+var testVerifier = verifierFunc(func(token string) (string, error) {
+	switch token {
+	case "tasks-token":
+		return "tasks", nil
+	case "unverifiable":
+		return "", ErrUnavailable
+	default:
+		return "", ErrInvalidToken
+	}
+})
+
+func TestUnaryServerInterceptor(t *testing.T) {
+	interceptor := UnaryServerInterceptor(testVerifier, "/grpc.health.v1.Health/Check", "/grpc.reflection.v1.ServerReflection/")
+
+	call := func(method string, authorization string) (string, error) {
+		ctx := context.Background()
+		if authorization != "" {
+			ctx = metadata.NewIncomingContext(ctx, metadata.Pairs("authorization", authorization))
+		}
+		var caller string
+		_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: method}, func(ctx context.Context, _ any) (any, error) {
+			caller, _ = ServiceFromContext(ctx)
+			return nil, nil
+		})
+		return caller, err
+	}
+
+	if caller, err := call("/permissions.v1.PermissionService/RegisterService", "Bearer tasks-token"); err != nil || caller != "tasks" {
+		t.Errorf("call with a valid token = %q, %v, want tasks", caller, err)
+	}
+
+	tests := map[string]struct {
+		method        string
+		authorization string
+		want          codes.Code
+	}{
+		"no token":          {method: "/permissions.v1.PermissionService/RegisterService", want: codes.Unauthenticated},
+		"wrong scheme":      {method: "/permissions.v1.PermissionService/RegisterService", authorization: "Basic dGFza3M6c2VjcmV0", want: codes.Unauthenticated},
+		"invalid token":     {method: "/permissions.v1.PermissionService/RegisterService", authorization: "Bearer forged", want: codes.Unauthenticated},
+		"unverifiable":      {method: "/permissions.v1.PermissionService/RegisterService", authorization: "Bearer unverifiable", want: codes.Unavailable},
+		"public method":     {method: "/grpc.health.v1.Health/Check", want: codes.OK},
+		"public service":    {method: "/grpc.reflection.v1.ServerReflection/ServerReflectionInfo", want: codes.OK},
+		"other health call": {method: "/grpc.health.v1.Health/Watch", want: codes.Unauthenticated},
+	}
+	for name, tt := range tests {
+		if _, err := call(tt.method, tt.authorization); status.Code(err) != tt.want {
+			t.Errorf("%s: error = %v, want %v", name, err, tt.want)
+		}
+	}
+}
+
+func TestRequireService(t *testing.T) {
+	ctx := WithService(context.Background(), "tasks")
+
+	if err := RequireService(ctx, "forms", "tasks"); err != nil {
+		t.Errorf("RequireService() error = %v, want the caller allowed", err)
+	}
+	if err := RequireService(ctx, "forms"); status.Code(err) != codes.PermissionDenied {
+		t.Errorf("RequireService() of another service error = %v, want PermissionDenied", err)
+	}
+	if err := RequireService(context.Background(), "tasks"); status.Code(err) != codes.Unauthenticated {
+		t.Errorf("RequireService() without a caller error = %v, want Unauthenticated", err)
+	}
+}
+
+// userVerifierFunc adapts a function to UserTokenVerifier
+type userVerifierFunc func(string) (string, error)
+
+func (f userVerifierFunc) VerifyUserToken(_ context.Context, token string) (string, error) {
+	return f(token)
+}
+
+func TestVerifiedUser(t *testing.T) {
+	verifier := userVerifierFunc(func(token string) (string, error) {
+		switch token {
+		case "alice-token":
+			return "kc-alice", nil
+		case "unverifiable":
+			return "", ErrUnavailable
+		default:
+			return "", ErrInvalidToken
+		}
+	})
+	incoming := func(token string) context.Context {
+		return metadata.NewIncomingContext(context.Background(), metadata.Pairs(UserTokenHeader, token))
+	}
+
+	if subject, ok, err := VerifiedUser(incoming("alice-token"), verifier); subject != "kc-alice" || !ok || err != nil {
+		t.Errorf("VerifiedUser() = %q, %v, %v, want kc-alice", subject, ok, err)
+	}
+	if _, ok, err := VerifiedUser(context.Background(), verifier); ok || err != nil {
+		t.Errorf("VerifiedUser() without a token = %v, %v, want no user", ok, err)
+	}
+	if _, ok, err := VerifiedUser(incoming("forged"), verifier); ok || status.Code(err) != codes.Unauthenticated {
+		t.Errorf("VerifiedUser() with an invalid token = %v, %v, want Unauthenticated", ok, err)
+	}
+	if _, ok, err := VerifiedUser(incoming("unverifiable"), verifier); ok || status.Code(err) != codes.Unavailable {
+		t.Errorf("VerifiedUser() with an unverifiable token = %v, %v, want Unavailable", ok, err)
+	}
+}
+
+func TestUnaryClientInterceptor(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.PostForm.Get("grant_type") != "client_credentials" || r.PostForm.Get("client_secret") != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		requests++
+		w.Write([]byte(`{"access_token":"tasks-token","expires_in":300}`))
+	}))
+	t.Cleanup(server.Close)
+
+	interceptor := UnaryClientInterceptor(NewClientCredentials(server.URL, "tasks", "secret", server.Client()))
+	for range 2 {
+		err := interceptor(context.Background(), "/permissions.v1.PermissionService/RegisterService", nil, nil, nil,
+			func(ctx context.Context, _ string, _ any, _ any, _ *grpc.ClientConn, _ ...grpc.CallOption) error {
+				md, _ := metadata.FromOutgoingContext(ctx)
+				if got := md.Get("authorization"); len(got) != 1 || got[0] != "Bearer tasks-token" {
+					t.Errorf("authorization metadata = %v, want the service token", got)
+				}
+				return nil
+			})
+		if err != nil {
+			t.Fatalf("interceptor error = %v", err)
+		}
+	}
+	if requests != 1 {
+		t.Errorf("requested %d tokens, want the first one cached", requests)
+	}
+
+	failing := UnaryClientInterceptor(NewClientCredentials(server.URL, "tasks", "wrong", server.Client()))
+	err := failing(context.Background(), "/permissions.v1.PermissionService/RegisterService", nil, nil, nil,
+		func(context.Context, string, any, any, *grpc.ClientConn, ...grpc.CallOption) error {
+			return errors.New("call made without a token")
+		})
+	if status.Code(err) != codes.Unavailable {
+		t.Errorf("interceptor with wrong credentials error = %v, want Unavailable", err)
+	}
+}
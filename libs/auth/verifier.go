@@ -0,0 +1,263 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// ServiceAccountPrefix starts the username of every Keycloak service account
+const ServiceAccountPrefix = "service-account-"
+
+const (
+	// keysTTL is how long signing keys are used before they are fetched again
+	keysTTL = time.Hour
+	// minRefreshInterval limits how often tokens with an unknown key ID can
+	// trigger a fetch
+	minRefreshInterval = 10 * time.Second
+	clockSkew          = 30 * time.Second
+)
+
+// signingMethods are the algorithms Keycloak signs tokens with
+var signingMethods = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}
+
+// Verifier verifies service and user tokens offline against the realm's
+// signing keys
+type Verifier struct {
+	issuer     string
+	audience   string
+	jwksURL    string
+	httpClient *http.Client
+	now        func() time.Time
+
+	mu          sync.Mutex
+	keys        map[string]crypto.PublicKey
+	fetchedAt   time.Time
+	lastAttempt time.Time
+}
+
+// NewVerifier returns a verifier for tokens with the given iss claim and
+// audience, signed with the keys served at jwksURL
+func NewVerifier(issuer string, audience string, jwksURL string, httpClient *http.Client) *Verifier {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	return &Verifier{
+		issuer:     issuer,
+		audience:   audience,
+		jwksURL:    jwksURL,
+		httpClient: httpClient,
+		now:        time.Now,
+	}
+}
+
+type tokenClaims struct {
+	jwt.RegisteredClaims
+	Type              string `json:"typ"`
+	PreferredUsername string `json:"preferred_username"`
+	AuthorizedParty   string `json:"azp"`
+	// ClientID is only added to tokens of service accounts, by the mapper
+	// of Keycloak's service_account client scope
+	ClientID string `json:"client_id"`
+}
+
+// VerifyServiceToken returns the client ID of the service a token was issued
+// to. Tokens of users are rejected, even if they were issued to a service's
+// client or the user is named like a service account.
+func (v *Verifier) VerifyServiceToken(ctx context.Context, token string) (string, error) {
+	claims, err := v.parse(ctx, token)
+	if err != nil {
+		return "", err
+	}
+
+	if !strings.HasPrefix(claims.PreferredUsername, ServiceAccountPrefix) || claims.AuthorizedParty == "" || claims.ClientID != claims.AuthorizedParty {
+		return "", fmt.Errorf("%w: not issued to a service account", ErrInvalidToken)
+	}
+
+	return claims.AuthorizedParty, nil
+}
+
+// VerifyUserToken returns the subject of a user's access token. Tokens of
+// service accounts are rejected.
+func (v *Verifier) VerifyUserToken(ctx context.Context, token string) (string, error) {
+	claims, err := v.parse(ctx, token)
+	if err != nil {
+		return "", err
+	}
+
+	// Keycloak also signs ID and refresh tokens with the realm keys
+	if claims.Type != "Bearer" {
+		return "", fmt.Errorf("%w: token type is %q, expected an access token", ErrInvalidToken, claims.Type)
+	}
+	if claims.ClientID != "" || claims.Subject == "" {
+		return "", fmt.Errorf("%w: not issued to a user", ErrInvalidToken)
+	}
+
+	return claims.Subject, nil
+}
+
+// parse checks the token's signature, issuer, audience and validity window
+// and returns its claims
+func (v *Verifier) parse(ctx context.Context, token string) (*tokenClaims, error) {
+	var claims tokenClaims
+	_, err := jwt.ParseWithClaims(token, &claims, func(t *jwt.Token) (any, error) {
+		kid, _ := t.Header["kid"].(string)
+		return v.key(ctx, kid)
+	},
+		jwt.WithValidMethods(signingMethods),
+		jwt.WithIssuer(v.issuer),
+		jwt.WithAudience(v.audience),
+		jwt.WithExpirationRequired(),
+		jwt.WithLeeway(clockSkew),
+		jwt.WithTimeFunc(v.now),
+	)
+	if errors.Is(err, ErrUnavailable) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidToken, err)
+	}
+
+	return &claims, nil
+}
+
+// key returns the signing key with the given ID, fetching the keys again
+// when they are old or the ID is unknown
+func (v *Verifier) key(ctx context.Context, kid string) (crypto.PublicKey, error) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	now := v.now()
+	key, known := v.keys[kid]
+	if known && now.Sub(v.fetchedAt) < keysTTL {
+		return key, nil
+	}
+
+	if now.Sub(v.lastAttempt) >= minRefreshInterval {
+		v.lastAttempt = now
+		keys, err := v.fetchKeys(ctx)
+		switch {
+		case err == nil:
+			v.keys, v.fetchedAt = keys, now
+		case v.keys == nil:
+			return nil, err
+		}
+		// Old keys keep working while the endpoint is down
+	}
+
+	if key, ok := v.keys[kid]; ok {
+		return key, nil
+	}
+	return nil, fmt.Errorf("unknown signing key %q", kid)
+}
+
+type jsonWebKey struct {
+	KeyID   string `json:"kid"`
+	KeyType string `json:"kty"`
+	Use     string `json:"use"`
+	N       string `json:"n"`
+	E       string `json:"e"`
+	Curve   string `json:"crv"`
+	X       string `json:"x"`
+	Y       string `json:"y"`
+}
+
+func (v *Verifier) fetchKeys(ctx context.Context) (map[string]crypto.PublicKey, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, v.jwksURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create JWKS request: %w", err)
+	}
+
+	resp, err := v.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to fetch signing keys: %w", ErrUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: fetching signing keys failed with %d", ErrUnavailable, resp.StatusCode)
+	}
+
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, fmt.Errorf("%w: failed to decode signing keys: %w", ErrUnavailable, err)
+	}
+
+	keys := make(map[string]crypto.PublicKey, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		// Keys of unsupported types are skipped rather than failing the whole set
+		if key, err := jwk.publicKey(); err == nil {
+			keys[jwk.KeyID] = key
+		}
+	}
+
+	return keys, nil
+}
+
+func (k jsonWebKey) publicKey() (crypto.PublicKey, error) {
+	switch k.KeyType {
+	case "RSA":
+		n, err := decodeInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := decodeInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+		if !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return nil, fmt.Errorf("RSA exponent out of range")
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Curve {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Curve)
+		}
+		x, err := decodeInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := decodeInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.KeyType)
+	}
+}
+
+func decodeInt(value string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("invalid key parameter: %w", err)
+	}
+	return new(big.Int).SetBytes(b), nil
+}
@@ -0,0 +1,174 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+const (
+	testIssuer   = "https://keycloak.example.com/realms/intellifinder"
+	testAudience = "permissions"
+)
+
+// newTestKeys serves a fresh RSA key as a JWKS and returns a function that
+// signs tokens with it
+func newTestKeys(t *testing.T) (*httptest.Server, func(jwt.MapClaims) string) {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		json.NewEncoder(w).Encode(map[string]any{"keys": []map[string]string{{
+			"kid": "key-1",
+			"kty": "RSA",
+			"use": "sig",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	}))
+	t.Cleanup(server.Close)
+
+	sign := func(claims jwt.MapClaims) string {
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+		token.Header["kid"] = "key-1"
+		signed, err := token.SignedString(key)
+		if err != nil {
+			t.Fatalf("failed to sign token: %v", err)
+		}
+		return signed
+	}
+
+	return server, sign
+}
+
+func serviceToken(azp string, username string, expiresAt time.Time) jwt.MapClaims {
+	return jwt.MapClaims{
+		"iss":                testIssuer,
+		"aud":                []string{testAudience},
+		"exp":                expiresAt.Unix(),
+		"azp":                azp,
+		"client_id":          azp,
+		"preferred_username": username,
+	}
+}
+
+func TestVerifyServiceToken(t *testing.T) {
+	server, sign := newTestKeys(t)
+	verifier := NewVerifier(testIssuer, testAudience, server.URL, server.Client())
+	ctx := context.Background()
+	valid := time.Now().Add(time.Minute)
+
+	name, err := verifier.VerifyServiceToken(ctx, sign(serviceToken("tasks", "service-account-tasks", valid)))
+	if err != nil || name != "tasks" {
+		t.Errorf("VerifyServiceToken() = %q, %v, want tasks", name, err)
+	}
+
+	otherIssuer := serviceToken("tasks", "service-account-tasks", valid)
+	otherIssuer["iss"] = "https://evil.example.com"
+	otherAudience := serviceToken("tasks", "service-account-tasks", valid)
+	otherAudience["aud"] = []string{"tasks"}
+	// A user created with a service account's name and logged in through
+	// the service's client
+	impostor := serviceToken("tasks", "service-account-tasks", valid)
+	delete(impostor, "client_id")
+	otherClient := serviceToken("tasks", "service-account-tasks", valid)
+	otherClient["client_id"] = "reports"
+
+	tests := map[string]string{
+		"user token":     sign(serviceToken("tasks", "alice", valid)),
+		"impostor token": sign(impostor),
+		"other client":   sign(otherClient),
+		"expired token":  sign(serviceToken("tasks", "service-account-tasks", time.Now().Add(-time.Hour))),
+		"other issuer":   sign(otherIssuer),
+		"other audience": sign(otherAudience),
+		"garbage":        "not-a-token",
+	}
+	for name, token := range tests {
+		if _, err := verifier.VerifyServiceToken(ctx, token); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("VerifyServiceToken(%s) error = %v, want ErrInvalidToken", name, err)
+		}
+	}
+}
+
+func TestVerifyUserToken(t *testing.T) {
+	server, sign := newTestKeys(t)
+	verifier := NewVerifier(testIssuer, testAudience, server.URL, server.Client())
+	ctx := context.Background()
+	valid := time.Now().Add(time.Minute)
+
+	userToken := func() jwt.MapClaims {
+		return jwt.MapClaims{
+			"iss":                testIssuer,
+			"aud":                []string{testAudience},
+			"exp":                valid.Unix(),
+			"typ":                "Bearer",
+			"sub":                "kc-alice",
+			"azp":                "intellifinder-web",
+			"preferred_username": "alice",
+		}
+	}
+
+	subject, err := verifier.VerifyUserToken(ctx, sign(userToken()))
+	if err != nil || subject != "kc-alice" {
+		t.Errorf("VerifyUserToken() = %q, %v, want kc-alice", subject, err)
+	}
+
+	idToken := userToken()
+	idToken["typ"] = "ID"
+	noSubject := userToken()
+	delete(noSubject, "sub")
+	otherAudience := userToken()
+	otherAudience["aud"] = []string{"tasks"}
+
+	tests := map[string]string{
+		"service token":  sign(serviceToken("tasks", "service-account-tasks", valid)),
+		"ID token":       sign(idToken),
+		"no subject":     sign(noSubject),
+		"other audience": sign(otherAudience),
+		"garbage":        "not-a-token",
+	}
+	for name, token := range tests {
+		if _, err := verifier.VerifyUserToken(ctx, token); !errors.Is(err, ErrInvalidToken) {
+			t.Errorf("VerifyUserToken(%s) error = %v, want ErrInvalidToken", name, err)
+		}
+	}
+}
+
+func TestVerifyServiceTokenWithoutKeys(t *testing.T) {
+	_, sign := newTestKeys(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	t.Cleanup(server.Close)
+
+	verifier := NewVerifier(testIssuer, testAudience, server.URL, server.Client())
+	token := sign(serviceToken("tasks", "service-account-tasks", time.Now().Add(time.Minute)))
+	if _, err := verifier.VerifyServiceToken(context.Background(), token); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("VerifyServiceToken() error = %v, want ErrUnavailable", err)
+	}
+}
+
+func TestJSONWebKeyExponentOutOfRange(t *testing.T) {
+	key := jsonWebKey{
+		KeyType: "RSA",
+		N:       base64.RawURLEncoding.EncodeToString(big.NewInt(1).Lsh(big.NewInt(1), 2047).Bytes()),
+		E:       base64.RawURLEncoding.EncodeToString(big.NewInt(1 << 40).Bytes()),
+	}
+	if _, err := key.publicKey(); err == nil {
+		t.Error("publicKey() error = nil, want an error for an exponent above 2^31-1")
+	}
+}
@@ -0,0 +1,148 @@
+package observability
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// RequestIDHeader is read from incoming metadata and echoed back in the response headers
+	RequestIDHeader = "x-request-id"
+	// TraceParentHeader is the W3C trace context header propagated by Traefik and OpenTelemetry
+	TraceParentHeader = "traceparent"
+	// UserIDHeader is set by the ForwardAuth middleware for authenticated requests
+	UserIDHeader = "x-user-id"
+)
+
+// UnaryServerLogging logs one entry per unary RPC and stores a request-scoped
+// logger in the handler context.
+func UnaryServerLogging(logger *zap.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		start := time.Now()
+		reqLogger := requestLogger(ctx, logger, info.FullMethod)
+
+		resp, err := handler(WithLogger(ctx, reqLogger), req)
+
+		logCompletion(reqLogger, start, err)
+		return resp, err
+	}
+}
+
+// StreamServerLogging logs one entry per streaming RPC once the stream has finished.
+func StreamServerLogging(logger *zap.Logger) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+		reqLogger := requestLogger(ss.Context(), logger, info.FullMethod)
+
+		err := handler(srv, &loggingStream{
+			ServerStream: ss,
+			ctx:          WithLogger(ss.Context(), reqLogger),
+		})
+
+		logCompletion(reqLogger, start, err)
+		return err
+	}
+}
+
+type loggingStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *loggingStream) Context() context.Context {
+	return s.ctx
+}
+
+func requestLogger(ctx context.Context, logger *zap.Logger, fullMethod string) *zap.Logger {
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	requestID := firstValue(md, RequestIDHeader)
+	if requestID == "" {
+		requestID = newRequestID()
+	}
+	_ = grpc.SetHeader(ctx, metadata.Pairs(RequestIDHeader, requestID))
+
+	fields := []zap.Field{
+		zap.String("request_id", requestID),
+		zap.String("rpc.method", fullMethod),
+	}
+
+	if traceID, spanID := parseTraceParent(firstValue(md, TraceParentHeader)); traceID != "" {
+		fields = append(fields, zap.String("trace_id", traceID), zap.String("span_id", spanID))
+	}
+
+	if caller := firstValue(md, UserIDHeader); caller != "" {
+		fields = append(fields, zap.String("caller", caller))
+	}
+
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		fields = append(fields, zap.String("peer", p.Addr.String()))
+	}
+
+	return logger.With(fields...)
+}
+
+func logCompletion(logger *zap.Logger, start time.Time, err error) {
+	code := status.Code(err)
+	fields := []zap.Field{
+		zap.String("rpc.code", code.String()),
+		zap.Duration("duration", time.Since(start)),
+	}
+	if err != nil {
+		fields = append(fields, zap.Error(err))
+	}
+
+	if ce := logger.Check(levelForCode(code), "rpc completed"); ce != nil {
+		ce.Write(fields...)
+	}
+}
+
+// levelForCode logs client mistakes as warnings and only server-side failures as errors
+func levelForCode(code codes.Code) zapcore.Level {
+	switch code {
+	case codes.OK:
+		return zapcore.InfoLevel
+	case codes.Canceled, codes.InvalidArgument, codes.NotFound, codes.AlreadyExists,
+		codes.PermissionDenied, codes.Unauthenticated, codes.FailedPrecondition,
+		codes.OutOfRange, codes.ResourceExhausted:
+		return zapcore.WarnLevel
+	default:
+		return zapcore.ErrorLevel
+	}
+}
+
+// parseTraceParent extracts the trace and span IDs from a W3C traceparent
+// header ("00-<trace-id>-<span-id>-<flags>"). Malformed values are ignored.
+func parseTraceParent(value string) (traceID, spanID string) {
+	parts := strings.Split(value, "-")
+	if len(parts) != 4 || len(parts[1]) != 32 || len(parts[2]) != 16 {
+		return "", ""
+	}
+	return parts[1], parts[2]
+}
+
+func firstValue(md metadata.MD, key string) string {
+	if values := md.Get(key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return ""
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,246 @@
+package observability
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+const testTraceParent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+// transportStream records the headers the interceptors send back
+type transportStream struct {
+	header metadata.MD
+}
+
+func (s *transportStream) Method() string { return "" }
+
+func (s *transportStream) SetHeader(md metadata.MD) error {
+	s.header = metadata.Join(s.header, md)
+	return nil
+}
+
+func (s *transportStream) SendHeader(md metadata.MD) error { return s.SetHeader(md) }
+
+func (s *transportStream) SetTrailer(metadata.MD) error { return nil }
+
+// serverStream is a stream of the incoming call in ctx
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context { return s.ctx }
+
+// incomingContext returns the context of a call from 10.0.0.1 with the
+// given metadata, and the transport stream receiving its response headers
+func incomingContext(pairs ...string) (context.Context, *transportStream) {
+	transport := &transportStream{}
+	ctx := grpc.NewContextWithServerTransportStream(context.Background(), transport)
+	ctx = peer.NewContext(ctx, &peer.Peer{Addr: &net.TCPAddr{IP: net.IPv4(10, 0, 0, 1), Port: 4321}})
+	return metadata.NewIncomingContext(ctx, metadata.Pairs(pairs...)), transport
+}
+
+func TestParseTraceParent(t *testing.T) {
+	traceID, spanID := parseTraceParent(testTraceParent)
+	if traceID != "4bf92f3577b34da6a3ce929d0e0e4736" || spanID != "00f067aa0ba902b7" {
+		t.Errorf("parseTraceParent() = %v, %v", traceID, spanID)
+	}
+
+	// Test with malformed header
+	if traceID, _ := parseTraceParent("not-a-traceparent"); traceID != "" {
+		t.Errorf("parseTraceParent() = %v, want empty", traceID)
+	}
+}
+
+func TestLevelForCode(t *testing.T) {
+	tests := map[codes.Code]zapcore.Level{
+		codes.OK:              zapcore.InfoLevel,
+		codes.InvalidArgument: zapcore.WarnLevel,
+		codes.Internal:        zapcore.ErrorLevel,
+		codes.Unknown:         zapcore.ErrorLevel,
+	}
+
+	for code, want := range tests {
+		if got := levelForCode(code); got != want {
+			t.Errorf("levelForCode(%v) = %v, want %v", code, got, want)
+		}
+	}
+}
+
+func TestUnaryServerLogging(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	interceptor := UnaryServerLogging(zap.New(core))
+	info := &grpc.UnaryServerInfo{FullMethod: "/tasks.v1.TaskService/GetTask"}
+
+	ctx, transport := incomingContext(RequestIDHeader, "req-1", TraceParentHeader, testTraceParent, UserIDHeader, "user-1")
+	resp, err := interceptor(ctx, "request", info, func(ctx context.Context, req any) (any, error) {
+		Logger(ctx).Info("handling")
+		return "response", nil
+	})
+	if resp != "response" || err != nil {
+		t.Fatalf("interceptor() = %v, %v, want the handler's response", resp, err)
+	}
+	if got := transport.header.Get(RequestIDHeader); len(got) != 1 || got[0] != "req-1" {
+		t.Errorf("response %s = %v, want [req-1]", RequestIDHeader, got)
+	}
+
+	entries := logs.All()
+	if len(entries) != 2 {
+		t.Fatalf("logged %d entries, want 2", len(entries))
+	}
+	for _, entry := range entries {
+		fields := entry.ContextMap()
+		if fields["request_id"] != "req-1" || fields["rpc.method"] != info.FullMethod ||
+			fields["trace_id"] != "4bf92f3577b34da6a3ce929d0e0e4736" || fields["span_id"] != "00f067aa0ba902b7" ||
+			fields["caller"] != "user-1" || fields["peer"] != "10.0.0.1:4321" {
+			t.Errorf("entry %q fields = %v, want the request's ID, method, trace, caller and peer", entry.Message, fields)
+		}
+	}
+
+	completion := entries[1]
+	fields := completion.ContextMap()
+	if completion.Message != "rpc completed" || completion.Level != zapcore.InfoLevel || fields["rpc.code"] != "OK" {
+		t.Errorf("completion entry = %v %q %v, want rpc completed at info with code OK", completion.Level, completion.Message, fields)
+	}
+	if _, ok := fields["duration"].(time.Duration); !ok {
+		t.Errorf("completion fields = %v, want a duration", fields)
+	}
+	if _, ok := fields["error"]; ok {
+		t.Errorf("completion fields = %v, want no error", fields)
+	}
+}
+
+func TestUnaryServerLoggingGeneratesRequestID(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	interceptor := UnaryServerLogging(zap.New(core))
+
+	ctx, transport := incomingContext(TraceParentHeader, "not-a-traceparent")
+	_, _ = interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/tasks.v1.TaskService/GetTask"}, func(context.Context, any) (any, error) {
+		return nil, nil
+	})
+
+	fields := logs.All()[0].ContextMap()
+	requestID, _ := fields["request_id"].(string)
+	if len(requestID) != 32 {
+		t.Errorf("request_id = %q, want a generated ID of 32 hex digits", requestID)
+	}
+	if got := transport.header.Get(RequestIDHeader); len(got) != 1 || got[0] != requestID {
+		t.Errorf("response %s = %v, want the generated [%s]", RequestIDHeader, got, requestID)
+	}
+	for _, field := range []string{"trace_id", "span_id", "caller"} {
+		if _, ok := fields[field]; ok {
+			t.Errorf("fields = %v, want no %s when the request has none", fields, field)
+		}
+	}
+}
+
+func TestUnaryServerLoggingLevels(t *testing.T) {
+	tests := []struct {
+		code codes.Code
+		want zapcore.Level
+	}{
+		{codes.OK, zapcore.InfoLevel},
+		{codes.Canceled, zapcore.WarnLevel},
+		{codes.InvalidArgument, zapcore.WarnLevel},
+		{codes.NotFound, zapcore.WarnLevel},
+		{codes.AlreadyExists, zapcore.WarnLevel},
+		{codes.PermissionDenied, zapcore.WarnLevel},
+		{codes.Unauthenticated, zapcore.WarnLevel},
+		{codes.FailedPrecondition, zapcore.WarnLevel},
+		{codes.OutOfRange, zapcore.WarnLevel},
+		{codes.ResourceExhausted, zapcore.WarnLevel},
+		{codes.Unknown, zapcore.ErrorLevel},
+		{codes.DeadlineExceeded, zapcore.ErrorLevel},
+		{codes.Aborted, zapcore.ErrorLevel},
+		{codes.Unimplemented, zapcore.ErrorLevel},
+		{codes.Internal, zapcore.ErrorLevel},
+		{codes.Unavailable, zapcore.ErrorLevel},
+		{codes.DataLoss, zapcore.ErrorLevel},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.code.String(), func(t *testing.T) {
+			core, logs := observer.New(zapcore.DebugLevel)
+			interceptor := UnaryServerLogging(zap.New(core))
+
+			var handlerErr error
+			if tt.code != codes.OK {
+				handlerErr = status.Error(tt.code, "failed")
+			}
+			ctx, _ := incomingContext()
+			_, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/tasks.v1.TaskService/GetTask"}, func(context.Context, any) (any, error) {
+				return nil, handlerErr
+			})
+			if err != handlerErr {
+				t.Errorf("interceptor() error = %v, want the handler's %v", err, handlerErr)
+			}
+
+			entries := logs.All()
+			if len(entries) != 1 {
+				t.Fatalf("logged %d entries, want 1", len(entries))
+			}
+			fields := entries[0].ContextMap()
+			if entries[0].Level != tt.want || fields["rpc.code"] != tt.code.String() {
+				t.Errorf("entry = %v %v, want %v with code %s", entries[0].Level, fields, tt.want, tt.code)
+			}
+			if _, ok := fields["error"]; ok != (tt.code != codes.OK) {
+				t.Errorf("fields = %v, want the error logged only for failures", fields)
+			}
+		})
+	}
+}
+
+func TestStreamServerLogging(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	interceptor := StreamServerLogging(zap.New(core))
+	info := &grpc.StreamServerInfo{FullMethod: "/tasks.v1.TaskService/WatchTasks", IsServerStream: true}
+
+	ctx, transport := incomingContext(RequestIDHeader, "req-2", TraceParentHeader, testTraceParent, UserIDHeader, "user-2")
+	handlerErr := status.Error(codes.Unavailable, "backend down")
+	err := interceptor(nil, &serverStream{ctx: ctx}, info, func(_ any, stream grpc.ServerStream) error {
+		Logger(stream.Context()).Info("streaming")
+		return handlerErr
+	})
+	if !errors.Is(err, handlerErr) {
+		t.Fatalf("interceptor() error = %v, want the handler's %v", err, handlerErr)
+	}
+	if got := transport.header.Get(RequestIDHeader); len(got) != 1 || got[0] != "req-2" {
+		t.Errorf("response %s = %v, want [req-2]", RequestIDHeader, got)
+	}
+
+	entries := logs.All()
+	if len(entries) != 2 {
+		t.Fatalf("logged %d entries, want 2", len(entries))
+	}
+	if entries[0].Message != "streaming" {
+		t.Errorf("first entry = %q, want the handler's, logged through the stream's context", entries[0].Message)
+	}
+	for _, entry := range entries {
+		fields := entry.ContextMap()
+		if fields["request_id"] != "req-2" || fields["rpc.method"] != info.FullMethod ||
+			fields["trace_id"] != "4bf92f3577b34da6a3ce929d0e0e4736" || fields["caller"] != "user-2" || fields["peer"] != "10.0.0.1:4321" {
+			t.Errorf("entry %q fields = %v, want the request's ID, method, trace, caller and peer", entry.Message, fields)
+		}
+	}
+
+	completion := entries[1]
+	fields := completion.ContextMap()
+	if completion.Message != "rpc completed" || completion.Level != zapcore.ErrorLevel || fields["rpc.code"] != "Unavailable" || fields["error"] == nil {
+		t.Errorf("completion entry = %v %q %v, want rpc completed at error with code Unavailable and the error", completion.Level, completion.Message, fields)
+	}
+	if _, ok := fields["duration"].(time.Duration); !ok {
+		t.Errorf("completion fields = %v, want a duration", fields)
+	}
+}
@@ -0,0 +1,85 @@
+package observability
+
+import (
+	"net"
+	"net/http"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// HTTPServerLogging wraps an HTTP handler like UnaryServerLogging wraps a
+// gRPC one: one entry per request, and a request-scoped logger in the
+// request context.
+func HTTPServerLogging(logger *zap.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+
+			requestID := r.Header.Get(RequestIDHeader)
+			if requestID == "" {
+				requestID = newRequestID()
+			}
+			w.Header().Set(RequestIDHeader, requestID)
+
+			fields := []zap.Field{
+				zap.String("request_id", requestID),
+				zap.String("http.method", r.Method),
+				zap.String("http.path", r.URL.Path),
+			}
+			if traceID, spanID := parseTraceParent(r.Header.Get(TraceParentHeader)); traceID != "" {
+				fields = append(fields, zap.String("trace_id", traceID), zap.String("span_id", spanID))
+			}
+			if caller := r.Header.Get(UserIDHeader); caller != "" {
+				fields = append(fields, zap.String("caller", caller))
+			}
+			if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+				fields = append(fields, zap.String("peer", host))
+			}
+			reqLogger := logger.With(fields...)
+
+			sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(sw, r.WithContext(WithLogger(r.Context(), reqLogger)))
+
+			if ce := reqLogger.Check(levelForStatus(sw.status), "request completed"); ce != nil {
+				ce.Write(zap.Int("http.status", sw.status), zap.Duration("duration", time.Since(start)))
+			}
+		})
+	}
+}
+
+type statusWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (w *statusWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status = status
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	w.wroteHeader = true
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// levelForStatus mirrors levelForCode: 4xx responses are the client's fault
+func levelForStatus(status int) zapcore.Level {
+	switch {
+	case status >= 500:
+		return zapcore.ErrorLevel
+	case status >= 400:
+		return zapcore.WarnLevel
+	default:
+		return zapcore.InfoLevel
+	}
+}
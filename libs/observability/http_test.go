@@ -0,0 +1,39 @@
+package observability
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestHTTPServerLogging(t *testing.T) {
+	core, logs := observer.New(zapcore.InfoLevel)
+	handler := HTTPServerLogging(zap.New(core))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		Logger(r.Context()).Info("handling")
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/auth/validate", nil)
+	req.Header.Set(RequestIDHeader, "req-1")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if got := rec.Header().Get(RequestIDHeader); got != "req-1" {
+		t.Errorf("response %s = %q, want req-1", RequestIDHeader, got)
+	}
+
+	entries := logs.All()
+	if len(entries) != 2 {
+		t.Fatalf("logged %d entries, want 2", len(entries))
+	}
+	if fields := entries[0].ContextMap(); fields["request_id"] != "req-1" || fields["http.path"] != "/auth/validate" {
+		t.Errorf("handler entry fields = %v, want the request ID and path", fields)
+	}
+	if entries[1].Level != zapcore.WarnLevel || entries[1].ContextMap()["http.status"] != int64(http.StatusUnauthorized) {
+		t.Errorf("completion entry = %v %v, want a warning with status 401", entries[1].Level, entries[1].ContextMap())
+	}
+}
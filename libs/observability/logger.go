@@ -0,0 +1,33 @@
+package observability
+
+import (
+	"fmt"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// NewLogger creates a JSON logger suitable for shipping to Loki. Every entry
+// carries the service name so logs from different containers can be told apart.
+func NewLogger(service, level string) (*zap.Logger, error) {
+	lvl, err := zapcore.ParseLevel(level)
+	if err != nil {
+		return nil, fmt.Errorf("invalid log level %q: %w", level, err)
+	}
+
+	cfg := zap.NewProductionConfig()
+	cfg.Level = zap.NewAtomicLevelAt(lvl)
+	cfg.Encoding = "json"
+	cfg.EncoderConfig.TimeKey = "time"
+	cfg.EncoderConfig.MessageKey = "msg"
+	cfg.EncoderConfig.EncodeTime = zapcore.RFC3339NanoTimeEncoder
+	cfg.EncoderConfig.EncodeDuration = zapcore.MillisDurationEncoder
+	cfg.DisableStacktrace = true
+
+	logger, err := cfg.Build()
+	if err != nil {
+		return nil, fmt.Errorf("failed to build logger: %w", err)
+	}
+
+	return logger.With(zap.String("service", service)), nil
+}
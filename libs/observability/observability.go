@@ -1,3 +1,2 @@
+// Package observability holds the logging, metrics and tracing helpers shared by all services.
 package observability
-
-// Placeholder package for observability utilities
@@ -0,0 +1,194 @@
+// Package config binds environment variables and an optional YAML file into
+// typed configuration structs.
+//
+// Fields are described with struct tags:
+//
+//	type Config struct {
+//		Port        int           `env:"PORT" yaml:"port" default:"8080"`
+//		DatabaseURL string        `env:"DATABASE_URL" yaml:"database_url" required:"true" secret:"true"`
+//		Timeout     time.Duration `env:"TIMEOUT" yaml:"timeout" default:"5s"`
+//		Origins     []string      `env:"ALLOWED_ORIGINS" yaml:"allowed_origins"`
+//	}
+//
+// Values are resolved in order of increasing precedence: default tag, YAML
+// file, environment. A required field must end up with a non-zero value.
+// Nested structs are walked recursively; their env names can be prefixed with
+// the `envPrefix` tag.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FileEnv names the environment variable that points at an optional YAML config file
+const FileEnv = "CONFIG_FILE"
+
+// Validator can be implemented by config structs to add cross-field checks
+// that run after all values have been loaded.
+type Validator interface {
+	Validate() error
+}
+
+// FieldError describes a single invalid setting
+type FieldError struct {
+	Field string
+	Env   string
+	Err   error
+}
+
+func (e *FieldError) Error() string {
+	if e.Env != "" {
+		return fmt.Sprintf("%s (%s): %v", e.Field, e.Env, e.Err)
+	}
+	return fmt.Sprintf("%s: %v", e.Field, e.Err)
+}
+
+func (e *FieldError) Unwrap() error {
+	return e.Err
+}
+
+// ValidationError lists every invalid setting found while loading
+type ValidationError struct {
+	Errors []error
+}
+
+func (e *ValidationError) Error() string {
+	lines := make([]string, len(e.Errors))
+	for i, err := range e.Errors {
+		lines[i] = "  - " + err.Error()
+	}
+	return fmt.Sprintf("invalid configuration:\n%s", strings.Join(lines, "\n"))
+}
+
+// ErrRequired is reported for required fields that were not set
+var ErrRequired = errors.New("required but not set")
+
+type options struct {
+	file     string
+	lookupFn func(string) (string, bool)
+}
+
+// Option customises Load
+type Option func(*options)
+
+// WithFile loads the given YAML file before applying environment variables.
+// It takes precedence over the CONFIG_FILE environment variable.
+func WithFile(path string) Option {
+	return func(o *options) {
+		o.file = path
+	}
+}
+
+// WithLookup replaces os.LookupEnv, mainly for tests
+func WithLookup(fn func(string) (string, bool)) Option {
+	return func(o *options) {
+		o.lookupFn = fn
+	}
+}
+
+// Load populates the struct pointed to by target. It returns a
+// *ValidationError listing every invalid or missing setting at once.
+func Load(target any, opts ...Option) error {
+	o := &options{lookupFn: os.LookupEnv}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.file == "" {
+		o.file, _ = o.lookupFn(FileEnv)
+	}
+
+	v := reflect.ValueOf(target)
+	if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("config target must be a pointer to a struct, got %T", target)
+	}
+
+	var errs []error
+
+	walk(v.Elem(), "", "", func(f field) {
+		if def, ok := f.tag.Lookup("default"); ok {
+			if err := setValue(f.value, def); err != nil {
+				errs = append(errs, &FieldError{Field: f.path, Env: f.env, Err: fmt.Errorf("invalid default: %w", err)})
+			}
+		}
+	})
+
+	if o.file != "" {
+		data, err := os.ReadFile(o.file)
+		if err != nil {
+			return fmt.Errorf("failed to read config file: %w", err)
+		}
+		if err := yaml.Unmarshal(data, target); err != nil {
+			return fmt.Errorf("failed to parse config file %s: %w", o.file, err)
+		}
+	}
+
+	walk(v.Elem(), "", "", func(f field) {
+		if f.env != "" {
+			if raw, ok := o.lookupFn(f.env); ok && raw != "" {
+				if err := setValue(f.value, raw); err != nil {
+					errs = append(errs, &FieldError{Field: f.path, Env: f.env, Err: err})
+					return
+				}
+			}
+		}
+		if f.tag.Get("required") == "true" && f.value.IsZero() {
+			errs = append(errs, &FieldError{Field: f.path, Env: f.env, Err: ErrRequired})
+		}
+	})
+
+	if validator, ok := target.(Validator); ok && len(errs) == 0 {
+		if err := validator.Validate(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if len(errs) > 0 {
+		return &ValidationError{Errors: errs}
+	}
+	return nil
+}
+
+// MustLoad is like Load but exits the process with the validation report on failure
+func MustLoad(target any, opts ...Option) {
+	if err := Load(target, opts...); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+type field struct {
+	path  string
+	env   string
+	tag   reflect.StructTag
+	value reflect.Value
+}
+
+func walk(v reflect.Value, pathPrefix, envPrefix string, fn func(field)) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		if !sf.IsExported() {
+			continue
+		}
+
+		fv := v.Field(i)
+		path := pathPrefix + sf.Name
+
+		if sf.Type.Kind() == reflect.Struct && !isScalar(sf.Type) {
+			walk(fv, path+".", envPrefix+sf.Tag.Get("envPrefix"), fn)
+			continue
+		}
+
+		env := sf.Tag.Get("env")
+		if env != "" {
+			env = envPrefix + env
+		}
+		fn(field{path: path, env: env, tag: sf.Tag, value: fv})
+	}
+}
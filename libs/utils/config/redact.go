@@ -0,0 +1,54 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+const redacted = "******"
+
+// Redact returns the loaded settings keyed by field path with every field
+// tagged `secret:"true"` masked, so the result is safe to log.
+func Redact(cfg any) map[string]string {
+	v := reflect.ValueOf(cfg)
+	if v.Kind() == reflect.Pointer {
+		v = v.Elem()
+	}
+
+	out := make(map[string]string)
+	if v.Kind() != reflect.Struct {
+		return out
+	}
+
+	walk(v, "", "", func(f field) {
+		if f.tag.Get("secret") == "true" {
+			if f.value.IsZero() {
+				out[f.path] = ""
+			} else {
+				out[f.path] = redacted
+			}
+			return
+		}
+		out[f.path] = fmt.Sprint(f.value.Interface())
+	})
+	return out
+}
+
+// String renders the redacted settings as sorted "Field=value" pairs
+func String(cfg any) string {
+	values := Redact(cfg)
+
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		pairs[i] = k + "=" + values[k]
+	}
+	return strings.Join(pairs, " ")
+}
@@ -1,2 +1,375 @@
-package server
-¨
\ No newline at end of file
+package main
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+	// Time zones of profiles are validated whether or not the image has a zoneinfo database
+	_ "time/tzdata"
+
+	"github.com/gin-gonic/gin"
+	serviceauth "github.com/intellifinder/v4/libs/auth"
+	"github.com/intellifinder/v4/libs/observability"
+	authv1 "github.com/intellifinder/v4/services/auth/api/v1"
+	"github.com/intellifinder/v4/services/auth/internal/config"
+	"github.com/intellifinder/v4/services/auth/internal/domain/apikey"
+	"github.com/intellifinder/v4/services/auth/internal/domain/authentication"
+	"github.com/intellifinder/v4/services/auth/internal/domain/impersonation"
+	"github.com/intellifinder/v4/services/auth/internal/domain/ingestion"
+	"github.com/intellifinder/v4/services/auth/internal/domain/profile"
+	"github.com/intellifinder/v4/services/auth/internal/domain/ratelimit"
+	"github.com/intellifinder/v4/services/auth/internal/domain/revocation"
+	"github.com/intellifinder/v4/services/auth/internal/domain/scim"
+	"github.com/intellifinder/v4/services/auth/internal/domain/session"
+	"github.com/intellifinder/v4/services/auth/internal/domain/user"
+	"github.com/intellifinder/v4/services/auth/internal/infrastructure/database"
+	grpcServer "github.com/intellifinder/v4/services/auth/internal/infrastructure/grpc"
+	"github.com/intellifinder/v4/services/auth/internal/infrastructure/keycloak"
+	"github.com/intellifinder/v4/services/auth/internal/infrastructure/metrics"
+	"github.com/intellifinder/v4/services/auth/internal/infrastructure/permissions"
+	"github.com/intellifinder/v4/services/auth/internal/infrastructure/redis"
+	"github.com/intellifinder/v4/services/auth/internal/infrastructure/rest"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	goredis "github.com/redis/go-redis/v9"
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/reflection"
+	envconfig "intellifinder/libs/utils/config"
+)
+
+// shutdownTimeout bounds how long in-flight requests may take to finish on shutdown
+const shutdownTimeout = 10 * time.Second
+
+// reflectionServices can be called without a service token
+var reflectionServices = []string{
+	"/grpc.reflection.v1.ServerReflection/",
+	"/grpc.reflection.v1alpha.ServerReflection/",
+}
+
+func main() {
+	// Load configuration
+	cfg := loadConfig()
+
+	logger, err := observability.NewLogger("auth", cfg.LogLevel)
+	if err != nil {
+		log.Fatalf("failed to create logger: %v", err)
+	}
+	defer logger.Sync()
+
+	logger.Info("Loaded configurations.", zap.Any("config", envconfig.Redact(cfg)))
+
+	// Create context with cancellation
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Create pgx connection pool
+	db, err := pgxpool.New(ctx, cfg.Database.URL)
+	if err != nil {
+		logger.Fatal("failed to create database pool", zap.Error(err))
+	}
+	defer db.Close()
+
+	// Run migration
+	err = database.CreateAPIKeyTable(ctx, db)
+	if err != nil {
+		logger.Fatal("failed to create API key table", zap.Error(err))
+	}
+
+	err = database.CreateUserTable(ctx, db)
+	if err != nil {
+		logger.Fatal("failed to create user table", zap.Error(err))
+	}
+
+	err = database.CreateImpersonationTables(ctx, db)
+	if err != nil {
+		logger.Fatal("failed to create impersonation tables", zap.Error(err))
+	}
+
+	err = database.CreateSCIMTables(ctx, db)
+	if err != nil {
+		logger.Fatal("failed to create SCIM tables", zap.Error(err))
+	}
+
+	err = database.CreateProfileTables(ctx, db)
+	if err != nil {
+		logger.Fatal("failed to create profile tables", zap.Error(err))
+	}
+
+	logger.Info("Migration completed successfully!")
+
+	// Create Redis client
+	redisOptions, err := goredis.ParseURL(cfg.Redis.URL)
+	if err != nil {
+		logger.Fatal("failed to parse Redis URL", zap.Error(err))
+	}
+	redisClient := goredis.NewClient(redisOptions)
+	defer redisClient.Close()
+
+	if err := redisClient.Ping(ctx).Err(); err != nil {
+		logger.Fatal("failed to connect to Redis", zap.Error(err))
+	}
+
+	repo := database.NewRepository(db)
+	redisRepo := redis.NewRepository(redisClient)
+	revocationService := revocation.NewService(redisRepo, cfg.AccessTokenMaxLifetime)
+	httpClient := &http.Client{Timeout: 10 * time.Second}
+	keycloakClient := keycloak.NewClient(cfg.Keycloak, httpClient)
+	apiKeyService := apikey.NewService(repo, keycloak.NewUserDirectory(keycloakClient), cfg.APIKeyMaxLifetime)
+
+	userService := user.NewService(repo)
+	userService.SetDirectory(keycloak.NewUserDirectory(keycloakClient))
+	userService.SetRevoker(revocationService)
+	userService.SetAPIKeyRevoker(apiKeyService)
+
+	validator := keycloak.NewValidator(cfg.Keycloak, httpClient)
+	authService := authentication.NewService(validator, cfg.ValidationCacheTTL)
+	authService.SetAPIKeyVerifier(apiKeyService)
+	authService.SetUserProvisioner(userService, func(err error) {
+		logger.Warn("failed to provision user", zap.Error(err))
+	})
+	authService.SetTokenIssuer(keycloak.NewTokenIssuer(keycloakClient))
+	authService.SetRevocationChecker(revocationService)
+
+	sessionService := session.NewService(redisRepo, authService, cfg.SessionMaxLifetime)
+	sessionService.SetRevoker(revocationService)
+	userService.SetSessionRevoker(sessionService)
+
+	impersonationService := impersonation.NewService(repo, userService, cfg.Impersonation.TokenLifetime)
+	impersonationService.SetRevoker(revocationService)
+	if cfg.Impersonation.Enabled() {
+		signingKey, err := impersonation.ParseSigningKey(cfg.Impersonation.SigningKey)
+		if err != nil {
+			logger.Fatal("failed to parse impersonation signing key", zap.Error(err))
+		}
+
+		// The permissions service only accepts calls with a service token,
+		// requested with the auth service's own Keycloak client
+		serviceTokens := serviceauth.NewClientCredentials(cfg.Keycloak.TokenURL(), cfg.Keycloak.ClientID, cfg.Keycloak.ClientSecret, httpClient)
+		permissionsConn, err := grpc.NewClient(cfg.Impersonation.PermissionsAddr,
+			grpc.WithTransportCredentials(insecure.NewCredentials()),
+			grpc.WithUnaryInterceptor(serviceauth.UnaryClientInterceptor(serviceTokens)),
+		)
+		if err != nil {
+			logger.Fatal("failed to create permissions client", zap.Error(err))
+		}
+		defer permissionsConn.Close()
+
+		impersonationService.SetSigningKey(signingKey)
+		impersonationService.SetDirectory(keycloak.NewUserDirectory(keycloakClient))
+		impersonationService.SetPermissionChecker(permissions.NewChecker(permissionsConn))
+		authService.SetImpersonationVerifier(impersonationService)
+	}
+
+	if cfg.UserSyncInterval > 0 {
+		go reconcileUsers(ctx, userService, cfg.UserSyncInterval, logger)
+	}
+
+	// Changes made directly in Keycloak arrive through the webhook, with
+	// polling catching what it missed; Redis claims apply each event once
+	eventService := ingestion.NewService(redisRepo, userService, sessionService, revocationService, redisRepo)
+	eventSource := keycloak.NewEventSource(keycloakClient)
+	if cfg.Events.WebhookSecret != "" {
+		eventService.SetWebhook(eventSource, []byte(cfg.Events.WebhookSecret))
+	}
+	eventService.SetSource(eventSource)
+	if cfg.Events.PollInterval > 0 {
+		go pollEvents(ctx, eventService, cfg.Events.PollInterval, logger)
+	}
+
+	// Lockouts of accounts are published for the notifications service to tell their owners
+	userService.SetPublisher(redisRepo)
+	limits := ratelimit.NewService(redisRepo)
+	configureRateLimits(limits, cfg.RateLimits)
+	limits.SetRecorder(metrics.NewRateLimits(prometheus.DefaultRegisterer))
+	limits.SetNotifier(userService, func(err error) {
+		logger.Warn("failed to notify lockout", zap.Error(err))
+	})
+
+	// Tenants' identity providers provision users and groups into Keycloak
+	provisioningService := scim.NewService(repo, userService, keycloak.NewTenantDirectory(keycloakClient))
+
+	profileService := profile.NewService(repo, userService)
+	if cfg.PreferenceSchemaDir != "" {
+		clients, err := profileService.LoadSchemas(os.DirFS(cfg.PreferenceSchemaDir))
+		if err != nil {
+			logger.Fatal("failed to load preference schemas", zap.Error(err))
+		}
+		logger.Info("Preference schemas loaded", zap.Strings("clients", clients))
+	}
+
+	gin.SetMode(gin.ReleaseMode)
+	router := rest.NewRouter(rest.NewHandler(authService, apiKeyService, userService, sessionService, revocationService, limits, impersonationService, eventService, provisioningService, profileService))
+	if err := router.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		logger.Fatal("failed to set trusted proxies", zap.Error(err))
+	}
+	router.GET("/metrics", gin.WrapH(promhttp.Handler()))
+
+	server := &http.Server{
+		Addr:              ":" + cfg.Port,
+		Handler:           observability.HTTPServerLogging(logger)(router),
+		ReadHeaderTimeout: 5 * time.Second,
+	}
+
+	authServer := grpcServer.NewAuthServer(authService, userService)
+
+	// Only other services may call the gRPC API, with a service token
+	serviceVerifier := grpcServer.NewServiceVerifier(validator)
+	grpcServer := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(
+			observability.UnaryServerLogging(logger),
+			serviceauth.UnaryServerInterceptor(serviceVerifier, reflectionServices...),
+		),
+		grpc.ChainStreamInterceptor(
+			observability.StreamServerLogging(logger),
+			serviceauth.StreamServerInterceptor(serviceVerifier, reflectionServices...),
+		),
+	)
+	authv1.RegisterAuthServiceServer(grpcServer, authServer)
+
+	// Enable gRPC reflection for easy endpoint inspection
+	reflection.Register(grpcServer)
+
+	listen, err := net.Listen("tcp", ":"+cfg.GRPCPort)
+	if err != nil {
+		logger.Fatal("failed to listen", zap.Error(err))
+	}
+
+	go func() {
+		logger.Info("gRPC server is running", zap.String("port", cfg.GRPCPort))
+		if err := grpcServer.Serve(listen); err != nil {
+			logger.Fatal("failed to serve gRPC", zap.Error(err))
+		}
+	}()
+
+	// Setup graceful shutdown
+	sigChan := make(chan os.Signal, 1)
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
+
+	// ListenAndServe returns as soon as Shutdown is called; main waits for
+	// in-flight requests through stopped
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		<-sigChan
+		logger.Info("Shutting down gracefully...")
+
+		shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer shutdownCancel()
+		if err := server.Shutdown(shutdownCtx); err != nil {
+			logger.Warn("failed to shut down gracefully", zap.Error(err))
+		}
+		grpcServer.GracefulStop()
+		cancel()
+	}()
+
+	logger.Info("Server is running", zap.String("port", cfg.Port))
+	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		logger.Fatal("failed to serve", zap.Error(err))
+	}
+	<-stopped
+}
+
+// reconcileUsers syncs the local users with Keycloak on startup and then periodically
+func reconcileUsers(ctx context.Context, service *user.Service, interval time.Duration, logger *zap.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		result, err := service.Reconcile(ctx)
+		if err != nil {
+			logger.Warn("failed to reconcile users", zap.Error(err))
+		} else {
+			logger.Info("Users reconciled", zap.Int("synced", result.Synced), zap.Int("deleted", result.Deleted))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// pollEvents ingests the events of Keycloak's event store periodically
+func pollEvents(ctx context.Context, service *ingestion.Service, interval time.Duration, logger *zap.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		ingested, err := service.Poll(ctx)
+		if err != nil {
+			logger.Warn("failed to poll keycloak events", zap.Error(err))
+		} else if ingested > 0 {
+			logger.Info("Keycloak events ingested", zap.Int("ingested", ingested))
+		}
+	}
+}
+
+// configureRateLimits overrides the default rate limit policies with the
+// configured ones, which were validated when the config was loaded
+func configureRateLimits(limits *ratelimit.Service, cfg config.RateLimits) {
+	rule := func(rule *ratelimit.Rule, value string) {
+		if rate, err := config.ParseRate(value); value != "" && err == nil {
+			*rule = ratelimit.Rule{Limit: rate.Limit, Window: rate.Window}
+		}
+	}
+	lockout := func(lockout *ratelimit.Lockout, value string) {
+		if rate, err := config.ParseRate(value); value != "" && err == nil {
+			lockout.Threshold, lockout.Window = rate.Limit, rate.Window
+		}
+		if cfg.LockoutDuration > 0 {
+			lockout.Duration = cfg.LockoutDuration
+		}
+		if cfg.LockoutMaxDuration > 0 {
+			lockout.MaxDuration = cfg.LockoutMaxDuration
+		}
+	}
+
+	login := limits.Policy(ratelimit.EndpointLogin)
+	rule(&login.PerIP, cfg.LoginPerIP)
+	rule(&login.PerAccount, cfg.LoginPerAccount)
+	lockout(&login.Lockout, cfg.LoginLockout)
+	limits.SetPolicy(ratelimit.EndpointLogin, login)
+
+	refresh := limits.Policy(ratelimit.EndpointRefresh)
+	rule(&refresh.PerIP, cfg.RefreshPerIP)
+	limits.SetPolicy(ratelimit.EndpointRefresh, refresh)
+
+	forgotPassword := limits.Policy(ratelimit.EndpointForgotPassword)
+	rule(&forgotPassword.PerIP, cfg.ForgotPasswordPerIP)
+	rule(&forgotPassword.PerAccount, cfg.ForgotPasswordPerAccount)
+	limits.SetPolicy(ratelimit.EndpointForgotPassword, forgotPassword)
+
+	serviceToken := limits.Policy(ratelimit.EndpointServiceToken)
+	rule(&serviceToken.PerIP, cfg.ServiceTokenPerIP)
+	rule(&serviceToken.PerAccount, cfg.ServiceTokenPerClient)
+	lockout(&serviceToken.Lockout, cfg.ServiceTokenLockout)
+	limits.SetPolicy(ratelimit.EndpointServiceToken, serviceToken)
+
+	validate := limits.Policy(ratelimit.EndpointValidate)
+	rule(&validate.PerAPIKey, cfg.ValidatePerAPIKey)
+	lockout(&validate.Lockout, cfg.ValidateLockout)
+	limits.SetPolicy(ratelimit.EndpointValidate, validate)
+}
+
+// loadConfig exits with a list of every invalid setting instead of starting half-configured
+func loadConfig() *config.Config {
+	cfg := &config.Config{}
+	envconfig.MustLoad(cfg)
+	return cfg
+}
@@ -1 +1,91 @@
 package config
+
+import (
+	"fmt"
+	"net"
+	"time"
+)
+
+// Config is the auth service's configuration, loaded from the environment
+// and an optional YAML file
+type Config struct {
+	Port     string `env:"PORT" yaml:"port" default:"8080"`
+	GRPCPort string `env:"GRPC_PORT" yaml:"grpc_port" default:"9090"`
+	LogLevel string `env:"LOG_LEVEL" yaml:"log_level" default:"info"`
+
+	// TrustedProxies are the addresses or CIDRs of the gateways in front of
+	// the service, whose X-Forwarded-For header gives the client's address.
+	// Without any, the client is the address the request came from.
+	TrustedProxies []string `env:"TRUSTED_PROXIES" yaml:"trusted_proxies"`
+
+	Database Database `yaml:"database"`
+	Keycloak Keycloak `yaml:"keycloak"`
+	Redis    Redis    `yaml:"redis"`
+
+	Impersonation Impersonation `yaml:"impersonation"`
+	Events        Events        `yaml:"events"`
+	RateLimits    RateLimits    `yaml:"rate_limits"`
+
+	// ValidationCacheTTL is how long a verified token or API key is trusted
+	// without checking it again; zero disables the cache
+	ValidationCacheTTL time.Duration `env:"VALIDATION_CACHE_TTL" yaml:"validation_cache_ttl" default:"10s"`
+
+	// APIKeyMaxLifetime caps how long API keys are valid and is the default
+	// lifetime of new keys; zero allows keys that never expire
+	APIKeyMaxLifetime time.Duration `env:"API_KEY_MAX_LIFETIME" yaml:"api_key_max_lifetime" default:"8760h"`
+
+	// UserSyncInterval is how often local users are reconciled against
+	// Keycloak; zero disables reconciliation
+	UserSyncInterval time.Duration `env:"USER_SYNC_INTERVAL" yaml:"user_sync_interval" default:"15m"`
+
+	// SessionMaxLifetime caps how long a session can be refreshed after
+	// logging in, however long Keycloak keeps it alive
+	SessionMaxLifetime time.Duration `env:"SESSION_MAX_LIFETIME" yaml:"session_max_lifetime" default:"720h"`
+
+	// AccessTokenMaxLifetime is the longest lifetime of access tokens issued
+	// by the realm; revocations of sessions and users are kept this long
+	AccessTokenMaxLifetime time.Duration `env:"ACCESS_TOKEN_MAX_LIFETIME" yaml:"access_token_max_lifetime" default:"1h"`
+
+	// PreferenceSchemaDir holds the JSON schema of each client app's
+	// preferences, named after its client ID, e.g. task-web.json. Apps
+	// without a schema can't store preferences.
+	PreferenceSchemaDir string `env:"PREFERENCE_SCHEMA_DIR" yaml:"preference_schema_dir"`
+}
+
+func (c *Config) Validate() error {
+	for _, proxy := range c.TrustedProxies {
+		if _, _, err := net.ParseCIDR(proxy); err != nil && net.ParseIP(proxy) == nil {
+			return fmt.Errorf("TRUSTED_PROXIES: %q is not an IP address or CIDR", proxy)
+		}
+	}
+
+	if c.ValidationCacheTTL < 0 {
+		return fmt.Errorf("VALIDATION_CACHE_TTL must not be negative")
+	}
+
+	if c.APIKeyMaxLifetime < 0 {
+		return fmt.Errorf("API_KEY_MAX_LIFETIME must not be negative")
+	}
+
+	if c.UserSyncInterval < 0 {
+		return fmt.Errorf("USER_SYNC_INTERVAL must not be negative")
+	}
+
+	if c.SessionMaxLifetime <= 0 {
+		return fmt.Errorf("SESSION_MAX_LIFETIME must be positive")
+	}
+
+	if c.AccessTokenMaxLifetime <= 0 {
+		return fmt.Errorf("ACCESS_TOKEN_MAX_LIFETIME must be positive")
+	}
+
+	if err := c.Events.validate(); err != nil {
+		return err
+	}
+
+	if err := c.RateLimits.validate(); err != nil {
+		return err
+	}
+
+	return c.Impersonation.validate()
+}
@@ -1 +1,6 @@
 package config
+
+// Database configures the Postgres database holding users and API keys
+type Database struct {
+	URL string `env:"DATABASE_URL" yaml:"url" required:"true" secret:"true"`
+}
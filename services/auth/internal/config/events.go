@@ -0,0 +1,28 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// Events configures following changes made directly in Keycloak. Polling
+// needs the service account to have the realm-management view-events role;
+// the webhook needs an event listener in Keycloak posting to
+// /webhooks/keycloak of the auth service.
+type Events struct {
+	// WebhookSecret signs webhook deliveries with HMAC-SHA256; the webhook
+	// is disabled without it
+	WebhookSecret string `env:"KEYCLOAK_EVENTS_WEBHOOK_SECRET" yaml:"webhook_secret" secret:"true"`
+
+	// PollInterval is how often Keycloak's event store is polled, catching
+	// events the webhook missed; zero disables polling
+	PollInterval time.Duration `env:"KEYCLOAK_EVENTS_POLL_INTERVAL" yaml:"poll_interval" default:"1m"`
+}
+
+func (e Events) validate() error {
+	if e.PollInterval < 0 {
+		return fmt.Errorf("KEYCLOAK_EVENTS_POLL_INTERVAL must not be negative")
+	}
+
+	return nil
+}
@@ -0,0 +1,43 @@
+package config
+
+import (
+	"crypto/ed25519"
+	"encoding/base64"
+	"fmt"
+	"time"
+)
+
+// Impersonation configures support staff acting as other users. It is
+// disabled until both the signing key and the permissions service are set.
+type Impersonation struct {
+	// SigningKey is the base64-encoded Ed25519 seed impersonation tokens are
+	// signed with, e.g. from openssl rand -base64 32
+	SigningKey string `env:"IMPERSONATION_SIGNING_KEY" yaml:"signing_key" secret:"true"`
+
+	// TokenLifetime is how long an impersonation lasts unless it is ended
+	TokenLifetime time.Duration `env:"IMPERSONATION_TOKEN_LIFETIME" yaml:"token_lifetime" default:"15m"`
+
+	// PermissionsAddr is the gRPC address of the permissions service, which
+	// decides who may impersonate
+	PermissionsAddr string `env:"PERMISSIONS_ADDR" yaml:"permissions_addr"`
+}
+
+// Enabled reports whether impersonations can be started
+func (i Impersonation) Enabled() bool {
+	return i.SigningKey != "" && i.PermissionsAddr != ""
+}
+
+func (i Impersonation) validate() error {
+	if i.TokenLifetime <= 0 {
+		return fmt.Errorf("IMPERSONATION_TOKEN_LIFETIME must be positive")
+	}
+
+	if i.SigningKey != "" {
+		seed, err := base64.StdEncoding.DecodeString(i.SigningKey)
+		if err != nil || len(seed) != ed25519.SeedSize {
+			return fmt.Errorf("IMPERSONATION_SIGNING_KEY must be %d base64-encoded bytes", ed25519.SeedSize)
+		}
+	}
+
+	return nil
+}
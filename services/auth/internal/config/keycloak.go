@@ -1 +1,74 @@
 package config
+
+import (
+	"strings"
+	"time"
+)
+
+// Keycloak configures the realm whose tokens the auth service accepts
+type Keycloak struct {
+	URL   string `env:"KEYCLOAK_URL" yaml:"url" required:"true"`
+	Realm string `env:"KEYCLOAK_REALM" yaml:"realm" required:"true"`
+
+	// Confidential client whose service account manages users through the
+	// Admin REST API; it needs the realm-management manage-users role, and
+	// direct access grants enabled for logins through the auth service
+	ClientID     string `env:"KEYCLOAK_CLIENT_ID" yaml:"client_id" required:"true"`
+	ClientSecret string `env:"KEYCLOAK_CLIENT_SECRET" yaml:"client_secret" required:"true" secret:"true"`
+
+	// Issuer overrides the expected iss claim when Keycloak is reached through
+	// an internal URL but issues tokens for its public one
+	Issuer string `env:"KEYCLOAK_ISSUER" yaml:"issuer"`
+
+	// Audience lists the accepted aud values; a token needs only one of them.
+	// Defaults to ClientID, which needs an audience mapper on the realm's clients.
+	Audience []string `env:"KEYCLOAK_AUDIENCE" yaml:"audience"`
+
+	ClockSkew time.Duration `env:"KEYCLOAK_CLOCK_SKEW" yaml:"clock_skew" default:"30s"`
+
+	// JWKSCacheTTL is how long signing keys are used before they are fetched again
+	JWKSCacheTTL time.Duration `env:"KEYCLOAK_JWKS_CACHE_TTL" yaml:"jwks_cache_ttl" default:"1h"`
+	// JWKSMinRefreshInterval limits how often tokens with an unknown key ID can trigger a fetch
+	JWKSMinRefreshInterval time.Duration `env:"KEYCLOAK_JWKS_MIN_REFRESH_INTERVAL" yaml:"jwks_min_refresh_interval" default:"10s"`
+}
+
+// RealmURL is the base of every OpenID Connect endpoint of the realm
+func (k Keycloak) RealmURL() string {
+	return strings.TrimSuffix(k.URL, "/") + "/realms/" + k.Realm
+}
+
+// IssuerURL is the expected iss claim of the realm's tokens
+func (k Keycloak) IssuerURL() string {
+	if k.Issuer != "" {
+		return k.Issuer
+	}
+	return k.RealmURL()
+}
+
+// AcceptedAudience is the list of aud values the realm's tokens are checked against
+func (k Keycloak) AcceptedAudience() []string {
+	if len(k.Audience) > 0 {
+		return k.Audience
+	}
+	return []string{k.ClientID}
+}
+
+// TokenURL is the realm's OpenID Connect token endpoint
+func (k Keycloak) TokenURL() string {
+	return k.RealmURL() + "/protocol/openid-connect/token"
+}
+
+// LogoutURL ends the session a refresh token belongs to
+func (k Keycloak) LogoutURL() string {
+	return k.RealmURL() + "/protocol/openid-connect/logout"
+}
+
+// AdminURL is the base of the realm's Admin REST API
+func (k Keycloak) AdminURL() string {
+	return strings.TrimSuffix(k.URL, "/") + "/admin/realms/" + k.Realm
+}
+
+// JWKSURL serves the realm's public signing keys
+func (k Keycloak) JWKSURL() string {
+	return k.RealmURL() + "/protocol/openid-connect/certs"
+}
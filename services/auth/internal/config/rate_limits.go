@@ -0,0 +1,98 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RateLimits overrides the limits of the auth endpoints. Limits are written
+// as attempts per window, e.g. 10/1m, and lockouts as the failures within a
+// window that lock the account, client or address out; 0 disables one.
+// Unset settings keep the default given with each.
+type RateLimits struct {
+	// LoginPerIP defaults to 30/1m
+	LoginPerIP string `env:"RATE_LIMIT_LOGIN_PER_IP" yaml:"login_per_ip"`
+	// LoginPerAccount defaults to 10/1m
+	LoginPerAccount string `env:"RATE_LIMIT_LOGIN_PER_ACCOUNT" yaml:"login_per_account"`
+	// LoginLockout defaults to 5/15m; the owner of a locked account is notified
+	LoginLockout string `env:"RATE_LIMIT_LOGIN_LOCKOUT" yaml:"login_lockout"`
+
+	// RefreshPerIP defaults to 60/1m
+	RefreshPerIP string `env:"RATE_LIMIT_REFRESH_PER_IP" yaml:"refresh_per_ip"`
+
+	// ForgotPasswordPerIP defaults to 10/1h
+	ForgotPasswordPerIP string `env:"RATE_LIMIT_FORGOT_PASSWORD_PER_IP" yaml:"forgot_password_per_ip"`
+	// ForgotPasswordPerAccount bounds the emails sent to one account and
+	// defaults to 3/1h
+	ForgotPasswordPerAccount string `env:"RATE_LIMIT_FORGOT_PASSWORD_PER_ACCOUNT" yaml:"forgot_password_per_account"`
+
+	// ServiceTokenPerIP is unlimited by default
+	ServiceTokenPerIP string `env:"RATE_LIMIT_SERVICE_TOKEN_PER_IP" yaml:"service_token_per_ip"`
+	// ServiceTokenPerClient defaults to 30/1m
+	ServiceTokenPerClient string `env:"RATE_LIMIT_SERVICE_TOKEN_PER_CLIENT" yaml:"service_token_per_client"`
+	// ServiceTokenLockout defaults to 10/15m
+	ServiceTokenLockout string `env:"RATE_LIMIT_SERVICE_TOKEN_LOCKOUT" yaml:"service_token_lockout"`
+
+	// ValidatePerAPIKey defaults to 6000/1m
+	ValidatePerAPIKey string `env:"RATE_LIMIT_VALIDATE_PER_API_KEY" yaml:"validate_per_api_key"`
+	// ValidateLockout locks out addresses sending invalid API keys and
+	// defaults to 20/5m
+	ValidateLockout string `env:"RATE_LIMIT_VALIDATE_LOCKOUT" yaml:"validate_lockout"`
+
+	// LockoutDuration is how long a first lockout lasts. Each one after it
+	// within a day lasts twice as long, up to LockoutMaxDuration. They
+	// default to 1m and 1h, or 5m and 1h for invalid API keys.
+	LockoutDuration    time.Duration `env:"LOCKOUT_DURATION" yaml:"lockout_duration"`
+	LockoutMaxDuration time.Duration `env:"LOCKOUT_MAX_DURATION" yaml:"lockout_max_duration"`
+}
+
+// Rate is a limit of Limit attempts, or failures, per Window
+type Rate struct {
+	Limit  int
+	Window time.Duration
+}
+
+// ParseRate parses a limit written as attempts per window, e.g. 10/1m, or
+// 0 for no limit
+func ParseRate(value string) (Rate, error) {
+	if strings.TrimSpace(value) == "0" {
+		return Rate{}, nil
+	}
+
+	limit, window, ok := strings.Cut(value, "/")
+	n, err := strconv.Atoi(strings.TrimSpace(limit))
+	if !ok || err != nil || n <= 0 {
+		return Rate{}, fmt.Errorf("invalid rate %q, want attempts per window, e.g. 10/1m", value)
+	}
+	d, err := time.ParseDuration(strings.TrimSpace(window))
+	if err != nil || d <= 0 {
+		return Rate{}, fmt.Errorf("invalid rate %q, want attempts per window, e.g. 10/1m", value)
+	}
+
+	return Rate{Limit: n, Window: d}, nil
+}
+
+func (r RateLimits) validate() error {
+	v := reflect.ValueOf(r)
+	for i := 0; i < v.NumField(); i++ {
+		value, ok := v.Field(i).Interface().(string)
+		if !ok || value == "" {
+			continue
+		}
+		if _, err := ParseRate(value); err != nil {
+			return fmt.Errorf("%s: %w", v.Type().Field(i).Tag.Get("env"), err)
+		}
+	}
+
+	if r.LockoutDuration < 0 || r.LockoutMaxDuration < 0 {
+		return fmt.Errorf("LOCKOUT_DURATION and LOCKOUT_MAX_DURATION must not be negative")
+	}
+	if r.LockoutDuration > 0 && r.LockoutMaxDuration > 0 && r.LockoutMaxDuration < r.LockoutDuration {
+		return fmt.Errorf("LOCKOUT_MAX_DURATION must not be shorter than LOCKOUT_DURATION")
+	}
+
+	return nil
+}
@@ -0,0 +1,6 @@
+package config
+
+// Redis configures the Redis server holding sessions, token revocations and rate limits
+type Redis struct {
+	URL string `env:"REDIS_URL" yaml:"url" required:"true" secret:"true"`
+}
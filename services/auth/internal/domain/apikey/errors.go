@@ -0,0 +1,13 @@
+package apikey
+
+import "errors"
+
+var (
+	ErrAPIKeyNotFound = errors.New("API key not found")
+	ErrAPIKeyRevoked  = errors.New("API key has been revoked")
+	ErrInvalidAPIKey  = errors.New("invalid API key definition")
+	// ErrRoleNotHeld is returned when a key would grant a role its owner doesn't have
+	ErrRoleNotHeld = errors.New("API keys can only grant roles their owner holds")
+	// ErrOwnerInactive is returned by Owners for users who are disabled or deleted
+	ErrOwnerInactive = errors.New("API key owner is disabled or deleted")
+)
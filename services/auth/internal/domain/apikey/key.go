@@ -0,0 +1,86 @@
+package apikey
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+const (
+	// KeyPrefix starts every API key so keys are recognisable in the
+	// Authorization header and by secret scanners
+	KeyPrefix = "ifk_"
+
+	prefixBytes = 8
+	secretBytes = 32
+	saltBytes   = 16
+)
+
+// IsAPIKey reports whether the credential looks like an API key rather than a JWT
+func IsAPIKey(credential string) bool {
+	return strings.HasPrefix(credential, KeyPrefix)
+}
+
+// Prefix returns the public identifier of a well-formed API key, which
+// names the key without revealing its secret
+func Prefix(key string) (string, bool) {
+	prefix, _, ok := parseKey(key)
+	return prefix, ok
+}
+
+// newPrefix returns the random public identifier of a new key
+func newPrefix() (string, error) {
+	b := make([]byte, prefixBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate key prefix: %w", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// newSecret returns a random secret with the salt and hash to store for it
+func newSecret() (secret string, salt []byte, hash []byte, err error) {
+	b := make([]byte, secretBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", nil, nil, fmt.Errorf("failed to generate key secret: %w", err)
+	}
+
+	salt = make([]byte, saltBytes)
+	if _, err := rand.Read(salt); err != nil {
+		return "", nil, nil, fmt.Errorf("failed to generate key salt: %w", err)
+	}
+
+	secret = base64.RawURLEncoding.EncodeToString(b)
+	return secret, salt, hashSecret(salt, secret), nil
+}
+
+// hashSecret hashes the secret with HMAC-SHA256. The secrets are random
+// 256-bit values, so a slow password hash would only add latency to every
+// request without making them harder to guess.
+func hashSecret(salt []byte, secret string) []byte {
+	mac := hmac.New(sha256.New, salt)
+	mac.Write([]byte(secret))
+	return mac.Sum(nil)
+}
+
+func formatKey(prefix string, secret string) string {
+	return KeyPrefix + prefix + "_" + secret
+}
+
+// parseKey splits a key into its prefix and secret
+func parseKey(key string) (prefix string, secret string, ok bool) {
+	rest, found := strings.CutPrefix(key, KeyPrefix)
+	if !found {
+		return "", "", false
+	}
+
+	prefix, secret, found = strings.Cut(rest, "_")
+	if !found || len(prefix) != 2*prefixBytes || secret == "" {
+		return "", "", false
+	}
+
+	return prefix, secret, true
+}
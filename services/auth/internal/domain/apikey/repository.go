@@ -0,0 +1,36 @@
+package apikey
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/intellifinder/v4/services/auth/pkg/models"
+)
+
+type Repository interface {
+	// CreateAPIKey stores the key and sets its ID
+	CreateAPIKey(ctx context.Context, key *models.APIKey) error
+	// GetAPIKey and GetAPIKeyByPrefix return ErrAPIKeyNotFound for unknown keys
+	GetAPIKey(ctx context.Context, id uuid.UUID) (*models.APIKey, error)
+	GetAPIKeyByPrefix(ctx context.Context, prefix string) (*models.APIKey, error)
+	// ListAPIKeys returns the user's keys, revoked ones included, newest first
+	ListAPIKeys(ctx context.Context, userID string) ([]*models.APIKey, error)
+	// UpdateAPIKeySecret replaces the salt and hash of a key that isn't revoked
+	UpdateAPIKeySecret(ctx context.Context, id uuid.UUID, salt []byte, hash []byte, updatedAt time.Time) error
+	// RevokeAPIKey marks the key revoked; revoking it again keeps the first time
+	RevokeAPIKey(ctx context.Context, id uuid.UUID, revokedAt time.Time) error
+	// RevokeUserAPIKeys revokes every key of the user that isn't revoked yet
+	// and returns how many were revoked
+	RevokeUserAPIKeys(ctx context.Context, userID string, revokedAt time.Time) (int, error)
+	// TouchAPIKey records that the key was used
+	TouchAPIKey(ctx context.Context, id uuid.UUID, usedAt time.Time) error
+}
+
+// Owners tells what key owners may currently do, so a key loses access as
+// soon as its owner does
+type Owners interface {
+	// GetOwnerRoles returns the realm roles the user currently holds, and
+	// ErrOwnerInactive if the user is disabled or no longer exists
+	GetOwnerRoles(ctx context.Context, oidcID string) ([]string, error)
+}
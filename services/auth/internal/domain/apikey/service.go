@@ -0,0 +1,276 @@
+package apikey
+
+import (
+	"context"
+	"crypto/hmac"
+	"errors"
+	"fmt"
+	"regexp"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/intellifinder/v4/services/auth/internal/domain/authentication"
+	"github.com/intellifinder/v4/services/auth/pkg/models"
+)
+
+// lastUsedResolution limits how often the last-used time of a busy key is written
+const lastUsedResolution = time.Minute
+
+// maxNameLength bounds key names, which are only labels for their owners
+const maxNameLength = 100
+
+var scopePattern = regexp.MustCompile(`^[a-z0-9-]+:[a-z0-9*-]+$`)
+
+var _ authentication.APIKeyVerifier = (*Service)(nil)
+
+type Service struct {
+	repo        Repository
+	owners      Owners
+	maxLifetime time.Duration
+	now         func() time.Time
+}
+
+// NewService returns a service whose keys expire after at most maxLifetime;
+// zero allows keys that never expire. Keys only work while owners reports
+// their owner as active.
+func NewService(repo Repository, owners Owners, maxLifetime time.Duration) *Service {
+	return &Service{
+		repo:        repo,
+		owners:      owners,
+		maxLifetime: maxLifetime,
+		now:         time.Now,
+	}
+}
+
+// CreateParams describes a new key
+type CreateParams struct {
+	Name   string
+	Scopes []string
+	// Roles default to all roles of the owner
+	Roles []string
+	// ExpiresAt defaults to the maximum lifetime
+	ExpiresAt *time.Time
+}
+
+// Create issues a key for the owner and returns it together with the full
+// key, which can't be recovered later
+func (s *Service) Create(ctx context.Context, owner *models.Identity, params CreateParams) (*models.APIKey, string, error) {
+	now := s.now()
+
+	if err := s.validate(owner, &params, now); err != nil {
+		return nil, "", err
+	}
+
+	prefix, err := newPrefix()
+	if err != nil {
+		return nil, "", err
+	}
+
+	secret, salt, hash, err := newSecret()
+	if err != nil {
+		return nil, "", err
+	}
+
+	key := &models.APIKey{
+		Name:      params.Name,
+		UserID:    owner.Subject,
+		TenantID:  owner.TenantID,
+		Prefix:    prefix,
+		Salt:      salt,
+		Hash:      hash,
+		Scopes:    params.Scopes,
+		Roles:     params.Roles,
+		ExpiresAt: params.ExpiresAt,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := s.repo.CreateAPIKey(ctx, key); err != nil {
+		return nil, "", fmt.Errorf("failed to create API key: %w", err)
+	}
+
+	return key, formatKey(prefix, secret), nil
+}
+
+func (s *Service) validate(owner *models.Identity, params *CreateParams, now time.Time) error {
+	if params.Name == "" || len(params.Name) > maxNameLength {
+		return fmt.Errorf("%w: name must be 1 to %d characters", ErrInvalidAPIKey, maxNameLength)
+	}
+
+	for _, scope := range params.Scopes {
+		if !scopePattern.MatchString(scope) {
+			return fmt.Errorf("%w: scope %q is not of the form service:action", ErrInvalidAPIKey, scope)
+		}
+	}
+
+	if params.Roles == nil {
+		params.Roles = slices.Clone(owner.Roles)
+	}
+	for _, role := range params.Roles {
+		if !owner.HasRole(role) {
+			return fmt.Errorf("%w: %s", ErrRoleNotHeld, role)
+		}
+	}
+
+	if params.ExpiresAt == nil && s.maxLifetime > 0 {
+		expiresAt := now.Add(s.maxLifetime)
+		params.ExpiresAt = &expiresAt
+	}
+	if params.ExpiresAt != nil {
+		if !params.ExpiresAt.After(now) {
+			return fmt.Errorf("%w: expiry must be in the future", ErrInvalidAPIKey)
+		}
+		if s.maxLifetime > 0 && params.ExpiresAt.Sub(now) > s.maxLifetime {
+			return fmt.Errorf("%w: keys can't be valid for more than %s", ErrInvalidAPIKey, s.maxLifetime)
+		}
+	}
+
+	if params.Scopes == nil {
+		params.Scopes = []string{}
+	}
+
+	return nil
+}
+
+// List returns the owner's keys without their secrets
+func (s *Service) List(ctx context.Context, owner *models.Identity) ([]*models.APIKey, error) {
+	keys, err := s.repo.ListAPIKeys(ctx, owner.Subject)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list API keys: %w", err)
+	}
+
+	return keys, nil
+}
+
+// Rotate replaces the secret of one of the owner's keys and returns the new
+// full key. The old secret stops working immediately, apart from validations
+// already cached by the authentication service.
+func (s *Service) Rotate(ctx context.Context, owner *models.Identity, id uuid.UUID) (*models.APIKey, string, error) {
+	key, err := s.ownedKey(ctx, owner, id)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if key.RevokedAt != nil {
+		return nil, "", ErrAPIKeyRevoked
+	}
+
+	secret, salt, hash, err := newSecret()
+	if err != nil {
+		return nil, "", err
+	}
+
+	now := s.now()
+	if err := s.repo.UpdateAPIKeySecret(ctx, key.ID, salt, hash, now); err != nil {
+		return nil, "", fmt.Errorf("failed to rotate API key: %w", err)
+	}
+
+	key.Salt, key.Hash, key.UpdatedAt = salt, hash, now
+	return key, formatKey(key.Prefix, secret), nil
+}
+
+// Revoke permanently disables one of the owner's keys
+func (s *Service) Revoke(ctx context.Context, owner *models.Identity, id uuid.UUID) error {
+	if _, err := s.ownedKey(ctx, owner, id); err != nil {
+		return err
+	}
+
+	if err := s.repo.RevokeAPIKey(ctx, id, s.now()); err != nil {
+		return fmt.Errorf("failed to revoke API key: %w", err)
+	}
+
+	return nil
+}
+
+// RevokeUserAPIKeys permanently disables every key of the user, e.g. when
+// the user is disabled or deleted, and returns how many were revoked
+func (s *Service) RevokeUserAPIKeys(ctx context.Context, userID string) (int, error) {
+	revoked, err := s.repo.RevokeUserAPIKeys(ctx, userID, s.now())
+	if err != nil {
+		return 0, fmt.Errorf("failed to revoke API keys: %w", err)
+	}
+
+	return revoked, nil
+}
+
+// ownedKey returns ErrAPIKeyNotFound for other users' keys so their IDs can't be probed
+func (s *Service) ownedKey(ctx context.Context, owner *models.Identity, id uuid.UUID) (*models.APIKey, error) {
+	key, err := s.repo.GetAPIKey(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get API key: %w", err)
+	}
+
+	if key.UserID != owner.Subject {
+		return nil, ErrAPIKeyNotFound
+	}
+
+	return key, nil
+}
+
+// VerifyAPIKey returns the identity of the key's owner, limited to the key's
+// scopes and to the roles of the key the owner still holds, and records that
+// the key was used. Keys of disabled or deleted owners are rejected.
+func (s *Service) VerifyAPIKey(ctx context.Context, credential string) (*models.Identity, error) {
+	prefix, secret, ok := parseKey(credential)
+	if !ok {
+		return nil, fmt.Errorf("%w: malformed API key", authentication.ErrInvalidCredentials)
+	}
+
+	key, err := s.repo.GetAPIKeyByPrefix(ctx, prefix)
+	if errors.Is(err, ErrAPIKeyNotFound) {
+		return nil, fmt.Errorf("%w: unknown API key", authentication.ErrInvalidCredentials)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get API key: %w", err)
+	}
+
+	if !hmac.Equal(hashSecret(key.Salt, secret), key.Hash) {
+		return nil, fmt.Errorf("%w: wrong API key secret", authentication.ErrInvalidCredentials)
+	}
+
+	now := s.now()
+	if key.RevokedAt != nil {
+		return nil, fmt.Errorf("%w: API key has been revoked", authentication.ErrInvalidCredentials)
+	}
+	if !key.Active(now) {
+		return nil, authentication.ErrCredentialsExpired
+	}
+
+	held, err := s.owners.GetOwnerRoles(ctx, key.UserID)
+	if errors.Is(err, ErrOwnerInactive) {
+		return nil, fmt.Errorf("%w: %w", authentication.ErrInvalidCredentials, err)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: failed to get roles of API key owner: %w", authentication.ErrUnavailable, err)
+	}
+
+	// Roles the owner lost since the key was created are dropped
+	roles := []string{}
+	for _, role := range key.Roles {
+		if slices.Contains(held, role) {
+			roles = append(roles, role)
+		}
+	}
+
+	if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) >= lastUsedResolution {
+		if err := s.repo.TouchAPIKey(ctx, key.ID, now); err != nil {
+			return nil, fmt.Errorf("failed to record API key use: %w", err)
+		}
+	}
+
+	identity := &models.Identity{
+		Subject:  key.UserID,
+		Roles:    roles,
+		TenantID: key.TenantID,
+		IssuedAt: now,
+		Method:   models.AuthMethodAPIKey,
+	}
+	if len(key.Scopes) > 0 {
+		identity.Scopes = key.Scopes
+	}
+	if key.ExpiresAt != nil {
+		identity.ExpiresAt = *key.ExpiresAt
+	}
+
+	return identity, nil
+}
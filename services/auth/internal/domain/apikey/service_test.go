@@ -0,0 +1,233 @@
+package apikey_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/intellifinder/v4/services/auth/internal/domain/apikey"
+	"github.com/intellifinder/v4/services/auth/internal/domain/authentication"
+	"github.com/intellifinder/v4/services/auth/internal/infrastructure/memory"
+	"github.com/intellifinder/v4/services/auth/pkg/models"
+)
+
+var alice = &models.Identity{Subject: "user-1", Roles: []string{"admin", "editor"}, TenantID: "tenant-1", Method: models.AuthMethodJWT}
+
+// owners holds the current roles of active users; others are inactive
+type owners struct {
+	roles map[string][]string
+	err   error
+}
+
+func (o *owners) GetOwnerRoles(_ context.Context, oidcID string) ([]string, error) {
+	if o.err != nil {
+		return nil, o.err
+	}
+	roles, ok := o.roles[oidcID]
+	if !ok {
+		return nil, apikey.ErrOwnerInactive
+	}
+	return roles, nil
+}
+
+func newOwners() *owners {
+	return &owners{roles: map[string][]string{alice.Subject: alice.Roles}}
+}
+
+func TestCreateAndVerifyAPIKey(t *testing.T) {
+	repo := memory.NewRepository()
+	service := apikey.NewService(repo, newOwners(), 24*time.Hour)
+	now := time.Now()
+	apikey.SetNow(service, func() time.Time { return now })
+
+	ctx := context.Background()
+	key, secret, err := service.Create(ctx, alice, apikey.CreateParams{Name: "ci", Scopes: []string{"tasks:read"}, Roles: []string{"editor"}})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	if !strings.HasPrefix(secret, apikey.KeyPrefix+key.Prefix+"_") {
+		t.Errorf("Create() key = %q, want it to start with the public prefix %q", secret, key.Prefix)
+	}
+	stored, err := repo.GetAPIKey(ctx, key.ID)
+	if err != nil {
+		t.Fatalf("GetAPIKey() error = %v", err)
+	}
+	if strings.Contains(string(stored.Hash), secret) || len(stored.Salt) == 0 {
+		t.Errorf("stored key = %+v, want only a salted hash of the secret", stored)
+	}
+	if key.ExpiresAt == nil || !key.ExpiresAt.Equal(now.Add(24*time.Hour)) {
+		t.Errorf("Create() ExpiresAt = %v, want the maximum lifetime", key.ExpiresAt)
+	}
+
+	identity, err := service.VerifyAPIKey(ctx, secret)
+	if err != nil {
+		t.Fatalf("VerifyAPIKey() error = %v", err)
+	}
+	if identity.Subject != "user-1" || identity.TenantID != "tenant-1" || identity.Method != models.AuthMethodAPIKey ||
+		!slices.Equal(identity.Roles, []string{"editor"}) || !slices.Equal(identity.Scopes, []string{"tasks:read"}) {
+		t.Errorf("VerifyAPIKey() = %+v, want alice limited to the key's roles and scopes", identity)
+	}
+	if stored, _ := repo.GetAPIKey(ctx, key.ID); stored.LastUsedAt == nil || !stored.LastUsedAt.Equal(now) {
+		t.Errorf("LastUsedAt = %v, want %v", stored.LastUsedAt, now)
+	}
+
+	wrong := apikey.KeyPrefix + key.Prefix + "_" + strings.Repeat("A", 43)
+	for _, credential := range []string{wrong, "ifk_short_secret", "not-a-key"} {
+		if _, err := service.VerifyAPIKey(ctx, credential); !errors.Is(err, authentication.ErrInvalidCredentials) {
+			t.Errorf("VerifyAPIKey(%q) error = %v, want ErrInvalidCredentials", credential, err)
+		}
+	}
+
+	now = now.Add(25 * time.Hour)
+	if _, err := service.VerifyAPIKey(ctx, secret); !errors.Is(err, authentication.ErrCredentialsExpired) {
+		t.Errorf("VerifyAPIKey() after expiry error = %v, want ErrCredentialsExpired", err)
+	}
+}
+
+func TestCreateAPIKeyValidation(t *testing.T) {
+	service := apikey.NewService(memory.NewRepository(), newOwners(), 24*time.Hour)
+	ctx := context.Background()
+
+	past := time.Now().Add(-time.Minute)
+	tooLate := time.Now().Add(48 * time.Hour)
+	tests := []struct {
+		name    string
+		params  apikey.CreateParams
+		wantErr error
+	}{
+		{"no name", apikey.CreateParams{}, apikey.ErrInvalidAPIKey},
+		{"malformed scope", apikey.CreateParams{Name: "ci", Scopes: []string{"everything"}}, apikey.ErrInvalidAPIKey},
+		{"expired", apikey.CreateParams{Name: "ci", ExpiresAt: &past}, apikey.ErrInvalidAPIKey},
+		{"beyond maximum lifetime", apikey.CreateParams{Name: "ci", ExpiresAt: &tooLate}, apikey.ErrInvalidAPIKey},
+		{"role not held", apikey.CreateParams{Name: "ci", Roles: []string{"superuser"}}, apikey.ErrRoleNotHeld},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, _, err := service.Create(ctx, alice, tt.params); !errors.Is(err, tt.wantErr) {
+				t.Errorf("Create() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	key, _, err := service.Create(ctx, alice, apikey.CreateParams{Name: "defaults"})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+	if !slices.Equal(key.Roles, alice.Roles) {
+		t.Errorf("Create() Roles = %v, want the owner's roles by default", key.Roles)
+	}
+}
+
+func TestRotateAndRevokeAPIKey(t *testing.T) {
+	service := apikey.NewService(memory.NewRepository(), newOwners(), 0)
+	ctx := context.Background()
+
+	key, oldSecret, err := service.Create(ctx, alice, apikey.CreateParams{Name: "ci"})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	bob := &models.Identity{Subject: "user-2"}
+	if _, _, err := service.Rotate(ctx, bob, key.ID); !errors.Is(err, apikey.ErrAPIKeyNotFound) {
+		t.Errorf("Rotate() by another user error = %v, want apikey.ErrAPIKeyNotFound", err)
+	}
+
+	rotated, newSecret, err := service.Rotate(ctx, alice, key.ID)
+	if err != nil {
+		t.Fatalf("Rotate() error = %v", err)
+	}
+	if rotated.Prefix != key.Prefix || newSecret == oldSecret {
+		t.Errorf("Rotate() = %s, %q, want the same prefix with a new secret", rotated.Prefix, newSecret)
+	}
+	if _, err := service.VerifyAPIKey(ctx, oldSecret); !errors.Is(err, authentication.ErrInvalidCredentials) {
+		t.Errorf("VerifyAPIKey() with the old secret error = %v, want ErrInvalidCredentials", err)
+	}
+	if _, err := service.VerifyAPIKey(ctx, newSecret); err != nil {
+		t.Errorf("VerifyAPIKey() with the new secret error = %v", err)
+	}
+
+	if err := service.Revoke(ctx, bob, key.ID); !errors.Is(err, apikey.ErrAPIKeyNotFound) {
+		t.Errorf("Revoke() by another user error = %v, want apikey.ErrAPIKeyNotFound", err)
+	}
+	if err := service.Revoke(ctx, alice, key.ID); err != nil {
+		t.Fatalf("Revoke() error = %v", err)
+	}
+	if _, err := service.VerifyAPIKey(ctx, newSecret); !errors.Is(err, authentication.ErrInvalidCredentials) {
+		t.Errorf("VerifyAPIKey() after revocation error = %v, want ErrInvalidCredentials", err)
+	}
+	if _, _, err := service.Rotate(ctx, alice, key.ID); !errors.Is(err, apikey.ErrAPIKeyRevoked) {
+		t.Errorf("Rotate() after revocation error = %v, want apikey.ErrAPIKeyRevoked", err)
+	}
+
+	keys, err := service.List(ctx, alice)
+	if err != nil || len(keys) != 1 || keys[0].RevokedAt == nil {
+		t.Errorf("List() = %v, %v, want the revoked key", keys, err)
+	}
+}
+
+func TestRevokeUserAPIKeys(t *testing.T) {
+	repo := memory.NewRepository()
+	service := apikey.NewService(repo, newOwners(), 0)
+	ctx := context.Background()
+
+	_, first, _ := service.Create(ctx, alice, apikey.CreateParams{Name: "ci"})
+	_, second, _ := service.Create(ctx, alice, apikey.CreateParams{Name: "deploy"})
+	bob := &models.Identity{Subject: "user-2"}
+	if _, _, err := service.Create(ctx, bob, apikey.CreateParams{Name: "ci"}); err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	revoked, err := service.RevokeUserAPIKeys(ctx, alice.Subject)
+	if err != nil || revoked != 2 {
+		t.Fatalf("RevokeUserAPIKeys() = %d, %v, want 2", revoked, err)
+	}
+	for _, secret := range []string{first, second} {
+		if _, err := service.VerifyAPIKey(ctx, secret); !errors.Is(err, authentication.ErrInvalidCredentials) {
+			t.Errorf("VerifyAPIKey() after revoking the owner's keys error = %v, want ErrInvalidCredentials", err)
+		}
+	}
+	keys, _ := repo.ListAPIKeys(ctx, bob.Subject)
+	if len(keys) != 1 || keys[0].RevokedAt != nil {
+		t.Errorf("keys of another user = %v, want them left alone", keys)
+	}
+
+	if revoked, err := service.RevokeUserAPIKeys(ctx, alice.Subject); err != nil || revoked != 0 {
+		t.Errorf("RevokeUserAPIKeys() again = %d, %v, want 0", revoked, err)
+	}
+}
+
+func TestVerifyAPIKeyFollowsOwner(t *testing.T) {
+	owners := newOwners()
+	service := apikey.NewService(memory.NewRepository(), owners, 0)
+	ctx := context.Background()
+
+	_, secret, err := service.Create(ctx, alice, apikey.CreateParams{Name: "ci"})
+	if err != nil {
+		t.Fatalf("Create() error = %v", err)
+	}
+
+	owners.roles[alice.Subject] = []string{"editor", "viewer"}
+	identity, err := service.VerifyAPIKey(ctx, secret)
+	if err != nil {
+		t.Fatalf("VerifyAPIKey() error = %v", err)
+	}
+	if !slices.Equal(identity.Roles, []string{"editor"}) {
+		t.Errorf("VerifyAPIKey() Roles = %v, want only the key's roles the owner still holds", identity.Roles)
+	}
+
+	owners.err = errors.New("directory down")
+	if _, err := service.VerifyAPIKey(ctx, secret); !errors.Is(err, authentication.ErrUnavailable) {
+		t.Errorf("VerifyAPIKey() while owners can't be checked error = %v, want ErrUnavailable", err)
+	}
+
+	owners.err = nil
+	delete(owners.roles, alice.Subject)
+	if _, err := service.VerifyAPIKey(ctx, secret); !errors.Is(err, authentication.ErrInvalidCredentials) {
+		t.Errorf("VerifyAPIKey() for an inactive owner error = %v, want ErrInvalidCredentials", err)
+	}
+}
@@ -0,0 +1,17 @@
+package authentication
+
+import "errors"
+
+var (
+	// ErrInvalidCredentials is returned for missing, malformed, unknown or badly
+	// signed tokens and API keys
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	// ErrCredentialsExpired is returned for otherwise valid credentials past their expiry
+	ErrCredentialsExpired = errors.New("credentials expired")
+	// ErrCredentialsRevoked is returned, wrapped in ErrInvalidCredentials, for
+	// revoked access tokens
+	ErrCredentialsRevoked = errors.New("credentials revoked")
+	// ErrUnavailable is returned when credentials can't be checked at the moment,
+	// e.g. because the realm's signing keys can't be fetched
+	ErrUnavailable = errors.New("credential verification unavailable")
+)
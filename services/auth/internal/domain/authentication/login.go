@@ -0,0 +1,62 @@
+package authentication
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/intellifinder/v4/services/auth/pkg/models"
+)
+
+// Login exchanges a username and password for tokens
+func (s *Service) Login(ctx context.Context, username string, password string) (*models.TokenSet, error) {
+	if s.issuer == nil {
+		return nil, fmt.Errorf("%w: logging in is not enabled", ErrUnavailable)
+	}
+
+	if username == "" || password == "" {
+		return nil, fmt.Errorf("%w: username and password are required", ErrInvalidCredentials)
+	}
+
+	return s.issuer.IssueTokens(ctx, username, password)
+}
+
+// LoginService exchanges a service's client credentials for a short-lived
+// access token, which it sends with its calls to other services
+func (s *Service) LoginService(ctx context.Context, clientID string, clientSecret string) (*models.TokenSet, error) {
+	if s.issuer == nil {
+		return nil, fmt.Errorf("%w: logging in is not enabled", ErrUnavailable)
+	}
+
+	if clientID == "" || clientSecret == "" {
+		return nil, fmt.Errorf("%w: client ID and secret are required", ErrInvalidCredentials)
+	}
+
+	return s.issuer.IssueServiceTokens(ctx, clientID, clientSecret)
+}
+
+// Refresh exchanges a refresh token for new tokens
+func (s *Service) Refresh(ctx context.Context, refreshToken string) (*models.TokenSet, error) {
+	if s.issuer == nil {
+		return nil, fmt.Errorf("%w: refreshing tokens is not enabled", ErrUnavailable)
+	}
+
+	if refreshToken == "" {
+		return nil, fmt.Errorf("%w: no refresh token", ErrInvalidCredentials)
+	}
+
+	return s.issuer.RefreshTokens(ctx, refreshToken)
+}
+
+// Logout ends the session of the refresh token. Access tokens issued in the
+// session stay valid until they expire.
+func (s *Service) Logout(ctx context.Context, refreshToken string) error {
+	if s.issuer == nil {
+		return fmt.Errorf("%w: logging out is not enabled", ErrUnavailable)
+	}
+
+	if refreshToken == "" {
+		return fmt.Errorf("%w: no refresh token", ErrInvalidCredentials)
+	}
+
+	return s.issuer.RevokeTokens(ctx, refreshToken)
+}
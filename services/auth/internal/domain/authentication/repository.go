@@ -1 +1,53 @@
 package authentication
+
+import (
+	"context"
+
+	"github.com/intellifinder/v4/services/auth/pkg/models"
+)
+
+// TokenVerifier verifies bearer access tokens. Implementations return
+// ErrInvalidCredentials, ErrCredentialsExpired or ErrUnavailable so callers can
+// tell a rejected token from a failed check.
+type TokenVerifier interface {
+	VerifyAccessToken(ctx context.Context, token string) (*models.Identity, error)
+}
+
+// ImpersonationVerifier verifies the access tokens the auth service issues
+// for impersonations, with the same errors as TokenVerifier
+type ImpersonationVerifier interface {
+	TokenVerifier
+	// IsImpersonationToken tells its tokens from the identity provider's
+	// without verifying them
+	IsImpersonationToken(token string) bool
+}
+
+// APIKeyVerifier verifies API keys, with the same errors as TokenVerifier
+type APIKeyVerifier interface {
+	VerifyAPIKey(ctx context.Context, key string) (*models.Identity, error)
+}
+
+// UserProvisioner creates or updates the local user behind a verified access
+// token, so users exist locally from their first login on
+type UserProvisioner interface {
+	ProvisionUser(ctx context.Context, identity *models.Identity) error
+}
+
+// RevocationChecker tells whether an otherwise valid access token was
+// revoked, or an API key identity was verified before its owner's tokens were
+type RevocationChecker interface {
+	IsRevoked(ctx context.Context, identity *models.Identity) (bool, error)
+}
+
+// TokenIssuer issues tokens on behalf of the identity provider. It returns
+// ErrInvalidCredentials for wrong passwords and client secrets, disabled
+// accounts and expired or revoked refresh tokens.
+type TokenIssuer interface {
+	IssueTokens(ctx context.Context, username string, password string) (*models.TokenSet, error)
+	// IssueServiceTokens issues an access token for the service account of a
+	// client with the client credentials grant
+	IssueServiceTokens(ctx context.Context, clientID string, clientSecret string) (*models.TokenSet, error)
+	RefreshTokens(ctx context.Context, refreshToken string) (*models.TokenSet, error)
+	// RevokeTokens ends the session the refresh token belongs to
+	RevokeTokens(ctx context.Context, refreshToken string) error
+}
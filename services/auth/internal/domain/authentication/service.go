@@ -1 +1,228 @@
 package authentication
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/intellifinder/v4/services/auth/pkg/models"
+)
+
+// maxCacheEntries bounds the validation cache so a flood of distinct
+// credentials can't grow it without limit
+const maxCacheEntries = 10000
+
+type Service struct {
+	tokens        TokenVerifier
+	impersonation ImpersonationVerifier
+	apiKeys       APIKeyVerifier
+	users         UserProvisioner
+	onUserError   func(error)
+	issuer        TokenIssuer
+	revocations   RevocationChecker
+	now           func() time.Time
+
+	cacheTTL time.Duration
+	cacheMu  sync.Mutex
+	cache    map[[sha256.Size]byte]cachedIdentity
+}
+
+type cachedIdentity struct {
+	identity  *models.Identity
+	expiresAt time.Time
+}
+
+// NewService returns a service that verifies access tokens with tokens and
+// remembers successful verifications for cacheTTL; zero disables the cache
+func NewService(tokens TokenVerifier, cacheTTL time.Duration) *Service {
+	return &Service{
+		tokens:   tokens,
+		now:      time.Now,
+		cacheTTL: cacheTTL,
+		cache:    make(map[[sha256.Size]byte]cachedIdentity),
+	}
+}
+
+// SetAPIKeyVerifier enables authentication with API keys
+func (s *Service) SetAPIKeyVerifier(apiKeys APIKeyVerifier) {
+	s.apiKeys = apiKeys
+}
+
+// SetImpersonationVerifier accepts impersonation tokens as access tokens
+func (s *Service) SetImpersonationVerifier(impersonation ImpersonationVerifier) {
+	s.impersonation = impersonation
+}
+
+// SetUserProvisioner provisions the users of freshly verified access tokens.
+// Provisioning failures are passed to onError and don't fail the request,
+// so authentication keeps working while the user store is unavailable.
+func (s *Service) SetUserProvisioner(users UserProvisioner, onError func(error)) {
+	s.users = users
+	s.onUserError = onError
+}
+
+// SetTokenIssuer enables logging in with a username and password
+func (s *Service) SetTokenIssuer(issuer TokenIssuer) {
+	s.issuer = issuer
+}
+
+// SetRevocationChecker rejects revoked access tokens. Revocations are checked
+// on every authentication, including those answered from the cache, and
+// tokens are rejected while revocations can't be checked.
+func (s *Service) SetRevocationChecker(revocations RevocationChecker) {
+	s.revocations = revocations
+}
+
+// AuthenticateToken returns the identity behind a bearer access token. The
+// returned identity may be shared with other callers and must not be modified.
+func (s *Service) AuthenticateToken(ctx context.Context, token string) (*models.Identity, error) {
+	if token == "" {
+		return nil, fmt.Errorf("%w: no token", ErrInvalidCredentials)
+	}
+
+	verify := s.verifyAccessToken
+	if s.impersonation != nil && s.impersonation.IsImpersonationToken(token) {
+		verify = s.impersonation.VerifyAccessToken
+	}
+
+	identity, err := s.authenticate(ctx, models.AuthMethodJWT, token, verify)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.revocations != nil {
+		revoked, err := s.revocations.IsRevoked(ctx, identity)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrUnavailable, err)
+		}
+		if revoked {
+			return nil, fmt.Errorf("%w: %w", ErrInvalidCredentials, ErrCredentialsRevoked)
+		}
+	}
+
+	return identity, nil
+}
+
+func (s *Service) verifyAccessToken(ctx context.Context, token string) (*models.Identity, error) {
+	identity, err := s.tokens.VerifyAccessToken(ctx, token)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.users != nil {
+		if err := s.users.ProvisionUser(ctx, identity); err != nil && s.onUserError != nil {
+			s.onUserError(fmt.Errorf("failed to provision user %s: %w", identity.Subject, err))
+		}
+	}
+
+	return identity, nil
+}
+
+// AuthenticateAPIKey returns the identity behind an API key, like AuthenticateToken
+func (s *Service) AuthenticateAPIKey(ctx context.Context, key string) (*models.Identity, error) {
+	if key == "" || s.apiKeys == nil {
+		return nil, fmt.Errorf("%w: API keys are not accepted", ErrInvalidCredentials)
+	}
+
+	// A key cached before its owner's tokens were revoked, e.g. because the
+	// owner was disabled, is verified again, which checks the owner's state
+	if s.revocations != nil {
+		k := cacheKey(models.AuthMethodAPIKey, key)
+		if identity, ok := s.cached(k); ok {
+			revoked, err := s.revocations.IsRevoked(ctx, identity)
+			if err != nil {
+				return nil, fmt.Errorf("%w: %w", ErrUnavailable, err)
+			}
+			if revoked {
+				s.forget(k)
+			}
+		}
+	}
+
+	return s.authenticate(ctx, models.AuthMethodAPIKey, key, s.apiKeys.VerifyAPIKey)
+}
+
+// authenticate serves successful verifications from the cache. Failures are
+// never cached so a token that was just issued, or a key whose verifier was
+// briefly unavailable, works on the next attempt.
+func (s *Service) authenticate(ctx context.Context, method string, credential string, verify func(context.Context, string) (*models.Identity, error)) (*models.Identity, error) {
+	key := cacheKey(method, credential)
+
+	if identity, ok := s.cached(key); ok {
+		return identity, nil
+	}
+
+	identity, err := verify(ctx, credential)
+	if err != nil {
+		return nil, err
+	}
+
+	s.store(key, identity)
+	return identity, nil
+}
+
+// cacheKey is a digest of the credential, so the credential itself isn't kept in memory
+func cacheKey(method string, credential string) [sha256.Size]byte {
+	return sha256.Sum256([]byte(method + ":" + credential))
+}
+
+func (s *Service) cached(key [sha256.Size]byte) (*models.Identity, bool) {
+	if s.cacheTTL <= 0 {
+		return nil, false
+	}
+
+	s.cacheMu.Lock()
+	defer s.cacheMu.Unlock()
+
+	entry, ok := s.cache[key]
+	if !ok {
+		return nil, false
+	}
+
+	if !s.now().Before(entry.expiresAt) {
+		delete(s.cache, key)
+		return nil, false
+	}
+
+	return entry.identity, true
+}
+
+// store caches the identity for the cache TTL, but never past the expiry of
+// the credential it was verified from
+func (s *Service) store(key [sha256.Size]byte, identity *models.Identity) {
+	if s.cacheTTL <= 0 {
+		return
+	}
+
+	now := s.now()
+	expiresAt := now.Add(s.cacheTTL)
+	if !identity.ExpiresAt.IsZero() && identity.ExpiresAt.Before(expiresAt) {
+		expiresAt = identity.ExpiresAt
+	}
+
+	s.cacheMu.Lock()
+	defer s.cacheMu.Unlock()
+
+	if len(s.cache) >= maxCacheEntries {
+		for k, entry := range s.cache {
+			if !now.Before(entry.expiresAt) {
+				delete(s.cache, k)
+			}
+		}
+		// Still full of live entries; they expire within one TTL
+		if len(s.cache) >= maxCacheEntries {
+			return
+		}
+	}
+
+	s.cache[key] = cachedIdentity{identity: identity, expiresAt: expiresAt}
+}
+
+func (s *Service) forget(key [sha256.Size]byte) {
+	s.cacheMu.Lock()
+	defer s.cacheMu.Unlock()
+
+	delete(s.cache, key)
+}
@@ -0,0 +1,322 @@
+package authentication
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/intellifinder/v4/services/auth/pkg/models"
+)
+
+// countingVerifier accepts "valid" and counts how often it was asked
+type countingVerifier struct {
+	calls     int
+	expiresAt time.Time
+}
+
+func (v *countingVerifier) VerifyAccessToken(_ context.Context, token string) (*models.Identity, error) {
+	v.calls++
+	if token != "valid" {
+		return nil, ErrInvalidCredentials
+	}
+	return &models.Identity{Subject: "user-1", Method: models.AuthMethodJWT, ExpiresAt: v.expiresAt}, nil
+}
+
+func TestAuthenticateTokenCachesSuccesses(t *testing.T) {
+	now := time.Now()
+	tokens := &countingVerifier{expiresAt: now.Add(time.Hour)}
+	service := NewService(tokens, 5*time.Second)
+	service.now = func() time.Time { return now }
+
+	ctx := context.Background()
+	for range 3 {
+		identity, err := service.AuthenticateToken(ctx, "valid")
+		if err != nil || identity.Subject != "user-1" {
+			t.Fatalf("AuthenticateToken() = %v, %v, want user-1", identity, err)
+		}
+	}
+	if tokens.calls != 1 {
+		t.Errorf("verifier called %d times, want 1 with caching", tokens.calls)
+	}
+
+	for range 2 {
+		if _, err := service.AuthenticateToken(ctx, "invalid"); !errors.Is(err, ErrInvalidCredentials) {
+			t.Errorf("AuthenticateToken() error = %v, want ErrInvalidCredentials", err)
+		}
+	}
+	if tokens.calls != 3 {
+		t.Errorf("verifier called %d times, want failures not cached", tokens.calls)
+	}
+
+	now = now.Add(5 * time.Second)
+	service.AuthenticateToken(ctx, "valid")
+	if tokens.calls != 4 {
+		t.Errorf("verifier called %d times, want the entry expired after the TTL", tokens.calls)
+	}
+}
+
+func TestAuthenticateTokenCacheStopsAtExpiry(t *testing.T) {
+	now := time.Now()
+	tokens := &countingVerifier{expiresAt: now.Add(time.Second)}
+	service := NewService(tokens, time.Minute)
+	service.now = func() time.Time { return now }
+
+	ctx := context.Background()
+	service.AuthenticateToken(ctx, "valid")
+	now = now.Add(2 * time.Second)
+	service.AuthenticateToken(ctx, "valid")
+
+	if tokens.calls != 2 {
+		t.Errorf("verifier called %d times, want the token verified again after it expired", tokens.calls)
+	}
+}
+
+func TestAuthenticateAPIKeyWithoutVerifier(t *testing.T) {
+	service := NewService(&countingVerifier{}, time.Minute)
+
+	if _, err := service.AuthenticateAPIKey(context.Background(), "valid"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("AuthenticateAPIKey() error = %v, want ErrInvalidCredentials", err)
+	}
+}
+
+// provisioner records the subjects it was asked to provision
+type provisioner struct {
+	subjects []string
+	err      error
+}
+
+func (p *provisioner) ProvisionUser(_ context.Context, identity *models.Identity) error {
+	p.subjects = append(p.subjects, identity.Subject)
+	return p.err
+}
+
+func TestAuthenticateTokenProvisionsUsers(t *testing.T) {
+	tokens := &countingVerifier{expiresAt: time.Now().Add(time.Hour)}
+	service := NewService(tokens, time.Minute)
+
+	users := &provisioner{err: errors.New("database down")}
+	var provisionErrs []error
+	service.SetUserProvisioner(users, func(err error) { provisionErrs = append(provisionErrs, err) })
+
+	ctx := context.Background()
+	for range 2 {
+		if _, err := service.AuthenticateToken(ctx, "valid"); err != nil {
+			t.Fatalf("AuthenticateToken() error = %v, want provisioning failures ignored", err)
+		}
+	}
+	service.AuthenticateToken(ctx, "invalid")
+
+	if len(users.subjects) != 1 || users.subjects[0] != "user-1" {
+		t.Errorf("provisioned %v, want user-1 once for the uncached verification", users.subjects)
+	}
+	if len(provisionErrs) != 1 {
+		t.Errorf("reported %d provisioning errors, want 1", len(provisionErrs))
+	}
+}
+
+// impersonationVerifier accepts "impersonation" as support acting as user-2
+type impersonationVerifier struct{}
+
+func (impersonationVerifier) IsImpersonationToken(token string) bool {
+	return strings.HasPrefix(token, "impersonation")
+}
+
+func (impersonationVerifier) VerifyAccessToken(_ context.Context, token string) (*models.Identity, error) {
+	if token != "impersonation" {
+		return nil, ErrInvalidCredentials
+	}
+	return &models.Identity{Subject: "user-2", Method: models.AuthMethodJWT, Actor: &models.Actor{Subject: "support-1"}}, nil
+}
+
+func TestAuthenticateTokenAcceptsImpersonationTokens(t *testing.T) {
+	tokens := &countingVerifier{expiresAt: time.Now().Add(time.Hour)}
+	service := NewService(tokens, 0)
+	service.SetImpersonationVerifier(impersonationVerifier{})
+	users := &provisioner{}
+	service.SetUserProvisioner(users, nil)
+
+	ctx := context.Background()
+	identity, err := service.AuthenticateToken(ctx, "impersonation")
+	if err != nil {
+		t.Fatalf("AuthenticateToken() error = %v", err)
+	}
+	if identity.Subject != "user-2" || !identity.Impersonated() {
+		t.Errorf("AuthenticateToken() = %+v, want user-2 impersonated", identity)
+	}
+
+	if _, err := service.AuthenticateToken(ctx, "impersonation-forged"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("AuthenticateToken(forged) error = %v, want %v", err, ErrInvalidCredentials)
+	}
+
+	if _, err := service.AuthenticateToken(ctx, "valid"); err != nil {
+		t.Fatalf("AuthenticateToken(valid) error = %v", err)
+	}
+
+	if tokens.calls != 1 {
+		t.Errorf("token verifier called %d times, want only for the realm token", tokens.calls)
+	}
+	if len(users.subjects) != 1 || users.subjects[0] != "user-1" {
+		t.Errorf("provisioned %v, want only user-1", users.subjects)
+	}
+}
+
+// issuer accepts alice's password, the tasks service's secret and the
+// refresh tokens it issued
+type issuer struct {
+	issued map[string]bool
+}
+
+func (i *issuer) IssueTokens(_ context.Context, username string, password string) (*models.TokenSet, error) {
+	if username != "alice" || password != "secret" {
+		return nil, ErrInvalidCredentials
+	}
+	i.issued["refresh-1"] = true
+	return &models.TokenSet{AccessToken: "access-1", RefreshToken: "refresh-1"}, nil
+}
+
+func (i *issuer) IssueServiceTokens(_ context.Context, clientID string, clientSecret string) (*models.TokenSet, error) {
+	if clientID != "tasks" || clientSecret != "tasks-secret" {
+		return nil, ErrInvalidCredentials
+	}
+	return &models.TokenSet{AccessToken: "service-access-1"}, nil
+}
+
+func (i *issuer) RefreshTokens(_ context.Context, refreshToken string) (*models.TokenSet, error) {
+	if !i.issued[refreshToken] {
+		return nil, ErrInvalidCredentials
+	}
+	return &models.TokenSet{AccessToken: "access-2", RefreshToken: refreshToken}, nil
+}
+
+func (i *issuer) RevokeTokens(_ context.Context, refreshToken string) error {
+	if !i.issued[refreshToken] {
+		return ErrInvalidCredentials
+	}
+	delete(i.issued, refreshToken)
+	return nil
+}
+
+func TestLoginRefreshAndLogout(t *testing.T) {
+	service := NewService(&countingVerifier{}, 0)
+	ctx := context.Background()
+
+	if _, err := service.Login(ctx, "alice", "secret"); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("Login() without an issuer error = %v, want ErrUnavailable", err)
+	}
+
+	service.SetTokenIssuer(&issuer{issued: make(map[string]bool)})
+
+	tokens, err := service.Login(ctx, "alice", "secret")
+	if err != nil || tokens.RefreshToken != "refresh-1" {
+		t.Fatalf("Login() = %v, %v, want tokens", tokens, err)
+	}
+	if _, err := service.Login(ctx, "alice", ""); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("Login() without password error = %v, want ErrInvalidCredentials", err)
+	}
+
+	if tokens, err := service.LoginService(ctx, "tasks", "tasks-secret"); err != nil || tokens.AccessToken != "service-access-1" {
+		t.Errorf("LoginService() = %v, %v, want a service token", tokens, err)
+	}
+	if _, err := service.LoginService(ctx, "tasks", ""); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("LoginService() without secret error = %v, want ErrInvalidCredentials", err)
+	}
+
+	if refreshed, err := service.Refresh(ctx, tokens.RefreshToken); err != nil || refreshed.AccessToken != "access-2" {
+		t.Errorf("Refresh() = %v, %v, want new tokens", refreshed, err)
+	}
+
+	if err := service.Logout(ctx, tokens.RefreshToken); err != nil {
+		t.Fatalf("Logout() error = %v", err)
+	}
+	if _, err := service.Refresh(ctx, tokens.RefreshToken); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("Refresh() after logout error = %v, want ErrInvalidCredentials", err)
+	}
+}
+
+// revocationList revokes the tokens of the listed subjects, or fails with err
+type revocationList struct {
+	revoked map[string]bool
+	err     error
+}
+
+func (l *revocationList) IsRevoked(_ context.Context, identity *models.Identity) (bool, error) {
+	return l.revoked[identity.Subject], l.err
+}
+
+func TestAuthenticateTokenChecksRevocations(t *testing.T) {
+	tokens := &countingVerifier{expiresAt: time.Now().Add(time.Hour)}
+	service := NewService(tokens, time.Minute)
+	revocations := &revocationList{revoked: map[string]bool{}}
+	service.SetRevocationChecker(revocations)
+
+	ctx := context.Background()
+	if _, err := service.AuthenticateToken(ctx, "valid"); err != nil {
+		t.Fatalf("AuthenticateToken() error = %v", err)
+	}
+
+	revocations.revoked["user-1"] = true
+	_, err := service.AuthenticateToken(ctx, "valid")
+	if !errors.Is(err, ErrInvalidCredentials) || !errors.Is(err, ErrCredentialsRevoked) {
+		t.Errorf("AuthenticateToken() error = %v, want a cached token rejected once revoked", err)
+	}
+
+	revocations.err = errors.New("connection refused")
+	if _, err := service.AuthenticateToken(ctx, "valid"); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("AuthenticateToken() error = %v, want ErrUnavailable while revocations can't be checked", err)
+	}
+}
+
+// keyVerifier accepts "valid" while its owner is active
+type keyVerifier struct {
+	calls    int
+	inactive bool
+}
+
+func (v *keyVerifier) VerifyAPIKey(_ context.Context, key string) (*models.Identity, error) {
+	v.calls++
+	if key != "valid" || v.inactive {
+		return nil, ErrInvalidCredentials
+	}
+	return &models.Identity{Subject: "user-1", Method: models.AuthMethodAPIKey}, nil
+}
+
+func TestAuthenticateAPIKeyReverifiesAfterRevocation(t *testing.T) {
+	service := NewService(&countingVerifier{}, time.Minute)
+	keys := &keyVerifier{}
+	service.SetAPIKeyVerifier(keys)
+	revocations := &revocationList{revoked: map[string]bool{}}
+	service.SetRevocationChecker(revocations)
+
+	ctx := context.Background()
+	for range 2 {
+		if _, err := service.AuthenticateAPIKey(ctx, "valid"); err != nil {
+			t.Fatalf("AuthenticateAPIKey() error = %v", err)
+		}
+	}
+	if keys.calls != 1 {
+		t.Errorf("verifier called %d times, want 1 with caching", keys.calls)
+	}
+
+	// The owner was disabled, which revokes their tokens
+	revocations.revoked["user-1"] = true
+	keys.inactive = true
+	if _, err := service.AuthenticateAPIKey(ctx, "valid"); !errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("AuthenticateAPIKey() error = %v, want the cached key verified again and rejected", err)
+	}
+
+	// Revoking an active owner's tokens leaves their keys working
+	keys.inactive = false
+	if _, err := service.AuthenticateAPIKey(ctx, "valid"); err != nil {
+		t.Errorf("AuthenticateAPIKey() error = %v, want the key verified again and accepted", err)
+	}
+	if keys.calls != 3 {
+		t.Errorf("verifier called %d times, want 3", keys.calls)
+	}
+
+	revocations.err = errors.New("connection refused")
+	if _, err := service.AuthenticateAPIKey(ctx, "valid"); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("AuthenticateAPIKey() error = %v, want ErrUnavailable while revocations can't be checked", err)
+	}
+}
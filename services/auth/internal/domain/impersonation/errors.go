@@ -0,0 +1,19 @@
+package impersonation
+
+import "errors"
+
+var (
+	ErrImpersonationNotFound = errors.New("impersonation not found")
+	// ErrInvalidImpersonation is returned for requests without a reason and
+	// for targets that can't be impersonated, like the actor or disabled users
+	ErrInvalidImpersonation = errors.New("invalid impersonation")
+	// ErrNotPermitted is returned when the actor lacks the impersonate
+	// permission or the target is protected from impersonation
+	ErrNotPermitted = errors.New("impersonation not permitted")
+	// ErrImpersonating is returned for sensitive actions, including starting
+	// another impersonation, attempted while impersonating
+	ErrImpersonating = errors.New("not allowed while impersonating")
+	// ErrUnavailable is returned when impersonation isn't configured or
+	// permissions can't be checked at the moment
+	ErrUnavailable = errors.New("impersonation unavailable")
+)
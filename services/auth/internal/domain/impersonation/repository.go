@@ -0,0 +1,46 @@
+package impersonation
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/intellifinder/v4/services/auth/pkg/dto"
+	"github.com/intellifinder/v4/services/auth/pkg/models"
+)
+
+// Repository is the audit store of impersonations
+type Repository interface {
+	CreateImpersonation(ctx context.Context, i *models.Impersonation) error
+	// GetImpersonation returns ErrImpersonationNotFound for unknown IDs
+	GetImpersonation(ctx context.Context, id uuid.UUID) (*models.Impersonation, error)
+	// EndImpersonation sets the end time unless it's already set
+	EndImpersonation(ctx context.Context, id uuid.UUID, endedAt time.Time) error
+	// ListImpersonations returns the matching impersonations, most recent first
+	ListImpersonations(ctx context.Context, filter dto.ImpersonationFilter) ([]*models.Impersonation, error)
+	AddImpersonationEvent(ctx context.Context, event *models.ImpersonationEvent) error
+	// ListImpersonationEvents returns the events of an impersonation, oldest first
+	ListImpersonationEvents(ctx context.Context, impersonationID uuid.UUID) ([]*models.ImpersonationEvent, error)
+}
+
+// Users resolves impersonation targets. It's implemented by *user.Service.
+type Users interface {
+	GetUser(ctx context.Context, id uuid.UUID) (*models.User, error)
+}
+
+// Directory tells the realm roles and tenant the user's own access tokens
+// would carry, so an impersonation sees what the user sees
+type Directory interface {
+	GetUserAccess(ctx context.Context, oidcID string) (roles []string, tenantID string, err error)
+}
+
+// PermissionChecker tells whether a subject holds a permission in a tenant.
+// It returns ErrUnavailable when permissions can't be checked.
+type PermissionChecker interface {
+	HasPermission(ctx context.Context, subject string, tenantID string, permission string) (bool, error)
+}
+
+// Revoker revokes the token of an impersonation when it ends
+type Revoker interface {
+	RevokeToken(ctx context.Context, identity *models.Identity) error
+}
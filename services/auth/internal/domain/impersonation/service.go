@@ -0,0 +1,252 @@
+// Package impersonation lets support staff act as another user to reproduce
+// their issues. Impersonations get a short-lived access token signed by the
+// auth service, and everything done with it is kept in an audit trail.
+package impersonation
+
+import (
+	"context"
+	"crypto/ed25519"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/intellifinder/v4/services/auth/internal/domain/authentication"
+	"github.com/intellifinder/v4/services/auth/pkg/dto"
+	"github.com/intellifinder/v4/services/auth/pkg/models"
+)
+
+// Permission is required to start impersonations
+const Permission = "auth:impersonate"
+
+// protectedRole can never be impersonated, so impersonation can't be used to
+// gain administrative rights
+const protectedRole = "admin"
+
+// maxReasonLength bounds the reason stored in the audit trail
+const maxReasonLength = 500
+
+var _ authentication.TokenVerifier = (*Service)(nil)
+
+type Service struct {
+	repo        Repository
+	users       Users
+	directory   Directory
+	permissions PermissionChecker
+	revoker     Revoker
+	key         ed25519.PrivateKey
+	lifetime    time.Duration
+	now         func() time.Time
+}
+
+// NewService returns a service whose tokens are valid for lifetime.
+// Impersonations can only be started once a signing key, directory and
+// permission checker are set.
+func NewService(repo Repository, users Users, lifetime time.Duration) *Service {
+	return &Service{
+		repo:     repo,
+		users:    users,
+		lifetime: lifetime,
+		now:      time.Now,
+	}
+}
+
+// SetSigningKey sets the key impersonation tokens are signed and verified with
+func (s *Service) SetSigningKey(key ed25519.PrivateKey) {
+	s.key = key
+}
+
+// SetDirectory looks up the roles and tenant of impersonated users
+func (s *Service) SetDirectory(directory Directory) {
+	s.directory = directory
+}
+
+// SetPermissionChecker checks who may impersonate and who is protected from it
+func (s *Service) SetPermissionChecker(permissions PermissionChecker) {
+	s.permissions = permissions
+}
+
+// SetRevoker revokes the tokens of impersonations when they are ended, so
+// they stop working at once rather than when they expire
+func (s *Service) SetRevoker(revoker Revoker) {
+	s.revoker = revoker
+}
+
+// Start lets the actor act as the local user targetID and returns the
+// impersonation with its access token
+func (s *Service) Start(ctx context.Context, actor *models.Identity, targetID uuid.UUID, reason string) (*models.Impersonation, string, error) {
+	if s.key == nil || s.directory == nil || s.permissions == nil {
+		return nil, "", fmt.Errorf("%w: impersonation is not enabled", ErrUnavailable)
+	}
+
+	if actor.Impersonated() {
+		return nil, "", ErrImpersonating
+	}
+
+	if reason == "" || len(reason) > maxReasonLength {
+		return nil, "", fmt.Errorf("%w: reason must be 1 to %d characters", ErrInvalidImpersonation, maxReasonLength)
+	}
+
+	target, err := s.users.GetUser(ctx, targetID)
+	if err != nil {
+		return nil, "", err
+	}
+
+	switch {
+	case target.OIDCID == actor.Subject:
+		return nil, "", fmt.Errorf("%w: users can't impersonate themselves", ErrInvalidImpersonation)
+	case target.DeletedAt != nil || !target.Enabled:
+		return nil, "", fmt.Errorf("%w: user is disabled or deleted", ErrInvalidImpersonation)
+	}
+
+	roles, tenantID, err := s.directory.GetUserAccess(ctx, target.OIDCID)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to get access of user %s: %w", target.OIDCID, err)
+	}
+
+	if err := s.authorize(ctx, actor, target, roles, tenantID); err != nil {
+		return nil, "", err
+	}
+
+	now := s.now()
+	impersonation := &models.Impersonation{
+		ID:             uuid.New(),
+		ActorID:        actor.Subject,
+		ActorUsername:  actor.Username,
+		TargetID:       target.OIDCID,
+		TargetUsername: target.Username,
+		TenantID:       tenantID,
+		Reason:         reason,
+		StartedAt:      now.Truncate(time.Second),
+		ExpiresAt:      now.Add(s.lifetime).Truncate(time.Second),
+	}
+
+	token, err := s.signToken(impersonation, target, roles)
+	if err != nil {
+		return nil, "", err
+	}
+
+	// The audit trail is written before the token is handed out
+	if err := s.repo.CreateImpersonation(ctx, impersonation); err != nil {
+		return nil, "", fmt.Errorf("failed to create impersonation: %w", err)
+	}
+	if err := s.addEvent(ctx, impersonation.ID, models.ImpersonationStarted, "", ""); err != nil {
+		return nil, "", err
+	}
+
+	return impersonation, token, nil
+}
+
+// authorize requires the actor to hold the permission in the target's
+// tenant, and the target to be neither an admin nor allowed to impersonate
+func (s *Service) authorize(ctx context.Context, actor *models.Identity, target *models.User, roles []string, tenantID string) error {
+	allowed, err := s.permissions.HasPermission(ctx, actor.Subject, tenantID, Permission)
+	if err != nil {
+		return fmt.Errorf("failed to check permission of %s: %w", actor.Subject, err)
+	}
+	if !allowed {
+		return fmt.Errorf("%w: missing permission %s", ErrNotPermitted, Permission)
+	}
+
+	if slices.Contains(roles, protectedRole) {
+		return fmt.Errorf("%w: users with the %s role can't be impersonated", ErrNotPermitted, protectedRole)
+	}
+
+	protected, err := s.permissions.HasPermission(ctx, target.OIDCID, tenantID, Permission)
+	if err != nil {
+		return fmt.Errorf("failed to check permission of %s: %w", target.OIDCID, err)
+	}
+	if protected {
+		return fmt.Errorf("%w: users who can impersonate can't be impersonated", ErrNotPermitted)
+	}
+
+	return nil
+}
+
+// End ends an impersonation and revokes its token. It may be called with the
+// impersonation's own token or by its actor; ending it again is not an error.
+func (s *Service) End(ctx context.Context, caller *models.Identity, id uuid.UUID) (*models.Impersonation, error) {
+	impersonation, err := s.repo.GetImpersonation(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	own := caller.Impersonated() && caller.TokenID == id.String()
+	if !own && (caller.Impersonated() || caller.Subject != impersonation.ActorID) {
+		return nil, ErrImpersonationNotFound
+	}
+
+	if impersonation.EndedAt != nil {
+		return impersonation, nil
+	}
+
+	if s.revoker != nil {
+		token := &models.Identity{
+			Subject:   impersonation.TargetID,
+			TokenID:   impersonation.ID.String(),
+			Method:    models.AuthMethodJWT,
+			ExpiresAt: impersonation.ExpiresAt,
+		}
+		if err := s.revoker.RevokeToken(ctx, token); err != nil {
+			return nil, fmt.Errorf("failed to revoke impersonation token: %w", err)
+		}
+	}
+
+	now := s.now()
+	if err := s.repo.EndImpersonation(ctx, id, now); err != nil {
+		return nil, fmt.Errorf("failed to end impersonation: %w", err)
+	}
+	if err := s.addEvent(ctx, id, models.ImpersonationEnded, "", ""); err != nil {
+		return nil, err
+	}
+
+	impersonation.EndedAt = &now
+	return impersonation, nil
+}
+
+// RecordRequest adds a request made with the identity to the audit trail of
+// its impersonation; other identities are ignored. blocked records a
+// sensitive action that was refused.
+func (s *Service) RecordRequest(ctx context.Context, identity *models.Identity, method string, path string, blocked bool) error {
+	if !identity.Impersonated() {
+		return nil
+	}
+
+	id, err := uuid.Parse(identity.TokenID)
+	if err != nil {
+		return fmt.Errorf("%w: malformed impersonation ID", ErrImpersonationNotFound)
+	}
+
+	eventType := models.ImpersonationRequest
+	if blocked {
+		eventType = models.ImpersonationBlocked
+	}
+	return s.addEvent(ctx, id, eventType, method, path)
+}
+
+func (s *Service) addEvent(ctx context.Context, id uuid.UUID, eventType string, method string, path string) error {
+	event := &models.ImpersonationEvent{
+		ImpersonationID: id,
+		Type:            eventType,
+		Method:          method,
+		Path:            path,
+		CreatedAt:       s.now(),
+	}
+	if err := s.repo.AddImpersonationEvent(ctx, event); err != nil {
+		return fmt.Errorf("failed to record impersonation event: %w", err)
+	}
+	return nil
+}
+
+// ListImpersonations returns the matching impersonations, most recent first
+func (s *Service) ListImpersonations(ctx context.Context, filter dto.ImpersonationFilter) ([]*models.Impersonation, error) {
+	return s.repo.ListImpersonations(ctx, filter)
+}
+
+// ListEvents returns the audit trail of an impersonation, oldest first
+func (s *Service) ListEvents(ctx context.Context, id uuid.UUID) ([]*models.ImpersonationEvent, error) {
+	if _, err := s.repo.GetImpersonation(ctx, id); err != nil {
+		return nil, err
+	}
+	return s.repo.ListImpersonationEvents(ctx, id)
+}
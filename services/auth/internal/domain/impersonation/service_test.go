@@ -0,0 +1,237 @@
+package impersonation_test
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/intellifinder/v4/services/auth/internal/domain/authentication"
+	"github.com/intellifinder/v4/services/auth/internal/domain/impersonation"
+	"github.com/intellifinder/v4/services/auth/internal/domain/revocation"
+	"github.com/intellifinder/v4/services/auth/internal/domain/user"
+	"github.com/intellifinder/v4/services/auth/internal/infrastructure/memory"
+	"github.com/intellifinder/v4/services/auth/pkg/dto"
+	"github.com/intellifinder/v4/services/auth/pkg/models"
+)
+
+// directory grants each user the roles in its map, all in tenant-1
+type directory map[string][]string
+
+func (d directory) GetUserAccess(_ context.Context, oidcID string) ([]string, string, error) {
+	return d[oidcID], "tenant-1", nil
+}
+
+// permissions lets the subjects in its map impersonate
+type permissions struct {
+	impersonators map[string]bool
+	err           error
+}
+
+func (p *permissions) HasPermission(_ context.Context, subject string, tenantID string, permission string) (bool, error) {
+	if p.err != nil {
+		return false, p.err
+	}
+	return tenantID == "tenant-1" && permission == impersonation.Permission && p.impersonators[subject], nil
+}
+
+var support = &models.Identity{Subject: "kc-support", Username: "support", Method: models.AuthMethodJWT}
+
+type fixture struct {
+	service     *impersonation.Service
+	auth        *authentication.Service
+	repo        *memory.Repository
+	permissions *permissions
+	users       map[string]uuid.UUID
+	now         time.Time
+}
+
+// newFixture serves alice, root (an admin), carol (who may impersonate too)
+// and a disabled user to the support user
+func newFixture(t *testing.T) *fixture {
+	t.Helper()
+
+	ctx := context.Background()
+	repo := memory.NewRepository()
+	users := map[string]uuid.UUID{}
+	for _, u := range []models.User{
+		{OIDCID: "kc-support", Username: "support", Enabled: true},
+		{OIDCID: "kc-alice", Username: "alice", Email: "alice@example.com", Enabled: true},
+		{OIDCID: "kc-root", Username: "root", Enabled: true},
+		{OIDCID: "kc-carol", Username: "carol", Enabled: true},
+		{OIDCID: "kc-dave", Username: "dave", Enabled: false},
+	} {
+		if err := repo.UpsertUser(ctx, &u); err != nil {
+			t.Fatalf("UpsertUser() error = %v", err)
+		}
+		stored, err := repo.GetUserByOIDCID(ctx, u.OIDCID)
+		if err != nil {
+			t.Fatalf("GetUserByOIDCID() error = %v", err)
+		}
+		users[u.Username] = stored.ID
+	}
+
+	now := time.Now()
+	perms := &permissions{impersonators: map[string]bool{"kc-support": true, "kc-carol": true}}
+
+	service := impersonation.NewService(repo, user.NewService(repo), 15*time.Minute)
+	service.SetSigningKey(ed25519.NewKeyFromSeed(make([]byte, ed25519.SeedSize)))
+	service.SetDirectory(directory{"kc-alice": {"user"}, "kc-root": {"user", "admin"}})
+	service.SetPermissionChecker(perms)
+	revocations := revocation.NewService(repo, time.Hour)
+	service.SetRevoker(revocations)
+	impersonation.SetNow(service, func() time.Time { return now })
+
+	auth := authentication.NewService(nil, 0)
+	auth.SetImpersonationVerifier(service)
+	auth.SetRevocationChecker(revocations)
+
+	return &fixture{service: service, auth: auth, repo: repo, permissions: perms, users: users, now: now}
+}
+
+func TestStartImpersonation(t *testing.T) {
+	f := newFixture(t)
+	ctx := context.Background()
+
+	started, token, err := f.service.Start(ctx, support, f.users["alice"], "ticket 42")
+	if err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	if started.ActorID != "kc-support" || started.TargetID != "kc-alice" || started.TenantID != "tenant-1" || !started.Active(f.now) {
+		t.Errorf("Start() = %+v, want active impersonation of alice by support", started)
+	}
+
+	identity, err := f.auth.AuthenticateToken(ctx, token)
+	if err != nil {
+		t.Fatalf("AuthenticateToken() error = %v", err)
+	}
+	want := &models.Actor{Subject: "kc-support", Username: "support"}
+	if identity.Subject != "kc-alice" || identity.Username != "alice" || !slices.Equal(identity.Roles, []string{"user"}) ||
+		identity.TokenID != started.ID.String() || *identity.Actor != *want {
+		t.Errorf("AuthenticateToken() = %+v, want alice acted as by support", identity)
+	}
+
+	events, err := f.service.ListEvents(ctx, started.ID)
+	if err != nil || len(events) != 1 || events[0].Type != models.ImpersonationStarted {
+		t.Errorf("ListEvents() = %v, %v, want the start", events, err)
+	}
+}
+
+func TestStartImpersonationRefusals(t *testing.T) {
+	f := newFixture(t)
+	ctx := context.Background()
+
+	tests := map[string]struct {
+		actor  *models.Identity
+		target uuid.UUID
+		reason string
+		want   error
+	}{
+		"no reason":           {actor: support, target: f.users["alice"], want: impersonation.ErrInvalidImpersonation},
+		"self":                {actor: support, target: f.users["support"], reason: "r", want: impersonation.ErrInvalidImpersonation},
+		"disabled user":       {actor: support, target: f.users["dave"], reason: "r", want: impersonation.ErrInvalidImpersonation},
+		"unknown user":        {actor: support, target: uuid.New(), reason: "r", want: user.ErrUserNotFound},
+		"admin":               {actor: support, target: f.users["root"], reason: "r", want: impersonation.ErrNotPermitted},
+		"other impersonator":  {actor: support, target: f.users["carol"], reason: "r", want: impersonation.ErrNotPermitted},
+		"without permission":  {actor: &models.Identity{Subject: "kc-alice", Method: models.AuthMethodJWT}, target: f.users["carol"], reason: "r", want: impersonation.ErrNotPermitted},
+		"while impersonating": {actor: &models.Identity{Subject: "kc-alice", Actor: &models.Actor{Subject: "kc-support"}}, target: f.users["carol"], reason: "r", want: impersonation.ErrImpersonating},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, _, err := f.service.Start(ctx, tt.actor, tt.target, tt.reason); !errors.Is(err, tt.want) {
+				t.Errorf("Start() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	f.permissions.err = impersonation.ErrUnavailable
+	if _, _, err := f.service.Start(ctx, support, f.users["alice"], "r"); !errors.Is(err, impersonation.ErrUnavailable) {
+		t.Errorf("Start() while permissions are down error = %v, want %v", err, impersonation.ErrUnavailable)
+	}
+
+	list, _ := f.service.ListImpersonations(ctx, dto.ImpersonationFilter{})
+	if len(list) != 0 {
+		t.Errorf("ListImpersonations() = %v, want none after refusals", list)
+	}
+}
+
+func TestImpersonationTokenExpires(t *testing.T) {
+	f := newFixture(t)
+	ctx := context.Background()
+
+	_, token, err := f.service.Start(ctx, support, f.users["alice"], "ticket 42")
+	if err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+
+	impersonation.SetNow(f.service, func() time.Time { return f.now.Add(16 * time.Minute) })
+	if _, err := f.auth.AuthenticateToken(ctx, token); !errors.Is(err, authentication.ErrCredentialsExpired) {
+		t.Errorf("AuthenticateToken() after expiry error = %v, want %v", err, authentication.ErrCredentialsExpired)
+	}
+
+	other := impersonation.NewService(f.repo, user.NewService(f.repo), time.Minute)
+	other.SetSigningKey(ed25519.NewKeyFromSeed(append(make([]byte, ed25519.SeedSize-1), 1)))
+	if _, err := other.VerifyAccessToken(ctx, token); !errors.Is(err, authentication.ErrInvalidCredentials) {
+		t.Errorf("VerifyAccessToken() with another key error = %v, want %v", err, authentication.ErrInvalidCredentials)
+	}
+}
+
+func TestEndImpersonation(t *testing.T) {
+	f := newFixture(t)
+	ctx := context.Background()
+
+	started, token, err := f.service.Start(ctx, support, f.users["alice"], "ticket 42")
+	if err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	identity, err := f.auth.AuthenticateToken(ctx, token)
+	if err != nil {
+		t.Fatalf("AuthenticateToken() error = %v", err)
+	}
+
+	if err := f.service.RecordRequest(ctx, identity, "GET", "/tasks", false); err != nil {
+		t.Fatalf("RecordRequest() error = %v", err)
+	}
+	if err := f.service.RecordRequest(ctx, identity, "POST", "/api-keys", true); err != nil {
+		t.Fatalf("RecordRequest() blocked error = %v", err)
+	}
+	if err := f.service.RecordRequest(ctx, support, "GET", "/tasks", false); err != nil {
+		t.Fatalf("RecordRequest() without impersonation error = %v", err)
+	}
+
+	alice := &models.Identity{Subject: "kc-alice", Method: models.AuthMethodJWT}
+	if _, err := f.service.End(ctx, alice, started.ID); !errors.Is(err, impersonation.ErrImpersonationNotFound) {
+		t.Errorf("End() by the target error = %v, want %v", err, impersonation.ErrImpersonationNotFound)
+	}
+
+	ended, err := f.service.End(ctx, identity, started.ID)
+	if err != nil {
+		t.Fatalf("End() error = %v", err)
+	}
+	if ended.Active(f.now) {
+		t.Errorf("End() = %+v, want ended", ended)
+	}
+	if _, err := f.service.End(ctx, support, started.ID); err != nil {
+		t.Errorf("End() again by the actor error = %v", err)
+	}
+
+	if _, err := f.auth.AuthenticateToken(ctx, token); !errors.Is(err, authentication.ErrCredentialsRevoked) {
+		t.Errorf("AuthenticateToken() after end error = %v, want %v", err, authentication.ErrCredentialsRevoked)
+	}
+
+	events, err := f.service.ListEvents(ctx, started.ID)
+	if err != nil {
+		t.Fatalf("ListEvents() error = %v", err)
+	}
+	var types []string
+	for _, event := range events {
+		types = append(types, event.Type)
+	}
+	want := []string{models.ImpersonationStarted, models.ImpersonationRequest, models.ImpersonationBlocked, models.ImpersonationEnded}
+	if !slices.Equal(types, want) {
+		t.Errorf("ListEvents() types = %v, want %v", types, want)
+	}
+}
@@ -0,0 +1,12 @@
+package ingestion
+
+import "errors"
+
+var (
+	// ErrWebhookDisabled is returned for webhook deliveries when no webhook secret is set
+	ErrWebhookDisabled = errors.New("event webhook disabled")
+	// ErrInvalidSignature is returned for webhook deliveries not signed with the webhook secret
+	ErrInvalidSignature = errors.New("invalid event signature")
+	// ErrInvalidEvents is returned for webhook deliveries that can't be decoded
+	ErrInvalidEvents = errors.New("invalid events")
+)
@@ -0,0 +1,57 @@
+package ingestion
+
+import (
+	"context"
+	"time"
+
+	"github.com/intellifinder/v4/services/auth/pkg/models"
+)
+
+// Repository remembers which events were ingested, so events delivered by
+// both the webhook and polling, or polled by several replicas, are applied once
+type Repository interface {
+	// ClaimEvent records the event until ttl passes and returns false if it
+	// was recorded already
+	ClaimEvent(ctx context.Context, id string, ttl time.Duration) (bool, error)
+	// ReleaseEvent forgets a claimed event whose ingestion failed, so it can be retried
+	ReleaseEvent(ctx context.Context, id string) error
+	// GetEventCursor returns the time polling has caught up to, or zero if it never ran
+	GetEventCursor(ctx context.Context) (time.Time, error)
+	// SetEventCursor moves the cursor forward; an earlier time never replaces a later one
+	SetEventCursor(ctx context.Context, cursor time.Time) error
+}
+
+// Source lists the events of the identity provider
+type Source interface {
+	// ListEvents returns the relevant events that occurred at or after since, oldest first
+	ListEvents(ctx context.Context, since time.Time) ([]*models.DirectoryEvent, error)
+}
+
+// Parser decodes the events delivered to the webhook, skipping irrelevant ones
+type Parser interface {
+	ParseEvents(body []byte) ([]*models.DirectoryEvent, error)
+}
+
+// Users updates local users from the directory. It's implemented by *user.Service.
+type Users interface {
+	// SyncUser returns the domain event type of the change, or "" if there was none
+	SyncUser(ctx context.Context, oidcID string) (string, error)
+}
+
+// Sessions ends local sessions. It's implemented by *session.Service.
+type Sessions interface {
+	// EndSession returns the session's user, or "" for sessions it didn't know
+	EndSession(ctx context.Context, id string) (string, error)
+	RevokeAllSessions(ctx context.Context, userID string) (int, error)
+}
+
+// Revoker revokes the access tokens of users whose roles changed, so they
+// have to get new ones carrying the new roles
+type Revoker interface {
+	RevokeUserTokens(ctx context.Context, userID string, issuedBefore time.Time) error
+}
+
+// Publisher tells other services about changes
+type Publisher interface {
+	PublishEvent(ctx context.Context, event *models.DomainEvent) error
+}
@@ -0,0 +1,219 @@
+// Package ingestion follows changes made directly in Keycloak, e.g. in its
+// admin console. Events arrive through a webhook or by polling Keycloak's
+// event store; each is applied once to the local users and sessions and
+// published as a domain event for other services.
+package ingestion
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/intellifinder/v4/services/auth/pkg/models"
+)
+
+// claimTTL is how long ingested events are remembered. It must cover the
+// time between an event being delivered to the webhook and being polled.
+const claimTTL = 24 * time.Hour
+
+// SignaturePrefix precedes the hex-encoded HMAC-SHA256 of webhook deliveries
+const SignaturePrefix = "sha256="
+
+type Service struct {
+	repo      Repository
+	users     Users
+	sessions  Sessions
+	revoker   Revoker
+	publisher Publisher
+	source    Source
+	parser    Parser
+	secret    []byte
+	now       func() time.Time
+}
+
+func NewService(repo Repository, users Users, sessions Sessions, revoker Revoker, publisher Publisher) *Service {
+	return &Service{
+		repo:      repo,
+		users:     users,
+		sessions:  sessions,
+		revoker:   revoker,
+		publisher: publisher,
+		now:       time.Now,
+	}
+}
+
+// SetSource enables polling for events
+func (s *Service) SetSource(source Source) {
+	s.source = source
+}
+
+// SetWebhook accepts deliveries to the webhook signed with the secret
+func (s *Service) SetWebhook(parser Parser, secret []byte) {
+	s.parser = parser
+	s.secret = secret
+}
+
+// HandleWebhook verifies a webhook delivery and ingests its events. The
+// signature is SignaturePrefix followed by the HMAC-SHA256 of the body.
+func (s *Service) HandleWebhook(ctx context.Context, body []byte, signature string) error {
+	if s.parser == nil || len(s.secret) == 0 {
+		return ErrWebhookDisabled
+	}
+
+	sum, err := hex.DecodeString(strings.TrimPrefix(signature, SignaturePrefix))
+	if err != nil || !strings.HasPrefix(signature, SignaturePrefix) {
+		return ErrInvalidSignature
+	}
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write(body)
+	if !hmac.Equal(sum, mac.Sum(nil)) {
+		return ErrInvalidSignature
+	}
+
+	events, err := s.parser.ParseEvents(body)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidEvents, err)
+	}
+
+	for _, event := range events {
+		if err := s.Ingest(ctx, event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Poll ingests the events since the last poll and returns how many were
+// new. The first poll only starts the cursor, so history isn't replayed.
+func (s *Service) Poll(ctx context.Context) (int, error) {
+	if s.source == nil {
+		return 0, fmt.Errorf("no event source configured")
+	}
+
+	cursor, err := s.repo.GetEventCursor(ctx)
+	if err != nil {
+		return 0, err
+	}
+	if cursor.IsZero() {
+		return 0, s.repo.SetEventCursor(ctx, s.now())
+	}
+
+	events, err := s.source.ListEvents(ctx, cursor)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list events: %w", err)
+	}
+
+	// The cursor stops at the first event that fails, which is listed again
+	// by the next poll. So are the events at the cursor, which are skipped
+	// then because they were claimed.
+	ingested := 0
+	var ingestErr error
+	for _, event := range events {
+		claimed, err := s.ingest(ctx, event)
+		if err != nil {
+			ingestErr = err
+			break
+		}
+		if claimed {
+			ingested++
+		}
+		cursor = event.OccurredAt
+	}
+
+	if err := s.repo.SetEventCursor(ctx, cursor); err != nil {
+		return ingested, err
+	}
+	return ingested, ingestErr
+}
+
+// Ingest applies an event unless it was ingested before
+func (s *Service) Ingest(ctx context.Context, event *models.DirectoryEvent) error {
+	_, err := s.ingest(ctx, event)
+	return err
+}
+
+// ingest claims the event, applies it and publishes the resulting domain
+// events. Claims of events that couldn't be applied are released so they
+// are retried; publishing isn't retried, since the change was applied.
+func (s *Service) ingest(ctx context.Context, event *models.DirectoryEvent) (bool, error) {
+	claimed, err := s.repo.ClaimEvent(ctx, event.ID, claimTTL)
+	if err != nil {
+		return false, fmt.Errorf("failed to claim event %s: %w", event.ID, err)
+	}
+	if !claimed {
+		return false, nil
+	}
+
+	published, err := s.apply(ctx, event)
+	if err != nil {
+		if releaseErr := s.repo.ReleaseEvent(ctx, event.ID); releaseErr != nil {
+			return true, fmt.Errorf("%w (and failed to release it: %w)", err, releaseErr)
+		}
+		return true, err
+	}
+
+	for _, domainEvent := range published {
+		if err := s.publisher.PublishEvent(ctx, domainEvent); err != nil {
+			return true, fmt.Errorf("failed to publish %s event: %w", domainEvent.Type, err)
+		}
+	}
+	return true, nil
+}
+
+// apply follows the event locally and returns the domain events to publish
+func (s *Service) apply(ctx context.Context, event *models.DirectoryEvent) ([]*models.DomainEvent, error) {
+	switch event.Type {
+	case models.DirectoryUserChanged:
+		change, err := s.users.SyncUser(ctx, event.UserID)
+		if err != nil || change == "" {
+			return nil, err
+		}
+		return []*models.DomainEvent{s.domainEvent(change, event.UserID, "")}, nil
+
+	case models.DirectoryRolesChanged:
+		// Tokens carry the roles they were issued with; sessions stay valid
+		// and their next refresh picks up the new roles
+		if err := s.revoker.RevokeUserTokens(ctx, event.UserID, s.now()); err != nil {
+			return nil, fmt.Errorf("failed to revoke tokens of user %s: %w", event.UserID, err)
+		}
+		return []*models.DomainEvent{s.domainEvent(models.UserRolesChanged, event.UserID, "")}, nil
+
+	case models.DirectoryLogout:
+		if event.SessionID != "" {
+			userID, err := s.sessions.EndSession(ctx, event.SessionID)
+			if err != nil {
+				return nil, fmt.Errorf("failed to end session %s: %w", event.SessionID, err)
+			}
+			if userID == "" {
+				userID = event.UserID
+			}
+			return []*models.DomainEvent{s.domainEvent(models.SessionEnded, userID, event.SessionID)}, nil
+		}
+
+		if _, err := s.sessions.RevokeAllSessions(ctx, event.UserID); err != nil {
+			return nil, fmt.Errorf("failed to end sessions of user %s: %w", event.UserID, err)
+		}
+		if err := s.revoker.RevokeUserTokens(ctx, event.UserID, s.now()); err != nil {
+			return nil, fmt.Errorf("failed to revoke tokens of user %s: %w", event.UserID, err)
+		}
+		return []*models.DomainEvent{s.domainEvent(models.UserLoggedOut, event.UserID, "")}, nil
+
+	default:
+		return nil, nil
+	}
+}
+
+func (s *Service) domainEvent(eventType string, userID string, sessionID string) *models.DomainEvent {
+	return &models.DomainEvent{
+		ID:         uuid.NewString(),
+		Type:       eventType,
+		UserID:     userID,
+		SessionID:  sessionID,
+		OccurredAt: s.now(),
+	}
+}
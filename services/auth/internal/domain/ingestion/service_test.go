@@ -0,0 +1,248 @@
+package ingestion_test
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/intellifinder/v4/services/auth/internal/domain/ingestion"
+	"github.com/intellifinder/v4/services/auth/internal/infrastructure/memory"
+	"github.com/intellifinder/v4/services/auth/pkg/models"
+)
+
+// directory records what the service asked of the users, sessions and
+// revocation services
+type directory struct {
+	changes   map[string]string
+	sessions  map[string]string
+	synced    []string
+	ended     []string
+	loggedOut []string
+	revoked   []string
+	fail      error
+}
+
+func newDirectory() *directory {
+	return &directory{changes: make(map[string]string), sessions: make(map[string]string)}
+}
+
+func (d *directory) SyncUser(_ context.Context, oidcID string) (string, error) {
+	if d.fail != nil {
+		return "", d.fail
+	}
+	d.synced = append(d.synced, oidcID)
+	return d.changes[oidcID], nil
+}
+
+func (d *directory) EndSession(_ context.Context, id string) (string, error) {
+	d.ended = append(d.ended, id)
+	return d.sessions[id], nil
+}
+
+func (d *directory) RevokeAllSessions(_ context.Context, userID string) (int, error) {
+	d.loggedOut = append(d.loggedOut, userID)
+	return 1, nil
+}
+
+func (d *directory) RevokeUserTokens(_ context.Context, userID string, _ time.Time) error {
+	d.revoked = append(d.revoked, userID)
+	return nil
+}
+
+// source lists its events the way Keycloak does, from since on
+type source struct {
+	events []*models.DirectoryEvent
+	since  []time.Time
+}
+
+func (s *source) ListEvents(_ context.Context, since time.Time) ([]*models.DirectoryEvent, error) {
+	s.since = append(s.since, since)
+	var events []*models.DirectoryEvent
+	for _, e := range s.events {
+		if !e.OccurredAt.Before(since) {
+			events = append(events, e)
+		}
+	}
+	return events, nil
+}
+
+func (s *source) ParseEvents(body []byte) ([]*models.DirectoryEvent, error) {
+	var events []*models.DirectoryEvent
+	if err := json.Unmarshal(body, &events); err != nil {
+		return nil, err
+	}
+	return events, nil
+}
+
+func newService(t *testing.T) (*ingestion.Service, *memory.Repository, *directory) {
+	t.Helper()
+
+	repo := memory.NewRepository()
+	dir := newDirectory()
+	service := ingestion.NewService(repo, dir, dir, dir, repo)
+	return service, repo, dir
+}
+
+func publishedTypes(repo *memory.Repository) []string {
+	var types []string
+	for _, e := range repo.PublishedEvents() {
+		types = append(types, e.Type+" "+e.UserID+" "+e.SessionID)
+	}
+	return types
+}
+
+func sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return ingestion.SignaturePrefix + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestIngest(t *testing.T) {
+	service, repo, dir := newService(t)
+	ctx := context.Background()
+	dir.changes["kc-1"] = models.UserDisabled
+	dir.sessions["sid-1"] = "kc-2"
+
+	events := []*models.DirectoryEvent{
+		{ID: "1", Type: models.DirectoryUserChanged, UserID: "kc-1"},
+		{ID: "2", Type: models.DirectoryUserChanged, UserID: "kc-unchanged"},
+		{ID: "3", Type: models.DirectoryRolesChanged, UserID: "kc-3"},
+		{ID: "4", Type: models.DirectoryLogout, SessionID: "sid-1"},
+		{ID: "5", Type: models.DirectoryLogout, UserID: "kc-4"},
+		{ID: "6", Type: "unknown", UserID: "kc-5"},
+		// Delivered twice
+		{ID: "1", Type: models.DirectoryUserChanged, UserID: "kc-1"},
+	}
+	for _, event := range events {
+		if err := service.Ingest(ctx, event); err != nil {
+			t.Fatalf("Ingest(%s) error = %v", event.ID, err)
+		}
+	}
+
+	if want := []string{"kc-1", "kc-unchanged"}; !slices.Equal(dir.synced, want) {
+		t.Errorf("synced users = %v, want %v", dir.synced, want)
+	}
+	if want := []string{"sid-1"}; !slices.Equal(dir.ended, want) {
+		t.Errorf("ended sessions = %v, want %v", dir.ended, want)
+	}
+	if want := []string{"kc-4"}; !slices.Equal(dir.loggedOut, want) {
+		t.Errorf("logged out users = %v, want %v", dir.loggedOut, want)
+	}
+	// Role changes only revoke tokens; the sessions of the user stay valid
+	if want := []string{"kc-3", "kc-4"}; !slices.Equal(dir.revoked, want) {
+		t.Errorf("revoked tokens of = %v, want %v", dir.revoked, want)
+	}
+
+	want := []string{
+		models.UserDisabled + " kc-1 ",
+		models.UserRolesChanged + " kc-3 ",
+		models.SessionEnded + " kc-2 sid-1",
+		models.UserLoggedOut + " kc-4 ",
+	}
+	if got := publishedTypes(repo); !slices.Equal(got, want) {
+		t.Errorf("published %q, want %q", got, want)
+	}
+}
+
+func TestFailedEventsAreRetried(t *testing.T) {
+	service, repo, dir := newService(t)
+	ctx := context.Background()
+	dir.changes["kc-1"] = models.UserUpdated
+	event := &models.DirectoryEvent{ID: "1", Type: models.DirectoryUserChanged, UserID: "kc-1"}
+
+	dir.fail = errors.New("directory unavailable")
+	if err := service.Ingest(ctx, event); err == nil {
+		t.Fatal("Ingest() succeeded while the directory was unavailable")
+	}
+
+	dir.fail = nil
+	if err := service.Ingest(ctx, event); err != nil {
+		t.Fatalf("Ingest() retry error = %v", err)
+	}
+	if len(repo.PublishedEvents()) != 1 {
+		t.Errorf("published %d events, want 1", len(repo.PublishedEvents()))
+	}
+}
+
+func TestHandleWebhook(t *testing.T) {
+	service, repo, _ := newService(t)
+	ctx := context.Background()
+	body := []byte(`[{"ID": "1", "Type": "roles_changed", "UserID": "kc-1"}]`)
+
+	if err := service.HandleWebhook(ctx, body, sign("secret", body)); !errors.Is(err, ingestion.ErrWebhookDisabled) {
+		t.Errorf("HandleWebhook() without a secret error = %v, want %v", err, ingestion.ErrWebhookDisabled)
+	}
+
+	service.SetWebhook(&source{}, []byte("secret"))
+
+	for _, signature := range []string{"", "sha256=zz", sign("other", body), sign("secret", body)[len(ingestion.SignaturePrefix):]} {
+		if err := service.HandleWebhook(ctx, body, signature); !errors.Is(err, ingestion.ErrInvalidSignature) {
+			t.Errorf("HandleWebhook() signed %q error = %v, want %v", signature, err, ingestion.ErrInvalidSignature)
+		}
+	}
+
+	invalid := []byte(`{`)
+	if err := service.HandleWebhook(ctx, invalid, sign("secret", invalid)); !errors.Is(err, ingestion.ErrInvalidEvents) {
+		t.Errorf("HandleWebhook() of invalid JSON error = %v, want %v", err, ingestion.ErrInvalidEvents)
+	}
+	if len(repo.PublishedEvents()) != 0 {
+		t.Fatalf("rejected deliveries published %d events", len(repo.PublishedEvents()))
+	}
+
+	for range 2 {
+		if err := service.HandleWebhook(ctx, body, sign("secret", body)); err != nil {
+			t.Fatalf("HandleWebhook() error = %v", err)
+		}
+	}
+	if want := []string{models.UserRolesChanged + " kc-1 "}; !slices.Equal(publishedTypes(repo), want) {
+		t.Errorf("published %q, want %q", publishedTypes(repo), want)
+	}
+}
+
+func TestPoll(t *testing.T) {
+	service, repo, _ := newService(t)
+	ctx := context.Background()
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	ingestion.SetNow(service, func() time.Time { return now })
+
+	if _, err := service.Poll(ctx); err == nil {
+		t.Error("Poll() without a source succeeded")
+	}
+
+	src := &source{events: []*models.DirectoryEvent{
+		{ID: "old", Type: models.DirectoryRolesChanged, UserID: "kc-old", OccurredAt: now.Add(-time.Hour)},
+	}}
+	service.SetSource(src)
+
+	// The first poll starts the cursor without replaying history
+	if n, err := service.Poll(ctx); err != nil || n != 0 {
+		t.Fatalf("first Poll() = %d, %v, want 0, nil", n, err)
+	}
+
+	src.events = append(src.events,
+		&models.DirectoryEvent{ID: "1", Type: models.DirectoryRolesChanged, UserID: "kc-1", OccurredAt: now.Add(time.Minute)},
+		&models.DirectoryEvent{ID: "2", Type: models.DirectoryRolesChanged, UserID: "kc-2", OccurredAt: now.Add(2 * time.Minute)},
+	)
+	if n, err := service.Poll(ctx); err != nil || n != 2 {
+		t.Fatalf("Poll() = %d, %v, want 2, nil", n, err)
+	}
+
+	// Events at the cursor are listed again but not ingested twice
+	if n, err := service.Poll(ctx); err != nil || n != 0 {
+		t.Fatalf("repeated Poll() = %d, %v, want 0, nil", n, err)
+	}
+	if last := src.since[len(src.since)-1]; !last.Equal(now.Add(2 * time.Minute)) {
+		t.Errorf("Poll() listed events since %v, want %v", last, now.Add(2*time.Minute))
+	}
+
+	want := []string{models.UserRolesChanged + " kc-1 ", models.UserRolesChanged + " kc-2 "}
+	if got := publishedTypes(repo); !slices.Equal(got, want) {
+		t.Errorf("published %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,18 @@
+package profile
+
+import "errors"
+
+var (
+	// ErrProfileNotFound is returned by repositories for users who never
+	// changed their profile; the service returns a default profile instead
+	ErrProfileNotFound     = errors.New("profile not found")
+	ErrPreferencesNotFound = errors.New("preferences not found")
+	// ErrInvalidProfile is returned for profile fields out of range, like
+	// unknown time zones or locations off the globe
+	ErrInvalidProfile = errors.New("invalid profile")
+	// ErrInvalidPreferences is returned for preferences that aren't valid
+	// against the client app's schema
+	ErrInvalidPreferences = errors.New("invalid preferences")
+	// ErrUnknownClient is returned for client apps without a preference schema
+	ErrUnknownClient = errors.New("unknown client")
+)
@@ -0,0 +1,179 @@
+package profile
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/intellifinder/v4/services/auth/pkg/models"
+	"github.com/santhosh-tekuri/jsonschema/v6"
+)
+
+// MaxPreferencesSize bounds the JSON document a client app may keep per user
+const MaxPreferencesSize = 64 << 10
+
+// clientIDPattern matches the IDs of client apps, which name their schema files
+var clientIDPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,99}$`)
+
+// RegisterSchema compiles the JSON schema of the client app's preferences.
+// Schemas are registered on startup, before the service is used; they can't
+// reference other documents, and formats like email are asserted.
+func (s *Service) RegisterSchema(clientID string, schema []byte) error {
+	if !clientIDPattern.MatchString(clientID) {
+		return fmt.Errorf("invalid client ID %q", clientID)
+	}
+
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(schema))
+	if err != nil {
+		return fmt.Errorf("failed to parse schema of %s: %w", clientID, err)
+	}
+
+	compiler := jsonschema.NewCompiler()
+	compiler.UseLoader(jsonschema.SchemeURLLoader{})
+	compiler.AssertFormat()
+
+	location := "urn:preferences:" + clientID
+	if err := compiler.AddResource(location, doc); err != nil {
+		return fmt.Errorf("failed to add schema of %s: %w", clientID, err)
+	}
+	compiled, err := compiler.Compile(location)
+	if err != nil {
+		return fmt.Errorf("failed to compile schema of %s: %w", clientID, err)
+	}
+
+	s.schemas[clientID] = compiled
+	return nil
+}
+
+// LoadSchemas registers the schema of every client app in fsys, each in a
+// file named after the app's client ID, e.g. task-web.json. It returns the
+// client IDs in order.
+func (s *Service) LoadSchemas(fsys fs.FS) ([]string, error) {
+	files, err := fs.Glob(fsys, "*.json")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list schemas: %w", err)
+	}
+
+	clients := make([]string, 0, len(files))
+	for _, file := range files {
+		schema, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read schema %s: %w", file, err)
+		}
+
+		clientID := strings.TrimSuffix(path.Base(file), ".json")
+		if err := s.RegisterSchema(clientID, schema); err != nil {
+			return nil, err
+		}
+		clients = append(clients, clientID)
+	}
+
+	slices.Sort(clients)
+	return clients, nil
+}
+
+// GetPreferences returns the user's preferences for the client app. They
+// were valid against the app's schema when they were stored.
+func (s *Service) GetPreferences(ctx context.Context, userID uuid.UUID, clientID string) (*models.Preferences, error) {
+	if _, ok := s.schemas[clientID]; !ok {
+		return nil, ErrUnknownClient
+	}
+
+	if _, err := s.users.GetUser(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	p, err := s.repo.GetPreferences(ctx, userID, clientID)
+	if err != nil && !errors.Is(err, ErrPreferencesNotFound) {
+		return nil, fmt.Errorf("failed to get preferences: %w", err)
+	}
+	return p, err
+}
+
+// SetPreferences replaces the user's preferences for the client app with
+// data, which must be valid against the app's schema
+func (s *Service) SetPreferences(ctx context.Context, userID uuid.UUID, clientID string, data []byte) (*models.Preferences, error) {
+	schema, ok := s.schemas[clientID]
+	if !ok {
+		return nil, ErrUnknownClient
+	}
+
+	if len(data) > MaxPreferencesSize {
+		return nil, fmt.Errorf("%w: preferences must be at most %d bytes", ErrInvalidPreferences, MaxPreferencesSize)
+	}
+
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidPreferences, err)
+	}
+	if err := schema.Validate(doc); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidPreferences, violations(err))
+	}
+
+	if _, err := s.users.GetUser(ctx, userID); err != nil {
+		return nil, err
+	}
+
+	var compacted bytes.Buffer
+	if err := json.Compact(&compacted, data); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidPreferences, err)
+	}
+
+	p := &models.Preferences{
+		UserID:    userID,
+		ClientID:  clientID,
+		Data:      compacted.Bytes(),
+		UpdatedAt: s.now().UTC(),
+	}
+	if err := s.repo.UpsertPreferences(ctx, p); err != nil {
+		return nil, fmt.Errorf("failed to store preferences: %w", err)
+	}
+
+	return p, nil
+}
+
+func (s *Service) DeletePreferences(ctx context.Context, userID uuid.UUID, clientID string) error {
+	if _, ok := s.schemas[clientID]; !ok {
+		return ErrUnknownClient
+	}
+
+	err := s.repo.DeletePreferences(ctx, userID, clientID)
+	if err != nil && !errors.Is(err, ErrPreferencesNotFound) {
+		return fmt.Errorf("failed to delete preferences: %w", err)
+	}
+	return err
+}
+
+// violations lists where the document breaks its schema, one location per
+// violation, e.g. at /theme: value must be one of 'light', 'dark'
+func violations(err error) string {
+	var validation *jsonschema.ValidationError
+	if !errors.As(err, &validation) {
+		return err.Error()
+	}
+
+	var messages []string
+	for _, unit := range validation.BasicOutput().Errors {
+		if unit.Error == nil {
+			continue
+		}
+		location := unit.InstanceLocation
+		if location == "" {
+			location = "/"
+		}
+		messages = append(messages, fmt.Sprintf("at %s: %s", location, unit.Error))
+	}
+	if len(messages) == 0 {
+		return err.Error()
+	}
+
+	return strings.Join(messages, "; ")
+}
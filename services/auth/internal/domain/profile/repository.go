@@ -0,0 +1,28 @@
+package profile
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/intellifinder/v4/services/auth/pkg/models"
+)
+
+// Repository stores profiles and preferences by local user ID
+type Repository interface {
+	// GetProfile returns ErrProfileNotFound for users without a stored profile
+	GetProfile(ctx context.Context, userID uuid.UUID) (*models.Profile, error)
+	// UpsertProfile creates or replaces the profile of the user
+	UpsertProfile(ctx context.Context, p *models.Profile) error
+	// GetPreferences returns ErrPreferencesNotFound if the user has no
+	// preferences for the client app
+	GetPreferences(ctx context.Context, userID uuid.UUID, clientID string) (*models.Preferences, error)
+	// UpsertPreferences creates or replaces the user's preferences for the client app
+	UpsertPreferences(ctx context.Context, p *models.Preferences) error
+	// DeletePreferences returns ErrPreferencesNotFound if there are none to delete
+	DeletePreferences(ctx context.Context, userID uuid.UUID, clientID string) error
+}
+
+// Users resolves the owners of profiles. It's implemented by *user.Service.
+type Users interface {
+	GetUser(ctx context.Context, id uuid.UUID) (*models.User, error)
+}
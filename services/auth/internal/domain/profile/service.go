@@ -0,0 +1,204 @@
+// Package profile keeps what the task platform needs of users beyond their
+// Keycloak account: a display name, avatar, locale, time zone, notification
+// preferences and default location, and a preferences document per client
+// app, validated against the app's JSON schema.
+package profile
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/intellifinder/v4/services/auth/pkg/models"
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"golang.org/x/text/language"
+)
+
+// Defaults of profiles that were never changed, and of cleared fields
+const (
+	DefaultLocale   = "en"
+	DefaultTimeZone = "UTC"
+)
+
+const (
+	maxDisplayNameLength = 100
+	maxAvatarURLLength   = 2048
+	maxLocationLabel     = 200
+)
+
+// DefaultNotifications are the notification preferences of users who never changed them
+var DefaultNotifications = models.NotificationPreferences{Email: true, Push: true, Digest: models.DigestDaily}
+
+// UpdateParams changes the fields that are set. Empty strings reset the
+// display name, locale and time zone to their defaults and remove the avatar.
+type UpdateParams struct {
+	DisplayName     *string
+	AvatarURL       *string
+	Locale          *string
+	TimeZone        *string
+	Notifications   *models.NotificationPreferences
+	DefaultLocation *models.Location
+	// ClearDefaultLocation removes the default location
+	ClearDefaultLocation bool
+}
+
+type Service struct {
+	repo    Repository
+	users   Users
+	schemas map[string]*jsonschema.Schema
+	now     func() time.Time
+}
+
+func NewService(repo Repository, users Users) *Service {
+	return &Service{
+		repo:    repo,
+		users:   users,
+		schemas: make(map[string]*jsonschema.Schema),
+		now:     time.Now,
+	}
+}
+
+// GetProfile returns the profile of the local user, made of defaults if they
+// never changed it
+func (s *Service) GetProfile(ctx context.Context, userID uuid.UUID) (*models.Profile, error) {
+	u, err := s.users.GetUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := s.profile(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return withDefaults(p, u), nil
+}
+
+func (s *Service) UpdateProfile(ctx context.Context, userID uuid.UUID, params UpdateParams) (*models.Profile, error) {
+	u, err := s.users.GetUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := s.profile(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := apply(p, params); err != nil {
+		return nil, err
+	}
+
+	now := s.now().UTC()
+	p.UpdatedAt = &now
+	if err := s.repo.UpsertProfile(ctx, p); err != nil {
+		return nil, fmt.Errorf("failed to store profile: %w", err)
+	}
+
+	return withDefaults(p, u), nil
+}
+
+// profile returns the stored profile of the user, or a new one with the
+// default notification preferences
+func (s *Service) profile(ctx context.Context, userID uuid.UUID) (*models.Profile, error) {
+	p, err := s.repo.GetProfile(ctx, userID)
+	if errors.Is(err, ErrProfileNotFound) {
+		return &models.Profile{UserID: userID, Notifications: DefaultNotifications}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get profile: %w", err)
+	}
+
+	return p, nil
+}
+
+func apply(p *models.Profile, params UpdateParams) error {
+	if params.DisplayName != nil {
+		name := strings.TrimSpace(*params.DisplayName)
+		if utf8.RuneCountInString(name) > maxDisplayNameLength {
+			return fmt.Errorf("%w: display name must be at most %d characters", ErrInvalidProfile, maxDisplayNameLength)
+		}
+		p.DisplayName = name
+	}
+
+	if params.AvatarURL != nil {
+		avatar := strings.TrimSpace(*params.AvatarURL)
+		if avatar != "" {
+			u, err := url.Parse(avatar)
+			if err != nil || u.Scheme != "https" || u.Host == "" || len(avatar) > maxAvatarURLLength {
+				return fmt.Errorf("%w: avatar must be an https URL of at most %d characters", ErrInvalidProfile, maxAvatarURLLength)
+			}
+		}
+		p.AvatarURL = avatar
+	}
+
+	if params.Locale != nil {
+		locale := strings.TrimSpace(*params.Locale)
+		if locale != "" {
+			tag, err := language.Parse(locale)
+			if err != nil || tag == language.Und {
+				return fmt.Errorf("%w: locale %q is not a BCP 47 language tag", ErrInvalidProfile, locale)
+			}
+			locale = tag.String()
+		}
+		p.Locale = locale
+	}
+
+	if params.TimeZone != nil {
+		zone := strings.TrimSpace(*params.TimeZone)
+		if zone != "" {
+			// LoadLocation also accepts Local, the server's own zone
+			if _, err := time.LoadLocation(zone); err != nil || zone == "Local" {
+				return fmt.Errorf("%w: unknown time zone %q", ErrInvalidProfile, zone)
+			}
+		}
+		p.TimeZone = zone
+	}
+
+	if params.Notifications != nil {
+		switch params.Notifications.Digest {
+		case models.DigestOff, models.DigestDaily, models.DigestWeekly:
+		default:
+			return fmt.Errorf("%w: digest must be %s, %s or %s", ErrInvalidProfile, models.DigestOff, models.DigestDaily, models.DigestWeekly)
+		}
+		p.Notifications = *params.Notifications
+	}
+
+	if params.ClearDefaultLocation {
+		p.DefaultLocation = nil
+	} else if l := params.DefaultLocation; l != nil {
+		switch {
+		case l.Latitude < -90 || l.Latitude > 90 || l.Longitude < -180 || l.Longitude > 180:
+			return fmt.Errorf("%w: default location must have a latitude within ±90 and a longitude within ±180", ErrInvalidProfile)
+		case utf8.RuneCountInString(strings.TrimSpace(l.Label)) > maxLocationLabel:
+			return fmt.Errorf("%w: location label must be at most %d characters", ErrInvalidProfile, maxLocationLabel)
+		}
+		p.DefaultLocation = &models.Location{Label: strings.TrimSpace(l.Label), Latitude: l.Latitude, Longitude: l.Longitude}
+	}
+
+	return nil
+}
+
+// withDefaults fills in the defaults of the fields the user left empty. The
+// display name defaults to the user's full name, or else their username.
+func withDefaults(p *models.Profile, u *models.User) *models.Profile {
+	filled := *p
+	if filled.DisplayName == "" {
+		filled.DisplayName = strings.TrimSpace(u.FirstName + " " + u.LastName)
+	}
+	if filled.DisplayName == "" {
+		filled.DisplayName = u.Username
+	}
+	if filled.Locale == "" {
+		filled.Locale = DefaultLocale
+	}
+	if filled.TimeZone == "" {
+		filled.TimeZone = DefaultTimeZone
+	}
+	return &filled
+}
@@ -0,0 +1,240 @@
+package profile_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/intellifinder/v4/services/auth/internal/domain/profile"
+	"github.com/intellifinder/v4/services/auth/internal/domain/user"
+	"github.com/intellifinder/v4/services/auth/internal/infrastructure/memory"
+	"github.com/intellifinder/v4/services/auth/pkg/models"
+)
+
+const taskWebSchema = `{
+	"$schema": "https://json-schema.org/draft/2020-12/schema",
+	"type": "object",
+	"properties": {
+		"theme": {"enum": ["light", "dark"]},
+		"page_size": {"type": "integer", "minimum": 10, "maximum": 100},
+		"digest_email": {"type": "string", "format": "email"}
+	},
+	"additionalProperties": false
+}`
+
+var now = time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+
+func newTestService(t *testing.T) (*profile.Service, *memory.Repository) {
+	t.Helper()
+
+	repo := memory.NewRepository()
+	service := profile.NewService(repo, user.NewService(repo))
+	profile.SetNow(service, func() time.Time { return now })
+	if err := service.RegisterSchema("task-web", []byte(taskWebSchema)); err != nil {
+		t.Fatalf("RegisterSchema() error = %v", err)
+	}
+	return service, repo
+}
+
+func createUser(t *testing.T, repo *memory.Repository, u models.User) uuid.UUID {
+	t.Helper()
+	ctx := context.Background()
+	if err := repo.UpsertUser(ctx, &u); err != nil {
+		t.Fatal(err)
+	}
+	created, err := repo.GetUserByOIDCID(ctx, u.OIDCID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return created.ID
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func TestGetProfileDefaults(t *testing.T) {
+	service, repo := newTestService(t)
+	ctx := context.Background()
+
+	alice := createUser(t, repo, models.User{OIDCID: "kc-1", Username: "alice", FirstName: "Alice", LastName: "Smith"})
+	bob := createUser(t, repo, models.User{OIDCID: "kc-2", Username: "bob"})
+
+	p, err := service.GetProfile(ctx, alice)
+	if err != nil {
+		t.Fatalf("GetProfile() error = %v", err)
+	}
+	want := models.Profile{UserID: alice, DisplayName: "Alice Smith", Locale: profile.DefaultLocale, TimeZone: profile.DefaultTimeZone, Notifications: profile.DefaultNotifications}
+	if *p != want {
+		t.Errorf("GetProfile() = %+v, want %+v", p, want)
+	}
+
+	if p, err := service.GetProfile(ctx, bob); err != nil || p.DisplayName != "bob" {
+		t.Errorf("GetProfile() without names = %+v, %v, want the username as display name", p, err)
+	}
+
+	if _, err := service.GetProfile(ctx, uuid.New()); !errors.Is(err, user.ErrUserNotFound) {
+		t.Errorf("GetProfile() unknown user error = %v, want ErrUserNotFound", err)
+	}
+}
+
+func TestUpdateProfile(t *testing.T) {
+	service, repo := newTestService(t)
+	ctx := context.Background()
+
+	alice := createUser(t, repo, models.User{OIDCID: "kc-1", Username: "alice"})
+
+	p, err := service.UpdateProfile(ctx, alice, profile.UpdateParams{
+		DisplayName:     ptr("  Ally "),
+		AvatarURL:       ptr("https://cdn.example.com/avatars/alice.png"),
+		Locale:          ptr("en-gb"),
+		TimeZone:        ptr("Europe/Berlin"),
+		DefaultLocation: &models.Location{Label: " Office ", Latitude: 52.52, Longitude: 13.405},
+	})
+	if err != nil {
+		t.Fatalf("UpdateProfile() error = %v", err)
+	}
+	if p.DisplayName != "Ally" || p.Locale != "en-GB" || p.TimeZone != "Europe/Berlin" || p.DefaultLocation == nil || p.DefaultLocation.Label != "Office" {
+		t.Errorf("UpdateProfile() = %+v, want the trimmed and canonical values", p)
+	}
+	if p.Notifications != profile.DefaultNotifications || p.UpdatedAt == nil || !p.UpdatedAt.Equal(now) {
+		t.Errorf("UpdateProfile() = %+v, want default notifications updated now", p)
+	}
+
+	// Fields that aren't set are kept, and empty ones reset
+	p, err = service.UpdateProfile(ctx, alice, profile.UpdateParams{
+		DisplayName:          ptr(""),
+		TimeZone:             ptr(""),
+		Notifications:        &models.NotificationPreferences{SMS: true, Digest: models.DigestOff},
+		ClearDefaultLocation: true,
+	})
+	if err != nil {
+		t.Fatalf("UpdateProfile() reset error = %v", err)
+	}
+	if p.DisplayName != "alice" || p.TimeZone != profile.DefaultTimeZone || p.Locale != "en-GB" || p.AvatarURL == "" || p.DefaultLocation != nil || !p.Notifications.SMS || p.Notifications.Email {
+		t.Errorf("UpdateProfile() reset = %+v", p)
+	}
+
+	stored, err := service.GetProfile(ctx, alice)
+	if err != nil || stored.Locale != "en-GB" || !stored.Notifications.SMS {
+		t.Errorf("GetProfile() = %+v, %v, want the update", stored, err)
+	}
+
+	tests := []struct {
+		name   string
+		params profile.UpdateParams
+	}{
+		{"long display name", profile.UpdateParams{DisplayName: ptr(strings.Repeat("x", 101))}},
+		{"http avatar", profile.UpdateParams{AvatarURL: ptr("http://example.com/a.png")}},
+		{"relative avatar", profile.UpdateParams{AvatarURL: ptr("/a.png")}},
+		{"locale", profile.UpdateParams{Locale: ptr("not a locale")}},
+		{"time zone", profile.UpdateParams{TimeZone: ptr("Mars/Olympus_Mons")}},
+		{"local time zone", profile.UpdateParams{TimeZone: ptr("Local")}},
+		{"digest", profile.UpdateParams{Notifications: &models.NotificationPreferences{Digest: "hourly"}}},
+		{"latitude", profile.UpdateParams{DefaultLocation: &models.Location{Latitude: 91}}},
+		{"longitude", profile.UpdateParams{DefaultLocation: &models.Location{Longitude: -181}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := service.UpdateProfile(ctx, alice, tt.params); !errors.Is(err, profile.ErrInvalidProfile) {
+				t.Errorf("UpdateProfile() error = %v, want ErrInvalidProfile", err)
+			}
+		})
+	}
+}
+
+func TestPreferences(t *testing.T) {
+	service, repo := newTestService(t)
+	ctx := context.Background()
+
+	alice := createUser(t, repo, models.User{OIDCID: "kc-1", Username: "alice"})
+
+	if _, err := service.GetPreferences(ctx, alice, "task-web"); !errors.Is(err, profile.ErrPreferencesNotFound) {
+		t.Errorf("GetPreferences() before set error = %v, want ErrPreferencesNotFound", err)
+	}
+	if _, err := service.GetPreferences(ctx, alice, "unknown"); !errors.Is(err, profile.ErrUnknownClient) {
+		t.Errorf("GetPreferences() unknown client error = %v, want ErrUnknownClient", err)
+	}
+
+	p, err := service.SetPreferences(ctx, alice, "task-web", []byte(`{ "theme": "dark", "page_size": 50 }`))
+	if err != nil {
+		t.Fatalf("SetPreferences() error = %v", err)
+	}
+	if string(p.Data) != `{"theme":"dark","page_size":50}` || !p.UpdatedAt.Equal(now) {
+		t.Errorf("SetPreferences() = %s at %v, want the compacted document", p.Data, p.UpdatedAt)
+	}
+
+	got, err := service.GetPreferences(ctx, alice, "task-web")
+	if err != nil || string(got.Data) != string(p.Data) {
+		t.Errorf("GetPreferences() = %+v, %v, want the stored document", got, err)
+	}
+
+	invalid := []struct {
+		name string
+		data string
+		want string
+	}{
+		{"enum", `{"theme": "blue"}`, "/theme"},
+		{"maximum", `{"page_size": 500}`, "/page_size"},
+		{"format", `{"digest_email": "nope"}`, "/digest_email"},
+		{"additional property", `{"colour": "red"}`, "colour"},
+		{"type", `[]`, "at /:"},
+		{"syntax", `{"theme":`, ""},
+		{"size", `{"theme": "` + strings.Repeat("x", profile.MaxPreferencesSize) + `"}`, "bytes"},
+	}
+	for _, tt := range invalid {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := service.SetPreferences(ctx, alice, "task-web", []byte(tt.data))
+			if !errors.Is(err, profile.ErrInvalidPreferences) || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("SetPreferences() error = %v, want ErrInvalidPreferences mentioning %q", err, tt.want)
+			}
+		})
+	}
+
+	if _, err := service.SetPreferences(ctx, uuid.New(), "task-web", []byte(`{}`)); !errors.Is(err, user.ErrUserNotFound) {
+		t.Errorf("SetPreferences() unknown user error = %v, want ErrUserNotFound", err)
+	}
+	if _, err := service.SetPreferences(ctx, alice, "unknown", []byte(`{}`)); !errors.Is(err, profile.ErrUnknownClient) {
+		t.Errorf("SetPreferences() unknown client error = %v, want ErrUnknownClient", err)
+	}
+
+	if err := service.DeletePreferences(ctx, alice, "task-web"); err != nil {
+		t.Fatalf("DeletePreferences() error = %v", err)
+	}
+	if err := service.DeletePreferences(ctx, alice, "task-web"); !errors.Is(err, profile.ErrPreferencesNotFound) {
+		t.Errorf("DeletePreferences() again error = %v, want ErrPreferencesNotFound", err)
+	}
+}
+
+func TestLoadSchemas(t *testing.T) {
+	service := profile.NewService(memory.NewRepository(), nil)
+
+	clients, err := service.LoadSchemas(fstest.MapFS{
+		"task-web.json":    {Data: []byte(taskWebSchema)},
+		"task-mobile.json": {Data: []byte(`{"type": "object"}`)},
+		"README.md":        {Data: []byte("not a schema")},
+	})
+	if err != nil {
+		t.Fatalf("LoadSchemas() error = %v", err)
+	}
+	if strings.Join(clients, ",") != "task-mobile,task-web" {
+		t.Errorf("LoadSchemas() = %v, want task-mobile and task-web", clients)
+	}
+
+	for name, schema := range map[string]string{
+		"invalid.json":   `{"type": 42}`,
+		"malformed.json": `{"type":`,
+		"remote.json":    `{"$ref": "https://example.com/schema.json"}`,
+		"_hidden.json":   `{}`,
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := service.LoadSchemas(fstest.MapFS{name: {Data: []byte(schema)}}); err == nil {
+				t.Error("LoadSchemas() error = nil, want an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,98 @@
+package ratelimit
+
+import "time"
+
+// Endpoints with rate limits
+const (
+	EndpointLogin          = "login"
+	EndpointRefresh        = "refresh"
+	EndpointForgotPassword = "forgot_password"
+	EndpointServiceToken   = "service_token"
+	EndpointValidate       = "validate"
+)
+
+// Dimensions attempts are counted by. DimensionLockout stands for attempts
+// refused because of a lockout.
+const (
+	DimensionIP      = "ip"
+	DimensionAccount = "account"
+	DimensionAPIKey  = "api_key"
+	DimensionLockout = "lockout"
+)
+
+// Policy limits the attempts at an endpoint. Rules and lockouts left zero
+// don't apply.
+type Policy struct {
+	PerIP Rule
+	// PerAccount counts by the username, email or client ID the client sent
+	PerAccount Rule
+	// PerAPIKey counts by the API key, once it has been verified
+	PerAPIKey Rule
+	// Lockout locks out whoever keeps failing: the account of a login or
+	// service token request, or the address sending invalid API keys
+	Lockout Lockout
+}
+
+// Lockout locks a subject out after Threshold failures within Window. The
+// first lockout lasts Duration and each one after it within strikeMemory
+// twice as long as the one before, up to MaxDuration.
+type Lockout struct {
+	Threshold   int
+	Window      time.Duration
+	Duration    time.Duration
+	MaxDuration time.Duration
+	// Notify tells the owner of the locked account
+	Notify bool
+}
+
+// duration is how long the given lockout, counting from one, lasts
+func (l Lockout) duration(strikes int) time.Duration {
+	d := l.Duration
+	for i := 1; i < strikes && d < l.MaxDuration; i++ {
+		d *= 2
+	}
+	return min(d, max(l.MaxDuration, l.Duration))
+}
+
+// rule returns the rule of the dimension
+func (p Policy) rule(dimension string) Rule {
+	switch dimension {
+	case DimensionIP:
+		return p.PerIP
+	case DimensionAccount:
+		return p.PerAccount
+	case DimensionAPIKey:
+		return p.PerAPIKey
+	default:
+		return Rule{}
+	}
+}
+
+// DefaultPolicies are the policies of a new service
+func DefaultPolicies() map[string]Policy {
+	return map[string]Policy{
+		EndpointLogin: {
+			PerIP:      Rule{Limit: 30, Window: time.Minute},
+			PerAccount: Rule{Limit: 10, Window: time.Minute},
+			Lockout:    Lockout{Threshold: 5, Window: 15 * time.Minute, Duration: time.Minute, MaxDuration: time.Hour, Notify: true},
+		},
+		EndpointRefresh: {
+			PerIP: Rule{Limit: 60, Window: time.Minute},
+		},
+		EndpointForgotPassword: {
+			PerIP: Rule{Limit: 10, Window: time.Hour},
+			// Requests above it are accepted and ignored, so it doesn't tell
+			// whether the account exists
+			PerAccount: Rule{Limit: 3, Window: time.Hour},
+		},
+		EndpointServiceToken: {
+			// Services only ask for tokens when their cached one runs out
+			PerAccount: Rule{Limit: 30, Window: time.Minute},
+			Lockout:    Lockout{Threshold: 10, Window: 15 * time.Minute, Duration: time.Minute, MaxDuration: time.Hour},
+		},
+		EndpointValidate: {
+			PerAPIKey: Rule{Limit: 6000, Window: time.Minute},
+			Lockout:   Lockout{Threshold: 20, Window: 5 * time.Minute, Duration: 5 * time.Minute, MaxDuration: time.Hour},
+		},
+	}
+}
@@ -0,0 +1,37 @@
+package ratelimit
+
+import (
+	"context"
+	"time"
+)
+
+type Repository interface {
+	// Hit counts an attempt against the key in the fixed window of the given
+	// length that now falls in. It returns the attempts in the previous and
+	// current windows and how far into the current window now is.
+	Hit(ctx context.Context, key string, window time.Duration) (previous int, current int, elapsed time.Duration, err error)
+	// ResetHits forgets the attempts counted against the key
+	ResetHits(ctx context.Context, key string, window time.Duration) error
+
+	// AddStrike counts a lockout of the key and returns the lockouts it had
+	// within ttl, including this one
+	AddStrike(ctx context.Context, key string, ttl time.Duration) (int, error)
+	// Lock locks the key out for the duration
+	Lock(ctx context.Context, key string, duration time.Duration) error
+	// LockedFor returns how long the key stays locked out, or zero
+	LockedFor(ctx context.Context, key string) (time.Duration, error)
+}
+
+// Notifier tells users that their account was locked out, e.g. because
+// someone is guessing their password
+type Notifier interface {
+	NotifyLockout(ctx context.Context, account string, until time.Time) error
+}
+
+// Recorder counts blocked attempts and lockouts, e.g. for Prometheus
+type Recorder interface {
+	// RecordBlocked counts an attempt denied by the limit of the dimension,
+	// or by a lockout
+	RecordBlocked(endpoint string, dimension string)
+	RecordLockout(endpoint string)
+}
@@ -0,0 +1,212 @@
+// Package ratelimit bounds how often clients may attempt an action, e.g. to
+// stop an endpoint that sends emails from being used to flood inboxes, and
+// locks out accounts whose password is being guessed
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"time"
+)
+
+// strikeMemory is how long lockouts are remembered to lengthen the next one
+const strikeMemory = 24 * time.Hour
+
+// Rule allows Limit attempts per Window
+type Rule struct {
+	Limit  int
+	Window time.Duration
+}
+
+// Decision tells whether an attempt may go ahead, and otherwise when to try again
+type Decision struct {
+	Allowed    bool
+	RetryAfter time.Duration
+}
+
+type Service struct {
+	repo          Repository
+	policies      map[string]Policy
+	notifier      Notifier
+	onNotifyError func(error)
+	recorder      Recorder
+	now           func() time.Time
+}
+
+func NewService(repo Repository) *Service {
+	return &Service{
+		repo:     repo,
+		policies: DefaultPolicies(),
+		now:      time.Now,
+	}
+}
+
+// SetPolicy replaces the policy of the endpoint
+func (s *Service) SetPolicy(endpoint string, policy Policy) {
+	s.policies[endpoint] = policy
+}
+
+// Policy returns the policy of the endpoint
+func (s *Service) Policy(endpoint string) Policy {
+	return s.policies[endpoint]
+}
+
+// SetNotifier tells users about lockouts of their account. Failed
+// notifications are passed to onError, since the lockout stands either way.
+func (s *Service) SetNotifier(notifier Notifier, onError func(error)) {
+	s.notifier = notifier
+	s.onNotifyError = onError
+}
+
+// SetRecorder counts blocked attempts and lockouts
+func (s *Service) SetRecorder(recorder Recorder) {
+	s.recorder = recorder
+}
+
+// Allow counts an attempt against the key, which names both the action and
+// who attempts it. Attempts are counted in a sliding window, weighing those
+// of the previous window by how much of it still overlaps. Denied attempts
+// count too, so clients that keep trying stay limited.
+func (s *Service) Allow(ctx context.Context, key string, rule Rule) (*Decision, error) {
+	previous, current, elapsed, err := s.repo.Hit(ctx, key, rule.Window)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count attempt: %w", err)
+	}
+
+	if estimate(previous, current, elapsed, rule.Window) > float64(rule.Limit) {
+		return &Decision{RetryAfter: retryAfter(rule, previous, current, elapsed)}, nil
+	}
+
+	return &Decision{Allowed: true}, nil
+}
+
+// Check counts an attempt at the endpoint against the limit of the
+// dimension, e.g. DimensionIP and the client's address
+func (s *Service) Check(ctx context.Context, endpoint string, dimension string, value string) (*Decision, error) {
+	rule := s.policies[endpoint].rule(dimension)
+	if rule.Limit <= 0 {
+		return &Decision{Allowed: true}, nil
+	}
+
+	decision, err := s.Allow(ctx, endpoint+":"+dimension+":"+value, rule)
+	if err != nil {
+		return nil, err
+	}
+	if !decision.Allowed {
+		s.recordBlocked(endpoint, dimension)
+	}
+	return decision, nil
+}
+
+// CheckLockout tells whether the subject is locked out of the endpoint,
+// without counting an attempt
+func (s *Service) CheckLockout(ctx context.Context, endpoint string, subject string) (*Decision, error) {
+	if s.policies[endpoint].Lockout.Threshold <= 0 {
+		return &Decision{Allowed: true}, nil
+	}
+
+	remaining, err := s.repo.LockedFor(ctx, lockoutKey(endpoint, subject))
+	if err != nil {
+		return nil, fmt.Errorf("failed to check lockout: %w", err)
+	}
+	if remaining > 0 {
+		s.recordBlocked(endpoint, DimensionLockout)
+		return &Decision{RetryAfter: remaining}, nil
+	}
+
+	return &Decision{Allowed: true}, nil
+}
+
+// RecordFailure counts a failed attempt of the subject, e.g. a wrong
+// password, and locks the subject out once it failed too often. The
+// decision is denied if this failure locked it out.
+func (s *Service) RecordFailure(ctx context.Context, endpoint string, subject string) (*Decision, error) {
+	lockout := s.policies[endpoint].Lockout
+	if lockout.Threshold <= 0 {
+		return &Decision{Allowed: true}, nil
+	}
+
+	failures := failuresKey(endpoint, subject)
+	previous, current, elapsed, err := s.repo.Hit(ctx, failures, lockout.Window)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count failure: %w", err)
+	}
+	if estimate(previous, current, elapsed, lockout.Window) < float64(lockout.Threshold) {
+		return &Decision{Allowed: true}, nil
+	}
+
+	strikes, err := s.repo.AddStrike(ctx, endpoint+":strikes:"+subject, strikeMemory)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count lockout: %w", err)
+	}
+	duration := lockout.duration(strikes)
+	if err := s.repo.Lock(ctx, lockoutKey(endpoint, subject), duration); err != nil {
+		return nil, fmt.Errorf("failed to lock out: %w", err)
+	}
+	// The failures led to this lockout; the next one needs as many again
+	if err := s.repo.ResetHits(ctx, failures, lockout.Window); err != nil {
+		return nil, fmt.Errorf("failed to reset failures: %w", err)
+	}
+
+	if s.recorder != nil {
+		s.recorder.RecordLockout(endpoint)
+	}
+	if lockout.Notify && s.notifier != nil {
+		if err := s.notifier.NotifyLockout(ctx, subject, s.now().Add(duration)); err != nil && s.onNotifyError != nil {
+			s.onNotifyError(fmt.Errorf("failed to notify lockout of %s: %w", subject, err))
+		}
+	}
+
+	return &Decision{RetryAfter: duration}, nil
+}
+
+// RecordSuccess forgets the failures of the subject, e.g. after it logged in
+func (s *Service) RecordSuccess(ctx context.Context, endpoint string, subject string) error {
+	lockout := s.policies[endpoint].Lockout
+	if lockout.Threshold <= 0 {
+		return nil
+	}
+
+	if err := s.repo.ResetHits(ctx, failuresKey(endpoint, subject), lockout.Window); err != nil {
+		return fmt.Errorf("failed to reset failures: %w", err)
+	}
+	return nil
+}
+
+func (s *Service) recordBlocked(endpoint string, dimension string) {
+	if s.recorder != nil {
+		s.recorder.RecordBlocked(endpoint, dimension)
+	}
+}
+
+func failuresKey(endpoint string, subject string) string {
+	return endpoint + ":failures:" + subject
+}
+
+func lockoutKey(endpoint string, subject string) string {
+	return endpoint + ":lockout:" + subject
+}
+
+// estimate counts the attempts in the window ending now
+func estimate(previous int, current int, elapsed time.Duration, window time.Duration) float64 {
+	overlap := float64(window-elapsed) / float64(window)
+	return float64(previous)*overlap + float64(current)
+}
+
+// retryAfter is how long until the next attempt would be allowed, if the
+// client stopped trying until then
+func retryAfter(rule Rule, previous int, current int, elapsed time.Duration) time.Duration {
+	window := float64(rule.Window)
+	limit := float64(rule.Limit)
+
+	// Within the current window, once enough of the previous one slid out
+	if current < rule.Limit && previous > 0 {
+		wait := window*(1-(limit-float64(current)-1)/float64(previous)) - float64(elapsed)
+		return time.Duration(math.Ceil(wait))
+	}
+
+	// Otherwise once enough of the current window slid out after it ended
+	wait := 2*window - float64(elapsed) - window*(limit-1)/float64(current)
+	return time.Duration(math.Ceil(wait))
+}
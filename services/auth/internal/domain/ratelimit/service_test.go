@@ -0,0 +1,163 @@
+package ratelimit_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/intellifinder/v4/services/auth/internal/domain/ratelimit"
+	"github.com/intellifinder/v4/services/auth/internal/infrastructure/memory"
+)
+
+func TestAllow(t *testing.T) {
+	service := ratelimit.NewService(memory.NewRepository())
+	rule := ratelimit.Rule{Limit: 2, Window: time.Minute}
+	ctx := context.Background()
+
+	for i, want := range []bool{true, true, false, false} {
+		decision, err := service.Allow(ctx, "ip:192.0.2.1", rule)
+		if err != nil {
+			t.Fatalf("Allow() error = %v", err)
+		}
+		if decision.Allowed != want {
+			t.Errorf("Allow() attempt %d allowed = %v, want %v", i+1, decision.Allowed, want)
+		}
+		// Denied attempts count too, so they may push the retry past the window
+		if !decision.Allowed && (decision.RetryAfter <= 0 || decision.RetryAfter > 2*time.Minute) {
+			t.Errorf("Allow() attempt %d retry after = %v, want within two windows", i+1, decision.RetryAfter)
+		}
+	}
+
+	if decision, _ := service.Allow(ctx, "ip:192.0.2.2", rule); !decision.Allowed {
+		t.Error("Allow() for another key was denied, want keys limited separately")
+	}
+
+	if decision, _ := service.Allow(ctx, "ip:192.0.2.3", ratelimit.Rule{Limit: 1, Window: time.Millisecond}); !decision.Allowed {
+		t.Fatal("Allow() first attempt was denied")
+	}
+	time.Sleep(2 * time.Millisecond)
+	if decision, _ := service.Allow(ctx, "ip:192.0.2.3", ratelimit.Rule{Limit: 1, Window: time.Millisecond}); !decision.Allowed {
+		t.Error("Allow() after the window was denied, want a new window")
+	}
+}
+
+// recorder keeps what the service recorded and notified
+type recorder struct {
+	blocked  []string
+	lockouts []string
+	notified []string
+}
+
+func (r *recorder) RecordBlocked(endpoint string, dimension string) {
+	r.blocked = append(r.blocked, endpoint+" "+dimension)
+}
+
+func (r *recorder) RecordLockout(endpoint string) {
+	r.lockouts = append(r.lockouts, endpoint)
+}
+
+func (r *recorder) NotifyLockout(_ context.Context, account string, until time.Time) error {
+	if time.Until(until) <= 0 {
+		return errors.New("lockout already over")
+	}
+	r.notified = append(r.notified, account)
+	return nil
+}
+
+func TestCheck(t *testing.T) {
+	service := ratelimit.NewService(memory.NewRepository())
+	rec := &recorder{}
+	service.SetRecorder(rec)
+	service.SetPolicy("test", ratelimit.Policy{
+		PerIP:      ratelimit.Rule{Limit: 1, Window: time.Minute},
+		PerAccount: ratelimit.Rule{Limit: 2, Window: time.Minute},
+	})
+	ctx := context.Background()
+
+	check := func(dimension string, value string) bool {
+		t.Helper()
+		decision, err := service.Check(ctx, "test", dimension, value)
+		if err != nil {
+			t.Fatalf("Check() error = %v", err)
+		}
+		return decision.Allowed
+	}
+
+	if !check(ratelimit.DimensionIP, "192.0.2.1") || check(ratelimit.DimensionIP, "192.0.2.1") {
+		t.Error("Check() per IP didn't allow exactly one attempt")
+	}
+	if !check(ratelimit.DimensionAccount, "alice") || !check(ratelimit.DimensionAccount, "alice") || check(ratelimit.DimensionAccount, "alice") {
+		t.Error("Check() per account didn't allow exactly two attempts")
+	}
+	for range 3 {
+		if !check(ratelimit.DimensionAPIKey, "key") {
+			t.Fatal("Check() of a dimension without a rule was denied")
+		}
+	}
+
+	if want := []string{"test ip", "test account"}; !slices.Equal(rec.blocked, want) {
+		t.Errorf("recorded blocked %v, want %v", rec.blocked, want)
+	}
+}
+
+func TestLockout(t *testing.T) {
+	service := ratelimit.NewService(memory.NewRepository())
+	rec := &recorder{}
+	service.SetRecorder(rec)
+	service.SetNotifier(rec, func(err error) { t.Errorf("failed to notify: %v", err) })
+	service.SetPolicy("test", ratelimit.Policy{
+		Lockout: ratelimit.Lockout{Threshold: 2, Window: time.Minute, Duration: time.Minute, MaxDuration: 3 * time.Minute, Notify: true},
+	})
+	ctx := context.Background()
+
+	fail := func() *ratelimit.Decision {
+		t.Helper()
+		decision, err := service.RecordFailure(ctx, "test", "alice")
+		if err != nil {
+			t.Fatalf("RecordFailure() error = %v", err)
+		}
+		return decision
+	}
+	locked := func() time.Duration {
+		t.Helper()
+		decision, err := service.CheckLockout(ctx, "test", "alice")
+		if err != nil {
+			t.Fatalf("CheckLockout() error = %v", err)
+		}
+		return decision.RetryAfter
+	}
+
+	// A success forgets the failures before it
+	if !fail().Allowed {
+		t.Fatal("RecordFailure() locked out after one failure")
+	}
+	if err := service.RecordSuccess(ctx, "test", "alice"); err != nil {
+		t.Fatalf("RecordSuccess() error = %v", err)
+	}
+
+	// Each lockout lasts twice as long as the one before, up to the maximum
+	for _, want := range []time.Duration{time.Minute, 2 * time.Minute, 3 * time.Minute, 3 * time.Minute} {
+		if !fail().Allowed {
+			t.Fatal("RecordFailure() locked out after one failure")
+		}
+		decision := fail()
+		if decision.Allowed || decision.RetryAfter != want {
+			t.Errorf("RecordFailure() = %+v, want a lockout of %v", decision, want)
+		}
+		if remaining := locked(); remaining <= 0 || remaining > want {
+			t.Errorf("CheckLockout() retry after = %v, want within %v", remaining, want)
+		}
+	}
+
+	if decision, _ := service.CheckLockout(ctx, "test", "bob"); !decision.Allowed {
+		t.Error("CheckLockout() of another account was denied")
+	}
+	if want := []string{"alice", "alice", "alice", "alice"}; !slices.Equal(rec.notified, want) {
+		t.Errorf("notified %v, want %v", rec.notified, want)
+	}
+	if len(rec.lockouts) != 4 || len(rec.blocked) != 4 {
+		t.Errorf("recorded %d lockouts and %d blocked attempts, want 4 each", len(rec.lockouts), len(rec.blocked))
+	}
+}
@@ -0,0 +1,6 @@
+package revocation
+
+import "errors"
+
+// ErrInvalidRevocation is returned for revocations missing the ID they apply to
+var ErrInvalidRevocation = errors.New("invalid revocation")
@@ -0,0 +1,8 @@
+package revocation
+
+import "time"
+
+// SetNow replaces the service's clock in tests
+func SetNow(s *Service, now func() time.Time) {
+	s.now = now
+}
@@ -0,0 +1,26 @@
+package revocation
+
+import (
+	"context"
+	"time"
+)
+
+// Repository keeps revocations until expiresAt, when every token they cover
+// has expired anyway
+type Repository interface {
+	RevokeToken(ctx context.Context, tokenID string, expiresAt time.Time) error
+	RevokeSession(ctx context.Context, sessionID string, expiresAt time.Time) error
+	// RevokeUserTokens revokes the user's tokens issued before issuedBefore.
+	// An earlier time never replaces a later one.
+	RevokeUserTokens(ctx context.Context, userID string, issuedBefore time.Time, expiresAt time.Time) error
+	// GetRevocations returns the revocations in effect for a token
+	GetRevocations(ctx context.Context, tokenID string, sessionID string, userID string) (*Revocations, error)
+}
+
+// Revocations are those in effect for one token
+type Revocations struct {
+	TokenRevoked   bool
+	SessionRevoked bool
+	// UserTokensIssuedBefore is zero unless the user's tokens were revoked
+	UserTokensIssuedBefore time.Time
+}
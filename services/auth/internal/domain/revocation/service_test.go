@@ -0,0 +1,123 @@
+package revocation_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/intellifinder/v4/services/auth/internal/domain/revocation"
+	"github.com/intellifinder/v4/services/auth/internal/infrastructure/memory"
+	"github.com/intellifinder/v4/services/auth/pkg/models"
+)
+
+func accessToken(tokenID string, sessionID string, issuedAt time.Time) *models.Identity {
+	return &models.Identity{
+		Subject:   "user-1",
+		TokenID:   tokenID,
+		SessionID: sessionID,
+		Method:    models.AuthMethodJWT,
+		IssuedAt:  issuedAt.Truncate(time.Second),
+		ExpiresAt: issuedAt.Add(5 * time.Minute),
+	}
+}
+
+func TestRevocation(t *testing.T) {
+	service := revocation.NewService(memory.NewRepository(), time.Hour)
+	ctx := context.Background()
+	now := time.Now()
+
+	first := accessToken("jti-1", "sid-1", now)
+	second := accessToken("jti-2", "sid-1", now)
+	other := accessToken("jti-3", "sid-2", now)
+
+	if err := service.RevokeToken(ctx, first); err != nil {
+		t.Fatalf("RevokeToken() error = %v", err)
+	}
+	for _, tt := range []struct {
+		identity *models.Identity
+		want     bool
+	}{{first, true}, {second, false}, {other, false}} {
+		if revoked, err := service.IsRevoked(ctx, tt.identity); err != nil || revoked != tt.want {
+			t.Errorf("IsRevoked(%s) = %v, %v, want %v after revoking jti-1", tt.identity.TokenID, revoked, err, tt.want)
+		}
+	}
+
+	if err := service.RevokeSession(ctx, "sid-1"); err != nil {
+		t.Fatalf("RevokeSession() error = %v", err)
+	}
+	if revoked, _ := service.IsRevoked(ctx, second); !revoked {
+		t.Error("IsRevoked() = false for a token of a revoked session, want true")
+	}
+	if revoked, _ := service.IsRevoked(ctx, other); revoked {
+		t.Error("IsRevoked() = true for a token of another session, want false")
+	}
+
+	if err := service.RevokeUserTokens(ctx, "user-1", now); err != nil {
+		t.Fatalf("RevokeUserTokens() error = %v", err)
+	}
+	if revoked, _ := service.IsRevoked(ctx, other); !revoked {
+		t.Error("IsRevoked() = false for a token issued before the user's tokens were revoked, want true")
+	}
+	if revoked, _ := service.IsRevoked(ctx, accessToken("jti-4", "sid-3", now.Add(time.Second))); revoked {
+		t.Error("IsRevoked() = true for a token issued afterwards, want false")
+	}
+
+	// An earlier revocation doesn't shorten a later one
+	if err := service.RevokeUserTokens(ctx, "user-1", now.Add(-time.Minute)); err != nil {
+		t.Fatalf("RevokeUserTokens() error = %v", err)
+	}
+	if revoked, _ := service.IsRevoked(ctx, other); !revoked {
+		t.Error("IsRevoked() = false after an earlier revocation of the user, want the later one kept")
+	}
+
+	// API key identities are checked against their owner's revocation by the
+	// time the key was verified
+	apiKey := &models.Identity{Subject: "user-1", Method: models.AuthMethodAPIKey, IssuedAt: now.Add(-time.Second)}
+	if revoked, _ := service.IsRevoked(ctx, apiKey); !revoked {
+		t.Error("IsRevoked() = false for an API key verified before the user's tokens were revoked, want true")
+	}
+	apiKey.IssuedAt = now.Add(time.Second)
+	if revoked, _ := service.IsRevoked(ctx, apiKey); revoked {
+		t.Error("IsRevoked() = true for an API key verified afterwards, want false")
+	}
+}
+
+func TestRevocationValidation(t *testing.T) {
+	service := revocation.NewService(memory.NewRepository(), time.Hour)
+	ctx := context.Background()
+
+	if err := service.RevokeToken(ctx, accessToken("", "sid-1", time.Now())); !errors.Is(err, revocation.ErrInvalidRevocation) {
+		t.Errorf("RevokeToken() without a token ID error = %v, want ErrInvalidRevocation", err)
+	}
+	if err := service.RevokeSession(ctx, ""); !errors.Is(err, revocation.ErrInvalidRevocation) {
+		t.Errorf("RevokeSession() without a session ID error = %v, want ErrInvalidRevocation", err)
+	}
+	if err := service.RevokeUserTokens(ctx, "", time.Now()); !errors.Is(err, revocation.ErrInvalidRevocation) {
+		t.Errorf("RevokeUserTokens() without a user ID error = %v, want ErrInvalidRevocation", err)
+	}
+}
+
+func TestRevocationsExpireWithTheirTokens(t *testing.T) {
+	repo := memory.NewRepository()
+	service := revocation.NewService(repo, time.Hour)
+	now := time.Now()
+	revocation.SetNow(service, func() time.Time { return now })
+	ctx := context.Background()
+
+	expired := accessToken("jti-1", "sid-1", now.Add(-time.Hour))
+	if err := service.RevokeToken(ctx, expired); err != nil {
+		t.Fatalf("RevokeToken() error = %v", err)
+	}
+	if err := service.RevokeUserTokens(ctx, "user-1", now.Add(-2*time.Hour)); err != nil {
+		t.Fatalf("RevokeUserTokens() error = %v", err)
+	}
+
+	revocations, err := repo.GetRevocations(ctx, "jti-1", "sid-1", "user-1")
+	if err != nil {
+		t.Fatalf("GetRevocations() error = %v", err)
+	}
+	if revocations.TokenRevoked || !revocations.UserTokensIssuedBefore.IsZero() {
+		t.Errorf("GetRevocations() = %+v, want nothing stored for tokens that expired already", revocations)
+	}
+}
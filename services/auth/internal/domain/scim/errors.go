@@ -0,0 +1,25 @@
+package scim
+
+import "errors"
+
+// Errors correspond to the scimType values of RFC 7644 section 3.12
+var (
+	// ErrResourceNotFound is returned for users and groups the tenant didn't provision
+	ErrResourceNotFound = errors.New("resource not found")
+	// ErrUniqueness is returned when a username, email or group name is taken
+	ErrUniqueness = errors.New("resource already exists")
+	// ErrInvalidFilter is returned for filters that can't be parsed
+	ErrInvalidFilter = errors.New("invalid filter")
+	// ErrInvalidPath is returned for PATCH paths that can't be parsed
+	ErrInvalidPath = errors.New("invalid path")
+	// ErrNoTarget is returned when a PATCH path matches no value to change
+	ErrNoTarget = errors.New("no target")
+	// ErrInvalidSyntax is returned for malformed requests, e.g. unknown PATCH operations
+	ErrInvalidSyntax = errors.New("invalid syntax")
+	// ErrInvalidValue is returned for missing or invalid attribute values
+	ErrInvalidValue = errors.New("invalid value")
+	// ErrMutability is returned for changes to attributes that can't be changed, like userName
+	ErrMutability = errors.New("attribute is immutable")
+	// ErrPreconditionFailed is returned when If-Match names another version of the resource
+	ErrPreconditionFailed = errors.New("resource version doesn't match")
+)
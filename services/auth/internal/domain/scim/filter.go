@@ -0,0 +1,527 @@
+package scim
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/intellifinder/v4/services/auth/pkg/dto"
+)
+
+// Filter is a parsed filter expression (RFC 7644 section 3.4.2.2), evaluated
+// against resources decoded into maps as their JSON representation
+type Filter interface {
+	Matches(resource map[string]any) bool
+}
+
+// ParseFilter parses a filter such as
+//
+//	userName eq "alice" and (emails[type eq "work"] pr or not (active eq false))
+//
+// Attribute names are case-insensitive and may be qualified with the core
+// schema URNs. Attributes of other schemas never have a value.
+func ParseFilter(s string) (Filter, error) {
+	p, err := newParser(s)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidFilter, err)
+	}
+
+	f, err := p.parseOr()
+	if err == nil && p.peek().kind != tokenEOF {
+		err = fmt.Errorf("unexpected %q", p.peek().text)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidFilter, err)
+	}
+
+	return f, nil
+}
+
+// patchPath is a PATCH target such as members, name.givenName or
+// emails[type eq "work"].value. Filter and sub are only set when present.
+type patchPath struct {
+	attrPath
+	filter Filter
+}
+
+// parsePath parses the path of a PATCH operation
+func parsePath(s string) (patchPath, error) {
+	p, err := newParser(s)
+	if err != nil {
+		return patchPath{}, fmt.Errorf("%w: %w", ErrInvalidPath, err)
+	}
+
+	t := p.next()
+	if t.kind != tokenWord {
+		return patchPath{}, fmt.Errorf("%w: %q doesn't start with an attribute", ErrInvalidPath, s)
+	}
+	result := patchPath{}
+	if result.attrPath, err = parseAttrPath(t.text); err != nil {
+		return patchPath{}, fmt.Errorf("%w: %w", ErrInvalidPath, err)
+	}
+
+	if p.peek().kind == tokenLBracket {
+		if result.sub != "" {
+			return patchPath{}, fmt.Errorf("%w: %q filters a sub-attribute", ErrInvalidPath, s)
+		}
+		p.next()
+		if result.filter, err = p.parseOr(); err != nil {
+			return patchPath{}, fmt.Errorf("%w: %w", ErrInvalidPath, err)
+		}
+		if err := p.expect(tokenRBracket); err != nil {
+			return patchPath{}, fmt.Errorf("%w: %w", ErrInvalidPath, err)
+		}
+		// The sub-attribute follows the closing bracket, e.g. ].value
+		if t := p.peek(); t.kind == tokenWord && strings.HasPrefix(t.text, ".") {
+			p.next()
+			if !attrName.MatchString(t.text[1:]) {
+				return patchPath{}, fmt.Errorf("%w: invalid sub-attribute %q", ErrInvalidPath, t.text[1:])
+			}
+			result.sub = strings.ToLower(t.text[1:])
+		}
+	}
+
+	if t := p.peek(); t.kind != tokenEOF {
+		return patchPath{}, fmt.Errorf("%w: unexpected %q", ErrInvalidPath, t.text)
+	}
+	return result, nil
+}
+
+// attrPath names an attribute and optionally one of its sub-attributes, in lower case
+type attrPath struct {
+	attr string
+	sub  string
+}
+
+var attrName = regexp.MustCompile(`^\$?[A-Za-z][A-Za-z0-9_-]*$`)
+
+var coreSchemas = []string{dto.SCIMUserSchema, dto.SCIMGroupSchema}
+
+func parseAttrPath(s string) (attrPath, error) {
+	for _, schema := range coreSchemas {
+		if len(s) > len(schema) && strings.EqualFold(s[:len(schema)+1], schema+":") {
+			s = s[len(schema)+1:]
+			break
+		}
+	}
+
+	// Attributes of other schemas keep their URN, whose version contains dots
+	var urn string
+	if i := strings.LastIndex(s, ":"); i >= 0 {
+		urn, s = s[:i+1], s[i+1:]
+	}
+
+	attr, sub, hasSub := strings.Cut(s, ".")
+	if !attrName.MatchString(attr) || hasSub && !attrName.MatchString(sub) {
+		return attrPath{}, fmt.Errorf("invalid attribute %q", urn+s)
+	}
+
+	return attrPath{attr: strings.ToLower(urn + attr), sub: strings.ToLower(sub)}, nil
+}
+
+// values returns the values of the attribute in the resource. Multi-valued
+// complex attributes without a sub-attribute stand for their value
+// sub-attribute, e.g. emails for emails.value.
+func (p attrPath) values(resource map[string]any) []any {
+	v, ok := lookup(resource, p.attr)
+	if !ok {
+		return nil
+	}
+
+	items, multi := v.([]any)
+	if !multi {
+		items = []any{v}
+	}
+
+	var values []any
+	for _, item := range items {
+		obj, complex := item.(map[string]any)
+		switch {
+		case p.sub != "":
+			if complex {
+				if sv, ok := lookup(obj, p.sub); ok {
+					values = append(values, sv)
+				}
+			}
+		case complex && multi:
+			if sv, ok := lookup(obj, "value"); ok {
+				values = append(values, sv)
+			}
+		default:
+			values = append(values, item)
+		}
+	}
+	return values
+}
+
+// caseExact reports whether string values of the attribute are compared
+// case-sensitively. Names and emails aren't, identifiers are.
+func (p attrPath) caseExact() bool {
+	return p.sub == "" && (p.attr == "id" || p.attr == "externalid") || p.attr == "meta" && p.sub == "version"
+}
+
+// lookup finds the key of the map that equals name case-insensitively
+func lookup(m map[string]any, name string) (any, bool) {
+	for key, value := range m {
+		if strings.EqualFold(key, name) {
+			return value, value != nil
+		}
+	}
+	return nil, false
+}
+
+type comparison struct {
+	path  attrPath
+	op    string
+	value any
+}
+
+func (c comparison) Matches(resource map[string]any) bool {
+	values := c.path.values(resource)
+
+	switch c.op {
+	case "pr":
+		for _, v := range values {
+			if v != "" {
+				return true
+			}
+		}
+		return false
+	case "ne":
+		return !comparison{path: c.path, op: "eq", value: c.value}.Matches(resource)
+	}
+
+	if c.value == nil {
+		return len(values) == 0
+	}
+	for _, v := range values {
+		if compare(c.op, v, c.value, c.path.caseExact()) {
+			return true
+		}
+	}
+	return false
+}
+
+func compare(op string, actual any, expected any, caseExact bool) bool {
+	switch e := expected.(type) {
+	case string:
+		a, ok := actual.(string)
+		if !ok {
+			return false
+		}
+		if !caseExact {
+			a, e = strings.ToLower(a), strings.ToLower(e)
+		}
+		switch op {
+		case "eq":
+			return a == e
+		case "co":
+			return strings.Contains(a, e)
+		case "sw":
+			return strings.HasPrefix(a, e)
+		case "ew":
+			return strings.HasSuffix(a, e)
+		case "gt":
+			return a > e
+		case "ge":
+			return a >= e
+		case "lt":
+			return a < e
+		case "le":
+			return a <= e
+		}
+	case bool:
+		a, ok := actual.(bool)
+		return ok && op == "eq" && a == e
+	case float64:
+		a, ok := actual.(float64)
+		if !ok {
+			return false
+		}
+		switch op {
+		case "eq":
+			return a == e
+		case "gt":
+			return a > e
+		case "ge":
+			return a >= e
+		case "lt":
+			return a < e
+		case "le":
+			return a <= e
+		}
+	}
+	return false
+}
+
+// valuePath matches resources with an element of a multi-valued attribute
+// that matches the filter, e.g. emails[type eq "work" and value co "@example.com"]
+type valuePath struct {
+	attr   string
+	filter Filter
+}
+
+func (v valuePath) Matches(resource map[string]any) bool {
+	value, _ := lookup(resource, v.attr)
+	items, ok := value.([]any)
+	if !ok {
+		items = []any{value}
+	}
+
+	for _, item := range items {
+		if obj, ok := item.(map[string]any); ok && v.filter.Matches(obj) {
+			return true
+		}
+	}
+	return false
+}
+
+type and struct{ left, right Filter }
+
+func (a and) Matches(resource map[string]any) bool {
+	return a.left.Matches(resource) && a.right.Matches(resource)
+}
+
+type or struct{ left, right Filter }
+
+func (o or) Matches(resource map[string]any) bool {
+	return o.left.Matches(resource) || o.right.Matches(resource)
+}
+
+type not struct{ filter Filter }
+
+func (n not) Matches(resource map[string]any) bool {
+	return !n.filter.Matches(resource)
+}
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenWord
+	tokenString
+	tokenLParen
+	tokenRParen
+	tokenLBracket
+	tokenRBracket
+)
+
+type token struct {
+	kind tokenKind
+	text string
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func newParser(s string) (*parser, error) {
+	var tokens []token
+	for i := 0; i < len(s); {
+		switch c := s[i]; c {
+		case ' ', '\t', '\r', '\n':
+			i++
+		case '(', ')', '[', ']':
+			kind := map[byte]tokenKind{'(': tokenLParen, ')': tokenRParen, '[': tokenLBracket, ']': tokenRBracket}[c]
+			tokens = append(tokens, token{kind: kind, text: string(c)})
+			i++
+		case '"':
+			end := i + 1
+			for end < len(s) && s[end] != '"' {
+				if s[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(s) {
+				return nil, fmt.Errorf("unterminated string")
+			}
+			var text string
+			if err := json.Unmarshal([]byte(s[i:end+1]), &text); err != nil {
+				return nil, fmt.Errorf("invalid string %s", s[i:end+1])
+			}
+			tokens = append(tokens, token{kind: tokenString, text: text})
+			i = end + 1
+		default:
+			end := i
+			for end < len(s) && !strings.ContainsRune(" \t\r\n()[]\"", rune(s[end])) {
+				end++
+			}
+			tokens = append(tokens, token{kind: tokenWord, text: s[i:end]})
+			i = end
+		}
+	}
+
+	return &parser{tokens: tokens}, nil
+}
+
+func (p *parser) peek() token {
+	if p.pos >= len(p.tokens) {
+		return token{kind: tokenEOF, text: "end of filter"}
+	}
+	return p.tokens[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.peek()
+	if t.kind != tokenEOF {
+		p.pos++
+	}
+	return t
+}
+
+func (p *parser) expect(kind tokenKind) error {
+	if t := p.next(); t.kind != kind {
+		return fmt.Errorf("unexpected %q", t.text)
+	}
+	return nil
+}
+
+// isKeyword reports whether the next token is the given operator, which are case-insensitive
+func (p *parser) isKeyword(keyword string) bool {
+	t := p.peek()
+	return t.kind == tokenWord && strings.EqualFold(t.text, keyword)
+}
+
+func (p *parser) parseOr() (Filter, error) {
+	left, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.isKeyword("or") {
+		p.next()
+		right, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		left = or{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseAnd() (Filter, error) {
+	left, err := p.parseUnary()
+	if err != nil {
+		return nil, err
+	}
+
+	for p.isKeyword("and") {
+		p.next()
+		right, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		left = and{left: left, right: right}
+	}
+	return left, nil
+}
+
+func (p *parser) parseUnary() (Filter, error) {
+	negate := p.isKeyword("not")
+	if negate {
+		p.next()
+		if p.peek().kind != tokenLParen {
+			return nil, fmt.Errorf("not must be followed by a parenthesized filter")
+		}
+	}
+
+	var f Filter
+	var err error
+	switch t := p.next(); t.kind {
+	case tokenLParen:
+		if f, err = p.parseOr(); err != nil {
+			return nil, err
+		}
+		if err := p.expect(tokenRParen); err != nil {
+			return nil, err
+		}
+	case tokenWord:
+		if f, err = p.parseAttrExpr(t.text); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unexpected %q", t.text)
+	}
+
+	if negate {
+		return not{filter: f}, nil
+	}
+	return f, nil
+}
+
+func (p *parser) parseAttrExpr(name string) (Filter, error) {
+	attr, err := parseAttrPath(name)
+	if err != nil {
+		return nil, err
+	}
+
+	if p.peek().kind == tokenLBracket {
+		if attr.sub != "" {
+			return nil, fmt.Errorf("sub-attribute %s can't be filtered", name)
+		}
+		p.next()
+		inner, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(tokenRBracket); err != nil {
+			return nil, err
+		}
+		return valuePath{attr: attr.attr, filter: inner}, nil
+	}
+
+	t := p.next()
+	if t.kind != tokenWord {
+		return nil, fmt.Errorf("expected an operator after %s", name)
+	}
+	op := strings.ToLower(t.text)
+	switch op {
+	case "pr":
+		return comparison{path: attr, op: op}, nil
+	case "eq", "ne", "co", "sw", "ew", "gt", "ge", "lt", "le":
+	default:
+		return nil, fmt.Errorf("unknown operator %q", t.text)
+	}
+
+	value, err := p.parseValue()
+	if err != nil {
+		return nil, err
+	}
+
+	// Booleans and null only have equality; substrings only exist for strings
+	_, isString := value.(string)
+	_, isNumber := value.(float64)
+	switch {
+	case (op == "co" || op == "sw" || op == "ew") && !isString:
+		return nil, fmt.Errorf("%s needs a string", op)
+	case (op == "gt" || op == "ge" || op == "lt" || op == "le") && !isString && !isNumber:
+		return nil, fmt.Errorf("%s needs a string or number", op)
+	}
+
+	return comparison{path: attr, op: op, value: value}, nil
+}
+
+func (p *parser) parseValue() (any, error) {
+	t := p.next()
+	switch t.kind {
+	case tokenString:
+		return t.text, nil
+	case tokenWord:
+		switch strings.ToLower(t.text) {
+		case "true":
+			return true, nil
+		case "false":
+			return false, nil
+		case "null":
+			return nil, nil
+		}
+		if n, err := strconv.ParseFloat(t.text, 64); err == nil {
+			return n, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid value %q", t.text)
+}
@@ -0,0 +1,95 @@
+package scim_test
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/intellifinder/v4/services/auth/internal/domain/scim"
+)
+
+const filterUser = `{
+	"schemas": ["urn:ietf:params:scim:schemas:core:2.0:User"],
+	"id": "2819c223-7f76-453a-919d-413861904646",
+	"externalId": "00u1",
+	"userName": "bjensen@example.com",
+	"name": {"givenName": "Barbara", "familyName": "Jensen"},
+	"emails": [
+		{"value": "bjensen@example.com", "type": "work", "primary": true},
+		{"value": "babs@example.org", "type": "home"}
+	],
+	"active": true,
+	"meta": {"resourceType": "User", "lastModified": "2024-05-01T10:00:00Z"}
+}`
+
+func TestFilterMatches(t *testing.T) {
+	var resource map[string]any
+	if err := json.Unmarshal([]byte(filterUser), &resource); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		filter string
+		want   bool
+	}{
+		{`userName eq "bjensen@example.com"`, true},
+		{`USERNAME eq "BJensen@Example.com"`, true},
+		{`urn:ietf:params:scim:schemas:core:2.0:User:userName eq "bjensen@example.com"`, true},
+		{`userName ne "bjensen@example.com"`, false},
+		{`userName sw "bjen"`, true},
+		{`userName ew "example.org"`, false},
+		{`name.familyName co "ens"`, true},
+		{`externalId eq "00U1"`, false},
+		{`active eq true`, true},
+		{`active eq false`, false},
+		{`title pr`, false},
+		{`name pr`, true},
+		{`emails eq "babs@example.org"`, true},
+		{`emails[type eq "work" and value ew "example.com"]`, true},
+		{`emails[type eq "work" and value ew "example.org"]`, false},
+		{`emails.type eq "home"`, true},
+		{`meta.lastModified gt "2024-01-01T00:00:00Z"`, true},
+		{`meta.lastModified lt "2024-01-01T00:00:00Z"`, false},
+		{`userName eq "nobody" or active eq true`, true},
+		{`userName eq "nobody" or (active eq true and title pr)`, false},
+		{`not (userName eq "nobody")`, true},
+		{`name.givenName eq "Barbara" and not(emails[type eq "home"])`, false},
+		{`urn:ietf:params:scim:schemas:extension:enterprise:2.0:User:employeeNumber pr`, false},
+		{`userName eq "bjensen@example.com" and name.middleName eq null`, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.filter, func(t *testing.T) {
+			f, err := scim.ParseFilter(tt.filter)
+			if err != nil {
+				t.Fatalf("ParseFilter() error = %v", err)
+			}
+			if got := f.Matches(resource); got != tt.want {
+				t.Errorf("Matches() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseFilterErrors(t *testing.T) {
+	for _, filter := range []string{
+		``,
+		`userName`,
+		`userName eq`,
+		`userName is "x"`,
+		`userName eq "unterminated`,
+		`userName eq "x" and`,
+		`(userName eq "x"`,
+		`userName eq "x")`,
+		`emails[type eq "work"`,
+		`userName co 42`,
+		`active gt true`,
+		`userName eq bare`,
+	} {
+		t.Run(filter, func(t *testing.T) {
+			if _, err := scim.ParseFilter(filter); !errors.Is(err, scim.ErrInvalidFilter) {
+				t.Errorf("ParseFilter() error = %v, want ErrInvalidFilter", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,340 @@
+package scim
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/intellifinder/v4/services/auth/pkg/dto"
+	"github.com/intellifinder/v4/services/auth/pkg/models"
+)
+
+// ListGroups returns a page of the tenant's groups matching the query and
+// the number of matching groups
+func (s *Service) ListGroups(ctx context.Context, tenantID string, query Query) ([]*dto.SCIMGroup, int, error) {
+	filter, err := query.parse()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	groups, err := s.repo.ListSCIMGroups(ctx, tenantID)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list SCIM groups: %w", err)
+	}
+
+	var ids []uuid.UUID
+	for _, g := range groups {
+		ids = append(ids, g.Members...)
+	}
+	users, err := s.loadUsers(ctx, ids)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get members: %w", err)
+	}
+
+	matches := []*dto.SCIMGroup{}
+	for _, g := range groups {
+		resource := toSCIMGroup(g, users)
+		if filter == nil || filter.Matches(toMap(resource)) {
+			matches = append(matches, resource)
+		}
+	}
+
+	slices.SortFunc(matches, func(a, b *dto.SCIMGroup) int {
+		return cmp.Or(strings.Compare(a.DisplayName, b.DisplayName), strings.Compare(a.ID, b.ID))
+	})
+
+	start, end := query.page(len(matches))
+	return matches[start:end], len(matches), nil
+}
+
+// GetGroup returns one of the tenant's groups
+func (s *Service) GetGroup(ctx context.Context, tenantID string, id uuid.UUID) (*dto.SCIMGroup, error) {
+	g, err := s.repo.GetSCIMGroup(ctx, tenantID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.groupResource(ctx, g)
+}
+
+// CreateGroup creates the group in Keycloak with its members, which must be
+// users the tenant provisioned. The Keycloak group is deleted again if a
+// member can't be added.
+func (s *Service) CreateGroup(ctx context.Context, tenantID string, in *dto.SCIMGroup) (*dto.SCIMGroup, error) {
+	name := strings.TrimSpace(in.DisplayName)
+	if name == "" {
+		return nil, fmt.Errorf("%w: displayName is required", ErrInvalidValue)
+	}
+
+	ids, err := memberIDs(in.Members)
+	if err != nil {
+		return nil, err
+	}
+	users, err := s.resolveMembers(ctx, tenantID, ids, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	directoryID, err := s.directory.CreateGroup(ctx, tenantID, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create directory group: %w", err)
+	}
+
+	for _, id := range ids {
+		if err := s.directory.AddGroupMember(ctx, directoryID, users[id].OIDCID); err != nil {
+			if deleteErr := s.directory.DeleteGroup(ctx, directoryID); deleteErr != nil {
+				err = errors.Join(err, fmt.Errorf("failed to delete partially created group %s: %w", directoryID, deleteErr))
+			}
+			return nil, fmt.Errorf("failed to add group member: %w", err)
+		}
+	}
+
+	now := s.now()
+	g := &models.SCIMGroup{
+		ID:          uuid.New(),
+		TenantID:    tenantID,
+		DirectoryID: directoryID,
+		DisplayName: name,
+		ExternalID:  in.ExternalID,
+		Members:     ids,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if err := s.repo.CreateSCIMGroup(ctx, g); err != nil {
+		return nil, fmt.Errorf("failed to create SCIM group: %w", err)
+	}
+
+	return toSCIMGroup(g, users), nil
+}
+
+// ReplaceGroup replaces the group's name and members with those of in
+func (s *Service) ReplaceGroup(ctx context.Context, tenantID string, id uuid.UUID, in *dto.SCIMGroup, ifMatch string) (*dto.SCIMGroup, error) {
+	g, err := s.repo.GetSCIMGroup(ctx, tenantID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	current, err := s.groupResource(ctx, g)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkVersion(ifMatch, current.Meta.Version); err != nil {
+		return nil, err
+	}
+
+	return s.replaceGroup(ctx, g, in)
+}
+
+// PatchGroup applies the operations to the group's name and members, e.g.
+// adding members or removing members[value eq "..."]
+func (s *Service) PatchGroup(ctx context.Context, tenantID string, id uuid.UUID, operations []dto.SCIMPatchOperation, ifMatch string) (*dto.SCIMGroup, error) {
+	g, err := s.repo.GetSCIMGroup(ctx, tenantID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	current, err := s.groupResource(ctx, g)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkVersion(ifMatch, current.Meta.Version); err != nil {
+		return nil, err
+	}
+
+	resource := toMap(current)
+	if err := applyPatch(resource, operations); err != nil {
+		return nil, err
+	}
+
+	var in dto.SCIMGroup
+	if err := fromMap(resource, &in); err != nil {
+		return nil, err
+	}
+
+	return s.replaceGroup(ctx, g, &in)
+}
+
+// DeleteGroup deletes the group in Keycloak and locally. Its members lose
+// the roles mapped to it.
+func (s *Service) DeleteGroup(ctx context.Context, tenantID string, id uuid.UUID, ifMatch string) error {
+	g, err := s.repo.GetSCIMGroup(ctx, tenantID, id)
+	if err != nil {
+		return err
+	}
+
+	if ifMatch != "" {
+		current, err := s.groupResource(ctx, g)
+		if err != nil {
+			return err
+		}
+		if err := checkVersion(ifMatch, current.Meta.Version); err != nil {
+			return err
+		}
+	}
+
+	if err := s.directory.DeleteGroup(ctx, g.DirectoryID); err != nil {
+		return fmt.Errorf("failed to delete directory group: %w", err)
+	}
+
+	if err := s.repo.DeleteSCIMGroup(ctx, tenantID, id); err != nil {
+		return fmt.Errorf("failed to delete SCIM group: %w", err)
+	}
+
+	return nil
+}
+
+// replaceGroup renames the group and adds and removes members in Keycloak
+// as needed, then stores the group
+func (s *Service) replaceGroup(ctx context.Context, g *models.SCIMGroup, in *dto.SCIMGroup) (*dto.SCIMGroup, error) {
+	name := strings.TrimSpace(in.DisplayName)
+	if name == "" {
+		return nil, fmt.Errorf("%w: displayName is required", ErrInvalidValue)
+	}
+
+	ids, err := memberIDs(in.Members)
+	if err != nil {
+		return nil, err
+	}
+	users, err := s.resolveMembers(ctx, g.TenantID, ids, g.Members)
+	if err != nil {
+		return nil, err
+	}
+
+	if name != g.DisplayName {
+		if err := s.directory.RenameGroup(ctx, g.DirectoryID, name); err != nil {
+			return nil, fmt.Errorf("failed to rename directory group: %w", err)
+		}
+	}
+
+	current, wanted := idSet(g.Members), idSet(ids)
+	for _, id := range ids {
+		if !current[id] {
+			if err := s.directory.AddGroupMember(ctx, g.DirectoryID, users[id].OIDCID); err != nil {
+				return nil, fmt.Errorf("failed to add group member: %w", err)
+			}
+		}
+	}
+	for _, id := range g.Members {
+		// Members deleted from Keycloak left its group already
+		if u, ok := users[id]; ok && !wanted[id] && u.DeletedAt == nil {
+			if err := s.directory.RemoveGroupMember(ctx, g.DirectoryID, u.OIDCID); err != nil {
+				return nil, fmt.Errorf("failed to remove group member: %w", err)
+			}
+		}
+	}
+
+	g.DisplayName = name
+	g.ExternalID = in.ExternalID
+	g.Members = ids
+	g.UpdatedAt = s.now()
+	if err := s.repo.UpdateSCIMGroup(ctx, g); err != nil {
+		return nil, fmt.Errorf("failed to update SCIM group: %w", err)
+	}
+
+	return toSCIMGroup(g, users), nil
+}
+
+// resolveMembers returns the users of the members and the group's current
+// members by ID. Members that aren't current must be users the tenant
+// provisioned that still exist.
+func (s *Service) resolveMembers(ctx context.Context, tenantID string, ids []uuid.UUID, current []uuid.UUID) (map[uuid.UUID]*models.User, error) {
+	var added []uuid.UUID
+	members := idSet(current)
+	for _, id := range ids {
+		if !members[id] {
+			added = append(added, id)
+		}
+	}
+
+	users, err := s.loadUsers(ctx, append(slices.Clone(ids), current...))
+	if err != nil {
+		return nil, fmt.Errorf("failed to get members: %w", err)
+	}
+	if len(added) == 0 {
+		return users, nil
+	}
+
+	links, err := s.repo.ListSCIMUsers(ctx, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list SCIM users: %w", err)
+	}
+	provisioned := make(map[uuid.UUID]bool, len(links))
+	for _, link := range links {
+		provisioned[link.UserID] = true
+	}
+	for _, id := range added {
+		if u, ok := users[id]; !ok || !provisioned[id] || u.DeletedAt != nil {
+			return nil, fmt.Errorf("%w: member %s is not a user of the tenant", ErrInvalidValue, id)
+		}
+	}
+
+	return users, nil
+}
+
+func (s *Service) groupResource(ctx context.Context, g *models.SCIMGroup) (*dto.SCIMGroup, error) {
+	users, err := s.loadUsers(ctx, g.Members)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get members: %w", err)
+	}
+
+	return toSCIMGroup(g, users), nil
+}
+
+// memberIDs returns the sorted, distinct user IDs of the members. Groups
+// can't be nested.
+func memberIDs(members []dto.SCIMMultiValue) ([]uuid.UUID, error) {
+	ids := []uuid.UUID{}
+	seen := make(map[uuid.UUID]bool)
+	for _, m := range members {
+		if m.Type != "" && !strings.EqualFold(m.Type, "User") {
+			return nil, fmt.Errorf("%w: members must be users", ErrInvalidValue)
+		}
+		id, err := uuid.Parse(m.Value)
+		if err != nil {
+			return nil, fmt.Errorf("%w: member %q is not a user of the tenant", ErrInvalidValue, m.Value)
+		}
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+
+	slices.SortFunc(ids, func(a, b uuid.UUID) int { return strings.Compare(a.String(), b.String()) })
+	return ids, nil
+}
+
+func idSet(ids []uuid.UUID) map[uuid.UUID]bool {
+	set := make(map[uuid.UUID]bool, len(ids))
+	for _, id := range ids {
+		set[id] = true
+	}
+	return set
+}
+
+// toSCIMGroup leaves out members deleted from Keycloak
+func toSCIMGroup(g *models.SCIMGroup, users map[uuid.UUID]*models.User) *dto.SCIMGroup {
+	resource := &dto.SCIMGroup{
+		Schemas:     []string{dto.SCIMGroupSchema},
+		ID:          g.ID.String(),
+		ExternalID:  g.ExternalID,
+		DisplayName: g.DisplayName,
+	}
+
+	for _, id := range g.Members {
+		if u, ok := users[id]; ok && u.DeletedAt == nil {
+			resource.Members = append(resource.Members, dto.SCIMMultiValue{Value: id.String(), Display: u.Username, Type: "User"})
+		}
+	}
+
+	resource.Meta = &dto.SCIMMeta{
+		ResourceType: "Group",
+		Created:      g.CreatedAt,
+		LastModified: g.UpdatedAt,
+		Version:      version(resource),
+	}
+	return resource
+}
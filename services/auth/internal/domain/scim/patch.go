@@ -0,0 +1,238 @@
+package scim
+
+import (
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/intellifinder/v4/services/auth/pkg/dto"
+)
+
+// applyPatch applies the operations in order to the JSON representation of a
+// resource (RFC 7644 section 3.5.2). Operations on attributes the resource
+// doesn't have just add them; they are dropped when the result is decoded.
+func applyPatch(resource map[string]any, operations []dto.SCIMPatchOperation) error {
+	if len(operations) == 0 {
+		return fmt.Errorf("%w: no operations", ErrInvalidSyntax)
+	}
+
+	for _, operation := range operations {
+		// Some identity providers capitalise the operations
+		op := strings.ToLower(operation.Op)
+		if op != "add" && op != "replace" && op != "remove" {
+			return fmt.Errorf("%w: unknown operation %q", ErrInvalidSyntax, operation.Op)
+		}
+
+		var value any
+		if len(operation.Value) > 0 {
+			if err := json.Unmarshal(operation.Value, &value); err != nil {
+				return fmt.Errorf("%w: %w", ErrInvalidSyntax, err)
+			}
+		}
+		if op != "remove" && value == nil {
+			return fmt.Errorf("%w: %s needs a value", ErrInvalidValue, op)
+		}
+
+		if operation.Path != "" {
+			target, err := parsePath(operation.Path)
+			if err != nil {
+				return err
+			}
+			if err := applyOperation(resource, op, target, value); err != nil {
+				return err
+			}
+			continue
+		}
+
+		// Without a path the value maps paths to their values
+		if op == "remove" {
+			return fmt.Errorf("%w: remove needs a path", ErrNoTarget)
+		}
+		values, ok := value.(map[string]any)
+		if !ok {
+			return fmt.Errorf("%w: %s without a path needs an object", ErrInvalidValue, op)
+		}
+		for key, v := range values {
+			target, err := parsePath(key)
+			if err != nil {
+				return err
+			}
+			if err := applyOperation(resource, op, target, v); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func applyOperation(resource map[string]any, op string, target patchPath, value any) error {
+	key, current := findKey(resource, target.attr)
+
+	if target.filter != nil {
+		return applyFiltered(resource, key, current, op, target, value)
+	}
+
+	if target.sub != "" {
+		if _, multi := current.([]any); multi {
+			return fmt.Errorf("%w: %s is multi-valued; select values with a filter", ErrInvalidPath, target.attr)
+		}
+		obj, _ := current.(map[string]any)
+		if obj == nil {
+			if op == "remove" {
+				return nil
+			}
+			obj = make(map[string]any)
+		}
+		subKey, _ := findKey(obj, target.sub)
+		if op == "remove" {
+			delete(obj, subKey)
+		} else {
+			obj[subKey] = value
+		}
+		resource[key] = obj
+		return nil
+	}
+
+	switch op {
+	case "remove":
+		items, multi := current.([]any)
+		if !multi || value == nil {
+			delete(resource, key)
+			return nil
+		}
+		// Removing listed values, e.g. members, without a filter
+		remove := multiValues(value)
+		resource[key] = slices.DeleteFunc(items, func(item any) bool {
+			return slices.Contains(remove, elementValue(item))
+		})
+	case "add":
+		if items, multi := current.([]any); multi {
+			for _, v := range asList(value) {
+				if !slices.Contains(multiValues(items), elementValue(v)) {
+					items = append(items, v)
+				}
+			}
+			resource[key] = items
+			return nil
+		}
+		resource[key] = merge(current, value)
+	case "replace":
+		resource[key] = merge(current, value)
+	}
+	return nil
+}
+
+// applyFiltered changes the elements of a multi-valued attribute matching
+// the path's filter. Adding or replacing a sub-attribute when no element
+// matches a filter like type eq "work" adds such an element, which is how
+// identity providers set the first email.
+func applyFiltered(resource map[string]any, key string, current any, op string, target patchPath, value any) error {
+	items, _ := current.([]any)
+	if current != nil && items == nil {
+		return fmt.Errorf("%w: %s is not multi-valued", ErrInvalidPath, target.attr)
+	}
+
+	matched := false
+	kept := items[:0:0]
+	for _, item := range items {
+		obj, ok := item.(map[string]any)
+		if !ok || !target.filter.Matches(obj) {
+			kept = append(kept, item)
+			continue
+		}
+		matched = true
+
+		switch {
+		case op == "remove" && target.sub == "":
+			continue
+		case op == "remove":
+			subKey, _ := findKey(obj, target.sub)
+			delete(obj, subKey)
+		case target.sub != "":
+			subKey, _ := findKey(obj, target.sub)
+			obj[subKey] = value
+		default:
+			values, ok := value.(map[string]any)
+			if !ok {
+				return fmt.Errorf("%w: %s elements are objects", ErrInvalidValue, target.attr)
+			}
+			for k, v := range values {
+				subKey, _ := findKey(obj, k)
+				obj[subKey] = v
+			}
+		}
+		kept = append(kept, obj)
+	}
+
+	if !matched && op != "remove" {
+		eq, ok := target.filter.(comparison)
+		if !ok || eq.op != "eq" || eq.path.sub != "" || eq.value == nil {
+			return fmt.Errorf("%w: no %s value matches the filter", ErrNoTarget, target.attr)
+		}
+		element := map[string]any{eq.path.attr: eq.value}
+		if target.sub != "" {
+			element[target.sub] = value
+		} else if values, ok := value.(map[string]any); ok {
+			for k, v := range values {
+				element[k] = v
+			}
+		}
+		kept = append(kept, element)
+	}
+
+	resource[key] = kept
+	return nil
+}
+
+// findKey returns the key of the map that equals name case-insensitively,
+// or name if there is none, and its value
+func findKey(m map[string]any, name string) (string, any) {
+	for key, value := range m {
+		if strings.EqualFold(key, name) {
+			return key, value
+		}
+	}
+	return name, nil
+}
+
+// merge replaces current with value, except that the sub-attributes of
+// complex attributes not in value are kept
+func merge(current any, value any) any {
+	obj, ok := current.(map[string]any)
+	values, complex := value.(map[string]any)
+	if !ok || !complex {
+		return value
+	}
+
+	for k, v := range values {
+		key, _ := findKey(obj, k)
+		obj[key] = v
+	}
+	return obj
+}
+
+func asList(value any) []any {
+	if items, ok := value.([]any); ok {
+		return items
+	}
+	return []any{value}
+}
+
+// elementValue returns the value sub-attribute of a complex element, or the element itself
+func elementValue(item any) any {
+	if obj, ok := item.(map[string]any); ok {
+		v, _ := lookup(obj, "value")
+		return v
+	}
+	return item
+}
+
+func multiValues(value any) []any {
+	var values []any
+	for _, item := range asList(value) {
+		values = append(values, elementValue(item))
+	}
+	return values
+}
@@ -0,0 +1,64 @@
+package scim
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/intellifinder/v4/services/auth/internal/domain/user"
+	"github.com/intellifinder/v4/services/auth/pkg/models"
+)
+
+// Repository stores which users and groups each tenant provisioned
+type Repository interface {
+	// CreateSCIMUser returns ErrUniqueness if the user was provisioned already
+	CreateSCIMUser(ctx context.Context, u *models.SCIMUser) error
+	// GetSCIMUser returns ErrResourceNotFound unless the tenant provisioned the user
+	GetSCIMUser(ctx context.Context, tenantID string, userID uuid.UUID) (*models.SCIMUser, error)
+	ListSCIMUsers(ctx context.Context, tenantID string) ([]*models.SCIMUser, error)
+	// UpdateSCIMUser replaces the external ID
+	UpdateSCIMUser(ctx context.Context, u *models.SCIMUser) error
+	// DeleteSCIMUser forgets the user and removes it from the tenant's groups
+	DeleteSCIMUser(ctx context.Context, tenantID string, userID uuid.UUID) error
+
+	// CreateSCIMGroup returns ErrUniqueness if the tenant has a group with the same display name
+	CreateSCIMGroup(ctx context.Context, g *models.SCIMGroup) error
+	// GetSCIMGroup returns ErrResourceNotFound unless the tenant provisioned the group
+	GetSCIMGroup(ctx context.Context, tenantID string, id uuid.UUID) (*models.SCIMGroup, error)
+	ListSCIMGroups(ctx context.Context, tenantID string) ([]*models.SCIMGroup, error)
+	// UpdateSCIMGroup replaces the display name, external ID and members
+	UpdateSCIMGroup(ctx context.Context, g *models.SCIMGroup) error
+	DeleteSCIMGroup(ctx context.Context, tenantID string, id uuid.UUID) error
+}
+
+// Users manages the local users that provisioned users are stored as, and
+// their directory users once they exist. It's implemented by *user.Service.
+type Users interface {
+	GetUser(ctx context.Context, id uuid.UUID) (*models.User, error)
+	GetUserByOIDCID(ctx context.Context, oidcID string) (*models.User, error)
+	GetUsers(ctx context.Context, ids []uuid.UUID, oidcIDs []string) ([]*models.User, error)
+	SyncUser(ctx context.Context, oidcID string) (string, error)
+	UpdateUser(ctx context.Context, id uuid.UUID, params user.UpdateParams) (*models.User, error)
+	ActivateUser(ctx context.Context, id uuid.UUID) (*models.User, error)
+	DeactivateUser(ctx context.Context, id uuid.UUID) (*models.User, error)
+	DeleteUser(ctx context.Context, id uuid.UUID) error
+}
+
+// Directory creates the tenant's users and groups in the identity provider
+type Directory interface {
+	// CreateUser creates a user belonging to the tenant. It returns
+	// user.ErrUserExists if the username or email is taken and
+	// user.ErrInvalidUser for data the directory rejects.
+	CreateUser(ctx context.Context, u *models.User, tenantID string) (*models.User, error)
+	// DeleteUser removes a user whose provisioning couldn't be completed; it
+	// succeeds if the user is already gone
+	DeleteUser(ctx context.Context, oidcID string) error
+	// CreateGroup creates a group of the tenant and returns its directory ID,
+	// or ErrUniqueness if the tenant has a group of that name
+	CreateGroup(ctx context.Context, tenantID string, name string) (string, error)
+	// RenameGroup returns ErrUniqueness if the tenant has a group of that name
+	RenameGroup(ctx context.Context, id string, name string) error
+	// DeleteGroup and RemoveGroupMember succeed if the group or user is already gone
+	DeleteGroup(ctx context.Context, id string) error
+	AddGroupMember(ctx context.Context, groupID string, oidcID string) error
+	RemoveGroupMember(ctx context.Context, groupID string, oidcID string) error
+}
@@ -0,0 +1,148 @@
+// Package scim provisions the users and groups of enterprise tenants from
+// their identity providers, such as Azure AD and Okta, through SCIM 2.0
+// (RFC 7643, RFC 7644). Users and groups are created in Keycloak and stored
+// locally; each tenant only sees the resources it provisioned.
+package scim
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/intellifinder/v4/services/auth/internal/domain/user"
+	"github.com/intellifinder/v4/services/auth/pkg/models"
+)
+
+// MaxResults bounds the resources returned by one listing
+const MaxResults = 1000
+
+// Query selects a page of the resources matching Filter, ordered by
+// userName or displayName. StartIndex is 1-based.
+type Query struct {
+	Filter     string
+	StartIndex int
+	Count      int
+}
+
+type Service struct {
+	repo      Repository
+	users     Users
+	directory Directory
+	now       func() time.Time
+}
+
+func NewService(repo Repository, users Users, directory Directory) *Service {
+	return &Service{
+		repo:      repo,
+		users:     users,
+		directory: directory,
+		now:       time.Now,
+	}
+}
+
+// parse returns the query's filter, or nil if it has none
+func (q Query) parse() (Filter, error) {
+	if strings.TrimSpace(q.Filter) == "" {
+		return nil, nil
+	}
+	return ParseFilter(q.Filter)
+}
+
+// page returns the bounds of the query's page within total results. Start
+// indexes below 1 count as 1 and negative counts as 0 (RFC 7644 section 3.4.2.4).
+func (q Query) page(total int) (int, int) {
+	start := min(max(q.StartIndex, 1)-1, total)
+	count := min(max(q.Count, 0), MaxResults)
+	return start, min(start+count, total)
+}
+
+// loadUsers returns the local users with the given IDs by ID, looking them
+// up in batches. IDs may repeat.
+func (s *Service) loadUsers(ctx context.Context, ids []uuid.UUID) (map[uuid.UUID]*models.User, error) {
+	var distinct []uuid.UUID
+	seen := make(map[uuid.UUID]bool, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			distinct = append(distinct, id)
+		}
+	}
+
+	users := make(map[uuid.UUID]*models.User, len(distinct))
+	for batch := range slices.Chunk(distinct, user.MaxBatchSize) {
+		found, err := s.users.GetUsers(ctx, batch, nil)
+		if err != nil {
+			return nil, err
+		}
+		for _, u := range found {
+			users[u.ID] = u
+		}
+	}
+	return users, nil
+}
+
+// version returns the weak ETag of a resource, which changes whenever one of
+// its attributes does. Meta is left out, so syncing an unchanged user from
+// Keycloak doesn't change it.
+func version(resource any) string {
+	data, _ := json.Marshal(resource)
+	sum := sha256.Sum256(data)
+	return `W/"` + hex.EncodeToString(sum[:8]) + `"`
+}
+
+// checkVersion returns ErrPreconditionFailed unless ifMatch is empty, * or
+// lists the version. Weak and strong tags are compared alike.
+func checkVersion(ifMatch string, version string) error {
+	if ifMatch == "" {
+		return nil
+	}
+
+	for _, tag := range strings.Split(ifMatch, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == strings.TrimPrefix(version, "W/") {
+			return nil
+		}
+	}
+	return ErrPreconditionFailed
+}
+
+// toMap decodes a resource into its JSON representation for filters and PATCH
+func toMap(resource any) map[string]any {
+	data, _ := json.Marshal(resource)
+	var m map[string]any
+	json.Unmarshal(data, &m)
+	return m
+}
+
+// fromMap encodes a patched representation back into a resource
+func fromMap(m map[string]any, resource any) error {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidValue, err)
+	}
+	if err := json.Unmarshal(data, resource); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidValue, err)
+	}
+	return nil
+}
+
+// userError maps the errors of the user domain and directory to SCIM errors
+func userError(err error) error {
+	switch {
+	case errors.Is(err, user.ErrUserExists):
+		return fmt.Errorf("%w: %w", ErrUniqueness, err)
+	case errors.Is(err, user.ErrInvalidUser):
+		return fmt.Errorf("%w: %w", ErrInvalidValue, err)
+	case errors.Is(err, user.ErrUserNotFound):
+		return fmt.Errorf("%w: %w", ErrResourceNotFound, err)
+	default:
+		return err
+	}
+}
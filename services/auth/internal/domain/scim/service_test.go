@@ -0,0 +1,452 @@
+package scim_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/intellifinder/v4/services/auth/internal/domain/scim"
+	"github.com/intellifinder/v4/services/auth/internal/domain/user"
+	"github.com/intellifinder/v4/services/auth/internal/infrastructure/memory"
+	"github.com/intellifinder/v4/services/auth/pkg/dto"
+	"github.com/intellifinder/v4/services/auth/pkg/models"
+)
+
+// directory keeps Keycloak users by OIDC ID and the tenants' groups, with
+// their members' OIDC IDs, by group ID
+type directory struct {
+	users   map[string]models.User
+	tenants map[string]string
+	groups  map[string]string
+	members map[string][]string
+	nextID  int
+}
+
+func (d *directory) ListUsers(_ context.Context, _ int, _ int) ([]*models.User, error) {
+	return nil, nil
+}
+
+func (d *directory) GetUser(_ context.Context, oidcID string) (*models.User, error) {
+	u, ok := d.users[oidcID]
+	if !ok {
+		return nil, user.ErrUserNotFound
+	}
+	return &u, nil
+}
+
+func (d *directory) UpdateUser(_ context.Context, u *models.User) (*models.User, error) {
+	existing, ok := d.users[u.OIDCID]
+	if !ok {
+		return nil, user.ErrUserNotFound
+	}
+
+	existing.Email, existing.FirstName, existing.LastName, existing.Enabled = u.Email, u.FirstName, u.LastName, u.Enabled
+	d.users[u.OIDCID] = existing
+	return &existing, nil
+}
+
+func (d *directory) DeleteUser(_ context.Context, oidcID string) error {
+	if _, ok := d.users[oidcID]; !ok {
+		return user.ErrUserNotFound
+	}
+	delete(d.users, oidcID)
+	for id, members := range d.members {
+		d.members[id] = slices.DeleteFunc(members, func(m string) bool { return m == oidcID })
+	}
+	return nil
+}
+
+func (d *directory) SendPasswordReset(_ context.Context, _ string) error {
+	return nil
+}
+
+func (d *directory) CreateGroup(_ context.Context, tenantID string, name string) (string, error) {
+	for id, existing := range d.groups {
+		if existing == name && d.tenants[id] == tenantID {
+			return "", scim.ErrUniqueness
+		}
+	}
+	d.nextID++
+	id := fmt.Sprintf("group-%d", d.nextID)
+	d.groups[id], d.tenants[id] = name, tenantID
+	return id, nil
+}
+
+func (d *directory) RenameGroup(_ context.Context, id string, name string) error {
+	for otherID, existing := range d.groups {
+		if otherID != id && existing == name && d.tenants[otherID] == d.tenants[id] {
+			return scim.ErrUniqueness
+		}
+	}
+	d.groups[id] = name
+	return nil
+}
+
+func (d *directory) DeleteGroup(_ context.Context, id string) error {
+	delete(d.groups, id)
+	delete(d.members, id)
+	return nil
+}
+
+func (d *directory) AddGroupMember(_ context.Context, groupID string, oidcID string) error {
+	if _, ok := d.groups[groupID]; !ok {
+		return scim.ErrResourceNotFound
+	}
+	if !slices.Contains(d.members[groupID], oidcID) {
+		d.members[groupID] = append(d.members[groupID], oidcID)
+	}
+	return nil
+}
+
+func (d *directory) RemoveGroupMember(_ context.Context, groupID string, oidcID string) error {
+	d.members[groupID] = slices.DeleteFunc(d.members[groupID], func(m string) bool { return m == oidcID })
+	return nil
+}
+
+// userDirectory is the directory as the user service uses it
+type userDirectory struct {
+	*directory
+}
+
+func (d userDirectory) CreateUser(_ context.Context, u *models.User, _ []string) (*models.User, error) {
+	return d.createUser(u)
+}
+
+// tenantDirectory is the directory as the SCIM service uses it
+type tenantDirectory struct {
+	*directory
+}
+
+func (d tenantDirectory) CreateUser(_ context.Context, u *models.User, _ string) (*models.User, error) {
+	return d.createUser(u)
+}
+
+func (d *directory) createUser(u *models.User) (*models.User, error) {
+	for _, existing := range d.users {
+		if existing.Username == u.Username || u.Email != "" && existing.Email == u.Email {
+			return nil, user.ErrUserExists
+		}
+	}
+
+	d.nextID++
+	created := *u
+	created.OIDCID = fmt.Sprintf("kc-%d", d.nextID)
+	d.users[created.OIDCID] = created
+	return &created, nil
+}
+
+// failingRepository fails to record provisioned users
+type failingRepository struct {
+	*memory.Repository
+}
+
+func (failingRepository) CreateSCIMUser(_ context.Context, _ *models.SCIMUser) error {
+	return errors.New("database down")
+}
+
+func newTestService(t *testing.T) (*scim.Service, *directory) {
+	t.Helper()
+
+	repo := memory.NewRepository()
+	idp := &directory{
+		users:   make(map[string]models.User),
+		tenants: make(map[string]string),
+		groups:  make(map[string]string),
+		members: make(map[string][]string),
+	}
+	users := user.NewService(repo)
+	users.SetDirectory(userDirectory{idp})
+
+	service := scim.NewService(repo, users, tenantDirectory{idp})
+	now := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	scim.SetNow(service, func() time.Time { return now })
+	return service, idp
+}
+
+func createUser(t *testing.T, service *scim.Service, tenantID string, username string) *dto.SCIMUser {
+	t.Helper()
+	created, err := service.CreateUser(context.Background(), tenantID, &dto.SCIMUser{
+		Schemas:    []string{dto.SCIMUserSchema},
+		ExternalID: "ext-" + username,
+		UserName:   username,
+		Name:       &dto.SCIMName{GivenName: "Test", FamilyName: "User"},
+		Emails:     []dto.SCIMMultiValue{{Value: username + "@example.com", Type: "work", Primary: true}},
+	})
+	if err != nil {
+		t.Fatalf("CreateUser(%s) error = %v", username, err)
+	}
+	return created
+}
+
+// operations decodes PATCH operations written as JSON
+func operations(t *testing.T, s string) []dto.SCIMPatchOperation {
+	t.Helper()
+	var ops []dto.SCIMPatchOperation
+	if err := json.Unmarshal([]byte(s), &ops); err != nil {
+		t.Fatal(err)
+	}
+	return ops
+}
+
+func TestCreateAndGetUser(t *testing.T) {
+	service, idp := newTestService(t)
+	ctx := context.Background()
+
+	created := createUser(t, service, "tenant-1", "alice")
+	if created.UserName != "alice" || created.ExternalID != "ext-alice" || created.DisplayName != "Test User" || created.Active == nil || !*created.Active {
+		t.Errorf("CreateUser() = %+v, want active alice with her external ID", created)
+	}
+	if created.Meta == nil || created.Meta.Version == "" {
+		t.Errorf("CreateUser() meta = %+v, want a version", created.Meta)
+	}
+	if len(idp.users) != 1 {
+		t.Errorf("directory users = %v, want alice", idp.users)
+	}
+
+	id := uuid.MustParse(created.ID)
+	got, err := service.GetUser(ctx, "tenant-1", id)
+	if err != nil {
+		t.Fatalf("GetUser() error = %v", err)
+	}
+	if got.Meta.Version != created.Meta.Version || len(got.Emails) != 1 || got.Emails[0].Value != "alice@example.com" {
+		t.Errorf("GetUser() = %+v, want the created user", got)
+	}
+
+	if _, err := service.GetUser(ctx, "tenant-2", id); !errors.Is(err, scim.ErrResourceNotFound) {
+		t.Errorf("GetUser() from another tenant error = %v, want ErrResourceNotFound", err)
+	}
+
+	if _, err := service.CreateUser(ctx, "tenant-2", &dto.SCIMUser{UserName: "alice"}); !errors.Is(err, scim.ErrUniqueness) {
+		t.Errorf("CreateUser() taken userName error = %v, want ErrUniqueness", err)
+	}
+	if _, err := service.CreateUser(ctx, "tenant-1", &dto.SCIMUser{UserName: " "}); !errors.Is(err, scim.ErrInvalidValue) {
+		t.Errorf("CreateUser() without userName error = %v, want ErrInvalidValue", err)
+	}
+	if _, err := service.CreateUser(ctx, "tenant-1", &dto.SCIMUser{UserName: "service-account-tasks"}); !errors.Is(err, scim.ErrInvalidValue) {
+		t.Errorf("CreateUser() with a service account userName error = %v, want ErrInvalidValue", err)
+	}
+
+	inactive := false
+	bob, err := service.CreateUser(ctx, "tenant-1", &dto.SCIMUser{UserName: "bob", Active: &inactive})
+	if err != nil {
+		t.Fatalf("CreateUser() inactive error = %v", err)
+	}
+	if *bob.Active || idp.users["kc-2"].Enabled {
+		t.Errorf("CreateUser() inactive = %+v, want a disabled user", bob)
+	}
+}
+
+func TestCreateUserDeletesDirectoryUserOnFailure(t *testing.T) {
+	repo := memory.NewRepository()
+	idp := &directory{users: make(map[string]models.User)}
+	users := user.NewService(repo)
+	users.SetDirectory(userDirectory{idp})
+	service := scim.NewService(failingRepository{repo}, users, tenantDirectory{idp})
+
+	if _, err := service.CreateUser(context.Background(), "tenant-1", &dto.SCIMUser{UserName: "alice"}); err == nil {
+		t.Fatal("CreateUser() error = nil, want the repository's error")
+	}
+	if len(idp.users) != 0 {
+		t.Errorf("directory users = %v, want the partially created user deleted", idp.users)
+	}
+}
+
+func TestListUsers(t *testing.T) {
+	service, _ := newTestService(t)
+	ctx := context.Background()
+
+	for _, name := range []string{"carol", "alice", "bob"} {
+		createUser(t, service, "tenant-1", name)
+	}
+	createUser(t, service, "tenant-2", "dave")
+
+	users, total, err := service.ListUsers(ctx, "tenant-1", scim.Query{StartIndex: 2, Count: 1})
+	if err != nil {
+		t.Fatalf("ListUsers() error = %v", err)
+	}
+	if total != 3 || len(users) != 1 || users[0].UserName != "bob" {
+		t.Errorf("ListUsers() page 2 = %v, %d, want bob of 3", users, total)
+	}
+
+	users, total, err = service.ListUsers(ctx, "tenant-1", scim.Query{Filter: `userName sw "a" or emails[value ew "bob@example.com"]`, StartIndex: 1, Count: 10})
+	if err != nil {
+		t.Fatalf("ListUsers() filtered error = %v", err)
+	}
+	if total != 2 || len(users) != 2 || users[0].UserName != "alice" || users[1].UserName != "bob" {
+		t.Errorf("ListUsers() filtered = %v, %d, want alice and bob", users, total)
+	}
+
+	users, total, err = service.ListUsers(ctx, "tenant-1", scim.Query{StartIndex: 10, Count: 10})
+	if err != nil || total != 3 || len(users) != 0 {
+		t.Errorf("ListUsers() past the end = %v, %d, %v, want none of 3", users, total, err)
+	}
+
+	if _, _, err := service.ListUsers(ctx, "tenant-1", scim.Query{Filter: `userName eq`}); !errors.Is(err, scim.ErrInvalidFilter) {
+		t.Errorf("ListUsers() invalid filter error = %v, want ErrInvalidFilter", err)
+	}
+}
+
+func TestPatchUser(t *testing.T) {
+	service, idp := newTestService(t)
+	ctx := context.Background()
+
+	created := createUser(t, service, "tenant-1", "alice")
+	id := uuid.MustParse(created.ID)
+
+	// As Azure AD sends them: capitalised, without paths and with string booleans
+	patched, err := service.PatchUser(ctx, "tenant-1", id, operations(t, `[
+		{"op": "Replace", "value": {"active": "False", "name.givenName": "Alicia"}},
+		{"op": "replace", "path": "emails[type eq \"work\"].value", "value": "alicia@example.com"},
+		{"op": "add", "path": "externalId", "value": "00u2"}
+	]`), created.Meta.Version)
+	if err != nil {
+		t.Fatalf("PatchUser() error = %v", err)
+	}
+	if *patched.Active || patched.Name.GivenName != "Alicia" || patched.Emails[0].Value != "alicia@example.com" || patched.ExternalID != "00u2" {
+		t.Errorf("PatchUser() = %+v, want inactive Alicia with her new email and external ID", patched)
+	}
+	if u := idp.users["kc-1"]; u.Enabled || u.FirstName != "Alicia" || u.Email != "alicia@example.com" {
+		t.Errorf("directory user = %+v, want the changes", u)
+	}
+	if patched.Meta.Version == created.Meta.Version {
+		t.Errorf("PatchUser() version = %s, want a new one", patched.Meta.Version)
+	}
+
+	if _, err := service.PatchUser(ctx, "tenant-1", id, operations(t, `[{"op": "replace", "path": "active", "value": true}]`), created.Meta.Version); !errors.Is(err, scim.ErrPreconditionFailed) {
+		t.Errorf("PatchUser() stale version error = %v, want ErrPreconditionFailed", err)
+	}
+	if _, err := service.PatchUser(ctx, "tenant-1", id, operations(t, `[{"op": "replace", "path": "userName", "value": "eve"}]`), ""); !errors.Is(err, scim.ErrMutability) {
+		t.Errorf("PatchUser() userName error = %v, want ErrMutability", err)
+	}
+	if _, err := service.PatchUser(ctx, "tenant-1", id, operations(t, `[{"op": "remove"}]`), ""); !errors.Is(err, scim.ErrNoTarget) {
+		t.Errorf("PatchUser() remove without path error = %v, want ErrNoTarget", err)
+	}
+	if _, err := service.PatchUser(ctx, "tenant-1", id, operations(t, `[{"op": "replace", "path": "active", "value": "maybe"}]`), ""); !errors.Is(err, scim.ErrInvalidValue) {
+		t.Errorf("PatchUser() invalid active error = %v, want ErrInvalidValue", err)
+	}
+	if _, err := service.PatchUser(ctx, "tenant-1", id, operations(t, `[{"op": "replace", "path": "emails.value", "value": "x"}]`), ""); !errors.Is(err, scim.ErrInvalidPath) {
+		t.Errorf("PatchUser() sub-attribute of multi-valued attribute error = %v, want ErrInvalidPath", err)
+	}
+
+	replaced, err := service.ReplaceUser(ctx, "tenant-1", id, &dto.SCIMUser{UserName: "ALICE", Emails: []dto.SCIMMultiValue{{Value: "alice@example.com"}}}, "*")
+	if err != nil {
+		t.Fatalf("ReplaceUser() error = %v", err)
+	}
+	if *replaced.Active || replaced.Name != nil || replaced.ExternalID != "" {
+		t.Errorf("ReplaceUser() = %+v, want still inactive without names or external ID", replaced)
+	}
+}
+
+func TestDeleteUser(t *testing.T) {
+	service, idp := newTestService(t)
+	ctx := context.Background()
+
+	created := createUser(t, service, "tenant-1", "alice")
+	id := uuid.MustParse(created.ID)
+
+	if err := service.DeleteUser(ctx, "tenant-2", id, ""); !errors.Is(err, scim.ErrResourceNotFound) {
+		t.Errorf("DeleteUser() from another tenant error = %v, want ErrResourceNotFound", err)
+	}
+	if err := service.DeleteUser(ctx, "tenant-1", id, `W/"stale"`); !errors.Is(err, scim.ErrPreconditionFailed) {
+		t.Errorf("DeleteUser() stale version error = %v, want ErrPreconditionFailed", err)
+	}
+	if err := service.DeleteUser(ctx, "tenant-1", id, created.Meta.Version); err != nil {
+		t.Fatalf("DeleteUser() error = %v", err)
+	}
+	if len(idp.users) != 0 {
+		t.Errorf("directory users = %v, want none", idp.users)
+	}
+	if _, err := service.GetUser(ctx, "tenant-1", id); !errors.Is(err, scim.ErrResourceNotFound) {
+		t.Errorf("GetUser() deleted error = %v, want ErrResourceNotFound", err)
+	}
+}
+
+func TestGroups(t *testing.T) {
+	service, idp := newTestService(t)
+	ctx := context.Background()
+
+	alice := createUser(t, service, "tenant-1", "alice")
+	bob := createUser(t, service, "tenant-1", "bob")
+	dave := createUser(t, service, "tenant-2", "dave")
+
+	group, err := service.CreateGroup(ctx, "tenant-1", &dto.SCIMGroup{DisplayName: "Engineering", Members: []dto.SCIMMultiValue{{Value: alice.ID}, {Value: alice.ID, Type: "User"}}})
+	if err != nil {
+		t.Fatalf("CreateGroup() error = %v", err)
+	}
+	if len(group.Members) != 1 || group.Members[0].Display != "alice" {
+		t.Errorf("CreateGroup() members = %+v, want alice once", group.Members)
+	}
+	directoryID := "group-4"
+	if idp.groups[directoryID] != "Engineering" || !slices.Equal(idp.members[directoryID], []string{"kc-1"}) {
+		t.Errorf("directory groups = %v, members = %v, want Engineering with alice", idp.groups, idp.members)
+	}
+
+	if _, err := service.CreateGroup(ctx, "tenant-1", &dto.SCIMGroup{DisplayName: "Engineering"}); !errors.Is(err, scim.ErrUniqueness) {
+		t.Errorf("CreateGroup() same name error = %v, want ErrUniqueness", err)
+	}
+	if _, err := service.CreateGroup(ctx, "tenant-1", &dto.SCIMGroup{DisplayName: "Sales", Members: []dto.SCIMMultiValue{{Value: dave.ID}}}); !errors.Is(err, scim.ErrInvalidValue) {
+		t.Errorf("CreateGroup() with another tenant's user error = %v, want ErrInvalidValue", err)
+	}
+	if _, err := service.CreateGroup(ctx, "tenant-1", &dto.SCIMGroup{DisplayName: "Nested", Members: []dto.SCIMMultiValue{{Value: group.ID, Type: "Group"}}}); !errors.Is(err, scim.ErrInvalidValue) {
+		t.Errorf("CreateGroup() with a group member error = %v, want ErrInvalidValue", err)
+	}
+
+	id := uuid.MustParse(group.ID)
+	group, err = service.PatchGroup(ctx, "tenant-1", id, operations(t, `[
+		{"op": "add", "path": "members", "value": [{"value": "`+bob.ID+`"}]},
+		{"op": "remove", "path": "members[value eq \"`+alice.ID+`\"]"},
+		{"op": "replace", "path": "displayName", "value": "Platform"}
+	]`), group.Meta.Version)
+	if err != nil {
+		t.Fatalf("PatchGroup() error = %v", err)
+	}
+	if group.DisplayName != "Platform" || len(group.Members) != 1 || group.Members[0].Value != bob.ID {
+		t.Errorf("PatchGroup() = %+v, want Platform with bob", group)
+	}
+	if idp.groups[directoryID] != "Platform" || !slices.Equal(idp.members[directoryID], []string{"kc-2"}) {
+		t.Errorf("directory groups = %v, members = %v, want Platform with bob", idp.groups, idp.members)
+	}
+
+	// Okta removes members by listing their values
+	group, err = service.PatchGroup(ctx, "tenant-1", id, operations(t, `[{"op": "remove", "path": "members", "value": [{"value": "`+bob.ID+`"}]}]`), "")
+	if err != nil || len(group.Members) != 0 || len(idp.members[directoryID]) != 0 {
+		t.Errorf("PatchGroup() remove by value = %+v, %v, want no members", group, err)
+	}
+
+	group, err = service.ReplaceGroup(ctx, "tenant-1", id, &dto.SCIMGroup{DisplayName: "Platform", Members: []dto.SCIMMultiValue{{Value: alice.ID}, {Value: bob.ID}}}, "")
+	if err != nil || len(group.Members) != 2 {
+		t.Fatalf("ReplaceGroup() = %+v, %v, want alice and bob", group, err)
+	}
+
+	user, err := service.GetUser(ctx, "tenant-1", uuid.MustParse(alice.ID))
+	if err != nil || len(user.Groups) != 1 || user.Groups[0].Display != "Platform" {
+		t.Errorf("GetUser() groups = %+v, %v, want Platform", user, err)
+	}
+
+	// Deleted users leave their groups
+	if err := service.DeleteUser(ctx, "tenant-1", uuid.MustParse(alice.ID), ""); err != nil {
+		t.Fatalf("DeleteUser() error = %v", err)
+	}
+	groups, total, err := service.ListGroups(ctx, "tenant-1", scim.Query{Filter: `members[value eq "` + bob.ID + `"]`, StartIndex: 1, Count: 10})
+	if err != nil || total != 1 || len(groups[0].Members) != 1 {
+		t.Errorf("ListGroups() = %+v, %d, %v, want Platform with bob only", groups, total, err)
+	}
+	if groups, _, _ := service.ListGroups(ctx, "tenant-2", scim.Query{Count: 10}); len(groups) != 0 {
+		t.Errorf("ListGroups() of another tenant = %+v, want none", groups)
+	}
+
+	if err := service.DeleteGroup(ctx, "tenant-1", id, ""); err != nil {
+		t.Fatalf("DeleteGroup() error = %v", err)
+	}
+	if _, ok := idp.groups[directoryID]; ok {
+		t.Errorf("directory groups = %v, want Platform deleted", idp.groups)
+	}
+	if _, err := service.GetGroup(ctx, "tenant-1", id); !errors.Is(err, scim.ErrResourceNotFound) {
+		t.Errorf("GetGroup() deleted error = %v, want ErrResourceNotFound", err)
+	}
+}
@@ -0,0 +1,379 @@
+package scim
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/intellifinder/v4/services/auth/internal/domain/user"
+	"github.com/intellifinder/v4/services/auth/pkg/dto"
+	"github.com/intellifinder/v4/services/auth/pkg/models"
+)
+
+// ListUsers returns a page of the tenant's users matching the query and the
+// number of matching users
+func (s *Service) ListUsers(ctx context.Context, tenantID string, query Query) ([]*dto.SCIMUser, int, error) {
+	filter, err := query.parse()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	links, err := s.repo.ListSCIMUsers(ctx, tenantID)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list SCIM users: %w", err)
+	}
+
+	ids := make([]uuid.UUID, len(links))
+	for i, link := range links {
+		ids[i] = link.UserID
+	}
+	users, err := s.loadUsers(ctx, ids)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get users: %w", err)
+	}
+
+	memberships, err := s.memberships(ctx, tenantID)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	matches := []*dto.SCIMUser{}
+	for _, link := range links {
+		// Users deleted in Keycloak are gone for the tenant too
+		u, ok := users[link.UserID]
+		if !ok || u.DeletedAt != nil {
+			continue
+		}
+
+		resource := toSCIMUser(u, link, memberships[u.ID])
+		if filter == nil || filter.Matches(toMap(resource)) {
+			matches = append(matches, resource)
+		}
+	}
+
+	slices.SortFunc(matches, func(a, b *dto.SCIMUser) int {
+		return cmp.Or(strings.Compare(a.UserName, b.UserName), strings.Compare(a.ID, b.ID))
+	})
+
+	start, end := query.page(len(matches))
+	return matches[start:end], len(matches), nil
+}
+
+// GetUser returns one of the tenant's users
+func (s *Service) GetUser(ctx context.Context, tenantID string, id uuid.UUID) (*dto.SCIMUser, error) {
+	link, u, err := s.provisionedUser(ctx, tenantID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.userResource(ctx, link, u)
+}
+
+// CreateUser creates the user in Keycloak as a member of the tenant and
+// stores it locally right away, so its local ID can be returned
+func (s *Service) CreateUser(ctx context.Context, tenantID string, in *dto.SCIMUser) (*dto.SCIMUser, error) {
+	username := strings.TrimSpace(in.UserName)
+	if username == "" {
+		return nil, fmt.Errorf("%w: userName is required", ErrInvalidValue)
+	}
+	if models.ReservedUsername(username) {
+		return nil, fmt.Errorf("%w: userNames starting with %q are reserved for service accounts", ErrInvalidValue, models.ServiceAccountPrefix)
+	}
+
+	firstName, lastName := names(in)
+	created, err := s.directory.CreateUser(ctx, &models.User{
+		Username:  username,
+		Email:     primaryEmail(in.Emails),
+		FirstName: firstName,
+		LastName:  lastName,
+		Enabled:   in.Active == nil || *in.Active,
+	}, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create directory user: %w", userError(err))
+	}
+
+	u, link, err := s.storeUser(ctx, tenantID, created.OIDCID, in.ExternalID)
+	if err != nil {
+		// A local user stored already is deleted by the next reconciliation
+		if deleteErr := s.directory.DeleteUser(ctx, created.OIDCID); deleteErr != nil {
+			err = errors.Join(err, fmt.Errorf("failed to delete partially created user %s: %w", created.OIDCID, deleteErr))
+		}
+		return nil, err
+	}
+
+	return toSCIMUser(u, link, nil), nil
+}
+
+// storeUser stores the directory user locally and records that the tenant
+// provisioned it
+func (s *Service) storeUser(ctx context.Context, tenantID string, oidcID string, externalID string) (*models.User, *models.SCIMUser, error) {
+	if _, err := s.users.SyncUser(ctx, oidcID); err != nil {
+		return nil, nil, fmt.Errorf("failed to store user: %w", err)
+	}
+	u, err := s.users.GetUserByOIDCID(ctx, oidcID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	now := s.now()
+	link := &models.SCIMUser{
+		UserID:     u.ID,
+		TenantID:   tenantID,
+		ExternalID: externalID,
+		CreatedAt:  now,
+		UpdatedAt:  now,
+	}
+	if err := s.repo.CreateSCIMUser(ctx, link); err != nil {
+		return nil, nil, fmt.Errorf("failed to create SCIM user: %w", err)
+	}
+
+	return u, link, nil
+}
+
+// ReplaceUser replaces the user's attributes with those of in. The userName
+// can't be changed, and active is left unchanged if in doesn't have it.
+func (s *Service) ReplaceUser(ctx context.Context, tenantID string, id uuid.UUID, in *dto.SCIMUser, ifMatch string) (*dto.SCIMUser, error) {
+	link, u, err := s.provisionedUser(ctx, tenantID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	current, err := s.userResource(ctx, link, u)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkVersion(ifMatch, current.Meta.Version); err != nil {
+		return nil, err
+	}
+
+	return s.replaceUser(ctx, link, u, in)
+}
+
+// PatchUser applies the operations to the user's attributes
+func (s *Service) PatchUser(ctx context.Context, tenantID string, id uuid.UUID, operations []dto.SCIMPatchOperation, ifMatch string) (*dto.SCIMUser, error) {
+	link, u, err := s.provisionedUser(ctx, tenantID, id)
+	if err != nil {
+		return nil, err
+	}
+
+	current, err := s.userResource(ctx, link, u)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkVersion(ifMatch, current.Meta.Version); err != nil {
+		return nil, err
+	}
+
+	resource := toMap(current)
+	if err := applyPatch(resource, operations); err != nil {
+		return nil, err
+	}
+
+	// Azure AD sends active as a string, e.g. "False"
+	if key, value := findKey(resource, "active"); value != nil {
+		if text, ok := value.(string); ok {
+			active, err := strconv.ParseBool(text)
+			if err != nil {
+				return nil, fmt.Errorf("%w: active must be a boolean", ErrInvalidValue)
+			}
+			resource[key] = active
+		}
+	}
+
+	var in dto.SCIMUser
+	if err := fromMap(resource, &in); err != nil {
+		return nil, err
+	}
+
+	return s.replaceUser(ctx, link, u, &in)
+}
+
+// DeleteUser deletes the user in Keycloak and locally, revoking its access
+func (s *Service) DeleteUser(ctx context.Context, tenantID string, id uuid.UUID, ifMatch string) error {
+	link, u, err := s.provisionedUser(ctx, tenantID, id)
+	if err != nil {
+		return err
+	}
+
+	if ifMatch != "" {
+		current, err := s.userResource(ctx, link, u)
+		if err != nil {
+			return err
+		}
+		if err := checkVersion(ifMatch, current.Meta.Version); err != nil {
+			return err
+		}
+	}
+
+	if err := s.users.DeleteUser(ctx, u.ID); err != nil && !errors.Is(err, user.ErrUserNotFound) {
+		return fmt.Errorf("failed to delete user: %w", err)
+	}
+
+	if err := s.repo.DeleteSCIMUser(ctx, tenantID, u.ID); err != nil {
+		return fmt.Errorf("failed to delete SCIM user: %w", err)
+	}
+
+	return nil
+}
+
+// replaceUser changes what differs between the user and in, going through
+// the user service so disabled users lose their sessions and tokens
+func (s *Service) replaceUser(ctx context.Context, link *models.SCIMUser, u *models.User, in *dto.SCIMUser) (*dto.SCIMUser, error) {
+	username := strings.TrimSpace(in.UserName)
+	if username == "" {
+		return nil, fmt.Errorf("%w: userName is required", ErrInvalidValue)
+	}
+	if !strings.EqualFold(username, u.Username) {
+		return nil, fmt.Errorf("%w: userName can't be changed", ErrMutability)
+	}
+
+	var params user.UpdateParams
+	email := primaryEmail(in.Emails)
+	if email != u.Email {
+		params.Email = &email
+	}
+	firstName, lastName := names(in)
+	if firstName != u.FirstName {
+		params.FirstName = &firstName
+	}
+	if lastName != u.LastName {
+		params.LastName = &lastName
+	}
+
+	var err error
+	if params != (user.UpdateParams{}) {
+		if u, err = s.users.UpdateUser(ctx, u.ID, params); err != nil {
+			return nil, fmt.Errorf("failed to update user: %w", userError(err))
+		}
+	}
+
+	if in.Active != nil && *in.Active != u.Enabled {
+		if *in.Active {
+			u, err = s.users.ActivateUser(ctx, u.ID)
+		} else {
+			u, err = s.users.DeactivateUser(ctx, u.ID)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to change whether the user is active: %w", userError(err))
+		}
+	}
+
+	if in.ExternalID != link.ExternalID {
+		link.ExternalID = in.ExternalID
+		link.UpdatedAt = s.now()
+		if err := s.repo.UpdateSCIMUser(ctx, link); err != nil {
+			return nil, fmt.Errorf("failed to update SCIM user: %w", err)
+		}
+	}
+
+	return s.userResource(ctx, link, u)
+}
+
+// provisionedUser returns ErrResourceNotFound for users the tenant didn't
+// provision and users deleted since
+func (s *Service) provisionedUser(ctx context.Context, tenantID string, id uuid.UUID) (*models.SCIMUser, *models.User, error) {
+	link, err := s.repo.GetSCIMUser(ctx, tenantID, id)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	u, err := s.users.GetUser(ctx, id)
+	if errors.Is(err, user.ErrUserNotFound) || err == nil && u.DeletedAt != nil {
+		return nil, nil, ErrResourceNotFound
+	}
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	return link, u, nil
+}
+
+func (s *Service) userResource(ctx context.Context, link *models.SCIMUser, u *models.User) (*dto.SCIMUser, error) {
+	memberships, err := s.memberships(ctx, link.TenantID)
+	if err != nil {
+		return nil, err
+	}
+
+	return toSCIMUser(u, link, memberships[u.ID]), nil
+}
+
+// memberships returns the tenant's groups by member
+func (s *Service) memberships(ctx context.Context, tenantID string) (map[uuid.UUID][]*models.SCIMGroup, error) {
+	groups, err := s.repo.ListSCIMGroups(ctx, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list SCIM groups: %w", err)
+	}
+
+	memberships := make(map[uuid.UUID][]*models.SCIMGroup)
+	for _, g := range groups {
+		for _, id := range g.Members {
+			memberships[id] = append(memberships[id], g)
+		}
+	}
+	return memberships, nil
+}
+
+func toSCIMUser(u *models.User, link *models.SCIMUser, groups []*models.SCIMGroup) *dto.SCIMUser {
+	active := u.Enabled
+	resource := &dto.SCIMUser{
+		Schemas:     []string{dto.SCIMUserSchema},
+		ID:          u.ID.String(),
+		ExternalID:  link.ExternalID,
+		UserName:    u.Username,
+		DisplayName: u.Username,
+		Active:      &active,
+	}
+
+	if u.FirstName != "" || u.LastName != "" {
+		formatted := strings.TrimSpace(u.FirstName + " " + u.LastName)
+		resource.Name = &dto.SCIMName{Formatted: formatted, GivenName: u.FirstName, FamilyName: u.LastName}
+		resource.DisplayName = formatted
+	}
+	if u.Email != "" {
+		resource.Emails = []dto.SCIMMultiValue{{Value: u.Email, Type: "work", Primary: true}}
+	}
+	for _, g := range groups {
+		resource.Groups = append(resource.Groups, dto.SCIMMultiValue{Value: g.ID.String(), Display: g.DisplayName, Type: "direct"})
+	}
+
+	lastModified := u.UpdatedAt
+	if link.UpdatedAt.After(lastModified) {
+		lastModified = link.UpdatedAt
+	}
+	resource.Meta = &dto.SCIMMeta{
+		ResourceType: "User",
+		Created:      link.CreatedAt,
+		LastModified: lastModified,
+		Version:      version(resource),
+	}
+	return resource
+}
+
+// primaryEmail returns the primary email, or the first one if none is
+// primary, since Keycloak users have a single email
+func primaryEmail(emails []dto.SCIMMultiValue) string {
+	if len(emails) == 0 {
+		return ""
+	}
+
+	email := emails[0]
+	for _, e := range emails {
+		if e.Primary {
+			email = e
+			break
+		}
+	}
+	return strings.ToLower(strings.TrimSpace(email.Value))
+}
+
+func names(in *dto.SCIMUser) (string, string) {
+	if in.Name == nil {
+		return "", ""
+	}
+	return strings.TrimSpace(in.Name.GivenName), strings.TrimSpace(in.Name.FamilyName)
+}
@@ -0,0 +1,13 @@
+package session
+
+import "errors"
+
+var (
+	ErrSessionNotFound = errors.New("session not found")
+	// ErrSessionConflict is returned by repositories when a session was
+	// rotated or ended concurrently
+	ErrSessionConflict = errors.New("session was changed concurrently")
+	// ErrRefreshTokenReused is returned for a refresh token that was already
+	// rotated; the session is ended since the token may have been stolen
+	ErrRefreshTokenReused = errors.New("refresh token reused")
+)
@@ -0,0 +1,35 @@
+package session
+
+import (
+	"context"
+
+	"github.com/intellifinder/v4/services/auth/pkg/models"
+)
+
+type Repository interface {
+	// CreateSession stores a new session until it expires
+	CreateSession(ctx context.Context, s *models.Session) error
+	// GetSession returns ErrSessionNotFound for unknown and expired sessions
+	GetSession(ctx context.Context, id string) (*models.Session, error)
+	// UpdateSession replaces the session if it's still at the given
+	// generation and returns ErrSessionConflict otherwise
+	UpdateSession(ctx context.Context, s *models.Session, generation int) error
+	// ListSessions returns the user's unexpired sessions, most recently seen first
+	ListSessions(ctx context.Context, userID string) ([]*models.Session, error)
+	// DeleteSession ends the session; deleting it again is not an error
+	DeleteSession(ctx context.Context, s *models.Session) error
+}
+
+// Revoker revokes the access tokens issued in a session
+type Revoker interface {
+	RevokeSession(ctx context.Context, sessionID string) error
+}
+
+// Authenticator obtains and verifies the upstream tokens of sessions. It's
+// implemented by *authentication.Service.
+type Authenticator interface {
+	Login(ctx context.Context, username string, password string) (*models.TokenSet, error)
+	Refresh(ctx context.Context, refreshToken string) (*models.TokenSet, error)
+	Logout(ctx context.Context, refreshToken string) error
+	AuthenticateToken(ctx context.Context, token string) (*models.Identity, error)
+}
@@ -0,0 +1,301 @@
+package session
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/intellifinder/v4/services/auth/internal/domain/authentication"
+	"github.com/intellifinder/v4/services/auth/pkg/models"
+)
+
+// maxDeviceLength bounds the stored User-Agent, which is only shown to the user
+const maxDeviceLength = 255
+
+type Service struct {
+	repo        Repository
+	auth        Authenticator
+	revoker     Revoker
+	maxLifetime time.Duration
+	now         func() time.Time
+}
+
+// NewService returns a service whose sessions can be refreshed for at most
+// maxLifetime after logging in
+func NewService(repo Repository, auth Authenticator, maxLifetime time.Duration) *Service {
+	return &Service{
+		repo:        repo,
+		auth:        auth,
+		maxLifetime: maxLifetime,
+		now:         time.Now,
+	}
+}
+
+// SetRevoker revokes the access tokens of sessions when they end, so they
+// stop working at once rather than when they expire
+func (s *Service) SetRevoker(revoker Revoker) {
+	s.revoker = revoker
+}
+
+// Metadata describes the client a session is used from
+type Metadata struct {
+	Device    string
+	IPAddress string
+}
+
+// Login starts a session for the user and returns its first tokens
+func (s *Service) Login(ctx context.Context, username string, password string, meta Metadata) (*models.TokenSet, error) {
+	tokens, err := s.auth.Login(ctx, username, password)
+	if err != nil {
+		return nil, err
+	}
+
+	identity, err := s.auth.AuthenticateToken(ctx, tokens.AccessToken)
+	if err != nil {
+		s.logoutUpstream(ctx, tokens.RefreshToken)
+		return nil, fmt.Errorf("failed to verify issued access token: %w", err)
+	}
+	if identity.SessionID == "" {
+		s.logoutUpstream(ctx, tokens.RefreshToken)
+		return nil, fmt.Errorf("issued access token has no session ID")
+	}
+
+	key, err := newKey()
+	if err != nil {
+		s.logoutUpstream(ctx, tokens.RefreshToken)
+		return nil, err
+	}
+
+	now := s.now()
+	session := &models.Session{
+		ID:                   identity.SessionID,
+		UserID:               identity.Subject,
+		Device:               truncate(meta.Device, maxDeviceLength),
+		IPAddress:            meta.IPAddress,
+		CreatedAt:            now,
+		LastSeenAt:           now,
+		Key:                  key,
+		UpstreamRefreshToken: tokens.RefreshToken,
+	}
+	s.setExpiry(session, tokens, now)
+
+	if err := s.repo.CreateSession(ctx, session); err != nil {
+		s.logoutUpstream(ctx, tokens.RefreshToken)
+		return nil, fmt.Errorf("failed to create session: %w", err)
+	}
+
+	return s.tokenSet(session, tokens, now), nil
+}
+
+// Refresh rotates the refresh token of a session and returns new tokens.
+// Presenting a refresh token that was already rotated ends the session,
+// since either the legitimate client or an attacker holds a stolen copy.
+func (s *Service) Refresh(ctx context.Context, refreshToken string, meta Metadata) (*models.TokenSet, error) {
+	session, generation, err := s.verify(ctx, refreshToken)
+	if err != nil {
+		return nil, err
+	}
+
+	if generation != session.Generation {
+		s.end(ctx, session)
+		return nil, fmt.Errorf("%w: %w", authentication.ErrInvalidCredentials, ErrRefreshTokenReused)
+	}
+
+	tokens, err := s.auth.Refresh(ctx, session.UpstreamRefreshToken)
+	if errors.Is(err, authentication.ErrInvalidCredentials) {
+		// The session ended in Keycloak, e.g. because it idled out or an
+		// administrator logged the user out there
+		if err := s.repo.DeleteSession(ctx, session); err != nil {
+			return nil, fmt.Errorf("failed to delete session: %w", err)
+		}
+		return nil, err
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	now := s.now()
+	rotated := *session
+	rotated.Generation++
+	rotated.UpstreamRefreshToken = tokens.RefreshToken
+	rotated.LastSeenAt = now
+	if meta.IPAddress != "" {
+		rotated.IPAddress = meta.IPAddress
+	}
+	s.setExpiry(&rotated, tokens, now)
+
+	err = s.repo.UpdateSession(ctx, &rotated, session.Generation)
+	switch {
+	case errors.Is(err, ErrSessionConflict):
+		// Another request rotated the same token first
+		s.end(ctx, &rotated)
+		return nil, fmt.Errorf("%w: %w", authentication.ErrInvalidCredentials, ErrRefreshTokenReused)
+	case err != nil:
+		return nil, fmt.Errorf("failed to update session: %w", err)
+	}
+
+	return s.tokenSet(&rotated, tokens, now), nil
+}
+
+// Logout ends the session of a refresh token
+func (s *Service) Logout(ctx context.Context, refreshToken string) error {
+	session, _, err := s.verify(ctx, refreshToken)
+	if err != nil {
+		return err
+	}
+
+	return s.end(ctx, session)
+}
+
+// ListSessions returns the user's active sessions, most recently seen first
+func (s *Service) ListSessions(ctx context.Context, userID string) ([]*models.Session, error) {
+	sessions, err := s.repo.ListSessions(ctx, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+	return sessions, nil
+}
+
+// RevokeSession ends one of the user's sessions
+func (s *Service) RevokeSession(ctx context.Context, userID string, id string) error {
+	session, err := s.repo.GetSession(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	// Other users' sessions are reported as missing so their IDs can't be probed
+	if session.UserID != userID {
+		return ErrSessionNotFound
+	}
+
+	return s.end(ctx, session)
+}
+
+// RevokeAllSessions ends every session of the user and returns how many there were
+func (s *Service) RevokeAllSessions(ctx context.Context, userID string) (int, error) {
+	sessions, err := s.ListSessions(ctx, userID)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, session := range sessions {
+		if err := s.end(ctx, session); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(sessions), nil
+}
+
+// EndSession follows a session that was ended in Keycloak and returns its
+// user, or "" when the session wasn't started through the service. Its access
+// tokens are revoked either way, since they may have been issued to other
+// clients of the realm.
+func (s *Service) EndSession(ctx context.Context, id string) (string, error) {
+	if s.revoker != nil {
+		if err := s.revoker.RevokeSession(ctx, id); err != nil {
+			return "", err
+		}
+	}
+
+	session, err := s.repo.GetSession(ctx, id)
+	if errors.Is(err, ErrSessionNotFound) {
+		return "", nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get session: %w", err)
+	}
+
+	if err := s.repo.DeleteSession(ctx, session); err != nil {
+		return "", fmt.Errorf("failed to delete session: %w", err)
+	}
+	return session.UserID, nil
+}
+
+// verify returns the session and generation of a correctly signed refresh token
+func (s *Service) verify(ctx context.Context, refreshToken string) (*models.Session, int, error) {
+	id, generation, signature, ok := parseRefreshToken(refreshToken)
+	if !ok {
+		return nil, 0, fmt.Errorf("%w: malformed refresh token", authentication.ErrInvalidCredentials)
+	}
+
+	session, err := s.repo.GetSession(ctx, id)
+	if errors.Is(err, ErrSessionNotFound) {
+		return nil, 0, fmt.Errorf("%w: %w", authentication.ErrInvalidCredentials, err)
+	}
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get session: %w", err)
+	}
+
+	if !validSignature(session.Key, id, generation, signature) || generation > session.Generation {
+		return nil, 0, fmt.Errorf("%w: bad refresh token signature", authentication.ErrInvalidCredentials)
+	}
+
+	if !session.ExpiresAt.After(s.now()) {
+		return nil, 0, fmt.Errorf("%w: session expired", authentication.ErrInvalidCredentials)
+	}
+
+	return session, generation, nil
+}
+
+// end revokes the session's access tokens, deletes it and logs it out of
+// Keycloak. Tokens are revoked first so a failure can be retried while the
+// session is still listed.
+func (s *Service) end(ctx context.Context, session *models.Session) error {
+	if s.revoker != nil {
+		if err := s.revoker.RevokeSession(ctx, session.ID); err != nil {
+			return err
+		}
+	}
+
+	if err := s.repo.DeleteSession(ctx, session); err != nil {
+		return fmt.Errorf("failed to delete session: %w", err)
+	}
+
+	s.logoutUpstream(ctx, session.UpstreamRefreshToken)
+	return nil
+}
+
+// logoutUpstream ends the Keycloak session behind a refresh token. Failures
+// are ignored: the refresh token is never handed out, so the Keycloak
+// session can't be used anymore and idles out on its own.
+func (s *Service) logoutUpstream(ctx context.Context, refreshToken string) {
+	_ = s.auth.Logout(ctx, refreshToken)
+}
+
+// setExpiry makes the session expire with its upstream refresh token, but
+// no later than the maximum lifetime after logging in
+func (s *Service) setExpiry(session *models.Session, tokens *models.TokenSet, now time.Time) {
+	session.ExpiresAt = session.CreatedAt.Add(s.maxLifetime)
+	if tokens.RefreshExpiresIn > 0 {
+		session.ExpiresAt = minTime(session.ExpiresAt, now.Add(time.Duration(tokens.RefreshExpiresIn)*time.Second))
+	}
+}
+
+// tokenSet replaces the upstream refresh token with the session's own
+func (s *Service) tokenSet(session *models.Session, tokens *models.TokenSet, now time.Time) *models.TokenSet {
+	return &models.TokenSet{
+		AccessToken:      tokens.AccessToken,
+		RefreshToken:     formatRefreshToken(session.ID, session.Generation, session.Key),
+		TokenType:        tokens.TokenType,
+		ExpiresIn:        tokens.ExpiresIn,
+		RefreshExpiresIn: int(session.ExpiresAt.Sub(now) / time.Second),
+	}
+}
+
+func minTime(a time.Time, b time.Time) time.Time {
+	if b.Before(a) {
+		return b
+	}
+	return a
+}
+
+// truncate shortens s to at most n bytes without splitting a character
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+	return strings.ToValidUTF8(s[:n], "")
+}
@@ -0,0 +1,272 @@
+package session_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/intellifinder/v4/services/auth/internal/domain/authentication"
+	"github.com/intellifinder/v4/services/auth/internal/domain/session"
+	"github.com/intellifinder/v4/services/auth/internal/infrastructure/memory"
+	"github.com/intellifinder/v4/services/auth/pkg/models"
+)
+
+// upstream imitates Keycloak: every login starts a session whose refresh
+// token is rotated on refresh, and logging out ends it
+type upstream struct {
+	logins int
+	// sessions maps each session's current refresh token to its ID
+	sessions  map[string]string
+	loggedOut []string
+}
+
+func newUpstream() *upstream {
+	return &upstream{sessions: make(map[string]string)}
+}
+
+func (u *upstream) Login(_ context.Context, username string, password string) (*models.TokenSet, error) {
+	if username != "alice" && username != "bob" || password != "secret" {
+		return nil, authentication.ErrInvalidCredentials
+	}
+
+	u.logins++
+	sid := fmt.Sprintf("sid-%d", u.logins)
+	return u.issue(username, sid, 0), nil
+}
+
+func (u *upstream) Refresh(_ context.Context, refreshToken string) (*models.TokenSet, error) {
+	sid, ok := u.sessions[refreshToken]
+	if !ok {
+		return nil, authentication.ErrInvalidCredentials
+	}
+	delete(u.sessions, refreshToken)
+
+	username, rest, _ := strings.Cut(refreshToken, "/")
+	var generation int
+	fmt.Sscanf(strings.TrimPrefix(rest, sid+"/"), "%d", &generation)
+	return u.issue(username, sid, generation+1), nil
+}
+
+func (u *upstream) Logout(_ context.Context, refreshToken string) error {
+	sid, ok := u.sessions[refreshToken]
+	if !ok {
+		return authentication.ErrInvalidCredentials
+	}
+	delete(u.sessions, refreshToken)
+	u.loggedOut = append(u.loggedOut, sid)
+	return nil
+}
+
+func (u *upstream) AuthenticateToken(_ context.Context, token string) (*models.Identity, error) {
+	username, sid, ok := strings.Cut(strings.TrimPrefix(token, "access/"), "/")
+	if !ok {
+		return nil, authentication.ErrInvalidCredentials
+	}
+	return &models.Identity{Subject: username + "-id", Username: username, SessionID: sid}, nil
+}
+
+func (u *upstream) issue(username string, sid string, generation int) *models.TokenSet {
+	refreshToken := fmt.Sprintf("%s/%s/%d", username, sid, generation)
+	u.sessions[refreshToken] = sid
+	return &models.TokenSet{
+		AccessToken:      "access/" + username + "/" + sid,
+		RefreshToken:     refreshToken,
+		TokenType:        "Bearer",
+		ExpiresIn:        300,
+		RefreshExpiresIn: 1800,
+	}
+}
+
+var laptop = session.Metadata{Device: "Firefox", IPAddress: "192.0.2.1"}
+
+func TestRefreshRotatesTokens(t *testing.T) {
+	keycloak := newUpstream()
+	service := session.NewService(memory.NewRepository(), keycloak, 24*time.Hour)
+	ctx := context.Background()
+
+	tokens, err := service.Login(ctx, "alice", "secret", laptop)
+	if err != nil {
+		t.Fatalf("Login() error = %v", err)
+	}
+	if tokens.AccessToken != "access/alice/sid-1" || tokens.RefreshExpiresIn != 1800 {
+		t.Errorf("Login() = %+v, want Keycloak's access token and refresh lifetime", tokens)
+	}
+	if strings.HasPrefix(tokens.RefreshToken, "alice/") {
+		t.Errorf("Login() refresh token = %q, want the session's own token", tokens.RefreshToken)
+	}
+
+	refreshed, err := service.Refresh(ctx, tokens.RefreshToken, session.Metadata{IPAddress: "198.51.100.7"})
+	if err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+	if refreshed.RefreshToken == tokens.RefreshToken {
+		t.Error("Refresh() returned the same refresh token, want it rotated")
+	}
+
+	sessions, err := service.ListSessions(ctx, "alice-id")
+	if err != nil || len(sessions) != 1 {
+		t.Fatalf("ListSessions() = %v, %v, want one session", sessions, err)
+	}
+	if got := sessions[0]; got.ID != "sid-1" || got.Device != "Firefox" || got.IPAddress != "198.51.100.7" {
+		t.Errorf("ListSessions() = %+v, want sid-1 from Firefox last seen at 198.51.100.7", got)
+	}
+
+	if _, err := service.Refresh(ctx, refreshed.RefreshToken, laptop); err != nil {
+		t.Errorf("Refresh() with the rotated token error = %v", err)
+	}
+}
+
+func TestRefreshTokenReuseEndsSession(t *testing.T) {
+	keycloak := newUpstream()
+	service := session.NewService(memory.NewRepository(), keycloak, 24*time.Hour)
+	ctx := context.Background()
+
+	tokens, _ := service.Login(ctx, "alice", "secret", laptop)
+	refreshed, err := service.Refresh(ctx, tokens.RefreshToken, laptop)
+	if err != nil {
+		t.Fatalf("Refresh() error = %v", err)
+	}
+
+	_, err = service.Refresh(ctx, tokens.RefreshToken, laptop)
+	if !errors.Is(err, session.ErrRefreshTokenReused) || !errors.Is(err, authentication.ErrInvalidCredentials) {
+		t.Errorf("Refresh() with a rotated token error = %v, want ErrRefreshTokenReused", err)
+	}
+	if !slices.Equal(keycloak.loggedOut, []string{"sid-1"}) {
+		t.Errorf("Keycloak sessions logged out = %v, want [sid-1]", keycloak.loggedOut)
+	}
+
+	if _, err := service.Refresh(ctx, refreshed.RefreshToken, laptop); !errors.Is(err, authentication.ErrInvalidCredentials) {
+		t.Errorf("Refresh() after reuse error = %v, want the latest token revoked too", err)
+	}
+	if sessions, _ := service.ListSessions(ctx, "alice-id"); len(sessions) != 0 {
+		t.Errorf("ListSessions() = %v, want none", sessions)
+	}
+}
+
+func TestRefreshRejectsInvalidTokens(t *testing.T) {
+	now := time.Now()
+	keycloak := newUpstream()
+	service := session.NewService(memory.NewRepository(), keycloak, time.Hour)
+	session.SetNow(service, func() time.Time { return now })
+	ctx := context.Background()
+
+	tokens, _ := service.Login(ctx, "alice", "secret", laptop)
+	parts := strings.Split(tokens.RefreshToken, ".")
+	nextGeneration := parts[0] + ".1." + parts[2]
+
+	for _, token := range []string{"", "garbage", nextGeneration, tokens.RefreshToken + "x", "sid-2.0.AAAA"} {
+		if _, err := service.Refresh(ctx, token, laptop); !errors.Is(err, authentication.ErrInvalidCredentials) {
+			t.Errorf("Refresh(%q) error = %v, want ErrInvalidCredentials", token, err)
+		}
+	}
+
+	now = now.Add(time.Hour)
+	if _, err := service.Refresh(ctx, tokens.RefreshToken, laptop); !errors.Is(err, authentication.ErrInvalidCredentials) {
+		t.Errorf("Refresh() after the maximum lifetime error = %v, want ErrInvalidCredentials", err)
+	}
+}
+
+func TestRefreshEndsSessionsEndedUpstream(t *testing.T) {
+	keycloak := newUpstream()
+	service := session.NewService(memory.NewRepository(), keycloak, 24*time.Hour)
+	ctx := context.Background()
+
+	tokens, _ := service.Login(ctx, "alice", "secret", laptop)
+	clear(keycloak.sessions)
+
+	if _, err := service.Refresh(ctx, tokens.RefreshToken, laptop); !errors.Is(err, authentication.ErrInvalidCredentials) {
+		t.Errorf("Refresh() error = %v, want ErrInvalidCredentials", err)
+	}
+	if sessions, _ := service.ListSessions(ctx, "alice-id"); len(sessions) != 0 {
+		t.Errorf("ListSessions() = %v, want the session deleted", sessions)
+	}
+}
+
+// revoker records which sessions' access tokens were revoked
+type revoker struct {
+	revoked []string
+}
+
+func (r *revoker) RevokeSession(_ context.Context, sessionID string) error {
+	r.revoked = append(r.revoked, sessionID)
+	return nil
+}
+
+func TestLogoutAndRevocation(t *testing.T) {
+	keycloak := newUpstream()
+	service := session.NewService(memory.NewRepository(), keycloak, 24*time.Hour)
+	tokens := &revoker{}
+	service.SetRevoker(tokens)
+	ctx := context.Background()
+
+	first, _ := service.Login(ctx, "alice", "secret", laptop)
+	service.Login(ctx, "alice", "secret", session.Metadata{Device: "Safari"})
+	service.Login(ctx, "alice", "secret", session.Metadata{Device: "curl"})
+	service.Login(ctx, "bob", "secret", laptop)
+
+	if err := service.Logout(ctx, first.RefreshToken); err != nil {
+		t.Fatalf("Logout() error = %v", err)
+	}
+	if err := service.Logout(ctx, first.RefreshToken); !errors.Is(err, authentication.ErrInvalidCredentials) {
+		t.Errorf("Logout() again error = %v, want ErrInvalidCredentials", err)
+	}
+
+	if err := service.RevokeSession(ctx, "alice-id", "sid-4"); !errors.Is(err, session.ErrSessionNotFound) {
+		t.Errorf("RevokeSession() of another user's session error = %v, want ErrSessionNotFound", err)
+	}
+	if err := service.RevokeSession(ctx, "alice-id", "sid-2"); err != nil {
+		t.Errorf("RevokeSession() error = %v", err)
+	}
+
+	sessions, _ := service.ListSessions(ctx, "alice-id")
+	if len(sessions) != 1 || sessions[0].ID != "sid-3" {
+		t.Errorf("ListSessions() = %v, want only sid-3", sessions)
+	}
+
+	revoked, err := service.RevokeAllSessions(ctx, "alice-id")
+	if err != nil || revoked != 1 {
+		t.Errorf("RevokeAllSessions() = %d, %v, want 1", revoked, err)
+	}
+	if !slices.Equal(keycloak.loggedOut, []string{"sid-1", "sid-2", "sid-3"}) {
+		t.Errorf("Keycloak sessions logged out = %v, want sid-1 to sid-3", keycloak.loggedOut)
+	}
+	if !slices.Equal(tokens.revoked, keycloak.loggedOut) {
+		t.Errorf("access tokens revoked for sessions %v, want %v", tokens.revoked, keycloak.loggedOut)
+	}
+	if sessions, _ := service.ListSessions(ctx, "bob-id"); len(sessions) != 1 {
+		t.Errorf("ListSessions() for bob = %v, want his session kept", sessions)
+	}
+}
+
+func TestEndSession(t *testing.T) {
+	keycloak := newUpstream()
+	service := session.NewService(memory.NewRepository(), keycloak, 24*time.Hour)
+	tokens := &revoker{}
+	service.SetRevoker(tokens)
+	ctx := context.Background()
+
+	service.Login(ctx, "alice", "secret", laptop)
+
+	userID, err := service.EndSession(ctx, "sid-1")
+	if err != nil || userID != "alice-id" {
+		t.Errorf("EndSession() = %q, %v, want alice-id", userID, err)
+	}
+	if sessions, _ := service.ListSessions(ctx, "alice-id"); len(sessions) != 0 {
+		t.Errorf("ListSessions() = %v, want the session ended", sessions)
+	}
+
+	userID, err = service.EndSession(ctx, "sid-elsewhere")
+	if err != nil || userID != "" {
+		t.Errorf("EndSession() of unknown session = %q, %v, want no user", userID, err)
+	}
+	if want := []string{"sid-1", "sid-elsewhere"}; !slices.Equal(tokens.revoked, want) {
+		t.Errorf("access tokens revoked for sessions %v, want %v", tokens.revoked, want)
+	}
+	if len(keycloak.loggedOut) != 0 {
+		t.Errorf("Keycloak sessions logged out = %v, want none as they ended there", keycloak.loggedOut)
+	}
+}
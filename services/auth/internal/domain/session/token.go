@@ -0,0 +1,62 @@
+package session
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const keyBytes = 32
+
+// newKey returns a random key to sign a session's refresh tokens with
+func newKey() ([]byte, error) {
+	key := make([]byte, keyBytes)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("failed to generate session key: %w", err)
+	}
+	return key, nil
+}
+
+// formatRefreshToken returns the refresh token of a session's generation as
+// "<session ID>.<generation>.<signature>". Signing with a per-session key
+// lets the service tell a replayed old token from a forged one without
+// storing every token it has issued.
+func formatRefreshToken(id string, generation int, key []byte) string {
+	payload := id + "." + strconv.Itoa(generation)
+	return payload + "." + base64.RawURLEncoding.EncodeToString(sign(key, payload))
+}
+
+// parseRefreshToken splits a refresh token into its session ID, generation and signature
+func parseRefreshToken(token string) (id string, generation int, signature []byte, ok bool) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 || parts[0] == "" {
+		return "", 0, nil, false
+	}
+
+	generation, err := strconv.Atoi(parts[1])
+	if err != nil || generation < 0 {
+		return "", 0, nil, false
+	}
+
+	signature, err = base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return "", 0, nil, false
+	}
+
+	return parts[0], generation, signature, true
+}
+
+// validSignature reports whether signature was made with key for the generation
+func validSignature(key []byte, id string, generation int, signature []byte) bool {
+	return hmac.Equal(sign(key, id+"."+strconv.Itoa(generation)), signature)
+}
+
+func sign(key []byte, payload string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(payload))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,228 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/intellifinder/v4/services/auth/pkg/dto"
+	"github.com/intellifinder/v4/services/auth/pkg/models"
+)
+
+// CreateParams describes a new user. Users are created enabled and set their
+// password through Keycloak.
+type CreateParams struct {
+	Username  string
+	Email     string
+	FirstName string
+	LastName  string
+	Roles     []string
+}
+
+// UpdateParams changes the fields that are set
+type UpdateParams struct {
+	Email     *string
+	FirstName *string
+	LastName  *string
+}
+
+func (s *Service) ListUsers(ctx context.Context, filter dto.UserFilter, page int32, limit int32) (*dto.PaginatedUsers, error) {
+	if page <= 0 {
+		return nil, fmt.Errorf("%w: page must be greater than 0", ErrInvalidUser)
+	}
+
+	if limit <= 0 {
+		return nil, fmt.Errorf("%w: limit must be greater than 0", ErrInvalidUser)
+	}
+
+	users, err := s.repo.ListUsers(ctx, filter, page, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list users: %w", err)
+	}
+
+	return users, nil
+}
+
+// CreateUser creates the user in the directory and stores it locally right
+// away, so its local ID can be returned
+func (s *Service) CreateUser(ctx context.Context, params CreateParams) (*models.User, error) {
+	if s.directory == nil {
+		return nil, fmt.Errorf("no directory configured")
+	}
+
+	username := strings.TrimSpace(params.Username)
+	if username == "" {
+		return nil, fmt.Errorf("%w: username is required", ErrInvalidUser)
+	}
+	if models.ReservedUsername(username) {
+		return nil, fmt.Errorf("%w: usernames starting with %q are reserved for service accounts", ErrInvalidUser, models.ServiceAccountPrefix)
+	}
+
+	created, err := s.directory.CreateUser(ctx, &models.User{
+		Username:  username,
+		Email:     strings.ToLower(strings.TrimSpace(params.Email)),
+		FirstName: params.FirstName,
+		LastName:  params.LastName,
+		Enabled:   true,
+	}, params.Roles)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create directory user: %w", err)
+	}
+
+	if err := s.sync(ctx, created); err != nil {
+		return nil, err
+	}
+
+	return s.repo.GetUserByOIDCID(ctx, created.OIDCID)
+}
+
+// UpdateUser changes the user's profile in the directory and the local store
+func (s *Service) UpdateUser(ctx context.Context, id uuid.UUID, params UpdateParams) (*models.User, error) {
+	if s.directory == nil {
+		return nil, fmt.Errorf("no directory configured")
+	}
+
+	u, err := s.activeUser(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if params.Email != nil {
+		email := strings.ToLower(strings.TrimSpace(*params.Email))
+		if email == "" {
+			return nil, fmt.Errorf("%w: email must not be empty", ErrInvalidUser)
+		}
+		u.Email = email
+	}
+	if params.FirstName != nil {
+		u.FirstName = *params.FirstName
+	}
+	if params.LastName != nil {
+		u.LastName = *params.LastName
+	}
+
+	updated, err := s.directory.UpdateUser(ctx, u)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update directory user: %w", err)
+	}
+
+	if err := s.sync(ctx, updated); err != nil {
+		return nil, err
+	}
+
+	return s.repo.GetUser(ctx, id)
+}
+
+// DeleteUser deletes the user in the directory and marks it deleted locally.
+// A user already gone from the directory is only marked deleted.
+func (s *Service) DeleteUser(ctx context.Context, id uuid.UUID) error {
+	if s.directory == nil {
+		return fmt.Errorf("no directory configured")
+	}
+
+	u, err := s.activeUser(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if err := s.directory.DeleteUser(ctx, u.OIDCID); err != nil && !errors.Is(err, ErrUserNotFound) {
+		return fmt.Errorf("failed to delete directory user: %w", err)
+	}
+
+	if err := s.revokeAccess(ctx, u.OIDCID); err != nil {
+		return err
+	}
+
+	if _, err := s.repo.MarkUsersDeleted(ctx, []string{u.OIDCID}, s.now()); err != nil {
+		return fmt.Errorf("failed to delete user: %w", err)
+	}
+
+	return nil
+}
+
+// RevokeTokens revokes every access token the user holds, e.g. after their
+// credentials were compromised. Tokens issued afterwards are valid.
+func (s *Service) RevokeTokens(ctx context.Context, id uuid.UUID) error {
+	if s.revoker == nil {
+		return fmt.Errorf("token revocation is not enabled")
+	}
+
+	u, err := s.repo.GetUser(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	return s.revokeTokens(ctx, u.OIDCID)
+}
+
+// activeUser returns ErrUserNotFound for deleted users, which can be read but not changed
+func (s *Service) activeUser(ctx context.Context, id uuid.UUID) (*models.User, error) {
+	u, err := s.repo.GetUser(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if u.DeletedAt != nil {
+		return nil, ErrUserNotFound
+	}
+
+	return u, nil
+}
+
+// DeactivateUser disables the user in the directory and locally, ends their
+// sessions and revokes their API keys and access tokens. Deactivating a
+// disabled user revokes their access again.
+func (s *Service) DeactivateUser(ctx context.Context, id uuid.UUID) (*models.User, error) {
+	updated, err := s.setEnabled(ctx, id, false)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.revokeAccess(ctx, updated.OIDCID); err != nil {
+		return nil, err
+	}
+
+	if err := s.store(ctx, updated); err != nil {
+		return nil, err
+	}
+
+	return s.repo.GetUser(ctx, id)
+}
+
+// ActivateUser enables the user in the directory and locally. Tokens and API
+// keys revoked when the user was deactivated stay revoked.
+func (s *Service) ActivateUser(ctx context.Context, id uuid.UUID) (*models.User, error) {
+	updated, err := s.setEnabled(ctx, id, true)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.store(ctx, updated); err != nil {
+		return nil, err
+	}
+
+	return s.repo.GetUser(ctx, id)
+}
+
+// setEnabled enables or disables the user in the directory and returns the
+// directory user
+func (s *Service) setEnabled(ctx context.Context, id uuid.UUID, enabled bool) (*models.User, error) {
+	if s.directory == nil {
+		return nil, fmt.Errorf("no directory configured")
+	}
+
+	u, err := s.activeUser(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	u.Enabled = enabled
+	updated, err := s.directory.UpdateUser(ctx, u)
+	if err != nil {
+		return nil, fmt.Errorf("failed to update directory user: %w", err)
+	}
+
+	return updated, nil
+}
@@ -0,0 +1,17 @@
+package user
+
+import "errors"
+
+var (
+	ErrUserNotFound = errors.New("user not found")
+	// ErrUserExists is returned when creating a user whose username or email is taken
+	ErrUserExists = errors.New("user already exists")
+	// ErrInvalidUser is returned for user data the directory wouldn't accept
+	ErrInvalidUser = errors.New("invalid user")
+	// ErrUserDisabled is returned for changes that need the user to be enabled
+	ErrUserDisabled = errors.New("user is disabled")
+	// ErrTooManyUsers is returned for batch lookups above MaxBatchSize
+	ErrTooManyUsers = errors.New("too many users requested")
+	// ErrEmptyDirectory stops a reconciliation that would delete every local user
+	ErrEmptyDirectory = errors.New("directory returned no users")
+)
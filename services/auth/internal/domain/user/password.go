@@ -0,0 +1,82 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/intellifinder/v4/services/auth/pkg/models"
+)
+
+// ResetPassword emails the user a link to choose a new password. The
+// current password keeps working until they do.
+func (s *Service) ResetPassword(ctx context.Context, id uuid.UUID) error {
+	if s.directory == nil {
+		return fmt.Errorf("no directory configured")
+	}
+
+	u, err := s.activeUser(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if !u.Enabled {
+		return ErrUserDisabled
+	}
+
+	if u.Email == "" {
+		return fmt.Errorf("%w: user has no email", ErrInvalidUser)
+	}
+
+	return s.sendPasswordReset(ctx, u)
+}
+
+// ForgotPassword emails a password reset link to the user with the given
+// username or email. Unknown and disabled users, and users without an email,
+// are ignored, so callers can't tell which accounts exist.
+func (s *Service) ForgotPassword(ctx context.Context, login string) error {
+	if s.directory == nil {
+		return fmt.Errorf("no directory configured")
+	}
+
+	u, err := s.findByLogin(ctx, strings.TrimSpace(login))
+	if errors.Is(err, ErrUserNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get user: %w", err)
+	}
+
+	if !u.Enabled || u.Email == "" {
+		return nil
+	}
+
+	return s.sendPasswordReset(ctx, u)
+}
+
+// findByLogin looks the user up by email first if the login looks like one,
+// since usernames may contain @ too
+func (s *Service) findByLogin(ctx context.Context, login string) (*models.User, error) {
+	if login == "" {
+		return nil, ErrUserNotFound
+	}
+
+	if strings.Contains(login, "@") {
+		u, err := s.GetUserByEmail(ctx, login)
+		if !errors.Is(err, ErrUserNotFound) {
+			return u, err
+		}
+	}
+
+	return s.GetUserByUsername(ctx, login)
+}
+
+func (s *Service) sendPasswordReset(ctx context.Context, u *models.User) error {
+	if err := s.directory.SendPasswordReset(ctx, u.OIDCID); err != nil {
+		return fmt.Errorf("failed to send password reset: %w", err)
+	}
+
+	return nil
+}
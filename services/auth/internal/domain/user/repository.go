@@ -1 +1,69 @@
 package user
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/intellifinder/v4/services/auth/pkg/dto"
+	"github.com/intellifinder/v4/services/auth/pkg/models"
+)
+
+type Repository interface {
+	// UpsertUser creates the user or updates the profile of the user with the
+	// same OIDCID. A nil LastLoginAt keeps the stored one; deleted users stay deleted.
+	UpsertUser(ctx context.Context, user *models.User) error
+	// GetUser returns deleted users too, so references can always be resolved
+	GetUser(ctx context.Context, id uuid.UUID) (*models.User, error)
+	// GetUserByOIDCID, GetUserByEmail and GetUserByUsername only return users
+	// that haven't been deleted, and ErrUserNotFound otherwise
+	GetUserByOIDCID(ctx context.Context, oidcID string) (*models.User, error)
+	GetUserByEmail(ctx context.Context, email string) (*models.User, error)
+	GetUserByUsername(ctx context.Context, username string) (*models.User, error)
+	// GetUsers returns the users with the given IDs, deleted ones included;
+	// GetUsersByOIDCIDs only those that haven't been deleted. Unknown IDs are skipped.
+	GetUsers(ctx context.Context, ids []uuid.UUID) ([]*models.User, error)
+	GetUsersByOIDCIDs(ctx context.Context, oidcIDs []string) ([]*models.User, error)
+	// ListUsers returns a page of users ordered by username
+	ListUsers(ctx context.Context, filter dto.UserFilter, page int32, limit int32) (*dto.PaginatedUsers, error)
+	// ListActiveOIDCIDs returns the OIDC IDs of all users that haven't been deleted
+	ListActiveOIDCIDs(ctx context.Context) ([]string, error)
+	// MarkUsersDeleted deletes the given users and returns how many were deleted
+	MarkUsersDeleted(ctx context.Context, oidcIDs []string, deletedAt time.Time) (int, error)
+}
+
+// Directory is the identity provider users are synchronised from and
+// managed in. Users it returns have OIDCID set and ID unset.
+type Directory interface {
+	// ListUsers returns a page of users in a stable order
+	ListUsers(ctx context.Context, first int, max int) ([]*models.User, error)
+	// GetUser, UpdateUser and DeleteUser return ErrUserNotFound if the user doesn't exist
+	GetUser(ctx context.Context, oidcID string) (*models.User, error)
+	// CreateUser returns ErrUserExists if the username or email is taken
+	CreateUser(ctx context.Context, user *models.User, roles []string) (*models.User, error)
+	// UpdateUser replaces the email, names and enabled flag of the user with the same OIDCID
+	UpdateUser(ctx context.Context, user *models.User) (*models.User, error)
+	DeleteUser(ctx context.Context, oidcID string) error
+	// SendPasswordReset emails the user a link to choose a new password
+	SendPasswordReset(ctx context.Context, oidcID string) error
+}
+
+// Revoker revokes the access tokens of users who were disabled or deleted
+type Revoker interface {
+	RevokeUserTokens(ctx context.Context, userID string, issuedBefore time.Time) error
+}
+
+// SessionRevoker ends the sessions of users who were disabled or deleted
+type SessionRevoker interface {
+	RevokeAllSessions(ctx context.Context, userID string) (int, error)
+}
+
+// APIKeyRevoker revokes the API keys of users who were disabled or deleted
+type APIKeyRevoker interface {
+	RevokeUserAPIKeys(ctx context.Context, userID string) (int, error)
+}
+
+// Publisher tells other services about changes to users
+type Publisher interface {
+	PublishEvent(ctx context.Context, event *models.DomainEvent) error
+}
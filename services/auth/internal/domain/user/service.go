@@ -1 +1,385 @@
 package user
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/intellifinder/v4/services/auth/internal/domain/authentication"
+	"github.com/intellifinder/v4/services/auth/pkg/models"
+)
+
+const (
+	// directoryPageSize is how many users are requested from the directory at once
+	directoryPageSize = 100
+	// lastLoginResolution limits how often the last login of an active user is written
+	lastLoginResolution = time.Minute
+	// MaxBatchSize bounds the number of users a single GetUsers call looks up
+	MaxBatchSize = 500
+)
+
+var _ authentication.UserProvisioner = (*Service)(nil)
+
+type Service struct {
+	repo      Repository
+	directory Directory
+	revoker   Revoker
+	sessions  SessionRevoker
+	apiKeys   APIKeyRevoker
+	publisher Publisher
+	now       func() time.Time
+}
+
+func NewService(repo Repository) *Service {
+	return &Service{
+		repo: repo,
+		now:  time.Now,
+	}
+}
+
+// SetDirectory enables reconciliation against the identity provider
+func (s *Service) SetDirectory(directory Directory) {
+	s.directory = directory
+}
+
+// SetRevoker revokes the access tokens of users when they are disabled or
+// deleted, instead of letting them work until they expire
+func (s *Service) SetRevoker(revoker Revoker) {
+	s.revoker = revoker
+}
+
+// SetSessionRevoker ends the sessions of users when they are disabled or
+// deleted, so they can't get new access tokens
+func (s *Service) SetSessionRevoker(sessions SessionRevoker) {
+	s.sessions = sessions
+}
+
+// SetAPIKeyRevoker revokes the API keys of users when they are disabled or
+// deleted
+func (s *Service) SetAPIKeyRevoker(apiKeys APIKeyRevoker) {
+	s.apiKeys = apiKeys
+}
+
+// SetPublisher publishes the lockouts of users
+func (s *Service) SetPublisher(publisher Publisher) {
+	s.publisher = publisher
+}
+
+// NotifyLockout publishes that the account with the given username or email
+// was locked out after failed logins. Logins of unknown accounts are
+// ignored, so guessing at them doesn't publish anything.
+func (s *Service) NotifyLockout(ctx context.Context, login string, until time.Time) error {
+	if s.publisher == nil {
+		return nil
+	}
+
+	user, err := s.repo.GetUserByUsername(ctx, login)
+	if errors.Is(err, ErrUserNotFound) {
+		user, err = s.repo.GetUserByEmail(ctx, strings.ToLower(login))
+	}
+	if errors.Is(err, ErrUserNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get user %s: %w", login, err)
+	}
+
+	return s.publisher.PublishEvent(ctx, &models.DomainEvent{
+		ID:         uuid.NewString(),
+		Type:       models.UserLockedOut,
+		UserID:     user.OIDCID,
+		OccurredAt: s.now(),
+		ExpiresAt:  &until,
+	})
+}
+
+// ProvisionUser creates the local user of an access token on first login and
+// keeps its profile up to date with the token's claims afterwards. Whether
+// the user is enabled is left to reconciliation, so a token issued before the
+// user was disabled can't enable it again.
+func (s *Service) ProvisionUser(ctx context.Context, identity *models.Identity) error {
+	if identity.Method != models.AuthMethodJWT {
+		return nil
+	}
+
+	now := s.now()
+	user := &models.User{
+		OIDCID:      identity.Subject,
+		Username:    identity.Username,
+		Email:       strings.ToLower(identity.Email),
+		FirstName:   identity.FirstName,
+		LastName:    identity.LastName,
+		Enabled:     true,
+		LastLoginAt: &now,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+
+	existing, err := s.repo.GetUserByOIDCID(ctx, identity.Subject)
+	switch {
+	case errors.Is(err, ErrUserNotFound):
+	case err != nil:
+		return fmt.Errorf("failed to get user: %w", err)
+	case sameProfile(existing, user) && existing.LastLoginAt != nil && now.Sub(*existing.LastLoginAt) < lastLoginResolution:
+		// Most verifications are of tokens of users seen moments ago
+		return nil
+	default:
+		user.Enabled = existing.Enabled
+	}
+
+	if err := s.repo.UpsertUser(ctx, user); err != nil {
+		return fmt.Errorf("failed to upsert user: %w", err)
+	}
+
+	return nil
+}
+
+func sameProfile(a *models.User, b *models.User) bool {
+	return a.Username == b.Username && a.Email == b.Email && a.FirstName == b.FirstName && a.LastName == b.LastName
+}
+
+// SyncResult summarises a reconciliation
+type SyncResult struct {
+	Synced  int
+	Deleted int
+}
+
+// Reconcile updates every local user from the directory, creating users that
+// haven't logged in yet and marking users that no longer exist as deleted.
+// Users missing from the listing are looked up one by one before they are
+// deleted, since users created or deleted while the directory is being read
+// shift its pages.
+func (s *Service) Reconcile(ctx context.Context) (*SyncResult, error) {
+	if s.directory == nil {
+		return nil, fmt.Errorf("no directory configured")
+	}
+
+	result := &SyncResult{}
+	seen := make(map[string]bool)
+
+	for first := 0; ; first += directoryPageSize {
+		users, err := s.directory.ListUsers(ctx, first, directoryPageSize)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list directory users: %w", err)
+		}
+
+		for _, user := range users {
+			if err := s.sync(ctx, user); err != nil {
+				return nil, err
+			}
+			seen[user.OIDCID] = true
+			result.Synced++
+		}
+
+		if len(users) < directoryPageSize {
+			break
+		}
+	}
+
+	local, err := s.repo.ListActiveOIDCIDs(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list local users: %w", err)
+	}
+
+	var missing []string
+	for _, oidcID := range local {
+		if !seen[oidcID] {
+			missing = append(missing, oidcID)
+		}
+	}
+
+	if len(missing) == 0 {
+		return result, nil
+	}
+
+	// An empty realm is far more likely a misconfiguration than every user being deleted
+	if len(seen) == 0 {
+		return nil, fmt.Errorf("%w: refusing to delete %d local users", ErrEmptyDirectory, len(missing))
+	}
+
+	var deleted []string
+	for _, oidcID := range missing {
+		user, err := s.directory.GetUser(ctx, oidcID)
+		if errors.Is(err, ErrUserNotFound) {
+			if err := s.revokeAccess(ctx, oidcID); err != nil {
+				return nil, err
+			}
+			deleted = append(deleted, oidcID)
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to get directory user %s: %w", oidcID, err)
+		}
+
+		if err := s.sync(ctx, user); err != nil {
+			return nil, err
+		}
+		result.Synced++
+	}
+
+	if len(deleted) > 0 {
+		result.Deleted, err = s.repo.MarkUsersDeleted(ctx, deleted, s.now())
+		if err != nil {
+			return nil, fmt.Errorf("failed to delete users: %w", err)
+		}
+	}
+
+	return result, nil
+}
+
+// SyncUser updates one local user from the directory, e.g. after it was
+// changed there, and returns the domain event type of the change. Nothing
+// is written and "" returned when the user is unchanged.
+func (s *Service) SyncUser(ctx context.Context, oidcID string) (string, error) {
+	if s.directory == nil {
+		return "", fmt.Errorf("no directory configured")
+	}
+
+	existing, err := s.repo.GetUserByOIDCID(ctx, oidcID)
+	if err != nil && !errors.Is(err, ErrUserNotFound) {
+		return "", fmt.Errorf("failed to get user %s: %w", oidcID, err)
+	}
+
+	user, err := s.directory.GetUser(ctx, oidcID)
+	if errors.Is(err, ErrUserNotFound) {
+		if existing == nil {
+			return "", nil
+		}
+		if err := s.revokeAccess(ctx, oidcID); err != nil {
+			return "", err
+		}
+		if _, err := s.repo.MarkUsersDeleted(ctx, []string{oidcID}, s.now()); err != nil {
+			return "", fmt.Errorf("failed to delete user %s: %w", oidcID, err)
+		}
+		return models.UserDeleted, nil
+	}
+	if err != nil {
+		return "", fmt.Errorf("failed to get directory user %s: %w", oidcID, err)
+	}
+
+	user.Email = strings.ToLower(user.Email)
+	var change string
+	switch {
+	case existing == nil:
+		change = models.UserCreated
+	case existing.Enabled && !user.Enabled:
+		change = models.UserDisabled
+	case !existing.Enabled && user.Enabled:
+		change = models.UserEnabled
+	case !sameProfile(existing, user):
+		change = models.UserUpdated
+	default:
+		return "", nil
+	}
+
+	if err := s.sync(ctx, user); err != nil {
+		return "", err
+	}
+	return change, nil
+}
+
+// sync stores a directory user's profile without touching its last login.
+// Tokens and sessions of users found disabled are revoked before the user is
+// stored, so a failed revocation is retried by the next sync.
+func (s *Service) sync(ctx context.Context, user *models.User) error {
+	if !user.Enabled {
+		existing, err := s.repo.GetUserByOIDCID(ctx, user.OIDCID)
+		if err != nil && !errors.Is(err, ErrUserNotFound) {
+			return fmt.Errorf("failed to get user %s: %w", user.OIDCID, err)
+		}
+		if err != nil || existing.Enabled {
+			if err := s.revokeAccess(ctx, user.OIDCID); err != nil {
+				return err
+			}
+		}
+	}
+
+	return s.store(ctx, user)
+}
+
+// store upserts a directory user without touching its last login
+func (s *Service) store(ctx context.Context, user *models.User) error {
+	now := s.now()
+	user.Email = strings.ToLower(user.Email)
+	user.LastLoginAt = nil
+	user.CreatedAt = now
+	user.UpdatedAt = now
+
+	if err := s.repo.UpsertUser(ctx, user); err != nil {
+		return fmt.Errorf("failed to upsert user %s: %w", user.OIDCID, err)
+	}
+
+	return nil
+}
+
+// revokeTokens revokes every access token the user holds
+func (s *Service) revokeTokens(ctx context.Context, oidcID string) error {
+	if s.revoker == nil {
+		return nil
+	}
+
+	return s.revoker.RevokeUserTokens(ctx, oidcID, s.now())
+}
+
+// revokeAccess ends the user's sessions and revokes their API keys and
+// access tokens
+func (s *Service) revokeAccess(ctx context.Context, oidcID string) error {
+	if s.sessions != nil {
+		if _, err := s.sessions.RevokeAllSessions(ctx, oidcID); err != nil {
+			return fmt.Errorf("failed to revoke sessions of user %s: %w", oidcID, err)
+		}
+	}
+
+	if s.apiKeys != nil {
+		if _, err := s.apiKeys.RevokeUserAPIKeys(ctx, oidcID); err != nil {
+			return fmt.Errorf("failed to revoke API keys of user %s: %w", oidcID, err)
+		}
+	}
+
+	return s.revokeTokens(ctx, oidcID)
+}
+
+func (s *Service) GetUser(ctx context.Context, id uuid.UUID) (*models.User, error) {
+	return s.repo.GetUser(ctx, id)
+}
+
+func (s *Service) GetUserByOIDCID(ctx context.Context, oidcID string) (*models.User, error) {
+	return s.repo.GetUserByOIDCID(ctx, oidcID)
+}
+
+func (s *Service) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	return s.repo.GetUserByEmail(ctx, strings.ToLower(email))
+}
+
+func (s *Service) GetUserByUsername(ctx context.Context, username string) (*models.User, error) {
+	return s.repo.GetUserByUsername(ctx, username)
+}
+
+// GetUsers looks users up by local ID and OIDC ID in one call
+func (s *Service) GetUsers(ctx context.Context, ids []uuid.UUID, oidcIDs []string) ([]*models.User, error) {
+	if len(ids)+len(oidcIDs) > MaxBatchSize {
+		return nil, fmt.Errorf("%w: at most %d per call", ErrTooManyUsers, MaxBatchSize)
+	}
+
+	var users []*models.User
+	if len(ids) > 0 {
+		found, err := s.repo.GetUsers(ctx, ids)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get users: %w", err)
+		}
+		users = append(users, found...)
+	}
+
+	if len(oidcIDs) > 0 {
+		found, err := s.repo.GetUsersByOIDCIDs(ctx, oidcIDs)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get users by OIDC ID: %w", err)
+		}
+		users = append(users, found...)
+	}
+
+	return users, nil
+}
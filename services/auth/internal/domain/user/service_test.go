@@ -0,0 +1,508 @@
+package user_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/intellifinder/v4/services/auth/internal/domain/user"
+	"github.com/intellifinder/v4/services/auth/internal/infrastructure/memory"
+	"github.com/intellifinder/v4/services/auth/pkg/dto"
+	"github.com/intellifinder/v4/services/auth/pkg/models"
+)
+
+// directory serves a fixed set of users, sorted by OIDC ID
+type directory struct {
+	users map[string]models.User
+	// hidden users exist but are left out of listings, like users skipped
+	// when the pages shift during a reconciliation
+	hidden map[string]bool
+	// resets lists the users sent a password reset
+	resets []string
+}
+
+func (d *directory) ListUsers(_ context.Context, first int, max int) ([]*models.User, error) {
+	var ids []string
+	for id := range d.users {
+		if !d.hidden[id] {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+
+	var page []*models.User
+	for _, id := range ids[min(first, len(ids)):min(first+max, len(ids))] {
+		u := d.users[id]
+		page = append(page, &u)
+	}
+	return page, nil
+}
+
+func (d *directory) GetUser(_ context.Context, oidcID string) (*models.User, error) {
+	u, ok := d.users[oidcID]
+	if !ok {
+		return nil, user.ErrUserNotFound
+	}
+	return &u, nil
+}
+
+func (d *directory) CreateUser(_ context.Context, u *models.User, _ []string) (*models.User, error) {
+	for _, existing := range d.users {
+		if existing.Username == u.Username || existing.Email == u.Email {
+			return nil, user.ErrUserExists
+		}
+	}
+
+	if d.users == nil {
+		d.users = make(map[string]models.User)
+	}
+	created := *u
+	created.OIDCID = fmt.Sprintf("kc-%d", len(d.users)+1)
+	d.users[created.OIDCID] = created
+	return &created, nil
+}
+
+func (d *directory) UpdateUser(_ context.Context, u *models.User) (*models.User, error) {
+	existing, ok := d.users[u.OIDCID]
+	if !ok {
+		return nil, user.ErrUserNotFound
+	}
+
+	existing.Email, existing.FirstName, existing.LastName, existing.Enabled = u.Email, u.FirstName, u.LastName, u.Enabled
+	d.users[u.OIDCID] = existing
+	return &existing, nil
+}
+
+func (d *directory) DeleteUser(_ context.Context, oidcID string) error {
+	if _, ok := d.users[oidcID]; !ok {
+		return user.ErrUserNotFound
+	}
+	delete(d.users, oidcID)
+	return nil
+}
+
+func (d *directory) SendPasswordReset(_ context.Context, oidcID string) error {
+	if _, ok := d.users[oidcID]; !ok {
+		return user.ErrUserNotFound
+	}
+	d.resets = append(d.resets, oidcID)
+	return nil
+}
+
+func token(subject string, username string, email string) *models.Identity {
+	return &models.Identity{Subject: subject, Username: username, Email: email, Method: models.AuthMethodJWT}
+}
+
+func TestProvisionUser(t *testing.T) {
+	repo := memory.NewRepository()
+	service := user.NewService(repo)
+	now := time.Now()
+	user.SetNow(service, func() time.Time { return now })
+
+	ctx := context.Background()
+	if err := service.ProvisionUser(ctx, token("kc-1", "alice", "Alice@Example.com")); err != nil {
+		t.Fatalf("ProvisionUser() error = %v", err)
+	}
+
+	created, err := service.GetUserByEmail(ctx, "alice@example.com")
+	if err != nil {
+		t.Fatalf("GetUserByEmail() error = %v", err)
+	}
+	if created.OIDCID != "kc-1" || created.Username != "alice" || !created.Enabled || created.LastLoginAt == nil {
+		t.Errorf("provisioned user = %+v, want alice enabled with a last login", created)
+	}
+
+	// A rename in a later token updates the same local user
+	now = now.Add(10 * time.Second)
+	if err := service.ProvisionUser(ctx, token("kc-1", "alice.smith", "alice@example.com")); err != nil {
+		t.Fatalf("ProvisionUser() error = %v", err)
+	}
+	renamed, err := service.GetUserByUsername(ctx, "alice.smith")
+	if err != nil || renamed.ID != created.ID {
+		t.Errorf("GetUserByUsername() = %+v, %v, want the renamed user with ID %s", renamed, err, created.ID)
+	}
+	if _, err := service.GetUserByUsername(ctx, "alice"); !errors.Is(err, user.ErrUserNotFound) {
+		t.Errorf("GetUserByUsername() old name error = %v, want ErrUserNotFound", err)
+	}
+
+	// API keys carry no profile
+	if err := service.ProvisionUser(ctx, &models.Identity{Subject: "kc-2", Method: models.AuthMethodAPIKey}); err != nil {
+		t.Fatalf("ProvisionUser() API key error = %v", err)
+	}
+	if _, err := service.GetUserByOIDCID(ctx, "kc-2"); !errors.Is(err, user.ErrUserNotFound) {
+		t.Errorf("GetUserByOIDCID() for an API key error = %v, want ErrUserNotFound", err)
+	}
+}
+
+func TestProvisionUserKeepsDisabledUsersDisabled(t *testing.T) {
+	repo := memory.NewRepository()
+	service := user.NewService(repo)
+	ctx := context.Background()
+
+	repo.UpsertUser(ctx, &models.User{OIDCID: "kc-1", Username: "alice", Enabled: false})
+	if err := service.ProvisionUser(ctx, token("kc-1", "alice", "alice@example.com")); err != nil {
+		t.Fatalf("ProvisionUser() error = %v", err)
+	}
+
+	u, err := service.GetUserByOIDCID(ctx, "kc-1")
+	if err != nil || u.Enabled || u.Email != "alice@example.com" {
+		t.Errorf("GetUserByOIDCID() = %+v, %v, want the profile updated but the user still disabled", u, err)
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	repo := memory.NewRepository()
+	service := user.NewService(repo)
+	ctx := context.Background()
+
+	for _, id := range []string{"kc-1", "kc-2", "kc-3"} {
+		if err := service.ProvisionUser(ctx, token(id, "user-"+id, id+"@example.com")); err != nil {
+			t.Fatalf("ProvisionUser() error = %v", err)
+		}
+	}
+	deletedUser, _ := service.GetUserByOIDCID(ctx, "kc-2")
+
+	dir := &directory{
+		users: map[string]models.User{
+			"kc-1": {OIDCID: "kc-1", Username: "renamed", Email: "New@Example.com", Enabled: true},
+			"kc-3": {OIDCID: "kc-3", Username: "user-kc-3", Email: "kc-3@example.com", Enabled: false},
+			"kc-4": {OIDCID: "kc-4", Username: "never-logged-in", Enabled: true},
+		},
+		hidden: map[string]bool{"kc-3": true},
+	}
+	service.SetDirectory(dir)
+
+	result, err := service.Reconcile(ctx)
+	if err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	if result.Synced != 3 || result.Deleted != 1 {
+		t.Errorf("Reconcile() = %+v, want 3 synced and 1 deleted", result)
+	}
+
+	if u, err := service.GetUserByEmail(ctx, "new@example.com"); err != nil || u.Username != "renamed" {
+		t.Errorf("GetUserByEmail() = %+v, %v, want the renamed user", u, err)
+	}
+	if u, err := service.GetUserByOIDCID(ctx, "kc-3"); err != nil || u.Enabled {
+		t.Errorf("GetUserByOIDCID(kc-3) = %+v, %v, want the user missing from the listing kept and disabled", u, err)
+	}
+	if _, err := service.GetUserByUsername(ctx, "never-logged-in"); err != nil {
+		t.Errorf("GetUserByUsername() error = %v, want users created before their first login", err)
+	}
+
+	if _, err := service.GetUserByOIDCID(ctx, "kc-2"); !errors.Is(err, user.ErrUserNotFound) {
+		t.Errorf("GetUserByOIDCID(kc-2) error = %v, want the deleted user hidden", err)
+	}
+	if u, err := service.GetUser(ctx, deletedUser.ID); err != nil || u.DeletedAt == nil {
+		t.Errorf("GetUser() = %+v, %v, want the deleted user still resolvable by ID", u, err)
+	}
+}
+
+func TestReconcileRefusesEmptyDirectory(t *testing.T) {
+	service := user.NewService(memory.NewRepository())
+	ctx := context.Background()
+
+	service.ProvisionUser(ctx, token("kc-1", "alice", "alice@example.com"))
+	service.SetDirectory(&directory{})
+
+	if _, err := service.Reconcile(ctx); !errors.Is(err, user.ErrEmptyDirectory) {
+		t.Errorf("Reconcile() error = %v, want ErrEmptyDirectory", err)
+	}
+	if _, err := service.GetUserByOIDCID(ctx, "kc-1"); err != nil {
+		t.Errorf("GetUserByOIDCID() error = %v, want the user kept", err)
+	}
+}
+
+func TestUserAdministration(t *testing.T) {
+	service := user.NewService(memory.NewRepository())
+	dir := &directory{}
+	service.SetDirectory(dir)
+	ctx := context.Background()
+
+	created, err := service.CreateUser(ctx, user.CreateParams{Username: " alice ", Email: "Alice@Example.com", FirstName: "Alice"})
+	if err != nil {
+		t.Fatalf("CreateUser() error = %v", err)
+	}
+	if created.ID == uuid.Nil || created.Username != "alice" || created.Email != "alice@example.com" || !created.Enabled {
+		t.Errorf("CreateUser() = %+v, want alice stored locally and enabled", created)
+	}
+
+	if _, err := service.CreateUser(ctx, user.CreateParams{Username: "alice", Email: "other@example.com"}); !errors.Is(err, user.ErrUserExists) {
+		t.Errorf("CreateUser() duplicate error = %v, want ErrUserExists", err)
+	}
+	if _, err := service.CreateUser(ctx, user.CreateParams{Username: "  "}); !errors.Is(err, user.ErrInvalidUser) {
+		t.Errorf("CreateUser() without username error = %v, want ErrInvalidUser", err)
+	}
+	if _, err := service.CreateUser(ctx, user.CreateParams{Username: "Service-Account-tasks"}); !errors.Is(err, user.ErrInvalidUser) {
+		t.Errorf("CreateUser() with a service account name error = %v, want ErrInvalidUser", err)
+	}
+
+	lastName := "Smith"
+	updated, err := service.UpdateUser(ctx, created.ID, user.UpdateParams{LastName: &lastName})
+	if err != nil {
+		t.Fatalf("UpdateUser() error = %v", err)
+	}
+	if updated.FirstName != "Alice" || updated.LastName != "Smith" || dir.users[created.OIDCID].LastName != "Smith" {
+		t.Errorf("UpdateUser() = %+v, want only the last name changed, locally and in the directory", updated)
+	}
+
+	page, err := service.ListUsers(ctx, dto.UserFilter{Search: "SMITH"}, 1, 10)
+	if err != nil || page.TotalCount != 1 || page.Users[0].ID != created.ID {
+		t.Errorf("ListUsers() = %+v, %v, want alice", page, err)
+	}
+
+	if err := service.DeleteUser(ctx, created.ID); err != nil {
+		t.Fatalf("DeleteUser() error = %v", err)
+	}
+	if _, ok := dir.users[created.OIDCID]; ok {
+		t.Errorf("DeleteUser() kept the directory user")
+	}
+	if u, err := service.GetUser(ctx, created.ID); err != nil || u.DeletedAt == nil {
+		t.Errorf("GetUser() = %+v, %v, want the user marked deleted", u, err)
+	}
+	if _, err := service.UpdateUser(ctx, created.ID, user.UpdateParams{LastName: &lastName}); !errors.Is(err, user.ErrUserNotFound) {
+		t.Errorf("UpdateUser() of a deleted user error = %v, want ErrUserNotFound", err)
+	}
+
+	page, err = service.ListUsers(ctx, dto.UserFilter{IncludeDeleted: true}, 1, 10)
+	if err != nil || page.TotalCount != 1 {
+		t.Errorf("ListUsers() including deleted = %+v, %v, want alice", page, err)
+	}
+}
+
+// revoker records whose tokens and API keys were revoked and whose sessions
+// were ended
+type revoker struct {
+	revoked []string
+	ended   []string
+	keys    []string
+}
+
+func (r *revoker) RevokeUserTokens(_ context.Context, userID string, _ time.Time) error {
+	r.revoked = append(r.revoked, userID)
+	return nil
+}
+
+func (r *revoker) RevokeAllSessions(_ context.Context, userID string) (int, error) {
+	r.ended = append(r.ended, userID)
+	return 1, nil
+}
+
+func (r *revoker) RevokeUserAPIKeys(_ context.Context, userID string) (int, error) {
+	r.keys = append(r.keys, userID)
+	return 1, nil
+}
+
+func TestSyncUser(t *testing.T) {
+	repo := memory.NewRepository()
+	service := user.NewService(repo)
+	dir := &directory{users: map[string]models.User{
+		"kc-1": {OIDCID: "kc-1", Username: "alice", Email: "alice@example.com", Enabled: true},
+	}}
+	service.SetDirectory(dir)
+	tokens := &revoker{}
+	service.SetRevoker(tokens)
+
+	ctx := context.Background()
+	sync := func(oidcID string, want string) {
+		t.Helper()
+		change, err := service.SyncUser(ctx, oidcID)
+		if err != nil {
+			t.Fatalf("SyncUser(%s) error = %v", oidcID, err)
+		}
+		if change != want {
+			t.Errorf("SyncUser(%s) = %q, want %q", oidcID, change, want)
+		}
+	}
+
+	sync("kc-1", models.UserCreated)
+	sync("kc-1", "")
+	sync("kc-unknown", "")
+
+	alice := dir.users["kc-1"]
+	alice.Email = "Alice@Corp.example.com"
+	dir.users["kc-1"] = alice
+	sync("kc-1", models.UserUpdated)
+	if u, _ := service.GetUserByOIDCID(ctx, "kc-1"); u.Email != "alice@corp.example.com" {
+		t.Errorf("email = %q, want the new one in lower case", u.Email)
+	}
+
+	alice.Enabled = false
+	dir.users["kc-1"] = alice
+	sync("kc-1", models.UserDisabled)
+	alice.Enabled = true
+	dir.users["kc-1"] = alice
+	sync("kc-1", models.UserEnabled)
+
+	delete(dir.users, "kc-1")
+	sync("kc-1", models.UserDeleted)
+	sync("kc-1", "")
+	if _, err := service.GetUserByOIDCID(ctx, "kc-1"); !errors.Is(err, user.ErrUserNotFound) {
+		t.Errorf("GetUserByOIDCID() of deleted user error = %v, want ErrUserNotFound", err)
+	}
+	if want := []string{"kc-1", "kc-1"}; !slices.Equal(tokens.revoked, want) {
+		t.Errorf("revoked tokens of %v, want %v when disabled and deleted", tokens.revoked, want)
+	}
+}
+
+func TestDisabledAndDeletedUsersLoseTheirTokens(t *testing.T) {
+	repo := memory.NewRepository()
+	service := user.NewService(repo)
+	dir := &directory{users: map[string]models.User{
+		"kc-1": {OIDCID: "kc-1", Username: "alice", Enabled: true},
+		"kc-2": {OIDCID: "kc-2", Username: "bob", Enabled: true},
+		"kc-3": {OIDCID: "kc-3", Username: "carol", Enabled: true},
+	}}
+	service.SetDirectory(dir)
+	tokens := &revoker{}
+	service.SetRevoker(tokens)
+
+	ctx := context.Background()
+	if _, err := service.Reconcile(ctx); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	if len(tokens.revoked) != 0 {
+		t.Errorf("revoked tokens of %v, want none for enabled users", tokens.revoked)
+	}
+
+	bob := dir.users["kc-2"]
+	bob.Enabled = false
+	dir.users["kc-2"] = bob
+	delete(dir.users, "kc-3")
+
+	for range 2 {
+		if _, err := service.Reconcile(ctx); err != nil {
+			t.Fatalf("Reconcile() error = %v", err)
+		}
+	}
+	if want := []string{"kc-2", "kc-3"}; !slices.Equal(tokens.revoked, want) {
+		t.Errorf("revoked tokens of %v, want %v once each", tokens.revoked, want)
+	}
+
+	alice, _ := service.GetUserByOIDCID(ctx, "kc-1")
+	if err := service.DeleteUser(ctx, alice.ID); err != nil {
+		t.Fatalf("DeleteUser() error = %v", err)
+	}
+	if err := service.RevokeTokens(ctx, alice.ID); err != nil {
+		t.Fatalf("RevokeTokens() error = %v", err)
+	}
+	if want := []string{"kc-2", "kc-3", "kc-1", "kc-1"}; !slices.Equal(tokens.revoked, want) {
+		t.Errorf("revoked tokens of %v, want %v", tokens.revoked, want)
+	}
+
+	if err := service.RevokeTokens(ctx, uuid.New()); !errors.Is(err, user.ErrUserNotFound) {
+		t.Errorf("RevokeTokens() of an unknown user error = %v, want ErrUserNotFound", err)
+	}
+}
+
+func TestDeactivateAndActivateUser(t *testing.T) {
+	service := user.NewService(memory.NewRepository())
+	dir := &directory{}
+	service.SetDirectory(dir)
+	access := &revoker{}
+	service.SetRevoker(access)
+	service.SetSessionRevoker(access)
+	service.SetAPIKeyRevoker(access)
+	ctx := context.Background()
+
+	alice, _ := service.CreateUser(ctx, user.CreateParams{Username: "alice", Email: "alice@example.com"})
+
+	deactivated, err := service.DeactivateUser(ctx, alice.ID)
+	if err != nil {
+		t.Fatalf("DeactivateUser() error = %v", err)
+	}
+	if deactivated.Enabled || dir.users[alice.OIDCID].Enabled {
+		t.Errorf("DeactivateUser() = %+v, want the user disabled locally and in the directory", deactivated)
+	}
+	if !slices.Equal(access.ended, []string{alice.OIDCID}) || !slices.Equal(access.revoked, []string{alice.OIDCID}) {
+		t.Errorf("ended sessions of %v and revoked tokens of %v, want alice's once", access.ended, access.revoked)
+	}
+	if !slices.Equal(access.keys, []string{alice.OIDCID}) {
+		t.Errorf("revoked API keys of %v, want alice's once", access.keys)
+	}
+
+	activated, err := service.ActivateUser(ctx, alice.ID)
+	if err != nil {
+		t.Fatalf("ActivateUser() error = %v", err)
+	}
+	if !activated.Enabled || !dir.users[alice.OIDCID].Enabled {
+		t.Errorf("ActivateUser() = %+v, want the user enabled locally and in the directory", activated)
+	}
+	if len(access.ended) != 1 || len(access.revoked) != 1 || len(access.keys) != 1 {
+		t.Errorf("ActivateUser() revoked access, want it left alone")
+	}
+
+	if err := service.DeleteUser(ctx, alice.ID); err != nil {
+		t.Fatalf("DeleteUser() error = %v", err)
+	}
+	if !slices.Equal(access.ended, []string{alice.OIDCID, alice.OIDCID}) || !slices.Equal(access.keys, []string{alice.OIDCID, alice.OIDCID}) {
+		t.Errorf("ended sessions of %v and revoked API keys of %v, want alice's again on deletion", access.ended, access.keys)
+	}
+	if _, err := service.ActivateUser(ctx, alice.ID); !errors.Is(err, user.ErrUserNotFound) {
+		t.Errorf("ActivateUser() of a deleted user error = %v, want ErrUserNotFound", err)
+	}
+}
+
+func TestPasswordReset(t *testing.T) {
+	service := user.NewService(memory.NewRepository())
+	dir := &directory{}
+	service.SetDirectory(dir)
+	ctx := context.Background()
+
+	alice, _ := service.CreateUser(ctx, user.CreateParams{Username: "alice", Email: "alice@example.com"})
+	bob, _ := service.CreateUser(ctx, user.CreateParams{Username: "bob@home", Email: "bob@example.com"})
+	carol, _ := service.CreateUser(ctx, user.CreateParams{Username: "carol"})
+	dave, _ := service.CreateUser(ctx, user.CreateParams{Username: "dave", Email: "dave@example.com"})
+	service.DeactivateUser(ctx, dave.ID)
+
+	if err := service.ResetPassword(ctx, alice.ID); err != nil {
+		t.Errorf("ResetPassword() error = %v", err)
+	}
+	if err := service.ResetPassword(ctx, carol.ID); !errors.Is(err, user.ErrInvalidUser) {
+		t.Errorf("ResetPassword() of a user without email error = %v, want ErrInvalidUser", err)
+	}
+	if err := service.ResetPassword(ctx, dave.ID); !errors.Is(err, user.ErrUserDisabled) {
+		t.Errorf("ResetPassword() of a disabled user error = %v, want ErrUserDisabled", err)
+	}
+
+	for _, login := range []string{"ALICE@example.com", " bob@home ", "carol", "dave", "nobody", "nobody@example.com", ""} {
+		if err := service.ForgotPassword(ctx, login); err != nil {
+			t.Errorf("ForgotPassword(%q) error = %v", login, err)
+		}
+	}
+	if want := []string{alice.OIDCID, alice.OIDCID, bob.OIDCID}; !slices.Equal(dir.resets, want) {
+		t.Errorf("password resets sent to %v, want %v", dir.resets, want)
+	}
+}
+
+func TestNotifyLockout(t *testing.T) {
+	repo := memory.NewRepository()
+	service := user.NewService(repo)
+	service.SetDirectory(&directory{})
+	service.SetPublisher(repo)
+	ctx := context.Background()
+
+	alice, _ := service.CreateUser(ctx, user.CreateParams{Username: "alice", Email: "alice@example.com"})
+	until := time.Now().Add(time.Minute)
+
+	for _, login := range []string{"alice", "Alice@Example.com", "nobody"} {
+		if err := service.NotifyLockout(ctx, login, until); err != nil {
+			t.Fatalf("NotifyLockout(%s) error = %v", login, err)
+		}
+	}
+
+	published := repo.PublishedEvents()
+	if len(published) != 2 {
+		t.Fatalf("published %d events, want 2 for alice and none for unknown accounts", len(published))
+	}
+	for _, event := range published {
+		if event.Type != models.UserLockedOut || event.UserID != alice.OIDCID || event.ExpiresAt == nil || !event.ExpiresAt.Equal(until) {
+			t.Errorf("published %+v, want alice locked out until %v", event, until)
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/intellifinder/v4/services/auth/internal/domain/apikey"
+	"github.com/intellifinder/v4/services/auth/pkg/models"
+	"github.com/jackc/pgx/v5"
+)
+
+var _ apikey.Repository = (*Repository)(nil)
+
+func (r *Repository) CreateAPIKey(ctx context.Context, key *models.APIKey) error {
+	err := r.db.QueryRow(ctx, insertAPIKey,
+		key.Name,
+		key.UserID,
+		key.TenantID,
+		key.Prefix,
+		key.Salt,
+		key.Hash,
+		key.Scopes,
+		key.Roles,
+		key.ExpiresAt,
+		key.CreatedAt,
+		key.UpdatedAt,
+	).Scan(&key.ID)
+	if err != nil {
+		return fmt.Errorf("failed to insert API key: %w", err)
+	}
+
+	return nil
+}
+
+func (r *Repository) GetAPIKey(ctx context.Context, id uuid.UUID) (*models.APIKey, error) {
+	return r.getAPIKey(ctx, getAPIKey, id)
+}
+
+func (r *Repository) GetAPIKeyByPrefix(ctx context.Context, prefix string) (*models.APIKey, error) {
+	return r.getAPIKey(ctx, getAPIKeyByPrefix, prefix)
+}
+
+func (r *Repository) getAPIKey(ctx context.Context, query string, arg any) (*models.APIKey, error) {
+	rows, err := r.db.Query(ctx, query, arg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get API key: %w", err)
+	}
+	defer rows.Close()
+
+	key, err := pgx.CollectOneRow(rows, pgx.RowToAddrOfStructByName[models.APIKey])
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, apikey.ErrAPIKeyNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan API key: %w", err)
+	}
+
+	return key, nil
+}
+
+func (r *Repository) ListAPIKeys(ctx context.Context, userID string) ([]*models.APIKey, error) {
+	rows, err := r.db.Query(ctx, listAPIKeys, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list API keys: %w", err)
+	}
+	defer rows.Close()
+
+	keys, err := pgx.CollectRows(rows, pgx.RowToAddrOfStructByName[models.APIKey])
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan API keys: %w", err)
+	}
+
+	return keys, nil
+}
+
+func (r *Repository) UpdateAPIKeySecret(ctx context.Context, id uuid.UUID, salt []byte, hash []byte, updatedAt time.Time) error {
+	tag, err := r.db.Exec(ctx, updateAPIKeySecret, id, salt, hash, updatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to update API key secret: %w", err)
+	}
+
+	// The key was revoked after the caller last read it
+	if tag.RowsAffected() == 0 {
+		return apikey.ErrAPIKeyRevoked
+	}
+
+	return nil
+}
+
+func (r *Repository) RevokeAPIKey(ctx context.Context, id uuid.UUID, revokedAt time.Time) error {
+	tag, err := r.db.Exec(ctx, revokeAPIKey, id, revokedAt)
+	if err != nil {
+		return fmt.Errorf("failed to revoke API key: %w", err)
+	}
+
+	if tag.RowsAffected() == 0 {
+		return apikey.ErrAPIKeyNotFound
+	}
+
+	return nil
+}
+
+func (r *Repository) RevokeUserAPIKeys(ctx context.Context, userID string, revokedAt time.Time) (int, error) {
+	tag, err := r.db.Exec(ctx, revokeUserAPIKeys, userID, revokedAt)
+	if err != nil {
+		return 0, fmt.Errorf("failed to revoke API keys: %w", err)
+	}
+
+	return int(tag.RowsAffected()), nil
+}
+
+func (r *Repository) TouchAPIKey(ctx context.Context, id uuid.UUID, usedAt time.Time) error {
+	if _, err := r.db.Exec(ctx, touchAPIKey, id, usedAt); err != nil {
+		return fmt.Errorf("failed to update API key last use: %w", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,106 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/intellifinder/v4/services/auth/internal/domain/impersonation"
+	"github.com/intellifinder/v4/services/auth/pkg/dto"
+	"github.com/intellifinder/v4/services/auth/pkg/models"
+	"github.com/jackc/pgx/v5"
+)
+
+var _ impersonation.Repository = (*Repository)(nil)
+
+func (r *Repository) CreateImpersonation(ctx context.Context, i *models.Impersonation) error {
+	_, err := r.db.Exec(ctx, insertImpersonation,
+		i.ID,
+		i.ActorID,
+		i.ActorUsername,
+		i.TargetID,
+		i.TargetUsername,
+		i.TenantID,
+		i.Reason,
+		i.StartedAt,
+		i.ExpiresAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to insert impersonation: %w", err)
+	}
+
+	return nil
+}
+
+func (r *Repository) GetImpersonation(ctx context.Context, id uuid.UUID) (*models.Impersonation, error) {
+	rows, err := r.db.Query(ctx, getImpersonation, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get impersonation: %w", err)
+	}
+	defer rows.Close()
+
+	i, err := pgx.CollectOneRow(rows, pgx.RowToAddrOfStructByName[models.Impersonation])
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, impersonation.ErrImpersonationNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan impersonation: %w", err)
+	}
+
+	return i, nil
+}
+
+func (r *Repository) EndImpersonation(ctx context.Context, id uuid.UUID, endedAt time.Time) error {
+	if _, err := r.db.Exec(ctx, endImpersonation, id, endedAt); err != nil {
+		return fmt.Errorf("failed to end impersonation: %w", err)
+	}
+
+	return nil
+}
+
+func (r *Repository) ListImpersonations(ctx context.Context, filter dto.ImpersonationFilter) ([]*models.Impersonation, error) {
+	rows, err := r.db.Query(ctx, listImpersonations, filter.ActorID, filter.TargetID, filter.Limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list impersonations: %w", err)
+	}
+	defer rows.Close()
+
+	list, err := pgx.CollectRows(rows, pgx.RowToAddrOfStructByName[models.Impersonation])
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan impersonations: %w", err)
+	}
+
+	return list, nil
+}
+
+func (r *Repository) AddImpersonationEvent(ctx context.Context, event *models.ImpersonationEvent) error {
+	err := r.db.QueryRow(ctx, insertImpersonationEvent,
+		event.ImpersonationID,
+		event.Type,
+		event.Method,
+		event.Path,
+		event.CreatedAt,
+	).Scan(&event.ID)
+	if err != nil {
+		return fmt.Errorf("failed to insert impersonation event: %w", err)
+	}
+
+	return nil
+}
+
+func (r *Repository) ListImpersonationEvents(ctx context.Context, impersonationID uuid.UUID) ([]*models.ImpersonationEvent, error) {
+	rows, err := r.db.Query(ctx, listImpersonationEvents, impersonationID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list impersonation events: %w", err)
+	}
+	defer rows.Close()
+
+	events, err := pgx.CollectRows(rows, pgx.RowToAddrOfStructByName[models.ImpersonationEvent])
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan impersonation events: %w", err)
+	}
+
+	return events, nil
+}
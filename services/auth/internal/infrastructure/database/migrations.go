@@ -1 +1,73 @@
 package database
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+func CreateAPIKeyTable(ctx context.Context, db *pgxpool.Pool) error {
+	_, err := db.Exec(ctx, createAPIKeyTable)
+	if err != nil {
+		return fmt.Errorf("failed to create API key table: %w", err)
+	}
+
+	_, err = db.Exec(ctx, createAPIKeyIndex)
+	if err != nil {
+		return fmt.Errorf("failed to create API key indexes: %w", err)
+	}
+
+	return nil
+}
+
+func CreateUserTable(ctx context.Context, db *pgxpool.Pool) error {
+	_, err := db.Exec(ctx, createUserTable)
+	if err != nil {
+		return fmt.Errorf("failed to create user table: %w", err)
+	}
+
+	_, err = db.Exec(ctx, createUserIndex)
+	if err != nil {
+		return fmt.Errorf("failed to create user indexes: %w", err)
+	}
+
+	return nil
+}
+
+func CreateImpersonationTables(ctx context.Context, db *pgxpool.Pool) error {
+	_, err := db.Exec(ctx, createImpersonationTables)
+	if err != nil {
+		return fmt.Errorf("failed to create impersonation tables: %w", err)
+	}
+
+	_, err = db.Exec(ctx, createImpersonationIndex)
+	if err != nil {
+		return fmt.Errorf("failed to create impersonation indexes: %w", err)
+	}
+
+	return nil
+}
+
+func CreateSCIMTables(ctx context.Context, db *pgxpool.Pool) error {
+	_, err := db.Exec(ctx, createSCIMTables)
+	if err != nil {
+		return fmt.Errorf("failed to create SCIM tables: %w", err)
+	}
+
+	_, err = db.Exec(ctx, createSCIMIndex)
+	if err != nil {
+		return fmt.Errorf("failed to create SCIM indexes: %w", err)
+	}
+
+	return nil
+}
+
+func CreateProfileTables(ctx context.Context, db *pgxpool.Pool) error {
+	_, err := db.Exec(ctx, createProfileTables)
+	if err != nil {
+		return fmt.Errorf("failed to create profile tables: %w", err)
+	}
+
+	return nil
+}
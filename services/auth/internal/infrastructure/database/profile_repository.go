@@ -0,0 +1,89 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/intellifinder/v4/services/auth/internal/domain/profile"
+	"github.com/intellifinder/v4/services/auth/pkg/models"
+	"github.com/jackc/pgx/v5"
+)
+
+var _ profile.Repository = (*Repository)(nil)
+
+func (r *Repository) GetProfile(ctx context.Context, userID uuid.UUID) (*models.Profile, error) {
+	rows, err := r.db.Query(ctx, getProfile, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get profile: %w", err)
+	}
+	defer rows.Close()
+
+	p, err := pgx.CollectOneRow(rows, pgx.RowToAddrOfStructByName[models.Profile])
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, profile.ErrProfileNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan profile: %w", err)
+	}
+
+	return p, nil
+}
+
+func (r *Repository) UpsertProfile(ctx context.Context, p *models.Profile) error {
+	_, err := r.db.Exec(ctx, upsertProfile,
+		p.UserID,
+		p.DisplayName,
+		p.AvatarURL,
+		p.Locale,
+		p.TimeZone,
+		p.Notifications,
+		p.DefaultLocation,
+		p.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to upsert profile: %w", err)
+	}
+
+	return nil
+}
+
+func (r *Repository) GetPreferences(ctx context.Context, userID uuid.UUID, clientID string) (*models.Preferences, error) {
+	rows, err := r.db.Query(ctx, getPreferences, userID, clientID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get preferences: %w", err)
+	}
+	defer rows.Close()
+
+	p, err := pgx.CollectOneRow(rows, pgx.RowToAddrOfStructByName[models.Preferences])
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, profile.ErrPreferencesNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan preferences: %w", err)
+	}
+
+	return p, nil
+}
+
+func (r *Repository) UpsertPreferences(ctx context.Context, p *models.Preferences) error {
+	_, err := r.db.Exec(ctx, upsertPreferences, p.UserID, p.ClientID, p.Data, p.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to upsert preferences: %w", err)
+	}
+
+	return nil
+}
+
+func (r *Repository) DeletePreferences(ctx context.Context, userID uuid.UUID, clientID string) error {
+	tag, err := r.db.Exec(ctx, deletePreferences, userID, clientID)
+	if err != nil {
+		return fmt.Errorf("failed to delete preferences: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return profile.ErrPreferencesNotFound
+	}
+
+	return nil
+}
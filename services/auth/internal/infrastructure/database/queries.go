@@ -1 +1,426 @@
 package database
+
+const (
+	createAPIKeyTable = `
+		CREATE TABLE IF NOT EXISTS api_keys (
+			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			name VARCHAR(255) NOT NULL,
+			user_id VARCHAR(255) NOT NULL,
+			tenant_id VARCHAR(255) NOT NULL DEFAULT '',
+			prefix VARCHAR(64) NOT NULL UNIQUE,
+			salt BYTEA NOT NULL,
+			hash BYTEA NOT NULL,
+			scopes TEXT[] NOT NULL DEFAULT '{}',
+			roles TEXT[] NOT NULL DEFAULT '{}',
+			expires_at TIMESTAMP,
+			last_used_at TIMESTAMP,
+			revoked_at TIMESTAMP,
+			created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)
+	`
+
+	createAPIKeyIndex = `
+		CREATE INDEX IF NOT EXISTS idx_api_keys_user_id ON api_keys (user_id);
+	`
+
+	insertAPIKey = `
+		INSERT INTO api_keys (name, user_id, tenant_id, prefix, salt, hash, scopes, roles, expires_at, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+		RETURNING id
+	`
+
+	selectAPIKeys = `
+		SELECT id, name, user_id, tenant_id, prefix, salt, hash, scopes, roles,
+			expires_at, last_used_at, revoked_at, created_at, updated_at
+		FROM api_keys
+	`
+
+	getAPIKey = selectAPIKeys + `
+		WHERE id = $1
+	`
+
+	getAPIKeyByPrefix = selectAPIKeys + `
+		WHERE prefix = $1
+	`
+
+	listAPIKeys = selectAPIKeys + `
+		WHERE user_id = $1
+		ORDER BY created_at DESC
+	`
+
+	updateAPIKeySecret = `
+		UPDATE api_keys
+		SET salt = $2, hash = $3, updated_at = $4
+		WHERE id = $1 AND revoked_at IS NULL
+	`
+
+	revokeAPIKey = `
+		UPDATE api_keys
+		SET revoked_at = COALESCE(revoked_at, $2), updated_at = $2
+		WHERE id = $1
+	`
+
+	revokeUserAPIKeys = `
+		UPDATE api_keys
+		SET revoked_at = $2, updated_at = $2
+		WHERE user_id = $1 AND revoked_at IS NULL
+	`
+
+	touchAPIKey = `
+		UPDATE api_keys
+		SET last_used_at = $2
+		WHERE id = $1
+	`
+
+	// oidc_id is the only unique column: usernames and emails can be swapped
+	// between users in Keycloak, and would collide until both are synced
+	createUserTable = `
+		CREATE TABLE IF NOT EXISTS users (
+			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			oidc_id VARCHAR(255) NOT NULL UNIQUE,
+			username VARCHAR(255) NOT NULL,
+			email VARCHAR(255) NOT NULL DEFAULT '',
+			first_name VARCHAR(255) NOT NULL DEFAULT '',
+			last_name VARCHAR(255) NOT NULL DEFAULT '',
+			enabled BOOLEAN NOT NULL DEFAULT TRUE,
+			last_login_at TIMESTAMP,
+			created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			deleted_at TIMESTAMP
+		)
+	`
+
+	createUserIndex = `
+		CREATE INDEX IF NOT EXISTS idx_users_username ON users (username) WHERE deleted_at IS NULL;
+		CREATE INDEX IF NOT EXISTS idx_users_email ON users (email) WHERE deleted_at IS NULL;
+	`
+
+	// Rows are only rewritten when the profile changed or a login is recorded
+	upsertUser = `
+		INSERT INTO users (oidc_id, username, email, first_name, last_name, enabled, last_login_at, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+		ON CONFLICT (oidc_id) DO UPDATE
+		SET username = EXCLUDED.username,
+			email = EXCLUDED.email,
+			first_name = EXCLUDED.first_name,
+			last_name = EXCLUDED.last_name,
+			enabled = EXCLUDED.enabled,
+			last_login_at = COALESCE(EXCLUDED.last_login_at, users.last_login_at),
+			updated_at = EXCLUDED.updated_at
+		WHERE (users.username, users.email, users.first_name, users.last_name, users.enabled)
+				IS DISTINCT FROM (EXCLUDED.username, EXCLUDED.email, EXCLUDED.first_name, EXCLUDED.last_name, EXCLUDED.enabled)
+			OR EXCLUDED.last_login_at IS NOT NULL
+	`
+
+	selectUsers = `
+		SELECT id, oidc_id, username, email, first_name, last_name, enabled,
+			last_login_at, created_at, updated_at, deleted_at
+		FROM users
+	`
+
+	getUser = selectUsers + `
+		WHERE id = $1
+	`
+
+	getUserByOIDCID = selectUsers + `
+		WHERE oidc_id = $1 AND deleted_at IS NULL
+	`
+
+	// Two users can briefly share a username or email while a swap is synced
+	getUserByEmail = selectUsers + `
+		WHERE email = $1 AND deleted_at IS NULL
+		ORDER BY updated_at DESC
+		LIMIT 1
+	`
+
+	getUserByUsername = selectUsers + `
+		WHERE username = $1 AND deleted_at IS NULL
+		ORDER BY updated_at DESC
+		LIMIT 1
+	`
+
+	getUsers = selectUsers + `
+		WHERE id = ANY($1)
+	`
+
+	getUsersByOIDCIDs = selectUsers + `
+		WHERE oidc_id = ANY($1) AND deleted_at IS NULL
+	`
+
+	// $1 is a LIKE pattern, or empty to list every user
+	listUsers = selectUsers + `
+		WHERE ($1 = '' OR username ILIKE $1 OR email ILIKE $1 OR first_name || ' ' || last_name ILIKE $1)
+			AND ($2 OR deleted_at IS NULL)
+		ORDER BY username, id
+		LIMIT $3 OFFSET $4
+	`
+
+	countUsers = `
+		SELECT COUNT(*) FROM users
+		WHERE ($1 = '' OR username ILIKE $1 OR email ILIKE $1 OR first_name || ' ' || last_name ILIKE $1)
+			AND ($2 OR deleted_at IS NULL)
+	`
+
+	listActiveOIDCIDs = `
+		SELECT oidc_id FROM users
+		WHERE deleted_at IS NULL
+	`
+
+	markUsersDeleted = `
+		UPDATE users
+		SET deleted_at = $2, updated_at = $2
+		WHERE oidc_id = ANY($1) AND deleted_at IS NULL
+	`
+)
+
+const (
+	createImpersonationTables = `
+		CREATE TABLE IF NOT EXISTS impersonations (
+			id UUID PRIMARY KEY,
+			actor_id VARCHAR(255) NOT NULL,
+			actor_username VARCHAR(255) NOT NULL,
+			target_id VARCHAR(255) NOT NULL,
+			target_username VARCHAR(255) NOT NULL,
+			tenant_id VARCHAR(255) NOT NULL DEFAULT '',
+			reason TEXT NOT NULL,
+			started_at TIMESTAMP NOT NULL,
+			expires_at TIMESTAMP NOT NULL,
+			ended_at TIMESTAMP
+		);
+
+		CREATE TABLE IF NOT EXISTS impersonation_events (
+			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			impersonation_id UUID NOT NULL REFERENCES impersonations (id),
+			type VARCHAR(32) NOT NULL,
+			method VARCHAR(16) NOT NULL DEFAULT '',
+			path TEXT NOT NULL DEFAULT '',
+			created_at TIMESTAMP NOT NULL
+		)
+	`
+
+	createImpersonationIndex = `
+		CREATE INDEX IF NOT EXISTS idx_impersonations_actor_id ON impersonations (actor_id, started_at DESC);
+		CREATE INDEX IF NOT EXISTS idx_impersonations_target_id ON impersonations (target_id, started_at DESC);
+		CREATE INDEX IF NOT EXISTS idx_impersonation_events_impersonation_id ON impersonation_events (impersonation_id, created_at);
+	`
+
+	insertImpersonation = `
+		INSERT INTO impersonations (id, actor_id, actor_username, target_id, target_username, tenant_id, reason, started_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)
+	`
+
+	selectImpersonations = `
+		SELECT id, actor_id, actor_username, target_id, target_username, tenant_id,
+			reason, started_at, expires_at, ended_at
+		FROM impersonations
+	`
+
+	getImpersonation = selectImpersonations + `
+		WHERE id = $1
+	`
+
+	// Empty actor and target IDs match every impersonation
+	listImpersonations = selectImpersonations + `
+		WHERE ($1 = '' OR actor_id = $1) AND ($2 = '' OR target_id = $2)
+		ORDER BY started_at DESC, id
+		LIMIT $3
+	`
+
+	endImpersonation = `
+		UPDATE impersonations
+		SET ended_at = $2
+		WHERE id = $1 AND ended_at IS NULL
+	`
+
+	insertImpersonationEvent = `
+		INSERT INTO impersonation_events (impersonation_id, type, method, path, created_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id
+	`
+
+	listImpersonationEvents = `
+		SELECT id, impersonation_id, type, method, path, created_at
+		FROM impersonation_events
+		WHERE impersonation_id = $1
+		ORDER BY created_at, id
+	`
+
+	createSCIMTables = `
+		CREATE TABLE IF NOT EXISTS scim_users (
+			user_id UUID PRIMARY KEY REFERENCES users (id),
+			tenant_id VARCHAR(255) NOT NULL,
+			external_id VARCHAR(255) NOT NULL DEFAULT '',
+			created_at TIMESTAMP NOT NULL,
+			updated_at TIMESTAMP NOT NULL
+		);
+
+		CREATE TABLE IF NOT EXISTS scim_groups (
+			id UUID PRIMARY KEY,
+			tenant_id VARCHAR(255) NOT NULL,
+			directory_id VARCHAR(255) NOT NULL,
+			display_name VARCHAR(255) NOT NULL,
+			external_id VARCHAR(255) NOT NULL DEFAULT '',
+			created_at TIMESTAMP NOT NULL,
+			updated_at TIMESTAMP NOT NULL,
+			UNIQUE (tenant_id, display_name)
+		);
+
+		CREATE TABLE IF NOT EXISTS scim_group_members (
+			group_id UUID NOT NULL REFERENCES scim_groups (id) ON DELETE CASCADE,
+			user_id UUID NOT NULL REFERENCES scim_users (user_id) ON DELETE CASCADE,
+			PRIMARY KEY (group_id, user_id)
+		)
+	`
+
+	createSCIMIndex = `
+		CREATE INDEX IF NOT EXISTS idx_scim_users_tenant_id ON scim_users (tenant_id);
+		CREATE INDEX IF NOT EXISTS idx_scim_group_members_user_id ON scim_group_members (user_id);
+	`
+
+	insertSCIMUser = `
+		INSERT INTO scim_users (user_id, tenant_id, external_id, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (user_id) DO NOTHING
+	`
+
+	selectSCIMUsers = `
+		SELECT user_id, tenant_id, external_id, created_at, updated_at
+		FROM scim_users
+	`
+
+	getSCIMUser = selectSCIMUsers + `
+		WHERE tenant_id = $1 AND user_id = $2
+	`
+
+	listSCIMUsers = selectSCIMUsers + `
+		WHERE tenant_id = $1
+	`
+
+	updateSCIMUser = `
+		UPDATE scim_users
+		SET external_id = $3, updated_at = $4
+		WHERE tenant_id = $1 AND user_id = $2
+	`
+
+	// Memberships are deleted by the foreign key
+	deleteSCIMUser = `
+		DELETE FROM scim_users
+		WHERE tenant_id = $1 AND user_id = $2
+	`
+
+	insertSCIMGroup = `
+		INSERT INTO scim_groups (id, tenant_id, directory_id, display_name, external_id, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT DO NOTHING
+	`
+
+	selectSCIMGroups = `
+		SELECT id, tenant_id, directory_id, display_name, external_id, created_at, updated_at
+		FROM scim_groups
+	`
+
+	getSCIMGroup = selectSCIMGroups + `
+		WHERE tenant_id = $1 AND id = $2
+	`
+
+	listSCIMGroups = selectSCIMGroups + `
+		WHERE tenant_id = $1
+		ORDER BY display_name, id
+	`
+
+	updateSCIMGroup = `
+		UPDATE scim_groups
+		SET display_name = $3, external_id = $4, updated_at = $5
+		WHERE tenant_id = $1 AND id = $2
+	`
+
+	deleteSCIMGroup = `
+		DELETE FROM scim_groups
+		WHERE tenant_id = $1 AND id = $2
+	`
+
+	listSCIMGroupMembers = `
+		SELECT m.group_id, m.user_id
+		FROM scim_group_members m
+		JOIN scim_groups g ON g.id = m.group_id
+		WHERE g.tenant_id = $1
+		ORDER BY m.user_id
+	`
+
+	getSCIMGroupMembers = `
+		SELECT user_id
+		FROM scim_group_members
+		WHERE group_id = $1
+		ORDER BY user_id
+	`
+
+	deleteSCIMGroupMembers = `
+		DELETE FROM scim_group_members
+		WHERE group_id = $1
+	`
+
+	insertSCIMGroupMembers = `
+		INSERT INTO scim_group_members (group_id, user_id)
+		SELECT $1, UNNEST($2::UUID[])
+	`
+
+	createProfileTables = `
+		CREATE TABLE IF NOT EXISTS user_profiles (
+			user_id UUID PRIMARY KEY REFERENCES users (id),
+			display_name VARCHAR(255) NOT NULL DEFAULT '',
+			avatar_url TEXT NOT NULL DEFAULT '',
+			locale VARCHAR(64) NOT NULL DEFAULT '',
+			time_zone VARCHAR(64) NOT NULL DEFAULT '',
+			notifications JSONB NOT NULL,
+			default_location JSONB,
+			updated_at TIMESTAMP NOT NULL
+		);
+
+		CREATE TABLE IF NOT EXISTS user_preferences (
+			user_id UUID NOT NULL REFERENCES users (id),
+			client_id VARCHAR(100) NOT NULL,
+			data JSONB NOT NULL,
+			updated_at TIMESTAMP NOT NULL,
+			PRIMARY KEY (user_id, client_id)
+		)
+	`
+
+	getProfile = `
+		SELECT user_id, display_name, avatar_url, locale, time_zone, notifications, default_location, updated_at
+		FROM user_profiles
+		WHERE user_id = $1
+	`
+
+	upsertProfile = `
+		INSERT INTO user_profiles (user_id, display_name, avatar_url, locale, time_zone, notifications, default_location, updated_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (user_id) DO UPDATE SET
+			display_name = EXCLUDED.display_name,
+			avatar_url = EXCLUDED.avatar_url,
+			locale = EXCLUDED.locale,
+			time_zone = EXCLUDED.time_zone,
+			notifications = EXCLUDED.notifications,
+			default_location = EXCLUDED.default_location,
+			updated_at = EXCLUDED.updated_at
+	`
+
+	getPreferences = `
+		SELECT user_id, client_id, data, updated_at
+		FROM user_preferences
+		WHERE user_id = $1 AND client_id = $2
+	`
+
+	upsertPreferences = `
+		INSERT INTO user_preferences (user_id, client_id, data, updated_at)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id, client_id) DO UPDATE SET
+			data = EXCLUDED.data,
+			updated_at = EXCLUDED.updated_at
+	`
+
+	deletePreferences = `
+		DELETE FROM user_preferences
+		WHERE user_id = $1 AND client_id = $2
+	`
+)
@@ -0,0 +1,418 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/intellifinder/v4/services/auth/internal/domain/apikey"
+	"github.com/intellifinder/v4/services/auth/internal/domain/impersonation"
+	"github.com/intellifinder/v4/services/auth/internal/domain/profile"
+	"github.com/intellifinder/v4/services/auth/internal/domain/scim"
+	"github.com/intellifinder/v4/services/auth/internal/domain/user"
+	"github.com/intellifinder/v4/services/auth/pkg/dto"
+	"github.com/intellifinder/v4/services/auth/pkg/models"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// newTestRepository connects to the database in TEST_DATABASE_URL and
+// creates the tables. All auth data in that database is deleted.
+func newTestRepository(t *testing.T) *Repository {
+	t.Helper()
+
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+
+	ctx := context.Background()
+	db, err := pgxpool.New(ctx, url)
+	if err != nil {
+		t.Fatalf("failed to create database pool: %v", err)
+	}
+	t.Cleanup(db.Close)
+
+	if err := CreateAPIKeyTable(ctx, db); err != nil {
+		t.Fatal(err)
+	}
+	if err := CreateUserTable(ctx, db); err != nil {
+		t.Fatal(err)
+	}
+	if err := CreateImpersonationTables(ctx, db); err != nil {
+		t.Fatal(err)
+	}
+	if err := CreateSCIMTables(ctx, db); err != nil {
+		t.Fatal(err)
+	}
+	if err := CreateProfileTables(ctx, db); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := db.Exec(ctx, "TRUNCATE api_keys, users, impersonations, impersonation_events, scim_users, scim_groups, scim_group_members, user_profiles, user_preferences"); err != nil {
+		t.Fatalf("failed to truncate tables: %v", err)
+	}
+
+	return NewRepository(db)
+}
+
+func TestAPIKeyRepository(t *testing.T) {
+	repo := newTestRepository(t)
+	ctx := context.Background()
+
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	expiresAt := now.Add(time.Hour)
+	key := &models.APIKey{
+		Name:      "ci",
+		UserID:    "user-1",
+		Prefix:    "0123456789abcdef",
+		Salt:      []byte("salt"),
+		Hash:      []byte("hash"),
+		Scopes:    []string{"tasks:read"},
+		Roles:     []string{"editor"},
+		ExpiresAt: &expiresAt,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	if err := repo.CreateAPIKey(ctx, key); err != nil {
+		t.Fatalf("CreateAPIKey() error = %v", err)
+	}
+	if key.ID == uuid.Nil {
+		t.Fatalf("CreateAPIKey() did not set the ID")
+	}
+
+	got, err := repo.GetAPIKeyByPrefix(ctx, key.Prefix)
+	if err != nil {
+		t.Fatalf("GetAPIKeyByPrefix() error = %v", err)
+	}
+	if got.ID != key.ID || !slices.Equal(got.Scopes, key.Scopes) || string(got.Hash) != "hash" || !got.ExpiresAt.Equal(expiresAt) {
+		t.Errorf("GetAPIKeyByPrefix() = %+v, want %+v", got, key)
+	}
+
+	if _, err := repo.GetAPIKey(ctx, uuid.New()); !errors.Is(err, apikey.ErrAPIKeyNotFound) {
+		t.Errorf("GetAPIKey() unknown error = %v, want ErrAPIKeyNotFound", err)
+	}
+
+	if err := repo.UpdateAPIKeySecret(ctx, key.ID, []byte("salt-2"), []byte("hash-2"), now); err != nil {
+		t.Fatalf("UpdateAPIKeySecret() error = %v", err)
+	}
+	if err := repo.TouchAPIKey(ctx, key.ID, now); err != nil {
+		t.Fatalf("TouchAPIKey() error = %v", err)
+	}
+	if err := repo.RevokeAPIKey(ctx, key.ID, now); err != nil {
+		t.Fatalf("RevokeAPIKey() error = %v", err)
+	}
+	if err := repo.UpdateAPIKeySecret(ctx, key.ID, []byte("salt-3"), []byte("hash-3"), now); !errors.Is(err, apikey.ErrAPIKeyRevoked) {
+		t.Errorf("UpdateAPIKeySecret() after revocation error = %v, want ErrAPIKeyRevoked", err)
+	}
+
+	keys, err := repo.ListAPIKeys(ctx, "user-1")
+	if err != nil {
+		t.Fatalf("ListAPIKeys() error = %v", err)
+	}
+	if len(keys) != 1 || string(keys[0].Hash) != "hash-2" || keys[0].LastUsedAt == nil || keys[0].RevokedAt == nil {
+		t.Errorf("ListAPIKeys() = %+v, want the rotated, used and revoked key", keys)
+	}
+}
+
+func TestUserRepository(t *testing.T) {
+	repo := newTestRepository(t)
+	ctx := context.Background()
+
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	alice := &models.User{OIDCID: "kc-1", Username: "alice", Email: "alice@example.com", Enabled: true, LastLoginAt: &now, CreatedAt: now, UpdatedAt: now}
+	if err := repo.UpsertUser(ctx, alice); err != nil {
+		t.Fatalf("UpsertUser() error = %v", err)
+	}
+
+	created, err := repo.GetUserByOIDCID(ctx, "kc-1")
+	if err != nil {
+		t.Fatalf("GetUserByOIDCID() error = %v", err)
+	}
+
+	// A sync without a login renames the user and keeps the last login
+	later := now.Add(time.Minute)
+	renamed := &models.User{OIDCID: "kc-1", Username: "alice.smith", Email: "alice@example.com", Enabled: false, CreatedAt: later, UpdatedAt: later}
+	if err := repo.UpsertUser(ctx, renamed); err != nil {
+		t.Fatalf("UpsertUser() error = %v", err)
+	}
+
+	got, err := repo.GetUserByUsername(ctx, "alice.smith")
+	if err != nil {
+		t.Fatalf("GetUserByUsername() error = %v", err)
+	}
+	if got.ID != created.ID || got.Enabled || got.LastLoginAt == nil || !got.LastLoginAt.Equal(now) || !got.CreatedAt.Equal(now) {
+		t.Errorf("GetUserByUsername() = %+v, want the same user renamed, disabled and with its first login", got)
+	}
+
+	ids, err := repo.ListActiveOIDCIDs(ctx)
+	if err != nil || len(ids) != 1 || ids[0] != "kc-1" {
+		t.Errorf("ListActiveOIDCIDs() = %v, %v, want [kc-1]", ids, err)
+	}
+
+	if err := repo.UpsertUser(ctx, &models.User{OIDCID: "kc-2", Username: "bob_50%", Email: "bob@example.com", Enabled: true, CreatedAt: now, UpdatedAt: now}); err != nil {
+		t.Fatalf("UpsertUser() error = %v", err)
+	}
+	page, err := repo.ListUsers(ctx, dto.UserFilter{Search: "_50%"}, 1, 10)
+	if err != nil || page.TotalCount != 1 || page.Users[0].Username != "bob_50%" {
+		t.Errorf("ListUsers() = %+v, %v, want the search matched literally", page, err)
+	}
+	page, err = repo.ListUsers(ctx, dto.UserFilter{}, 2, 1)
+	if err != nil || page.TotalCount != 2 || page.LastPage != 2 || len(page.Users) != 1 || page.Users[0].Username != "bob_50%" {
+		t.Errorf("ListUsers() page 2 = %+v, %v, want bob", page, err)
+	}
+
+	deleted, err := repo.MarkUsersDeleted(ctx, []string{"kc-1", "kc-9"}, later)
+	if err != nil || deleted != 1 {
+		t.Errorf("MarkUsersDeleted() = %d, %v, want 1", deleted, err)
+	}
+	if _, err := repo.GetUserByEmail(ctx, "alice@example.com"); !errors.Is(err, user.ErrUserNotFound) {
+		t.Errorf("GetUserByEmail() deleted user error = %v, want ErrUserNotFound", err)
+	}
+	if got, err := repo.GetUser(ctx, created.ID); err != nil || got.DeletedAt == nil {
+		t.Errorf("GetUser() = %+v, %v, want the deleted user", got, err)
+	}
+
+	if users, err := repo.GetUsers(ctx, []uuid.UUID{created.ID, uuid.New()}); err != nil || len(users) != 1 {
+		t.Errorf("GetUsers() = %v, %v, want the deleted user only", users, err)
+	}
+	if users, err := repo.GetUsersByOIDCIDs(ctx, []string{"kc-1"}); err != nil || len(users) != 0 {
+		t.Errorf("GetUsersByOIDCIDs() = %v, %v, want the deleted user hidden", users, err)
+	}
+}
+
+func TestImpersonationRepository(t *testing.T) {
+	repo := newTestRepository(t)
+	ctx := context.Background()
+
+	now := time.Now().UTC().Truncate(time.Second)
+	i := &models.Impersonation{
+		ID:             uuid.New(),
+		ActorID:        "kc-support",
+		ActorUsername:  "support",
+		TargetID:       "kc-alice",
+		TargetUsername: "alice",
+		Reason:         "ticket 42",
+		StartedAt:      now,
+		ExpiresAt:      now.Add(15 * time.Minute),
+	}
+	if err := repo.CreateImpersonation(ctx, i); err != nil {
+		t.Fatalf("CreateImpersonation() error = %v", err)
+	}
+
+	if _, err := repo.GetImpersonation(ctx, uuid.New()); !errors.Is(err, impersonation.ErrImpersonationNotFound) {
+		t.Errorf("GetImpersonation() unknown error = %v, want ErrImpersonationNotFound", err)
+	}
+
+	for _, eventType := range []string{models.ImpersonationStarted, models.ImpersonationRequest, models.ImpersonationEnded} {
+		event := &models.ImpersonationEvent{ImpersonationID: i.ID, Type: eventType, CreatedAt: now}
+		if eventType == models.ImpersonationRequest {
+			event.Method, event.Path = "GET", "/tasks"
+		}
+		if err := repo.AddImpersonationEvent(ctx, event); err != nil {
+			t.Fatalf("AddImpersonationEvent() error = %v", err)
+		}
+		now = now.Add(time.Second)
+	}
+
+	if err := repo.EndImpersonation(ctx, i.ID, now); err != nil {
+		t.Fatalf("EndImpersonation() error = %v", err)
+	}
+	if err := repo.EndImpersonation(ctx, i.ID, now.Add(time.Hour)); err != nil {
+		t.Fatalf("EndImpersonation() again error = %v", err)
+	}
+
+	got, err := repo.GetImpersonation(ctx, i.ID)
+	if err != nil {
+		t.Fatalf("GetImpersonation() error = %v", err)
+	}
+	if got.Reason != i.Reason || got.EndedAt == nil || !got.EndedAt.Equal(now) {
+		t.Errorf("GetImpersonation() = %+v, want ended at %v", got, now)
+	}
+
+	list, err := repo.ListImpersonations(ctx, dto.ImpersonationFilter{TargetID: "kc-alice", Limit: 10})
+	if err != nil || len(list) != 1 {
+		t.Errorf("ListImpersonations() = %v, %v, want the impersonation", list, err)
+	}
+	list, err = repo.ListImpersonations(ctx, dto.ImpersonationFilter{ActorID: "kc-alice", Limit: 10})
+	if err != nil || len(list) != 0 {
+		t.Errorf("ListImpersonations() by other actor = %v, %v, want none", list, err)
+	}
+
+	events, err := repo.ListImpersonationEvents(ctx, i.ID)
+	if err != nil {
+		t.Fatalf("ListImpersonationEvents() error = %v", err)
+	}
+	if len(events) != 3 || events[1].Path != "/tasks" || events[2].Type != models.ImpersonationEnded {
+		t.Errorf("ListImpersonationEvents() = %+v, want started, request and ended in order", events)
+	}
+}
+
+func TestSCIMRepository(t *testing.T) {
+	repo := newTestRepository(t)
+	ctx := context.Background()
+
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	var ids []uuid.UUID
+	for _, name := range []string{"alice", "bob"} {
+		u := &models.User{OIDCID: "kc-" + name, Username: name, Email: name + "@example.com", Enabled: true, CreatedAt: now, UpdatedAt: now}
+		if err := repo.UpsertUser(ctx, u); err != nil {
+			t.Fatalf("UpsertUser() error = %v", err)
+		}
+		created, err := repo.GetUserByOIDCID(ctx, u.OIDCID)
+		if err != nil {
+			t.Fatalf("GetUserByOIDCID() error = %v", err)
+		}
+		link := &models.SCIMUser{UserID: created.ID, TenantID: "tenant-1", ExternalID: "ext-" + name, CreatedAt: now, UpdatedAt: now}
+		if err := repo.CreateSCIMUser(ctx, link); err != nil {
+			t.Fatalf("CreateSCIMUser() error = %v", err)
+		}
+		ids = append(ids, created.ID)
+	}
+	slices.SortFunc(ids, func(a, b uuid.UUID) int { return strings.Compare(a.String(), b.String()) })
+
+	if err := repo.CreateSCIMUser(ctx, &models.SCIMUser{UserID: ids[0], TenantID: "tenant-2", CreatedAt: now, UpdatedAt: now}); !errors.Is(err, scim.ErrUniqueness) {
+		t.Errorf("CreateSCIMUser() again error = %v, want ErrUniqueness", err)
+	}
+	if _, err := repo.GetSCIMUser(ctx, "tenant-2", ids[0]); !errors.Is(err, scim.ErrResourceNotFound) {
+		t.Errorf("GetSCIMUser() other tenant error = %v, want ErrResourceNotFound", err)
+	}
+
+	link, err := repo.GetSCIMUser(ctx, "tenant-1", ids[0])
+	if err != nil {
+		t.Fatalf("GetSCIMUser() error = %v", err)
+	}
+	link.ExternalID = "changed"
+	if err := repo.UpdateSCIMUser(ctx, link); err != nil {
+		t.Fatalf("UpdateSCIMUser() error = %v", err)
+	}
+	if got, err := repo.GetSCIMUser(ctx, "tenant-1", ids[0]); err != nil || got.ExternalID != "changed" {
+		t.Errorf("GetSCIMUser() = %+v, %v, want the external ID changed", got, err)
+	}
+
+	g := &models.SCIMGroup{ID: uuid.New(), TenantID: "tenant-1", DirectoryID: "kc-group", DisplayName: "Engineering", Members: ids, CreatedAt: now, UpdatedAt: now}
+	if err := repo.CreateSCIMGroup(ctx, g); err != nil {
+		t.Fatalf("CreateSCIMGroup() error = %v", err)
+	}
+	duplicate := &models.SCIMGroup{ID: uuid.New(), TenantID: "tenant-1", DirectoryID: "kc-other", DisplayName: "Engineering", CreatedAt: now, UpdatedAt: now}
+	if err := repo.CreateSCIMGroup(ctx, duplicate); !errors.Is(err, scim.ErrUniqueness) {
+		t.Errorf("CreateSCIMGroup() same name error = %v, want ErrUniqueness", err)
+	}
+
+	other := &models.SCIMGroup{ID: uuid.New(), TenantID: "tenant-1", DirectoryID: "kc-other", DisplayName: "Sales", CreatedAt: now, UpdatedAt: now}
+	if err := repo.CreateSCIMGroup(ctx, other); err != nil {
+		t.Fatalf("CreateSCIMGroup() error = %v", err)
+	}
+	other.DisplayName = "Engineering"
+	if err := repo.UpdateSCIMGroup(ctx, other); !errors.Is(err, scim.ErrUniqueness) {
+		t.Errorf("UpdateSCIMGroup() taken name error = %v, want ErrUniqueness", err)
+	}
+
+	got, err := repo.GetSCIMGroup(ctx, "tenant-1", g.ID)
+	if err != nil {
+		t.Fatalf("GetSCIMGroup() error = %v", err)
+	}
+	if !slices.Equal(got.Members, ids) {
+		t.Errorf("GetSCIMGroup() members = %v, want %v", got.Members, ids)
+	}
+
+	g.DisplayName = "Platform"
+	g.Members = ids[1:]
+	if err := repo.UpdateSCIMGroup(ctx, g); err != nil {
+		t.Fatalf("UpdateSCIMGroup() error = %v", err)
+	}
+
+	// Deleting a user removes it from its groups
+	if err := repo.DeleteSCIMUser(ctx, "tenant-1", ids[1]); err != nil {
+		t.Fatalf("DeleteSCIMUser() error = %v", err)
+	}
+
+	groups, err := repo.ListSCIMGroups(ctx, "tenant-1")
+	if err != nil {
+		t.Fatalf("ListSCIMGroups() error = %v", err)
+	}
+	if len(groups) != 2 || groups[0].DisplayName != "Platform" || len(groups[0].Members) != 0 {
+		t.Errorf("ListSCIMGroups() = %+v, want Platform without members and Sales", groups)
+	}
+
+	if err := repo.DeleteSCIMGroup(ctx, "tenant-1", g.ID); err != nil {
+		t.Fatalf("DeleteSCIMGroup() error = %v", err)
+	}
+	if _, err := repo.GetSCIMGroup(ctx, "tenant-1", g.ID); !errors.Is(err, scim.ErrResourceNotFound) {
+		t.Errorf("GetSCIMGroup() deleted error = %v, want ErrResourceNotFound", err)
+	}
+
+	users, err := repo.ListSCIMUsers(ctx, "tenant-1")
+	if err != nil || len(users) != 1 || users[0].UserID != ids[0] {
+		t.Errorf("ListSCIMUsers() = %v, %v, want the remaining user", users, err)
+	}
+}
+
+func TestProfileRepository(t *testing.T) {
+	repo := newTestRepository(t)
+	ctx := context.Background()
+
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	u := &models.User{OIDCID: "kc-alice", Username: "alice", Email: "alice@example.com", Enabled: true, CreatedAt: now, UpdatedAt: now}
+	if err := repo.UpsertUser(ctx, u); err != nil {
+		t.Fatalf("UpsertUser() error = %v", err)
+	}
+	alice, err := repo.GetUserByOIDCID(ctx, u.OIDCID)
+	if err != nil {
+		t.Fatalf("GetUserByOIDCID() error = %v", err)
+	}
+
+	if _, err := repo.GetProfile(ctx, alice.ID); !errors.Is(err, profile.ErrProfileNotFound) {
+		t.Errorf("GetProfile() before upsert error = %v, want ErrProfileNotFound", err)
+	}
+
+	p := &models.Profile{
+		UserID:          alice.ID,
+		DisplayName:     "Alice",
+		Locale:          "en-GB",
+		TimeZone:        "Europe/London",
+		Notifications:   models.NotificationPreferences{Email: true, Digest: models.DigestWeekly},
+		DefaultLocation: &models.Location{Label: "Office", Latitude: 51.5, Longitude: -0.12},
+		UpdatedAt:       &now,
+	}
+	if err := repo.UpsertProfile(ctx, p); err != nil {
+		t.Fatalf("UpsertProfile() error = %v", err)
+	}
+	p.DefaultLocation = nil
+	p.AvatarURL = "https://example.com/alice.png"
+	if err := repo.UpsertProfile(ctx, p); err != nil {
+		t.Fatalf("UpsertProfile() again error = %v", err)
+	}
+
+	got, err := repo.GetProfile(ctx, alice.ID)
+	if err != nil {
+		t.Fatalf("GetProfile() error = %v", err)
+	}
+	if got.AvatarURL != p.AvatarURL || got.DefaultLocation != nil || got.Notifications != p.Notifications || got.UpdatedAt == nil || !got.UpdatedAt.Equal(now) {
+		t.Errorf("GetProfile() = %+v, want the second upsert", got)
+	}
+
+	if _, err := repo.GetPreferences(ctx, alice.ID, "task-web"); !errors.Is(err, profile.ErrPreferencesNotFound) {
+		t.Errorf("GetPreferences() before upsert error = %v, want ErrPreferencesNotFound", err)
+	}
+	for _, data := range []string{`{"theme":"light"}`, `{"theme":"dark"}`} {
+		if err := repo.UpsertPreferences(ctx, &models.Preferences{UserID: alice.ID, ClientID: "task-web", Data: []byte(data), UpdatedAt: now}); err != nil {
+			t.Fatalf("UpsertPreferences() error = %v", err)
+		}
+	}
+	prefs, err := repo.GetPreferences(ctx, alice.ID, "task-web")
+	if err != nil || !strings.Contains(string(prefs.Data), "dark") {
+		t.Errorf("GetPreferences() = %+v, %v, want the dark theme", prefs, err)
+	}
+
+	if err := repo.DeletePreferences(ctx, alice.ID, "task-web"); err != nil {
+		t.Fatalf("DeletePreferences() error = %v", err)
+	}
+	if err := repo.DeletePreferences(ctx, alice.ID, "task-web"); !errors.Is(err, profile.ErrPreferencesNotFound) {
+		t.Errorf("DeletePreferences() again error = %v, want ErrPreferencesNotFound", err)
+	}
+}
@@ -0,0 +1,212 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/intellifinder/v4/services/auth/internal/domain/scim"
+	"github.com/intellifinder/v4/services/auth/pkg/models"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+var _ scim.Repository = (*Repository)(nil)
+
+// uniqueViolation is the Postgres error code of unique constraint violations
+const uniqueViolation = "23505"
+
+func (r *Repository) CreateSCIMUser(ctx context.Context, u *models.SCIMUser) error {
+	tag, err := r.db.Exec(ctx, insertSCIMUser,
+		u.UserID,
+		u.TenantID,
+		u.ExternalID,
+		u.CreatedAt,
+		u.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to insert SCIM user: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return scim.ErrUniqueness
+	}
+
+	return nil
+}
+
+func (r *Repository) GetSCIMUser(ctx context.Context, tenantID string, userID uuid.UUID) (*models.SCIMUser, error) {
+	rows, err := r.db.Query(ctx, getSCIMUser, tenantID, userID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get SCIM user: %w", err)
+	}
+	defer rows.Close()
+
+	u, err := pgx.CollectOneRow(rows, pgx.RowToAddrOfStructByName[models.SCIMUser])
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, scim.ErrResourceNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan SCIM user: %w", err)
+	}
+
+	return u, nil
+}
+
+func (r *Repository) ListSCIMUsers(ctx context.Context, tenantID string) ([]*models.SCIMUser, error) {
+	rows, err := r.db.Query(ctx, listSCIMUsers, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list SCIM users: %w", err)
+	}
+	defer rows.Close()
+
+	users, err := pgx.CollectRows(rows, pgx.RowToAddrOfStructByName[models.SCIMUser])
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan SCIM users: %w", err)
+	}
+
+	return users, nil
+}
+
+func (r *Repository) UpdateSCIMUser(ctx context.Context, u *models.SCIMUser) error {
+	tag, err := r.db.Exec(ctx, updateSCIMUser, u.TenantID, u.UserID, u.ExternalID, u.UpdatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to update SCIM user: %w", err)
+	}
+	if tag.RowsAffected() == 0 {
+		return scim.ErrResourceNotFound
+	}
+
+	return nil
+}
+
+func (r *Repository) DeleteSCIMUser(ctx context.Context, tenantID string, userID uuid.UUID) error {
+	if _, err := r.db.Exec(ctx, deleteSCIMUser, tenantID, userID); err != nil {
+		return fmt.Errorf("failed to delete SCIM user: %w", err)
+	}
+
+	return nil
+}
+
+func (r *Repository) CreateSCIMGroup(ctx context.Context, g *models.SCIMGroup) error {
+	return pgx.BeginFunc(ctx, r.db, func(tx pgx.Tx) error {
+		tag, err := tx.Exec(ctx, insertSCIMGroup,
+			g.ID,
+			g.TenantID,
+			g.DirectoryID,
+			g.DisplayName,
+			g.ExternalID,
+			g.CreatedAt,
+			g.UpdatedAt,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to insert SCIM group: %w", err)
+		}
+		if tag.RowsAffected() == 0 {
+			return scim.ErrUniqueness
+		}
+
+		if _, err := tx.Exec(ctx, insertSCIMGroupMembers, g.ID, g.Members); err != nil {
+			return fmt.Errorf("failed to insert SCIM group members: %w", err)
+		}
+
+		return nil
+	})
+}
+
+func (r *Repository) GetSCIMGroup(ctx context.Context, tenantID string, id uuid.UUID) (*models.SCIMGroup, error) {
+	rows, err := r.db.Query(ctx, getSCIMGroup, tenantID, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get SCIM group: %w", err)
+	}
+	defer rows.Close()
+
+	g, err := pgx.CollectOneRow(rows, pgx.RowToAddrOfStructByName[models.SCIMGroup])
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, scim.ErrResourceNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan SCIM group: %w", err)
+	}
+
+	rows, err = r.db.Query(ctx, getSCIMGroupMembers, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get SCIM group members: %w", err)
+	}
+	defer rows.Close()
+
+	g.Members, err = pgx.CollectRows(rows, pgx.RowTo[uuid.UUID])
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan SCIM group members: %w", err)
+	}
+
+	return g, nil
+}
+
+func (r *Repository) ListSCIMGroups(ctx context.Context, tenantID string) ([]*models.SCIMGroup, error) {
+	rows, err := r.db.Query(ctx, listSCIMGroups, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list SCIM groups: %w", err)
+	}
+	defer rows.Close()
+
+	groups, err := pgx.CollectRows(rows, pgx.RowToAddrOfStructByName[models.SCIMGroup])
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan SCIM groups: %w", err)
+	}
+
+	rows, err = r.db.Query(ctx, listSCIMGroupMembers, tenantID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list SCIM group members: %w", err)
+	}
+	defer rows.Close()
+
+	members := make(map[uuid.UUID][]uuid.UUID)
+	var groupID, userID uuid.UUID
+	_, err = pgx.ForEachRow(rows, []any{&groupID, &userID}, func() error {
+		members[groupID] = append(members[groupID], userID)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan SCIM group members: %w", err)
+	}
+
+	for _, g := range groups {
+		g.Members = members[g.ID]
+	}
+
+	return groups, nil
+}
+
+func (r *Repository) UpdateSCIMGroup(ctx context.Context, g *models.SCIMGroup) error {
+	return pgx.BeginFunc(ctx, r.db, func(tx pgx.Tx) error {
+		tag, err := tx.Exec(ctx, updateSCIMGroup, g.TenantID, g.ID, g.DisplayName, g.ExternalID, g.UpdatedAt)
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == uniqueViolation {
+			return scim.ErrUniqueness
+		}
+		if err != nil {
+			return fmt.Errorf("failed to update SCIM group: %w", err)
+		}
+		if tag.RowsAffected() == 0 {
+			return scim.ErrResourceNotFound
+		}
+
+		if _, err := tx.Exec(ctx, deleteSCIMGroupMembers, g.ID); err != nil {
+			return fmt.Errorf("failed to delete SCIM group members: %w", err)
+		}
+		if _, err := tx.Exec(ctx, insertSCIMGroupMembers, g.ID, g.Members); err != nil {
+			return fmt.Errorf("failed to insert SCIM group members: %w", err)
+		}
+
+		return nil
+	})
+}
+
+func (r *Repository) DeleteSCIMGroup(ctx context.Context, tenantID string, id uuid.UUID) error {
+	if _, err := r.db.Exec(ctx, deleteSCIMGroup, tenantID, id); err != nil {
+		return fmt.Errorf("failed to delete SCIM group: %w", err)
+	}
+
+	return nil
+}
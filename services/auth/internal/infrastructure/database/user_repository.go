@@ -0,0 +1,148 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/intellifinder/v4/services/auth/internal/domain/user"
+	"github.com/intellifinder/v4/services/auth/pkg/dto"
+	"github.com/intellifinder/v4/services/auth/pkg/models"
+	"github.com/jackc/pgx/v5"
+)
+
+var _ user.Repository = (*Repository)(nil)
+
+func (r *Repository) UpsertUser(ctx context.Context, u *models.User) error {
+	_, err := r.db.Exec(ctx, upsertUser,
+		u.OIDCID,
+		u.Username,
+		u.Email,
+		u.FirstName,
+		u.LastName,
+		u.Enabled,
+		u.LastLoginAt,
+		u.CreatedAt,
+		u.UpdatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to upsert user: %w", err)
+	}
+
+	return nil
+}
+
+func (r *Repository) GetUser(ctx context.Context, id uuid.UUID) (*models.User, error) {
+	return r.getUser(ctx, getUser, id)
+}
+
+func (r *Repository) GetUserByOIDCID(ctx context.Context, oidcID string) (*models.User, error) {
+	return r.getUser(ctx, getUserByOIDCID, oidcID)
+}
+
+func (r *Repository) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	return r.getUser(ctx, getUserByEmail, email)
+}
+
+func (r *Repository) GetUserByUsername(ctx context.Context, username string) (*models.User, error) {
+	return r.getUser(ctx, getUserByUsername, username)
+}
+
+func (r *Repository) getUser(ctx context.Context, query string, arg any) (*models.User, error) {
+	rows, err := r.db.Query(ctx, query, arg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	defer rows.Close()
+
+	u, err := pgx.CollectOneRow(rows, pgx.RowToAddrOfStructByName[models.User])
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, user.ErrUserNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan user: %w", err)
+	}
+
+	return u, nil
+}
+
+func (r *Repository) GetUsers(ctx context.Context, ids []uuid.UUID) ([]*models.User, error) {
+	return r.getUsers(ctx, getUsers, ids)
+}
+
+func (r *Repository) GetUsersByOIDCIDs(ctx context.Context, oidcIDs []string) ([]*models.User, error) {
+	return r.getUsers(ctx, getUsersByOIDCIDs, oidcIDs)
+}
+
+func (r *Repository) getUsers(ctx context.Context, query string, arg any) ([]*models.User, error) {
+	rows, err := r.db.Query(ctx, query, arg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get users: %w", err)
+	}
+	defer rows.Close()
+
+	users, err := pgx.CollectRows(rows, pgx.RowToAddrOfStructByName[models.User])
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan users: %w", err)
+	}
+
+	return users, nil
+}
+
+func (r *Repository) ListUsers(ctx context.Context, filter dto.UserFilter, page int32, limit int32) (*dto.PaginatedUsers, error) {
+	offset := (page - 1) * limit
+
+	var pattern string
+	if filter.Search != "" {
+		pattern = "%" + likeEscaper.Replace(filter.Search) + "%"
+	}
+
+	rows, err := r.db.Query(ctx, listUsers, pattern, filter.IncludeDeleted, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list users: %w", err)
+	}
+	defer rows.Close()
+
+	users, err := pgx.CollectRows(rows, pgx.RowToAddrOfStructByName[models.User])
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan users: %w", err)
+	}
+
+	var totalCount int32
+	err = r.db.QueryRow(ctx, countUsers, pattern, filter.IncludeDeleted).Scan(&totalCount)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count users: %w", err)
+	}
+
+	return dto.NewPaginatedUsers(users, page, limit, totalCount), nil
+}
+
+// likeEscaper makes a search term match literally within a LIKE pattern
+var likeEscaper = strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`)
+
+func (r *Repository) ListActiveOIDCIDs(ctx context.Context) ([]string, error) {
+	rows, err := r.db.Query(ctx, listActiveOIDCIDs)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list users: %w", err)
+	}
+	defer rows.Close()
+
+	ids, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, fmt.Errorf("failed to scan users: %w", err)
+	}
+
+	return ids, nil
+}
+
+func (r *Repository) MarkUsersDeleted(ctx context.Context, oidcIDs []string, deletedAt time.Time) (int, error) {
+	tag, err := r.db.Exec(ctx, markUsersDeleted, oidcIDs, deletedAt)
+	if err != nil {
+		return 0, fmt.Errorf("failed to mark users deleted: %w", err)
+	}
+
+	return int(tag.RowsAffected()), nil
+}
@@ -0,0 +1,110 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+
+	authv1 "github.com/intellifinder/v4/services/auth/api/v1"
+	"github.com/intellifinder/v4/services/auth/internal/domain/apikey"
+	"github.com/intellifinder/v4/services/auth/internal/domain/authentication"
+	"github.com/intellifinder/v4/services/auth/internal/domain/user"
+	"github.com/intellifinder/v4/services/auth/pkg/models"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// AuthServer lets other services validate credentials and look up users
+// without going through the gateway
+type AuthServer struct {
+	authv1.UnimplementedAuthServiceServer
+	auth  *authentication.Service
+	users *user.Service
+}
+
+func NewAuthServer(auth *authentication.Service, users *user.Service) *AuthServer {
+	return &AuthServer{
+		auth:  auth,
+		users: users,
+	}
+}
+
+func (s *AuthServer) ValidateToken(ctx context.Context, req *authv1.ValidateTokenRequest) (*authv1.ValidateTokenResponse, error) {
+	identity, err := s.auth.AuthenticateToken(ctx, req.Token)
+	if err != nil {
+		return nil, authenticationError(err)
+	}
+
+	return &authv1.ValidateTokenResponse{
+		Identity: s.toProtoIdentity(ctx, identity),
+	}, nil
+}
+
+// IntrospectToken reports invalid and expired credentials as inactive rather
+// than failing, in the manner of RFC 7662
+func (s *AuthServer) IntrospectToken(ctx context.Context, req *authv1.IntrospectTokenRequest) (*authv1.IntrospectTokenResponse, error) {
+	authenticate := s.auth.AuthenticateToken
+	if apikey.IsAPIKey(req.Token) {
+		authenticate = s.auth.AuthenticateAPIKey
+	}
+
+	identity, err := authenticate(ctx, req.Token)
+	switch {
+	case errors.Is(err, authentication.ErrInvalidCredentials), errors.Is(err, authentication.ErrCredentialsExpired):
+		return &authv1.IntrospectTokenResponse{Active: false}, nil
+	case err != nil:
+		return nil, authenticationError(err)
+	}
+
+	return &authv1.IntrospectTokenResponse{
+		Active:   true,
+		Identity: s.toProtoIdentity(ctx, identity),
+	}, nil
+}
+
+func (s *AuthServer) ValidateAPIKey(ctx context.Context, req *authv1.ValidateAPIKeyRequest) (*authv1.ValidateAPIKeyResponse, error) {
+	identity, err := s.auth.AuthenticateAPIKey(ctx, req.Key)
+	if err != nil {
+		return nil, authenticationError(err)
+	}
+
+	return &authv1.ValidateAPIKeyResponse{
+		Identity: s.toProtoIdentity(ctx, identity),
+	}, nil
+}
+
+// toProtoIdentity adds the local user ID when the subject has been synced;
+// a failed lookup leaves it empty rather than failing a valid credential
+func (s *AuthServer) toProtoIdentity(ctx context.Context, identity *models.Identity) *authv1.Identity {
+	proto := &authv1.Identity{
+		Subject:   identity.Subject,
+		Username:  identity.Username,
+		Email:     identity.Email,
+		Roles:     identity.Roles,
+		Scopes:    identity.Scopes,
+		TenantId:  identity.TenantID,
+		SessionId: identity.SessionID,
+		Method:    identity.Method,
+	}
+
+	if !identity.ExpiresAt.IsZero() {
+		proto.ExpiresAt = timestamppb.New(identity.ExpiresAt)
+	}
+
+	if u, err := s.users.GetUserByOIDCID(ctx, identity.Subject); err == nil {
+		proto.UserId = u.ID.String()
+	}
+
+	return proto
+}
+
+func authenticationError(err error) error {
+	switch {
+	case errors.Is(err, authentication.ErrInvalidCredentials), errors.Is(err, authentication.ErrCredentialsExpired):
+		return status.Error(codes.Unauthenticated, err.Error())
+	case errors.Is(err, authentication.ErrUnavailable):
+		return status.Error(codes.Unavailable, err.Error())
+	default:
+		return err
+	}
+}
@@ -0,0 +1,243 @@
+package grpc
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	authv1 "github.com/intellifinder/v4/services/auth/api/v1"
+	"github.com/intellifinder/v4/services/auth/internal/domain/authentication"
+	"github.com/intellifinder/v4/services/auth/internal/domain/user"
+	"github.com/intellifinder/v4/services/auth/internal/infrastructure/memory"
+	"github.com/intellifinder/v4/services/auth/pkg/models"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// verifier accepts the credentials in its identities map
+type verifier struct {
+	identities map[string]*models.Identity
+	err        error
+}
+
+func (v *verifier) verify(credential string) (*models.Identity, error) {
+	if v.err != nil {
+		return nil, v.err
+	}
+	if identity, ok := v.identities[credential]; ok {
+		return identity, nil
+	}
+	return nil, authentication.ErrInvalidCredentials
+}
+
+func (v *verifier) VerifyAccessToken(_ context.Context, token string) (*models.Identity, error) {
+	return v.verify(token)
+}
+
+func (v *verifier) VerifyAPIKey(_ context.Context, key string) (*models.Identity, error) {
+	return v.verify(key)
+}
+
+var (
+	alice = &models.Identity{
+		Subject:   "kc-alice",
+		Username:  "alice",
+		Email:     "alice@example.com",
+		Roles:     []string{"user"},
+		SessionID: "session-1",
+		Method:    models.AuthMethodJWT,
+		ExpiresAt: time.Now().Add(time.Hour),
+	}
+	aliceKey = &models.Identity{
+		Subject:  "kc-alice",
+		Username: "alice",
+		Roles:    []string{"user"},
+		Scopes:   []string{"tasks:read"},
+		Method:   models.AuthMethodAPIKey,
+	}
+)
+
+// newTestClient serves an AuthServer backed by the in-memory repository over
+// bufconn, with alice synced to the local user store
+func newTestClient(t *testing.T, tokens *verifier) (authv1.AuthServiceClient, *memory.Repository) {
+	t.Helper()
+
+	repo := memory.NewRepository()
+	if err := repo.UpsertUser(context.Background(), &models.User{
+		OIDCID:   "kc-alice",
+		Username: "alice",
+		Email:    "alice@example.com",
+		Enabled:  true,
+	}); err != nil {
+		t.Fatalf("UpsertUser() error = %v", err)
+	}
+
+	auth := authentication.NewService(tokens, 0)
+	auth.SetAPIKeyVerifier(&verifier{identities: map[string]*models.Identity{"ifk_0123456789abcdef_secret": aliceKey}})
+
+	listener := bufconn.Listen(1024 * 1024)
+	server := grpc.NewServer()
+	authv1.RegisterAuthServiceServer(server, NewAuthServer(auth, user.NewService(repo)))
+
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("failed to dial bufconn: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return authv1.NewAuthServiceClient(conn), repo
+}
+
+func TestValidateToken(t *testing.T) {
+	client, repo := newTestClient(t, &verifier{identities: map[string]*models.Identity{"valid": alice}})
+	ctx := context.Background()
+
+	resp, err := client.ValidateToken(ctx, &authv1.ValidateTokenRequest{Token: "valid"})
+	if err != nil {
+		t.Fatalf("ValidateToken() error = %v", err)
+	}
+
+	local, _ := repo.GetUserByOIDCID(ctx, "kc-alice")
+	identity := resp.Identity
+	if identity.Subject != "kc-alice" || identity.UserId != local.ID.String() || identity.SessionId != "session-1" {
+		t.Errorf("ValidateToken() identity = %v, want alice with local user ID %s", identity, local.ID)
+	}
+	if identity.Method != models.AuthMethodJWT || identity.ExpiresAt == nil {
+		t.Errorf("ValidateToken() method = %q, expires_at = %v, want jwt with expiry", identity.Method, identity.ExpiresAt)
+	}
+
+	_, err = client.ValidateToken(ctx, &authv1.ValidateTokenRequest{Token: "forged"})
+	if status.Code(err) != codes.Unauthenticated {
+		t.Errorf("ValidateToken(forged) code = %v, want %v", status.Code(err), codes.Unauthenticated)
+	}
+}
+
+func TestValidateTokenUnavailable(t *testing.T) {
+	client, _ := newTestClient(t, &verifier{err: authentication.ErrUnavailable})
+
+	_, err := client.ValidateToken(context.Background(), &authv1.ValidateTokenRequest{Token: "valid"})
+	if status.Code(err) != codes.Unavailable {
+		t.Errorf("ValidateToken() code = %v, want %v", status.Code(err), codes.Unavailable)
+	}
+}
+
+func TestIntrospectToken(t *testing.T) {
+	client, _ := newTestClient(t, &verifier{identities: map[string]*models.Identity{"valid": alice}})
+	ctx := context.Background()
+
+	tests := []struct {
+		token      string
+		wantActive bool
+		wantMethod string
+	}{
+		{token: "valid", wantActive: true, wantMethod: models.AuthMethodJWT},
+		{token: "ifk_0123456789abcdef_secret", wantActive: true, wantMethod: models.AuthMethodAPIKey},
+		{token: "forged", wantActive: false},
+		{token: "ifk_0123456789abcdef_wrong", wantActive: false},
+	}
+
+	for _, tt := range tests {
+		resp, err := client.IntrospectToken(ctx, &authv1.IntrospectTokenRequest{Token: tt.token})
+		if err != nil {
+			t.Fatalf("IntrospectToken(%q) error = %v", tt.token, err)
+		}
+		if resp.Active != tt.wantActive {
+			t.Errorf("IntrospectToken(%q) active = %v, want %v", tt.token, resp.Active, tt.wantActive)
+		}
+		if resp.Identity.GetMethod() != tt.wantMethod {
+			t.Errorf("IntrospectToken(%q) method = %q, want %q", tt.token, resp.Identity.GetMethod(), tt.wantMethod)
+		}
+	}
+}
+
+func TestValidateAPIKey(t *testing.T) {
+	client, _ := newTestClient(t, &verifier{})
+	ctx := context.Background()
+
+	resp, err := client.ValidateAPIKey(ctx, &authv1.ValidateAPIKeyRequest{Key: "ifk_0123456789abcdef_secret"})
+	if err != nil {
+		t.Fatalf("ValidateAPIKey() error = %v", err)
+	}
+	if len(resp.Identity.Scopes) != 1 || resp.Identity.Scopes[0] != "tasks:read" || resp.Identity.ExpiresAt != nil {
+		t.Errorf("ValidateAPIKey() identity = %v, want scope tasks:read and no expiry", resp.Identity)
+	}
+
+	_, err = client.ValidateAPIKey(ctx, &authv1.ValidateAPIKeyRequest{Key: "ifk_0123456789abcdef_wrong"})
+	if status.Code(err) != codes.Unauthenticated {
+		t.Errorf("ValidateAPIKey(wrong) code = %v, want %v", status.Code(err), codes.Unauthenticated)
+	}
+}
+
+func TestGetUser(t *testing.T) {
+	client, repo := newTestClient(t, &verifier{})
+	ctx := context.Background()
+	local, _ := repo.GetUserByOIDCID(ctx, "kc-alice")
+
+	tests := []struct {
+		name     string
+		req      *authv1.GetUserRequest
+		wantCode codes.Code
+	}{
+		{name: "id", req: &authv1.GetUserRequest{Lookup: &authv1.GetUserRequest_Id{Id: local.ID.String()}}, wantCode: codes.OK},
+		{name: "oidc id", req: &authv1.GetUserRequest{Lookup: &authv1.GetUserRequest_OidcId{OidcId: "kc-alice"}}, wantCode: codes.OK},
+		{name: "email", req: &authv1.GetUserRequest{Lookup: &authv1.GetUserRequest_Email{Email: "Alice@Example.com"}}, wantCode: codes.OK},
+		{name: "username", req: &authv1.GetUserRequest{Lookup: &authv1.GetUserRequest_Username{Username: "alice"}}, wantCode: codes.OK},
+		{name: "unknown", req: &authv1.GetUserRequest{Lookup: &authv1.GetUserRequest_Username{Username: "bob"}}, wantCode: codes.NotFound},
+		{name: "invalid id", req: &authv1.GetUserRequest{Lookup: &authv1.GetUserRequest_Id{Id: "42"}}, wantCode: codes.InvalidArgument},
+		{name: "no lookup", req: &authv1.GetUserRequest{}, wantCode: codes.InvalidArgument},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, err := client.GetUser(ctx, tt.req)
+			if status.Code(err) != tt.wantCode {
+				t.Fatalf("GetUser() code = %v, want %v", status.Code(err), tt.wantCode)
+			}
+			if err == nil && resp.User.Id != local.ID.String() {
+				t.Errorf("GetUser() = %v, want %s", resp.User, local.ID)
+			}
+		})
+	}
+}
+
+func TestGetUsers(t *testing.T) {
+	client, repo := newTestClient(t, &verifier{})
+	ctx := context.Background()
+	local, _ := repo.GetUserByOIDCID(ctx, "kc-alice")
+	unknown := "00000000-0000-0000-0000-000000000001"
+
+	resp, err := client.GetUsers(ctx, &authv1.GetUsersRequest{
+		Ids:     []string{local.ID.String(), unknown},
+		OidcIds: []string{"kc-alice", "kc-bob"},
+	})
+	if err != nil {
+		t.Fatalf("GetUsers() error = %v", err)
+	}
+	if len(resp.Users) != 2 {
+		t.Errorf("GetUsers() returned %d users, want 2", len(resp.Users))
+	}
+	if len(resp.Missing) != 2 || resp.Missing[0] != unknown || resp.Missing[1] != "kc-bob" {
+		t.Errorf("GetUsers() missing = %v, want [%s kc-bob]", resp.Missing, unknown)
+	}
+
+	tooMany := make([]string, user.MaxBatchSize+1)
+	for i := range tooMany {
+		tooMany[i] = "kc-user"
+	}
+	_, err = client.GetUsers(ctx, &authv1.GetUsersRequest{OidcIds: tooMany})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("GetUsers(too many) code = %v, want %v", status.Code(err), codes.InvalidArgument)
+	}
+}
@@ -0,0 +1,38 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	serviceauth "github.com/intellifinder/v4/libs/auth"
+	"github.com/intellifinder/v4/services/auth/internal/domain/authentication"
+)
+
+// ServiceVerifier checks the service tokens sent to this server. It uses the
+// token verifier directly rather than the authentication service, so service
+// accounts are never provisioned as local users.
+type ServiceVerifier struct {
+	tokens authentication.TokenVerifier
+}
+
+func NewServiceVerifier(tokens authentication.TokenVerifier) *ServiceVerifier {
+	return &ServiceVerifier{tokens: tokens}
+}
+
+// VerifyServiceToken returns the client ID of the service account the token
+// was issued to; tokens of human users are rejected, whatever their username
+func (v *ServiceVerifier) VerifyServiceToken(ctx context.Context, token string) (string, error) {
+	identity, err := v.tokens.VerifyAccessToken(ctx, token)
+	if errors.Is(err, authentication.ErrUnavailable) {
+		return "", serviceauth.ErrUnavailable
+	}
+	if err != nil {
+		return "", serviceauth.ErrInvalidToken
+	}
+
+	if !identity.ServiceAccount || !strings.HasPrefix(identity.Username, serviceauth.ServiceAccountPrefix) || identity.ClientID == "" {
+		return "", serviceauth.ErrInvalidToken
+	}
+	return identity.ClientID, nil
+}
@@ -0,0 +1,51 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	serviceauth "github.com/intellifinder/v4/libs/auth"
+	"github.com/intellifinder/v4/services/auth/internal/domain/authentication"
+	"github.com/intellifinder/v4/services/auth/pkg/models"
+)
+
+func TestVerifyServiceToken(t *testing.T) {
+	ctx := context.Background()
+	services := NewServiceVerifier(&verifier{identities: map[string]*models.Identity{
+		"tasks-token": {
+			Subject:        "kc-service-tasks",
+			Username:       "service-account-tasks-service",
+			ClientID:       "tasks-service",
+			ServiceAccount: true,
+			Method:         models.AuthMethodJWT,
+		},
+		// A user named like a service account, logged in through the service's client
+		"impostor-token": {
+			Subject:  "kc-impostor",
+			Username: "service-account-tasks-service",
+			ClientID: "tasks-service",
+			Method:   models.AuthMethodJWT,
+		},
+		"alice-token": alice,
+	}})
+
+	name, err := services.VerifyServiceToken(ctx, "tasks-token")
+	if err != nil {
+		t.Fatalf("VerifyServiceToken() error = %v", err)
+	}
+	if name != "tasks-service" {
+		t.Errorf("VerifyServiceToken() = %q, want %q", name, "tasks-service")
+	}
+
+	for _, token := range []string{"alice-token", "impostor-token", "unknown-token"} {
+		if _, err := services.VerifyServiceToken(ctx, token); !errors.Is(err, serviceauth.ErrInvalidToken) {
+			t.Errorf("VerifyServiceToken(%q) error = %v, want %v", token, err, serviceauth.ErrInvalidToken)
+		}
+	}
+
+	unavailable := NewServiceVerifier(&verifier{err: authentication.ErrUnavailable})
+	if _, err := unavailable.VerifyServiceToken(ctx, "tasks-token"); !errors.Is(err, serviceauth.ErrUnavailable) {
+		t.Errorf("VerifyServiceToken() error = %v, want %v", err, serviceauth.ErrUnavailable)
+	}
+}
@@ -0,0 +1,121 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+	authv1 "github.com/intellifinder/v4/services/auth/api/v1"
+	"github.com/intellifinder/v4/services/auth/internal/domain/user"
+	"github.com/intellifinder/v4/services/auth/pkg/models"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func (s *AuthServer) GetUser(ctx context.Context, req *authv1.GetUserRequest) (*authv1.GetUserResponse, error) {
+	var (
+		u   *models.User
+		err error
+	)
+
+	switch lookup := req.Lookup.(type) {
+	case *authv1.GetUserRequest_Id:
+		id, parseErr := uuid.Parse(lookup.Id)
+		if parseErr != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid user ID %q", lookup.Id)
+		}
+		u, err = s.users.GetUser(ctx, id)
+	case *authv1.GetUserRequest_OidcId:
+		u, err = s.users.GetUserByOIDCID(ctx, lookup.OidcId)
+	case *authv1.GetUserRequest_Email:
+		u, err = s.users.GetUserByEmail(ctx, lookup.Email)
+	case *authv1.GetUserRequest_Username:
+		u, err = s.users.GetUserByUsername(ctx, lookup.Username)
+	default:
+		return nil, status.Error(codes.InvalidArgument, "one of id, oidc_id, email or username is required")
+	}
+	if err != nil {
+		return nil, userError(err)
+	}
+
+	return &authv1.GetUserResponse{
+		User: toProtoUser(u),
+	}, nil
+}
+
+func (s *AuthServer) GetUsers(ctx context.Context, req *authv1.GetUsersRequest) (*authv1.GetUsersResponse, error) {
+	ids := make([]uuid.UUID, len(req.Ids))
+	for i, raw := range req.Ids {
+		id, err := uuid.Parse(raw)
+		if err != nil {
+			return nil, status.Errorf(codes.InvalidArgument, "invalid user ID %q", raw)
+		}
+		ids[i] = id
+	}
+
+	users, err := s.users.GetUsers(ctx, ids, req.OidcIds)
+	if err != nil {
+		return nil, userError(err)
+	}
+
+	found := make(map[string]bool, len(users)*2)
+	protoUsers := make([]*authv1.User, len(users))
+	for i, u := range users {
+		found[u.ID.String()] = true
+		found[u.OIDCID] = true
+		protoUsers[i] = toProtoUser(u)
+	}
+
+	// IDs are reported as requested, so match them in their canonical form
+	var missing []string
+	for i, id := range ids {
+		if !found[id.String()] {
+			missing = append(missing, req.Ids[i])
+		}
+	}
+	for _, oidcID := range req.OidcIds {
+		if !found[oidcID] {
+			missing = append(missing, oidcID)
+		}
+	}
+
+	return &authv1.GetUsersResponse{
+		Users:   protoUsers,
+		Missing: missing,
+	}, nil
+}
+
+func toProtoUser(u *models.User) *authv1.User {
+	proto := &authv1.User{
+		Id:        u.ID.String(),
+		OidcId:    u.OIDCID,
+		Username:  u.Username,
+		Email:     u.Email,
+		FirstName: u.FirstName,
+		LastName:  u.LastName,
+		Enabled:   u.Enabled,
+		CreatedAt: timestamppb.New(u.CreatedAt),
+		UpdatedAt: timestamppb.New(u.UpdatedAt),
+	}
+
+	if u.LastLoginAt != nil {
+		proto.LastLoginAt = timestamppb.New(*u.LastLoginAt)
+	}
+	if u.DeletedAt != nil {
+		proto.DeletedAt = timestamppb.New(*u.DeletedAt)
+	}
+
+	return proto
+}
+
+func userError(err error) error {
+	switch {
+	case errors.Is(err, user.ErrUserNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, user.ErrTooManyUsers):
+		return status.Error(codes.InvalidArgument, err.Error())
+	default:
+		return err
+	}
+}
@@ -1,51 +1,594 @@
 package keycloak
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/intellifinder/v4/services/auth/internal/config"
 )
 
+// tokenExpiryMargin renews the service account token this long before it
+// expires so requests in flight don't fail with 401
+const tokenExpiryMargin = 30 * time.Second
+
+// Client manages realm users through the Keycloak Admin REST API, acting as
+// the service account of the configured confidential client.
 type Client struct {
-	baseURL    string
-	httpClient *http.Client
+	adminURL     string
+	tokenURL     string
+	logoutURL    string
+	clientID     string
+	clientSecret string
+	httpClient   *http.Client
+	now          func() time.Time
+
+	tokenMu        sync.Mutex
+	token          string
+	tokenExpiresAt time.Time
+}
+
+func NewClient(cfg config.Keycloak, httpClient *http.Client) *Client {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	return &Client{
+		adminURL:     cfg.AdminURL(),
+		tokenURL:     cfg.TokenURL(),
+		logoutURL:    cfg.LogoutURL(),
+		clientID:     cfg.ClientID,
+		clientSecret: cfg.ClientSecret,
+		httpClient:   httpClient,
+		now:          time.Now,
+	}
 }
 
 func (c *Client) GetUser(ctx context.Context, id string) (*KeycloakUser, error) {
-	// TODO implement
-	panic("not implemented")
+	var rep userRepresentation
+	if _, err := c.do(ctx, http.MethodGet, userPath(id), nil, nil, &rep); err != nil {
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+
+	return c.withRoles(ctx, &rep)
 }
 
+// RefreshToken exchanges a refresh token issued to the configured client for new tokens
 func (c *Client) RefreshToken(ctx context.Context, refreshToken string) (*KeycloakToken, error) {
-	// TODO implement
-	panic("not implemented")
+	token, err := c.requestToken(ctx, url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {refreshToken},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to refresh token: %w", err)
+	}
+
+	return token, nil
 }
 
-func (c *Client) ValidateToken(ctx context.Context, token string) (*KeycloakToken, error) {
-	// TODO implement
-	panic("not implemented")
+// Login exchanges a user's credentials for tokens with the resource owner
+// password grant
+func (c *Client) Login(ctx context.Context, username string, password string) (*KeycloakToken, error) {
+	token, err := c.requestToken(ctx, url.Values{
+		"grant_type": {"password"},
+		"username":   {username},
+		"password":   {password},
+		"scope":      {"openid"},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to log in: %w", err)
+	}
+
+	return token, nil
+}
+
+// ClientCredentialsToken requests an access token for the service account of
+// another confidential client, authenticating with that client's credentials.
+// Wrong credentials and clients without a service account are reported as
+// ErrInvalidToken, like wrong passwords.
+func (c *Client) ClientCredentialsToken(ctx context.Context, clientID string, clientSecret string) (*KeycloakToken, error) {
+	token, err := c.requestToken(ctx, url.Values{
+		"grant_type":    {"client_credentials"},
+		"client_id":     {clientID},
+		"client_secret": {clientSecret},
+	})
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && (strings.HasPrefix(apiErr.Message, "invalid_client") || strings.HasPrefix(apiErr.Message, "unauthorized_client")) {
+		return nil, fmt.Errorf("failed to get client token: %w: %s", ErrInvalidToken, apiErr.Message)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get client token: %w", err)
+	}
+
+	return token, nil
+}
+
+// Logout ends the Keycloak session of a refresh token issued to the configured client
+func (c *Client) Logout(ctx context.Context, refreshToken string) error {
+	resp, err := c.postForm(ctx, c.logoutURL, url.Values{"refresh_token": {refreshToken}})
+	if err != nil {
+		return fmt.Errorf("failed to log out: %w", err)
+	}
+	resp.Body.Close()
+
+	return nil
 }
 
+// CreateUser creates the user and assigns its realm roles. The user is
+// deleted again if a role can't be assigned.
 func (c *Client) CreateUser(ctx context.Context, user *KeycloakUser) (*KeycloakUser, error) {
-	// TODO implement
-	panic("not implemented")
+	rep := toRepresentation(user)
+	rep.Username = user.Username
+	if user.TenantID != "" {
+		rep.Attributes = map[string][]string{tenantAttribute: {user.TenantID}}
+	}
+
+	header, err := c.do(ctx, http.MethodPost, "/users", nil, rep, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create user: %w", err)
+	}
+
+	// Keycloak answers with the new user's URL instead of a body
+	id := path.Base(header.Get("Location"))
+	if id == "" || id == "." || id == "/" {
+		return nil, fmt.Errorf("failed to create user: response has no Location header")
+	}
+
+	if len(user.Roles) > 0 {
+		if err := c.assignRealmRoles(ctx, id, user.Roles); err != nil {
+			if _, deleteErr := c.do(ctx, http.MethodDelete, userPath(id), nil, nil, nil); deleteErr != nil {
+				err = errors.Join(err, fmt.Errorf("failed to delete partially created user %s: %w", id, deleteErr))
+			}
+			return nil, fmt.Errorf("failed to assign roles: %w", err)
+		}
+	}
+
+	return c.GetUser(ctx, id)
 }
 
+// UpdateUser replaces the user's profile and enabled flag; the username and
+// roles are left unchanged
 func (c *Client) UpdateUser(ctx context.Context, user *KeycloakUser) (*KeycloakUser, error) {
-	// TODO implement
-	panic("not implemented")
+	if _, err := c.do(ctx, http.MethodPut, userPath(user.ID), nil, toRepresentation(user), nil); err != nil {
+		return nil, fmt.Errorf("failed to update user: %w", err)
+	}
+
+	return c.GetUser(ctx, user.ID)
 }
 
 func (c *Client) DeleteUser(ctx context.Context, id string) error {
-	// TODO implement
-	panic("not implemented")
+	if _, err := c.do(ctx, http.MethodDelete, userPath(id), nil, nil, nil); err != nil {
+		return fmt.Errorf("failed to delete user: %w", err)
+	}
+
+	return nil
+}
+
+// ExecuteActionsEmail emails the user a link to perform required actions,
+// such as UPDATE_PASSWORD. Keycloak rejects users who are disabled or have
+// no email with 400.
+func (c *Client) ExecuteActionsEmail(ctx context.Context, id string, actions []string) error {
+	if _, err := c.do(ctx, http.MethodPut, userPath(id)+"/execute-actions-email", nil, actions, nil); err != nil {
+		return fmt.Errorf("failed to send actions email: %w", err)
+	}
+
+	return nil
 }
 
 func (c *Client) GetUserByEmail(ctx context.Context, email string) (*KeycloakUser, error) {
-	// TODO implement
-	panic("not implemented")
+	return c.findUser(ctx, "email", email)
 }
 
 func (c *Client) GetUserByUsername(ctx context.Context, username string) (*KeycloakUser, error) {
-	// TODO implement
-	panic("not implemented")
+	return c.findUser(ctx, "username", username)
+}
+
+// findUser looks a user up by an exact attribute match and returns an
+// APIError wrapping ErrNotFound if there is none
+func (c *Client) findUser(ctx context.Context, attribute string, value string) (*KeycloakUser, error) {
+	query := url.Values{attribute: {value}, "exact": {"true"}}
+
+	var reps []userRepresentation
+	if _, err := c.do(ctx, http.MethodGet, "/users", query, nil, &reps); err != nil {
+		return nil, fmt.Errorf("failed to find user by %s: %w", attribute, err)
+	}
+
+	if len(reps) == 0 {
+		return nil, &APIError{StatusCode: http.StatusNotFound, Message: fmt.Sprintf("no user with %s %q", attribute, value)}
+	}
+
+	return c.withRoles(ctx, &reps[0])
+}
+
+// ListUsers returns a page of realm users. Unlike the single-user lookups it
+// leaves Roles empty, which saves a request per user.
+func (c *Client) ListUsers(ctx context.Context, first int, max int) ([]*KeycloakUser, error) {
+	query := url.Values{
+		"first":               {strconv.Itoa(first)},
+		"max":                 {strconv.Itoa(max)},
+		"briefRepresentation": {"true"},
+	}
+
+	var reps []userRepresentation
+	if _, err := c.do(ctx, http.MethodGet, "/users", query, nil, &reps); err != nil {
+		return nil, fmt.Errorf("failed to list users: %w", err)
+	}
+
+	users := make([]*KeycloakUser, len(reps))
+	for i := range reps {
+		users[i] = fromRepresentation(&reps[i], nil)
+	}
+
+	return users, nil
+}
+
+// ListAdminEvents returns a page of the realm's admin events on the given
+// resource types, most recent first, from the day of dateFrom on. The
+// service account needs the realm-management view-events role.
+func (c *Client) ListAdminEvents(ctx context.Context, dateFrom time.Time, resourceTypes []string, first int, max int) ([]*KeycloakEvent, error) {
+	query := url.Values{
+		"dateFrom":      {dateFrom.UTC().Format(time.DateOnly)},
+		"resourceTypes": resourceTypes,
+		"first":         {strconv.Itoa(first)},
+		"max":           {strconv.Itoa(max)},
+	}
+
+	var events []*KeycloakEvent
+	if _, err := c.do(ctx, http.MethodGet, "/admin-events", query, nil, &events); err != nil {
+		return nil, fmt.Errorf("failed to list admin events: %w", err)
+	}
+
+	return events, nil
+}
+
+// ListEvents returns a page of the realm's login events of the given types,
+// most recent first, from the day of dateFrom on. Keycloak only stores them
+// when saving events is enabled for the realm.
+func (c *Client) ListEvents(ctx context.Context, dateFrom time.Time, types []string, first int, max int) ([]*KeycloakEvent, error) {
+	query := url.Values{
+		"dateFrom": {dateFrom.UTC().Format(time.DateOnly)},
+		"type":     types,
+		"first":    {strconv.Itoa(first)},
+		"max":      {strconv.Itoa(max)},
+	}
+
+	var events []*KeycloakEvent
+	if _, err := c.do(ctx, http.MethodGet, "/events", query, nil, &events); err != nil {
+		return nil, fmt.Errorf("failed to list events: %w", err)
+	}
+
+	return events, nil
+}
+
+// FindGroup returns the ID of the top-level group with the given name, or an
+// APIError wrapping ErrNotFound if there is none
+func (c *Client) FindGroup(ctx context.Context, name string) (string, error) {
+	query := url.Values{"search": {name}, "exact": {"true"}, "briefRepresentation": {"true"}}
+
+	var reps []groupRepresentation
+	if _, err := c.do(ctx, http.MethodGet, "/groups", query, nil, &reps); err != nil {
+		return "", fmt.Errorf("failed to find group: %w", err)
+	}
+
+	// The search also returns top-level groups with a subgroup of that name
+	for _, rep := range reps {
+		if rep.Name == name {
+			return rep.ID, nil
+		}
+	}
+
+	return "", &APIError{StatusCode: http.StatusNotFound, Message: fmt.Sprintf("no group named %q", name)}
+}
+
+// CreateGroup creates a subgroup of the parent group, or a top-level group
+// if parentID is empty, and returns its ID. Keycloak answers 409 if the
+// parent has a group of that name.
+func (c *Client) CreateGroup(ctx context.Context, parentID string, name string) (string, error) {
+	resource := "/groups"
+	if parentID != "" {
+		resource = groupPath(parentID) + "/children"
+	}
+
+	header, err := c.do(ctx, http.MethodPost, resource, nil, groupRepresentation{Name: name}, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create group: %w", err)
+	}
+
+	id := path.Base(header.Get("Location"))
+	if id == "" || id == "." || id == "/" {
+		return "", fmt.Errorf("failed to create group: response has no Location header")
+	}
+
+	return id, nil
+}
+
+func (c *Client) RenameGroup(ctx context.Context, id string, name string) error {
+	if _, err := c.do(ctx, http.MethodPut, groupPath(id), nil, groupRepresentation{Name: name}, nil); err != nil {
+		return fmt.Errorf("failed to rename group: %w", err)
+	}
+
+	return nil
+}
+
+// DeleteGroup deletes the group with its subgroups
+func (c *Client) DeleteGroup(ctx context.Context, id string) error {
+	if _, err := c.do(ctx, http.MethodDelete, groupPath(id), nil, nil, nil); err != nil {
+		return fmt.Errorf("failed to delete group: %w", err)
+	}
+
+	return nil
+}
+
+// AddGroupMember makes the user a member of the group, granting it the
+// group's role mappings
+func (c *Client) AddGroupMember(ctx context.Context, groupID string, userID string) error {
+	if _, err := c.do(ctx, http.MethodPut, userPath(userID)+groupPath(groupID), nil, nil, nil); err != nil {
+		return fmt.Errorf("failed to add group member: %w", err)
+	}
+
+	return nil
+}
+
+func (c *Client) RemoveGroupMember(ctx context.Context, groupID string, userID string) error {
+	if _, err := c.do(ctx, http.MethodDelete, userPath(userID)+groupPath(groupID), nil, nil, nil); err != nil {
+		return fmt.Errorf("failed to remove group member: %w", err)
+	}
+
+	return nil
+}
+
+func (c *Client) withRoles(ctx context.Context, rep *userRepresentation) (*KeycloakUser, error) {
+	var roles []roleRepresentation
+	if _, err := c.do(ctx, http.MethodGet, userPath(rep.ID)+"/role-mappings/realm", nil, nil, &roles); err != nil {
+		return nil, fmt.Errorf("failed to get realm roles: %w", err)
+	}
+
+	names := make([]string, len(roles))
+	for i, role := range roles {
+		names[i] = role.Name
+	}
+
+	return fromRepresentation(rep, names), nil
+}
+
+// GetEffectiveRealmRoles returns every realm role of the user, including
+// those granted through composite roles and groups, as in its access tokens
+func (c *Client) GetEffectiveRealmRoles(ctx context.Context, id string) ([]string, error) {
+	var roles []roleRepresentation
+	if _, err := c.do(ctx, http.MethodGet, userPath(id)+"/role-mappings/realm/composite", nil, nil, &roles); err != nil {
+		return nil, fmt.Errorf("failed to get effective realm roles: %w", err)
+	}
+
+	names := make([]string, len(roles))
+	for i, role := range roles {
+		names[i] = role.Name
+	}
+	return names, nil
+}
+
+func fromRepresentation(rep *userRepresentation, roles []string) *KeycloakUser {
+	var tenantID string
+	if values := rep.Attributes[tenantAttribute]; len(values) > 0 {
+		tenantID = values[0]
+	}
+
+	return &KeycloakUser{
+		ID:            rep.ID,
+		Username:      rep.Username,
+		Email:         rep.Email,
+		EmailVerified: rep.EmailVerified,
+		FirstName:     rep.FirstName,
+		LastName:      rep.LastName,
+		Enabled:       rep.Enabled,
+		Roles:         roles,
+		TenantID:      tenantID,
+		CreatedAt:     time.UnixMilli(rep.CreatedTimestamp).UTC(),
+	}
+}
+
+func (c *Client) assignRealmRoles(ctx context.Context, userID string, names []string) error {
+	roles := make([]roleRepresentation, len(names))
+	for i, name := range names {
+		if _, err := c.do(ctx, http.MethodGet, "/roles/"+url.PathEscape(name), nil, nil, &roles[i]); err != nil {
+			return fmt.Errorf("failed to get realm role %s: %w", name, err)
+		}
+	}
+
+	if _, err := c.do(ctx, http.MethodPost, userPath(userID)+"/role-mappings/realm", nil, roles, nil); err != nil {
+		return fmt.Errorf("failed to add realm role mappings: %w", err)
+	}
+
+	return nil
+}
+
+func toRepresentation(user *KeycloakUser) userRepresentation {
+	return userRepresentation{
+		Email:         user.Email,
+		EmailVerified: user.EmailVerified,
+		FirstName:     user.FirstName,
+		LastName:      user.LastName,
+		Enabled:       user.Enabled,
+	}
+}
+
+func userPath(id string) string {
+	return "/users/" + url.PathEscape(id)
+}
+
+func groupPath(id string) string {
+	return "/groups/" + url.PathEscape(id)
+}
+
+// do sends an authenticated Admin REST API request, encoding body and
+// decoding the response into out when they are not nil. A request rejected
+// with 401 is retried once with a new token in case the cached one was
+// revoked.
+func (c *Client) do(ctx context.Context, method string, resource string, query url.Values, body any, out any) (http.Header, error) {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return nil, fmt.Errorf("failed to encode request: %w", err)
+		}
+	}
+
+	endpoint := c.adminURL + resource
+	if len(query) > 0 {
+		endpoint += "?" + query.Encode()
+	}
+
+	for attempt := 0; ; attempt++ {
+		token, err := c.serviceToken(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		req, err := http.NewRequestWithContext(ctx, method, endpoint, bytes.NewReader(payload))
+		if err != nil {
+			return nil, fmt.Errorf("failed to create request: %w", err)
+		}
+		req.Header.Set("Authorization", "Bearer "+token)
+		req.Header.Set("Accept", "application/json")
+		if body != nil {
+			req.Header.Set("Content-Type", "application/json")
+		}
+
+		resp, err := c.httpClient.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("failed to send request: %w", err)
+		}
+
+		if resp.StatusCode == http.StatusUnauthorized && attempt == 0 {
+			resp.Body.Close()
+			c.invalidateToken(token)
+			continue
+		}
+
+		defer resp.Body.Close()
+
+		if resp.StatusCode >= 300 {
+			return nil, readAPIError(resp)
+		}
+
+		if out != nil {
+			if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+				return nil, fmt.Errorf("failed to decode response: %w", err)
+			}
+		}
+
+		return resp.Header, nil
+	}
+}
+
+// serviceToken returns the cached service account token, requesting a new
+// one when it is missing or about to expire
+func (c *Client) serviceToken(ctx context.Context) (string, error) {
+	c.tokenMu.Lock()
+	defer c.tokenMu.Unlock()
+
+	if c.token != "" && c.now().Before(c.tokenExpiresAt) {
+		return c.token, nil
+	}
+
+	token, err := c.requestToken(ctx, url.Values{"grant_type": {"client_credentials"}})
+	if err != nil {
+		return "", fmt.Errorf("failed to get service account token: %w", err)
+	}
+
+	lifetime := time.Duration(token.ExpiresIn) * time.Second
+	c.token = token.AccessToken
+	c.tokenExpiresAt = c.now().Add(lifetime - min(tokenExpiryMargin, lifetime/2))
+	return c.token, nil
+}
+
+// invalidateToken drops the cached token unless another request already replaced it
+func (c *Client) invalidateToken(token string) {
+	c.tokenMu.Lock()
+	defer c.tokenMu.Unlock()
+
+	if c.token == token {
+		c.token = ""
+	}
+}
+
+func (c *Client) requestToken(ctx context.Context, form url.Values) (*KeycloakToken, error) {
+	resp, err := c.postForm(ctx, c.tokenURL, form)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	var token KeycloakToken
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return nil, fmt.Errorf("failed to decode token response: %w", err)
+	}
+
+	return &token, nil
+}
+
+// postForm sends an OpenID Connect endpoint request authenticated as the
+// configured client, unless the form names another, and returns the
+// response if it succeeded
+func (c *Client) postForm(ctx context.Context, endpoint string, form url.Values) (*http.Response, error) {
+	if !form.Has("client_id") {
+		form.Set("client_id", c.clientID)
+		form.Set("client_secret", c.clientSecret)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create token request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to send token request: %w", err)
+	}
+
+	if resp.StatusCode >= 300 {
+		defer resp.Body.Close()
+		apiErr := readAPIError(resp)
+		// Wrong passwords and expired or revoked refresh tokens are the caller's
+		// problem, not a server error. Keycloak answers the former with 401.
+		if strings.HasPrefix(apiErr.Message, "invalid_grant") {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidToken, apiErr.Message)
+		}
+		return nil, apiErr
+	}
+
+	return resp, nil
+}
+
+// readAPIError extracts the message from the error formats used by the
+// Admin REST API ({"errorMessage"}) and the OpenID Connect endpoints
+// ({"error", "error_description"})
+func readAPIError(resp *http.Response) *APIError {
+	var body struct {
+		ErrorMessage     string `json:"errorMessage"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	data, _ := io.ReadAll(io.LimitReader(resp.Body, 64<<10))
+	json.Unmarshal(data, &body)
+
+	message := body.ErrorMessage
+	if message == "" && body.Error != "" {
+		message = body.Error
+		if body.ErrorDescription != "" {
+			message += ": " + body.ErrorDescription
+		}
+	}
+
+	return &APIError{StatusCode: resp.StatusCode, Message: message}
 }
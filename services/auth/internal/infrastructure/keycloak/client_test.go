@@ -0,0 +1,952 @@
+package keycloak
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/intellifinder/v4/services/auth/internal/config"
+	"github.com/intellifinder/v4/services/auth/internal/domain/apikey"
+	"github.com/intellifinder/v4/services/auth/internal/domain/authentication"
+	"github.com/intellifinder/v4/services/auth/internal/domain/scim"
+	"github.com/intellifinder/v4/services/auth/internal/domain/user"
+	"github.com/intellifinder/v4/services/auth/pkg/models"
+)
+
+const (
+	testClientID     = "auth-service"
+	testClientSecret = "secret"
+	// testServiceClientID is another service's client with a service account
+	testServiceClientID     = "tasks-service"
+	testServiceClientSecret = "tasks-secret"
+)
+
+// fakeKeycloak mimics the token and logout endpoints and the user and group endpoints of the Admin REST API
+type fakeKeycloak struct {
+	*httptest.Server
+
+	mu           sync.Mutex
+	users        map[string]userRepresentation
+	roles        map[string][]roleRepresentation // realm role mappings by user ID
+	realmRoles   map[string]roleRepresentation
+	tokens       map[string]bool // issued service account tokens that are still valid
+	tokensIssued int
+	tokenTTL     int
+	nextID       int
+	// actions lists the required actions emailed to each user
+	actions map[string][]string
+	// adminEvents and loginEvents are stored most recent first, like Keycloak lists them
+	adminEvents []*KeycloakEvent
+	loginEvents []*KeycloakEvent
+	groups      map[string]fakeGroup
+	// members holds the IDs of each user's groups
+	members map[string][]string
+}
+
+type fakeGroup struct {
+	name     string
+	parentID string
+}
+
+func newFakeKeycloak(t *testing.T) *fakeKeycloak {
+	t.Helper()
+	k := &fakeKeycloak{
+		users: make(map[string]userRepresentation),
+		roles: make(map[string][]roleRepresentation),
+		realmRoles: map[string]roleRepresentation{
+			"admin":  {ID: "role-admin", Name: "admin"},
+			"viewer": {ID: "role-viewer", Name: "viewer"},
+		},
+		tokens:   make(map[string]bool),
+		tokenTTL: 300,
+		actions:  make(map[string][]string),
+		groups:   make(map[string]fakeGroup),
+		members:  make(map[string][]string),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("POST /realms/"+testRealm+"/protocol/openid-connect/token", k.token)
+	mux.HandleFunc("POST /realms/"+testRealm+"/protocol/openid-connect/logout", k.logout)
+	mux.HandleFunc("GET /admin/realms/"+testRealm+"/users", k.authenticated(k.listUsers))
+	mux.HandleFunc("POST /admin/realms/"+testRealm+"/users", k.authenticated(k.createUser))
+	mux.HandleFunc("GET /admin/realms/"+testRealm+"/users/{id}", k.authenticated(k.getUser))
+	mux.HandleFunc("PUT /admin/realms/"+testRealm+"/users/{id}", k.authenticated(k.updateUser))
+	mux.HandleFunc("DELETE /admin/realms/"+testRealm+"/users/{id}", k.authenticated(k.deleteUser))
+	mux.HandleFunc("PUT /admin/realms/"+testRealm+"/users/{id}/execute-actions-email", k.authenticated(k.executeActionsEmail))
+	mux.HandleFunc("GET /admin/realms/"+testRealm+"/users/{id}/role-mappings/realm", k.authenticated(k.getRoleMappings))
+	mux.HandleFunc("POST /admin/realms/"+testRealm+"/users/{id}/role-mappings/realm", k.authenticated(k.addRoleMappings))
+	mux.HandleFunc("GET /admin/realms/"+testRealm+"/users/{id}/role-mappings/realm/composite", k.authenticated(k.getEffectiveRoles))
+	mux.HandleFunc("GET /admin/realms/"+testRealm+"/roles/{name}", k.authenticated(k.getRole))
+	mux.HandleFunc("GET /admin/realms/"+testRealm+"/groups", k.authenticated(k.searchGroups))
+	mux.HandleFunc("POST /admin/realms/"+testRealm+"/groups", k.authenticated(k.createGroup))
+	mux.HandleFunc("POST /admin/realms/"+testRealm+"/groups/{id}/children", k.authenticated(k.createGroup))
+	mux.HandleFunc("PUT /admin/realms/"+testRealm+"/groups/{id}", k.authenticated(k.updateGroup))
+	mux.HandleFunc("DELETE /admin/realms/"+testRealm+"/groups/{id}", k.authenticated(k.deleteGroup))
+	mux.HandleFunc("PUT /admin/realms/"+testRealm+"/users/{id}/groups/{group}", k.authenticated(k.joinGroup))
+	mux.HandleFunc("DELETE /admin/realms/"+testRealm+"/users/{id}/groups/{group}", k.authenticated(k.leaveGroup))
+	mux.HandleFunc("GET /admin/realms/"+testRealm+"/admin-events", k.authenticated(k.listEvents(&k.adminEvents, "resourceTypes")))
+	mux.HandleFunc("GET /admin/realms/"+testRealm+"/events", k.authenticated(k.listEvents(&k.loginEvents, "type")))
+
+	k.Server = httptest.NewServer(mux)
+	t.Cleanup(k.Close)
+	return k
+}
+
+func (k *fakeKeycloak) config() config.Keycloak {
+	return config.Keycloak{
+		URL:          k.URL,
+		Realm:        testRealm,
+		ClientID:     testClientID,
+		ClientSecret: testClientSecret,
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, value any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(value)
+}
+
+func (k *fakeKeycloak) token(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	clientID, secret := r.PostForm.Get("client_id"), r.PostForm.Get("client_secret")
+	if !(clientID == testClientID && secret == testClientSecret) && !(clientID == testServiceClientID && secret == testServiceClientSecret) {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client", "error_description": "Invalid client credentials"})
+		return
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	switch r.PostForm.Get("grant_type") {
+	case "client_credentials":
+		k.tokensIssued++
+		token := fmt.Sprintf("service-token-%d", k.tokensIssued)
+		k.tokens[token] = true
+		writeJSON(w, http.StatusOK, KeycloakToken{AccessToken: token, ExpiresIn: k.tokenTTL, TokenType: "Bearer"})
+	case "refresh_token":
+		if r.PostForm.Get("refresh_token") != "valid-refresh-token" {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant", "error_description": "Token is not active"})
+			return
+		}
+		writeJSON(w, http.StatusOK, KeycloakToken{AccessToken: "access", ExpiresIn: 300, RefreshToken: "next-refresh-token", ExpiresInRefresh: 1800, TokenType: "Bearer"})
+	case "password":
+		if r.PostForm.Get("username") != "alice" || r.PostForm.Get("password") != "correct horse" {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_grant", "error_description": "Invalid user credentials"})
+			return
+		}
+		writeJSON(w, http.StatusOK, KeycloakToken{AccessToken: "access", ExpiresIn: 300, RefreshToken: "valid-refresh-token", ExpiresInRefresh: 1800, TokenType: "Bearer"})
+	default:
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "unsupported_grant_type"})
+	}
+}
+
+func (k *fakeKeycloak) logout(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	if r.PostForm.Get("refresh_token") != "valid-refresh-token" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant", "error_description": "Invalid refresh token"})
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// revokeTokens invalidates every issued service account token, like a Keycloak restart would
+func (k *fakeKeycloak) revokeTokens() {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	clear(k.tokens)
+}
+
+func (k *fakeKeycloak) issued() int {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return k.tokensIssued
+}
+
+func (k *fakeKeycloak) authenticated(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+
+		k.mu.Lock()
+		defer k.mu.Unlock()
+
+		if !k.tokens[token] {
+			writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "HTTP 401 Unauthorized"})
+			return
+		}
+		next(w, r)
+	}
+}
+
+func (k *fakeKeycloak) listUsers(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	matches := []userRepresentation{}
+	for _, user := range k.users {
+		if (query.Has("email") && user.Email == query.Get("email")) || (query.Has("username") && user.Username == query.Get("username")) {
+			matches = append(matches, user)
+		}
+	}
+
+	// Without a search the users are paged in ID order
+	if !query.Has("email") && !query.Has("username") {
+		for _, user := range k.users {
+			matches = append(matches, user)
+		}
+		slices.SortFunc(matches, func(a, b userRepresentation) int { return strings.Compare(a.ID, b.ID) })
+
+		first, _ := strconv.Atoi(query.Get("first"))
+		max, _ := strconv.Atoi(query.Get("max"))
+		matches = matches[min(first, len(matches)):min(first+max, len(matches))]
+	}
+
+	writeJSON(w, http.StatusOK, matches)
+}
+
+func (k *fakeKeycloak) createUser(w http.ResponseWriter, r *http.Request) {
+	var rep userRepresentation
+	json.NewDecoder(r.Body).Decode(&rep)
+
+	for _, user := range k.users {
+		if user.Username == rep.Username || (rep.Email != "" && user.Email == rep.Email) {
+			writeJSON(w, http.StatusConflict, map[string]string{"errorMessage": "User exists with same username"})
+			return
+		}
+	}
+
+	k.nextID++
+	rep.ID = fmt.Sprintf("user-%d", k.nextID)
+	rep.CreatedTimestamp = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).UnixMilli()
+	k.users[rep.ID] = rep
+
+	w.Header().Set("Location", k.URL+"/admin/realms/"+testRealm+"/users/"+rep.ID)
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (k *fakeKeycloak) getUser(w http.ResponseWriter, r *http.Request) {
+	user, ok := k.users[r.PathValue("id")]
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "User not found"})
+		return
+	}
+	writeJSON(w, http.StatusOK, user)
+}
+
+func (k *fakeKeycloak) updateUser(w http.ResponseWriter, r *http.Request) {
+	user, ok := k.users[r.PathValue("id")]
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "User not found"})
+		return
+	}
+
+	var rep userRepresentation
+	json.NewDecoder(r.Body).Decode(&rep)
+	user.Email = rep.Email
+	user.EmailVerified = rep.EmailVerified
+	user.FirstName = rep.FirstName
+	user.LastName = rep.LastName
+	user.Enabled = rep.Enabled
+	k.users[user.ID] = user
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (k *fakeKeycloak) deleteUser(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if _, ok := k.users[id]; !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "User not found"})
+		return
+	}
+	delete(k.users, id)
+	delete(k.roles, id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (k *fakeKeycloak) executeActionsEmail(w http.ResponseWriter, r *http.Request) {
+	user, ok := k.users[r.PathValue("id")]
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "User not found"})
+		return
+	}
+	if !user.Enabled || user.Email == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"errorMessage": "User is disabled or has no email"})
+		return
+	}
+
+	var actions []string
+	json.NewDecoder(r.Body).Decode(&actions)
+	k.actions[user.ID] = append(k.actions[user.ID], actions...)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (k *fakeKeycloak) getRoleMappings(w http.ResponseWriter, r *http.Request) {
+	roles := k.roles[r.PathValue("id")]
+	if roles == nil {
+		roles = []roleRepresentation{}
+	}
+	writeJSON(w, http.StatusOK, roles)
+}
+
+// getEffectiveRoles adds the realm's default role, a composite every user holds
+func (k *fakeKeycloak) getEffectiveRoles(w http.ResponseWriter, r *http.Request) {
+	if _, ok := k.users[r.PathValue("id")]; !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "User not found"})
+		return
+	}
+	roles := append([]roleRepresentation{{ID: "role-user", Name: "user"}}, k.roles[r.PathValue("id")]...)
+	writeJSON(w, http.StatusOK, roles)
+}
+
+func (k *fakeKeycloak) addRoleMappings(w http.ResponseWriter, r *http.Request) {
+	var roles []roleRepresentation
+	json.NewDecoder(r.Body).Decode(&roles)
+	id := r.PathValue("id")
+	k.roles[id] = append(k.roles[id], roles...)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (k *fakeKeycloak) getRole(w http.ResponseWriter, r *http.Request) {
+	role, ok := k.realmRoles[r.PathValue("name")]
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "Could not find role"})
+		return
+	}
+	writeJSON(w, http.StatusOK, role)
+}
+
+// searchGroups returns the top-level groups with the searched name or a
+// subgroup of that name
+func (k *fakeKeycloak) searchGroups(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("search")
+	matches := []groupRepresentation{}
+	for id, group := range k.groups {
+		if group.name != name {
+			continue
+		}
+		if group.parentID != "" {
+			id = group.parentID
+		}
+		matches = append(matches, groupRepresentation{ID: id, Name: k.groups[id].name})
+	}
+	writeJSON(w, http.StatusOK, matches)
+}
+
+func (k *fakeKeycloak) createGroup(w http.ResponseWriter, r *http.Request) {
+	parentID := r.PathValue("id")
+	if _, ok := k.groups[parentID]; parentID != "" && !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "Could not find parent group"})
+		return
+	}
+
+	var rep groupRepresentation
+	json.NewDecoder(r.Body).Decode(&rep)
+	for _, group := range k.groups {
+		if group.parentID == parentID && group.name == rep.Name {
+			writeJSON(w, http.StatusConflict, map[string]string{"errorMessage": "Sibling group named '" + rep.Name + "' already exists."})
+			return
+		}
+	}
+
+	k.nextID++
+	id := fmt.Sprintf("group-%d", k.nextID)
+	k.groups[id] = fakeGroup{name: rep.Name, parentID: parentID}
+
+	w.Header().Set("Location", k.URL+"/admin/realms/"+testRealm+"/groups/"+id)
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (k *fakeKeycloak) updateGroup(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	group, ok := k.groups[id]
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "Could not find group by id"})
+		return
+	}
+
+	var rep groupRepresentation
+	json.NewDecoder(r.Body).Decode(&rep)
+	for otherID, other := range k.groups {
+		if otherID != id && other.parentID == group.parentID && other.name == rep.Name {
+			writeJSON(w, http.StatusConflict, map[string]string{"errorMessage": "Sibling group named '" + rep.Name + "' already exists."})
+			return
+		}
+	}
+
+	group.name = rep.Name
+	k.groups[id] = group
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (k *fakeKeycloak) deleteGroup(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if _, ok := k.groups[id]; !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "Could not find group by id"})
+		return
+	}
+	delete(k.groups, id)
+	for userID, groups := range k.members {
+		k.members[userID] = slices.DeleteFunc(groups, func(g string) bool { return g == id })
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (k *fakeKeycloak) joinGroup(w http.ResponseWriter, r *http.Request) {
+	id, groupID := r.PathValue("id"), r.PathValue("group")
+	_, userExists := k.users[id]
+	if _, ok := k.groups[groupID]; !ok || !userExists {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "Not found"})
+		return
+	}
+	if !slices.Contains(k.members[id], groupID) {
+		k.members[id] = append(k.members[id], groupID)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (k *fakeKeycloak) leaveGroup(w http.ResponseWriter, r *http.Request) {
+	id, groupID := r.PathValue("id"), r.PathValue("group")
+	_, userExists := k.users[id]
+	if _, ok := k.groups[groupID]; !ok || !userExists {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "Not found"})
+		return
+	}
+	k.members[id] = slices.DeleteFunc(k.members[id], func(g string) bool { return g == groupID })
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// listEvents serves a page of the events whose resource type or type is
+// listed in the filter parameter
+func (k *fakeKeycloak) listEvents(events *[]*KeycloakEvent, filter string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if _, err := time.Parse(time.DateOnly, query.Get("dateFrom")); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid dateFrom"})
+			return
+		}
+		first, _ := strconv.Atoi(query.Get("first"))
+		max, _ := strconv.Atoi(query.Get("max"))
+
+		var matching []*KeycloakEvent
+		for _, e := range *events {
+			if slices.Contains(query[filter], e.ResourceType) || slices.Contains(query[filter], e.Type) {
+				matching = append(matching, e)
+			}
+		}
+		writeJSON(w, http.StatusOK, matching[min(first, len(matching)):min(first+max, len(matching))])
+	}
+}
+
+func (k *fakeKeycloak) userCount() int {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	return len(k.users)
+}
+
+func createTestUser(t *testing.T, client *Client, username string, roles ...string) *KeycloakUser {
+	t.Helper()
+	user, err := client.CreateUser(context.Background(), &KeycloakUser{
+		Username:  username,
+		Email:     username + "@example.com",
+		FirstName: "Test",
+		LastName:  "User",
+		Enabled:   true,
+		Roles:     roles,
+	})
+	if err != nil {
+		t.Fatalf("CreateUser(%s) error = %v", username, err)
+	}
+	return user
+}
+
+func TestCreateAndGetUser(t *testing.T) {
+	keycloak := newFakeKeycloak(t)
+	client := NewClient(keycloak.config(), keycloak.Client())
+	ctx := context.Background()
+
+	created := createTestUser(t, client, "alice", "admin", "viewer")
+	if created.ID == "" || created.Username != "alice" || !created.Enabled || !slices.Equal(created.Roles, []string{"admin", "viewer"}) {
+		t.Errorf("CreateUser() = %+v, want alice with admin and viewer", created)
+	}
+	if !created.CreatedAt.Equal(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("CreatedAt = %v, want the Keycloak creation timestamp", created.CreatedAt)
+	}
+
+	byID, err := client.GetUser(ctx, created.ID)
+	if err != nil || byID.Email != "alice@example.com" {
+		t.Errorf("GetUser() = %+v, %v, want alice", byID, err)
+	}
+
+	byEmail, err := client.GetUserByEmail(ctx, "alice@example.com")
+	if err != nil || byEmail.ID != created.ID {
+		t.Errorf("GetUserByEmail() = %+v, %v, want alice", byEmail, err)
+	}
+
+	byUsername, err := client.GetUserByUsername(ctx, "alice")
+	if err != nil || byUsername.ID != created.ID || len(byUsername.Roles) != 2 {
+		t.Errorf("GetUserByUsername() = %+v, %v, want alice with her roles", byUsername, err)
+	}
+}
+
+func TestCreateUserErrors(t *testing.T) {
+	keycloak := newFakeKeycloak(t)
+	client := NewClient(keycloak.config(), keycloak.Client())
+	ctx := context.Background()
+
+	createTestUser(t, client, "alice")
+
+	_, err := client.CreateUser(ctx, &KeycloakUser{Username: "alice"})
+	var apiErr *APIError
+	if !errors.Is(err, ErrConflict) || !errors.As(err, &apiErr) || apiErr.Message != "User exists with same username" {
+		t.Errorf("CreateUser(duplicate) error = %v, want ErrConflict with Keycloak's message", err)
+	}
+
+	_, err = client.CreateUser(ctx, &KeycloakUser{Username: "bob", Roles: []string{"missing"}})
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("CreateUser(unknown role) error = %v, want ErrNotFound", err)
+	}
+	if got := keycloak.userCount(); got != 1 {
+		t.Errorf("users = %d, want the partially created user deleted", got)
+	}
+}
+
+func TestUpdateAndDeleteUser(t *testing.T) {
+	keycloak := newFakeKeycloak(t)
+	client := NewClient(keycloak.config(), keycloak.Client())
+	ctx := context.Background()
+
+	user := createTestUser(t, client, "alice", "viewer")
+	user.FirstName = "Alice"
+	user.Enabled = false
+
+	updated, err := client.UpdateUser(ctx, user)
+	if err != nil {
+		t.Fatalf("UpdateUser() error = %v", err)
+	}
+	if updated.FirstName != "Alice" || updated.Enabled || !slices.Equal(updated.Roles, []string{"viewer"}) {
+		t.Errorf("UpdateUser() = %+v, want renamed, disabled and roles kept", updated)
+	}
+
+	if err := client.DeleteUser(ctx, user.ID); err != nil {
+		t.Fatalf("DeleteUser() error = %v", err)
+	}
+
+	tests := map[string]func() error{
+		"GetUser": func() error {
+			_, err := client.GetUser(ctx, user.ID)
+			return err
+		},
+		"GetUserByEmail": func() error {
+			_, err := client.GetUserByEmail(ctx, "alice@example.com")
+			return err
+		},
+		"UpdateUser": func() error {
+			_, err := client.UpdateUser(ctx, user)
+			return err
+		},
+		"DeleteUser": func() error {
+			return client.DeleteUser(ctx, user.ID)
+		},
+	}
+	for name, call := range tests {
+		if err := call(); !errors.Is(err, ErrNotFound) {
+			t.Errorf("%s(deleted user) error = %v, want ErrNotFound", name, err)
+		}
+	}
+}
+
+func TestSendPasswordReset(t *testing.T) {
+	keycloak := newFakeKeycloak(t)
+	client := NewClient(keycloak.config(), keycloak.Client())
+	directory := NewUserDirectory(client)
+	ctx := context.Background()
+
+	alice := createTestUser(t, client, "alice")
+	if err := directory.SendPasswordReset(ctx, alice.ID); err != nil {
+		t.Fatalf("SendPasswordReset() error = %v", err)
+	}
+	if got := keycloak.actions[alice.ID]; !slices.Equal(got, []string{"UPDATE_PASSWORD"}) {
+		t.Errorf("actions emailed = %v, want [UPDATE_PASSWORD]", got)
+	}
+
+	alice.Enabled = false
+	client.UpdateUser(ctx, alice)
+	if err := directory.SendPasswordReset(ctx, alice.ID); !errors.Is(err, user.ErrInvalidUser) {
+		t.Errorf("SendPasswordReset() of a disabled user error = %v, want ErrInvalidUser", err)
+	}
+	if err := directory.SendPasswordReset(ctx, "missing"); !errors.Is(err, user.ErrUserNotFound) {
+		t.Errorf("SendPasswordReset() of an unknown user error = %v, want ErrUserNotFound", err)
+	}
+}
+
+func TestGetUserAccess(t *testing.T) {
+	keycloak := newFakeKeycloak(t)
+	client := NewClient(keycloak.config(), keycloak.Client())
+	directory := NewUserDirectory(client)
+	ctx := context.Background()
+
+	alice := createTestUser(t, client, "alice", "viewer")
+	rep := keycloak.users[alice.ID]
+	rep.Attributes = map[string][]string{"tenant_id": {"tenant-1"}}
+	keycloak.users[alice.ID] = rep
+
+	roles, tenantID, err := directory.GetUserAccess(ctx, alice.ID)
+	if err != nil {
+		t.Fatalf("GetUserAccess() error = %v", err)
+	}
+	if !slices.Equal(roles, []string{"user", "viewer"}) || tenantID != "tenant-1" {
+		t.Errorf("GetUserAccess() = %v, %q, want [user viewer] in tenant-1", roles, tenantID)
+	}
+
+	if _, _, err := directory.GetUserAccess(ctx, "missing"); !errors.Is(err, user.ErrUserNotFound) {
+		t.Errorf("GetUserAccess() of an unknown user error = %v, want ErrUserNotFound", err)
+	}
+}
+
+func TestGetOwnerRoles(t *testing.T) {
+	keycloak := newFakeKeycloak(t)
+	client := NewClient(keycloak.config(), keycloak.Client())
+	directory := NewUserDirectory(client)
+	ctx := context.Background()
+
+	alice := createTestUser(t, client, "alice", "viewer")
+	roles, err := directory.GetOwnerRoles(ctx, alice.ID)
+	if err != nil {
+		t.Fatalf("GetOwnerRoles() error = %v", err)
+	}
+	if !slices.Equal(roles, []string{"user", "viewer"}) {
+		t.Errorf("GetOwnerRoles() = %v, want [user viewer]", roles)
+	}
+
+	rep := keycloak.users[alice.ID]
+	rep.Enabled = false
+	keycloak.users[alice.ID] = rep
+	if _, err := directory.GetOwnerRoles(ctx, alice.ID); !errors.Is(err, apikey.ErrOwnerInactive) {
+		t.Errorf("GetOwnerRoles() of a disabled user error = %v, want ErrOwnerInactive", err)
+	}
+	if _, err := directory.GetOwnerRoles(ctx, "missing"); !errors.Is(err, apikey.ErrOwnerInactive) {
+		t.Errorf("GetOwnerRoles() of an unknown user error = %v, want ErrOwnerInactive", err)
+	}
+}
+
+func TestTenantDirectory(t *testing.T) {
+	keycloak := newFakeKeycloak(t)
+	client := NewClient(keycloak.config(), keycloak.Client())
+	directory := NewTenantDirectory(client)
+	ctx := context.Background()
+
+	alice, err := directory.CreateUser(ctx, &models.User{Username: "alice", Email: "alice@example.com", Enabled: true}, "tenant-1")
+	if err != nil {
+		t.Fatalf("CreateUser() error = %v", err)
+	}
+	if got := keycloak.users[alice.OIDCID].Attributes["tenant_id"]; !slices.Equal(got, []string{"tenant-1"}) {
+		t.Errorf("tenant attribute = %v, want [tenant-1]", got)
+	}
+	if _, err := directory.CreateUser(ctx, &models.User{Username: "alice"}, "tenant-1"); !errors.Is(err, user.ErrUserExists) {
+		t.Errorf("CreateUser() taken username error = %v, want ErrUserExists", err)
+	}
+
+	engineering, err := directory.CreateGroup(ctx, "tenant-1", "Engineering")
+	if err != nil {
+		t.Fatalf("CreateGroup() error = %v", err)
+	}
+	if _, err := directory.CreateGroup(ctx, "tenant-1", "Engineering"); !errors.Is(err, scim.ErrUniqueness) {
+		t.Errorf("CreateGroup() same name error = %v, want ErrUniqueness", err)
+	}
+
+	// Another tenant can use the name, and a tenant named like a subgroup gets its own group
+	other, err := directory.CreateGroup(ctx, "tenant-2", "Engineering")
+	if err != nil {
+		t.Fatalf("CreateGroup() other tenant error = %v", err)
+	}
+	if _, err := directory.CreateGroup(ctx, "Engineering", "Sales"); err != nil {
+		t.Fatalf("CreateGroup() tenant named Engineering error = %v", err)
+	}
+
+	tenant1 := keycloak.groups[engineering].parentID
+	if keycloak.groups[tenant1].name != "tenant-1" || keycloak.groups[other].parentID == tenant1 {
+		t.Errorf("groups = %+v, want the Engineering groups under their tenants' groups", keycloak.groups)
+	}
+	var tenants []string
+	for _, g := range keycloak.groups {
+		if g.parentID == "" {
+			tenants = append(tenants, g.name)
+		}
+	}
+	slices.Sort(tenants)
+	if !slices.Equal(tenants, []string{"Engineering", "tenant-1", "tenant-2"}) {
+		t.Errorf("top-level groups = %v, want one per tenant", tenants)
+	}
+
+	if err := directory.RenameGroup(ctx, other, "Platform"); err != nil {
+		t.Fatalf("RenameGroup() error = %v", err)
+	}
+	if keycloak.groups[other].name != "Platform" {
+		t.Errorf("renamed group = %+v, want Platform", keycloak.groups[other])
+	}
+
+	if err := directory.AddGroupMember(ctx, engineering, alice.OIDCID); err != nil {
+		t.Fatalf("AddGroupMember() error = %v", err)
+	}
+	if !slices.Equal(keycloak.members[alice.OIDCID], []string{engineering}) {
+		t.Errorf("memberships = %v, want [%s]", keycloak.members[alice.OIDCID], engineering)
+	}
+	if err := directory.RemoveGroupMember(ctx, engineering, alice.OIDCID); err != nil {
+		t.Fatalf("RemoveGroupMember() error = %v", err)
+	}
+	if len(keycloak.members[alice.OIDCID]) != 0 {
+		t.Errorf("memberships = %v, want none", keycloak.members[alice.OIDCID])
+	}
+
+	if err := directory.DeleteGroup(ctx, engineering); err != nil {
+		t.Fatalf("DeleteGroup() error = %v", err)
+	}
+	if err := directory.DeleteGroup(ctx, engineering); err != nil {
+		t.Errorf("DeleteGroup() again error = %v, want nil", err)
+	}
+	if err := directory.RemoveGroupMember(ctx, engineering, alice.OIDCID); err != nil {
+		t.Errorf("RemoveGroupMember() of a deleted group error = %v, want nil", err)
+	}
+	if err := directory.AddGroupMember(ctx, engineering, alice.OIDCID); !errors.Is(err, scim.ErrResourceNotFound) {
+		t.Errorf("AddGroupMember() to a deleted group error = %v, want ErrResourceNotFound", err)
+	}
+
+	if err := directory.DeleteUser(ctx, alice.OIDCID); err != nil {
+		t.Fatalf("DeleteUser() error = %v", err)
+	}
+	if _, ok := keycloak.users[alice.OIDCID]; ok {
+		t.Errorf("users = %v, want alice deleted", keycloak.users)
+	}
+	if err := directory.DeleteUser(ctx, alice.OIDCID); err != nil {
+		t.Errorf("DeleteUser() again error = %v, want nil", err)
+	}
+}
+
+func TestServiceAccountTokenIsCached(t *testing.T) {
+	keycloak := newFakeKeycloak(t)
+	client := NewClient(keycloak.config(), keycloak.Client())
+	ctx := context.Background()
+
+	now := time.Now()
+	client.now = func() time.Time { return now }
+
+	user := createTestUser(t, client, "alice")
+	for range 3 {
+		if _, err := client.GetUser(ctx, user.ID); err != nil {
+			t.Fatalf("GetUser() error = %v", err)
+		}
+	}
+	if got := keycloak.issued(); got != 1 {
+		t.Errorf("tokens issued = %d, want 1 cached token", got)
+	}
+
+	// Renewed ahead of its expiry
+	now = now.Add(271 * time.Second)
+	if _, err := client.GetUser(ctx, user.ID); err != nil {
+		t.Fatalf("GetUser() error = %v", err)
+	}
+	if got := keycloak.issued(); got != 2 {
+		t.Errorf("tokens issued = %d, want a new token near expiry", got)
+	}
+
+	// A token Keycloak no longer accepts is replaced and the request retried
+	keycloak.revokeTokens()
+	if _, err := client.GetUser(ctx, user.ID); err != nil {
+		t.Fatalf("GetUser() after revocation error = %v", err)
+	}
+	if got := keycloak.issued(); got != 3 {
+		t.Errorf("tokens issued = %d, want a new token after revocation", got)
+	}
+}
+
+func TestInvalidClientCredentials(t *testing.T) {
+	keycloak := newFakeKeycloak(t)
+	cfg := keycloak.config()
+	cfg.ClientSecret = "wrong"
+	client := NewClient(cfg, keycloak.Client())
+
+	_, err := client.GetUser(context.Background(), "user-1")
+	var apiErr *APIError
+	if !errors.Is(err, ErrUnauthorized) || !errors.As(err, &apiErr) || apiErr.Message != "invalid_client: Invalid client credentials" {
+		t.Errorf("GetUser() error = %v, want ErrUnauthorized", err)
+	}
+}
+
+func TestIssueServiceTokens(t *testing.T) {
+	keycloak := newFakeKeycloak(t)
+	issuer := NewTokenIssuer(NewClient(keycloak.config(), keycloak.Client()))
+	ctx := context.Background()
+
+	tokens, err := issuer.IssueServiceTokens(ctx, testServiceClientID, testServiceClientSecret)
+	if err != nil {
+		t.Fatalf("IssueServiceTokens() error = %v", err)
+	}
+	if tokens.AccessToken == "" || tokens.ExpiresIn != 300 {
+		t.Errorf("IssueServiceTokens() = %+v, want an access token valid for 300s", tokens)
+	}
+
+	if _, err := issuer.IssueServiceTokens(ctx, testServiceClientID, "wrong"); !errors.Is(err, authentication.ErrInvalidCredentials) {
+		t.Errorf("IssueServiceTokens() with a wrong secret error = %v, want ErrInvalidCredentials", err)
+	}
+}
+
+func TestRefreshToken(t *testing.T) {
+	keycloak := newFakeKeycloak(t)
+	client := NewClient(keycloak.config(), keycloak.Client())
+	ctx := context.Background()
+
+	token, err := client.RefreshToken(ctx, "valid-refresh-token")
+	if err != nil {
+		t.Fatalf("RefreshToken() error = %v", err)
+	}
+	if token.RefreshToken != "next-refresh-token" || token.ExpiresInRefresh != 1800 {
+		t.Errorf("RefreshToken() = %+v, want the rotated refresh token", token)
+	}
+
+	if _, err := client.RefreshToken(ctx, "expired"); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("RefreshToken(expired) error = %v, want ErrInvalidToken", err)
+	}
+}
+
+func TestLoginAndLogout(t *testing.T) {
+	keycloak := newFakeKeycloak(t)
+	client := NewClient(keycloak.config(), keycloak.Client())
+	ctx := context.Background()
+
+	token, err := client.Login(ctx, "alice", "correct horse")
+	if err != nil {
+		t.Fatalf("Login() error = %v", err)
+	}
+	if token.AccessToken == "" || token.RefreshToken != "valid-refresh-token" {
+		t.Errorf("Login() = %+v, want access and refresh tokens", token)
+	}
+
+	// Keycloak answers wrong passwords with 401 rather than 400
+	if _, err := client.Login(ctx, "alice", "wrong"); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Login(wrong password) error = %v, want ErrInvalidToken", err)
+	}
+
+	if err := client.Logout(ctx, token.RefreshToken); err != nil {
+		t.Errorf("Logout() error = %v", err)
+	}
+	if err := client.Logout(ctx, "expired"); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Logout(expired) error = %v, want ErrInvalidToken", err)
+	}
+}
+
+func TestListUsers(t *testing.T) {
+	k := newFakeKeycloak(t)
+	client := NewClient(k.config(), k.Client())
+	ctx := context.Background()
+
+	for _, name := range []string{"alice", "bob", "carol"} {
+		if _, err := client.CreateUser(ctx, &KeycloakUser{Username: name, Email: name + "@example.com", Enabled: true}); err != nil {
+			t.Fatalf("CreateUser() error = %v", err)
+		}
+	}
+
+	page, err := client.ListUsers(ctx, 0, 2)
+	if err != nil {
+		t.Fatalf("ListUsers() error = %v", err)
+	}
+	if len(page) != 2 || page[0].Username != "alice" || page[1].Username != "bob" {
+		t.Errorf("ListUsers(0, 2) = %v, want alice and bob", page)
+	}
+
+	directory := NewUserDirectory(client)
+	rest, err := directory.ListUsers(ctx, 2, 2)
+	if err != nil {
+		t.Fatalf("UserDirectory.ListUsers() error = %v", err)
+	}
+	if len(rest) != 1 || rest[0].Username != "carol" || rest[0].OIDCID != "user-3" || !rest[0].Enabled {
+		t.Errorf("UserDirectory.ListUsers(2, 2) = %+v, want carol as user-3", rest)
+	}
+
+	if _, err := directory.GetUser(ctx, "user-9"); !errors.Is(err, user.ErrUserNotFound) {
+		t.Errorf("UserDirectory.GetUser() unknown error = %v, want ErrUserNotFound", err)
+	}
+}
+
+func TestEventSource(t *testing.T) {
+	keycloak := newFakeKeycloak(t)
+	source := NewEventSource(NewClient(keycloak.config(), keycloak.Client()))
+	ctx := context.Background()
+
+	since := time.Now().Truncate(time.Millisecond)
+	at := func(offset time.Duration) int64 {
+		return since.Add(offset).UnixMilli()
+	}
+
+	// More updates than fit on a page, then older events that are left out
+	for i := eventPageSize + 10; i > 0; i-- {
+		keycloak.adminEvents = append(keycloak.adminEvents, &KeycloakEvent{Time: at(time.Duration(i) * time.Second), OperationType: "UPDATE", ResourceType: "USER", ResourcePath: "users/kc-1"})
+	}
+	keycloak.adminEvents = append([]*KeycloakEvent{
+		{Time: at(5 * time.Minute), OperationType: "DELETE", ResourceType: "USER_SESSION", ResourcePath: "sessions/sid-1"},
+		{Time: at(4 * time.Minute), OperationType: "ACTION", ResourceType: "USER", ResourcePath: "users/kc-2/logout"},
+		{Time: at(4 * time.Minute), OperationType: "ACTION", ResourceType: "USER", ResourcePath: "users/kc-2/reset-password"},
+		{Time: at(3 * time.Minute), OperationType: "CREATE", ResourceType: "REALM_ROLE_MAPPING", ResourcePath: "users/kc-2/role-mappings/realm"},
+	}, keycloak.adminEvents...)
+	keycloak.adminEvents = append(keycloak.adminEvents,
+		&KeycloakEvent{Time: at(-time.Second), OperationType: "DELETE", ResourceType: "USER", ResourcePath: "users/kc-old"},
+		&KeycloakEvent{Time: at(time.Hour), OperationType: "DELETE", ResourceType: "USER", ResourcePath: "users/kc-misordered"},
+	)
+	keycloak.loginEvents = []*KeycloakEvent{
+		{Time: at(2 * time.Minute), Type: "LOGOUT", UserID: "kc-3", SessionID: "sid-3"},
+		{Time: at(2 * time.Minute), Type: "UPDATE_PROFILE", UserID: "kc-3", Error: "invalid_input"},
+		{Time: at(0), Type: "UPDATE_EMAIL", UserID: "kc-3"},
+		{Time: at(-time.Minute), Type: "DELETE_ACCOUNT", UserID: "kc-old"},
+	}
+
+	events, err := source.ListEvents(ctx, since)
+	if err != nil {
+		t.Fatalf("ListEvents() error = %v", err)
+	}
+
+	var got []string
+	seen := make(map[string]bool)
+	for i, event := range events {
+		if i > 0 && event.OccurredAt.Before(events[i-1].OccurredAt) {
+			t.Errorf("ListEvents() event %d is older than the one before it", i)
+		}
+		if seen[event.ID] {
+			t.Errorf("ListEvents() returned event ID %s twice", event.ID)
+		}
+		seen[event.ID] = true
+		got = append(got, event.Type+" "+event.UserID+" "+event.SessionID)
+	}
+	want := []string{"user_changed kc-3 "}
+	for range eventPageSize + 10 {
+		want = append(want, "user_changed kc-1 ")
+	}
+	want = append(want, "logout kc-3 sid-3", "roles_changed kc-2 ", "logout kc-2 ", "logout  sid-1")
+	if !slices.Equal(got, want) {
+		t.Errorf("ListEvents() = %q, want %q", got, want)
+	}
+}
+
+func TestParseEvents(t *testing.T) {
+	source := NewEventSource(nil)
+
+	events, err := source.ParseEvents([]byte(`{"time": 1700000000000, "operationType": "DELETE", "resourceType": "USER", "resourcePath": "users/kc-1"}`))
+	if err != nil || len(events) != 1 || events[0].Type != "user_changed" || events[0].UserID != "kc-1" || events[0].OccurredAt.UnixMilli() != 1700000000000 {
+		t.Errorf("ParseEvents() of one event = %+v, %v, want the user changed", events, err)
+	}
+
+	events, err = source.ParseEvents([]byte(`[{"time": 1, "type": "LOGIN", "userId": "kc-1"}, {"time": 2, "type": "LOGOUT", "userId": "kc-1", "sessionId": "sid-1"}]`))
+	if err != nil || len(events) != 1 || events[0].SessionID != "sid-1" {
+		t.Errorf("ParseEvents() of an array = %+v, %v, want only the logout", events, err)
+	}
+
+	for _, body := range []string{`not json`, `{"type": "LOGOUT"}`, `[null]`} {
+		if _, err := source.ParseEvents([]byte(body)); err == nil {
+			t.Errorf("ParseEvents(%s) succeeded, want an error", body)
+		}
+	}
+}
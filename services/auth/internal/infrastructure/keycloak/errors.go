@@ -0,0 +1,52 @@
+package keycloak
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+var (
+	// ErrInvalidToken is returned for tokens that are malformed, badly signed or
+	// not meant for this service
+	ErrInvalidToken = errors.New("invalid token")
+	// ErrTokenExpired is returned for otherwise valid tokens past their expiry
+	ErrTokenExpired = errors.New("token expired")
+	// ErrJWKSUnavailable is returned when the signing keys can't be fetched
+	ErrJWKSUnavailable = errors.New("signing keys unavailable")
+
+	// ErrNotFound is returned when Keycloak answers 404, e.g. for unknown users
+	ErrNotFound = errors.New("not found")
+	// ErrConflict is returned when Keycloak answers 409, e.g. for a taken username or email
+	ErrConflict = errors.New("conflict")
+	// ErrUnauthorized is returned when Keycloak rejects the client's credentials
+	// or its service account lacks the required roles
+	ErrUnauthorized = errors.New("unauthorized")
+)
+
+// APIError is a failed Keycloak request. It unwraps to ErrNotFound,
+// ErrConflict or ErrUnauthorized depending on the status code.
+type APIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *APIError) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("keycloak: %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	}
+	return fmt.Sprintf("keycloak: %d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+func (e *APIError) Unwrap() error {
+	switch e.StatusCode {
+	case http.StatusNotFound:
+		return ErrNotFound
+	case http.StatusConflict:
+		return ErrConflict
+	case http.StatusUnauthorized, http.StatusForbidden:
+		return ErrUnauthorized
+	default:
+		return nil
+	}
+}
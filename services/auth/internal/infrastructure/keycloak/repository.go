@@ -1 +1,552 @@
 package keycloak
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/intellifinder/v4/services/auth/internal/domain/apikey"
+	"github.com/intellifinder/v4/services/auth/internal/domain/authentication"
+	"github.com/intellifinder/v4/services/auth/internal/domain/ingestion"
+	"github.com/intellifinder/v4/services/auth/internal/domain/scim"
+	"github.com/intellifinder/v4/services/auth/internal/domain/user"
+	"github.com/intellifinder/v4/services/auth/pkg/models"
+)
+
+var (
+	_ authentication.TokenVerifier = (*Validator)(nil)
+	_ authentication.TokenIssuer   = (*TokenIssuer)(nil)
+	_ user.Directory               = (*UserDirectory)(nil)
+	_ apikey.Owners                = (*UserDirectory)(nil)
+	_ scim.Directory               = (*TenantDirectory)(nil)
+	_ ingestion.Source             = (*EventSource)(nil)
+	_ ingestion.Parser             = (*EventSource)(nil)
+)
+
+// VerifyAccessToken validates a Keycloak access token and returns the
+// identity of its subject with its realm roles
+func (v *Validator) VerifyAccessToken(ctx context.Context, token string) (*models.Identity, error) {
+	claims, err := v.Validate(ctx, token)
+	switch {
+	case errors.Is(err, ErrTokenExpired):
+		return nil, authentication.ErrCredentialsExpired
+	case errors.Is(err, ErrJWKSUnavailable):
+		return nil, fmt.Errorf("%w: %w", authentication.ErrUnavailable, err)
+	case err != nil:
+		return nil, fmt.Errorf("%w: %w", authentication.ErrInvalidCredentials, err)
+	}
+
+	if claims.Subject == "" {
+		return nil, fmt.Errorf("%w: token has no subject", authentication.ErrInvalidCredentials)
+	}
+
+	// Tokens without an issue time count as issued before any revocation of their user
+	var issuedAt time.Time
+	if claims.IssuedAt != nil {
+		issuedAt = claims.IssuedAt.Time
+	}
+
+	return &models.Identity{
+		Subject:   claims.Subject,
+		Username:  claims.PreferredUsername,
+		Email:     claims.Email,
+		FirstName: claims.GivenName,
+		LastName:  claims.FamilyName,
+		Roles:     claims.RealmRoles(),
+		TenantID:  claims.TenantID,
+		SessionID: claims.SessionID,
+		TokenID:   claims.ID,
+		IssuedAt:  issuedAt,
+		ClientID:  claims.AuthorizedParty,
+		Method:    models.AuthMethodJWT,
+		ExpiresAt: claims.ExpiresAt.Time,
+		// Usernames are chosen by whoever creates a user; the client_id claim
+		// is only set by Keycloak
+		ServiceAccount: claims.ClientID != "" && claims.ClientID == claims.AuthorizedParty,
+	}, nil
+}
+
+// TokenIssuer logs users in through the realm's token endpoint
+type TokenIssuer struct {
+	client *Client
+}
+
+func NewTokenIssuer(client *Client) *TokenIssuer {
+	return &TokenIssuer{client: client}
+}
+
+func (i *TokenIssuer) IssueTokens(ctx context.Context, username string, password string) (*models.TokenSet, error) {
+	token, err := i.client.Login(ctx, username, password)
+	if err != nil {
+		return nil, issuerError(err)
+	}
+
+	return toTokenSet(token), nil
+}
+
+func (i *TokenIssuer) IssueServiceTokens(ctx context.Context, clientID string, clientSecret string) (*models.TokenSet, error) {
+	token, err := i.client.ClientCredentialsToken(ctx, clientID, clientSecret)
+	if err != nil {
+		return nil, issuerError(err)
+	}
+
+	return toTokenSet(token), nil
+}
+
+func (i *TokenIssuer) RefreshTokens(ctx context.Context, refreshToken string) (*models.TokenSet, error) {
+	token, err := i.client.RefreshToken(ctx, refreshToken)
+	if err != nil {
+		return nil, issuerError(err)
+	}
+
+	return toTokenSet(token), nil
+}
+
+func (i *TokenIssuer) RevokeTokens(ctx context.Context, refreshToken string) error {
+	if err := i.client.Logout(ctx, refreshToken); err != nil {
+		return issuerError(err)
+	}
+
+	return nil
+}
+
+func issuerError(err error) error {
+	if errors.Is(err, ErrInvalidToken) {
+		return fmt.Errorf("%w: %w", authentication.ErrInvalidCredentials, err)
+	}
+	return err
+}
+
+func toTokenSet(token *KeycloakToken) *models.TokenSet {
+	return &models.TokenSet{
+		AccessToken:      token.AccessToken,
+		RefreshToken:     token.RefreshToken,
+		TokenType:        token.TokenType,
+		ExpiresIn:        token.ExpiresIn,
+		RefreshExpiresIn: token.ExpiresInRefresh,
+	}
+}
+
+// UserDirectory serves the realm's users to the local user store
+type UserDirectory struct {
+	client *Client
+}
+
+func NewUserDirectory(client *Client) *UserDirectory {
+	return &UserDirectory{client: client}
+}
+
+func (d *UserDirectory) ListUsers(ctx context.Context, first int, max int) ([]*models.User, error) {
+	users, err := d.client.ListUsers(ctx, first, max)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*models.User, len(users))
+	for i, u := range users {
+		result[i] = toModel(u)
+	}
+
+	return result, nil
+}
+
+func (d *UserDirectory) GetUser(ctx context.Context, oidcID string) (*models.User, error) {
+	u, err := d.client.GetUser(ctx, oidcID)
+	if err != nil {
+		return nil, directoryError(err)
+	}
+
+	return toModel(u), nil
+}
+
+func (d *UserDirectory) CreateUser(ctx context.Context, u *models.User, roles []string) (*models.User, error) {
+	created, err := d.client.CreateUser(ctx, &KeycloakUser{
+		Username:  u.Username,
+		Email:     u.Email,
+		FirstName: u.FirstName,
+		LastName:  u.LastName,
+		Enabled:   u.Enabled,
+		Roles:     roles,
+	})
+	// The only lookup that can fail with 404 while creating a user is the one of a role
+	if errors.Is(err, ErrNotFound) {
+		return nil, fmt.Errorf("%w: unknown role: %w", user.ErrInvalidUser, err)
+	}
+	if err != nil {
+		return nil, directoryError(err)
+	}
+
+	return toModel(created), nil
+}
+
+// UpdateUser keeps whether the email is verified unless the email changes
+func (d *UserDirectory) UpdateUser(ctx context.Context, u *models.User) (*models.User, error) {
+	existing, err := d.client.GetUser(ctx, u.OIDCID)
+	if err != nil {
+		return nil, directoryError(err)
+	}
+
+	if !strings.EqualFold(existing.Email, u.Email) {
+		existing.EmailVerified = false
+	}
+	existing.Email = u.Email
+	existing.FirstName = u.FirstName
+	existing.LastName = u.LastName
+	existing.Enabled = u.Enabled
+
+	updated, err := d.client.UpdateUser(ctx, existing)
+	if err != nil {
+		return nil, directoryError(err)
+	}
+
+	return toModel(updated), nil
+}
+
+func (d *UserDirectory) DeleteUser(ctx context.Context, oidcID string) error {
+	if err := d.client.DeleteUser(ctx, oidcID); err != nil {
+		return directoryError(err)
+	}
+
+	return nil
+}
+
+// SendPasswordReset emails the user Keycloak's link to update their password
+func (d *UserDirectory) SendPasswordReset(ctx context.Context, oidcID string) error {
+	if err := d.client.ExecuteActionsEmail(ctx, oidcID, []string{"UPDATE_PASSWORD"}); err != nil {
+		return directoryError(err)
+	}
+
+	return nil
+}
+
+// GetUserAccess returns the realm roles and tenant the user's access tokens carry
+func (d *UserDirectory) GetUserAccess(ctx context.Context, oidcID string) ([]string, string, error) {
+	u, err := d.client.GetUser(ctx, oidcID)
+	if err != nil {
+		return nil, "", directoryError(err)
+	}
+
+	roles, err := d.client.GetEffectiveRealmRoles(ctx, oidcID)
+	if err != nil {
+		return nil, "", directoryError(err)
+	}
+
+	return roles, u.TenantID, nil
+}
+
+// GetOwnerRoles returns the realm roles an API key owner holds. Keys of users
+// who are disabled or were deleted in Keycloak stop working.
+func (d *UserDirectory) GetOwnerRoles(ctx context.Context, oidcID string) ([]string, error) {
+	u, err := d.client.GetUser(ctx, oidcID)
+	if errors.Is(err, ErrNotFound) {
+		return nil, fmt.Errorf("%w: %w", apikey.ErrOwnerInactive, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if !u.Enabled {
+		return nil, apikey.ErrOwnerInactive
+	}
+
+	return d.client.GetEffectiveRealmRoles(ctx, oidcID)
+}
+
+// directoryError maps Keycloak's answers to the user domain's errors. Keycloak
+// rejects invalid user data with 400.
+func directoryError(err error) error {
+	var apiErr *APIError
+	switch {
+	case errors.Is(err, ErrNotFound):
+		return fmt.Errorf("%w: %w", user.ErrUserNotFound, err)
+	case errors.Is(err, ErrConflict):
+		return fmt.Errorf("%w: %w", user.ErrUserExists, err)
+	case errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusBadRequest:
+		return fmt.Errorf("%w: %w", user.ErrInvalidUser, err)
+	default:
+		return err
+	}
+}
+
+func toModel(u *KeycloakUser) *models.User {
+	return &models.User{
+		OIDCID:    u.ID,
+		Username:  u.Username,
+		Email:     u.Email,
+		FirstName: u.FirstName,
+		LastName:  u.LastName,
+		Enabled:   u.Enabled,
+	}
+}
+
+// TenantDirectory creates the users and groups tenants provision. Each
+// tenant's groups are subgroups of a top-level group named after the
+// tenant, so group names only need to be unique per tenant.
+type TenantDirectory struct {
+	client *Client
+}
+
+func NewTenantDirectory(client *Client) *TenantDirectory {
+	return &TenantDirectory{client: client}
+}
+
+// CreateUser creates the user without roles; they come from its groups
+func (d *TenantDirectory) CreateUser(ctx context.Context, u *models.User, tenantID string) (*models.User, error) {
+	created, err := d.client.CreateUser(ctx, &KeycloakUser{
+		Username:  u.Username,
+		Email:     u.Email,
+		FirstName: u.FirstName,
+		LastName:  u.LastName,
+		Enabled:   u.Enabled,
+		TenantID:  tenantID,
+	})
+	if err != nil {
+		return nil, directoryError(err)
+	}
+
+	return toModel(created), nil
+}
+
+func (d *TenantDirectory) DeleteUser(ctx context.Context, oidcID string) error {
+	if err := d.client.DeleteUser(ctx, oidcID); err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
+
+	return nil
+}
+
+func (d *TenantDirectory) CreateGroup(ctx context.Context, tenantID string, name string) (string, error) {
+	parentID, err := d.tenantGroup(ctx, tenantID)
+	if err != nil {
+		return "", err
+	}
+
+	id, err := d.client.CreateGroup(ctx, parentID, name)
+	if err != nil {
+		return "", groupError(err)
+	}
+
+	return id, nil
+}
+
+func (d *TenantDirectory) RenameGroup(ctx context.Context, id string, name string) error {
+	if err := d.client.RenameGroup(ctx, id, name); err != nil {
+		return groupError(err)
+	}
+
+	return nil
+}
+
+func (d *TenantDirectory) DeleteGroup(ctx context.Context, id string) error {
+	if err := d.client.DeleteGroup(ctx, id); err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
+
+	return nil
+}
+
+func (d *TenantDirectory) AddGroupMember(ctx context.Context, groupID string, oidcID string) error {
+	if err := d.client.AddGroupMember(ctx, groupID, oidcID); err != nil {
+		return groupError(err)
+	}
+
+	return nil
+}
+
+func (d *TenantDirectory) RemoveGroupMember(ctx context.Context, groupID string, oidcID string) error {
+	if err := d.client.RemoveGroupMember(ctx, groupID, oidcID); err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
+
+	return nil
+}
+
+// tenantGroup returns the ID of the tenant's top-level group, creating it
+// the first time. A concurrent creation makes Keycloak answer 409, after
+// which the group is looked up again.
+func (d *TenantDirectory) tenantGroup(ctx context.Context, tenantID string) (string, error) {
+	id, err := d.client.FindGroup(ctx, tenantID)
+	if !errors.Is(err, ErrNotFound) {
+		return id, err
+	}
+
+	id, err = d.client.CreateGroup(ctx, "", tenantID)
+	if errors.Is(err, ErrConflict) {
+		return d.client.FindGroup(ctx, tenantID)
+	}
+	return id, err
+}
+
+// groupError maps a taken group name to ErrUniqueness and unknown groups or
+// users to ErrResourceNotFound
+func groupError(err error) error {
+	switch {
+	case errors.Is(err, ErrConflict):
+		return fmt.Errorf("%w: %w", scim.ErrUniqueness, err)
+	case errors.Is(err, ErrNotFound):
+		return fmt.Errorf("%w: %w", scim.ErrResourceNotFound, err)
+	default:
+		return err
+	}
+}
+
+// eventPageSize is how many events are requested from Keycloak at once
+const eventPageSize = 100
+
+var (
+	// adminEventResources are the resources whose admin events change local state
+	adminEventResources = []string{"USER", "REALM_ROLE_MAPPING", "CLIENT_ROLE_MAPPING", "GROUP_MEMBERSHIP", "USER_SESSION"}
+	// loginEventTypes are the login events that change local state
+	loginEventTypes = []string{"LOGOUT", "UPDATE_PROFILE", "UPDATE_EMAIL", "VERIFY_EMAIL", "DELETE_ACCOUNT"}
+)
+
+// EventSource turns the realm's admin and login events into directory events
+type EventSource struct {
+	client *Client
+}
+
+func NewEventSource(client *Client) *EventSource {
+	return &EventSource{client: client}
+}
+
+// ListEvents polls both kinds of events. Keycloak only filters them by day,
+// in its own time zone, so a day more is requested and the rest filtered here.
+func (s *EventSource) ListEvents(ctx context.Context, since time.Time) ([]*models.DirectoryEvent, error) {
+	dateFrom := since.Add(-24 * time.Hour)
+
+	var events []*models.DirectoryEvent
+	collect := func(list func(first int) ([]*KeycloakEvent, error)) error {
+		for first := 0; ; first += eventPageSize {
+			page, err := list(first)
+			if err != nil {
+				return err
+			}
+
+			// Pages are most recent first, so the first older event ends the listing
+			for _, e := range page {
+				if time.UnixMilli(e.Time).Before(since) {
+					return nil
+				}
+				if event := toDirectoryEvent(e); event != nil {
+					events = append(events, event)
+				}
+			}
+
+			if len(page) < eventPageSize {
+				return nil
+			}
+		}
+	}
+
+	err := collect(func(first int) ([]*KeycloakEvent, error) {
+		return s.client.ListAdminEvents(ctx, dateFrom, adminEventResources, first, eventPageSize)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = collect(func(first int) ([]*KeycloakEvent, error) {
+		return s.client.ListEvents(ctx, dateFrom, loginEventTypes, first, eventPageSize)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(events, func(a, b int) bool {
+		return events[a].OccurredAt.Before(events[b].OccurredAt)
+	})
+	return events, nil
+}
+
+// ParseEvents decodes a webhook delivery of one event representation, or an
+// array of them, as the Admin REST API lists them
+func (s *EventSource) ParseEvents(body []byte) ([]*models.DirectoryEvent, error) {
+	var raw []*KeycloakEvent
+	body = bytes.TrimSpace(body)
+	if len(body) > 0 && body[0] == '[' {
+		if err := json.Unmarshal(body, &raw); err != nil {
+			return nil, fmt.Errorf("failed to decode events: %w", err)
+		}
+	} else {
+		var e KeycloakEvent
+		if err := json.Unmarshal(body, &e); err != nil {
+			return nil, fmt.Errorf("failed to decode event: %w", err)
+		}
+		raw = append(raw, &e)
+	}
+
+	var events []*models.DirectoryEvent
+	for _, e := range raw {
+		if e == nil || e.Time == 0 {
+			return nil, fmt.Errorf("event without a time")
+		}
+		if event := toDirectoryEvent(e); event != nil {
+			events = append(events, event)
+		}
+	}
+	return events, nil
+}
+
+// toDirectoryEvent returns nil for failed events and events that don't
+// change local state
+func toDirectoryEvent(e *KeycloakEvent) *models.DirectoryEvent {
+	if e.Error != "" {
+		return nil
+	}
+
+	event := &models.DirectoryEvent{ID: eventID(e), OccurredAt: time.UnixMilli(e.Time).UTC()}
+
+	if e.OperationType == "" {
+		switch e.Type {
+		case "LOGOUT":
+			event.Type = models.DirectoryLogout
+			event.SessionID = e.SessionID
+		case "UPDATE_PROFILE", "UPDATE_EMAIL", "VERIFY_EMAIL", "DELETE_ACCOUNT":
+			event.Type = models.DirectoryUserChanged
+		default:
+			return nil
+		}
+		// A logout without a session would end every session of the user
+		if e.UserID == "" || event.Type == models.DirectoryLogout && e.SessionID == "" {
+			return nil
+		}
+		event.UserID = e.UserID
+		return event
+	}
+
+	path := strings.Split(e.ResourcePath, "/")
+	switch {
+	case e.ResourceType == "USER_SESSION" && e.OperationType == "DELETE" && len(path) == 2 && path[0] == "sessions":
+		event.Type = models.DirectoryLogout
+		event.SessionID = path[1]
+		return event
+	case len(path) < 2 || path[0] != "users" || path[1] == "":
+		return nil
+	case e.ResourceType == "USER" && len(path) == 2 && e.OperationType != "ACTION":
+		event.Type = models.DirectoryUserChanged
+	case e.ResourceType == "USER" && len(path) == 3 && path[2] == "logout":
+		event.Type = models.DirectoryLogout
+	case e.ResourceType == "REALM_ROLE_MAPPING", e.ResourceType == "CLIENT_ROLE_MAPPING", e.ResourceType == "GROUP_MEMBERSHIP":
+		event.Type = models.DirectoryRolesChanged
+	default:
+		return nil
+	}
+
+	event.UserID = path[1]
+	return event
+}
+
+// eventID identifies an event by its content, since older Keycloak releases
+// leave out event IDs and webhook providers may deliver them differently
+func eventID(e *KeycloakEvent) string {
+	sum := sha256.Sum256(fmt.Appendf(nil, "%d|%s|%s|%s|%s|%s|%s", e.Time, e.Type, e.UserID, e.SessionID, e.OperationType, e.ResourceType, e.ResourcePath))
+	return hex.EncodeToString(sum[:16])
+}
@@ -1,23 +1,123 @@
 package keycloak
 
-import "time"
+import (
+	"slices"
+	"time"
 
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// KeycloakUser is a realm user. Roles are the realm roles mapped directly to
+// the user; CreateUser assigns them, UpdateUser leaves them unchanged.
 type KeycloakUser struct {
-	ID        string    `json:"id"`
-	Username  string    `json:"username"`
-	Email     string    `json:"email"`
-	FirstName string    `json:"first_name"`
-	LastName  string    `json:"last_name"`
-	Roles     []string  `json:"realm_roles"`
+	ID            string   `json:"id"`
+	Username      string   `json:"username"`
+	Email         string   `json:"email"`
+	EmailVerified bool     `json:"email_verified"`
+	FirstName     string   `json:"first_name"`
+	LastName      string   `json:"last_name"`
+	Enabled       bool     `json:"enabled"`
+	Roles         []string `json:"realm_roles"`
+	// TenantID is the user attribute the realm maps into the tenant_id claim
+	TenantID  string    `json:"tenant_id,omitempty"`
 	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
 }
 
+// tenantAttribute is the user attribute holding the tenant ID
+const tenantAttribute = "tenant_id"
+
+// userRepresentation is the user as the Admin REST API encodes it
+type userRepresentation struct {
+	ID               string `json:"id,omitempty"`
+	Username         string `json:"username,omitempty"`
+	Email            string `json:"email"`
+	EmailVerified    bool   `json:"emailVerified"`
+	FirstName        string `json:"firstName"`
+	LastName         string `json:"lastName"`
+	Enabled          bool   `json:"enabled"`
+	CreatedTimestamp int64  `json:"createdTimestamp,omitempty"`
+	// Attributes are left out of updates, which then keep the stored ones
+	Attributes map[string][]string `json:"attributes,omitempty"`
+}
+
+// groupRepresentation is the group as the Admin REST API encodes it
+type groupRepresentation struct {
+	ID   string `json:"id,omitempty"`
+	Name string `json:"name"`
+}
+
+type roleRepresentation struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// KeycloakEvent is a login or admin event as the Admin REST API encodes it.
+// Admin events have an OperationType, login events a Type.
+type KeycloakEvent struct {
+	ID   string `json:"id,omitempty"`
+	Time int64  `json:"time"`
+	// Type, UserID and SessionID describe login events
+	Type      string `json:"type,omitempty"`
+	UserID    string `json:"userId,omitempty"`
+	SessionID string `json:"sessionId,omitempty"`
+	// OperationType is CREATE, UPDATE, DELETE or ACTION on the resource at
+	// ResourcePath, e.g. users/{id}/role-mappings/realm
+	OperationType string `json:"operationType,omitempty"`
+	ResourceType  string `json:"resourceType,omitempty"`
+	ResourcePath  string `json:"resourcePath,omitempty"`
+	Error         string `json:"error,omitempty"`
+}
+
+// KeycloakToken is a token endpoint response
 type KeycloakToken struct {
 	AccessToken      string `json:"access_token"`
 	ExpiresIn        int    `json:"expires_in"`
 	RefreshToken     string `json:"refresh_token"`
-	ExpiresInRefresh int    `json:"expires_in_refresh"`
+	ExpiresInRefresh int    `json:"refresh_expires_in"`
 	TokenType        string `json:"token_type"`
 	Scope            string `json:"scope"`
 }
+
+// Claims are the claims of a validated Keycloak access token
+type Claims struct {
+	jwt.RegisteredClaims
+	Type              string `json:"typ"`
+	AuthorizedParty   string `json:"azp"`
+	SessionID         string `json:"sid"`
+	Scope             string `json:"scope"`
+	PreferredUsername string `json:"preferred_username"`
+	Email             string `json:"email"`
+	EmailVerified     bool   `json:"email_verified"`
+	GivenName         string `json:"given_name"`
+	FamilyName        string `json:"family_name"`
+	// TenantID is added by a user attribute mapper on the realm's clients
+	TenantID       string            `json:"tenant_id"`
+	RealmAccess    Access            `json:"realm_access"`
+	ResourceAccess map[string]Access `json:"resource_access"`
+	// ClientID is only added to tokens of service accounts, by the mapper
+	// of Keycloak's service_account client scope
+	ClientID string `json:"client_id"`
+}
+
+// Access lists the roles granted in the realm or by one client
+type Access struct {
+	Roles []string `json:"roles"`
+}
+
+// RealmRoles returns the realm roles of the token's subject
+func (c *Claims) RealmRoles() []string {
+	return c.RealmAccess.Roles
+}
+
+// ClientRoles returns the roles the subject holds on the given client
+func (c *Claims) ClientRoles(clientID string) []string {
+	return c.ResourceAccess[clientID].Roles
+}
+
+func (c *Claims) HasRealmRole(role string) bool {
+	return slices.Contains(c.RealmAccess.Roles, role)
+}
+
+func (c *Claims) HasClientRole(clientID string, role string) bool {
+	return slices.Contains(c.ResourceAccess[clientID].Roles, role)
+}
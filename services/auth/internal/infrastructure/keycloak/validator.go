@@ -1 +1,299 @@
 package keycloak
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/intellifinder/v4/services/auth/internal/config"
+)
+
+// signingMethods are the algorithms Keycloak signs tokens with; anything
+// else, notably "none" and HMAC, is rejected before a key is looked up
+var signingMethods = []string{"RS256", "RS384", "RS512", "PS256", "PS384", "PS512", "ES256", "ES384", "ES512"}
+
+// Validator verifies Keycloak access tokens offline against the realm's JWKS.
+// Keys are cached for the configured TTL and fetched again early when a token
+// names an unknown key ID, at most once per minimum refresh interval so
+// tokens with made-up key IDs can't be used to flood Keycloak.
+type Validator struct {
+	jwksURL            string
+	issuer             string
+	audience           []string
+	clockSkew          time.Duration
+	cacheTTL           time.Duration
+	minRefreshInterval time.Duration
+	httpClient         *http.Client
+	now                func() time.Time
+
+	mu          sync.RWMutex
+	keys        map[string]jsonWebKey
+	fetchedAt   time.Time
+	lastAttempt time.Time
+	lastErr     error
+
+	refreshMu sync.Mutex
+}
+
+type jsonWebKey struct {
+	KeyID     string `json:"kid"`
+	KeyType   string `json:"kty"`
+	Algorithm string `json:"alg"`
+	Use       string `json:"use"`
+	N         string `json:"n"`
+	E         string `json:"e"`
+	Curve     string `json:"crv"`
+	X         string `json:"x"`
+	Y         string `json:"y"`
+
+	publicKey crypto.PublicKey
+}
+
+type jsonWebKeySet struct {
+	Keys []jsonWebKey `json:"keys"`
+}
+
+func NewValidator(cfg config.Keycloak, httpClient *http.Client) *Validator {
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+
+	return &Validator{
+		jwksURL:            cfg.JWKSURL(),
+		issuer:             cfg.IssuerURL(),
+		audience:           cfg.AcceptedAudience(),
+		clockSkew:          cfg.ClockSkew,
+		cacheTTL:           cfg.JWKSCacheTTL,
+		minRefreshInterval: cfg.JWKSMinRefreshInterval,
+		httpClient:         httpClient,
+		now:                time.Now,
+		keys:               make(map[string]jsonWebKey),
+	}
+}
+
+// Validate checks the token's signature, issuer, audience, type and validity
+// window and returns its claims
+func (v *Validator) Validate(ctx context.Context, token string) (*Claims, error) {
+	parser := jwt.NewParser(
+		jwt.WithValidMethods(signingMethods),
+		jwt.WithIssuer(v.issuer),
+		jwt.WithLeeway(v.clockSkew),
+		jwt.WithExpirationRequired(),
+		jwt.WithTimeFunc(v.now),
+	)
+
+	claims := &Claims{}
+	_, err := parser.ParseWithClaims(token, claims, func(t *jwt.Token) (interface{}, error) {
+		kid, _ := t.Header["kid"].(string)
+		if kid == "" {
+			return nil, fmt.Errorf("token has no key ID")
+		}
+
+		key, err := v.key(ctx, kid)
+		if err != nil {
+			return nil, err
+		}
+
+		if key.Algorithm != "" && key.Algorithm != t.Method.Alg() {
+			return nil, fmt.Errorf("key %s is for %s, token is signed with %s", kid, key.Algorithm, t.Method.Alg())
+		}
+
+		return key.publicKey, nil
+	})
+	switch {
+	case errors.Is(err, ErrJWKSUnavailable):
+		return nil, err
+	case errors.Is(err, jwt.ErrTokenExpired):
+		return nil, ErrTokenExpired
+	case err != nil:
+		return nil, fmt.Errorf("%w: %w", ErrInvalidToken, err)
+	}
+
+	// Keycloak also signs ID and refresh tokens with the realm keys
+	if claims.Type != "Bearer" {
+		return nil, fmt.Errorf("%w: token type is %q, expected an access token", ErrInvalidToken, claims.Type)
+	}
+
+	if !slices.ContainsFunc(claims.Audience, func(aud string) bool {
+		return slices.Contains(v.audience, aud)
+	}) {
+		return nil, fmt.Errorf("%w: token audience %v is not accepted", ErrInvalidToken, claims.Audience)
+	}
+
+	return claims, nil
+}
+
+// key returns the cached key with the given ID, fetching the key set when
+// the ID is unknown or the cache has expired
+func (v *Validator) key(ctx context.Context, kid string) (jsonWebKey, error) {
+	v.mu.RLock()
+	key, ok := v.keys[kid]
+	expired := v.now().Sub(v.fetchedAt) >= v.cacheTTL
+	v.mu.RUnlock()
+
+	if ok && !expired {
+		return key, nil
+	}
+
+	if err := v.refresh(ctx); err != nil {
+		// Keep validating with the keys we have while Keycloak is unreachable
+		if ok {
+			return key, nil
+		}
+		return jsonWebKey{}, err
+	}
+
+	v.mu.RLock()
+	key, ok = v.keys[kid]
+	v.mu.RUnlock()
+
+	if !ok {
+		return jsonWebKey{}, fmt.Errorf("unknown key ID %s", kid)
+	}
+
+	return key, nil
+}
+
+// refresh fetches the key set unless that was already attempted within the
+// minimum refresh interval, in which case the outcome of that attempt is returned
+func (v *Validator) refresh(ctx context.Context) error {
+	v.refreshMu.Lock()
+	defer v.refreshMu.Unlock()
+
+	v.mu.RLock()
+	lastAttempt, lastErr := v.lastAttempt, v.lastErr
+	v.mu.RUnlock()
+
+	now := v.now()
+	if !lastAttempt.IsZero() && now.Sub(lastAttempt) < v.minRefreshInterval {
+		return lastErr
+	}
+
+	// Cached keys stay usable while the fetch runs
+	keys, err := v.fetch(ctx)
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.lastAttempt = now
+	if err != nil {
+		v.lastErr = fmt.Errorf("%w: %w", ErrJWKSUnavailable, err)
+		return v.lastErr
+	}
+
+	v.keys = keys
+	v.fetchedAt = now
+	v.lastErr = nil
+	return nil
+}
+
+func (v *Validator) fetch(ctx context.Context) (map[string]jsonWebKey, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, v.jwksURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create JWKS request: %w", err)
+	}
+
+	resp, err := v.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch JWKS: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch JWKS: unexpected status %s", resp.Status)
+	}
+
+	var set jsonWebKeySet
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, fmt.Errorf("failed to decode JWKS: %w", err)
+	}
+
+	keys := make(map[string]jsonWebKey, len(set.Keys))
+	for _, key := range set.Keys {
+		// Encryption keys and keys of unsupported types are skipped rather than failing the whole set
+		if key.KeyID == "" || (key.Use != "" && key.Use != "sig") {
+			continue
+		}
+
+		publicKey, err := key.parse()
+		if err != nil {
+			continue
+		}
+
+		key.publicKey = publicKey
+		keys[key.KeyID] = key
+	}
+
+	return keys, nil
+}
+
+func (k jsonWebKey) parse() (crypto.PublicKey, error) {
+	switch k.KeyType {
+	case "RSA":
+		n, err := decodeBigInt(k.N)
+		if err != nil {
+			return nil, err
+		}
+
+		e, err := decodeBigInt(k.E)
+		if err != nil {
+			return nil, err
+		}
+
+		if !e.IsInt64() || e.Int64() > 1<<31-1 {
+			return nil, fmt.Errorf("RSA exponent out of range")
+		}
+
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Curve {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Curve)
+		}
+
+		x, err := decodeBigInt(k.X)
+		if err != nil {
+			return nil, err
+		}
+
+		y, err := decodeBigInt(k.Y)
+		if err != nil {
+			return nil, err
+		}
+
+		if !curve.IsOnCurve(x, y) {
+			return nil, fmt.Errorf("point is not on curve %s", k.Curve)
+		}
+
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, nil
+	default:
+		return nil, fmt.Errorf("unsupported key type %q", k.KeyType)
+	}
+}
+
+func decodeBigInt(value string) (*big.Int, error) {
+	b, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode key parameter: %w", err)
+	}
+	return new(big.Int).SetBytes(b), nil
+}
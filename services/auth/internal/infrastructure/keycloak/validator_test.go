@@ -0,0 +1,444 @@
+package keycloak
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/intellifinder/v4/services/auth/internal/config"
+	"github.com/intellifinder/v4/services/auth/internal/domain/authentication"
+	"github.com/intellifinder/v4/services/auth/pkg/models"
+)
+
+const testRealm = "intellifinder"
+
+// jwksServer stands in for Keycloak's certs endpoint
+type jwksServer struct {
+	*httptest.Server
+	fetches atomic.Int32
+
+	mu     sync.Mutex
+	keys   []map[string]string
+	status int
+}
+
+func newJWKSServer(t *testing.T) *jwksServer {
+	t.Helper()
+	s := &jwksServer{status: http.StatusOK}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/realms/"+testRealm+"/protocol/openid-connect/certs" {
+			http.NotFound(w, r)
+			return
+		}
+		s.fetches.Add(1)
+
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if s.status != http.StatusOK {
+			w.WriteHeader(s.status)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]any{"keys": s.keys})
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *jwksServer) setKeys(keys ...map[string]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys = keys
+}
+
+func (s *jwksServer) setStatus(status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.status = status
+}
+
+func (s *jwksServer) config() config.Keycloak {
+	return config.Keycloak{
+		URL:                    s.URL,
+		Realm:                  testRealm,
+		Audience:               []string{"intellifinder-api"},
+		ClockSkew:              30 * time.Second,
+		JWKSCacheTTL:           time.Hour,
+		JWKSMinRefreshInterval: 10 * time.Second,
+	}
+}
+
+func encodeInt(b []byte) string {
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func rsaJWK(kid string, key *rsa.PrivateKey) map[string]string {
+	return map[string]string{
+		"kid": kid,
+		"kty": "RSA",
+		"alg": "RS256",
+		"use": "sig",
+		"n":   encodeInt(key.N.Bytes()),
+		"e":   encodeInt(big.NewInt(int64(key.E)).Bytes()),
+	}
+}
+
+func ecJWK(kid string, key *ecdsa.PrivateKey) map[string]string {
+	return map[string]string{
+		"kid": kid,
+		"kty": "EC",
+		"alg": "ES256",
+		"use": "sig",
+		"crv": "P-256",
+		"x":   encodeInt(key.X.FillBytes(make([]byte, 32))),
+		"y":   encodeInt(key.Y.FillBytes(make([]byte, 32))),
+	}
+}
+
+func newRSAKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("failed to generate RSA key: %v", err)
+	}
+	return key
+}
+
+// accessToken returns the claims of a valid access token issued by the server at now
+func accessToken(s *jwksServer, now time.Time) jwt.MapClaims {
+	return jwt.MapClaims{
+		"iss":                s.URL + "/realms/" + testRealm,
+		"sub":                "user-1",
+		"aud":                []string{"account", "intellifinder-api"},
+		"exp":                now.Add(5 * time.Minute).Unix(),
+		"iat":                now.Unix(),
+		"jti":                "token-1",
+		"typ":                "Bearer",
+		"azp":                "intellifinder-web",
+		"sid":                "session-1",
+		"preferred_username": "alice",
+		"email":              "alice@example.com",
+		"email_verified":     true,
+		"tenant_id":          "tenant-1",
+		"realm_access":       map[string]any{"roles": []string{"admin", "offline_access"}},
+		"resource_access": map[string]any{
+			"intellifinder-api": map[string]any{"roles": []string{"tasks-editor"}},
+		},
+	}
+}
+
+func sign(t *testing.T, method jwt.SigningMethod, kid string, key crypto.Signer, claims jwt.MapClaims) string {
+	t.Helper()
+	token := jwt.NewWithClaims(method, claims)
+	if kid != "" {
+		token.Header["kid"] = kid
+	}
+	signed, err := token.SignedString(key)
+	if err != nil {
+		t.Fatalf("failed to sign token: %v", err)
+	}
+	return signed
+}
+
+func TestValidateAccessToken(t *testing.T) {
+	server := newJWKSServer(t)
+	key := newRSAKey(t)
+	server.setKeys(rsaJWK("rsa-1", key))
+
+	validator := NewValidator(server.config(), server.Client())
+	token := sign(t, jwt.SigningMethodRS256, "rsa-1", key, accessToken(server, time.Now()))
+
+	claims, err := validator.Validate(context.Background(), token)
+	if err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+
+	if claims.Subject != "user-1" || claims.PreferredUsername != "alice" || claims.SessionID != "session-1" || !claims.EmailVerified {
+		t.Errorf("claims = %+v, want the token's identity", claims)
+	}
+	if !slices.Equal(claims.RealmRoles(), []string{"admin", "offline_access"}) || !claims.HasRealmRole("admin") {
+		t.Errorf("RealmRoles() = %v, want [admin offline_access]", claims.RealmRoles())
+	}
+	if !claims.HasClientRole("intellifinder-api", "tasks-editor") || claims.ClientRoles("other") != nil {
+		t.Errorf("ResourceAccess = %v, want tasks-editor on intellifinder-api only", claims.ResourceAccess)
+	}
+
+	if _, err := validator.Validate(context.Background(), token); err != nil {
+		t.Fatalf("Validate() second call error = %v", err)
+	}
+	if got := server.fetches.Load(); got != 1 {
+		t.Errorf("JWKS fetched %d times, want 1 with caching", got)
+	}
+}
+
+func TestValidateECDSAToken(t *testing.T) {
+	server := newJWKSServer(t)
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate EC key: %v", err)
+	}
+	server.setKeys(ecJWK("ec-1", key))
+
+	validator := NewValidator(server.config(), server.Client())
+	token := sign(t, jwt.SigningMethodES256, "ec-1", key, accessToken(server, time.Now()))
+
+	if _, err := validator.Validate(context.Background(), token); err != nil {
+		t.Errorf("Validate() error = %v", err)
+	}
+}
+
+func TestValidateRejectsInvalidTokens(t *testing.T) {
+	server := newJWKSServer(t)
+	key := newRSAKey(t)
+	otherKey := newRSAKey(t)
+	server.setKeys(rsaJWK("rsa-1", key))
+
+	now := time.Now()
+	tests := []struct {
+		name    string
+		token   func() string
+		wantErr error
+	}{
+		{"expired beyond skew", func() string {
+			claims := accessToken(server, now)
+			claims["exp"] = now.Add(-time.Minute).Unix()
+			return sign(t, jwt.SigningMethodRS256, "rsa-1", key, claims)
+		}, ErrTokenExpired},
+		{"not valid yet beyond skew", func() string {
+			claims := accessToken(server, now)
+			claims["nbf"] = now.Add(time.Minute).Unix()
+			return sign(t, jwt.SigningMethodRS256, "rsa-1", key, claims)
+		}, ErrInvalidToken},
+		{"missing expiry", func() string {
+			claims := accessToken(server, now)
+			delete(claims, "exp")
+			return sign(t, jwt.SigningMethodRS256, "rsa-1", key, claims)
+		}, ErrInvalidToken},
+		{"wrong issuer", func() string {
+			claims := accessToken(server, now)
+			claims["iss"] = server.URL + "/realms/other"
+			return sign(t, jwt.SigningMethodRS256, "rsa-1", key, claims)
+		}, ErrInvalidToken},
+		{"wrong audience", func() string {
+			claims := accessToken(server, now)
+			claims["aud"] = "account"
+			return sign(t, jwt.SigningMethodRS256, "rsa-1", key, claims)
+		}, ErrInvalidToken},
+		{"ID token", func() string {
+			claims := accessToken(server, now)
+			claims["typ"] = "ID"
+			return sign(t, jwt.SigningMethodRS256, "rsa-1", key, claims)
+		}, ErrInvalidToken},
+		{"signed by another key", func() string {
+			return sign(t, jwt.SigningMethodRS256, "rsa-1", otherKey, accessToken(server, now))
+		}, ErrInvalidToken},
+		{"algorithm not matching the key", func() string {
+			return sign(t, jwt.SigningMethodRS512, "rsa-1", key, accessToken(server, now))
+		}, ErrInvalidToken},
+		{"no key ID", func() string {
+			return sign(t, jwt.SigningMethodRS256, "", key, accessToken(server, now))
+		}, ErrInvalidToken},
+		{"HMAC", func() string {
+			token := jwt.NewWithClaims(jwt.SigningMethodHS256, accessToken(server, now))
+			token.Header["kid"] = "rsa-1"
+			signed, _ := token.SignedString([]byte("secret"))
+			return signed
+		}, ErrInvalidToken},
+		{"none algorithm", func() string {
+			token := jwt.NewWithClaims(jwt.SigningMethodNone, accessToken(server, now))
+			token.Header["kid"] = "rsa-1"
+			signed, _ := token.SignedString(jwt.UnsafeAllowNoneSignatureType)
+			return signed
+		}, ErrInvalidToken},
+		{"malformed", func() string {
+			return "not.a.token"
+		}, ErrInvalidToken},
+	}
+
+	validator := NewValidator(server.config(), server.Client())
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := validator.Validate(context.Background(), tt.token())
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Validate() error = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateDefaultsAudienceToClientID(t *testing.T) {
+	server := newJWKSServer(t)
+	key := newRSAKey(t)
+	server.setKeys(rsaJWK("rsa-1", key))
+
+	cfg := server.config()
+	cfg.Audience = nil
+	cfg.ClientID = "intellifinder-auth"
+	validator := NewValidator(cfg, server.Client())
+
+	claims := accessToken(server, time.Now())
+	if _, err := validator.Validate(context.Background(), sign(t, jwt.SigningMethodRS256, "rsa-1", key, claims)); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Validate() for another audience error = %v, want ErrInvalidToken", err)
+	}
+
+	claims["aud"] = []string{"account", "intellifinder-auth"}
+	if _, err := validator.Validate(context.Background(), sign(t, jwt.SigningMethodRS256, "rsa-1", key, claims)); err != nil {
+		t.Errorf("Validate() for the client ID error = %v", err)
+	}
+}
+
+func TestValidateToleratesClockSkew(t *testing.T) {
+	server := newJWKSServer(t)
+	key := newRSAKey(t)
+	server.setKeys(rsaJWK("rsa-1", key))
+	validator := NewValidator(server.config(), server.Client())
+
+	now := time.Now()
+	claims := accessToken(server, now)
+	claims["exp"] = now.Add(-10 * time.Second).Unix()
+	claims["nbf"] = now.Add(10 * time.Second).Unix()
+
+	if _, err := validator.Validate(context.Background(), sign(t, jwt.SigningMethodRS256, "rsa-1", key, claims)); err != nil {
+		t.Errorf("Validate() within skew error = %v", err)
+	}
+}
+
+func TestValidateRefreshesOnUnknownKeyID(t *testing.T) {
+	server := newJWKSServer(t)
+	oldKey := newRSAKey(t)
+	newKey := newRSAKey(t)
+	server.setKeys(rsaJWK("old", oldKey))
+
+	validator := NewValidator(server.config(), server.Client())
+	now := time.Now()
+	validator.now = func() time.Time { return now }
+
+	ctx := context.Background()
+	if _, err := validator.Validate(ctx, sign(t, jwt.SigningMethodRS256, "old", oldKey, accessToken(server, now))); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+
+	// Keycloak rotates its key within the minimum refresh interval
+	server.setKeys(rsaJWK("old", oldKey), rsaJWK("new", newKey))
+	rotated := sign(t, jwt.SigningMethodRS256, "new", newKey, accessToken(server, now))
+
+	if _, err := validator.Validate(ctx, rotated); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Validate() within refresh interval error = %v, want ErrInvalidToken", err)
+	}
+	for range 5 {
+		validator.Validate(ctx, sign(t, jwt.SigningMethodRS256, "made-up", newKey, accessToken(server, now)))
+	}
+	if got := server.fetches.Load(); got != 1 {
+		t.Fatalf("JWKS fetched %d times, want unknown key IDs rate limited to 1 fetch", got)
+	}
+
+	now = now.Add(11 * time.Second)
+	if _, err := validator.Validate(ctx, rotated); err != nil {
+		t.Errorf("Validate() after refresh interval error = %v", err)
+	}
+	if got := server.fetches.Load(); got != 2 {
+		t.Errorf("JWKS fetched %d times, want 2", got)
+	}
+}
+
+func TestValidateKeepsCachedKeysWhenKeycloakIsDown(t *testing.T) {
+	server := newJWKSServer(t)
+	key := newRSAKey(t)
+	server.setKeys(rsaJWK("rsa-1", key))
+
+	validator := NewValidator(server.config(), server.Client())
+	now := time.Now()
+	validator.now = func() time.Time { return now }
+
+	ctx := context.Background()
+	token := sign(t, jwt.SigningMethodRS256, "rsa-1", key, accessToken(server, now))
+	if _, err := validator.Validate(ctx, token); err != nil {
+		t.Fatalf("Validate() error = %v", err)
+	}
+
+	server.setStatus(http.StatusServiceUnavailable)
+	now = now.Add(2 * time.Hour)
+	token = sign(t, jwt.SigningMethodRS256, "rsa-1", key, accessToken(server, now))
+
+	if _, err := validator.Validate(ctx, token); err != nil {
+		t.Errorf("Validate() with expired cache and Keycloak down error = %v, want cached key used", err)
+	}
+	if got := server.fetches.Load(); got != 2 {
+		t.Errorf("JWKS fetched %d times, want a refresh attempt after the cache TTL", got)
+	}
+
+	unknown := sign(t, jwt.SigningMethodRS256, "rsa-2", key, accessToken(server, now))
+	if _, err := validator.Validate(ctx, unknown); !errors.Is(err, ErrJWKSUnavailable) {
+		t.Errorf("Validate() unknown key with Keycloak down error = %v, want ErrJWKSUnavailable", err)
+	}
+}
+
+func TestVerifyAccessToken(t *testing.T) {
+	server := newJWKSServer(t)
+	key := newRSAKey(t)
+	server.setKeys(rsaJWK("rsa-1", key))
+	validator := NewValidator(server.config(), server.Client())
+
+	ctx := context.Background()
+	now := time.Now()
+	identity, err := validator.VerifyAccessToken(ctx, sign(t, jwt.SigningMethodRS256, "rsa-1", key, accessToken(server, now)))
+	if err != nil {
+		t.Fatalf("VerifyAccessToken() error = %v", err)
+	}
+
+	want := &models.Identity{
+		Subject:   "user-1",
+		Username:  "alice",
+		Email:     "alice@example.com",
+		Roles:     []string{"admin", "offline_access"},
+		TenantID:  "tenant-1",
+		SessionID: "session-1",
+		TokenID:   "token-1",
+		IssuedAt:  time.Unix(now.Unix(), 0),
+		ClientID:  "intellifinder-web",
+		Method:    models.AuthMethodJWT,
+		ExpiresAt: time.Unix(now.Add(5*time.Minute).Unix(), 0),
+	}
+	if !reflect.DeepEqual(identity, want) {
+		t.Errorf("VerifyAccessToken() = %+v, want %+v", identity, want)
+	}
+
+	service := accessToken(server, now)
+	service["azp"], service["client_id"], service["preferred_username"] = "tasks-service", "tasks-service", "service-account-tasks-service"
+	identity, err = validator.VerifyAccessToken(ctx, sign(t, jwt.SigningMethodRS256, "rsa-1", key, service))
+	if err != nil || !identity.ServiceAccount || identity.ClientID != "tasks-service" {
+		t.Errorf("VerifyAccessToken() service token = %+v, %v, want a service account of tasks-service", identity, err)
+	}
+
+	// A user named like a service account has no client_id claim
+	delete(service, "client_id")
+	identity, err = validator.VerifyAccessToken(ctx, sign(t, jwt.SigningMethodRS256, "rsa-1", key, service))
+	if err != nil || identity.ServiceAccount {
+		t.Errorf("VerifyAccessToken() user token = %+v, %v, want no service account", identity, err)
+	}
+
+	expired := accessToken(server, now)
+	expired["exp"] = now.Add(-time.Hour).Unix()
+	if _, err := validator.VerifyAccessToken(ctx, sign(t, jwt.SigningMethodRS256, "rsa-1", key, expired)); !errors.Is(err, authentication.ErrCredentialsExpired) {
+		t.Errorf("VerifyAccessToken() expired error = %v, want ErrCredentialsExpired", err)
+	}
+
+	if _, err := validator.VerifyAccessToken(ctx, "not.a.token"); !errors.Is(err, authentication.ErrInvalidCredentials) {
+		t.Errorf("VerifyAccessToken() malformed error = %v, want ErrInvalidCredentials", err)
+	}
+}
@@ -0,0 +1,144 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/intellifinder/v4/services/auth/internal/domain/apikey"
+	"github.com/intellifinder/v4/services/auth/pkg/models"
+)
+
+var _ apikey.Repository = (*Repository)(nil)
+
+func (r *Repository) CreateAPIKey(_ context.Context, key *models.APIKey) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, existing := range r.apiKeys {
+		if existing.Prefix == key.Prefix {
+			return fmt.Errorf("failed to insert API key: prefix %s already exists", key.Prefix)
+		}
+	}
+
+	key.ID = uuid.New()
+	r.apiKeys[key.ID] = cloneAPIKey(*key)
+	return nil
+}
+
+func (r *Repository) GetAPIKey(_ context.Context, id uuid.UUID) (*models.APIKey, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	key, ok := r.apiKeys[id]
+	if !ok {
+		return nil, apikey.ErrAPIKeyNotFound
+	}
+
+	copied := cloneAPIKey(key)
+	return &copied, nil
+}
+
+func (r *Repository) GetAPIKeyByPrefix(_ context.Context, prefix string) (*models.APIKey, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, key := range r.apiKeys {
+		if key.Prefix == prefix {
+			copied := cloneAPIKey(key)
+			return &copied, nil
+		}
+	}
+
+	return nil, apikey.ErrAPIKeyNotFound
+}
+
+func (r *Repository) ListAPIKeys(_ context.Context, userID string) ([]*models.APIKey, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	keys := []*models.APIKey{}
+	for _, key := range r.apiKeys {
+		if key.UserID == userID {
+			copied := cloneAPIKey(key)
+			keys = append(keys, &copied)
+		}
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].CreatedAt.After(keys[j].CreatedAt)
+	})
+
+	return keys, nil
+}
+
+func (r *Repository) UpdateAPIKeySecret(_ context.Context, id uuid.UUID, salt []byte, hash []byte, updatedAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key, ok := r.apiKeys[id]
+	if !ok || key.RevokedAt != nil {
+		return apikey.ErrAPIKeyRevoked
+	}
+
+	key.Salt, key.Hash, key.UpdatedAt = slices.Clone(salt), slices.Clone(hash), updatedAt
+	r.apiKeys[id] = key
+	return nil
+}
+
+func (r *Repository) RevokeAPIKey(_ context.Context, id uuid.UUID, revokedAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key, ok := r.apiKeys[id]
+	if !ok {
+		return apikey.ErrAPIKeyNotFound
+	}
+
+	if key.RevokedAt == nil {
+		key.RevokedAt = &revokedAt
+	}
+	key.UpdatedAt = revokedAt
+	r.apiKeys[id] = key
+	return nil
+}
+
+func (r *Repository) RevokeUserAPIKeys(_ context.Context, userID string, revokedAt time.Time) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	revoked := 0
+	for id, key := range r.apiKeys {
+		if key.UserID != userID || key.RevokedAt != nil {
+			continue
+		}
+		key.RevokedAt = &revokedAt
+		key.UpdatedAt = revokedAt
+		r.apiKeys[id] = key
+		revoked++
+	}
+	return revoked, nil
+}
+
+func (r *Repository) TouchAPIKey(_ context.Context, id uuid.UUID, usedAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if key, ok := r.apiKeys[id]; ok {
+		key.LastUsedAt = &usedAt
+		r.apiKeys[id] = key
+	}
+	return nil
+}
+
+// cloneAPIKey copies the slices so callers can't change the stored key
+func cloneAPIKey(key models.APIKey) models.APIKey {
+	key.Salt = slices.Clone(key.Salt)
+	key.Hash = slices.Clone(key.Hash)
+	key.Scopes = slices.Clone(key.Scopes)
+	key.Roles = slices.Clone(key.Roles)
+	return key
+}
@@ -0,0 +1,68 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/intellifinder/v4/services/auth/internal/domain/ingestion"
+	"github.com/intellifinder/v4/services/auth/pkg/models"
+)
+
+var (
+	_ ingestion.Repository = (*Repository)(nil)
+	_ ingestion.Publisher  = (*Repository)(nil)
+)
+
+func (r *Repository) ClaimEvent(_ context.Context, id string, ttl time.Duration) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	if expiresAt, ok := r.claimedEvents[id]; ok && expiresAt.After(now) {
+		return false, nil
+	}
+	r.claimedEvents[id] = now.Add(ttl)
+	return true, nil
+}
+
+func (r *Repository) ReleaseEvent(_ context.Context, id string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.claimedEvents, id)
+	return nil
+}
+
+func (r *Repository) GetEventCursor(_ context.Context) (time.Time, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.eventCursor, nil
+}
+
+func (r *Repository) SetEventCursor(_ context.Context, cursor time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if cursor.After(r.eventCursor) {
+		r.eventCursor = cursor
+	}
+	return nil
+}
+
+// PublishEvent keeps the event for PublishedEvents
+func (r *Repository) PublishEvent(_ context.Context, event *models.DomainEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.publishedEvents = append(r.publishedEvents, *event)
+	return nil
+}
+
+// PublishedEvents returns the events published so far, oldest first
+func (r *Repository) PublishedEvents() []models.DomainEvent {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return append([]models.DomainEvent(nil), r.publishedEvents...)
+}
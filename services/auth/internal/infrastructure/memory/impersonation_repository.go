@@ -0,0 +1,105 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/intellifinder/v4/services/auth/internal/domain/impersonation"
+	"github.com/intellifinder/v4/services/auth/pkg/dto"
+	"github.com/intellifinder/v4/services/auth/pkg/models"
+)
+
+var _ impersonation.Repository = (*Repository)(nil)
+
+func (r *Repository) CreateImpersonation(_ context.Context, i *models.Impersonation) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.impersonations[i.ID] = cloneImpersonation(*i)
+	return nil
+}
+
+func (r *Repository) GetImpersonation(_ context.Context, id uuid.UUID) (*models.Impersonation, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	i, ok := r.impersonations[id]
+	if !ok {
+		return nil, impersonation.ErrImpersonationNotFound
+	}
+
+	copied := cloneImpersonation(i)
+	return &copied, nil
+}
+
+func (r *Repository) EndImpersonation(_ context.Context, id uuid.UUID, endedAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	i, ok := r.impersonations[id]
+	if !ok {
+		return impersonation.ErrImpersonationNotFound
+	}
+
+	if i.EndedAt == nil {
+		i.EndedAt = &endedAt
+		r.impersonations[id] = i
+	}
+	return nil
+}
+
+func (r *Repository) ListImpersonations(_ context.Context, filter dto.ImpersonationFilter) ([]*models.Impersonation, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	list := []*models.Impersonation{}
+	for _, i := range r.impersonations {
+		if (filter.ActorID == "" || i.ActorID == filter.ActorID) && (filter.TargetID == "" || i.TargetID == filter.TargetID) {
+			copied := cloneImpersonation(i)
+			list = append(list, &copied)
+		}
+	}
+
+	sort.Slice(list, func(a, b int) bool {
+		return list[a].StartedAt.After(list[b].StartedAt)
+	})
+
+	if filter.Limit > 0 && len(list) > filter.Limit {
+		list = list[:filter.Limit]
+	}
+	return list, nil
+}
+
+func (r *Repository) AddImpersonationEvent(_ context.Context, event *models.ImpersonationEvent) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	event.ID = uuid.New()
+	r.impersonationEvents = append(r.impersonationEvents, *event)
+	return nil
+}
+
+func (r *Repository) ListImpersonationEvents(_ context.Context, impersonationID uuid.UUID) ([]*models.ImpersonationEvent, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	// Events are appended in the order they happened
+	events := []*models.ImpersonationEvent{}
+	for _, event := range r.impersonationEvents {
+		if event.ImpersonationID == impersonationID {
+			copied := event
+			events = append(events, &copied)
+		}
+	}
+	return events, nil
+}
+
+func cloneImpersonation(i models.Impersonation) models.Impersonation {
+	if i.EndedAt != nil {
+		endedAt := *i.EndedAt
+		i.EndedAt = &endedAt
+	}
+	return i
+}
@@ -0,0 +1,85 @@
+package memory
+
+import (
+	"context"
+	"slices"
+
+	"github.com/google/uuid"
+	"github.com/intellifinder/v4/services/auth/internal/domain/profile"
+	"github.com/intellifinder/v4/services/auth/pkg/models"
+)
+
+var _ profile.Repository = (*Repository)(nil)
+
+type preferencesKey struct {
+	userID   uuid.UUID
+	clientID string
+}
+
+func (r *Repository) GetProfile(_ context.Context, userID uuid.UUID) (*models.Profile, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	p, ok := r.profiles[userID]
+	if !ok {
+		return nil, profile.ErrProfileNotFound
+	}
+
+	copied := cloneProfile(p)
+	return &copied, nil
+}
+
+func (r *Repository) UpsertProfile(_ context.Context, p *models.Profile) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.profiles[p.UserID] = cloneProfile(*p)
+	return nil
+}
+
+func (r *Repository) GetPreferences(_ context.Context, userID uuid.UUID, clientID string) (*models.Preferences, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	p, ok := r.preferences[preferencesKey{userID, clientID}]
+	if !ok {
+		return nil, profile.ErrPreferencesNotFound
+	}
+
+	p.Data = slices.Clone(p.Data)
+	return &p, nil
+}
+
+func (r *Repository) UpsertPreferences(_ context.Context, p *models.Preferences) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	copied := *p
+	copied.Data = slices.Clone(p.Data)
+	r.preferences[preferencesKey{p.UserID, p.ClientID}] = copied
+	return nil
+}
+
+func (r *Repository) DeletePreferences(_ context.Context, userID uuid.UUID, clientID string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := preferencesKey{userID, clientID}
+	if _, ok := r.preferences[key]; !ok {
+		return profile.ErrPreferencesNotFound
+	}
+	delete(r.preferences, key)
+	return nil
+}
+
+func cloneProfile(p models.Profile) models.Profile {
+	if p.DefaultLocation != nil {
+		location := *p.DefaultLocation
+		p.DefaultLocation = &location
+	}
+	if p.UpdatedAt != nil {
+		updatedAt := *p.UpdatedAt
+		p.UpdatedAt = &updatedAt
+	}
+	return p
+}
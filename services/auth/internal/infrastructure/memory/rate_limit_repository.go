@@ -0,0 +1,86 @@
+package memory
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/intellifinder/v4/services/auth/internal/domain/ratelimit"
+)
+
+var _ ratelimit.Repository = (*Repository)(nil)
+
+// rateWindow is a counter that expires, used for attempts, strikes and locks
+type rateWindow struct {
+	count     int
+	expiresAt time.Time
+}
+
+func (r *Repository) Hit(_ context.Context, key string, window time.Duration) (int, int, time.Duration, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	start := now.Truncate(window)
+	current := r.liveWindow(windowKey(key, start), now)
+	current.count++
+	current.expiresAt = start.Add(2 * window)
+	r.rateLimits[windowKey(key, start)] = current
+
+	previous := r.liveWindow(windowKey(key, start.Add(-window)), now)
+	return previous.count, current.count, now.Sub(start), nil
+}
+
+func (r *Repository) ResetHits(_ context.Context, key string, window time.Duration) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	start := time.Now().Truncate(window)
+	delete(r.rateLimits, windowKey(key, start))
+	delete(r.rateLimits, windowKey(key, start.Add(-window)))
+	return nil
+}
+
+func (r *Repository) AddStrike(_ context.Context, key string, ttl time.Duration) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	now := time.Now()
+	strikes := r.liveWindow(key, now)
+	strikes.count++
+	strikes.expiresAt = now.Add(ttl)
+	r.rateLimits[key] = strikes
+	return strikes.count, nil
+}
+
+func (r *Repository) Lock(_ context.Context, key string, duration time.Duration) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.rateLimits[key] = rateWindow{count: 1, expiresAt: time.Now().Add(duration)}
+	return nil
+}
+
+func (r *Repository) LockedFor(_ context.Context, key string) (time.Duration, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	now := time.Now()
+	if lock := r.liveWindow(key, now); lock.count > 0 {
+		return lock.expiresAt.Sub(now), nil
+	}
+	return 0, nil
+}
+
+// liveWindow returns the counter of the key, or an empty one if it expired
+func (r *Repository) liveWindow(key string, now time.Time) rateWindow {
+	w, ok := r.rateLimits[key]
+	if !ok || !w.expiresAt.After(now) {
+		return rateWindow{}
+	}
+	return w
+}
+
+func windowKey(key string, start time.Time) string {
+	return key + ":" + strconv.FormatInt(start.UnixMilli(), 10)
+}
@@ -0,0 +1,63 @@
+// Package memory provides in-memory implementations of the auth service's
+// repositories for tests and local development. They mirror the Postgres and
+// Redis implementations' semantics, including unique key prefixes and OIDC
+// IDs, ordering, soft deletion and the expiry of sessions, revocations and rate limits.
+package memory
+
+import (
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/intellifinder/v4/services/auth/pkg/models"
+)
+
+type Repository struct {
+	mu       sync.RWMutex
+	apiKeys  map[uuid.UUID]models.APIKey
+	users    map[uuid.UUID]models.User
+	sessions map[string]models.Session
+	// revokedTokens and revokedSessions map IDs to when their revocation expires
+	revokedTokens   map[string]time.Time
+	revokedSessions map[string]time.Time
+	revokedUsers    map[string]userRevocation
+	rateLimits      map[string]rateWindow
+
+	impersonations      map[uuid.UUID]models.Impersonation
+	impersonationEvents []models.ImpersonationEvent
+
+	scimUsers  map[uuid.UUID]models.SCIMUser
+	scimGroups map[uuid.UUID]models.SCIMGroup
+
+	profiles    map[uuid.UUID]models.Profile
+	preferences map[preferencesKey]models.Preferences
+
+	// claimedEvents maps ingested event IDs to when their claim expires
+	claimedEvents   map[string]time.Time
+	eventCursor     time.Time
+	publishedEvents []models.DomainEvent
+}
+
+// NewRepository creates an empty in-memory repository
+func NewRepository() *Repository {
+	return &Repository{
+		apiKeys:  make(map[uuid.UUID]models.APIKey),
+		users:    make(map[uuid.UUID]models.User),
+		sessions: make(map[string]models.Session),
+
+		revokedTokens:   make(map[string]time.Time),
+		revokedSessions: make(map[string]time.Time),
+		revokedUsers:    make(map[string]userRevocation),
+		rateLimits:      make(map[string]rateWindow),
+
+		impersonations: make(map[uuid.UUID]models.Impersonation),
+
+		scimUsers:  make(map[uuid.UUID]models.SCIMUser),
+		scimGroups: make(map[uuid.UUID]models.SCIMGroup),
+
+		profiles:    make(map[uuid.UUID]models.Profile),
+		preferences: make(map[preferencesKey]models.Preferences),
+
+		claimedEvents: make(map[string]time.Time),
+	}
+}
@@ -0,0 +1,60 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"github.com/intellifinder/v4/services/auth/internal/domain/revocation"
+)
+
+var _ revocation.Repository = (*Repository)(nil)
+
+type userRevocation struct {
+	issuedBefore time.Time
+	expiresAt    time.Time
+}
+
+func (r *Repository) RevokeToken(_ context.Context, tokenID string, expiresAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.revokedTokens[tokenID] = expiresAt
+	return nil
+}
+
+func (r *Repository) RevokeSession(_ context.Context, sessionID string, expiresAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.revokedSessions[sessionID] = expiresAt
+	return nil
+}
+
+func (r *Repository) RevokeUserTokens(_ context.Context, userID string, issuedBefore time.Time, expiresAt time.Time) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	existing, ok := r.revokedUsers[userID]
+	if ok && existing.expiresAt.After(time.Now()) && !existing.issuedBefore.Before(issuedBefore) {
+		return nil
+	}
+
+	r.revokedUsers[userID] = userRevocation{issuedBefore: issuedBefore, expiresAt: expiresAt}
+	return nil
+}
+
+func (r *Repository) GetRevocations(_ context.Context, tokenID string, sessionID string, userID string) (*revocation.Revocations, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	now := time.Now()
+	revocations := &revocation.Revocations{
+		TokenRevoked:   r.revokedTokens[tokenID].After(now),
+		SessionRevoked: r.revokedSessions[sessionID].After(now),
+	}
+	if user, ok := r.revokedUsers[userID]; ok && user.expiresAt.After(now) {
+		revocations.UserTokensIssuedBefore = user.issuedBefore
+	}
+
+	return revocations, nil
+}
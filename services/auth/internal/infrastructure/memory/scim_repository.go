@@ -0,0 +1,171 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/intellifinder/v4/services/auth/internal/domain/scim"
+	"github.com/intellifinder/v4/services/auth/pkg/models"
+)
+
+var _ scim.Repository = (*Repository)(nil)
+
+func (r *Repository) CreateSCIMUser(_ context.Context, u *models.SCIMUser) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.scimUsers[u.UserID]; ok {
+		return scim.ErrUniqueness
+	}
+	r.scimUsers[u.UserID] = *u
+	return nil
+}
+
+func (r *Repository) GetSCIMUser(_ context.Context, tenantID string, userID uuid.UUID) (*models.SCIMUser, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	u, ok := r.scimUsers[userID]
+	if !ok || u.TenantID != tenantID {
+		return nil, scim.ErrResourceNotFound
+	}
+	return &u, nil
+}
+
+func (r *Repository) ListSCIMUsers(_ context.Context, tenantID string) ([]*models.SCIMUser, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	users := []*models.SCIMUser{}
+	for _, u := range r.scimUsers {
+		if u.TenantID == tenantID {
+			copied := u
+			users = append(users, &copied)
+		}
+	}
+	return users, nil
+}
+
+func (r *Repository) UpdateSCIMUser(_ context.Context, u *models.SCIMUser) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	existing, ok := r.scimUsers[u.UserID]
+	if !ok || existing.TenantID != u.TenantID {
+		return scim.ErrResourceNotFound
+	}
+	existing.ExternalID = u.ExternalID
+	existing.UpdatedAt = u.UpdatedAt
+	r.scimUsers[u.UserID] = existing
+	return nil
+}
+
+func (r *Repository) DeleteSCIMUser(_ context.Context, tenantID string, userID uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if u, ok := r.scimUsers[userID]; !ok || u.TenantID != tenantID {
+		return scim.ErrResourceNotFound
+	}
+	delete(r.scimUsers, userID)
+
+	for id, g := range r.scimGroups {
+		if g.TenantID == tenantID && slices.Contains(g.Members, userID) {
+			g.Members = slices.DeleteFunc(slices.Clone(g.Members), func(member uuid.UUID) bool { return member == userID })
+			r.scimGroups[id] = g
+		}
+	}
+	return nil
+}
+
+func (r *Repository) CreateSCIMGroup(_ context.Context, g *models.SCIMGroup) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.scimGroupNameTaken(g) {
+		return scim.ErrUniqueness
+	}
+	r.scimGroups[g.ID] = cloneSCIMGroup(*g)
+	return nil
+}
+
+func (r *Repository) GetSCIMGroup(_ context.Context, tenantID string, id uuid.UUID) (*models.SCIMGroup, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	g, ok := r.scimGroups[id]
+	if !ok || g.TenantID != tenantID {
+		return nil, scim.ErrResourceNotFound
+	}
+	copied := cloneSCIMGroup(g)
+	return &copied, nil
+}
+
+func (r *Repository) ListSCIMGroups(_ context.Context, tenantID string) ([]*models.SCIMGroup, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	groups := []*models.SCIMGroup{}
+	for _, g := range r.scimGroups {
+		if g.TenantID == tenantID {
+			copied := cloneSCIMGroup(g)
+			groups = append(groups, &copied)
+		}
+	}
+
+	slices.SortFunc(groups, func(a, b *models.SCIMGroup) int {
+		return strings.Compare(a.DisplayName, b.DisplayName)
+	})
+	return groups, nil
+}
+
+func (r *Repository) UpdateSCIMGroup(_ context.Context, g *models.SCIMGroup) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	existing, ok := r.scimGroups[g.ID]
+	if !ok || existing.TenantID != g.TenantID {
+		return scim.ErrResourceNotFound
+	}
+	if r.scimGroupNameTaken(g) {
+		return scim.ErrUniqueness
+	}
+
+	existing.DisplayName = g.DisplayName
+	existing.ExternalID = g.ExternalID
+	existing.Members = slices.Clone(g.Members)
+	existing.UpdatedAt = g.UpdatedAt
+	r.scimGroups[g.ID] = existing
+	return nil
+}
+
+func (r *Repository) DeleteSCIMGroup(_ context.Context, tenantID string, id uuid.UUID) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if g, ok := r.scimGroups[id]; !ok || g.TenantID != tenantID {
+		return scim.ErrResourceNotFound
+	}
+	delete(r.scimGroups, id)
+	return nil
+}
+
+// scimGroupNameTaken reports whether another group of the tenant has the group's display name
+func (r *Repository) scimGroupNameTaken(g *models.SCIMGroup) bool {
+	for _, other := range r.scimGroups {
+		if other.ID != g.ID && other.TenantID == g.TenantID && other.DisplayName == g.DisplayName {
+			return true
+		}
+	}
+	return false
+}
+
+func cloneSCIMGroup(g models.SCIMGroup) models.SCIMGroup {
+	g.Members = slices.Clone(g.Members)
+	if g.Members == nil {
+		g.Members = []uuid.UUID{}
+	}
+	return g
+}
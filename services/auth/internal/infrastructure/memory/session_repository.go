@@ -0,0 +1,93 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/intellifinder/v4/services/auth/internal/domain/session"
+	"github.com/intellifinder/v4/services/auth/pkg/models"
+)
+
+var _ session.Repository = (*Repository)(nil)
+
+func (r *Repository) CreateSession(_ context.Context, s *models.Session) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.activeSession(s.ID); ok {
+		return fmt.Errorf("failed to create session: %s already exists", s.ID)
+	}
+
+	r.sessions[s.ID] = cloneSession(*s)
+	return nil
+}
+
+func (r *Repository) GetSession(_ context.Context, id string) (*models.Session, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	s, ok := r.activeSession(id)
+	if !ok {
+		return nil, session.ErrSessionNotFound
+	}
+
+	s = cloneSession(s)
+	return &s, nil
+}
+
+func (r *Repository) UpdateSession(_ context.Context, s *models.Session, generation int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	existing, ok := r.activeSession(s.ID)
+	if !ok || existing.Generation != generation {
+		return session.ErrSessionConflict
+	}
+
+	r.sessions[s.ID] = cloneSession(*s)
+	return nil
+}
+
+func (r *Repository) ListSessions(_ context.Context, userID string) ([]*models.Session, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	sessions := []*models.Session{}
+	for id := range r.sessions {
+		if s, ok := r.activeSession(id); ok && s.UserID == userID {
+			s = cloneSession(s)
+			sessions = append(sessions, &s)
+		}
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastSeenAt.After(sessions[j].LastSeenAt)
+	})
+	return sessions, nil
+}
+
+func (r *Repository) DeleteSession(_ context.Context, s *models.Session) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.sessions, s.ID)
+	return nil
+}
+
+// activeSession returns the session unless it's missing or expired, which
+// Redis would have evicted
+func (r *Repository) activeSession(id string) (models.Session, bool) {
+	s, ok := r.sessions[id]
+	if !ok || !s.ExpiresAt.After(time.Now()) {
+		return models.Session{}, false
+	}
+	return s, true
+}
+
+func cloneSession(s models.Session) models.Session {
+	s.Key = slices.Clone(s.Key)
+	return s
+}
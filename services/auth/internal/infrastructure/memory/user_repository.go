@@ -0,0 +1,176 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/intellifinder/v4/services/auth/internal/domain/user"
+	"github.com/intellifinder/v4/services/auth/pkg/dto"
+	"github.com/intellifinder/v4/services/auth/pkg/models"
+)
+
+var _ user.Repository = (*Repository)(nil)
+
+func (r *Repository) UpsertUser(_ context.Context, u *models.User) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for id, existing := range r.users {
+		if existing.OIDCID != u.OIDCID {
+			continue
+		}
+
+		changed := existing.Username != u.Username || existing.Email != u.Email ||
+			existing.FirstName != u.FirstName || existing.LastName != u.LastName || existing.Enabled != u.Enabled
+		if !changed && u.LastLoginAt == nil {
+			return nil
+		}
+
+		existing.Username, existing.Email = u.Username, u.Email
+		existing.FirstName, existing.LastName = u.FirstName, u.LastName
+		existing.Enabled = u.Enabled
+		if u.LastLoginAt != nil {
+			existing.LastLoginAt = u.LastLoginAt
+		}
+		existing.UpdatedAt = u.UpdatedAt
+		r.users[id] = existing
+		return nil
+	}
+
+	stored := *u
+	stored.ID = uuid.New()
+	stored.DeletedAt = nil
+	r.users[stored.ID] = stored
+	return nil
+}
+
+func (r *Repository) GetUser(_ context.Context, id uuid.UUID) (*models.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	u, ok := r.users[id]
+	if !ok {
+		return nil, user.ErrUserNotFound
+	}
+	return &u, nil
+}
+
+func (r *Repository) GetUserByOIDCID(_ context.Context, oidcID string) (*models.User, error) {
+	return r.findUser(func(u models.User) bool { return u.OIDCID == oidcID })
+}
+
+func (r *Repository) GetUserByEmail(_ context.Context, email string) (*models.User, error) {
+	return r.findUser(func(u models.User) bool { return u.Email == email })
+}
+
+func (r *Repository) GetUserByUsername(_ context.Context, username string) (*models.User, error) {
+	return r.findUser(func(u models.User) bool { return u.Username == username })
+}
+
+// findUser returns the most recently updated user that matches and hasn't been deleted
+func (r *Repository) findUser(match func(models.User) bool) (*models.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var found *models.User
+	for _, u := range r.users {
+		if u.DeletedAt == nil && match(u) && (found == nil || u.UpdatedAt.After(found.UpdatedAt)) {
+			found = &u
+		}
+	}
+
+	if found == nil {
+		return nil, user.ErrUserNotFound
+	}
+	return found, nil
+}
+
+func (r *Repository) GetUsers(_ context.Context, ids []uuid.UUID) ([]*models.User, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	users := []*models.User{}
+	for _, id := range ids {
+		if u, ok := r.users[id]; ok {
+			users = append(users, &u)
+		}
+	}
+	return users, nil
+}
+
+func (r *Repository) GetUsersByOIDCIDs(ctx context.Context, oidcIDs []string) ([]*models.User, error) {
+	users := []*models.User{}
+	for _, oidcID := range oidcIDs {
+		if u, err := r.GetUserByOIDCID(ctx, oidcID); err == nil {
+			users = append(users, u)
+		}
+	}
+	return users, nil
+}
+
+func (r *Repository) ListUsers(_ context.Context, filter dto.UserFilter, page int32, limit int32) (*dto.PaginatedUsers, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	search := strings.ToLower(filter.Search)
+	matches := []*models.User{}
+	for _, u := range r.users {
+		if u.DeletedAt != nil && !filter.IncludeDeleted {
+			continue
+		}
+		if search != "" && !strings.Contains(strings.ToLower(u.Username), search) &&
+			!strings.Contains(strings.ToLower(u.Email), search) &&
+			!strings.Contains(strings.ToLower(u.FirstName+" "+u.LastName), search) {
+			continue
+		}
+		matches = append(matches, &u)
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Username != matches[j].Username {
+			return matches[i].Username < matches[j].Username
+		}
+		return matches[i].ID.String() < matches[j].ID.String()
+	})
+
+	offset := int((page - 1) * limit)
+	users := matches[min(offset, len(matches)):min(offset+int(limit), len(matches))]
+	return dto.NewPaginatedUsers(users, page, limit, int32(len(matches))), nil
+}
+
+func (r *Repository) ListActiveOIDCIDs(_ context.Context) ([]string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	ids := []string{}
+	for _, u := range r.users {
+		if u.DeletedAt == nil {
+			ids = append(ids, u.OIDCID)
+		}
+	}
+	return ids, nil
+}
+
+func (r *Repository) MarkUsersDeleted(_ context.Context, oidcIDs []string, deletedAt time.Time) (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	remove := make(map[string]bool, len(oidcIDs))
+	for _, id := range oidcIDs {
+		remove[id] = true
+	}
+
+	deleted := 0
+	for id, u := range r.users {
+		if u.DeletedAt == nil && remove[u.OIDCID] {
+			u.DeletedAt = &deletedAt
+			u.UpdatedAt = deletedAt
+			r.users[id] = u
+			deleted++
+		}
+	}
+	return deleted, nil
+}
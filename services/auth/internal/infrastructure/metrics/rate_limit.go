@@ -0,0 +1,41 @@
+// Package metrics exports the auth service's Prometheus metrics
+package metrics
+
+import (
+	"github.com/intellifinder/v4/services/auth/internal/domain/ratelimit"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var _ ratelimit.Recorder = (*RateLimits)(nil)
+
+// RateLimits counts the attempts refused by rate limits and lockouts
+type RateLimits struct {
+	blocked  *prometheus.CounterVec
+	lockouts *prometheus.CounterVec
+}
+
+// NewRateLimits registers the rate limit metrics with the registerer
+func NewRateLimits(registerer prometheus.Registerer) *RateLimits {
+	m := &RateLimits{
+		blocked: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "auth",
+			Name:      "rate_limit_blocked_total",
+			Help:      "Attempts refused by a rate limit or lockout, by endpoint and the dimension that refused them.",
+		}, []string{"endpoint", "dimension"}),
+		lockouts: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "auth",
+			Name:      "lockouts_total",
+			Help:      "Lockouts after repeated failures, by endpoint.",
+		}, []string{"endpoint"}),
+	}
+	registerer.MustRegister(m.blocked, m.lockouts)
+	return m
+}
+
+func (m *RateLimits) RecordBlocked(endpoint string, dimension string) {
+	m.blocked.WithLabelValues(endpoint, dimension).Inc()
+}
+
+func (m *RateLimits) RecordLockout(endpoint string) {
+	m.lockouts.WithLabelValues(endpoint).Inc()
+}
@@ -0,0 +1,27 @@
+package metrics
+
+import (
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestRateLimits(t *testing.T) {
+	m := NewRateLimits(prometheus.NewRegistry())
+
+	m.RecordBlocked("login", "ip")
+	m.RecordBlocked("login", "ip")
+	m.RecordBlocked("login", "lockout")
+	m.RecordLockout("login")
+
+	if got := testutil.ToFloat64(m.blocked.WithLabelValues("login", "ip")); got != 2 {
+		t.Errorf("blocked login attempts by IP = %v, want 2", got)
+	}
+	if got := testutil.ToFloat64(m.blocked.WithLabelValues("login", "lockout")); got != 1 {
+		t.Errorf("blocked login attempts by lockout = %v, want 1", got)
+	}
+	if got := testutil.ToFloat64(m.lockouts.WithLabelValues("login")); got != 1 {
+		t.Errorf("login lockouts = %v, want 1", got)
+	}
+}
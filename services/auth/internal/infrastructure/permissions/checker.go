@@ -0,0 +1,43 @@
+// Package permissions checks permissions with the permissions service
+package permissions
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/intellifinder/v4/services/auth/internal/domain/impersonation"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	permissionsv1 "intellifinder/services/permissions/api/v1"
+)
+
+var _ impersonation.PermissionChecker = (*Checker)(nil)
+
+// Checker asks the permissions service's Authorize. The connection must send
+// the auth service's service token with every call.
+type Checker struct {
+	client permissionsv1.PermissionServiceClient
+}
+
+func NewChecker(conn grpc.ClientConnInterface) *Checker {
+	return &Checker{client: permissionsv1.NewPermissionServiceClient(conn)}
+}
+
+func (c *Checker) HasPermission(ctx context.Context, subject string, tenantID string, permission string) (bool, error) {
+	resp, err := c.client.Authorize(ctx, &permissionsv1.AuthorizeRequest{
+		Subject:    subject,
+		Permission: permission,
+		Tenant:     tenantID,
+	})
+	if err != nil {
+		switch status.Code(err) {
+		case codes.Unavailable, codes.DeadlineExceeded, codes.Unauthenticated:
+			return false, fmt.Errorf("%w: %w", impersonation.ErrUnavailable, err)
+		default:
+			return false, fmt.Errorf("failed to authorize %s for %s: %w", subject, permission, err)
+		}
+	}
+
+	return resp.Allowed, nil
+}
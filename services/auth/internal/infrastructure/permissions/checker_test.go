@@ -0,0 +1,85 @@
+package permissions
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/intellifinder/v4/services/auth/internal/domain/impersonation"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	permissionsv1 "intellifinder/services/permissions/api/v1"
+)
+
+// fakePermissions allows support to impersonate in tenant-1
+type fakePermissions struct {
+	permissionsv1.UnimplementedPermissionServiceServer
+	err error
+}
+
+func (f *fakePermissions) Authorize(_ context.Context, req *permissionsv1.AuthorizeRequest) (*permissionsv1.AuthorizeResponse, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	allowed := req.Subject == "kc-support" && req.Permission == impersonation.Permission && req.Tenant == "tenant-1"
+	return &permissionsv1.AuthorizeResponse{Allowed: allowed}, nil
+}
+
+func newTestChecker(t *testing.T, server *fakePermissions) *Checker {
+	t.Helper()
+
+	listener := bufconn.Listen(1024 * 1024)
+	s := grpc.NewServer()
+	permissionsv1.RegisterPermissionServiceServer(s, server)
+	go s.Serve(listener)
+	t.Cleanup(s.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatalf("failed to dial bufconn: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return NewChecker(conn)
+}
+
+func TestHasPermission(t *testing.T) {
+	server := &fakePermissions{}
+	checker := newTestChecker(t, server)
+	ctx := context.Background()
+
+	tests := []struct {
+		subject string
+		tenant  string
+		want    bool
+	}{
+		{subject: "kc-support", tenant: "tenant-1", want: true},
+		{subject: "kc-support", tenant: "tenant-2", want: false},
+		{subject: "kc-alice", tenant: "tenant-1", want: false},
+	}
+	for _, tt := range tests {
+		got, err := checker.HasPermission(ctx, tt.subject, tt.tenant, impersonation.Permission)
+		if err != nil || got != tt.want {
+			t.Errorf("HasPermission(%s, %s) = %v, %v, want %v", tt.subject, tt.tenant, got, err, tt.want)
+		}
+	}
+
+	server.err = status.Error(codes.Unavailable, "database down")
+	if _, err := checker.HasPermission(ctx, "kc-support", "tenant-1", impersonation.Permission); !errors.Is(err, impersonation.ErrUnavailable) {
+		t.Errorf("HasPermission() error = %v, want %v", err, impersonation.ErrUnavailable)
+	}
+
+	server.err = status.Error(codes.Internal, "bug")
+	if _, err := checker.HasPermission(ctx, "kc-support", "tenant-1", impersonation.Permission); err == nil || errors.Is(err, impersonation.ErrUnavailable) {
+		t.Errorf("HasPermission() error = %v, want an internal error", err)
+	}
+}
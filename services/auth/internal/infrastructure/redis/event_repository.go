@@ -0,0 +1,88 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/intellifinder/v4/services/auth/internal/domain/ingestion"
+	"github.com/intellifinder/v4/services/auth/pkg/models"
+	goredis "github.com/redis/go-redis/v9"
+)
+
+var (
+	_ ingestion.Repository = (*Repository)(nil)
+	_ ingestion.Publisher  = (*Repository)(nil)
+)
+
+// eventCursorKey holds the time event polling has caught up to, in Unix milliseconds
+const eventCursorKey = keyPrefix + "event_cursor"
+
+// advanceEventCursor stores ARGV[1] in KEYS[1] unless a later time is stored already
+var advanceEventCursor = goredis.NewScript(`
+local current = redis.call('GET', KEYS[1])
+if current and tonumber(current) >= tonumber(ARGV[1]) then
+	return 0
+end
+redis.call('SET', KEYS[1], ARGV[1])
+return 1
+`)
+
+func (r *Repository) ClaimEvent(ctx context.Context, id string, ttl time.Duration) (bool, error) {
+	claimed, err := r.client.SetNX(ctx, claimedEventKey(id), 1, ttl).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to claim event: %w", err)
+	}
+
+	return claimed, nil
+}
+
+func (r *Repository) ReleaseEvent(ctx context.Context, id string) error {
+	if err := r.client.Del(ctx, claimedEventKey(id)).Err(); err != nil {
+		return fmt.Errorf("failed to release event: %w", err)
+	}
+
+	return nil
+}
+
+func (r *Repository) GetEventCursor(ctx context.Context) (time.Time, error) {
+	ms, err := r.client.Get(ctx, eventCursorKey).Int64()
+	if errors.Is(err, goredis.Nil) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, fmt.Errorf("failed to get event cursor: %w", err)
+	}
+
+	return time.UnixMilli(ms), nil
+}
+
+func (r *Repository) SetEventCursor(ctx context.Context, cursor time.Time) error {
+	if err := advanceEventCursor.Run(ctx, r.client, []string{eventCursorKey}, strconv.FormatInt(cursor.UnixMilli(), 10)).Err(); err != nil {
+		return fmt.Errorf("failed to set event cursor: %w", err)
+	}
+
+	return nil
+}
+
+// PublishEvent publishes the event as JSON on models.EventsChannel. Pub/sub
+// doesn't keep messages, so only services subscribed at the time receive it.
+func (r *Repository) PublishEvent(ctx context.Context, event *models.DomainEvent) error {
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("failed to encode event: %w", err)
+	}
+
+	if err := r.client.Publish(ctx, models.EventsChannel, payload).Err(); err != nil {
+		return fmt.Errorf("failed to publish event: %w", err)
+	}
+
+	return nil
+}
+
+func claimedEventKey(id string) string {
+	return keyPrefix + "claimed_event:" + id
+}
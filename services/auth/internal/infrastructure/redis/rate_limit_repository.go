@@ -0,0 +1,91 @@
+package redis
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/intellifinder/v4/services/auth/internal/domain/ratelimit"
+	goredis "github.com/redis/go-redis/v9"
+)
+
+var _ ratelimit.Repository = (*Repository)(nil)
+
+// hit increments the counter of the current window in KEYS[1], keeping it
+// for ARGV[1] milliseconds, and returns it with the counter of the previous
+// window in KEYS[2]
+var hit = goredis.NewScript(`
+local count = redis.call('INCR', KEYS[1])
+if count == 1 or redis.call('PTTL', KEYS[1]) < 0 then
+	redis.call('PEXPIRE', KEYS[1], ARGV[1])
+end
+local previous = tonumber(redis.call('GET', KEYS[2]) or '0')
+return {previous, count}
+`)
+
+// addStrike increments the counter in KEYS[1] and restarts its expiry of
+// ARGV[1] milliseconds
+var addStrike = goredis.NewScript(`
+local count = redis.call('INCR', KEYS[1])
+redis.call('PEXPIRE', KEYS[1], ARGV[1])
+return count
+`)
+
+// Hit counts in fixed windows aligned to the Unix epoch, so every replica
+// counts in the same windows
+func (r *Repository) Hit(ctx context.Context, key string, window time.Duration) (int, int, time.Duration, error) {
+	now := time.Now()
+	start := now.Truncate(window)
+	keys := []string{windowKey(key, start), windowKey(key, start.Add(-window))}
+
+	result, err := hit.Run(ctx, r.client, keys, (2 * window).Milliseconds()).Int64Slice()
+	if err != nil {
+		return 0, 0, 0, fmt.Errorf("failed to count attempt: %w", err)
+	}
+
+	return int(result[0]), int(result[1]), now.Sub(start), nil
+}
+
+func (r *Repository) ResetHits(ctx context.Context, key string, window time.Duration) error {
+	start := time.Now().Truncate(window)
+	if err := r.client.Del(ctx, windowKey(key, start), windowKey(key, start.Add(-window))).Err(); err != nil {
+		return fmt.Errorf("failed to reset attempts: %w", err)
+	}
+	return nil
+}
+
+func (r *Repository) AddStrike(ctx context.Context, key string, ttl time.Duration) (int, error) {
+	count, err := addStrike.Run(ctx, r.client, []string{rateLimitKey(key)}, ttl.Milliseconds()).Int()
+	if err != nil {
+		return 0, fmt.Errorf("failed to count strike: %w", err)
+	}
+	return count, nil
+}
+
+func (r *Repository) Lock(ctx context.Context, key string, duration time.Duration) error {
+	if err := r.client.Set(ctx, rateLimitKey(key), 1, duration).Err(); err != nil {
+		return fmt.Errorf("failed to lock out: %w", err)
+	}
+	return nil
+}
+
+func (r *Repository) LockedFor(ctx context.Context, key string) (time.Duration, error) {
+	ttl, err := r.client.PTTL(ctx, rateLimitKey(key)).Result()
+	if errors.Is(err, goredis.Nil) || err == nil && ttl < 0 {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to get lockout: %w", err)
+	}
+	return ttl, nil
+}
+
+func rateLimitKey(key string) string {
+	return keyPrefix + "rate_limit:" + key
+}
+
+func windowKey(key string, start time.Time) string {
+	return rateLimitKey(key) + ":" + strconv.FormatInt(start.UnixMilli(), 10)
+}
@@ -0,0 +1,20 @@
+// Package redis stores the auth service's short-lived state in Redis
+package redis
+
+import (
+	goredis "github.com/redis/go-redis/v9"
+)
+
+// keyPrefix namespaces the auth service's keys in a Redis shared with other services
+const keyPrefix = "auth:"
+
+type Repository struct {
+	client *goredis.Client
+}
+
+// NewRepository creates a repository backed by the given client
+func NewRepository(client *goredis.Client) *Repository {
+	return &Repository{
+		client: client,
+	}
+}
@@ -0,0 +1,263 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/intellifinder/v4/services/auth/internal/domain/session"
+	"github.com/intellifinder/v4/services/auth/pkg/models"
+	goredis "github.com/redis/go-redis/v9"
+)
+
+// newTestRepository connects to the Redis server in TEST_REDIS_URL. All data
+// in its database is deleted.
+func newTestRepository(t *testing.T) *Repository {
+	t.Helper()
+
+	url := os.Getenv("TEST_REDIS_URL")
+	if url == "" {
+		t.Skip("TEST_REDIS_URL not set")
+	}
+
+	opts, err := goredis.ParseURL(url)
+	if err != nil {
+		t.Fatalf("failed to parse TEST_REDIS_URL: %v", err)
+	}
+
+	client := goredis.NewClient(opts)
+	t.Cleanup(func() { client.Close() })
+
+	if err := client.FlushDB(context.Background()).Err(); err != nil {
+		t.Fatalf("failed to flush database: %v", err)
+	}
+
+	return NewRepository(client)
+}
+
+func TestSessionRepository(t *testing.T) {
+	repo := newTestRepository(t)
+	ctx := context.Background()
+
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	first := &models.Session{
+		ID:                   "sid-1",
+		UserID:               "user-1",
+		Device:               "Firefox",
+		IPAddress:            "192.0.2.1",
+		CreatedAt:            now,
+		LastSeenAt:           now,
+		ExpiresAt:            now.Add(time.Hour),
+		Key:                  []byte("key"),
+		UpstreamRefreshToken: "upstream-1",
+	}
+	if err := repo.CreateSession(ctx, first); err != nil {
+		t.Fatalf("CreateSession() error = %v", err)
+	}
+	if err := repo.CreateSession(ctx, first); err == nil {
+		t.Error("CreateSession() with an existing ID succeeded, want an error")
+	}
+
+	second := *first
+	second.ID = "sid-2"
+	second.LastSeenAt = now.Add(time.Minute)
+	if err := repo.CreateSession(ctx, &second); err != nil {
+		t.Fatalf("CreateSession() error = %v", err)
+	}
+
+	got, err := repo.GetSession(ctx, "sid-1")
+	if err != nil {
+		t.Fatalf("GetSession() error = %v", err)
+	}
+	if got.UserID != "user-1" || string(got.Key) != "key" || got.UpstreamRefreshToken != "upstream-1" || !got.ExpiresAt.Equal(first.ExpiresAt) {
+		t.Errorf("GetSession() = %+v, want %+v", got, first)
+	}
+	if _, err := repo.GetSession(ctx, "missing"); !errors.Is(err, session.ErrSessionNotFound) {
+		t.Errorf("GetSession() error = %v, want ErrSessionNotFound", err)
+	}
+
+	rotated := *first
+	rotated.Generation = 1
+	rotated.UpstreamRefreshToken = "upstream-2"
+	rotated.LastSeenAt = now.Add(2 * time.Minute)
+	if err := repo.UpdateSession(ctx, &rotated, 0); err != nil {
+		t.Fatalf("UpdateSession() error = %v", err)
+	}
+	if err := repo.UpdateSession(ctx, &rotated, 0); !errors.Is(err, session.ErrSessionConflict) {
+		t.Errorf("UpdateSession() of a stale generation error = %v, want ErrSessionConflict", err)
+	}
+
+	sessions, err := repo.ListSessions(ctx, "user-1")
+	if err != nil {
+		t.Fatalf("ListSessions() error = %v", err)
+	}
+	if len(sessions) != 2 || sessions[0].ID != "sid-1" || sessions[0].Generation != 1 || sessions[1].ID != "sid-2" {
+		t.Errorf("ListSessions() = %v, want sid-1 at generation 1, then sid-2", sessions)
+	}
+
+	if err := repo.DeleteSession(ctx, &rotated); err != nil {
+		t.Fatalf("DeleteSession() error = %v", err)
+	}
+	if err := repo.DeleteSession(ctx, &rotated); err != nil {
+		t.Errorf("DeleteSession() again error = %v", err)
+	}
+	if err := repo.UpdateSession(ctx, &rotated, 1); !errors.Is(err, session.ErrSessionConflict) {
+		t.Errorf("UpdateSession() of a deleted session error = %v, want ErrSessionConflict", err)
+	}
+
+	sessions, _ = repo.ListSessions(ctx, "user-1")
+	if len(sessions) != 1 || sessions[0].ID != "sid-2" {
+		t.Errorf("ListSessions() = %v, want only sid-2", sessions)
+	}
+}
+
+func TestSessionsExpire(t *testing.T) {
+	repo := newTestRepository(t)
+	ctx := context.Background()
+
+	now := time.Now()
+	s := &models.Session{ID: "sid-1", UserID: "user-1", CreatedAt: now, LastSeenAt: now, ExpiresAt: now.Add(100 * time.Millisecond)}
+	if err := repo.CreateSession(ctx, s); err != nil {
+		t.Fatalf("CreateSession() error = %v", err)
+	}
+
+	time.Sleep(200 * time.Millisecond)
+
+	if _, err := repo.GetSession(ctx, "sid-1"); !errors.Is(err, session.ErrSessionNotFound) {
+		t.Errorf("GetSession() error = %v, want ErrSessionNotFound after expiry", err)
+	}
+	if sessions, _ := repo.ListSessions(ctx, "user-1"); len(sessions) != 0 {
+		t.Errorf("ListSessions() = %v, want none after expiry", sessions)
+	}
+}
+
+func TestRevocationRepository(t *testing.T) {
+	repo := newTestRepository(t)
+	ctx := context.Background()
+
+	now := time.Now().Truncate(time.Millisecond)
+	if err := repo.RevokeToken(ctx, "jti-1", now.Add(time.Hour)); err != nil {
+		t.Fatalf("RevokeToken() error = %v", err)
+	}
+	if err := repo.RevokeSession(ctx, "sid-1", now.Add(time.Hour)); err != nil {
+		t.Fatalf("RevokeSession() error = %v", err)
+	}
+	if err := repo.RevokeUserTokens(ctx, "user-1", now, now.Add(time.Hour)); err != nil {
+		t.Fatalf("RevokeUserTokens() error = %v", err)
+	}
+	if err := repo.RevokeUserTokens(ctx, "user-1", now.Add(-time.Minute), now.Add(time.Hour)); err != nil {
+		t.Fatalf("RevokeUserTokens() error = %v", err)
+	}
+
+	revocations, err := repo.GetRevocations(ctx, "jti-1", "sid-1", "user-1")
+	if err != nil {
+		t.Fatalf("GetRevocations() error = %v", err)
+	}
+	if !revocations.TokenRevoked || !revocations.SessionRevoked || !revocations.UserTokensIssuedBefore.Equal(now) {
+		t.Errorf("GetRevocations() = %+v, want everything revoked and the later issue time kept", revocations)
+	}
+
+	revocations, err = repo.GetRevocations(ctx, "jti-2", "", "user-2")
+	if err != nil {
+		t.Fatalf("GetRevocations() error = %v", err)
+	}
+	if revocations.TokenRevoked || revocations.SessionRevoked || !revocations.UserTokensIssuedBefore.IsZero() {
+		t.Errorf("GetRevocations() = %+v, want nothing revoked", revocations)
+	}
+}
+
+func TestRateLimitRepository(t *testing.T) {
+	repo := newTestRepository(t)
+	ctx := context.Background()
+
+	// A window may end between hits, moving the earlier ones to the previous window
+	for want := 1; want <= 3; want++ {
+		previous, current, elapsed, err := repo.Hit(ctx, "login:alice", time.Hour)
+		if err != nil {
+			t.Fatalf("Hit() error = %v", err)
+		}
+		if previous+current != want || elapsed < 0 || elapsed >= time.Hour {
+			t.Errorf("Hit() = %d, %d, %v, want %d in total within the hour", previous, current, elapsed, want)
+		}
+	}
+
+	if previous, current, _, err := repo.Hit(ctx, "login:bob", time.Hour); err != nil || previous+current != 1 {
+		t.Errorf("Hit() for another key = %d, %d, %v, want 1", previous, current, err)
+	}
+
+	if err := repo.ResetHits(ctx, "login:alice", time.Hour); err != nil {
+		t.Fatalf("ResetHits() error = %v", err)
+	}
+	if previous, current, _, err := repo.Hit(ctx, "login:alice", time.Hour); err != nil || previous+current != 1 {
+		t.Errorf("Hit() after ResetHits() = %d, %d, %v, want 1", previous, current, err)
+	}
+
+	for want := 1; want <= 2; want++ {
+		if strikes, err := repo.AddStrike(ctx, "login:strikes:alice", time.Hour); err != nil || strikes != want {
+			t.Errorf("AddStrike() = %d, %v, want %d", strikes, err, want)
+		}
+	}
+
+	if locked, err := repo.LockedFor(ctx, "login:lockout:alice"); err != nil || locked != 0 {
+		t.Errorf("LockedFor() before Lock() = %v, %v, want 0", locked, err)
+	}
+	if err := repo.Lock(ctx, "login:lockout:alice", time.Minute); err != nil {
+		t.Fatalf("Lock() error = %v", err)
+	}
+	if locked, err := repo.LockedFor(ctx, "login:lockout:alice"); err != nil || locked <= 0 || locked > time.Minute {
+		t.Errorf("LockedFor() = %v, %v, want within a minute", locked, err)
+	}
+}
+
+func TestEventRepository(t *testing.T) {
+	repo := newTestRepository(t)
+	ctx := context.Background()
+
+	if claimed, err := repo.ClaimEvent(ctx, "event-1", time.Minute); err != nil || !claimed {
+		t.Fatalf("ClaimEvent() = %v, %v, want claimed", claimed, err)
+	}
+	if claimed, err := repo.ClaimEvent(ctx, "event-1", time.Minute); err != nil || claimed {
+		t.Errorf("ClaimEvent() again = %v, %v, want not claimed", claimed, err)
+	}
+	if err := repo.ReleaseEvent(ctx, "event-1"); err != nil {
+		t.Fatalf("ReleaseEvent() error = %v", err)
+	}
+	if claimed, err := repo.ClaimEvent(ctx, "event-1", time.Minute); err != nil || !claimed {
+		t.Errorf("ClaimEvent() after release = %v, %v, want claimed", claimed, err)
+	}
+
+	if cursor, err := repo.GetEventCursor(ctx); err != nil || !cursor.IsZero() {
+		t.Errorf("GetEventCursor() = %v, %v, want zero before polling", cursor, err)
+	}
+	now := time.Now().Truncate(time.Millisecond)
+	for _, cursor := range []time.Time{now, now.Add(-time.Hour)} {
+		if err := repo.SetEventCursor(ctx, cursor); err != nil {
+			t.Fatalf("SetEventCursor() error = %v", err)
+		}
+	}
+	if cursor, err := repo.GetEventCursor(ctx); err != nil || !cursor.Equal(now) {
+		t.Errorf("GetEventCursor() = %v, %v, want %v kept over an earlier time", cursor, err, now)
+	}
+
+	sub := repo.client.Subscribe(ctx, models.EventsChannel)
+	defer sub.Close()
+	if _, err := sub.Receive(ctx); err != nil {
+		t.Fatalf("Subscribe() error = %v", err)
+	}
+
+	event := &models.DomainEvent{ID: "1", Type: models.UserDisabled, UserID: "user-1", OccurredAt: now}
+	if err := repo.PublishEvent(ctx, event); err != nil {
+		t.Fatalf("PublishEvent() error = %v", err)
+	}
+	msg, err := sub.ReceiveMessage(ctx)
+	if err != nil {
+		t.Fatalf("ReceiveMessage() error = %v", err)
+	}
+	var received models.DomainEvent
+	if err := json.Unmarshal([]byte(msg.Payload), &received); err != nil || received.ID != event.ID || received.Type != event.Type || !received.OccurredAt.Equal(now) {
+		t.Errorf("received %s, want %+v", msg.Payload, event)
+	}
+}
@@ -0,0 +1,83 @@
+package redis
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/intellifinder/v4/services/auth/internal/domain/revocation"
+	goredis "github.com/redis/go-redis/v9"
+)
+
+var _ revocation.Repository = (*Repository)(nil)
+
+// revokeUserTokens stores the issue time in ARGV[1], in Unix milliseconds,
+// unless a later one is stored already
+var revokeUserTokens = goredis.NewScript(`
+local current = redis.call('GET', KEYS[1])
+if current and tonumber(current) >= tonumber(ARGV[1]) then
+	return 0
+end
+redis.call('SET', KEYS[1], ARGV[1], 'PXAT', ARGV[2])
+return 1
+`)
+
+func (r *Repository) RevokeToken(ctx context.Context, tokenID string, expiresAt time.Time) error {
+	if err := r.client.SetArgs(ctx, revokedTokenKey(tokenID), 1, goredis.SetArgs{ExpireAt: expiresAt}).Err(); err != nil {
+		return fmt.Errorf("failed to revoke token: %w", err)
+	}
+
+	return nil
+}
+
+func (r *Repository) RevokeSession(ctx context.Context, sessionID string, expiresAt time.Time) error {
+	if err := r.client.SetArgs(ctx, revokedSessionKey(sessionID), 1, goredis.SetArgs{ExpireAt: expiresAt}).Err(); err != nil {
+		return fmt.Errorf("failed to revoke session: %w", err)
+	}
+
+	return nil
+}
+
+func (r *Repository) RevokeUserTokens(ctx context.Context, userID string, issuedBefore time.Time, expiresAt time.Time) error {
+	keys := []string{revokedUserKey(userID)}
+	if err := revokeUserTokens.Run(ctx, r.client, keys, issuedBefore.UnixMilli(), expiresAt.UnixMilli()).Err(); err != nil {
+		return fmt.Errorf("failed to revoke user tokens: %w", err)
+	}
+
+	return nil
+}
+
+func (r *Repository) GetRevocations(ctx context.Context, tokenID string, sessionID string, userID string) (*revocation.Revocations, error) {
+	values, err := r.client.MGet(ctx, revokedTokenKey(tokenID), revokedSessionKey(sessionID), revokedUserKey(userID)).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get revocations: %w", err)
+	}
+
+	revocations := &revocation.Revocations{
+		TokenRevoked:   tokenID != "" && values[0] != nil,
+		SessionRevoked: sessionID != "" && values[1] != nil,
+	}
+
+	if issuedBefore, ok := values[2].(string); ok && userID != "" {
+		ms, err := strconv.ParseInt(issuedBefore, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse user revocation: %w", err)
+		}
+		revocations.UserTokensIssuedBefore = time.UnixMilli(ms)
+	}
+
+	return revocations, nil
+}
+
+func revokedTokenKey(tokenID string) string {
+	return keyPrefix + "revoked_token:" + tokenID
+}
+
+func revokedSessionKey(sessionID string) string {
+	return keyPrefix + "revoked_session:" + sessionID
+}
+
+func revokedUserKey(userID string) string {
+	return keyPrefix + "revoked_user:" + userID
+}
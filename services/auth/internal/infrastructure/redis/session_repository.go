@@ -0,0 +1,213 @@
+package redis
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/intellifinder/v4/services/auth/internal/domain/session"
+	"github.com/intellifinder/v4/services/auth/pkg/models"
+	goredis "github.com/redis/go-redis/v9"
+)
+
+var _ session.Repository = (*Repository)(nil)
+
+// A session is stored as JSON under its own key, which expires with it. Each
+// user has a sorted set of their session IDs scored by expiry in Unix
+// milliseconds, so sessions can be listed and expired members pruned.
+
+// storeSession is shared by the scripts below. It writes the session in
+// ARGV[1] expiring at ARGV[2] and adds it to the user's set, which lives as
+// long as the user's last session.
+const storeSession = `
+redis.call('SET', KEYS[1], ARGV[1], 'PXAT', ARGV[2])
+redis.call('ZADD', KEYS[2], ARGV[2], ARGV[3])
+local last = redis.call('ZRANGE', KEYS[2], -1, -1, 'WITHSCORES')
+redis.call('PEXPIREAT', KEYS[2], last[2])
+`
+
+var createSession = goredis.NewScript(`
+if redis.call('EXISTS', KEYS[1]) == 1 then
+	return 0
+end
+` + storeSession + `
+return 1
+`)
+
+// updateSession only stores the session if the stored one is still at the
+// generation in ARGV[4]
+var updateSession = goredis.NewScript(`
+local current = redis.call('GET', KEYS[1])
+if not current or cjson.decode(current).generation ~= tonumber(ARGV[4]) then
+	return 0
+end
+` + storeSession + `
+return 1
+`)
+
+// sessionRecord is the stored form of a session, including the fields
+// models.Session keeps out of API responses
+type sessionRecord struct {
+	ID                   string    `json:"id"`
+	UserID               string    `json:"user_id"`
+	Device               string    `json:"device"`
+	IPAddress            string    `json:"ip_address"`
+	CreatedAt            time.Time `json:"created_at"`
+	LastSeenAt           time.Time `json:"last_seen_at"`
+	ExpiresAt            time.Time `json:"expires_at"`
+	Generation           int       `json:"generation"`
+	Key                  []byte    `json:"key"`
+	UpstreamRefreshToken string    `json:"upstream_refresh_token"`
+}
+
+func (r *Repository) CreateSession(ctx context.Context, s *models.Session) error {
+	stored, err := r.storeSession(ctx, createSession, s)
+	if err != nil {
+		return fmt.Errorf("failed to create session: %w", err)
+	}
+	if !stored {
+		return fmt.Errorf("failed to create session: %s already exists", s.ID)
+	}
+
+	return nil
+}
+
+func (r *Repository) UpdateSession(ctx context.Context, s *models.Session, generation int) error {
+	stored, err := r.storeSession(ctx, updateSession, s, generation)
+	if err != nil {
+		return fmt.Errorf("failed to update session: %w", err)
+	}
+	if !stored {
+		return session.ErrSessionConflict
+	}
+
+	return nil
+}
+
+func (r *Repository) storeSession(ctx context.Context, script *goredis.Script, s *models.Session, args ...any) (bool, error) {
+	data, err := json.Marshal(sessionRecord{
+		ID:                   s.ID,
+		UserID:               s.UserID,
+		Device:               s.Device,
+		IPAddress:            s.IPAddress,
+		CreatedAt:            s.CreatedAt,
+		LastSeenAt:           s.LastSeenAt,
+		ExpiresAt:            s.ExpiresAt,
+		Generation:           s.Generation,
+		Key:                  s.Key,
+		UpstreamRefreshToken: s.UpstreamRefreshToken,
+	})
+	if err != nil {
+		return false, err
+	}
+
+	keys := []string{sessionKey(s.ID), userSessionsKey(s.UserID)}
+	args = append([]any{data, s.ExpiresAt.UnixMilli(), s.ID}, args...)
+
+	return script.Run(ctx, r.client, keys, args...).Bool()
+}
+
+func (r *Repository) GetSession(ctx context.Context, id string) (*models.Session, error) {
+	data, err := r.client.Get(ctx, sessionKey(id)).Bytes()
+	if errors.Is(err, goredis.Nil) {
+		return nil, session.ErrSessionNotFound
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get session: %w", err)
+	}
+
+	return decodeSession(data)
+}
+
+func (r *Repository) ListSessions(ctx context.Context, userID string) ([]*models.Session, error) {
+	key := userSessionsKey(userID)
+
+	now := strconv.FormatInt(time.Now().UnixMilli(), 10)
+	if err := r.client.ZRemRangeByScore(ctx, key, "-inf", now).Err(); err != nil {
+		return nil, fmt.Errorf("failed to prune sessions: %w", err)
+	}
+
+	ids, err := r.client.ZRange(ctx, key, 0, -1).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list sessions: %w", err)
+	}
+
+	sessions := []*models.Session{}
+	if len(ids) == 0 {
+		return sessions, nil
+	}
+
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = sessionKey(id)
+	}
+
+	values, err := r.client.MGet(ctx, keys...).Result()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get sessions: %w", err)
+	}
+
+	for _, value := range values {
+		// Sessions can expire between pruning and reading them
+		data, ok := value.(string)
+		if !ok {
+			continue
+		}
+
+		s, err := decodeSession([]byte(data))
+		if err != nil {
+			return nil, err
+		}
+		sessions = append(sessions, s)
+	}
+
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastSeenAt.After(sessions[j].LastSeenAt)
+	})
+	return sessions, nil
+}
+
+func (r *Repository) DeleteSession(ctx context.Context, s *models.Session) error {
+	_, err := r.client.TxPipelined(ctx, func(pipe goredis.Pipeliner) error {
+		pipe.Del(ctx, sessionKey(s.ID))
+		pipe.ZRem(ctx, userSessionsKey(s.UserID), s.ID)
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to delete session: %w", err)
+	}
+
+	return nil
+}
+
+func decodeSession(data []byte) (*models.Session, error) {
+	var record sessionRecord
+	if err := json.Unmarshal(data, &record); err != nil {
+		return nil, fmt.Errorf("failed to decode session: %w", err)
+	}
+
+	return &models.Session{
+		ID:                   record.ID,
+		UserID:               record.UserID,
+		Device:               record.Device,
+		IPAddress:            record.IPAddress,
+		CreatedAt:            record.CreatedAt,
+		LastSeenAt:           record.LastSeenAt,
+		ExpiresAt:            record.ExpiresAt,
+		Generation:           record.Generation,
+		Key:                  record.Key,
+		UpstreamRefreshToken: record.UpstreamRefreshToken,
+	}, nil
+}
+
+func sessionKey(id string) string {
+	return keyPrefix + "session:" + id
+}
+
+func userSessionsKey(userID string) string {
+	return keyPrefix + "user_sessions:" + userID
+}
@@ -0,0 +1,98 @@
+package rest
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/intellifinder/v4/libs/observability"
+	"github.com/intellifinder/v4/services/auth/internal/domain/apikey"
+	"github.com/intellifinder/v4/services/auth/pkg/dto"
+	"go.uber.org/zap"
+)
+
+func (h *Handler) CreateAPIKey(c *gin.Context) {
+	var req dto.CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	key, secret, err := h.apiKeys.Create(c.Request.Context(), identity(c), apikey.CreateParams{
+		Name:      req.Name,
+		Scopes:    req.Scopes,
+		Roles:     req.Roles,
+		ExpiresAt: req.ExpiresAt,
+	})
+	if err != nil {
+		apiKeyError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, dto.IssuedAPIKey{APIKey: key, Key: secret})
+}
+
+func (h *Handler) ListAPIKeys(c *gin.Context) {
+	keys, err := h.apiKeys.List(c.Request.Context(), identity(c))
+	if err != nil {
+		apiKeyError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.APIKeyList{APIKeys: keys})
+}
+
+func (h *Handler) RotateAPIKey(c *gin.Context) {
+	id, ok := parseID(c)
+	if !ok {
+		return
+	}
+
+	key, secret, err := h.apiKeys.Rotate(c.Request.Context(), identity(c), id)
+	if err != nil {
+		apiKeyError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.IssuedAPIKey{APIKey: key, Key: secret})
+}
+
+func (h *Handler) RevokeAPIKey(c *gin.Context) {
+	id, ok := parseID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.apiKeys.Revoke(c.Request.Context(), identity(c), id); err != nil {
+		apiKeyError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func parseID(c *gin.Context) (uuid.UUID, bool) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "invalid ID"})
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+func apiKeyError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, apikey.ErrAPIKeyNotFound):
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, apikey.ErrAPIKeyRevoked):
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, apikey.ErrInvalidAPIKey):
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, apikey.ErrRoleNotHeld):
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	default:
+		observability.Logger(c.Request.Context()).Error("API key request failed", zap.Error(err))
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+	}
+}
@@ -0,0 +1,152 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/intellifinder/v4/services/auth/internal/domain/apikey"
+	"github.com/intellifinder/v4/services/auth/internal/domain/authentication"
+	"github.com/intellifinder/v4/services/auth/internal/domain/impersonation"
+	"github.com/intellifinder/v4/services/auth/internal/domain/ratelimit"
+	"github.com/intellifinder/v4/services/auth/internal/domain/revocation"
+	"github.com/intellifinder/v4/services/auth/internal/domain/session"
+	"github.com/intellifinder/v4/services/auth/internal/domain/user"
+	"github.com/intellifinder/v4/services/auth/internal/infrastructure/memory"
+	"github.com/intellifinder/v4/services/auth/pkg/dto"
+	"github.com/intellifinder/v4/services/auth/pkg/models"
+)
+
+// owners holds the current roles of active API key owners
+type owners map[string][]string
+
+func (o owners) GetOwnerRoles(_ context.Context, oidcID string) ([]string, error) {
+	roles, ok := o[oidcID]
+	if !ok {
+		return nil, apikey.ErrOwnerInactive
+	}
+	return roles, nil
+}
+
+// newAPIKeyRouter accepts "alice-token" as an access token and verifies API
+// keys with a real key service
+func newAPIKeyRouter() *gin.Engine {
+	tokens := &verifier{identities: map[string]*models.Identity{
+		"alice-token": {Subject: "user-1", Roles: []string{"admin", "editor"}, Method: models.AuthMethodJWT},
+	}}
+	apiKeys := apikey.NewService(memory.NewRepository(), owners{"user-1": {"admin", "editor"}}, 24*time.Hour)
+
+	service := authentication.NewService(tokens, 0)
+	service.SetAPIKeyVerifier(apiKeys)
+	repo := memory.NewRepository()
+	sessions := session.NewService(repo, service, time.Hour)
+	return NewRouter(NewHandler(service, apiKeys, user.NewService(repo), sessions, revocation.NewService(repo, time.Hour), ratelimit.NewService(repo), impersonation.NewService(repo, repo, time.Minute), nil, nil, nil))
+}
+
+func request(router *gin.Engine, method string, target string, credential string, body any) *httptest.ResponseRecorder {
+	var payload bytes.Buffer
+	if body != nil {
+		json.NewEncoder(&payload).Encode(body)
+	}
+
+	req := httptest.NewRequest(method, target, &payload)
+	req.Header.Set("Content-Type", "application/json")
+	if credential != "" {
+		req.Header.Set("Authorization", "Bearer "+credential)
+	}
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAPIKeyLifecycle(t *testing.T) {
+	router := newAPIKeyRouter()
+
+	rec := request(router, http.MethodPost, "/api-keys", "alice-token", dto.CreateAPIKeyRequest{
+		Name:   "ci",
+		Scopes: []string{"tasks:read"},
+		Roles:  []string{"editor"},
+	})
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("POST /api-keys status = %d: %s", rec.Code, rec.Body)
+	}
+	var issued dto.IssuedAPIKey
+	if err := json.Unmarshal(rec.Body.Bytes(), &issued); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if bytes.Contains(rec.Body.Bytes(), []byte(`"hash"`)) || bytes.Contains(rec.Body.Bytes(), []byte(`"salt"`)) {
+		t.Errorf("POST /api-keys response exposes the stored hash: %s", rec.Body)
+	}
+
+	// The key works at the gateway with its roles and scopes, in either header
+	rec = validate(router, "/auth/validate?scope=tasks:read", map[string]string{"Authorization": "Bearer " + issued.Key})
+	if rec.Code != http.StatusOK || rec.Header().Get(UserIDHeader) != "user-1" || rec.Header().Get(UserRolesHeader) != "editor" || rec.Header().Get(ScopesHeader) != "tasks:read" {
+		t.Errorf("Validate() with API key = %d %v, want 200 as user-1 with role editor", rec.Code, rec.Header())
+	}
+	if rec = validate(router, "/auth/validate?scope=tasks:write", map[string]string{APIKeyHeader: issued.Key}); rec.Code != http.StatusForbidden {
+		t.Errorf("Validate() outside the key's scopes status = %d, want 403", rec.Code)
+	}
+
+	// Keys can't manage keys
+	if rec = request(router, http.MethodGet, "/api-keys", issued.Key, nil); rec.Code != http.StatusForbidden {
+		t.Errorf("GET /api-keys with an API key status = %d, want 403", rec.Code)
+	}
+
+	rec = request(router, http.MethodPost, "/api-keys/"+issued.APIKey.ID.String()+"/rotate", "alice-token", nil)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("POST /api-keys/:id/rotate status = %d: %s", rec.Code, rec.Body)
+	}
+	var rotated dto.IssuedAPIKey
+	json.Unmarshal(rec.Body.Bytes(), &rotated)
+	if rec = validate(router, "/auth/validate", map[string]string{APIKeyHeader: issued.Key}); rec.Code != http.StatusUnauthorized {
+		t.Errorf("Validate() with the rotated-out key status = %d, want 401", rec.Code)
+	}
+
+	if rec = request(router, http.MethodDelete, "/api-keys/"+issued.APIKey.ID.String(), "alice-token", nil); rec.Code != http.StatusNoContent {
+		t.Fatalf("DELETE /api-keys/:id status = %d: %s", rec.Code, rec.Body)
+	}
+	if rec = validate(router, "/auth/validate", map[string]string{APIKeyHeader: rotated.Key}); rec.Code != http.StatusUnauthorized {
+		t.Errorf("Validate() with a revoked key status = %d, want 401", rec.Code)
+	}
+
+	rec = request(router, http.MethodGet, "/api-keys", "alice-token", nil)
+	var list dto.APIKeyList
+	json.Unmarshal(rec.Body.Bytes(), &list)
+	if rec.Code != http.StatusOK || len(list.APIKeys) != 1 || list.APIKeys[0].RevokedAt == nil || list.APIKeys[0].LastUsedAt == nil {
+		t.Errorf("GET /api-keys = %d %s, want the revoked key with its last use", rec.Code, rec.Body)
+	}
+}
+
+func TestCreateAPIKeyErrors(t *testing.T) {
+	router := newAPIKeyRouter()
+
+	tests := []struct {
+		name       string
+		credential string
+		body       any
+		want       int
+	}{
+		{"unauthenticated", "", dto.CreateAPIKeyRequest{Name: "ci"}, http.StatusUnauthorized},
+		{"no name", "alice-token", dto.CreateAPIKeyRequest{}, http.StatusBadRequest},
+		{"malformed scope", "alice-token", dto.CreateAPIKeyRequest{Name: "ci", Scopes: []string{"all"}}, http.StatusBadRequest},
+		{"role not held", "alice-token", dto.CreateAPIKeyRequest{Name: "ci", Roles: []string{"superuser"}}, http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if rec := request(router, http.MethodPost, "/api-keys", tt.credential, tt.body); rec.Code != tt.want {
+				t.Errorf("POST /api-keys status = %d, want %d: %s", rec.Code, tt.want, rec.Body)
+			}
+		})
+	}
+
+	if rec := request(router, http.MethodDelete, "/api-keys/not-a-uuid", "alice-token", nil); rec.Code != http.StatusBadRequest {
+		t.Errorf("DELETE /api-keys/not-a-uuid status = %d, want 400", rec.Code)
+	}
+}
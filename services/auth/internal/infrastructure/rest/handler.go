@@ -0,0 +1,125 @@
+// Package rest serves the auth service's HTTP endpoints
+package rest
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/intellifinder/v4/services/auth/internal/domain/apikey"
+	"github.com/intellifinder/v4/services/auth/internal/domain/authentication"
+	"github.com/intellifinder/v4/services/auth/internal/domain/impersonation"
+	"github.com/intellifinder/v4/services/auth/internal/domain/ingestion"
+	"github.com/intellifinder/v4/services/auth/internal/domain/profile"
+	"github.com/intellifinder/v4/services/auth/internal/domain/ratelimit"
+	"github.com/intellifinder/v4/services/auth/internal/domain/revocation"
+	"github.com/intellifinder/v4/services/auth/internal/domain/scim"
+	"github.com/intellifinder/v4/services/auth/internal/domain/session"
+	"github.com/intellifinder/v4/services/auth/internal/domain/user"
+)
+
+type Handler struct {
+	auth        *authentication.Service
+	apiKeys     *apikey.Service
+	users       *user.Service
+	sessions    *session.Service
+	revocations *revocation.Service
+	limits      *ratelimit.Service
+
+	impersonations *impersonation.Service
+	ingestion      *ingestion.Service
+	provisioning   *scim.Service
+	profiles       *profile.Service
+}
+
+func NewHandler(auth *authentication.Service, apiKeys *apikey.Service, users *user.Service, sessions *session.Service, revocations *revocation.Service, limits *ratelimit.Service, impersonations *impersonation.Service, ingestion *ingestion.Service, provisioning *scim.Service, profiles *profile.Service) *Handler {
+	return &Handler{
+		auth:        auth,
+		apiKeys:     apiKeys,
+		users:       users,
+		sessions:    sessions,
+		revocations: revocations,
+		limits:      limits,
+
+		impersonations: impersonations,
+		ingestion:      ingestion,
+		provisioning:   provisioning,
+		profiles:       profiles,
+	}
+}
+
+// NewRouter returns the Gin engine serving every endpoint of the handler
+func NewRouter(h *Handler) *gin.Engine {
+	router := gin.New()
+	// Forwarded headers can be set by anyone, so until the gateways in front
+	// are trusted with SetTrustedProxies the client IP, which rate limits,
+	// lockouts and sessions are keyed by, is the address of the connection
+	_ = router.SetTrustedProxies(nil)
+	router.Use(gin.Recovery())
+
+	router.GET("/auth/validate", h.Validate)
+	router.POST("/auth/login", h.Login)
+	router.POST("/auth/refresh", h.Refresh)
+	router.POST("/auth/logout", h.Logout)
+	router.POST("/auth/revoke", h.RevokeToken)
+	router.POST("/auth/service-token", h.ServiceToken)
+	router.POST("/auth/forgot-password", h.ForgotPassword)
+
+	// Authenticated by the delivery's signature
+	router.POST("/webhooks/keycloak", h.KeycloakWebhook)
+
+	me := router.Group("/users/me", h.requireUser)
+	me.GET("", h.GetCurrentUser)
+	me.PATCH("", h.forbidImpersonation, h.UpdateCurrentUser)
+	me.GET("/profile", h.GetCurrentProfile)
+	me.PATCH("/profile", h.forbidImpersonation, h.UpdateCurrentProfile)
+	me.GET("/preferences/:client", h.GetCurrentPreferences)
+	me.PUT("/preferences/:client", h.forbidImpersonation, h.SetCurrentPreferences)
+	me.DELETE("/preferences/:client", h.forbidImpersonation, h.DeleteCurrentPreferences)
+
+	users := router.Group("/users", h.requireUser, h.forbidImpersonation, requireRole(AdminRole))
+	users.GET("", h.ListUsers)
+	users.POST("", h.CreateUser)
+	users.GET("/:id", h.GetUser)
+	users.PATCH("/:id", h.UpdateUser)
+	users.DELETE("/:id", h.DeleteUser)
+	users.POST("/:id/revoke-tokens", h.RevokeUserTokens)
+	users.POST("/:id/deactivate", h.DeactivateUser)
+	users.POST("/:id/activate", h.ActivateUser)
+	users.POST("/:id/reset-password", h.ResetUserPassword)
+	users.GET("/:id/profile", h.GetUserProfile)
+	users.GET("/:id/preferences/:client", h.GetUserPreferences)
+
+	sessions := router.Group("/sessions", h.requireUser)
+	sessions.GET("", h.ListSessions)
+	sessions.DELETE("", h.forbidImpersonation, h.RevokeAllSessions)
+	sessions.DELETE("/:id", h.forbidImpersonation, h.RevokeSession)
+
+	keys := router.Group("/api-keys", h.requireUser)
+	keys.POST("", h.forbidImpersonation, h.CreateAPIKey)
+	keys.GET("", h.ListAPIKeys)
+	keys.POST("/:id/rotate", h.forbidImpersonation, h.RotateAPIKey)
+	keys.DELETE("/:id", h.forbidImpersonation, h.RevokeAPIKey)
+
+	impersonations := router.Group("/impersonations", h.requireUser)
+	impersonations.POST("", h.forbidImpersonation, h.StartImpersonation)
+	impersonations.GET("", requireRole(AdminRole), h.ListImpersonations)
+	impersonations.GET("/:id/events", requireRole(AdminRole), h.ListImpersonationEvents)
+	impersonations.POST("/:id/end", h.EndImpersonation)
+
+	// SCIM 2.0 provisioning by the identity providers of tenants
+	provisioning := router.Group("/scim/v2", h.requireProvisioner)
+	provisioning.GET("/ServiceProviderConfig", h.GetServiceProviderConfig)
+	provisioning.GET("/ResourceTypes", h.ListResourceTypes)
+	provisioning.GET("/Users", h.ListSCIMUsers)
+	provisioning.POST("/Users", h.CreateSCIMUser)
+	provisioning.GET("/Users/:id", h.GetSCIMUser)
+	provisioning.PUT("/Users/:id", h.ReplaceSCIMUser)
+	provisioning.PATCH("/Users/:id", h.PatchSCIMUser)
+	provisioning.DELETE("/Users/:id", h.DeleteSCIMUser)
+	provisioning.GET("/Groups", h.ListSCIMGroups)
+	provisioning.POST("/Groups", h.CreateSCIMGroup)
+	provisioning.GET("/Groups/:id", h.GetSCIMGroup)
+	provisioning.PUT("/Groups/:id", h.ReplaceSCIMGroup)
+	provisioning.PATCH("/Groups/:id", h.PatchSCIMGroup)
+	provisioning.DELETE("/Groups/:id", h.DeleteSCIMGroup)
+
+	return router
+}
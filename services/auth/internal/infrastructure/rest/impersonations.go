@@ -0,0 +1,123 @@
+package rest
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/intellifinder/v4/libs/observability"
+	"github.com/intellifinder/v4/services/auth/internal/domain/impersonation"
+	"github.com/intellifinder/v4/services/auth/internal/domain/user"
+	"github.com/intellifinder/v4/services/auth/pkg/dto"
+	"go.uber.org/zap"
+)
+
+// StartImpersonation returns a short-lived access token acting as another
+// user. Callers need the impersonation permission in the user's tenant.
+func (h *Handler) StartImpersonation(c *gin.Context) {
+	var req dto.StartImpersonationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	started, token, err := h.impersonations.Start(c.Request.Context(), identity(c), uuid.MustParse(req.UserID), req.Reason)
+	if err != nil {
+		impersonationError(c, err)
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusCreated, dto.StartedImpersonation{
+		Impersonation: started,
+		AccessToken:   token,
+		TokenType:     "Bearer",
+		ExpiresIn:     int(started.ExpiresAt.Sub(started.StartedAt).Seconds()),
+	})
+}
+
+// EndImpersonation revokes the impersonation's token. Both its actor and the
+// token itself may end it.
+func (h *Handler) EndImpersonation(c *gin.Context) {
+	id, ok := parseID(c)
+	if !ok {
+		return
+	}
+
+	ended, err := h.impersonations.End(c.Request.Context(), identity(c), id)
+	if err != nil {
+		impersonationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, ended)
+}
+
+func (h *Handler) ListImpersonations(c *gin.Context) {
+	var query dto.ListImpersonationsQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	filter := dto.ImpersonationFilter{ActorID: query.ActorID, TargetID: query.TargetID, Limit: query.Limit}
+	impersonations, err := h.impersonations.ListImpersonations(c.Request.Context(), filter)
+	if err != nil {
+		impersonationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.ImpersonationList{Impersonations: impersonations})
+}
+
+// ListImpersonationEvents returns the audit trail of an impersonation
+func (h *Handler) ListImpersonationEvents(c *gin.Context) {
+	id, ok := parseID(c)
+	if !ok {
+		return
+	}
+
+	events, err := h.impersonations.ListEvents(c.Request.Context(), id)
+	if err != nil {
+		impersonationError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.ImpersonationEventList{Events: events})
+}
+
+// forbidImpersonation refuses sensitive actions, such as changing credentials
+// or managing users, to impersonation tokens and records the attempt
+func (h *Handler) forbidImpersonation(c *gin.Context) {
+	caller := identity(c)
+	if !caller.Impersonated() {
+		c.Next()
+		return
+	}
+
+	if err := h.impersonations.RecordRequest(c.Request.Context(), caller, c.Request.Method, c.Request.URL.Path, true); err != nil {
+		impersonationError(c, err)
+		return
+	}
+	c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "not allowed while impersonating"})
+}
+
+func impersonationError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, impersonation.ErrImpersonationNotFound):
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "impersonation not found"})
+	case errors.Is(err, user.ErrUserNotFound):
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "user not found"})
+	case errors.Is(err, impersonation.ErrInvalidImpersonation):
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	case errors.Is(err, impersonation.ErrNotPermitted), errors.Is(err, impersonation.ErrImpersonating):
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": err.Error()})
+	case errors.Is(err, impersonation.ErrUnavailable):
+		observability.Logger(c.Request.Context()).Warn("impersonation unavailable", zap.Error(err))
+		c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "impersonation temporarily unavailable"})
+	default:
+		observability.Logger(c.Request.Context()).Error("impersonation request failed", zap.Error(err))
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+	}
+}
@@ -0,0 +1,168 @@
+package rest
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/intellifinder/v4/libs/observability"
+	"github.com/intellifinder/v4/services/auth/internal/domain/authentication"
+	"github.com/intellifinder/v4/services/auth/internal/domain/ratelimit"
+	"github.com/intellifinder/v4/services/auth/internal/domain/revocation"
+	"github.com/intellifinder/v4/services/auth/internal/domain/session"
+	"github.com/intellifinder/v4/services/auth/pkg/dto"
+	"go.uber.org/zap"
+)
+
+// Login is limited per address and per account, and accounts whose password
+// keeps being wrong are locked out for progressively longer
+func (h *Handler) Login(c *gin.Context) {
+	var req dto.LoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	account := strings.ToLower(strings.TrimSpace(req.Username))
+	if !h.allow(c, ratelimit.EndpointLogin, ratelimit.DimensionIP, c.ClientIP()) ||
+		!h.allow(c, ratelimit.EndpointLogin, ratelimit.DimensionAccount, account) ||
+		!h.checkLockout(c, ratelimit.EndpointLogin, account) {
+		return
+	}
+
+	tokens, err := h.sessions.Login(c.Request.Context(), req.Username, req.Password, metadata(c))
+	if errors.Is(err, authentication.ErrInvalidCredentials) {
+		h.recordFailure(c, ratelimit.EndpointLogin, account)
+	}
+	if err != nil {
+		tokenError(c, err, "invalid username or password")
+		return
+	}
+	h.recordSuccess(c, ratelimit.EndpointLogin, account)
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, tokens)
+}
+
+func (h *Handler) Refresh(c *gin.Context) {
+	var req dto.RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !h.allow(c, ratelimit.EndpointRefresh, ratelimit.DimensionIP, c.ClientIP()) {
+		return
+	}
+
+	tokens, err := h.sessions.Refresh(c.Request.Context(), req.RefreshToken, metadata(c))
+	if err != nil {
+		tokenError(c, err, "invalid or expired refresh token")
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, tokens)
+}
+
+// ServiceToken exchanges a service's client credentials for an access token
+// to authenticate its calls to other services. It takes the same form body as
+// the identity provider's token endpoint, so service clients can use either.
+func (h *Handler) ServiceToken(c *gin.Context) {
+	var req dto.ServiceTokenRequest
+	if err := c.ShouldBind(&req); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if !h.allow(c, ratelimit.EndpointServiceToken, ratelimit.DimensionIP, c.ClientIP()) ||
+		!h.allow(c, ratelimit.EndpointServiceToken, ratelimit.DimensionAccount, req.ClientID) ||
+		!h.checkLockout(c, ratelimit.EndpointServiceToken, req.ClientID) {
+		return
+	}
+
+	tokens, err := h.auth.LoginService(c.Request.Context(), req.ClientID, req.ClientSecret)
+	if errors.Is(err, authentication.ErrInvalidCredentials) {
+		h.recordFailure(c, ratelimit.EndpointServiceToken, req.ClientID)
+	}
+	if err != nil {
+		tokenError(c, err, "invalid client credentials")
+		return
+	}
+	h.recordSuccess(c, ratelimit.EndpointServiceToken, req.ClientID)
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, tokens)
+}
+
+// Logout succeeds for refresh tokens that are already invalid, like token
+// revocation in RFC 7009, since their session is over either way
+func (h *Handler) Logout(c *gin.Context) {
+	var req dto.RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	err := h.sessions.Logout(c.Request.Context(), req.RefreshToken)
+	if err != nil && !errors.Is(err, authentication.ErrInvalidCredentials) {
+		tokenError(c, err, "")
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// RevokeToken revokes an access token at once. Like token revocation in
+// RFC 7009 it succeeds for tokens that are already invalid.
+func (h *Handler) RevokeToken(c *gin.Context) {
+	var req dto.RevokeTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	identity, err := h.auth.AuthenticateToken(c.Request.Context(), req.Token)
+	if errors.Is(err, authentication.ErrInvalidCredentials) || errors.Is(err, authentication.ErrCredentialsExpired) {
+		c.Status(http.StatusNoContent)
+		return
+	}
+	if err != nil {
+		tokenError(c, err, "")
+		return
+	}
+
+	err = h.revocations.RevokeToken(c.Request.Context(), identity)
+	switch {
+	case errors.Is(err, revocation.ErrInvalidRevocation):
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	case err != nil:
+		observability.Logger(c.Request.Context()).Error("failed to revoke token", zap.Error(err))
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// metadata describes the client of a login or refresh request
+func metadata(c *gin.Context) session.Metadata {
+	return session.Metadata{Device: c.Request.UserAgent(), IPAddress: c.ClientIP()}
+}
+
+// tokenError answers rejected credentials with message, which shouldn't tell
+// which part of them was wrong
+func tokenError(c *gin.Context, err error, message string) {
+	switch {
+	case errors.Is(err, authentication.ErrInvalidCredentials):
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": message})
+	case errors.Is(err, authentication.ErrUnavailable):
+		observability.Logger(c.Request.Context()).Warn("tokens could not be issued", zap.Error(err))
+		c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "authentication temporarily unavailable"})
+	default:
+		observability.Logger(c.Request.Context()).Error("token request failed", zap.Error(err))
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+	}
+}
@@ -0,0 +1,54 @@
+package rest
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/intellifinder/v4/services/auth/pkg/models"
+)
+
+const identityKey = "identity"
+
+// AdminRole is the realm role required to manage users
+const AdminRole = "admin"
+
+// requireUser authenticates the request and stores the caller's identity in
+// the context. Only access tokens are accepted, so a leaked API key can't be
+// used to manage keys. Requests made while impersonating are added to the
+// impersonation's audit trail before they are served.
+func (h *Handler) requireUser(c *gin.Context) {
+	identity, err := h.authenticate(c)
+	if err != nil {
+		h.authenticationError(c, err)
+		return
+	}
+
+	if identity.Method != models.AuthMethodJWT {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "this endpoint requires an access token"})
+		return
+	}
+
+	if err := h.impersonations.RecordRequest(c.Request.Context(), identity, c.Request.Method, c.Request.URL.Path, false); err != nil {
+		impersonationError(c, err)
+		return
+	}
+
+	c.Set(identityKey, identity)
+	c.Next()
+}
+
+// identity returns the caller stored by requireUser
+func identity(c *gin.Context) *models.Identity {
+	return c.MustGet(identityKey).(*models.Identity)
+}
+
+// requireRole rejects callers stored by requireUser that lack the realm role
+func requireRole(role string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !identity(c).HasRole(role) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "missing required role " + role})
+			return
+		}
+		c.Next()
+	}
+}
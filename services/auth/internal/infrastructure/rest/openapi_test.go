@@ -0,0 +1,654 @@
+package rest
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/gorillamux"
+	"github.com/gin-gonic/gin"
+	"github.com/intellifinder/v4/services/auth/internal/domain/apikey"
+	"github.com/intellifinder/v4/services/auth/internal/domain/authentication"
+	"github.com/intellifinder/v4/services/auth/internal/domain/impersonation"
+	"github.com/intellifinder/v4/services/auth/internal/domain/ingestion"
+	"github.com/intellifinder/v4/services/auth/internal/domain/profile"
+	"github.com/intellifinder/v4/services/auth/internal/domain/ratelimit"
+	"github.com/intellifinder/v4/services/auth/internal/domain/revocation"
+	"github.com/intellifinder/v4/services/auth/internal/domain/scim"
+	"github.com/intellifinder/v4/services/auth/internal/domain/session"
+	"github.com/intellifinder/v4/services/auth/internal/domain/user"
+	"github.com/intellifinder/v4/services/auth/internal/infrastructure/keycloak"
+	"github.com/intellifinder/v4/services/auth/internal/infrastructure/memory"
+	"github.com/intellifinder/v4/services/auth/pkg/dto"
+	"github.com/intellifinder/v4/services/auth/pkg/models"
+)
+
+const specPath = "../../../api/v1/openapi.yml"
+
+// specServer is the server in the spec that test requests are addressed to
+const specServer = "http://localhost:8080"
+
+// SCIM responses are JSON under their own media type
+func init() {
+	openapi3filter.RegisterBodyDecoder(scimContentType, openapi3filter.JSONBodyDecoder)
+}
+
+func loadSpec(t *testing.T) *openapi3.T {
+	t.Helper()
+
+	loader := openapi3.NewLoader()
+	doc, err := loader.LoadFromFile(specPath)
+	if err != nil {
+		t.Fatalf("failed to load OpenAPI spec: %v", err)
+	}
+	if err := doc.Validate(loader.Context); err != nil {
+		t.Fatalf("OpenAPI spec is invalid: %v", err)
+	}
+	return doc
+}
+
+// issuer logs alice and the tasks service in and accepts the refresh tokens
+// it issued
+type issuer struct{}
+
+func (issuer) IssueTokens(_ context.Context, username string, password string) (*models.TokenSet, error) {
+	if username != "alice" || password != "secret" {
+		return nil, authentication.ErrInvalidCredentials
+	}
+	return &models.TokenSet{AccessToken: "alice-session-token", RefreshToken: "refresh-token", TokenType: "Bearer", ExpiresIn: 300, RefreshExpiresIn: 1800}, nil
+}
+
+func (i issuer) RefreshTokens(ctx context.Context, refreshToken string) (*models.TokenSet, error) {
+	if refreshToken != "refresh-token" {
+		return nil, authentication.ErrInvalidCredentials
+	}
+	return i.IssueTokens(ctx, "alice", "secret")
+}
+
+func (issuer) IssueServiceTokens(_ context.Context, clientID string, clientSecret string) (*models.TokenSet, error) {
+	if clientID != "tasks-service" || clientSecret != "tasks-secret" {
+		return nil, authentication.ErrInvalidCredentials
+	}
+	return &models.TokenSet{AccessToken: "tasks-service-token", TokenType: "Bearer", ExpiresIn: 300}, nil
+}
+
+func (issuer) RevokeTokens(_ context.Context, refreshToken string) error {
+	if refreshToken != "refresh-token" {
+		return authentication.ErrInvalidCredentials
+	}
+	return nil
+}
+
+// directory keeps Keycloak users by OIDC ID
+type directory struct {
+	users map[string]models.User
+}
+
+func (d *directory) ListUsers(_ context.Context, _ int, _ int) ([]*models.User, error) {
+	return nil, nil
+}
+
+func (d *directory) GetUser(_ context.Context, oidcID string) (*models.User, error) {
+	u, ok := d.users[oidcID]
+	if !ok {
+		return nil, user.ErrUserNotFound
+	}
+	return &u, nil
+}
+
+func (d *directory) CreateUser(_ context.Context, u *models.User, _ []string) (*models.User, error) {
+	for _, existing := range d.users {
+		if existing.Username == u.Username || existing.Email == u.Email {
+			return nil, user.ErrUserExists
+		}
+	}
+	created := *u
+	created.OIDCID = fmt.Sprintf("kc-%d", len(d.users)+1)
+	d.users[created.OIDCID] = created
+	return &created, nil
+}
+
+func (d *directory) UpdateUser(_ context.Context, u *models.User) (*models.User, error) {
+	if _, ok := d.users[u.OIDCID]; !ok {
+		return nil, user.ErrUserNotFound
+	}
+	d.users[u.OIDCID] = *u
+	return u, nil
+}
+
+func (d *directory) DeleteUser(_ context.Context, oidcID string) error {
+	delete(d.users, oidcID)
+	return nil
+}
+
+func (d *directory) SendPasswordReset(_ context.Context, oidcID string) error {
+	if _, ok := d.users[oidcID]; !ok {
+		return user.ErrUserNotFound
+	}
+	return nil
+}
+
+// GetUserAccess puts every user in tenant-1; root is an admin
+func (d *directory) GetUserAccess(_ context.Context, oidcID string) ([]string, string, error) {
+	if _, ok := d.users[oidcID]; !ok {
+		return nil, "", user.ErrUserNotFound
+	}
+	if oidcID == "kc-root" {
+		return []string{AdminRole}, "tenant-1", nil
+	}
+	return []string{"user"}, "tenant-1", nil
+}
+
+// GetOwnerRoles gives API key owners the roles of GetUserAccess while they are enabled
+func (d *directory) GetOwnerRoles(ctx context.Context, oidcID string) ([]string, error) {
+	if u, ok := d.users[oidcID]; !ok || !u.Enabled {
+		return nil, apikey.ErrOwnerInactive
+	}
+	roles, _, err := d.GetUserAccess(ctx, oidcID)
+	return roles, err
+}
+
+// tenantDirectory creates provisioned users in the directory and keeps
+// groups' names by ID
+type tenantDirectory struct {
+	*directory
+	groups map[string]string
+}
+
+// CreateUser names users' OIDC IDs after them, so they aren't reused once deleted
+func (d *tenantDirectory) CreateUser(_ context.Context, u *models.User, _ string) (*models.User, error) {
+	for _, existing := range d.users {
+		if existing.Username == u.Username || existing.Email == u.Email {
+			return nil, user.ErrUserExists
+		}
+	}
+	created := *u
+	created.OIDCID = "kc-" + u.Username
+	d.users[created.OIDCID] = created
+	return &created, nil
+}
+
+func (d *tenantDirectory) CreateGroup(_ context.Context, tenantID string, name string) (string, error) {
+	for _, existing := range d.groups {
+		if existing == tenantID+"/"+name {
+			return "", scim.ErrUniqueness
+		}
+	}
+	id := fmt.Sprintf("group-%d", len(d.groups)+1)
+	d.groups[id] = tenantID + "/" + name
+	return id, nil
+}
+
+func (d *tenantDirectory) RenameGroup(_ context.Context, id string, name string) error {
+	tenantID, _, _ := strings.Cut(d.groups[id], "/")
+	d.groups[id] = tenantID + "/" + name
+	return nil
+}
+
+func (d *tenantDirectory) DeleteGroup(_ context.Context, id string) error {
+	delete(d.groups, id)
+	return nil
+}
+
+func (d *tenantDirectory) AddGroupMember(_ context.Context, _ string, _ string) error {
+	return nil
+}
+
+func (d *tenantDirectory) RemoveGroupMember(_ context.Context, _ string, _ string) error {
+	return nil
+}
+
+// impersonators lets the subjects in it impersonate
+type impersonators []string
+
+func (i impersonators) HasPermission(_ context.Context, subject string, _ string, permission string) (bool, error) {
+	return permission == impersonation.Permission && slices.Contains(i, subject), nil
+}
+
+// newContractRouter serves every endpoint with in-memory services. alice is
+// a regular user, root an admin who may impersonate; both exist in the
+// directory and are provisioned locally on their first request. root's
+// tenant token carries tenant-1, so its API keys can provision that tenant.
+func newContractRouter(t *testing.T) (*gin.Engine, *user.Service) {
+	t.Helper()
+
+	tokens := &verifier{identities: map[string]*models.Identity{
+		"alice-token":         {Subject: "kc-alice", Username: "alice", Email: "alice@example.com", Roles: []string{"user"}, Method: models.AuthMethodJWT, ExpiresAt: time.Now().Add(time.Hour)},
+		"alice-session-token": {Subject: "kc-alice", Username: "alice", Email: "alice@example.com", Roles: []string{"user"}, SessionID: "sid-alice", Method: models.AuthMethodJWT, ExpiresAt: time.Now().Add(time.Hour)},
+		"alice-jti-token":     {Subject: "kc-alice", Username: "alice", Email: "alice@example.com", Roles: []string{"user"}, TokenID: "jti-alice", Method: models.AuthMethodJWT, ExpiresAt: time.Now().Add(time.Hour)},
+		"root-token":          {Subject: "kc-root", Username: "root", Email: "root@example.com", Roles: []string{AdminRole}, Method: models.AuthMethodJWT, ExpiresAt: time.Now().Add(time.Hour)},
+		"root-tenant-token":   {Subject: "kc-root", Username: "root", Email: "root@example.com", Roles: []string{AdminRole}, TenantID: "tenant-1", Method: models.AuthMethodJWT, ExpiresAt: time.Now().Add(time.Hour)},
+	}}
+
+	repo := memory.NewRepository()
+	users := user.NewService(repo)
+	idp := &directory{users: map[string]models.User{
+		"kc-alice": {OIDCID: "kc-alice", Username: "alice", Email: "alice@example.com", Enabled: true},
+		"kc-root":  {OIDCID: "kc-root", Username: "root", Email: "root@example.com", Enabled: true},
+	}}
+	users.SetDirectory(idp)
+
+	apiKeys := apikey.NewService(repo, idp, 24*time.Hour)
+	auth := authentication.NewService(tokens, 0)
+	auth.SetAPIKeyVerifier(apiKeys)
+	auth.SetUserProvisioner(users, func(err error) { t.Errorf("failed to provision user: %v", err) })
+	auth.SetTokenIssuer(issuer{})
+
+	revocations := revocation.NewService(repo, time.Hour)
+	auth.SetRevocationChecker(revocations)
+	users.SetRevoker(revocations)
+
+	sessions := session.NewService(repo, auth, 24*time.Hour)
+	sessions.SetRevoker(revocations)
+	users.SetSessionRevoker(sessions)
+
+	impersonations := impersonation.NewService(repo, repo, 15*time.Minute)
+	impersonations.SetSigningKey(ed25519.NewKeyFromSeed(make([]byte, ed25519.SeedSize)))
+	impersonations.SetDirectory(idp)
+	impersonations.SetPermissionChecker(impersonators{"kc-root"})
+	impersonations.SetRevoker(revocations)
+	auth.SetImpersonationVerifier(impersonations)
+
+	events := ingestion.NewService(repo, users, sessions, revocations, repo)
+	events.SetWebhook(keycloak.NewEventSource(nil), []byte(webhookSecret))
+
+	provisioning := scim.NewService(repo, users, &tenantDirectory{directory: idp, groups: make(map[string]string)})
+
+	profiles := profile.NewService(repo, users)
+	if err := profiles.RegisterSchema("task-web", []byte(`{"type": "object", "properties": {"theme": {"enum": ["light", "dark"]}}}`)); err != nil {
+		t.Fatalf("RegisterSchema() error = %v", err)
+	}
+
+	return NewRouter(NewHandler(auth, apiKeys, users, sessions, revocations, ratelimit.NewService(repo), impersonations, events, provisioning, profiles)), users
+}
+
+// webhookSecret signs the Keycloak webhook deliveries of the contract router
+const webhookSecret = "webhook-secret"
+
+// signWebhook signs a delivery of the body as checkContract sends it
+func signWebhook(body any) map[string]string {
+	payload, _ := json.Marshal(body)
+	mac := hmac.New(sha256.New, []byte(webhookSecret))
+	mac.Write(payload)
+	return map[string]string{KeycloakSignatureHeader: ingestion.SignaturePrefix + hex.EncodeToString(mac.Sum(nil))}
+}
+
+// ginPathParam matches the path parameters of Gin routes, e.g. :id
+var ginPathParam = regexp.MustCompile(`:(\w+)`)
+
+func TestRoutesMatchSpec(t *testing.T) {
+	doc := loadSpec(t)
+	router, _ := newContractRouter(t)
+
+	var served []string
+	for _, route := range router.Routes() {
+		served = append(served, route.Method+" "+ginPathParam.ReplaceAllString(route.Path, "{$1}"))
+	}
+
+	var documented []string
+	for path, item := range doc.Paths.Map() {
+		for method := range item.Operations() {
+			documented = append(documented, method+" "+path)
+		}
+	}
+
+	for _, route := range served {
+		if !slices.Contains(documented, route) {
+			t.Errorf("%s is served but not documented", route)
+		}
+	}
+	for _, route := range documented {
+		if !slices.Contains(served, route) {
+			t.Errorf("%s is documented but not served", route)
+		}
+	}
+}
+
+// contractCase is a request whose response must have the wanted status and
+// match the spec. Requests of successful cases must match the spec too.
+type contractCase struct {
+	name       string
+	method     string
+	path       string
+	credential string
+	headers    map[string]string
+	body       any
+	want       int
+}
+
+func TestHandlersConformToSpec(t *testing.T) {
+	doc := loadSpec(t)
+	specRouter, err := gorillamux.NewRouter(doc)
+	if err != nil {
+		t.Fatalf("failed to route the spec: %v", err)
+	}
+
+	router, users := newContractRouter(t)
+	run := func(tt contractCase) *httptest.ResponseRecorder {
+		t.Helper()
+		return checkContract(t, specRouter, router, tt)
+	}
+
+	// Provision both users, log alice in and create a key to refer to
+	run(contractCase{name: "get profile", method: http.MethodGet, path: "/users/me", credential: "alice-token", want: http.StatusOK})
+	run(contractCase{name: "admin profile", method: http.MethodGet, path: "/users/me", credential: "root-token", want: http.StatusOK})
+	alice, err := users.GetUserByOIDCID(context.Background(), "kc-alice")
+	if err != nil {
+		t.Fatalf("GetUserByOIDCID() error = %v", err)
+	}
+
+	rec := run(contractCase{name: "login", method: http.MethodPost, path: "/auth/login", body: dto.LoginRequest{Username: "alice", Password: "secret"}, want: http.StatusOK})
+	var login models.TokenSet
+	json.Unmarshal(rec.Body.Bytes(), &login)
+
+	rec = run(contractCase{name: "create API key", method: http.MethodPost, path: "/api-keys", credential: "alice-token", body: dto.CreateAPIKeyRequest{Name: "ci", Scopes: []string{"tasks:read"}}, want: http.StatusCreated})
+	var issued dto.IssuedAPIKey
+	json.Unmarshal(rec.Body.Bytes(), &issued)
+	keyPath := "/api-keys/" + issued.APIKey.ID.String()
+	userPath := "/users/" + alice.ID.String()
+	unknownUserPath := "/users/00000000-0000-0000-0000-000000000001"
+
+	rec = run(contractCase{name: "start impersonation", method: http.MethodPost, path: "/impersonations", credential: "root-token", body: dto.StartImpersonationRequest{UserID: alice.ID.String(), Reason: "ticket 42"}, want: http.StatusCreated})
+	var started dto.StartedImpersonation
+	json.Unmarshal(rec.Body.Bytes(), &started)
+	impersonationPath := "/impersonations/" + started.Impersonation.ID.String()
+	root, err := users.GetUserByOIDCID(context.Background(), "kc-root")
+	if err != nil {
+		t.Fatalf("GetUserByOIDCID() error = %v", err)
+	}
+
+	// root provisions tenant-1 with a key restricted to SCIM
+	rec = run(contractCase{name: "create provisioning key", method: http.MethodPost, path: "/api-keys", credential: "root-tenant-token", body: dto.CreateAPIKeyRequest{Name: "okta", Scopes: []string{ProvisioningScope}}, want: http.StatusCreated})
+	var provisioner dto.IssuedAPIKey
+	json.Unmarshal(rec.Body.Bytes(), &provisioner)
+
+	rec = run(contractCase{name: "provision user", method: http.MethodPost, path: "/scim/v2/Users", credential: provisioner.Key, body: dto.SCIMUser{
+		Schemas:    []string{dto.SCIMUserSchema},
+		ExternalID: "00u1",
+		UserName:   "carol@example.com",
+		Name:       &dto.SCIMName{GivenName: "Carol", FamilyName: "Jones"},
+		Emails:     []dto.SCIMMultiValue{{Value: "carol@example.com", Type: "work", Primary: true}},
+	}, want: http.StatusCreated})
+	var carol dto.SCIMUser
+	json.Unmarshal(rec.Body.Bytes(), &carol)
+	scimUserPath := "/scim/v2/Users/" + carol.ID
+	members := []dto.SCIMMultiValue{{Value: carol.ID}}
+
+	rec = run(contractCase{name: "provision group", method: http.MethodPost, path: "/scim/v2/Groups", credential: provisioner.Key, body: dto.SCIMGroup{Schemas: []string{dto.SCIMGroupSchema}, DisplayName: "Engineering", Members: members}, want: http.StatusCreated})
+	var engineering dto.SCIMGroup
+	json.Unmarshal(rec.Body.Bytes(), &engineering)
+	scimGroupPath := "/scim/v2/Groups/" + engineering.ID
+
+	// Joining the group changed carol's version
+	rec = run(contractCase{name: "get SCIM user", method: http.MethodGet, path: scimUserPath, credential: provisioner.Key, want: http.StatusOK})
+	version := rec.Header().Get("ETag")
+
+	patch := func(op string, path string, value string) dto.SCIMPatchRequest {
+		operation := dto.SCIMPatchOperation{Op: op, Path: path}
+		if value != "" {
+			operation.Value = json.RawMessage(value)
+		}
+		return dto.SCIMPatchRequest{Schemas: []string{dto.SCIMPatchOpSchema}, Operations: []dto.SCIMPatchOperation{operation}}
+	}
+
+	name := "Alice"
+	email := "alice@corp.example.com"
+	invalidEmail := "not-an-email"
+	displayName, locale, timeZone, unknownTimeZone := "Ally", "en-GB", "Europe/London", "Mars/Olympus_Mons"
+	rootChanged := []keycloak.KeycloakEvent{{Time: time.Now().UnixMilli(), OperationType: "UPDATE", ResourceType: "USER", ResourcePath: "users/kc-root"}}
+
+	tests := []contractCase{
+		{name: "validate token", method: http.MethodGet, path: "/auth/validate?role=user", credential: "alice-token", want: http.StatusOK},
+		{name: "validate API key", method: http.MethodGet, path: "/auth/validate?scope=tasks:read", credential: issued.Key, want: http.StatusOK},
+		{name: "validate without credentials", method: http.MethodGet, path: "/auth/validate", want: http.StatusUnauthorized},
+		{name: "validate missing role", method: http.MethodGet, path: "/auth/validate?role=admin", credential: "alice-token", want: http.StatusForbidden},
+
+		{name: "login wrong password", method: http.MethodPost, path: "/auth/login", body: dto.LoginRequest{Username: "alice", Password: "wrong"}, want: http.StatusUnauthorized},
+		{name: "login without password", method: http.MethodPost, path: "/auth/login", body: map[string]string{"username": "alice"}, want: http.StatusBadRequest},
+		{name: "refresh", method: http.MethodPost, path: "/auth/refresh", body: dto.RefreshRequest{RefreshToken: login.RefreshToken}, want: http.StatusOK},
+		{name: "refresh expired", method: http.MethodPost, path: "/auth/refresh", body: dto.RefreshRequest{RefreshToken: "expired"}, want: http.StatusUnauthorized},
+
+		{name: "list sessions", method: http.MethodGet, path: "/sessions", credential: "alice-session-token", want: http.StatusOK},
+		{name: "list sessions without credentials", method: http.MethodGet, path: "/sessions", want: http.StatusUnauthorized},
+		{name: "revoke another user's session", method: http.MethodDelete, path: "/sessions/sid-alice", credential: "root-token", want: http.StatusNotFound},
+		{name: "revoke session", method: http.MethodDelete, path: "/sessions/sid-alice", credential: "alice-token", want: http.StatusNoContent},
+		{name: "validate token of revoked session", method: http.MethodGet, path: "/auth/validate", credential: "alice-session-token", want: http.StatusUnauthorized},
+		{name: "revoke revoked session", method: http.MethodDelete, path: "/sessions/sid-alice", credential: "alice-token", want: http.StatusNotFound},
+		{name: "revoke all sessions", method: http.MethodDelete, path: "/sessions", credential: "alice-token", want: http.StatusNoContent},
+		{name: "refresh revoked session", method: http.MethodPost, path: "/auth/refresh", body: dto.RefreshRequest{RefreshToken: login.RefreshToken}, want: http.StatusUnauthorized},
+
+		{name: "logout", method: http.MethodPost, path: "/auth/logout", body: dto.RefreshRequest{RefreshToken: login.RefreshToken}, want: http.StatusNoContent},
+		{name: "logout expired", method: http.MethodPost, path: "/auth/logout", body: dto.RefreshRequest{RefreshToken: "expired"}, want: http.StatusNoContent},
+		{name: "logout without token", method: http.MethodPost, path: "/auth/logout", body: map[string]string{}, want: http.StatusBadRequest},
+
+		{name: "revoke token", method: http.MethodPost, path: "/auth/revoke", body: dto.RevokeTokenRequest{Token: "alice-jti-token"}, want: http.StatusNoContent},
+		{name: "validate revoked token", method: http.MethodGet, path: "/auth/validate", credential: "alice-jti-token", want: http.StatusUnauthorized},
+		{name: "revoke revoked token", method: http.MethodPost, path: "/auth/revoke", body: dto.RevokeTokenRequest{Token: "alice-jti-token"}, want: http.StatusNoContent},
+		{name: "revoke token without ID", method: http.MethodPost, path: "/auth/revoke", body: dto.RevokeTokenRequest{Token: "root-token"}, want: http.StatusBadRequest},
+		{name: "revoke without token", method: http.MethodPost, path: "/auth/revoke", body: map[string]string{}, want: http.StatusBadRequest},
+
+		{name: "service token", method: http.MethodPost, path: "/auth/service-token", body: url.Values{"grant_type": {"client_credentials"}, "client_id": {"tasks-service"}, "client_secret": {"tasks-secret"}}, want: http.StatusOK},
+		{name: "service token from JSON", method: http.MethodPost, path: "/auth/service-token", body: dto.ServiceTokenRequest{ClientID: "tasks-service", ClientSecret: "tasks-secret"}, want: http.StatusOK},
+		{name: "service token wrong secret", method: http.MethodPost, path: "/auth/service-token", body: url.Values{"client_id": {"tasks-service"}, "client_secret": {"wrong"}}, want: http.StatusUnauthorized},
+		{name: "service token other grant", method: http.MethodPost, path: "/auth/service-token", body: url.Values{"grant_type": {"password"}, "client_id": {"tasks-service"}, "client_secret": {"tasks-secret"}}, want: http.StatusBadRequest},
+
+		{name: "forgot password", method: http.MethodPost, path: "/auth/forgot-password", body: dto.ForgotPasswordRequest{Login: "nobody@example.com"}, want: http.StatusAccepted},
+		{name: "forgot password without login", method: http.MethodPost, path: "/auth/forgot-password", body: map[string]string{}, want: http.StatusBadRequest},
+
+		{name: "validate impersonation", method: http.MethodGet, path: "/auth/validate?role=user", credential: started.AccessToken, want: http.StatusOK},
+		{name: "validate sensitive route while impersonating", method: http.MethodGet, path: "/auth/validate?sensitive=true", credential: started.AccessToken, want: http.StatusForbidden},
+		{name: "profile while impersonating", method: http.MethodGet, path: "/users/me", credential: started.AccessToken, want: http.StatusOK},
+		{name: "update profile while impersonating", method: http.MethodPatch, path: "/users/me", credential: started.AccessToken, body: dto.UpdateProfileRequest{FirstName: &name}, want: http.StatusForbidden},
+		{name: "local profile while impersonating", method: http.MethodGet, path: "/users/me/profile", credential: started.AccessToken, want: http.StatusOK},
+		{name: "set preferences while impersonating", method: http.MethodPut, path: "/users/me/preferences/task-web", credential: started.AccessToken, body: map[string]string{"theme": "dark"}, want: http.StatusForbidden},
+		{name: "create API key while impersonating", method: http.MethodPost, path: "/api-keys", credential: started.AccessToken, body: dto.CreateAPIKeyRequest{Name: "ci"}, want: http.StatusForbidden},
+		{name: "impersonate while impersonating", method: http.MethodPost, path: "/impersonations", credential: started.AccessToken, body: dto.StartImpersonationRequest{UserID: root.ID.String(), Reason: "escalate"}, want: http.StatusForbidden},
+		{name: "impersonate without permission", method: http.MethodPost, path: "/impersonations", credential: "alice-token", body: dto.StartImpersonationRequest{UserID: root.ID.String(), Reason: "escalate"}, want: http.StatusForbidden},
+		{name: "impersonate yourself", method: http.MethodPost, path: "/impersonations", credential: "root-token", body: dto.StartImpersonationRequest{UserID: root.ID.String(), Reason: "ticket 42"}, want: http.StatusBadRequest},
+		{name: "impersonate unknown user", method: http.MethodPost, path: "/impersonations", credential: "root-token", body: dto.StartImpersonationRequest{UserID: "00000000-0000-0000-0000-000000000001", Reason: "ticket 42"}, want: http.StatusNotFound},
+		{name: "impersonate without reason", method: http.MethodPost, path: "/impersonations", credential: "root-token", body: map[string]string{"user_id": alice.ID.String()}, want: http.StatusBadRequest},
+		{name: "list impersonations", method: http.MethodGet, path: "/impersonations?actor_id=kc-root", credential: "root-token", want: http.StatusOK},
+		{name: "list impersonations as non-admin", method: http.MethodGet, path: "/impersonations", credential: "alice-token", want: http.StatusForbidden},
+		{name: "end another actor's impersonation", method: http.MethodPost, path: impersonationPath + "/end", credential: "alice-token", want: http.StatusNotFound},
+		{name: "end impersonation", method: http.MethodPost, path: impersonationPath + "/end", credential: started.AccessToken, want: http.StatusOK},
+		{name: "validate ended impersonation", method: http.MethodGet, path: "/auth/validate", credential: started.AccessToken, want: http.StatusUnauthorized},
+		{name: "end ended impersonation", method: http.MethodPost, path: impersonationPath + "/end", credential: "root-token", want: http.StatusOK},
+		{name: "impersonation events", method: http.MethodGet, path: impersonationPath + "/events", credential: "root-token", want: http.StatusOK},
+		{name: "events of unknown impersonation", method: http.MethodGet, path: "/impersonations/00000000-0000-0000-0000-000000000001/events", credential: "root-token", want: http.StatusNotFound},
+
+		{name: "keycloak webhook", method: http.MethodPost, path: "/webhooks/keycloak", headers: signWebhook(rootChanged), body: rootChanged, want: http.StatusNoContent},
+		{name: "keycloak webhook with one event", method: http.MethodPost, path: "/webhooks/keycloak", headers: signWebhook(rootChanged[0]), body: rootChanged[0], want: http.StatusNoContent},
+		{name: "keycloak webhook with invalid signature", method: http.MethodPost, path: "/webhooks/keycloak", headers: signWebhook("other"), body: rootChanged, want: http.StatusUnauthorized},
+		{name: "keycloak webhook without signature", method: http.MethodPost, path: "/webhooks/keycloak", body: rootChanged, want: http.StatusUnauthorized},
+		{name: "keycloak webhook with invalid events", method: http.MethodPost, path: "/webhooks/keycloak", headers: signWebhook(map[string]string{}), body: map[string]string{}, want: http.StatusBadRequest},
+
+		{name: "SCIM service provider config", method: http.MethodGet, path: "/scim/v2/ServiceProviderConfig", credential: provisioner.Key, want: http.StatusOK},
+		{name: "SCIM resource types", method: http.MethodGet, path: "/scim/v2/ResourceTypes", credential: provisioner.Key, want: http.StatusOK},
+		{name: "SCIM without credentials", method: http.MethodGet, path: "/scim/v2/Users", want: http.StatusUnauthorized},
+		{name: "SCIM with access token", method: http.MethodGet, path: "/scim/v2/Users", credential: "root-tenant-token", want: http.StatusUnauthorized},
+		{name: "SCIM with unscoped API key", method: http.MethodGet, path: "/scim/v2/Users", credential: issued.Key, want: http.StatusForbidden},
+		{name: "list SCIM users", method: http.MethodGet, path: "/scim/v2/Users?attributes=userName,emails&filter=" + url.QueryEscape(`userName eq "Carol@example.com"`), credential: provisioner.Key, want: http.StatusOK},
+		{name: "list SCIM users with invalid filter", method: http.MethodGet, path: "/scim/v2/Users?filter=" + url.QueryEscape(`userName is "carol"`), credential: provisioner.Key, want: http.StatusBadRequest},
+		{name: "get unchanged SCIM user", method: http.MethodGet, path: scimUserPath, credential: provisioner.Key, headers: map[string]string{"If-None-Match": version}, want: http.StatusNotModified},
+		{name: "get unknown SCIM user", method: http.MethodGet, path: "/scim/v2/Users/00000000-0000-0000-0000-000000000001", credential: provisioner.Key, want: http.StatusNotFound},
+		{name: "get SCIM user that wasn't provisioned", method: http.MethodGet, path: "/scim/v2/Users/" + alice.ID.String(), credential: provisioner.Key, want: http.StatusNotFound},
+		{name: "provision existing user", method: http.MethodPost, path: "/scim/v2/Users", credential: provisioner.Key, body: dto.SCIMUser{Schemas: []string{dto.SCIMUserSchema}, UserName: "carol@example.com"}, want: http.StatusConflict},
+		{name: "provision user without userName", method: http.MethodPost, path: "/scim/v2/Users", credential: provisioner.Key, body: dto.SCIMUser{Schemas: []string{dto.SCIMUserSchema}}, want: http.StatusBadRequest},
+		{name: "deactivate SCIM user", method: http.MethodPatch, path: scimUserPath, credential: provisioner.Key, body: patch("Replace", "active", `false`), want: http.StatusOK},
+		{name: "patch SCIM user with stale version", method: http.MethodPatch, path: scimUserPath, credential: provisioner.Key, headers: map[string]string{"If-Match": carol.Meta.Version}, body: patch("replace", "name.givenName", `"Caroline"`), want: http.StatusPreconditionFailed},
+		{name: "patch SCIM user with invalid path", method: http.MethodPatch, path: scimUserPath, credential: provisioner.Key, body: patch("replace", `emails[type eq "work"`, `"x"`), want: http.StatusBadRequest},
+		{name: "replace SCIM user", method: http.MethodPut, path: scimUserPath, credential: provisioner.Key, body: dto.SCIMUser{Schemas: []string{dto.SCIMUserSchema}, UserName: "carol@example.com", Name: &dto.SCIMName{GivenName: "Caroline", FamilyName: "Jones"}, Emails: []dto.SCIMMultiValue{{Value: "carol@example.com"}}, Active: new(bool)}, want: http.StatusOK},
+		{name: "rename SCIM user", method: http.MethodPut, path: scimUserPath, credential: provisioner.Key, body: dto.SCIMUser{Schemas: []string{dto.SCIMUserSchema}, UserName: "caroline@example.com"}, want: http.StatusBadRequest},
+
+		{name: "list SCIM groups", method: http.MethodGet, path: "/scim/v2/Groups?excludedAttributes=members", credential: provisioner.Key, want: http.StatusOK},
+		{name: "get SCIM group", method: http.MethodGet, path: scimGroupPath, credential: provisioner.Key, want: http.StatusOK},
+		{name: "provision existing group", method: http.MethodPost, path: "/scim/v2/Groups", credential: provisioner.Key, body: dto.SCIMGroup{Schemas: []string{dto.SCIMGroupSchema}, DisplayName: "Engineering"}, want: http.StatusConflict},
+		{name: "provision group with another tenant's member", method: http.MethodPost, path: "/scim/v2/Groups", credential: provisioner.Key, body: dto.SCIMGroup{Schemas: []string{dto.SCIMGroupSchema}, DisplayName: "Sales", Members: []dto.SCIMMultiValue{{Value: alice.ID.String()}}}, want: http.StatusBadRequest},
+		{name: "remove SCIM group member", method: http.MethodPatch, path: scimGroupPath, credential: provisioner.Key, body: patch("remove", `members[value eq "`+carol.ID+`"]`, ""), want: http.StatusOK},
+		{name: "add SCIM group member", method: http.MethodPatch, path: scimGroupPath, credential: provisioner.Key, body: patch("add", "members", `[{"value": "`+carol.ID+`"}]`), want: http.StatusOK},
+		{name: "patch SCIM group with unknown operation", method: http.MethodPatch, path: scimGroupPath, credential: provisioner.Key, body: patch("move", "members", `[]`), want: http.StatusBadRequest},
+		{name: "replace SCIM group", method: http.MethodPut, path: scimGroupPath, credential: provisioner.Key, body: dto.SCIMGroup{Schemas: []string{dto.SCIMGroupSchema}, DisplayName: "Platform", Members: members}, want: http.StatusOK},
+		{name: "delete SCIM group with stale version", method: http.MethodDelete, path: scimGroupPath, credential: provisioner.Key, headers: map[string]string{"If-Match": engineering.Meta.Version}, want: http.StatusPreconditionFailed},
+		{name: "delete SCIM group", method: http.MethodDelete, path: scimGroupPath, credential: provisioner.Key, want: http.StatusNoContent},
+		{name: "get deleted SCIM group", method: http.MethodGet, path: scimGroupPath, credential: provisioner.Key, want: http.StatusNotFound},
+		{name: "delete SCIM user", method: http.MethodDelete, path: scimUserPath, credential: provisioner.Key, want: http.StatusNoContent},
+		{name: "get deleted SCIM user", method: http.MethodGet, path: scimUserPath, credential: provisioner.Key, want: http.StatusNotFound},
+
+		{name: "update profile", method: http.MethodPatch, path: "/users/me", credential: "alice-token", body: dto.UpdateProfileRequest{FirstName: &name}, want: http.StatusOK},
+		{name: "profile with API key", method: http.MethodGet, path: "/users/me", credential: issued.Key, want: http.StatusForbidden},
+		{name: "profile without credentials", method: http.MethodGet, path: "/users/me", want: http.StatusUnauthorized},
+
+		{name: "get local profile", method: http.MethodGet, path: "/users/me/profile", credential: "alice-token", want: http.StatusOK},
+		{name: "update local profile", method: http.MethodPatch, path: "/users/me/profile", credential: "alice-token", body: dto.PatchProfileRequest{
+			DisplayName:     &displayName,
+			Locale:          &locale,
+			TimeZone:        &timeZone,
+			Notifications:   &models.NotificationPreferences{Email: true, Digest: models.DigestWeekly},
+			DefaultLocation: &models.Location{Label: "Office", Latitude: 51.5, Longitude: -0.12},
+		}, want: http.StatusOK},
+		{name: "update local profile with unknown time zone", method: http.MethodPatch, path: "/users/me/profile", credential: "alice-token", body: dto.PatchProfileRequest{TimeZone: &unknownTimeZone}, want: http.StatusBadRequest},
+		{name: "get unset preferences", method: http.MethodGet, path: "/users/me/preferences/task-web", credential: "alice-token", want: http.StatusNotFound},
+		{name: "set preferences", method: http.MethodPut, path: "/users/me/preferences/task-web", credential: "alice-token", body: map[string]string{"theme": "dark"}, want: http.StatusOK},
+		{name: "set invalid preferences", method: http.MethodPut, path: "/users/me/preferences/task-web", credential: "alice-token", body: map[string]string{"theme": "blue"}, want: http.StatusBadRequest},
+		{name: "set too large preferences", method: http.MethodPut, path: "/users/me/preferences/task-web", credential: "alice-token", body: map[string]string{"theme": strings.Repeat("x", profile.MaxPreferencesSize)}, want: http.StatusRequestEntityTooLarge},
+		{name: "set preferences of unknown client", method: http.MethodPut, path: "/users/me/preferences/unknown", credential: "alice-token", body: map[string]string{}, want: http.StatusNotFound},
+		{name: "get preferences", method: http.MethodGet, path: "/users/me/preferences/task-web", credential: "alice-token", want: http.StatusOK},
+		{name: "get user's profile", method: http.MethodGet, path: userPath + "/profile", credential: "root-token", want: http.StatusOK},
+		{name: "get user's profile as non-admin", method: http.MethodGet, path: userPath + "/profile", credential: "alice-token", want: http.StatusForbidden},
+		{name: "get unknown user's profile", method: http.MethodGet, path: unknownUserPath + "/profile", credential: "root-token", want: http.StatusNotFound},
+		{name: "get user's preferences", method: http.MethodGet, path: userPath + "/preferences/task-web", credential: "root-token", want: http.StatusOK},
+		{name: "delete preferences", method: http.MethodDelete, path: "/users/me/preferences/task-web", credential: "alice-token", want: http.StatusNoContent},
+		{name: "delete deleted preferences", method: http.MethodDelete, path: "/users/me/preferences/task-web", credential: "alice-token", want: http.StatusNotFound},
+
+		{name: "list users", method: http.MethodGet, path: "/users?search=ali&limit=10", credential: "root-token", want: http.StatusOK},
+		{name: "list users as non-admin", method: http.MethodGet, path: "/users", credential: "alice-token", want: http.StatusForbidden},
+		{name: "list users with invalid limit", method: http.MethodGet, path: "/users?limit=1000", credential: "root-token", want: http.StatusBadRequest},
+		{name: "create user", method: http.MethodPost, path: "/users", credential: "root-token", body: dto.CreateUserRequest{Username: "bob", Email: "bob@example.com", Roles: []string{"user"}}, want: http.StatusCreated},
+		{name: "create existing user", method: http.MethodPost, path: "/users", credential: "root-token", body: dto.CreateUserRequest{Username: "alice", Email: "alice2@example.com"}, want: http.StatusConflict},
+		{name: "create user with invalid email", method: http.MethodPost, path: "/users", credential: "root-token", body: dto.CreateUserRequest{Username: "carol", Email: invalidEmail}, want: http.StatusBadRequest},
+		{name: "get user", method: http.MethodGet, path: userPath, credential: "root-token", want: http.StatusOK},
+		{name: "get unknown user", method: http.MethodGet, path: unknownUserPath, credential: "root-token", want: http.StatusNotFound},
+		{name: "get user with invalid ID", method: http.MethodGet, path: "/users/42", credential: "root-token", want: http.StatusBadRequest},
+		{name: "update user", method: http.MethodPatch, path: userPath, credential: "root-token", body: dto.UpdateUserRequest{Email: &email}, want: http.StatusOK},
+		{name: "update user with invalid email", method: http.MethodPatch, path: userPath, credential: "root-token", body: dto.UpdateUserRequest{Email: &invalidEmail}, want: http.StatusBadRequest},
+
+		{name: "list API keys", method: http.MethodGet, path: "/api-keys", credential: "alice-token", want: http.StatusOK},
+		{name: "rotate API key", method: http.MethodPost, path: keyPath + "/rotate", credential: "alice-token", want: http.StatusOK},
+		{name: "rotate another user's key", method: http.MethodPost, path: keyPath + "/rotate", credential: "root-token", want: http.StatusNotFound},
+		{name: "revoke API key", method: http.MethodDelete, path: keyPath, credential: "alice-token", want: http.StatusNoContent},
+		{name: "revoke revoked API key", method: http.MethodDelete, path: keyPath, credential: "alice-token", want: http.StatusNoContent},
+		{name: "rotate revoked API key", method: http.MethodPost, path: keyPath + "/rotate", credential: "alice-token", want: http.StatusConflict},
+
+		{name: "revoke user tokens as non-admin", method: http.MethodPost, path: userPath + "/revoke-tokens", credential: "alice-token", want: http.StatusForbidden},
+		{name: "revoke tokens of unknown user", method: http.MethodPost, path: unknownUserPath + "/revoke-tokens", credential: "root-token", want: http.StatusNotFound},
+		{name: "revoke user tokens", method: http.MethodPost, path: userPath + "/revoke-tokens", credential: "root-token", want: http.StatusNoContent},
+		{name: "validate revoked user token", method: http.MethodGet, path: "/auth/validate", credential: "alice-token", want: http.StatusUnauthorized},
+
+		{name: "reset password", method: http.MethodPost, path: userPath + "/reset-password", credential: "root-token", want: http.StatusAccepted},
+		{name: "deactivate user", method: http.MethodPost, path: userPath + "/deactivate", credential: "root-token", want: http.StatusOK},
+		{name: "reset password of deactivated user", method: http.MethodPost, path: userPath + "/reset-password", credential: "root-token", want: http.StatusConflict},
+		{name: "activate user", method: http.MethodPost, path: userPath + "/activate", credential: "root-token", want: http.StatusOK},
+		{name: "deactivate unknown user", method: http.MethodPost, path: unknownUserPath + "/deactivate", credential: "root-token", want: http.StatusNotFound},
+
+		{name: "delete user", method: http.MethodDelete, path: userPath, credential: "root-token", want: http.StatusNoContent},
+		{name: "get deleted user", method: http.MethodGet, path: userPath, credential: "root-token", want: http.StatusOK},
+		{name: "get deleted user's profile", method: http.MethodGet, path: userPath + "/profile", credential: "root-token", want: http.StatusOK},
+		{name: "delete deleted user", method: http.MethodDelete, path: userPath, credential: "root-token", want: http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkContract(t, specRouter, router, tt)
+		})
+	}
+}
+
+func checkContract(t *testing.T, specRouter routers.Router, router *gin.Engine, tt contractCase) *httptest.ResponseRecorder {
+	t.Helper()
+
+	// Bodies are sent as JSON, except url.Values which are sent as a form
+	var payload []byte
+	contentType := "application/json"
+	switch body := tt.body.(type) {
+	case nil:
+	case url.Values:
+		payload = []byte(body.Encode())
+		contentType = "application/x-www-form-urlencoded"
+	default:
+		payload, _ = json.Marshal(body)
+	}
+
+	newRequest := func() *http.Request {
+		req := httptest.NewRequest(tt.method, specServer+tt.path, bytes.NewReader(payload))
+		if tt.body != nil {
+			req.Header.Set("Content-Type", contentType)
+		}
+		if tt.credential != "" {
+			req.Header.Set("Authorization", "Bearer "+tt.credential)
+		}
+		for name, value := range tt.headers {
+			req.Header.Set(name, value)
+		}
+		return req
+	}
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, newRequest())
+	if rec.Code != tt.want {
+		t.Fatalf("%s %s status = %d, want %d: %s", tt.method, tt.path, rec.Code, tt.want, rec.Body)
+	}
+
+	req := newRequest()
+	route, pathParams, err := specRouter.FindRoute(req)
+	if err != nil {
+		t.Fatalf("%s %s is not in the spec: %v", tt.method, tt.path, err)
+	}
+
+	ctx := context.Background()
+	requestInput := &openapi3filter.RequestValidationInput{
+		Request:    req,
+		PathParams: pathParams,
+		Route:      route,
+		Options: &openapi3filter.Options{
+			AuthenticationFunc:    openapi3filter.NoopAuthenticationFunc,
+			IncludeResponseStatus: true,
+		},
+	}
+
+	// Requests meant to fail may break the spec on purpose
+	if tt.want < http.StatusBadRequest {
+		if err := openapi3filter.ValidateRequest(ctx, requestInput); err != nil {
+			t.Errorf("%s %s request doesn't match the spec: %v", tt.method, tt.path, err)
+		}
+	}
+
+	responseInput := &openapi3filter.ResponseValidationInput{
+		RequestValidationInput: requestInput,
+		Status:                 rec.Code,
+		Header:                 rec.Header(),
+		Body:                   io.NopCloser(bytes.NewReader(rec.Body.Bytes())),
+		Options:                requestInput.Options,
+	}
+	if err := openapi3filter.ValidateResponse(ctx, responseInput); err != nil {
+		t.Errorf("%s %s response doesn't match the spec: %v\n%s", tt.method, tt.path, err, rec.Body)
+	}
+
+	return rec
+}
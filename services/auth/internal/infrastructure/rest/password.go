@@ -0,0 +1,50 @@
+package rest
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/intellifinder/v4/libs/observability"
+	"github.com/intellifinder/v4/services/auth/internal/domain/ratelimit"
+	"github.com/intellifinder/v4/services/auth/pkg/dto"
+	"go.uber.org/zap"
+)
+
+// ForgotPassword emails a password reset link to the account with the given
+// username or email. It answers the same whether or not the account exists,
+// and sends the email in the background so the response time doesn't tell
+// either.
+func (h *Handler) ForgotPassword(c *gin.Context) {
+	var req dto.ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	ctx := c.Request.Context()
+	if !h.allow(c, ratelimit.EndpointForgotPassword, ratelimit.DimensionIP, c.ClientIP()) {
+		return
+	}
+
+	// Requests over the account's limit are accepted and ignored, so the
+	// answer doesn't tell whether the account exists
+	login := strings.ToLower(strings.TrimSpace(req.Login))
+	decision, err := h.limits.Check(ctx, ratelimit.EndpointForgotPassword, ratelimit.DimensionAccount, login)
+	if err != nil {
+		rateLimitError(c, err)
+		return
+	}
+
+	if decision.Allowed {
+		ctx := context.WithoutCancel(ctx)
+		go func() {
+			if err := h.users.ForgotPassword(ctx, login); err != nil {
+				observability.Logger(ctx).Error("failed to send password reset", zap.Error(err))
+			}
+		}()
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "if the account exists, an email with instructions has been sent"})
+}
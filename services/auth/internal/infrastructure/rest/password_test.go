@@ -0,0 +1,76 @@
+package rest
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/intellifinder/v4/services/auth/internal/domain/ratelimit"
+)
+
+func TestForgotPasswordIsRateLimitedPerAddress(t *testing.T) {
+	router := newTestRouter(&verifier{}, nil)
+
+	forgot := func(ip string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/auth/forgot-password", strings.NewReader(`{"login":"nobody@example.com"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.RemoteAddr = ip + ":1234"
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	for i := range ratelimit.DefaultPolicies()[ratelimit.EndpointForgotPassword].PerIP.Limit {
+		if rec := forgot("192.0.2.1"); rec.Code != http.StatusAccepted {
+			t.Fatalf("request %d status = %d, want %d", i+1, rec.Code, http.StatusAccepted)
+		}
+	}
+
+	rec := forgot("192.0.2.1")
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") == "" {
+		t.Errorf("request over the limit status = %d, Retry-After = %q, want %d with Retry-After", rec.Code, rec.Header().Get("Retry-After"), http.StatusTooManyRequests)
+	}
+
+	if rec := forgot("192.0.2.2"); rec.Code != http.StatusAccepted {
+		t.Errorf("request from another address status = %d, want %d", rec.Code, http.StatusAccepted)
+	}
+}
+
+func TestClientAddressIgnoresUntrustedForwardedFor(t *testing.T) {
+	router := newTestRouter(&verifier{}, nil)
+
+	forgot := func(remoteAddr string, forwardedFor string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/auth/forgot-password", strings.NewReader(`{"login":"nobody@example.com"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-Forwarded-For", forwardedFor)
+		req.RemoteAddr = remoteAddr + ":1234"
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// A new spoofed address on every request doesn't escape the limit
+	limit := ratelimit.DefaultPolicies()[ratelimit.EndpointForgotPassword].PerIP.Limit
+	for i := range limit {
+		forgot("192.0.2.1", fmt.Sprintf("198.51.100.%d", i))
+	}
+	if rec := forgot("192.0.2.1", "198.51.100.250"); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("request with a spoofed X-Forwarded-For status = %d, want %d", rec.Code, http.StatusTooManyRequests)
+	}
+
+	// Behind a trusted gateway every client has its own limit
+	if err := router.SetTrustedProxies([]string{"10.0.0.0/8"}); err != nil {
+		t.Fatal(err)
+	}
+	for range limit {
+		forgot("10.0.0.1", "192.0.2.2")
+	}
+	if rec := forgot("10.0.0.1", "192.0.2.2"); rec.Code != http.StatusTooManyRequests {
+		t.Errorf("request over the limit through the gateway status = %d, want %d", rec.Code, http.StatusTooManyRequests)
+	}
+	if rec := forgot("10.0.0.1", "192.0.2.3"); rec.Code != http.StatusAccepted {
+		t.Errorf("request of another client through the gateway status = %d, want %d", rec.Code, http.StatusAccepted)
+	}
+}
@@ -0,0 +1,174 @@
+package rest
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/intellifinder/v4/libs/observability"
+	"github.com/intellifinder/v4/services/auth/internal/domain/profile"
+	"github.com/intellifinder/v4/services/auth/internal/domain/user"
+	"github.com/intellifinder/v4/services/auth/pkg/dto"
+	"go.uber.org/zap"
+)
+
+// GetCurrentProfile returns the caller's local profile, made of defaults
+// until they change it
+func (h *Handler) GetCurrentProfile(c *gin.Context) {
+	id, ok := h.currentUserID(c)
+	if !ok {
+		return
+	}
+
+	p, err := h.profiles.GetProfile(c.Request.Context(), id)
+	if err != nil {
+		profileError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, p)
+}
+
+func (h *Handler) UpdateCurrentProfile(c *gin.Context) {
+	var req dto.PatchProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	id, ok := h.currentUserID(c)
+	if !ok {
+		return
+	}
+
+	p, err := h.profiles.UpdateProfile(c.Request.Context(), id, profile.UpdateParams{
+		DisplayName:          req.DisplayName,
+		AvatarURL:            req.AvatarURL,
+		Locale:               req.Locale,
+		TimeZone:             req.TimeZone,
+		Notifications:        req.Notifications,
+		DefaultLocation:      req.DefaultLocation,
+		ClearDefaultLocation: req.ClearDefaultLocation,
+	})
+	if err != nil {
+		profileError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, p)
+}
+
+// GetCurrentPreferences returns the caller's preferences for the client app
+func (h *Handler) GetCurrentPreferences(c *gin.Context) {
+	id, ok := h.currentUserID(c)
+	if !ok {
+		return
+	}
+
+	p, err := h.profiles.GetPreferences(c.Request.Context(), id, c.Param("client"))
+	if err != nil {
+		profileError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, p)
+}
+
+// SetCurrentPreferences replaces the caller's preferences for the client app
+// with the request body, which must be valid against the app's schema
+func (h *Handler) SetCurrentPreferences(c *gin.Context) {
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, profile.MaxPreferencesSize)
+	data, err := c.GetRawData()
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{"error": "preferences are too large"})
+		return
+	}
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	id, ok := h.currentUserID(c)
+	if !ok {
+		return
+	}
+
+	p, err := h.profiles.SetPreferences(c.Request.Context(), id, c.Param("client"), data)
+	if err != nil {
+		profileError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, p)
+}
+
+func (h *Handler) DeleteCurrentPreferences(c *gin.Context) {
+	id, ok := h.currentUserID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.profiles.DeletePreferences(c.Request.Context(), id, c.Param("client")); err != nil {
+		profileError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// GetUserProfile returns the profile of any local user, deleted ones included
+func (h *Handler) GetUserProfile(c *gin.Context) {
+	id, ok := parseID(c)
+	if !ok {
+		return
+	}
+
+	p, err := h.profiles.GetProfile(c.Request.Context(), id)
+	if err != nil {
+		profileError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, p)
+}
+
+func (h *Handler) GetUserPreferences(c *gin.Context) {
+	id, ok := parseID(c)
+	if !ok {
+		return
+	}
+
+	p, err := h.profiles.GetPreferences(c.Request.Context(), id, c.Param("client"))
+	if err != nil {
+		profileError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, p)
+}
+
+// currentUserID returns the local ID of the caller
+func (h *Handler) currentUserID(c *gin.Context) (uuid.UUID, bool) {
+	u, err := h.users.GetUserByOIDCID(c.Request.Context(), identity(c).Subject)
+	if err != nil {
+		userError(c, err)
+		return uuid.Nil, false
+	}
+	return u.ID, true
+}
+
+func profileError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, user.ErrUserNotFound):
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "user not found"})
+	case errors.Is(err, profile.ErrPreferencesNotFound), errors.Is(err, profile.ErrUnknownClient):
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	case errors.Is(err, profile.ErrInvalidProfile), errors.Is(err, profile.ErrInvalidPreferences):
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		observability.Logger(c.Request.Context()).Error("profile request failed", zap.Error(err))
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+	}
+}
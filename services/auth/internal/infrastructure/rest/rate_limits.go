@@ -0,0 +1,81 @@
+package rest
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/intellifinder/v4/libs/observability"
+	"go.uber.org/zap"
+)
+
+// allow counts the request at the endpoint against the limit of the
+// dimension and answers it with 429 if it went over
+func (h *Handler) allow(c *gin.Context, endpoint string, dimension string, value string) bool {
+	decision, err := h.limits.Check(c.Request.Context(), endpoint, dimension, value)
+	if err != nil {
+		rateLimitError(c, err)
+		return false
+	}
+
+	if !decision.Allowed {
+		tooManyRequests(c, decision.RetryAfter, "too many requests")
+		return false
+	}
+
+	return true
+}
+
+// checkLockout answers the request with 429 if the subject is locked out of
+// the endpoint after failing too often
+func (h *Handler) checkLockout(c *gin.Context, endpoint string, subject string) bool {
+	decision, err := h.limits.CheckLockout(c.Request.Context(), endpoint, subject)
+	if err != nil {
+		rateLimitError(c, err)
+		return false
+	}
+
+	if !decision.Allowed {
+		tooManyRequests(c, decision.RetryAfter, "too many failed attempts")
+		return false
+	}
+
+	return true
+}
+
+// recordFailure counts a failed attempt of the subject. The request fails
+// either way, so errors are only logged.
+func (h *Handler) recordFailure(c *gin.Context, endpoint string, subject string) {
+	decision, err := h.limits.RecordFailure(c.Request.Context(), endpoint, subject)
+	if err != nil {
+		observability.Logger(c.Request.Context()).Error("failed to record failed attempt", zap.Error(err))
+		return
+	}
+
+	if !decision.Allowed {
+		observability.Logger(c.Request.Context()).Warn("locked out after repeated failures",
+			zap.String("endpoint", endpoint), zap.String("client_ip", c.ClientIP()), zap.Duration("duration", decision.RetryAfter))
+	}
+}
+
+// recordSuccess forgets the failures of the subject. The request succeeded
+// either way, so errors are only logged.
+func (h *Handler) recordSuccess(c *gin.Context, endpoint string, subject string) {
+	if err := h.limits.RecordSuccess(c.Request.Context(), endpoint, subject); err != nil {
+		observability.Logger(c.Request.Context()).Error("failed to reset failed attempts", zap.Error(err))
+	}
+}
+
+func tooManyRequests(c *gin.Context, retryAfter time.Duration, message string) {
+	c.Header("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{"error": message})
+}
+
+// rateLimitError fails closed, since the limited endpoints send emails and
+// check secrets
+func rateLimitError(c *gin.Context, err error) {
+	observability.Logger(c.Request.Context()).Error("failed to check rate limit", zap.Error(err))
+	c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "temporarily unavailable"})
+}
@@ -0,0 +1,84 @@
+package rest
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/intellifinder/v4/services/auth/internal/domain/ratelimit"
+	"github.com/intellifinder/v4/services/auth/pkg/models"
+)
+
+func TestLoginLockout(t *testing.T) {
+	router, _ := newContractRouter(t)
+
+	login := func(username string, password string) *httptest.ResponseRecorder {
+		body := `{"username":"` + username + `","password":"` + password + `"}`
+		req := httptest.NewRequest(http.MethodPost, "/auth/login", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// A successful login forgets the failures before it
+	threshold := ratelimit.DefaultPolicies()[ratelimit.EndpointLogin].Lockout.Threshold
+	for range threshold - 1 {
+		login("alice", "guess")
+	}
+	if rec := login("alice", "secret"); rec.Code != http.StatusOK {
+		t.Fatalf("login status = %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	for i := range threshold {
+		if rec := login("Alice", "guess"); rec.Code != http.StatusUnauthorized {
+			t.Fatalf("failed login %d status = %d, want %d", i+1, rec.Code, http.StatusUnauthorized)
+		}
+	}
+
+	rec := login("alice", "secret")
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") == "" {
+		t.Errorf("login while locked out status = %d, Retry-After = %q, want %d with Retry-After", rec.Code, rec.Header().Get("Retry-After"), http.StatusTooManyRequests)
+	}
+
+	if rec := login("bob", "guess"); rec.Code != http.StatusUnauthorized {
+		t.Errorf("login of another account status = %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestValidateLocksOutInvalidAPIKeys(t *testing.T) {
+	const key = "ifk_0123456789abcdef_secret"
+	router := newTestRouter(&verifier{}, &verifier{identities: map[string]*models.Identity{
+		key: {Subject: "user-1", Method: models.AuthMethodAPIKey},
+	}})
+
+	validateFrom := func(ip string, credential string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/auth/validate", nil)
+		req.Header.Set(APIKeyHeader, credential)
+		req.RemoteAddr = ip + ":1234"
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// Invalid bearer tokens don't count; expired sessions send them all the time
+	for range 50 {
+		validate(router, "/auth/validate", map[string]string{"Authorization": "Bearer expired"})
+	}
+
+	for i := range ratelimit.DefaultPolicies()[ratelimit.EndpointValidate].Lockout.Threshold {
+		if rec := validateFrom("192.0.2.1", "ifk_0123456789abcdef_guess"); rec.Code != http.StatusUnauthorized {
+			t.Fatalf("invalid key %d status = %d, want %d", i+1, rec.Code, http.StatusUnauthorized)
+		}
+	}
+
+	rec := validateFrom("192.0.2.1", key)
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Retry-After") == "" {
+		t.Errorf("valid key while locked out status = %d, Retry-After = %q, want %d with Retry-After", rec.Code, rec.Header().Get("Retry-After"), http.StatusTooManyRequests)
+	}
+
+	if rec := validateFrom("192.0.2.2", key); rec.Code != http.StatusOK {
+		t.Errorf("valid key from another address status = %d, want %d", rec.Code, http.StatusOK)
+	}
+}
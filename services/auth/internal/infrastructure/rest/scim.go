@@ -0,0 +1,529 @@
+package rest
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/intellifinder/v4/libs/observability"
+	"github.com/intellifinder/v4/services/auth/internal/domain/authentication"
+	"github.com/intellifinder/v4/services/auth/internal/domain/scim"
+	"github.com/intellifinder/v4/services/auth/pkg/dto"
+	"go.uber.org/zap"
+)
+
+// ProvisioningScope is the API key scope identity providers provision users with
+const ProvisioningScope = "scim:provision"
+
+const (
+	scimContentType = "application/scim+json"
+	tenantKey       = "tenant"
+)
+
+// requireProvisioner authenticates SCIM clients. They use API keys of a
+// tenant admin that are restricted to the provisioning scope, so a key
+// handed to an identity provider can't be used for anything else and only
+// reaches the tenant's users and groups.
+func (h *Handler) requireProvisioner(c *gin.Context) {
+	key := apiKey(c)
+	if key == "" {
+		c.Header("WWW-Authenticate", `Bearer realm="scim"`)
+		writeSCIMError(c, http.StatusUnauthorized, "", "an API key is required")
+		return
+	}
+
+	caller, err := h.auth.AuthenticateAPIKey(c.Request.Context(), key)
+	switch {
+	case errors.Is(err, authentication.ErrCredentialsExpired):
+		c.Header("WWW-Authenticate", `Bearer error="invalid_token", error_description="credentials expired"`)
+		writeSCIMError(c, http.StatusUnauthorized, "", "credentials expired")
+		return
+	case errors.Is(err, authentication.ErrInvalidCredentials):
+		c.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+		writeSCIMError(c, http.StatusUnauthorized, "", "invalid credentials")
+		return
+	case errors.Is(err, authentication.ErrUnavailable):
+		observability.Logger(c.Request.Context()).Warn("credentials could not be verified", zap.Error(err))
+		writeSCIMError(c, http.StatusServiceUnavailable, "", "authentication temporarily unavailable")
+		return
+	case err != nil:
+		observability.Logger(c.Request.Context()).Error("failed to authenticate request", zap.Error(err))
+		writeSCIMError(c, http.StatusInternalServerError, "", "internal error")
+		return
+	}
+
+	// HasScope accepts unrestricted keys, which must not be used here
+	if !slices.Contains(caller.Scopes, ProvisioningScope) || !caller.HasRole(AdminRole) || caller.TenantID == "" {
+		writeSCIMError(c, http.StatusForbidden, "", "provisioning requires a tenant admin's API key with the "+ProvisioningScope+" scope")
+		return
+	}
+
+	c.Set(tenantKey, caller.TenantID)
+	c.Next()
+}
+
+// tenant returns the tenant stored by requireProvisioner
+func tenant(c *gin.Context) string {
+	return c.MustGet(tenantKey).(string)
+}
+
+// GetServiceProviderConfig describes the SCIM features the service supports
+func (h *Handler) GetServiceProviderConfig(c *gin.Context) {
+	writeSCIM(c, http.StatusOK, gin.H{
+		"schemas":          []string{dto.SCIMServiceProviderConfigSchema},
+		"documentationUri": "https://datatracker.ietf.org/doc/html/rfc7644",
+		"patch":            gin.H{"supported": true},
+		"bulk":             gin.H{"supported": false, "maxOperations": 0, "maxPayloadSize": 0},
+		"filter":           gin.H{"supported": true, "maxResults": scim.MaxResults},
+		"changePassword":   gin.H{"supported": false},
+		"sort":             gin.H{"supported": false},
+		"etag":             gin.H{"supported": true},
+		"authenticationSchemes": []gin.H{{
+			"type":        "oauthbearertoken",
+			"name":        "API key",
+			"description": "A tenant admin's API key with the " + ProvisioningScope + " scope, sent as a bearer token",
+			"primary":     true,
+		}},
+		"meta": gin.H{"resourceType": "ServiceProviderConfig", "location": resourceURL(c, "ServiceProviderConfig", "")},
+	})
+}
+
+// ListResourceTypes lists the User and Group resource types
+func (h *Handler) ListResourceTypes(c *gin.Context) {
+	types := []any{
+		gin.H{
+			"schemas":  []string{dto.SCIMResourceTypeSchema},
+			"id":       "User",
+			"name":     "User",
+			"endpoint": "/Users",
+			"schema":   dto.SCIMUserSchema,
+			"meta":     gin.H{"resourceType": "ResourceType", "location": resourceURL(c, "ResourceTypes", "User")},
+		},
+		gin.H{
+			"schemas":  []string{dto.SCIMResourceTypeSchema},
+			"id":       "Group",
+			"name":     "Group",
+			"endpoint": "/Groups",
+			"schema":   dto.SCIMGroupSchema,
+			"meta":     gin.H{"resourceType": "ResourceType", "location": resourceURL(c, "ResourceTypes", "Group")},
+		},
+	}
+
+	writeSCIM(c, http.StatusOK, dto.SCIMListResponse{
+		Schemas:      []string{dto.SCIMListResponseSchema},
+		TotalResults: len(types),
+		StartIndex:   1,
+		ItemsPerPage: len(types),
+		Resources:    types,
+	})
+}
+
+func (h *Handler) ListSCIMUsers(c *gin.Context) {
+	var query dto.SCIMListQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		writeSCIMError(c, http.StatusBadRequest, "invalidValue", err.Error())
+		return
+	}
+
+	users, total, err := h.provisioning.ListUsers(c.Request.Context(), tenant(c), listQuery(query))
+	if err != nil {
+		scimError(c, err)
+		return
+	}
+
+	resources := make([]any, len(users))
+	for i, u := range users {
+		u.Meta.Location = resourceURL(c, "Users", u.ID)
+		resources[i] = project(u, query.Attributes, query.ExcludedAttributes)
+	}
+	writeSCIM(c, http.StatusOK, listResponse(query, total, resources))
+}
+
+func (h *Handler) GetSCIMUser(c *gin.Context) {
+	id, ok := parseSCIMID(c)
+	if !ok {
+		return
+	}
+
+	u, err := h.provisioning.GetUser(c.Request.Context(), tenant(c), id)
+	if err != nil {
+		scimError(c, err)
+		return
+	}
+
+	writeSCIMResource(c, http.StatusOK, "Users", u.ID, u.Meta, u)
+}
+
+func (h *Handler) CreateSCIMUser(c *gin.Context) {
+	var req dto.SCIMUser
+	if err := c.ShouldBindJSON(&req); err != nil {
+		writeSCIMError(c, http.StatusBadRequest, "invalidSyntax", err.Error())
+		return
+	}
+
+	u, err := h.provisioning.CreateUser(c.Request.Context(), tenant(c), &req)
+	if err != nil {
+		scimError(c, err)
+		return
+	}
+
+	writeSCIMResource(c, http.StatusCreated, "Users", u.ID, u.Meta, u)
+}
+
+func (h *Handler) ReplaceSCIMUser(c *gin.Context) {
+	id, ok := parseSCIMID(c)
+	if !ok {
+		return
+	}
+
+	var req dto.SCIMUser
+	if err := c.ShouldBindJSON(&req); err != nil {
+		writeSCIMError(c, http.StatusBadRequest, "invalidSyntax", err.Error())
+		return
+	}
+
+	u, err := h.provisioning.ReplaceUser(c.Request.Context(), tenant(c), id, &req, c.GetHeader("If-Match"))
+	if err != nil {
+		scimError(c, err)
+		return
+	}
+
+	writeSCIMResource(c, http.StatusOK, "Users", u.ID, u.Meta, u)
+}
+
+func (h *Handler) PatchSCIMUser(c *gin.Context) {
+	id, ok := parseSCIMID(c)
+	if !ok {
+		return
+	}
+
+	var req dto.SCIMPatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		writeSCIMError(c, http.StatusBadRequest, "invalidSyntax", err.Error())
+		return
+	}
+
+	u, err := h.provisioning.PatchUser(c.Request.Context(), tenant(c), id, req.Operations, c.GetHeader("If-Match"))
+	if err != nil {
+		scimError(c, err)
+		return
+	}
+
+	writeSCIMResource(c, http.StatusOK, "Users", u.ID, u.Meta, u)
+}
+
+// DeleteSCIMUser deletes the user, which identity providers do when it's
+// unassigned from the application
+func (h *Handler) DeleteSCIMUser(c *gin.Context) {
+	id, ok := parseSCIMID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.provisioning.DeleteUser(c.Request.Context(), tenant(c), id, c.GetHeader("If-Match")); err != nil {
+		scimError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func (h *Handler) ListSCIMGroups(c *gin.Context) {
+	var query dto.SCIMListQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		writeSCIMError(c, http.StatusBadRequest, "invalidValue", err.Error())
+		return
+	}
+
+	groups, total, err := h.provisioning.ListGroups(c.Request.Context(), tenant(c), listQuery(query))
+	if err != nil {
+		scimError(c, err)
+		return
+	}
+
+	resources := make([]any, len(groups))
+	for i, g := range groups {
+		g.Meta.Location = resourceURL(c, "Groups", g.ID)
+		resources[i] = project(g, query.Attributes, query.ExcludedAttributes)
+	}
+	writeSCIM(c, http.StatusOK, listResponse(query, total, resources))
+}
+
+func (h *Handler) GetSCIMGroup(c *gin.Context) {
+	id, ok := parseSCIMID(c)
+	if !ok {
+		return
+	}
+
+	g, err := h.provisioning.GetGroup(c.Request.Context(), tenant(c), id)
+	if err != nil {
+		scimError(c, err)
+		return
+	}
+
+	writeSCIMResource(c, http.StatusOK, "Groups", g.ID, g.Meta, g)
+}
+
+func (h *Handler) CreateSCIMGroup(c *gin.Context) {
+	var req dto.SCIMGroup
+	if err := c.ShouldBindJSON(&req); err != nil {
+		writeSCIMError(c, http.StatusBadRequest, "invalidSyntax", err.Error())
+		return
+	}
+
+	g, err := h.provisioning.CreateGroup(c.Request.Context(), tenant(c), &req)
+	if err != nil {
+		scimError(c, err)
+		return
+	}
+
+	writeSCIMResource(c, http.StatusCreated, "Groups", g.ID, g.Meta, g)
+}
+
+func (h *Handler) ReplaceSCIMGroup(c *gin.Context) {
+	id, ok := parseSCIMID(c)
+	if !ok {
+		return
+	}
+
+	var req dto.SCIMGroup
+	if err := c.ShouldBindJSON(&req); err != nil {
+		writeSCIMError(c, http.StatusBadRequest, "invalidSyntax", err.Error())
+		return
+	}
+
+	g, err := h.provisioning.ReplaceGroup(c.Request.Context(), tenant(c), id, &req, c.GetHeader("If-Match"))
+	if err != nil {
+		scimError(c, err)
+		return
+	}
+
+	writeSCIMResource(c, http.StatusOK, "Groups", g.ID, g.Meta, g)
+}
+
+func (h *Handler) PatchSCIMGroup(c *gin.Context) {
+	id, ok := parseSCIMID(c)
+	if !ok {
+		return
+	}
+
+	var req dto.SCIMPatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		writeSCIMError(c, http.StatusBadRequest, "invalidSyntax", err.Error())
+		return
+	}
+
+	g, err := h.provisioning.PatchGroup(c.Request.Context(), tenant(c), id, req.Operations, c.GetHeader("If-Match"))
+	if err != nil {
+		scimError(c, err)
+		return
+	}
+
+	writeSCIMResource(c, http.StatusOK, "Groups", g.ID, g.Meta, g)
+}
+
+func (h *Handler) DeleteSCIMGroup(c *gin.Context) {
+	id, ok := parseSCIMID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.provisioning.DeleteGroup(c.Request.Context(), tenant(c), id, c.GetHeader("If-Match")); err != nil {
+		scimError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// parseSCIMID answers 404 for IDs that aren't UUIDs, since no resource has them
+func parseSCIMID(c *gin.Context) (uuid.UUID, bool) {
+	id, err := uuid.Parse(c.Param("id"))
+	if err != nil {
+		writeSCIMError(c, http.StatusNotFound, "", scim.ErrResourceNotFound.Error())
+		return uuid.Nil, false
+	}
+	return id, true
+}
+
+func listQuery(query dto.SCIMListQuery) scim.Query {
+	return scim.Query{Filter: query.Filter, StartIndex: query.StartIndex, Count: query.Count}
+}
+
+func listResponse(query dto.SCIMListQuery, total int, resources []any) dto.SCIMListResponse {
+	return dto.SCIMListResponse{
+		Schemas:      []string{dto.SCIMListResponseSchema},
+		TotalResults: total,
+		StartIndex:   max(query.StartIndex, 1),
+		ItemsPerPage: len(resources),
+		Resources:    resources,
+	}
+}
+
+// resourceURL returns the absolute URL of a SCIM endpoint, or of a resource
+// if id is set. Traefik sets X-Forwarded-Proto when it terminates TLS.
+func resourceURL(c *gin.Context, endpoint string, id string) string {
+	scheme := "http"
+	if proto := c.GetHeader("X-Forwarded-Proto"); proto != "" {
+		scheme = proto
+	} else if c.Request.TLS != nil {
+		scheme = "https"
+	}
+
+	location := scheme + "://" + c.Request.Host + "/scim/v2/" + endpoint
+	if id != "" {
+		location += "/" + id
+	}
+	return location
+}
+
+// writeSCIMResource answers with a resource, its location and its version.
+// GET requests whose If-None-Match lists the version are answered with 304.
+func writeSCIMResource(c *gin.Context, status int, endpoint string, id string, meta *dto.SCIMMeta, resource any) {
+	meta.Location = resourceURL(c, endpoint, id)
+	c.Header("ETag", meta.Version)
+	if status == http.StatusCreated {
+		c.Header("Location", meta.Location)
+	}
+
+	if c.Request.Method == http.MethodGet {
+		for _, tag := range strings.Split(c.GetHeader("If-None-Match"), ",") {
+			if strings.TrimPrefix(strings.TrimSpace(tag), "W/") == strings.TrimPrefix(meta.Version, "W/") {
+				c.Status(http.StatusNotModified)
+				return
+			}
+		}
+	}
+
+	writeSCIM(c, status, project(resource, c.Query("attributes"), c.Query("excludedAttributes")))
+}
+
+func writeSCIM(c *gin.Context, status int, body any) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		observability.Logger(c.Request.Context()).Error("failed to encode SCIM response", zap.Error(err))
+		c.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+	c.Data(status, scimContentType, data)
+}
+
+func writeSCIMError(c *gin.Context, status int, scimType string, detail string) {
+	data, _ := json.Marshal(dto.SCIMError{
+		Schemas:  []string{dto.SCIMErrorSchema},
+		Status:   strconv.Itoa(status),
+		SCIMType: scimType,
+		Detail:   detail,
+	})
+	c.Abort()
+	c.Data(status, scimContentType, data)
+}
+
+// project reduces a resource to the requested attributes, or removes the
+// excluded ones (RFC 7644 section 3.9). Attributes may name sub-attributes,
+// e.g. name.givenName, and carry the schema URN. The id and schemas are
+// always returned.
+func project(resource any, attributes string, excluded string) any {
+	if attributes == "" && excluded == "" {
+		return resource
+	}
+
+	data, _ := json.Marshal(resource)
+	var m map[string]any
+	json.Unmarshal(data, &m)
+
+	if attributes != "" {
+		kept := map[string]any{"id": m["id"], "schemas": m["schemas"]}
+		for _, name := range strings.Split(attributes, ",") {
+			attr, sub := projectedAttribute(name)
+			key, value := findAttribute(m, attr)
+			if value == nil {
+				continue
+			}
+			obj, complex := value.(map[string]any)
+			if sub == "" || !complex {
+				kept[key] = value
+				continue
+			}
+			subKey, subValue := findAttribute(obj, sub)
+			if subValue == nil {
+				continue
+			}
+			partial, _ := kept[key].(map[string]any)
+			if partial == nil {
+				partial = make(map[string]any)
+			}
+			partial[subKey] = subValue
+			kept[key] = partial
+		}
+		m = kept
+	}
+
+	for _, name := range strings.Split(excluded, ",") {
+		attr, sub := projectedAttribute(name)
+		if attr == "" || attr == "id" || attr == "schemas" {
+			continue
+		}
+		key, value := findAttribute(m, attr)
+		if obj, complex := value.(map[string]any); complex && sub != "" {
+			subKey, _ := findAttribute(obj, sub)
+			delete(obj, subKey)
+			continue
+		}
+		delete(m, key)
+	}
+
+	return m
+}
+
+// projectedAttribute splits an attribute name into the attribute and its
+// sub-attribute, dropping the schema URN
+func projectedAttribute(name string) (string, string) {
+	name = strings.TrimSpace(name)
+	if strings.HasPrefix(strings.ToLower(name), "urn:") {
+		name = name[strings.LastIndex(name, ":")+1:]
+	}
+	attr, sub, _ := strings.Cut(name, ".")
+	return attr, sub
+}
+
+// findAttribute looks an attribute up case-insensitively
+func findAttribute(m map[string]any, name string) (string, any) {
+	for key, value := range m {
+		if strings.EqualFold(key, name) {
+			return key, value
+		}
+	}
+	return name, nil
+}
+
+func scimError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, scim.ErrResourceNotFound):
+		writeSCIMError(c, http.StatusNotFound, "", err.Error())
+	case errors.Is(err, scim.ErrUniqueness):
+		writeSCIMError(c, http.StatusConflict, "uniqueness", err.Error())
+	case errors.Is(err, scim.ErrPreconditionFailed):
+		writeSCIMError(c, http.StatusPreconditionFailed, "", err.Error())
+	case errors.Is(err, scim.ErrInvalidFilter):
+		writeSCIMError(c, http.StatusBadRequest, "invalidFilter", err.Error())
+	case errors.Is(err, scim.ErrInvalidPath):
+		writeSCIMError(c, http.StatusBadRequest, "invalidPath", err.Error())
+	case errors.Is(err, scim.ErrNoTarget):
+		writeSCIMError(c, http.StatusBadRequest, "noTarget", err.Error())
+	case errors.Is(err, scim.ErrInvalidSyntax):
+		writeSCIMError(c, http.StatusBadRequest, "invalidSyntax", err.Error())
+	case errors.Is(err, scim.ErrInvalidValue):
+		writeSCIMError(c, http.StatusBadRequest, "invalidValue", err.Error())
+	case errors.Is(err, scim.ErrMutability):
+		writeSCIMError(c, http.StatusBadRequest, "mutability", err.Error())
+	default:
+		observability.Logger(c.Request.Context()).Error("SCIM request failed", zap.Error(err))
+		writeSCIMError(c, http.StatusInternalServerError, "", "internal error")
+	}
+}
@@ -0,0 +1,57 @@
+package rest
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/intellifinder/v4/libs/observability"
+	"github.com/intellifinder/v4/services/auth/internal/domain/session"
+	"github.com/intellifinder/v4/services/auth/pkg/dto"
+	"go.uber.org/zap"
+)
+
+func (h *Handler) ListSessions(c *gin.Context) {
+	caller := identity(c)
+	sessions, err := h.sessions.ListSessions(c.Request.Context(), caller.Subject)
+	if err != nil {
+		sessionError(c, err)
+		return
+	}
+
+	list := dto.SessionList{Sessions: make([]dto.Session, len(sessions))}
+	for i, s := range sessions {
+		list.Sessions[i] = dto.Session{Session: s, Current: s.ID == caller.SessionID}
+	}
+
+	c.JSON(http.StatusOK, list)
+}
+
+func (h *Handler) RevokeSession(c *gin.Context) {
+	if err := h.sessions.RevokeSession(c.Request.Context(), identity(c).Subject, c.Param("id")); err != nil {
+		sessionError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// RevokeAllSessions ends every session of the caller, including the current one
+func (h *Handler) RevokeAllSessions(c *gin.Context) {
+	if _, err := h.sessions.RevokeAllSessions(c.Request.Context(), identity(c).Subject); err != nil {
+		sessionError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func sessionError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, session.ErrSessionNotFound):
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": err.Error()})
+	default:
+		observability.Logger(c.Request.Context()).Error("session request failed", zap.Error(err))
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+	}
+}
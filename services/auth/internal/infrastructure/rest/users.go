@@ -0,0 +1,221 @@
+package rest
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/intellifinder/v4/libs/observability"
+	"github.com/intellifinder/v4/services/auth/internal/domain/user"
+	"github.com/intellifinder/v4/services/auth/pkg/dto"
+	"go.uber.org/zap"
+)
+
+// GetCurrentUser returns the caller's local profile with the roles and tenant of their token
+func (h *Handler) GetCurrentUser(c *gin.Context) {
+	caller := identity(c)
+	u, err := h.users.GetUserByOIDCID(c.Request.Context(), caller.Subject)
+	if err != nil {
+		userError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.CurrentUser{User: u, Roles: caller.Roles, TenantID: caller.TenantID})
+}
+
+func (h *Handler) UpdateCurrentUser(c *gin.Context) {
+	var req dto.UpdateProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	caller := identity(c)
+	u, err := h.users.GetUserByOIDCID(c.Request.Context(), caller.Subject)
+	if err != nil {
+		userError(c, err)
+		return
+	}
+
+	u, err = h.users.UpdateUser(c.Request.Context(), u.ID, user.UpdateParams{
+		FirstName: req.FirstName,
+		LastName:  req.LastName,
+	})
+	if err != nil {
+		userError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, dto.CurrentUser{User: u, Roles: caller.Roles, TenantID: caller.TenantID})
+}
+
+func (h *Handler) ListUsers(c *gin.Context) {
+	var query dto.ListUsersQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	filter := dto.UserFilter{Search: query.Search, IncludeDeleted: query.IncludeDeleted}
+	users, err := h.users.ListUsers(c.Request.Context(), filter, query.Page, query.Limit)
+	if err != nil {
+		userError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, users)
+}
+
+func (h *Handler) CreateUser(c *gin.Context) {
+	var req dto.CreateUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	u, err := h.users.CreateUser(c.Request.Context(), user.CreateParams{
+		Username:  req.Username,
+		Email:     req.Email,
+		FirstName: req.FirstName,
+		LastName:  req.LastName,
+		Roles:     req.Roles,
+	})
+	if err != nil {
+		userError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, u)
+}
+
+// GetUser returns deleted users too, so references to them can be resolved
+func (h *Handler) GetUser(c *gin.Context) {
+	id, ok := parseID(c)
+	if !ok {
+		return
+	}
+
+	u, err := h.users.GetUser(c.Request.Context(), id)
+	if err != nil {
+		userError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, u)
+}
+
+func (h *Handler) UpdateUser(c *gin.Context) {
+	id, ok := parseID(c)
+	if !ok {
+		return
+	}
+
+	var req dto.UpdateUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	u, err := h.users.UpdateUser(c.Request.Context(), id, user.UpdateParams{
+		Email:     req.Email,
+		FirstName: req.FirstName,
+		LastName:  req.LastName,
+	})
+	if err != nil {
+		userError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, u)
+}
+
+func (h *Handler) DeleteUser(c *gin.Context) {
+	id, ok := parseID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.users.DeleteUser(c.Request.Context(), id); err != nil {
+		userError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// RevokeUserTokens revokes every access token the user holds right now
+func (h *Handler) RevokeUserTokens(c *gin.Context) {
+	id, ok := parseID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.users.RevokeTokens(c.Request.Context(), id); err != nil {
+		userError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// DeactivateUser disables the user and ends their sessions
+func (h *Handler) DeactivateUser(c *gin.Context) {
+	id, ok := parseID(c)
+	if !ok {
+		return
+	}
+
+	u, err := h.users.DeactivateUser(c.Request.Context(), id)
+	if err != nil {
+		userError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, u)
+}
+
+func (h *Handler) ActivateUser(c *gin.Context) {
+	id, ok := parseID(c)
+	if !ok {
+		return
+	}
+
+	u, err := h.users.ActivateUser(c.Request.Context(), id)
+	if err != nil {
+		userError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, u)
+}
+
+// ResetUserPassword emails the user a link to choose a new password
+func (h *Handler) ResetUserPassword(c *gin.Context) {
+	id, ok := parseID(c)
+	if !ok {
+		return
+	}
+
+	if err := h.users.ResetPassword(c.Request.Context(), id); err != nil {
+		userError(c, err)
+		return
+	}
+
+	c.Status(http.StatusAccepted)
+}
+
+func userError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, user.ErrUserNotFound):
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "user not found"})
+	case errors.Is(err, user.ErrUserExists):
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "a user with this username or email already exists"})
+	case errors.Is(err, user.ErrUserDisabled):
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{"error": "user is disabled"})
+	case errors.Is(err, user.ErrInvalidUser):
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		observability.Logger(c.Request.Context()).Error("user request failed", zap.Error(err))
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+	}
+}
@@ -0,0 +1,165 @@
+package rest
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/intellifinder/v4/libs/observability"
+	"github.com/intellifinder/v4/services/auth/internal/domain/apikey"
+	"github.com/intellifinder/v4/services/auth/internal/domain/authentication"
+	"github.com/intellifinder/v4/services/auth/internal/domain/ratelimit"
+	"github.com/intellifinder/v4/services/auth/pkg/models"
+	"go.uber.org/zap"
+)
+
+// Headers set on successful validations. Traefik copies them onto the
+// forwarded request, replacing any the client sent, when they are listed in
+// the middleware's authResponseHeaders.
+const (
+	UserIDHeader    = "X-User-Id"
+	UserRolesHeader = "X-User-Roles"
+	TenantIDHeader  = "X-Tenant-Id"
+	SessionIDHeader = "X-Session-Id"
+	// ScopesHeader is only set for API keys restricted to some scopes
+	ScopesHeader = "X-Auth-Scopes"
+	// ImpersonatorIDHeader and ImpersonationIDHeader are only set for
+	// impersonation tokens; X-User-Id is then the impersonated user
+	ImpersonatorIDHeader  = "X-Impersonator-Id"
+	ImpersonationIDHeader = "X-Impersonation-Id"
+
+	// APIKeyHeader carries API keys; bearer tokens use the Authorization header
+	APIKeyHeader = "X-API-Key"
+	// forwardedMethodHeader is the method of the request Traefik is authorizing
+	forwardedMethodHeader = "X-Forwarded-Method"
+	// forwardedURIHeader is the path and query of the request Traefik is authorizing
+	forwardedURIHeader = "X-Forwarded-Uri"
+)
+
+// Validate is the Traefik ForwardAuth endpoint. It answers 200 with the
+// caller's identity in the response headers, 401 when the credentials are
+// missing or invalid and 403 when the caller lacks a role or scope required by
+// the route. Routes require them by adding them to the middleware address,
+// e.g. /auth/validate?role=admin&scope=tasks:write; every listed role and
+// scope is required. Scopes only restrict API keys. Routes adding
+// sensitive=true are refused to impersonation tokens; every other request made
+// with one is added to the impersonation's audit trail. Requests are limited
+// per API key, and addresses sending invalid API keys are locked out.
+func (h *Handler) Validate(c *gin.Context) {
+	// CORS preflights never carry credentials
+	if c.GetHeader(forwardedMethodHeader) == http.MethodOptions {
+		c.Status(http.StatusOK)
+		return
+	}
+
+	key := apiKey(c)
+	if key != "" && !h.checkLockout(c, ratelimit.EndpointValidate, c.ClientIP()) {
+		return
+	}
+
+	identity, err := h.authenticate(c)
+	if err != nil {
+		if key != "" && errors.Is(err, authentication.ErrInvalidCredentials) {
+			h.recordFailure(c, ratelimit.EndpointValidate, c.ClientIP())
+		}
+		h.authenticationError(c, err)
+		return
+	}
+
+	if prefix, ok := apikey.Prefix(key); ok && !h.allow(c, ratelimit.EndpointValidate, ratelimit.DimensionAPIKey, prefix) {
+		return
+	}
+
+	for _, role := range c.QueryArray("role") {
+		if !identity.HasRole(role) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "missing required role " + role})
+			return
+		}
+	}
+
+	for _, scope := range c.QueryArray("scope") {
+		if !identity.HasScope(scope) {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "credentials are not valid for scope " + scope})
+			return
+		}
+	}
+
+	sensitive := c.Query("sensitive") == "true"
+	if identity.Impersonated() {
+		method, uri := c.GetHeader(forwardedMethodHeader), c.GetHeader(forwardedURIHeader)
+		if err := h.impersonations.RecordRequest(c.Request.Context(), identity, method, uri, sensitive); err != nil {
+			impersonationError(c, err)
+			return
+		}
+		if sensitive {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "not allowed while impersonating"})
+			return
+		}
+	}
+
+	header := c.Writer.Header()
+	header.Set(UserIDHeader, identity.Subject)
+	header.Set(UserRolesHeader, strings.Join(identity.Roles, ","))
+	if identity.TenantID != "" {
+		header.Set(TenantIDHeader, identity.TenantID)
+	}
+	if identity.SessionID != "" {
+		header.Set(SessionIDHeader, identity.SessionID)
+	}
+	if identity.Scopes != nil {
+		header.Set(ScopesHeader, strings.Join(identity.Scopes, ","))
+	}
+	if identity.Impersonated() {
+		header.Set(ImpersonatorIDHeader, identity.Actor.Subject)
+		header.Set(ImpersonationIDHeader, identity.TokenID)
+	}
+	header.Set("Cache-Control", "no-store")
+
+	c.Status(http.StatusOK)
+}
+
+// authenticate accepts API keys in their own header or, for clients that only
+// support bearer tokens, in the Authorization header
+func (h *Handler) authenticate(c *gin.Context) (*models.Identity, error) {
+	if key := apiKey(c); key != "" {
+		return h.auth.AuthenticateAPIKey(c.Request.Context(), key)
+	}
+
+	scheme, token, _ := strings.Cut(c.GetHeader("Authorization"), " ")
+	if !strings.EqualFold(scheme, "Bearer") {
+		return nil, authentication.ErrInvalidCredentials
+	}
+
+	return h.auth.AuthenticateToken(c.Request.Context(), strings.TrimSpace(token))
+}
+
+// apiKey returns the API key the request carries, or ""
+func apiKey(c *gin.Context) string {
+	if key := c.GetHeader(APIKeyHeader); key != "" {
+		return key
+	}
+
+	scheme, token, _ := strings.Cut(c.GetHeader("Authorization"), " ")
+	if token = strings.TrimSpace(token); strings.EqualFold(scheme, "Bearer") && apikey.IsAPIKey(token) {
+		return token
+	}
+	return ""
+}
+
+func (h *Handler) authenticationError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, authentication.ErrCredentialsExpired):
+		c.Header("WWW-Authenticate", `Bearer error="invalid_token", error_description="credentials expired"`)
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "credentials expired"})
+	case errors.Is(err, authentication.ErrInvalidCredentials):
+		c.Header("WWW-Authenticate", `Bearer error="invalid_token"`)
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid credentials"})
+	case errors.Is(err, authentication.ErrUnavailable):
+		observability.Logger(c.Request.Context()).Warn("credentials could not be verified", zap.Error(err))
+		c.AbortWithStatusJSON(http.StatusServiceUnavailable, gin.H{"error": "authentication temporarily unavailable"})
+	default:
+		observability.Logger(c.Request.Context()).Error("failed to authenticate request", zap.Error(err))
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+	}
+}
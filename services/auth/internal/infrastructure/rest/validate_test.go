@@ -0,0 +1,216 @@
+package rest
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/intellifinder/v4/services/auth/internal/domain/apikey"
+	"github.com/intellifinder/v4/services/auth/internal/domain/authentication"
+	"github.com/intellifinder/v4/services/auth/internal/domain/impersonation"
+	"github.com/intellifinder/v4/services/auth/internal/domain/ratelimit"
+	"github.com/intellifinder/v4/services/auth/internal/domain/revocation"
+	"github.com/intellifinder/v4/services/auth/internal/domain/session"
+	"github.com/intellifinder/v4/services/auth/internal/domain/user"
+	"github.com/intellifinder/v4/services/auth/internal/infrastructure/memory"
+	"github.com/intellifinder/v4/services/auth/pkg/models"
+)
+
+func init() {
+	gin.SetMode(gin.TestMode)
+}
+
+// verifier accepts the credentials in its identities map
+type verifier struct {
+	identities map[string]*models.Identity
+	err        error
+}
+
+func (v *verifier) verify(credential string) (*models.Identity, error) {
+	if v.err != nil {
+		return nil, v.err
+	}
+	if identity, ok := v.identities[credential]; ok {
+		return identity, nil
+	}
+	return nil, authentication.ErrInvalidCredentials
+}
+
+func (v *verifier) VerifyAccessToken(_ context.Context, token string) (*models.Identity, error) {
+	return v.verify(token)
+}
+
+func (v *verifier) VerifyAPIKey(_ context.Context, key string) (*models.Identity, error) {
+	return v.verify(key)
+}
+
+func newTestRouter(tokens *verifier, apiKeys *verifier) *gin.Engine {
+	service := authentication.NewService(tokens, 0)
+	if apiKeys != nil {
+		service.SetAPIKeyVerifier(apiKeys)
+	}
+	repo := memory.NewRepository()
+	sessions := session.NewService(repo, service, time.Hour)
+	return NewRouter(NewHandler(service, apikey.NewService(repo, owners{}, 0), user.NewService(repo), sessions, revocation.NewService(repo, time.Hour), ratelimit.NewService(repo), impersonation.NewService(repo, repo, time.Minute), nil, nil, nil))
+}
+
+func validate(router *gin.Engine, target string, header map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, target, nil)
+	for name, value := range header {
+		req.Header.Set(name, value)
+	}
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestValidate(t *testing.T) {
+	tokens := &verifier{identities: map[string]*models.Identity{
+		"alice-token": {
+			Subject:   "user-1",
+			Roles:     []string{"admin", "editor"},
+			TenantID:  "tenant-1",
+			SessionID: "session-1",
+			Method:    models.AuthMethodJWT,
+			ExpiresAt: time.Now().Add(time.Hour),
+		},
+	}}
+	apiKeys := &verifier{identities: map[string]*models.Identity{
+		"key-1": {Subject: "user-2", Roles: []string{"reader"}, Method: models.AuthMethodAPIKey},
+	}}
+	router := newTestRouter(tokens, apiKeys)
+
+	rec := validate(router, "/auth/validate", map[string]string{"Authorization": "Bearer alice-token"})
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Validate() status = %d, want 200", rec.Code)
+	}
+	want := map[string]string{
+		UserIDHeader:    "user-1",
+		UserRolesHeader: "admin,editor",
+		TenantIDHeader:  "tenant-1",
+		SessionIDHeader: "session-1",
+	}
+	for name, value := range want {
+		if got := rec.Header().Get(name); got != value {
+			t.Errorf("%s = %q, want %q", name, got, value)
+		}
+	}
+
+	rec = validate(router, "/auth/validate", map[string]string{APIKeyHeader: "key-1"})
+	if rec.Code != http.StatusOK || rec.Header().Get(UserIDHeader) != "user-2" || rec.Header().Get(TenantIDHeader) != "" {
+		t.Errorf("Validate() with API key = %d %v, want 200 for user-2 without tenant", rec.Code, rec.Header())
+	}
+}
+
+func TestValidateRejects(t *testing.T) {
+	tokens := &verifier{identities: map[string]*models.Identity{
+		"alice-token": {Subject: "user-1", Roles: []string{"editor"}},
+	}}
+	router := newTestRouter(tokens, nil)
+
+	tests := []struct {
+		name   string
+		target string
+		header map[string]string
+		want   int
+	}{
+		{"no credentials", "/auth/validate", nil, http.StatusUnauthorized},
+		{"unknown token", "/auth/validate", map[string]string{"Authorization": "Bearer other"}, http.StatusUnauthorized},
+		{"basic auth", "/auth/validate", map[string]string{"Authorization": "Basic YWxpY2U6c2VjcmV0"}, http.StatusUnauthorized},
+		{"API keys disabled", "/auth/validate", map[string]string{APIKeyHeader: "key-1"}, http.StatusUnauthorized},
+		{"missing role", "/auth/validate?role=editor&role=admin", map[string]string{"Authorization": "Bearer alice-token"}, http.StatusForbidden},
+		{"held role", "/auth/validate?role=editor", map[string]string{"Authorization": "Bearer alice-token"}, http.StatusOK},
+		{"unrestricted token", "/auth/validate?scope=tasks:write", map[string]string{"Authorization": "Bearer alice-token"}, http.StatusOK},
+		{"preflight", "/auth/validate?role=admin", map[string]string{forwardedMethodHeader: http.MethodOptions}, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := validate(router, tt.target, tt.header)
+			if rec.Code != tt.want {
+				t.Errorf("Validate() status = %d, want %d", rec.Code, tt.want)
+			}
+			if rec.Code == http.StatusUnauthorized && rec.Header().Get("WWW-Authenticate") == "" {
+				t.Errorf("Validate() 401 without WWW-Authenticate header")
+			}
+			if rec.Code != http.StatusOK && rec.Header().Get(UserIDHeader) != "" {
+				t.Errorf("Validate() rejected request with %s set", UserIDHeader)
+			}
+		})
+	}
+}
+
+func TestValidateVerifierErrors(t *testing.T) {
+	tests := map[error]int{
+		authentication.ErrCredentialsExpired:                       http.StatusUnauthorized,
+		fmt.Errorf("%w: keys down", authentication.ErrUnavailable): http.StatusServiceUnavailable,
+		fmt.Errorf("unexpected failure"):                           http.StatusInternalServerError,
+	}
+
+	for err, want := range tests {
+		router := newTestRouter(&verifier{err: err}, nil)
+		rec := validate(router, "/auth/validate", map[string]string{"Authorization": "Bearer token"})
+		if rec.Code != want {
+			t.Errorf("Validate() with verifier error %v status = %d, want %d", err, rec.Code, want)
+		}
+	}
+}
+
+func TestValidateImpersonation(t *testing.T) {
+	id := uuid.New()
+	tokens := &verifier{identities: map[string]*models.Identity{
+		"impersonation-token": {
+			Subject:   "user-1",
+			Roles:     []string{"user"},
+			TokenID:   id.String(),
+			Method:    models.AuthMethodJWT,
+			ExpiresAt: time.Now().Add(time.Minute),
+			Actor:     &models.Actor{Subject: "support-1", Username: "support"},
+		},
+	}}
+	repo := memory.NewRepository()
+	service := authentication.NewService(tokens, 0)
+	impersonations := impersonation.NewService(repo, repo, time.Minute)
+	router := NewRouter(NewHandler(service, apikey.NewService(repo, owners{}, 0), user.NewService(repo), session.NewService(repo, service, time.Hour), revocation.NewService(repo, time.Hour), ratelimit.NewService(repo), impersonations, nil, nil, nil))
+
+	header := map[string]string{
+		"Authorization":       "Bearer impersonation-token",
+		forwardedMethodHeader: http.MethodGet,
+		forwardedURIHeader:    "/api/tasks?page=2",
+	}
+	rec := validate(router, "/auth/validate", header)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Validate() status = %d, want 200", rec.Code)
+	}
+	want := map[string]string{
+		UserIDHeader:          "user-1",
+		ImpersonatorIDHeader:  "support-1",
+		ImpersonationIDHeader: id.String(),
+	}
+	for name, value := range want {
+		if got := rec.Header().Get(name); got != value {
+			t.Errorf("%s = %q, want %q", name, got, value)
+		}
+	}
+
+	header[forwardedMethodHeader] = http.MethodDelete
+	rec = validate(router, "/auth/validate?sensitive=true", header)
+	if rec.Code != http.StatusForbidden || rec.Header().Get(UserIDHeader) != "" {
+		t.Errorf("Validate() of sensitive route = %d %v, want 403 without identity", rec.Code, rec.Header())
+	}
+
+	events, err := repo.ListImpersonationEvents(context.Background(), id)
+	if err != nil {
+		t.Fatalf("ListImpersonationEvents() error = %v", err)
+	}
+	if len(events) != 2 ||
+		events[0].Type != models.ImpersonationRequest || events[0].Method != http.MethodGet || events[0].Path != "/api/tasks?page=2" ||
+		events[1].Type != models.ImpersonationBlocked || events[1].Method != http.MethodDelete {
+		t.Errorf("audit trail = %+v, want the request and the blocked action", events)
+	}
+}
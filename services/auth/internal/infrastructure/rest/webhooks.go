@@ -0,0 +1,51 @@
+package rest
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/intellifinder/v4/libs/observability"
+	"github.com/intellifinder/v4/services/auth/internal/domain/ingestion"
+	"go.uber.org/zap"
+)
+
+const (
+	// KeycloakSignatureHeader carries the HMAC-SHA256 of Keycloak webhook deliveries
+	KeycloakSignatureHeader = "X-Keycloak-Signature"
+	// maxWebhookBody bounds the size of a webhook delivery
+	maxWebhookBody = 1 << 20
+)
+
+// KeycloakWebhook ingests the admin and login events Keycloak delivers, so
+// changes made in its admin console reach the local users and sessions
+// without waiting for the next poll
+func (h *Handler) KeycloakWebhook(c *gin.Context) {
+	body, err := io.ReadAll(http.MaxBytesReader(c.Writer, c.Request.Body, maxWebhookBody))
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusRequestEntityTooLarge, gin.H{"error": "body too large"})
+		return
+	}
+
+	if err := h.ingestion.HandleWebhook(c.Request.Context(), body, c.GetHeader(KeycloakSignatureHeader)); err != nil {
+		webhookError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+func webhookError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, ingestion.ErrWebhookDisabled):
+		c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "webhook not enabled"})
+	case errors.Is(err, ingestion.ErrInvalidSignature):
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "invalid signature"})
+	case errors.Is(err, ingestion.ErrInvalidEvents):
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		observability.Logger(c.Request.Context()).Error("failed to ingest keycloak events", zap.Error(err))
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "internal error"})
+	}
+}
@@ -0,0 +1,27 @@
+package dto
+
+import (
+	"time"
+
+	"github.com/intellifinder/v4/services/auth/pkg/models"
+)
+
+// CreateAPIKeyRequest describes a new API key. Roles default to all roles of
+// the caller and the expiry to the service's maximum key lifetime.
+type CreateAPIKeyRequest struct {
+	Name      string     `json:"name" binding:"required,max=100"`
+	Scopes    []string   `json:"scopes"`
+	Roles     []string   `json:"roles"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// IssuedAPIKey is returned when a key is created or rotated. Key is the full
+// secret key and is never shown again.
+type IssuedAPIKey struct {
+	APIKey *models.APIKey `json:"api_key"`
+	Key    string         `json:"key"`
+}
+
+type APIKeyList struct {
+	APIKeys []*models.APIKey `json:"api_keys"`
+}
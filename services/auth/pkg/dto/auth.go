@@ -1 +1,29 @@
 package dto
+
+type LoginRequest struct {
+	Username string `json:"username" binding:"required,max=255"`
+	Password string `json:"password" binding:"required,max=1024"`
+}
+
+// RevokeTokenRequest names an access token to revoke
+type RevokeTokenRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+// RefreshRequest is used both to refresh tokens and to log out
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
+
+// ForgotPasswordRequest names the account to reset by username or email
+type ForgotPasswordRequest struct {
+	Login string `json:"login" binding:"required,max=255"`
+}
+
+// ServiceTokenRequest carries a service's client credentials. It binds from
+// JSON and from the form body of an OAuth client credentials request.
+type ServiceTokenRequest struct {
+	GrantType    string `json:"grant_type,omitempty" form:"grant_type" binding:"omitempty,eq=client_credentials"`
+	ClientID     string `json:"client_id" form:"client_id" binding:"required,max=255"`
+	ClientSecret string `json:"client_secret" form:"client_secret" binding:"required,max=1024"`
+}
@@ -0,0 +1,39 @@
+package dto
+
+import "github.com/intellifinder/v4/services/auth/pkg/models"
+
+// StartImpersonationRequest names the local user to act as and why, for the audit trail
+type StartImpersonationRequest struct {
+	UserID string `json:"user_id" binding:"required,uuid"`
+	Reason string `json:"reason" binding:"required,max=500"`
+}
+
+// StartedImpersonation is returned when an impersonation starts. AccessToken
+// acts as the target user until it expires or the impersonation is ended.
+type StartedImpersonation struct {
+	Impersonation *models.Impersonation `json:"impersonation"`
+	AccessToken   string                `json:"access_token"`
+	TokenType     string                `json:"token_type"`
+	ExpiresIn     int                   `json:"expires_in"`
+}
+
+// ImpersonationFilter narrows an impersonation listing; empty fields match everything
+type ImpersonationFilter struct {
+	ActorID  string
+	TargetID string
+	Limit    int
+}
+
+type ListImpersonationsQuery struct {
+	ActorID  string `form:"actor_id" binding:"max=255"`
+	TargetID string `form:"target_id" binding:"max=255"`
+	Limit    int    `form:"limit,default=50" binding:"min=1,max=200"`
+}
+
+type ImpersonationList struct {
+	Impersonations []*models.Impersonation `json:"impersonations"`
+}
+
+type ImpersonationEventList struct {
+	Events []*models.ImpersonationEvent `json:"events"`
+}
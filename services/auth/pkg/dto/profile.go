@@ -0,0 +1,17 @@
+package dto
+
+import "github.com/intellifinder/v4/services/auth/pkg/models"
+
+// PatchProfileRequest changes the fields of the local profile that are set.
+// Empty strings reset the display name, locale and time zone to their
+// defaults and remove the avatar; notification preferences are replaced as a whole.
+type PatchProfileRequest struct {
+	DisplayName     *string                         `json:"display_name" binding:"omitempty,max=255"`
+	AvatarURL       *string                         `json:"avatar_url" binding:"omitempty,max=2048"`
+	Locale          *string                         `json:"locale" binding:"omitempty,max=64"`
+	TimeZone        *string                         `json:"time_zone" binding:"omitempty,max=64"`
+	Notifications   *models.NotificationPreferences `json:"notifications"`
+	DefaultLocation *models.Location                `json:"default_location"`
+	// ClearDefaultLocation removes the default location
+	ClearDefaultLocation bool `json:"clear_default_location"`
+}
@@ -0,0 +1,111 @@
+package dto
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// SCIM 2.0 schema and message URNs (RFC 7643, RFC 7644)
+const (
+	SCIMUserSchema                  = "urn:ietf:params:scim:schemas:core:2.0:User"
+	SCIMGroupSchema                 = "urn:ietf:params:scim:schemas:core:2.0:Group"
+	SCIMServiceProviderConfigSchema = "urn:ietf:params:scim:schemas:core:2.0:ServiceProviderConfig"
+	SCIMResourceTypeSchema          = "urn:ietf:params:scim:schemas:core:2.0:ResourceType"
+	SCIMListResponseSchema          = "urn:ietf:params:scim:api:messages:2.0:ListResponse"
+	SCIMPatchOpSchema               = "urn:ietf:params:scim:api:messages:2.0:PatchOp"
+	SCIMErrorSchema                 = "urn:ietf:params:scim:api:messages:2.0:Error"
+)
+
+// SCIMMeta describes a resource. Version is its weak ETag.
+type SCIMMeta struct {
+	ResourceType string    `json:"resourceType"`
+	Created      time.Time `json:"created"`
+	LastModified time.Time `json:"lastModified"`
+	Location     string    `json:"location,omitempty"`
+	Version      string    `json:"version,omitempty"`
+}
+
+type SCIMName struct {
+	Formatted  string `json:"formatted,omitempty"`
+	FamilyName string `json:"familyName,omitempty"`
+	GivenName  string `json:"givenName,omitempty"`
+}
+
+// SCIMMultiValue is an element of a multi-valued attribute such as emails,
+// groups or members
+type SCIMMultiValue struct {
+	Value   string `json:"value"`
+	Display string `json:"display,omitempty"`
+	Type    string `json:"type,omitempty"`
+	Primary bool   `json:"primary,omitempty"`
+}
+
+// SCIMUser is a user resource. Only the attributes stored by Keycloak are
+// kept; others sent by identity providers are ignored. DisplayName is
+// derived from the name and groups are read-only.
+type SCIMUser struct {
+	Schemas     []string         `json:"schemas"`
+	ID          string           `json:"id,omitempty"`
+	ExternalID  string           `json:"externalId,omitempty"`
+	UserName    string           `json:"userName"`
+	Name        *SCIMName        `json:"name,omitempty"`
+	DisplayName string           `json:"displayName,omitempty"`
+	Emails      []SCIMMultiValue `json:"emails,omitempty"`
+	// Active defaults to true for new users and to unchanged for replaced ones
+	Active *bool            `json:"active,omitempty"`
+	Groups []SCIMMultiValue `json:"groups,omitempty"`
+	Meta   *SCIMMeta        `json:"meta,omitempty"`
+}
+
+// SCIMGroup is a group resource whose members are users
+type SCIMGroup struct {
+	Schemas     []string         `json:"schemas"`
+	ID          string           `json:"id,omitempty"`
+	ExternalID  string           `json:"externalId,omitempty"`
+	DisplayName string           `json:"displayName"`
+	Members     []SCIMMultiValue `json:"members,omitempty"`
+	Meta        *SCIMMeta        `json:"meta,omitempty"`
+}
+
+// SCIMListResponse is a page of query results. Resources are SCIMUsers or
+// SCIMGroups, reduced to the requested attributes.
+type SCIMListResponse struct {
+	Schemas      []string `json:"schemas"`
+	TotalResults int      `json:"totalResults"`
+	StartIndex   int      `json:"startIndex"`
+	ItemsPerPage int      `json:"itemsPerPage"`
+	Resources    []any    `json:"Resources"`
+}
+
+// SCIMListQuery are the query parameters of resource listings. StartIndex is
+// 1-based; Attributes and ExcludedAttributes are comma-separated.
+type SCIMListQuery struct {
+	Filter             string `form:"filter"`
+	StartIndex         int    `form:"startIndex,default=1"`
+	Count              int    `form:"count,default=100"`
+	Attributes         string `form:"attributes"`
+	ExcludedAttributes string `form:"excludedAttributes"`
+}
+
+// SCIMPatchRequest modifies a resource with a list of operations applied in order
+type SCIMPatchRequest struct {
+	Schemas    []string             `json:"schemas"`
+	Operations []SCIMPatchOperation `json:"Operations"`
+}
+
+// SCIMPatchOperation adds, replaces or removes the value at Path. Without a
+// path, Value is an object whose keys are the paths.
+type SCIMPatchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// SCIMError is the body of SCIM error responses. Status repeats the HTTP
+// status code as a string.
+type SCIMError struct {
+	Schemas  []string `json:"schemas"`
+	Status   string   `json:"status"`
+	SCIMType string   `json:"scimType,omitempty"`
+	Detail   string   `json:"detail,omitempty"`
+}
@@ -0,0 +1,14 @@
+package dto
+
+import "github.com/intellifinder/v4/services/auth/pkg/models"
+
+// Session is one of the caller's sessions. Current marks the session of the
+// access token the sessions were listed with.
+type Session struct {
+	*models.Session
+	Current bool `json:"current"`
+}
+
+type SessionList struct {
+	Sessions []Session `json:"sessions"`
+}
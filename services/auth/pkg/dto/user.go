@@ -1 +1,73 @@
 package dto
+
+import "github.com/intellifinder/v4/services/auth/pkg/models"
+
+// UserFilter narrows a user listing. Search matches usernames, emails and
+// names case-insensitively.
+type UserFilter struct {
+	Search         string
+	IncludeDeleted bool
+}
+
+type ListUsersQuery struct {
+	Search         string `form:"search" binding:"max=255"`
+	IncludeDeleted bool   `form:"include_deleted"`
+	Page           int32  `form:"page,default=1" binding:"min=1"`
+	Limit          int32  `form:"limit,default=20" binding:"min=1,max=100"`
+}
+
+type PaginatedUsers struct {
+	Users      []*models.User `json:"users"`
+	Page       int32          `json:"page"`
+	Limit      int32          `json:"limit"`
+	TotalCount int32          `json:"total_count"`
+	LastPage   int32          `json:"last_page"`
+}
+
+func NewPaginatedUsers(users []*models.User, page, limit, totalCount int32) *PaginatedUsers {
+	lastPage := int32(1)
+	if limit > 0 {
+		lastPage = (totalCount + limit - 1) / limit // Ceiling division
+		if lastPage == 0 {
+			lastPage = 1
+		}
+	}
+
+	return &PaginatedUsers{
+		Users:      users,
+		Page:       page,
+		Limit:      limit,
+		TotalCount: totalCount,
+		LastPage:   lastPage,
+	}
+}
+
+// CreateUserRequest creates a user in Keycloak with the given realm roles
+type CreateUserRequest struct {
+	Username  string   `json:"username" binding:"required,max=255"`
+	Email     string   `json:"email" binding:"required,email,max=255"`
+	FirstName string   `json:"first_name" binding:"max=255"`
+	LastName  string   `json:"last_name" binding:"max=255"`
+	Roles     []string `json:"roles"`
+}
+
+// UpdateUserRequest changes the fields that are set and leaves the others as they are
+type UpdateUserRequest struct {
+	Email     *string `json:"email" binding:"omitempty,email,max=255"`
+	FirstName *string `json:"first_name" binding:"omitempty,max=255"`
+	LastName  *string `json:"last_name" binding:"omitempty,max=255"`
+}
+
+// UpdateProfileRequest is the part of their profile users may change
+// themselves; changing the email address is left to Keycloak, which verifies it
+type UpdateProfileRequest struct {
+	FirstName *string `json:"first_name" binding:"omitempty,max=255"`
+	LastName  *string `json:"last_name" binding:"omitempty,max=255"`
+}
+
+// CurrentUser is the caller's profile together with what their token grants
+type CurrentUser struct {
+	*models.User
+	Roles    []string `json:"roles"`
+	TenantID string   `json:"tenant_id,omitempty"`
+}
@@ -6,12 +6,32 @@ import (
 	"github.com/google/uuid"
 )
 
+// APIKey is a long-lived credential for scripts and integrations. Only a
+// salted hash of its secret is stored; the full key is shown once when the
+// key is created or rotated.
 type APIKey struct {
-	ID        uuid.UUID `json:"id"`
-	Name      string    `json:"name"`
-	UserID    uuid.UUID `json:"user_id"`
-	Key       string    `json:"key"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-	Roles     []string  `json:"roles"`
+	ID   uuid.UUID `json:"id" db:"id"`
+	Name string    `json:"name" db:"name"`
+	// UserID is the Keycloak subject of the owner; requests made with the key
+	// act as that user
+	UserID   string `json:"user_id" db:"user_id"`
+	TenantID string `json:"tenant_id,omitempty" db:"tenant_id"`
+	// Prefix is the public part of the key that identifies it in lookups and listings
+	Prefix string `json:"prefix" db:"prefix"`
+	Salt   []byte `json:"-" db:"salt"`
+	Hash   []byte `json:"-" db:"hash"`
+	// Scopes restrict the permissions the key can be used for; Roles are
+	// granted to requests made with it and are a subset of the owner's roles
+	Scopes     []string   `json:"scopes" db:"scopes"`
+	Roles      []string   `json:"roles" db:"roles"`
+	ExpiresAt  *time.Time `json:"expires_at,omitempty" db:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty" db:"last_used_at"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty" db:"revoked_at"`
+	CreatedAt  time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+// Active reports whether the key can still be used at the given time
+func (k *APIKey) Active(now time.Time) bool {
+	return k.RevokedAt == nil && (k.ExpiresAt == nil || now.Before(*k.ExpiresAt))
 }
@@ -0,0 +1,56 @@
+package models
+
+import "time"
+
+// Kinds of directory events
+const (
+	// DirectoryUserChanged is a user created, updated or deleted in Keycloak
+	DirectoryUserChanged = "user_changed"
+	// DirectoryRolesChanged is a change to the roles or groups of a user
+	DirectoryRolesChanged = "roles_changed"
+	// DirectoryLogout is a session ended in Keycloak; without a SessionID
+	// every session of the user was ended
+	DirectoryLogout = "logout"
+)
+
+// DirectoryEvent is a change made directly in Keycloak, e.g. in its admin
+// console, that the local state has to follow
+type DirectoryEvent struct {
+	// ID is unique per event, however often it is delivered
+	ID         string
+	Type       string
+	UserID     string
+	SessionID  string
+	OccurredAt time.Time
+}
+
+// EventsChannel is the Redis pub/sub channel domain events are published on
+const EventsChannel = "auth:events"
+
+// Kinds of domain events
+const (
+	UserCreated      = "user.created"
+	UserUpdated      = "user.updated"
+	UserEnabled      = "user.enabled"
+	UserDisabled     = "user.disabled"
+	UserDeleted      = "user.deleted"
+	UserRolesChanged = "user.roles_changed"
+	// UserLockedOut is published when failed logins locked the user's
+	// account, so the user can be told
+	UserLockedOut = "user.locked_out"
+	// UserLoggedOut is published when every session of a user was ended
+	UserLoggedOut = "user.logged_out"
+	SessionEnded  = "session.ended"
+)
+
+// DomainEvent tells other services about a change to a user
+type DomainEvent struct {
+	ID   string `json:"id"`
+	Type string `json:"type"`
+	// UserID is the Keycloak subject of the user
+	UserID     string    `json:"user_id"`
+	SessionID  string    `json:"session_id,omitempty"`
+	OccurredAt time.Time `json:"occurred_at"`
+	// ExpiresAt is when the state the event reports ends, e.g. a lockout
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
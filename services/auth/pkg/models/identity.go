@@ -0,0 +1,61 @@
+package models
+
+import (
+	"slices"
+	"time"
+)
+
+// Authentication methods an Identity can be established with
+const (
+	AuthMethodJWT    = "jwt"
+	AuthMethodAPIKey = "api_key"
+)
+
+// Identity is the authenticated caller behind an access token or API key
+type Identity struct {
+	Subject  string `json:"subject"`
+	Username string `json:"username"`
+	// Email and the names are only known for access tokens
+	Email     string   `json:"email,omitempty"`
+	FirstName string   `json:"first_name,omitempty"`
+	LastName  string   `json:"last_name,omitempty"`
+	Roles     []string `json:"roles"`
+	// Scopes restrict what an API key may be used for; nil means unrestricted
+	Scopes    []string `json:"scopes,omitempty"`
+	TenantID  string   `json:"tenant_id,omitempty"`
+	SessionID string   `json:"session_id,omitempty"`
+	// TokenID is only known for access tokens. IssuedAt is when an access
+	// token was issued, or when an API key was verified.
+	TokenID  string    `json:"token_id,omitempty"`
+	IssuedAt time.Time `json:"issued_at"`
+	// ClientID is the Keycloak client an access token was issued to
+	ClientID  string    `json:"client_id,omitempty"`
+	Method    string    `json:"method"`
+	ExpiresAt time.Time `json:"expires_at"`
+	// Actor is the user acting as the subject, only set for impersonation
+	// tokens; their TokenID is the ID of the impersonation
+	Actor *Actor `json:"actor,omitempty"`
+	// ServiceAccount is set for access tokens a client got for itself with
+	// the client credentials grant
+	ServiceAccount bool `json:"service_account,omitempty"`
+}
+
+// Actor is the user behind an impersonation, the act claim of its token
+type Actor struct {
+	Subject  string `json:"subject"`
+	Username string `json:"username"`
+}
+
+func (i *Identity) HasRole(role string) bool {
+	return slices.Contains(i.Roles, role)
+}
+
+// Impersonated reports whether the identity is used by someone else
+func (i *Identity) Impersonated() bool {
+	return i.Actor != nil
+}
+
+// HasScope reports whether the identity may be used for the given scope
+func (i *Identity) HasScope(scope string) bool {
+	return i.Scopes == nil || slices.Contains(i.Scopes, scope)
+}
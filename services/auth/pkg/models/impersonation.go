@@ -0,0 +1,49 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Kinds of impersonation audit events
+const (
+	ImpersonationStarted = "started"
+	ImpersonationEnded   = "ended"
+	// ImpersonationRequest is a request made while impersonating
+	ImpersonationRequest = "request"
+	// ImpersonationBlocked is a sensitive action refused while impersonating
+	ImpersonationBlocked = "blocked"
+)
+
+// Impersonation is a period in which a support user acts as another user.
+// Its ID is the ID of the access token issued for it.
+type Impersonation struct {
+	ID uuid.UUID `json:"id" db:"id"`
+	// ActorID and TargetID are Keycloak subjects
+	ActorID        string     `json:"actor_id" db:"actor_id"`
+	ActorUsername  string     `json:"actor_username" db:"actor_username"`
+	TargetID       string     `json:"target_id" db:"target_id"`
+	TargetUsername string     `json:"target_username" db:"target_username"`
+	TenantID       string     `json:"tenant_id,omitempty" db:"tenant_id"`
+	Reason         string     `json:"reason" db:"reason"`
+	StartedAt      time.Time  `json:"started_at" db:"started_at"`
+	ExpiresAt      time.Time  `json:"expires_at" db:"expires_at"`
+	EndedAt        *time.Time `json:"ended_at,omitempty" db:"ended_at"`
+}
+
+// Active reports whether the impersonation's token can still be used at the given time
+func (i *Impersonation) Active(now time.Time) bool {
+	return i.EndedAt == nil && now.Before(i.ExpiresAt)
+}
+
+// ImpersonationEvent is an entry in the audit trail of an impersonation
+type ImpersonationEvent struct {
+	ID              uuid.UUID `json:"id" db:"id"`
+	ImpersonationID uuid.UUID `json:"impersonation_id" db:"impersonation_id"`
+	Type            string    `json:"type" db:"type"`
+	// Method and Path are only set for requests and blocked actions
+	Method    string    `json:"method,omitempty" db:"method"`
+	Path      string    `json:"path,omitempty" db:"path"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
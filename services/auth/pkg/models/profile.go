@@ -0,0 +1,59 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Notification digest frequencies
+const (
+	DigestOff    = "off"
+	DigestDaily  = "daily"
+	DigestWeekly = "weekly"
+)
+
+// Profile is what the task platform shows of a local user and how it reaches
+// them. Unlike the names and email in User it's kept by the auth service
+// alone and never written to Keycloak.
+type Profile struct {
+	UserID      uuid.UUID `json:"user_id" db:"user_id"`
+	DisplayName string    `json:"display_name" db:"display_name"`
+	AvatarURL   string    `json:"avatar_url,omitempty" db:"avatar_url"`
+	// Locale is a BCP 47 language tag, e.g. en-GB
+	Locale string `json:"locale" db:"locale"`
+	// TimeZone is an IANA time zone name, e.g. Europe/Berlin
+	TimeZone        string                  `json:"time_zone" db:"time_zone"`
+	Notifications   NotificationPreferences `json:"notifications" db:"notifications"`
+	DefaultLocation *Location               `json:"default_location,omitempty" db:"default_location"`
+	// UpdatedAt is unset for users who never changed their profile, whose
+	// profile is made of defaults
+	UpdatedAt *time.Time `json:"updated_at,omitempty" db:"updated_at"`
+}
+
+// NotificationPreferences are the channels a user wants to be notified on
+type NotificationPreferences struct {
+	Email bool `json:"email"`
+	Push  bool `json:"push"`
+	SMS   bool `json:"sms"`
+	// Digest is how often a summary of activity is emailed: DigestOff,
+	// DigestDaily or DigestWeekly
+	Digest string `json:"digest"`
+}
+
+// Location is a place tasks are created at unless another one is given
+type Location struct {
+	Label     string  `json:"label,omitempty"`
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+// Preferences are the settings a client app keeps for a user, as a JSON
+// document valid against the app's schema
+type Preferences struct {
+	UserID    uuid.UUID       `json:"user_id" db:"user_id"`
+	ClientID  string          `json:"client_id" db:"client_id"`
+	Data      json.RawMessage `json:"data" db:"data"`
+	UpdatedAt time.Time       `json:"updated_at" db:"updated_at"`
+}
@@ -0,0 +1,33 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// SCIMUser records that a tenant provisioned a local user through SCIM.
+// Tenants can only see and change the users they provisioned.
+type SCIMUser struct {
+	UserID   uuid.UUID `json:"user_id" db:"user_id"`
+	TenantID string    `json:"tenant_id" db:"tenant_id"`
+	// ExternalID is the user's ID in the tenant's identity provider
+	ExternalID string    `json:"external_id,omitempty" db:"external_id"`
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// SCIMGroup is a group a tenant provisioned through SCIM. It's mirrored by
+// a Keycloak group, whose role mappings apply to the members.
+type SCIMGroup struct {
+	ID       uuid.UUID `json:"id" db:"id"`
+	TenantID string    `json:"tenant_id" db:"tenant_id"`
+	// DirectoryID is the ID of the Keycloak group
+	DirectoryID string `json:"directory_id" db:"directory_id"`
+	DisplayName string `json:"display_name" db:"display_name"`
+	ExternalID  string `json:"external_id,omitempty" db:"external_id"`
+	// Members are local user IDs, sorted
+	Members   []uuid.UUID `json:"members" db:"-"`
+	CreatedAt time.Time   `json:"created_at" db:"created_at"`
+	UpdatedAt time.Time   `json:"updated_at" db:"updated_at"`
+}
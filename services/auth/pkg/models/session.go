@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// Session is a user's login on one device. Clients only ever see the
+// service's own refresh tokens for it, which are rotated on every use; the
+// upstream Keycloak tokens stay in the service.
+type Session struct {
+	// ID is the Keycloak session ID, the sid claim of the session's access tokens
+	ID     string `json:"id"`
+	UserID string `json:"user_id"`
+	// Device is the User-Agent the session was started from
+	Device string `json:"device"`
+	// IPAddress is the address the session was last used from
+	IPAddress  string    `json:"ip_address"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+
+	// Generation counts the rotations of the refresh token; only the
+	// refresh token of the current generation can be used
+	Generation int `json:"-"`
+	// Key signs the session's refresh tokens
+	Key []byte `json:"-"`
+	// UpstreamRefreshToken is Keycloak's refresh token for the session
+	UpstreamRefreshToken string `json:"-"`
+}
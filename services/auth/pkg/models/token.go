@@ -1,11 +1,11 @@
 package models
 
-import "time"
-
-type Token struct {
-	TokenType        string    `json:"token_type"`
-	Token            string    `json:"token"`
-	ExpiresAt        time.Time `json:"expires_at"`
-	RefreshToken     string    `json:"refresh_token"`
-	ExpiresAtRefresh time.Time `json:"expires_at_refresh"`
+// TokenSet is what a login or refresh returns. Lifetimes are in seconds, as
+// in OAuth 2.0 token responses.
+type TokenSet struct {
+	AccessToken      string `json:"access_token"`
+	RefreshToken     string `json:"refresh_token"`
+	TokenType        string `json:"token_type"`
+	ExpiresIn        int    `json:"expires_in"`
+	RefreshExpiresIn int    `json:"refresh_expires_in"`
 }
@@ -1,16 +1,39 @@
 package models
 
 import (
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+// ServiceAccountPrefix starts the username Keycloak gives the service account
+// of every client
+const ServiceAccountPrefix = "service-account-"
+
+// ReservedUsername reports whether username can only belong to a service
+// account. Users must not be created with such names, or their tokens could
+// pass for a service's.
+func ReservedUsername(username string) bool {
+	return strings.HasPrefix(strings.ToLower(username), ServiceAccountPrefix)
+}
+
+// User is the local copy of a Keycloak user. ID is stable for the lifetime
+// of the user and is what other services should reference; OIDCID is the
+// Keycloak subject.
 type User struct {
-	ID        uuid.UUID `json:"id"`
-	OIDCID    string    `json:"oidc_id"`
-	Username  string    `json:"username"`
-	Email     string    `json:"email"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID        uuid.UUID `json:"id" db:"id"`
+	OIDCID    string    `json:"oidc_id" db:"oidc_id"`
+	Username  string    `json:"username" db:"username"`
+	Email     string    `json:"email" db:"email"`
+	FirstName string    `json:"first_name" db:"first_name"`
+	LastName  string    `json:"last_name" db:"last_name"`
+	Enabled   bool      `json:"enabled" db:"enabled"`
+	// LastLoginAt is when a token of the user was last verified, to within a minute
+	LastLoginAt *time.Time `json:"last_login_at,omitempty" db:"last_login_at"`
+	CreatedAt   time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time  `json:"updated_at" db:"updated_at"`
+	// DeletedAt is set once the user no longer exists in Keycloak. Deleted
+	// users are kept so references to their ID can still be resolved.
+	DeletedAt *time.Time `json:"deleted_at,omitempty" db:"deleted_at"`
 }
@@ -13,6 +13,7 @@ var authPermissions = []string{
 	"auth:forgot-password",
 	"auth:create-api-key",
 	"auth:delete-api-key",
+	"auth:impersonate",
 }
 
 func init() {
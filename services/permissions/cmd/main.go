@@ -2,51 +2,146 @@ package main
 
 import (
 	"context"
-	"intellifinder/libs/utils"
+	"fmt"
+	"intellifinder/libs/utils/config"
 	permissionsv1 "intellifinder/services/permissions/api/v1"
 	"intellifinder/services/permissions/internal/domain/permissions"
 	"intellifinder/services/permissions/internal/infrastructure/database"
 	grpcServer "intellifinder/services/permissions/internal/infrastructure/grpc"
 	"log"
 	"net"
+	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
+	"time"
 
+	serviceauth "github.com/intellifinder/v4/libs/auth"
+	"github.com/intellifinder/v4/libs/observability"
 	"github.com/jackc/pgx/v5/pgxpool"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/reflection"
 )
 
+// reflectionServices can be called without a service token
+var reflectionServices = []string{
+	"/grpc.reflection.v1.ServerReflection/",
+	"/grpc.reflection.v1alpha.ServerReflection/",
+}
+
 func main() {
 	// Load configuration
-	config := loadConfig()
-	log.Println("Loaded configurations.")
+	cfg := loadConfig()
+
+	logger, err := observability.NewLogger("permissions", cfg.LogLevel)
+	if err != nil {
+		log.Fatalf("failed to create logger: %v", err)
+	}
+	defer logger.Sync()
+
+	logger.Info("Loaded configurations.", zap.Any("config", config.Redact(cfg)))
 
 	// Create context with cancellation
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
 	// Create pgx connection pool
-	db, err := pgxpool.New(ctx, config.DatabaseURL)
+	db, err := pgxpool.New(ctx, cfg.DatabaseURL)
 	if err != nil {
-		log.Fatalf("failed to create database pool: %v", err)
+		logger.Fatal("failed to create database pool", zap.Error(err))
 	}
 	defer db.Close()
 
 	// Run migration
 	err = database.CreatePermissionTable(ctx, db)
 	if err != nil {
-		log.Fatalf("failed to create permission table: %v", err)
+		logger.Fatal("failed to create permission table", zap.Error(err))
 	}
 
-	log.Println("Migration completed successfully!")
+	err = database.CreateRoleTables(ctx, db)
+	if err != nil {
+		logger.Fatal("failed to create role tables", zap.Error(err))
+	}
+
+	err = database.CreateUsageTables(ctx, db)
+	if err != nil {
+		logger.Fatal("failed to create usage tables", zap.Error(err))
+	}
+
+	err = database.CreateReviewTables(ctx, db)
+	if err != nil {
+		logger.Fatal("failed to create review tables", zap.Error(err))
+	}
+
+	logger.Info("Migration completed successfully!")
 
 	repo := database.NewPermissionRepository(db)
 	service := permissions.NewService(repo)
-	permissionServer := grpcServer.NewPermissionServer(service)
 
-	grpcServer := grpc.NewServer()
+	// Subcommands such as "export" and "import" run once and exit
+	if len(os.Args) > 1 {
+		if err := runCommand(ctx, service, os.Args[1], os.Args[2:]); err != nil {
+			logger.Fatal("command failed", zap.String("command", os.Args[1]), zap.Error(err))
+		}
+		return
+	}
+
+	// Usage of checked permissions is written in the background; Close flushes
+	// what is pending once the server has stopped taking requests
+	usage := permissions.NewUsageRecorder(repo, cfg.UsageFlushInterval, cfg.UsageBatchSize, func(err error) {
+		logger.Warn("failed to record permission usage", zap.Error(err))
+	})
+	usage.Start()
+	defer usage.Close()
+	service.SetUsageRecorder(usage)
+
+	// Review reports can only be exported when a signing key is configured
+	if cfg.ReviewSigningKey != "" {
+		key, err := permissions.ParseReportSigningKey(cfg.ReviewSigningKey)
+		if err != nil {
+			logger.Fatal("invalid review signing key", zap.Error(err))
+		}
+		service.SetReportSigningKey(key)
+	}
+
+	go closeExpiredReviews(ctx, service, cfg.ReviewExpiryInterval, logger)
+
+	permissionServer := grpcServer.NewPermissionServer(service, cfg.RolesDir)
+	permissionServer.SetAdminServices(cfg.AdminServices...)
+
+	// Review items are decided with the reviewer's own access token, verified
+	// like service tokens but against the audience of user tokens
+	tokenClient := &http.Client{Timeout: 10 * time.Second}
+	if cfg.UserTokenAudience != "" {
+		permissionServer.SetUserVerifier(serviceauth.NewVerifier(cfg.ServiceTokenIssuer, cfg.UserTokenAudience, cfg.serviceTokenJWKSURL(), tokenClient))
+	}
+
+	// Role files may reference permissions of services that haven't registered
+	// yet; the sync is retried whenever a service registers its permissions.
+	if cfg.RolesDir != "" {
+		if err := permissionServer.SyncRoleDir(ctx); err != nil {
+			logger.Warn("failed to sync role files", zap.String("dir", cfg.RolesDir), zap.Error(err))
+		} else {
+			logger.Info("Role files synced", zap.String("dir", cfg.RolesDir))
+		}
+	}
+
+	// Every call must carry a service token, so handlers know which service
+	// is calling
+	serviceVerifier := serviceauth.NewVerifier(cfg.ServiceTokenIssuer, cfg.ServiceTokenAudience, cfg.serviceTokenJWKSURL(), tokenClient)
+	grpcServer := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(
+			observability.UnaryServerLogging(logger),
+			serviceauth.UnaryServerInterceptor(serviceVerifier, reflectionServices...),
+		),
+		grpc.ChainStreamInterceptor(
+			observability.StreamServerLogging(logger),
+			serviceauth.StreamServerInterceptor(serviceVerifier, reflectionServices...),
+		),
+	)
 	permissionsv1.RegisterPermissionServiceServer(grpcServer, permissionServer)
 
 	// Enable gRPC reflection for easy endpoint inspection
@@ -58,30 +153,109 @@ func main() {
 
 	go func() {
 		<-sigChan
-		log.Println("Shutting down gracefully...")
+		logger.Info("Shutting down gracefully...")
 		grpcServer.GracefulStop()
 		cancel()
 	}()
 
-	listen, err := net.Listen("tcp", ":"+config.Port)
+	listen, err := net.Listen("tcp", ":"+cfg.Port)
 	if err != nil {
-		log.Fatalf("failed to listen: %v", err)
+		logger.Fatal("failed to listen", zap.Error(err))
 	}
 
-	log.Printf("Server is running on port %s", config.Port)
+	logger.Info("Server is running", zap.String("port", cfg.Port))
 	if err := grpcServer.Serve(listen); err != nil {
-		log.Fatalf("failed to serve: %v", err)
+		usage.Close()
+		logger.Fatal("failed to serve", zap.Error(err))
 	}
 }
 
-func loadConfig() *Config {
-	return &Config{
-		Port:        utils.GetEnv("PORT", "8080"),
-		DatabaseURL: utils.GetEnv("DATABASE_URL", ""),
+// closeExpiredReviews auto-revokes the pending items of campaigns past their deadline
+func closeExpiredReviews(ctx context.Context, service *permissions.Service, interval time.Duration, logger *zap.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			revoked, err := service.CloseExpiredReviewCampaigns(ctx, now)
+			if err != nil {
+				logger.Warn("failed to close expired review campaigns", zap.Error(err))
+			}
+			if revoked > 0 {
+				logger.Info("Expired review items auto-revoked", zap.Int("revoked", revoked))
+			}
+		}
 	}
 }
 
+// loadConfig exits with a list of every invalid setting instead of starting half-configured
+func loadConfig() *Config {
+	cfg := &Config{}
+	config.MustLoad(cfg)
+	return cfg
+}
+
 type Config struct {
-	Port        string
-	DatabaseURL string
+	Port        string `env:"PORT" yaml:"port" default:"8080"`
+	DatabaseURL string `env:"DATABASE_URL" yaml:"database_url" required:"true" secret:"true"`
+	LogLevel    string `env:"LOG_LEVEL" yaml:"log_level" default:"info"`
+	RolesDir    string `env:"ROLES_DIR" yaml:"roles_dir"`
+
+	UsageFlushInterval time.Duration `env:"USAGE_FLUSH_INTERVAL" yaml:"usage_flush_interval" default:"10s"`
+	UsageBatchSize     int           `env:"USAGE_BATCH_SIZE" yaml:"usage_batch_size" default:"1000"`
+
+	// Base64-encoded 32 byte Ed25519 seed used to sign access review reports
+	ReviewSigningKey     string        `env:"REVIEW_SIGNING_KEY" yaml:"review_signing_key" secret:"true"`
+	ReviewExpiryInterval time.Duration `env:"REVIEW_EXPIRY_INTERVAL" yaml:"review_expiry_interval" default:"1m"`
+
+	// Issuer of the service tokens callers must send, e.g.
+	// http://keycloak:8080/realms/intellifinder
+	ServiceTokenIssuer string `env:"SERVICE_TOKEN_ISSUER" yaml:"service_token_issuer" required:"true"`
+	// Callers' Keycloak clients need an audience mapper adding it to their tokens
+	ServiceTokenAudience string `env:"SERVICE_TOKEN_AUDIENCE" yaml:"service_token_audience" default:"permissions"`
+	// Defaults to the issuer's Keycloak certs endpoint
+	ServiceTokenJWKSURL string `env:"SERVICE_TOKEN_JWKS_URL" yaml:"service_token_jwks_url"`
+	// Client IDs of the services that may change permissions, roles,
+	// assignments and review campaigns
+	AdminServices []string `env:"ADMIN_SERVICES" yaml:"admin_services" default:"permctl"`
+	// Audience of the users' access tokens, which reviewers send to decide
+	// review items; without it no item can be decided
+	UserTokenAudience string `env:"USER_TOKEN_AUDIENCE" yaml:"user_token_audience"`
+}
+
+// serviceTokenJWKSURL returns where the signing keys of service tokens are served
+func (c *Config) serviceTokenJWKSURL() string {
+	if c.ServiceTokenJWKSURL != "" {
+		return c.ServiceTokenJWKSURL
+	}
+	return strings.TrimSuffix(c.ServiceTokenIssuer, "/") + "/protocol/openid-connect/certs"
+}
+
+func (c *Config) Validate() error {
+	if _, err := zapcore.ParseLevel(c.LogLevel); err != nil {
+		return fmt.Errorf("LOG_LEVEL: %w", err)
+	}
+
+	if c.UsageFlushInterval <= 0 {
+		return fmt.Errorf("USAGE_FLUSH_INTERVAL must be positive")
+	}
+
+	if c.UsageBatchSize <= 0 {
+		return fmt.Errorf("USAGE_BATCH_SIZE must be positive")
+	}
+
+	if c.ReviewExpiryInterval <= 0 {
+		return fmt.Errorf("REVIEW_EXPIRY_INTERVAL must be positive")
+	}
+
+	if c.ReviewSigningKey != "" {
+		if _, err := permissions.ParseReportSigningKey(c.ReviewSigningKey); err != nil {
+			return fmt.Errorf("REVIEW_SIGNING_KEY: %w", err)
+		}
+	}
+
+	return nil
 }
@@ -0,0 +1,44 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConfigValidate(t *testing.T) {
+	valid := func() *Config {
+		return &Config{LogLevel: "info", UsageFlushInterval: 10 * time.Second, UsageBatchSize: 1000, ReviewExpiryInterval: time.Minute}
+	}
+
+	tests := []struct {
+		name    string
+		modify  func(*Config)
+		wantErr string
+	}{
+		{name: "defaults", modify: func(*Config) {}},
+		{name: "debug level", modify: func(c *Config) { c.LogLevel = "debug" }},
+		{name: "unknown log level", modify: func(c *Config) { c.LogLevel = "verbose" }, wantErr: "LOG_LEVEL"},
+		{name: "no flush interval", modify: func(c *Config) { c.UsageFlushInterval = 0 }, wantErr: "USAGE_FLUSH_INTERVAL"},
+		{name: "no batch size", modify: func(c *Config) { c.UsageBatchSize = 0 }, wantErr: "USAGE_BATCH_SIZE"},
+		{name: "no expiry interval", modify: func(c *Config) { c.ReviewExpiryInterval = 0 }, wantErr: "REVIEW_EXPIRY_INTERVAL"},
+		{name: "invalid signing key", modify: func(c *Config) { c.ReviewSigningKey = "not a key" }, wantErr: "REVIEW_SIGNING_KEY"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := valid()
+			tt.modify(cfg)
+			err := cfg.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Validate() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.HasPrefix(err.Error(), tt.wantErr) {
+				t.Errorf("Validate() error = %v, want a %s error", err, tt.wantErr)
+			}
+		})
+	}
+}
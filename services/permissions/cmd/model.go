@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"intellifinder/services/permissions/internal/domain/permissions"
+	"intellifinder/services/permissions/pkg/dto"
+	"io"
+	"os"
+)
+
+// runCommand executes a one-off subcommand against the database instead of starting the server
+func runCommand(ctx context.Context, service *permissions.Service, name string, args []string) error {
+	switch name {
+	case "export":
+		return runExport(ctx, service, args)
+	case "import":
+		return runImport(ctx, service, args)
+	default:
+		return fmt.Errorf("unknown command %q, expected \"export\" or \"import\"", name)
+	}
+}
+
+func runExport(ctx context.Context, service *permissions.Service, args []string) error {
+	flags := flag.NewFlagSet("export", flag.ContinueOnError)
+	format := flags.String("format", permissions.FormatYAML, "output format (yaml or json)")
+	output := flags.String("o", "", "write the document to this file instead of stdout")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	model, err := service.ExportModel(ctx)
+	if err != nil {
+		return err
+	}
+
+	document, err := permissions.EncodeModel(model, *format)
+	if err != nil {
+		return err
+	}
+
+	if *output == "" {
+		_, err = os.Stdout.Write(document)
+		return err
+	}
+	return os.WriteFile(*output, document, 0o644)
+}
+
+func runImport(ctx context.Context, service *permissions.Service, args []string) error {
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	input := flags.String("f", "", "document to import (- for stdin)")
+	dryRun := flags.Bool("dry-run", false, "print the diff without applying it")
+	prune := flags.Bool("prune", false, "remove permissions, API-managed roles and assignments that are not in the document")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if *input == "" {
+		return fmt.Errorf("-f is required")
+	}
+
+	var document []byte
+	var err error
+	if *input == "-" {
+		document, err = io.ReadAll(os.Stdin)
+	} else {
+		document, err = os.ReadFile(*input)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read document: %w", err)
+	}
+
+	model, err := permissions.DecodeModel(document)
+	if err != nil {
+		return err
+	}
+
+	diff, err := service.ImportModel(ctx, model, permissions.ImportOptions{
+		DryRun: *dryRun,
+		Prune:  *prune,
+	})
+	if err != nil {
+		return err
+	}
+
+	printDiff(os.Stdout, diff)
+	return nil
+}
+
+func printDiff(w io.Writer, diff *dto.ModelDiff) {
+	for _, permission := range diff.Added {
+		fmt.Fprintf(w, "+ %s\n", permission)
+	}
+	for _, permission := range diff.Removed {
+		fmt.Fprintf(w, "- %s\n", permission)
+	}
+	for _, alias := range diff.AliasesAdded {
+		fmt.Fprintf(w, "+ alias %s\n", alias)
+	}
+	for _, permission := range diff.DeprecationsAdded {
+		fmt.Fprintf(w, "+ deprecation %s\n", permission)
+	}
+	for _, permission := range diff.DeprecationsRemoved {
+		fmt.Fprintf(w, "- deprecation %s\n", permission)
+	}
+	for _, name := range diff.RolesAdded {
+		fmt.Fprintf(w, "+ role %s\n", name)
+	}
+	for _, name := range diff.RolesUpdated {
+		fmt.Fprintf(w, "~ role %s\n", name)
+	}
+	for _, name := range diff.RolesRemoved {
+		fmt.Fprintf(w, "- role %s\n", name)
+	}
+	for _, assignment := range diff.AssignmentsAdded {
+		fmt.Fprintf(w, "+ assignment %s\n", assignment)
+	}
+	for _, assignment := range diff.AssignmentsRemoved {
+		fmt.Fprintf(w, "- assignment %s\n", assignment)
+	}
+	fmt.Fprintf(w, "permissions: %d added, %d removed, %d unchanged\n", len(diff.Added), len(diff.Removed), diff.Unchanged)
+	fmt.Fprintf(w, "aliases: %d added, %d unchanged\n", len(diff.AliasesAdded), diff.AliasesUnchanged)
+	fmt.Fprintf(w, "deprecations: %d added, %d removed, %d unchanged\n",
+		len(diff.DeprecationsAdded), len(diff.DeprecationsRemoved), diff.DeprecationsUnchanged)
+	fmt.Fprintf(w, "roles: %d added, %d updated, %d removed, %d unchanged\n",
+		len(diff.RolesAdded), len(diff.RolesUpdated), len(diff.RolesRemoved), diff.RolesUnchanged)
+	fmt.Fprintf(w, "assignments: %d added, %d removed, %d unchanged\n",
+		len(diff.AssignmentsAdded), len(diff.AssignmentsRemoved), diff.AssignmentsUnchanged)
+	if !diff.Applied {
+		fmt.Fprintln(w, "No changes applied.")
+	}
+}
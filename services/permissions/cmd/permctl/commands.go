@@ -0,0 +1,887 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	permissionsv1 "intellifinder/services/permissions/api/v1"
+	"intellifinder/services/permissions/pkg/dto"
+	"intellifinder/services/permissions/pkg/models"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// pageSize is the page size used when a command walks a whole listing
+const pageSize = 100
+
+type cli struct {
+	client permissionsv1.PermissionServiceClient
+	out    *printer
+}
+
+type role struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description"`
+	Permissions []string `json:"permissions"`
+	ReadOnly    bool     `json:"read_only"`
+	Source      string   `json:"source,omitempty"`
+}
+
+type permission struct {
+	Name       string `json:"permission"`
+	Deprecated bool   `json:"deprecated"`
+}
+
+type alias struct {
+	Alias      string    `json:"alias"`
+	Permission string    `json:"permission"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+type assignment struct {
+	Subject   string    `json:"subject"`
+	Role      string    `json:"role"`
+	Tenant    string    `json:"tenant"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+func (c *cli) dispatch(ctx context.Context, args []string) error {
+	command, args := args[0], args[1:]
+
+	switch command {
+	case "permissions":
+		return c.subcommand(ctx, command, args, map[string]func(context.Context, []string) error{
+			"list":      c.listPermissions,
+			"search":    c.searchPermissions,
+			"deprecate": c.deprecatePermission,
+			"delete":    c.deletePermission,
+			"alias":     c.createAlias,
+			"aliases":   c.listAliases,
+		})
+	case "roles":
+		return c.subcommand(ctx, command, args, map[string]func(context.Context, []string) error{
+			"list":   c.listRoles,
+			"get":    c.getRole,
+			"create": c.createRole,
+			"update": c.updateRole,
+			"delete": c.deleteRole,
+			"sync":   c.syncRoles,
+		})
+	case "assignments":
+		return c.subcommand(ctx, command, args, map[string]func(context.Context, []string) error{
+			"list":   c.listAssignments,
+			"add":    c.assignRole,
+			"remove": c.unassignRole,
+		})
+	case "model":
+		return c.subcommand(ctx, command, args, map[string]func(context.Context, []string) error{
+			"export": c.exportModel,
+			"import": c.importModel,
+		})
+	case "reviews":
+		return c.subcommand(ctx, command, args, map[string]func(context.Context, []string) error{
+			"create":  c.createReview,
+			"list":    c.listReviews,
+			"items":   c.listReviewItems,
+			"approve": c.approveReviewItem,
+			"revoke":  c.revokeReviewItem,
+			"report":  c.exportReviewReport,
+		})
+	case "usage":
+		return c.usage(ctx, args)
+	case "check":
+		return c.check(ctx, args)
+	case "explain":
+		return c.explain(ctx, args)
+	default:
+		return fmt.Errorf("unknown command %q, run \"permctl help\" for usage", command)
+	}
+}
+
+func (c *cli) subcommand(ctx context.Context, command string, args []string, handlers map[string]func(context.Context, []string) error) error {
+	if len(args) == 0 {
+		return fmt.Errorf("%s requires a subcommand", command)
+	}
+
+	handler, ok := handlers[args[0]]
+	if !ok {
+		return fmt.Errorf("unknown subcommand %q for %s", args[0], command)
+	}
+	return handler(ctx, args[1:])
+}
+
+// parseArgs parses flags that may appear before, between or after the positional arguments
+// and checks that exactly want positional arguments were given
+func parseArgs(flags *flag.FlagSet, args []string, want int) ([]string, error) {
+	var positional []string
+	for {
+		if err := flags.Parse(args); err != nil {
+			return nil, err
+		}
+		if flags.NArg() == 0 {
+			break
+		}
+		positional = append(positional, flags.Arg(0))
+		args = flags.Args()[1:]
+	}
+
+	if want >= 0 && len(positional) != want {
+		return nil, fmt.Errorf("%s expects %d argument(s), got %d", flags.Name(), want, len(positional))
+	}
+	return positional, nil
+}
+
+// stringList collects a repeatable string flag
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+func (c *cli) listPermissions(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("permissions list", flag.ContinueOnError)
+	service := flags.String("service", "", "only list permissions of this service")
+	if _, err := parseArgs(flags, args, 0); err != nil {
+		return err
+	}
+
+	permissions, err := c.allPermissions(ctx, *service)
+	if err != nil {
+		return err
+	}
+	return c.printPermissions(permissions)
+}
+
+func (c *cli) searchPermissions(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("permissions search", flag.ContinueOnError)
+	service := flags.String("service", "", "only search permissions of this service")
+	positional, err := parseArgs(flags, args, 1)
+	if err != nil {
+		return err
+	}
+
+	permissions, err := c.allPermissions(ctx, *service)
+	if err != nil {
+		return err
+	}
+
+	query := strings.ToLower(positional[0])
+	matches := []permission{}
+	for _, perm := range permissions {
+		if strings.Contains(strings.ToLower(perm.Name), query) {
+			matches = append(matches, perm)
+		}
+	}
+	return c.printPermissions(matches)
+}
+
+// allPermissions walks every page of the permission listing
+func (c *cli) allPermissions(ctx context.Context, service string) ([]permission, error) {
+	permissions := []permission{}
+	for page := int32(1); ; page++ {
+		var batch, deprecated []string
+		var lastPage int32
+
+		if service == "" {
+			resp, err := c.client.GetAllPermissions(ctx, &permissionsv1.GetAllPermissionsRequest{Page: page, Limit: pageSize})
+			if err != nil {
+				return nil, err
+			}
+			batch, deprecated, lastPage = resp.Permissions, resp.Deprecated, resp.LastPage
+		} else {
+			resp, err := c.client.GetServicePermissions(ctx, &permissionsv1.GetServicePermissionsRequest{ServiceName: service, Page: page, Limit: pageSize})
+			if err != nil {
+				return nil, err
+			}
+			batch, deprecated, lastPage = resp.Permissions, resp.Deprecated, resp.LastPage
+		}
+
+		for _, name := range batch {
+			permissions = append(permissions, permission{Name: name, Deprecated: slices.Contains(deprecated, name)})
+		}
+		if page >= lastPage {
+			return permissions, nil
+		}
+	}
+}
+
+func (c *cli) printPermissions(permissions []permission) error {
+	return c.out.print(permissions, func(w io.Writer) {
+		row(w, "PERMISSION", "STATUS")
+		for _, perm := range permissions {
+			state := "active"
+			if perm.Deprecated {
+				state = "deprecated"
+			}
+			row(w, perm.Name, state)
+		}
+	})
+}
+
+func (c *cli) deprecatePermission(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("permissions deprecate", flag.ContinueOnError)
+	reason := flags.String("reason", "", "why the permission is deprecated and what replaces it")
+	positional, err := parseArgs(flags, args, 1)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.client.DeprecatePermission(ctx, &permissionsv1.DeprecatePermissionRequest{
+		Permission: positional[0],
+		Reason:     *reason,
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Permission %s deprecated.\n", positional[0])
+	return nil
+}
+
+func (c *cli) deletePermission(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("permissions delete", flag.ContinueOnError)
+	force := flags.Bool("force", false, "also remove the permission from the roles that grant it")
+	positional, err := parseArgs(flags, args, 1)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.client.DeletePermission(ctx, &permissionsv1.DeletePermissionRequest{
+		Permission: positional[0],
+		Force:      *force,
+	})
+	if err != nil {
+		return err
+	}
+
+	deletion := dto.PermissionDeletion{Permission: positional[0], RemovedFromRoles: resp.RemovedFromRoles}
+	return c.out.print(deletion, func(w io.Writer) {
+		fmt.Fprintf(w, "Permission %s deleted.\n", deletion.Permission)
+		if len(deletion.RemovedFromRoles) > 0 {
+			fmt.Fprintf(w, "Removed from roles: %s\n", strings.Join(deletion.RemovedFromRoles, ", "))
+		}
+	})
+}
+
+func (c *cli) createAlias(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("permissions alias", flag.ContinueOnError)
+	positional, err := parseArgs(flags, args, 2)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.client.CreatePermissionAlias(ctx, &permissionsv1.CreatePermissionAliasRequest{
+		Alias:      positional[0],
+		Permission: positional[1],
+	})
+	if err != nil {
+		return err
+	}
+
+	result := struct {
+		alias
+		MigratedRoles []string `json:"migrated_roles"`
+	}{fromProtoAlias(resp.Alias), resp.MigratedRoles}
+
+	return c.out.print(result, func(w io.Writer) {
+		fmt.Fprintf(w, "%s now resolves to %s.\n", result.Alias, result.Permission)
+		if len(result.MigratedRoles) > 0 {
+			fmt.Fprintf(w, "Migrated roles: %s\n", strings.Join(result.MigratedRoles, ", "))
+		}
+	})
+}
+
+func (c *cli) listAliases(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("permissions aliases", flag.ContinueOnError)
+	if _, err := parseArgs(flags, args, 0); err != nil {
+		return err
+	}
+
+	resp, err := c.client.GetPermissionAliases(ctx, &permissionsv1.GetPermissionAliasesRequest{})
+	if err != nil {
+		return err
+	}
+
+	aliases := make([]alias, len(resp.Aliases))
+	for i, a := range resp.Aliases {
+		aliases[i] = fromProtoAlias(a)
+	}
+
+	return c.out.print(aliases, func(w io.Writer) {
+		row(w, "ALIAS", "PERMISSION", "CREATED")
+		for _, a := range aliases {
+			row(w, a.Alias, a.Permission, a.CreatedAt.Format(time.RFC3339))
+		}
+	})
+}
+
+func fromProtoAlias(a *permissionsv1.PermissionAlias) alias {
+	return alias{
+		Alias:      a.Alias,
+		Permission: a.Permission,
+		CreatedAt:  a.CreatedAt.AsTime(),
+	}
+}
+
+func (c *cli) listRoles(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("roles list", flag.ContinueOnError)
+	if _, err := parseArgs(flags, args, 0); err != nil {
+		return err
+	}
+
+	roles := []role{}
+	for page := int32(1); ; page++ {
+		resp, err := c.client.GetAllRoles(ctx, &permissionsv1.GetAllRolesRequest{Page: page, Limit: pageSize})
+		if err != nil {
+			return err
+		}
+		for _, r := range resp.Roles {
+			roles = append(roles, fromProtoRole(r))
+		}
+		if page >= resp.LastPage {
+			break
+		}
+	}
+
+	return c.out.print(roles, func(w io.Writer) {
+		row(w, "NAME", "PERMISSIONS", "SOURCE", "DESCRIPTION")
+		for _, r := range roles {
+			row(w, r.Name, fmt.Sprint(len(r.Permissions)), orDash(r.Source), r.Description)
+		}
+	})
+}
+
+func (c *cli) getRole(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("roles get", flag.ContinueOnError)
+	positional, err := parseArgs(flags, args, 1)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.client.GetRole(ctx, &permissionsv1.GetRoleRequest{Name: positional[0]})
+	if err != nil {
+		return err
+	}
+	return c.printRole(fromProtoRole(resp.Role))
+}
+
+func (c *cli) createRole(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("roles create", flag.ContinueOnError)
+	description := flags.String("description", "", "role description")
+	var permissions stringList
+	flags.Var(&permissions, "permission", "permission granted by the role (repeatable)")
+	positional, err := parseArgs(flags, args, 1)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.client.CreateRole(ctx, &permissionsv1.CreateRoleRequest{
+		Name:        positional[0],
+		Description: *description,
+		Permissions: permissions,
+	})
+	if err != nil {
+		return err
+	}
+	return c.printRole(fromProtoRole(resp.Role))
+}
+
+func (c *cli) updateRole(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("roles update", flag.ContinueOnError)
+	description := flags.String("description", "", "role description")
+	var permissions stringList
+	flags.Var(&permissions, "permission", "permission granted by the role (repeatable, replaces the current set)")
+	positional, err := parseArgs(flags, args, 1)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.client.UpdateRole(ctx, &permissionsv1.UpdateRoleRequest{
+		Name:        positional[0],
+		Description: *description,
+		Permissions: permissions,
+	})
+	if err != nil {
+		return err
+	}
+	return c.printRole(fromProtoRole(resp.Role))
+}
+
+func (c *cli) deleteRole(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("roles delete", flag.ContinueOnError)
+	positional, err := parseArgs(flags, args, 1)
+	if err != nil {
+		return err
+	}
+
+	if _, err := c.client.DeleteRole(ctx, &permissionsv1.DeleteRoleRequest{Name: positional[0]}); err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Role %s deleted.\n", positional[0])
+	return nil
+}
+
+func (c *cli) syncRoles(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("roles sync", flag.ContinueOnError)
+	dryRun := flags.Bool("dry-run", false, "print the changes without applying them")
+	paths, err := parseArgs(flags, args, -1)
+	if err != nil {
+		return err
+	}
+
+	files := make([]*permissionsv1.RoleFile, 0, len(paths))
+	for _, path := range paths {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read role file: %w", err)
+		}
+		files = append(files, &permissionsv1.RoleFile{Path: roleFilePath(path), Content: content})
+	}
+
+	resp, err := c.client.SyncRoles(ctx, &permissionsv1.SyncRolesRequest{Files: files, DryRun: *dryRun})
+	if err != nil {
+		return err
+	}
+
+	result := dto.RoleSyncResult{
+		Created:   resp.Created,
+		Updated:   resp.Updated,
+		Deleted:   resp.Deleted,
+		Unchanged: resp.Unchanged,
+		Applied:   resp.Applied,
+	}
+	return c.out.print(result, func(w io.Writer) {
+		for _, name := range result.Created {
+			fmt.Fprintf(w, "+ %s\n", name)
+		}
+		for _, name := range result.Updated {
+			fmt.Fprintf(w, "~ %s\n", name)
+		}
+		for _, name := range result.Deleted {
+			fmt.Fprintf(w, "- %s\n", name)
+		}
+		fmt.Fprintf(w, "%d created, %d updated, %d deleted, %d unchanged\n",
+			len(result.Created), len(result.Updated), len(result.Deleted), result.Unchanged)
+		if !result.Applied {
+			fmt.Fprintln(w, "No changes applied.")
+		}
+	})
+}
+
+// roleFilePath returns the clean relative path the server records a role file
+// under. Files outside the working directory are named by their base name.
+func roleFilePath(path string) string {
+	if filepath.IsLocal(path) {
+		return filepath.ToSlash(filepath.Clean(path))
+	}
+	return filepath.Base(path)
+}
+
+func (c *cli) printRole(r role) error {
+	return c.out.print(r, func(w io.Writer) {
+		row(w, "Name:", r.Name)
+		row(w, "Description:", orDash(r.Description))
+		row(w, "Source:", orDash(r.Source))
+		row(w, "Read-only:", fmt.Sprint(r.ReadOnly))
+		row(w, "Permissions:", orDash(strings.Join(r.Permissions, ", ")))
+	})
+}
+
+func fromProtoRole(r *permissionsv1.Role) role {
+	return role{
+		Name:        r.Name,
+		Description: r.Description,
+		Permissions: r.Permissions,
+		ReadOnly:    r.ReadOnly,
+		Source:      r.Source,
+	}
+}
+
+func (c *cli) listAssignments(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("assignments list", flag.ContinueOnError)
+	subject := flags.String("subject", "", "only list assignments of this subject")
+	roleName := flags.String("role", "", "only list assignments of this role")
+	tenant := flags.String("tenant", "", "only list assignments in this tenant")
+	if _, err := parseArgs(flags, args, 0); err != nil {
+		return err
+	}
+
+	assignments := []assignment{}
+	for page := int32(1); ; page++ {
+		resp, err := c.client.GetAssignments(ctx, &permissionsv1.GetAssignmentsRequest{
+			Subject: *subject,
+			Role:    *roleName,
+			Tenant:  *tenant,
+			Page:    page,
+			Limit:   pageSize,
+		})
+		if err != nil {
+			return err
+		}
+		for _, a := range resp.Assignments {
+			assignments = append(assignments, fromProtoAssignment(a))
+		}
+		if page >= resp.LastPage {
+			break
+		}
+	}
+
+	return c.out.print(assignments, func(w io.Writer) {
+		row(w, "SUBJECT", "ROLE", "TENANT", "CREATED")
+		for _, a := range assignments {
+			row(w, a.Subject, a.Role, orDash(a.Tenant), a.CreatedAt.Format(time.RFC3339))
+		}
+	})
+}
+
+func (c *cli) assignRole(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("assignments add", flag.ContinueOnError)
+	tenant := flags.String("tenant", "", "tenant the assignment applies to (all tenants when empty)")
+	positional, err := parseArgs(flags, args, 2)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.client.AssignRole(ctx, &permissionsv1.AssignRoleRequest{
+		Subject: positional[0],
+		Role:    positional[1],
+		Tenant:  *tenant,
+	})
+	if err != nil {
+		return err
+	}
+
+	a := fromProtoAssignment(resp.Assignment)
+	return c.out.print(a, func(w io.Writer) {
+		row(w, "SUBJECT", "ROLE", "TENANT", "CREATED")
+		row(w, a.Subject, a.Role, orDash(a.Tenant), a.CreatedAt.Format(time.RFC3339))
+	})
+}
+
+func (c *cli) unassignRole(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("assignments remove", flag.ContinueOnError)
+	tenant := flags.String("tenant", "", "tenant of the assignment")
+	positional, err := parseArgs(flags, args, 2)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.client.UnassignRole(ctx, &permissionsv1.UnassignRoleRequest{
+		Subject: positional[0],
+		Role:    positional[1],
+		Tenant:  *tenant,
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(os.Stderr, "Role %s removed from %s.\n", positional[1], positional[0])
+	return nil
+}
+
+func fromProtoAssignment(a *permissionsv1.RoleAssignment) assignment {
+	return assignment{
+		Subject:   a.Subject,
+		Role:      a.Role,
+		Tenant:    a.Tenant,
+		CreatedAt: a.CreatedAt.AsTime(),
+	}
+}
+
+func (c *cli) usage(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("usage", flag.ContinueOnError)
+	service := flags.String("service", "", "only show permissions of this service")
+	unusedFor := flags.Duration("unused-for", 0, "only show permissions not checked for this long, e.g. 2160h")
+	if _, err := parseArgs(flags, args, 0); err != nil {
+		return err
+	}
+
+	req := &permissionsv1.GetPermissionUsageRequest{ServiceName: *service}
+	if *unusedFor > 0 {
+		req.UnusedSince = timestamppb.New(time.Now().Add(-*unusedFor))
+	}
+
+	resp, err := c.client.GetPermissionUsage(ctx, req)
+	if err != nil {
+		return err
+	}
+
+	usage := make([]models.PermissionUsage, len(resp.Permissions))
+	for i, p := range resp.Permissions {
+		usage[i] = models.PermissionUsage{
+			Permission:    p.Permission,
+			CheckCount:    p.CheckCount,
+			LastCheckedAt: optionalTime(p.LastCheckedAt),
+			Roles:         make([]models.RoleUsage, len(p.Roles)),
+		}
+		for j, r := range p.Roles {
+			usage[i].Roles[j] = models.RoleUsage{
+				Role:          r.Role,
+				Permission:    p.Permission,
+				GrantCount:    r.GrantCount,
+				LastGrantedAt: optionalTime(r.LastGrantedAt),
+			}
+		}
+	}
+
+	return c.out.print(usage, func(w io.Writer) {
+		row(w, "PERMISSION", "CHECKS", "LAST CHECKED", "ROLES (GRANTS)")
+		for _, u := range usage {
+			roles := make([]string, len(u.Roles))
+			for i, r := range u.Roles {
+				roles[i] = fmt.Sprintf("%s (%d)", r.Role, r.GrantCount)
+			}
+			row(w, u.Permission, fmt.Sprint(u.CheckCount), formatOptionalTime(u.LastCheckedAt), orDash(strings.Join(roles, ", ")))
+		}
+	})
+}
+
+func optionalTime(t *timestamppb.Timestamp) *time.Time {
+	if t == nil {
+		return nil
+	}
+	at := t.AsTime()
+	return &at
+}
+
+func formatOptionalTime(t *time.Time) string {
+	if t == nil {
+		return "never"
+	}
+	return t.Format(time.RFC3339)
+}
+
+func (c *cli) check(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("check", flag.ContinueOnError)
+	tenant := flags.String("tenant", "", "tenant to check the permission in")
+	positional, err := parseArgs(flags, args, 2)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.client.Authorize(ctx, &permissionsv1.AuthorizeRequest{
+		Subject:    positional[0],
+		Permission: positional[1],
+		Tenant:     *tenant,
+	})
+	if err != nil {
+		return err
+	}
+
+	decision := dto.AuthorizationDecision{
+		Subject:      positional[0],
+		Permission:   positional[1],
+		Tenant:       *tenant,
+		Allowed:      resp.Allowed,
+		Deprecated:   resp.Deprecated,
+		MatchedRoles: resp.MatchedRoles,
+	}
+	return c.out.print(decision, func(w io.Writer) {
+		row(w, "SUBJECT", "PERMISSION", "TENANT", "DECISION", "ROLES")
+		row(w, decision.Subject, decision.Permission, orDash(decision.Tenant), verdict(decision.Allowed), orDash(strings.Join(decision.MatchedRoles, ", ")))
+	})
+}
+
+func (c *cli) explain(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("explain", flag.ContinueOnError)
+	tenant := flags.String("tenant", "", "tenant to check the permission in")
+	positional, err := parseArgs(flags, args, 2)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.client.ExplainAuthorization(ctx, &permissionsv1.AuthorizeRequest{
+		Subject:    positional[0],
+		Permission: positional[1],
+		Tenant:     *tenant,
+	})
+	if err != nil {
+		return err
+	}
+
+	decision := dto.AuthorizationDecision{
+		Subject:              positional[0],
+		Permission:           positional[1],
+		Tenant:               *tenant,
+		CanonicalPermission:  resp.CanonicalPermission,
+		Allowed:              resp.Allowed,
+		PermissionRegistered: resp.PermissionRegistered,
+		Deprecated:           resp.Deprecated,
+		MatchedRoles:         resp.MatchedRoles,
+		Evaluated:            make([]dto.RoleEvaluation, len(resp.Evaluated)),
+	}
+	for i, evaluation := range resp.Evaluated {
+		decision.Evaluated[i] = dto.RoleEvaluation{Role: evaluation.Role, Granted: evaluation.Granted}
+	}
+
+	return c.out.print(decision, func(w io.Writer) {
+		fmt.Fprintf(w, "%s: %s has %s in tenant %s\n", verdict(decision.Allowed), decision.Subject, decision.Permission, orDash(decision.Tenant))
+		if !decision.PermissionRegistered {
+			fmt.Fprintf(w, "Permission %s is not registered by any service.\n", decision.Permission)
+		}
+		if decision.CanonicalPermission != "" && decision.CanonicalPermission != decision.Permission {
+			fmt.Fprintf(w, "%s is an alias of %s.\n", decision.Permission, decision.CanonicalPermission)
+		}
+		if decision.Deprecated {
+			fmt.Fprintf(w, "Permission %s is deprecated.\n", decision.CanonicalPermission)
+		}
+		if len(decision.Evaluated) == 0 {
+			fmt.Fprintln(w, "Subject holds no roles in this tenant.")
+			return
+		}
+
+		fmt.Fprintln(w)
+		row(w, "ROLE", "GRANTS")
+		for _, evaluation := range decision.Evaluated {
+			row(w, evaluation.Role, fmt.Sprint(evaluation.Granted))
+		}
+	})
+}
+
+func verdict(allowed bool) string {
+	if allowed {
+		return "ALLOW"
+	}
+	return "DENY"
+}
+
+func (c *cli) exportModel(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("model export", flag.ContinueOnError)
+	format := flags.String("format", "yaml", "document format (yaml or json)")
+	file := flags.String("file", "", "write the document to this file instead of stdout")
+	if _, err := parseArgs(flags, args, 0); err != nil {
+		return err
+	}
+
+	resp, err := c.client.ExportModel(ctx, &permissionsv1.ExportModelRequest{Format: *format})
+	if err != nil {
+		return err
+	}
+
+	if *file == "" {
+		_, err = os.Stdout.Write(resp.Document)
+		return err
+	}
+	return os.WriteFile(*file, resp.Document, 0o644)
+}
+
+func (c *cli) importModel(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("model import", flag.ContinueOnError)
+	file := flags.String("file", "", "document to import (- for stdin)")
+	dryRun := flags.Bool("dry-run", false, "print the diff without applying it")
+	prune := flags.Bool("prune", false, "remove permissions, API-managed roles and assignments that are not in the document")
+	if _, err := parseArgs(flags, args, 0); err != nil {
+		return err
+	}
+
+	if *file == "" {
+		return fmt.Errorf("-file is required")
+	}
+
+	var document []byte
+	var err error
+	if *file == "-" {
+		document, err = io.ReadAll(os.Stdin)
+	} else {
+		document, err = os.ReadFile(*file)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read document: %w", err)
+	}
+
+	resp, err := c.client.ImportModel(ctx, &permissionsv1.ImportModelRequest{
+		Document: document,
+		DryRun:   *dryRun,
+		Prune:    *prune,
+	})
+	if err != nil {
+		return err
+	}
+
+	diff := dto.ModelDiff{
+		Added:                 resp.Added,
+		Removed:               resp.Removed,
+		Unchanged:             resp.Unchanged,
+		RolesAdded:            resp.RolesAdded,
+		RolesUpdated:          resp.RolesUpdated,
+		RolesRemoved:          resp.RolesRemoved,
+		RolesUnchanged:        resp.RolesUnchanged,
+		AssignmentsAdded:      fromProtoModelAssignments(resp.AssignmentsAdded),
+		AssignmentsRemoved:    fromProtoModelAssignments(resp.AssignmentsRemoved),
+		AssignmentsUnchanged:  resp.AssignmentsUnchanged,
+		AliasesAdded:          fromProtoModelAliases(resp.AliasesAdded),
+		AliasesUnchanged:      resp.AliasesUnchanged,
+		DeprecationsAdded:     resp.DeprecationsAdded,
+		DeprecationsRemoved:   resp.DeprecationsRemoved,
+		DeprecationsUnchanged: resp.DeprecationsUnchanged,
+		Applied:               resp.Applied,
+	}
+	return c.out.print(diff, func(w io.Writer) {
+		for _, permission := range diff.Added {
+			fmt.Fprintf(w, "+ %s\n", permission)
+		}
+		for _, permission := range diff.Removed {
+			fmt.Fprintf(w, "- %s\n", permission)
+		}
+		for _, alias := range diff.AliasesAdded {
+			fmt.Fprintf(w, "+ alias %s\n", alias)
+		}
+		for _, permission := range diff.DeprecationsAdded {
+			fmt.Fprintf(w, "+ deprecation %s\n", permission)
+		}
+		for _, permission := range diff.DeprecationsRemoved {
+			fmt.Fprintf(w, "- deprecation %s\n", permission)
+		}
+		for _, name := range diff.RolesAdded {
+			fmt.Fprintf(w, "+ role %s\n", name)
+		}
+		for _, name := range diff.RolesUpdated {
+			fmt.Fprintf(w, "~ role %s\n", name)
+		}
+		for _, name := range diff.RolesRemoved {
+			fmt.Fprintf(w, "- role %s\n", name)
+		}
+		for _, assignment := range diff.AssignmentsAdded {
+			fmt.Fprintf(w, "+ assignment %s\n", assignment)
+		}
+		for _, assignment := range diff.AssignmentsRemoved {
+			fmt.Fprintf(w, "- assignment %s\n", assignment)
+		}
+		fmt.Fprintf(w, "permissions: %d added, %d removed, %d unchanged\n", len(diff.Added), len(diff.Removed), diff.Unchanged)
+		fmt.Fprintf(w, "aliases: %d added, %d unchanged\n", len(diff.AliasesAdded), diff.AliasesUnchanged)
+		fmt.Fprintf(w, "deprecations: %d added, %d removed, %d unchanged\n",
+			len(diff.DeprecationsAdded), len(diff.DeprecationsRemoved), diff.DeprecationsUnchanged)
+		fmt.Fprintf(w, "roles: %d added, %d updated, %d removed, %d unchanged\n",
+			len(diff.RolesAdded), len(diff.RolesUpdated), len(diff.RolesRemoved), diff.RolesUnchanged)
+		fmt.Fprintf(w, "assignments: %d added, %d removed, %d unchanged\n",
+			len(diff.AssignmentsAdded), len(diff.AssignmentsRemoved), diff.AssignmentsUnchanged)
+		if !diff.Applied {
+			fmt.Fprintln(w, "No changes applied.")
+		}
+	})
+}
+
+func fromProtoModelAliases(aliases []*permissionsv1.PermissionAlias) []models.ModelAlias {
+	result := make([]models.ModelAlias, len(aliases))
+	for i, a := range aliases {
+		result[i] = models.ModelAlias{Alias: a.Alias, Permission: a.Permission}
+	}
+	return result
+}
+
+func fromProtoModelAssignments(assignments []*permissionsv1.RoleAssignment) []models.ModelAssignment {
+	result := make([]models.ModelAssignment, len(assignments))
+	for i, a := range assignments {
+		result[i] = models.ModelAssignment{Subject: a.Subject, Role: a.Role, Tenant: a.Tenant}
+	}
+	return result
+}
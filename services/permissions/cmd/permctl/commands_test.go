@@ -0,0 +1,247 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	permissionsv1 "intellifinder/services/permissions/api/v1"
+	"intellifinder/services/permissions/internal/domain/permissions"
+	permissionsgrpc "intellifinder/services/permissions/internal/infrastructure/grpc"
+	"intellifinder/services/permissions/internal/infrastructure/memory"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	serviceauth "github.com/intellifinder/v4/libs/auth"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// serviceTokens accepts tokens of the form "<service>-token"
+type serviceTokens struct{}
+
+func (serviceTokens) VerifyServiceToken(_ context.Context, token string) (string, error) {
+	name, ok := strings.CutSuffix(token, "-token")
+	if !ok {
+		return "", serviceauth.ErrInvalidToken
+	}
+	return name, nil
+}
+
+// newTestServer registers a PermissionServer backed by the in-memory
+// repository with server, with the console service as its admin, and returns
+// the domain service to seed it with
+func newTestServer(server *grpc.Server) *permissions.Service {
+	service := permissions.NewService(memory.NewRepository())
+	permissionServer := permissionsgrpc.NewPermissionServer(service, "")
+	permissionServer.SetAdminServices("console")
+	permissionsv1.RegisterPermissionServiceServer(server, permissionServer)
+	return service
+}
+
+// newTestConn serves the permissions service over bufconn and returns a
+// connection authenticated as the console service
+func newTestConn(t *testing.T) (*grpc.ClientConn, *permissions.Service) {
+	t.Helper()
+
+	listener := bufconn.Listen(1024 * 1024)
+	server := grpc.NewServer(grpc.UnaryInterceptor(serviceauth.UnaryServerInterceptor(serviceTokens{})))
+	service := newTestServer(server)
+
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithPerRPCCredentials(tokenCredentials{token: "console-token"}),
+	)
+	if err != nil {
+		t.Fatalf("failed to dial bufconn: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	if err := service.RegisterServicePermissions(context.Background(), "tasks", []string{"tasks:read", "tasks:update"}); err != nil {
+		t.Fatalf("RegisterServicePermissions() error = %v", err)
+	}
+	return conn, service
+}
+
+func newTestCLI(conn *grpc.ClientConn, format string) (*cli, *bytes.Buffer) {
+	var buf bytes.Buffer
+	out, _ := newPrinter(format, &buf)
+	return &cli{client: permissionsv1.NewPermissionServiceClient(conn), out: out}, &buf
+}
+
+func TestParseArgs(t *testing.T) {
+	tests := []struct {
+		name           string
+		args           []string
+		want           int
+		wantPositional []string
+		wantTenant     string
+		wantRoles      []string
+		wantErr        string
+	}{
+		{name: "no arguments", args: nil, want: 0},
+		{name: "flags before", args: []string{"-tenant", "acme", "alice", "editor"}, want: 2, wantPositional: []string{"alice", "editor"}, wantTenant: "acme"},
+		{name: "flags between", args: []string{"alice", "-tenant=acme", "editor"}, want: 2, wantPositional: []string{"alice", "editor"}, wantTenant: "acme"},
+		{name: "flags after", args: []string{"alice", "editor", "-tenant", "acme"}, want: 2, wantPositional: []string{"alice", "editor"}, wantTenant: "acme"},
+		{name: "repeated flag", args: []string{"-role", "a", "x", "-role", "b"}, want: 1, wantPositional: []string{"x"}, wantRoles: []string{"a", "b"}},
+		{name: "any number", args: []string{"a.yaml", "b.yaml", "c.yaml"}, want: -1, wantPositional: []string{"a.yaml", "b.yaml", "c.yaml"}},
+		{name: "after terminator", args: []string{"--", "-not-a-flag"}, want: 1, wantPositional: []string{"-not-a-flag"}},
+		{name: "too few", args: []string{"alice"}, want: 2, wantErr: "test expects 2 argument(s), got 1"},
+		{name: "too many", args: []string{"alice", "editor", "extra"}, want: 2, wantErr: "test expects 2 argument(s), got 3"},
+		{name: "unknown flag", args: []string{"-nope"}, want: 0, wantErr: "flag provided but not defined: -nope"},
+		{name: "missing flag value", args: []string{"alice", "-tenant"}, want: 1, wantErr: "flag needs an argument: -tenant"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			flags := flag.NewFlagSet("test", flag.ContinueOnError)
+			flags.SetOutput(io.Discard)
+			tenant := flags.String("tenant", "", "")
+			var roles stringList
+			flags.Var(&roles, "role", "")
+
+			positional, err := parseArgs(flags, tt.args, tt.want)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Errorf("parseArgs() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseArgs() error = %v", err)
+			}
+			if !slices.Equal(positional, tt.wantPositional) || *tenant != tt.wantTenant || !slices.Equal(roles, tt.wantRoles) {
+				t.Errorf("parseArgs() = %q, tenant %q, roles %q, want %q, %q, %q",
+					positional, *tenant, roles, tt.wantPositional, tt.wantTenant, tt.wantRoles)
+			}
+		})
+	}
+}
+
+func TestDispatch(t *testing.T) {
+	conn, _ := newTestConn(t)
+	ctx := context.Background()
+
+	// The steps run in order against the same server
+	tests := []struct {
+		name    string
+		args    []string
+		want    []string
+		notWant []string
+		wantErr string
+	}{
+		{
+			name: "create role",
+			args: []string{"roles", "create", "editor", "-description", "Edits tasks", "-permission", "tasks:read", "-permission", "tasks:update"},
+			want: []string{"editor", "Edits tasks", "tasks:read, tasks:update"},
+		},
+		{name: "list roles", args: []string{"roles", "list"}, want: []string{"NAME", "editor  2"}},
+		{name: "search permissions", args: []string{"permissions", "search", "UPD"}, want: []string{"tasks:update  active"}, notWant: []string{"tasks:read"}},
+		{name: "list service permissions", args: []string{"permissions", "list", "-service", "tasks"}, want: []string{"tasks:read", "tasks:update"}},
+		{name: "assign role", args: []string{"assignments", "add", "alice", "editor", "-tenant", "acme"}, want: []string{"alice", "editor", "acme"}},
+		{name: "list assignments", args: []string{"assignments", "list", "-subject", "alice"}, want: []string{"SUBJECT", "alice", "acme"}},
+		{name: "check allowed", args: []string{"check", "alice", "tasks:update", "-tenant", "acme"}, want: []string{"ALLOW", "editor"}},
+		{name: "check other tenant", args: []string{"check", "alice", "tasks:update", "-tenant", "globex"}, want: []string{"DENY"}},
+		{name: "explain", args: []string{"explain", "bob", "tasks:read"}, want: []string{"DENY: bob has tasks:read in tenant -", "Subject holds no roles"}},
+		{name: "delete permission in use", args: []string{"permissions", "delete", "tasks:read"}, wantErr: "granted by editor"},
+		{name: "force delete permission", args: []string{"permissions", "delete", "tasks:read", "-force"}, want: []string{"Permission tasks:read deleted.", "Removed from roles: editor"}},
+		{name: "unknown command", args: []string{"frobnicate"}, wantErr: `unknown command "frobnicate"`},
+		{name: "missing subcommand", args: []string{"roles"}, wantErr: "roles requires a subcommand"},
+		{name: "unknown subcommand", args: []string{"roles", "frobnicate"}, wantErr: `unknown subcommand "frobnicate" for roles`},
+		{name: "missing argument", args: []string{"roles", "get"}, wantErr: "roles get expects 1 argument(s), got 0"},
+		{name: "missing role", args: []string{"roles", "get", "nobody"}, wantErr: "NotFound"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c, out := newTestCLI(conn, "table")
+			err := c.dispatch(ctx, tt.args)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("dispatch(%q) error = %v, want it to contain %q", tt.args, err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("dispatch(%q) error = %v", tt.args, err)
+			}
+
+			for _, want := range tt.want {
+				if !strings.Contains(out.String(), want) {
+					t.Errorf("dispatch(%q) output = %q, want it to contain %q", tt.args, out.String(), want)
+				}
+			}
+			for _, notWant := range tt.notWant {
+				if strings.Contains(out.String(), notWant) {
+					t.Errorf("dispatch(%q) output = %q, don't want %q", tt.args, out.String(), notWant)
+				}
+			}
+		})
+	}
+}
+
+func TestDispatchJSONOutput(t *testing.T) {
+	conn, _ := newTestConn(t)
+	ctx := context.Background()
+
+	c, out := newTestCLI(conn, "json")
+	if err := c.dispatch(ctx, []string{"roles", "create", "viewer", "-permission", "tasks:read"}); err != nil {
+		t.Fatalf("dispatch() error = %v", err)
+	}
+
+	var got role
+	if err := json.Unmarshal(out.Bytes(), &got); err != nil {
+		t.Fatalf("output %q is not JSON: %v", out.String(), err)
+	}
+	if got.Name != "viewer" || !slices.Equal(got.Permissions, []string{"tasks:read"}) || got.ReadOnly {
+		t.Errorf("role = %+v, want the created viewer role", got)
+	}
+}
+
+func TestModelCommands(t *testing.T) {
+	conn, _ := newTestConn(t)
+	ctx := context.Background()
+	document := filepath.Join(t.TempDir(), "model.json")
+
+	c, _ := newTestCLI(conn, "table")
+	if err := c.dispatch(ctx, []string{"roles", "create", "viewer", "-permission", "tasks:read"}); err != nil {
+		t.Fatalf("roles create error = %v", err)
+	}
+	if err := c.dispatch(ctx, []string{"model", "export", "-format", "json", "-file", document}); err != nil {
+		t.Fatalf("model export error = %v", err)
+	}
+
+	data, err := os.ReadFile(document)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), `"tasks:update"`) || !strings.Contains(string(data), `"viewer"`) {
+		t.Errorf("exported document = %s, want the permissions and roles", data)
+	}
+
+	c, out := newTestCLI(conn, "table")
+	if err := c.dispatch(ctx, []string{"model", "import", "-file", document, "-dry-run", "-prune"}); err != nil {
+		t.Fatalf("model import error = %v", err)
+	}
+	for _, want := range []string{"permissions: 0 added, 0 removed, 2 unchanged", "roles: 0 added, 0 updated, 0 removed, 1 unchanged", "No changes applied."} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("model import output = %q, want it to contain %q", out.String(), want)
+		}
+	}
+
+	if err := c.dispatch(ctx, []string{"model", "import"}); err == nil || err.Error() != "-file is required" {
+		t.Errorf("model import without -file error = %v, want -file is required", err)
+	}
+}
@@ -0,0 +1,195 @@
+// Command permctl administers the permissions service over gRPC.
+//
+//	permctl [global flags] <command> [subcommand] [flags] [args]
+//
+// Run "permctl help" for the list of commands.
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"flag"
+	"fmt"
+	permissionsv1 "intellifinder/services/permissions/api/v1"
+	"os"
+	"time"
+
+	serviceauth "github.com/intellifinder/v4/libs/auth"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+)
+
+const usage = `Usage: permctl [global flags] <command> [flags] [args]
+
+Commands:
+  permissions list [-service name]             List registered permissions
+  permissions search <text>                    Find permissions containing text
+  permissions deprecate <permission> [-reason r]
+  permissions delete <permission> [-force]     Refuses while roles grant it unless forced
+  permissions alias <old> <permission>         Rename a permission, migrating role bindings
+  permissions aliases                          List permission aliases
+  roles list                                   List roles
+  roles get <name>                             Show a role
+  roles create <name> [-description d] [-permission p]...
+  roles update <name> [-description d] [-permission p]...
+  roles delete <name>
+  roles sync [-dry-run] [file]...              Reconcile role files (server directory when no files given)
+  assignments list [-subject s] [-role r] [-tenant t]
+  assignments add <subject> <role> [-tenant t]
+  assignments remove <subject> <role> [-tenant t]
+  reviews create <name> -deadline t [-role r]... [-tenant t]...
+  reviews list [-status open|closed]           List access review campaigns
+  reviews items <campaign> [-decision d]       List the assignments under review
+  reviews approve <item> [-comment c]          Needs -user-token; recorded for that user
+  reviews revoke <item> [-comment c]           Needs -user-token
+  reviews report <campaign> -file path [-format csv|json]
+  usage [-service name] [-unused-for d]        Show how often permissions and role grants are used
+  check <subject> <permission> [-tenant t]     Run an authorization check
+  explain <subject> <permission> [-tenant t]   Explain an authorization decision
+  model export [-format yaml|json] [-file path]
+  model import -file path [-dry-run] [-prune]
+
+Global flags:
+`
+
+type globalOptions struct {
+	addr               string
+	token              string
+	userToken          string
+	useTLS             bool
+	caCert             string
+	insecureSkipVerify bool
+	output             string
+	timeout            time.Duration
+}
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(2)
+		}
+		if st, ok := status.FromError(err); ok {
+			fmt.Fprintf(os.Stderr, "Error: %s: %s\n", st.Code(), st.Message())
+		} else {
+			fmt.Fprintln(os.Stderr, "Error:", err)
+		}
+		os.Exit(1)
+	}
+}
+
+func run(args []string) error {
+	opts := &globalOptions{}
+	flags := flag.NewFlagSet("permctl", flag.ContinueOnError)
+	flags.StringVar(&opts.addr, "addr", envOr("PERMCTL_ADDR", "localhost:8080"), "permissions service address (env PERMCTL_ADDR)")
+	flags.StringVar(&opts.token, "token", os.Getenv("PERMCTL_TOKEN"), "service token sent with every call, e.g. from the auth service's /auth/service-token (env PERMCTL_TOKEN)")
+	flags.StringVar(&opts.userToken, "user-token", os.Getenv("PERMCTL_USER_TOKEN"), "your own access token, identifying you as the reviewer of access review decisions (env PERMCTL_USER_TOKEN)")
+	flags.BoolVar(&opts.useTLS, "tls", false, "connect using TLS")
+	flags.StringVar(&opts.caCert, "ca-cert", "", "PEM file with the CA that signed the server certificate (implies -tls)")
+	flags.BoolVar(&opts.insecureSkipVerify, "insecure-skip-verify", false, "don't verify the server certificate (implies -tls)")
+	flags.StringVar(&opts.output, "o", "table", "output format: table, json or yaml")
+	flags.DurationVar(&opts.timeout, "timeout", 30*time.Second, "timeout for the whole command")
+	flags.Usage = func() {
+		fmt.Fprint(flags.Output(), usage)
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if flags.NArg() == 0 || flags.Arg(0) == "help" {
+		flags.Usage()
+		return nil
+	}
+
+	out, err := newPrinter(opts.output, os.Stdout)
+	if err != nil {
+		return err
+	}
+
+	conn, err := dial(opts)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), opts.timeout)
+	defer cancel()
+
+	cli := &cli{
+		client: permissionsv1.NewPermissionServiceClient(conn),
+		out:    out,
+	}
+	return cli.dispatch(ctx, flags.Args())
+}
+
+func dial(opts *globalOptions) (*grpc.ClientConn, error) {
+	dialOpts := []grpc.DialOption{}
+
+	useTLS := opts.useTLS || opts.caCert != "" || opts.insecureSkipVerify
+	if useTLS {
+		tlsConfig := &tls.Config{InsecureSkipVerify: opts.insecureSkipVerify}
+
+		if opts.caCert != "" {
+			pem, err := os.ReadFile(opts.caCert)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read CA certificate: %w", err)
+			}
+
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("no certificates found in %s", opts.caCert)
+			}
+			tlsConfig.RootCAs = pool
+		}
+
+		dialOpts = append(dialOpts, grpc.WithTransportCredentials(credentials.NewTLS(tlsConfig)))
+	} else {
+		dialOpts = append(dialOpts, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	}
+
+	if opts.token != "" {
+		dialOpts = append(dialOpts, grpc.WithPerRPCCredentials(tokenCredentials{
+			token:      opts.token,
+			userToken:  opts.userToken,
+			requireTLS: useTLS,
+		}))
+	}
+
+	conn, err := grpc.NewClient(opts.addr, dialOpts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to %s: %w", opts.addr, err)
+	}
+	return conn, nil
+}
+
+// tokenCredentials sends a bearer token in the authorization metadata, and
+// the user's token, if any, next to it
+type tokenCredentials struct {
+	token      string
+	userToken  string
+	requireTLS bool
+}
+
+func (c tokenCredentials) GetRequestMetadata(ctx context.Context, uri ...string) (map[string]string, error) {
+	md := map[string]string{"authorization": "Bearer " + c.token}
+	if c.userToken != "" {
+		md[serviceauth.UserTokenHeader] = c.userToken
+	}
+	return md, nil
+}
+
+func (c tokenCredentials) RequireTransportSecurity() bool {
+	return c.requireTLS
+}
+
+func envOr(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"context"
+	"encoding/pem"
+	permissionsv1 "intellifinder/services/permissions/api/v1"
+	"net"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	serviceauth "github.com/intellifinder/v4/libs/auth"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/status"
+)
+
+// newTLSServer serves the permissions service over TLS on a local port and
+// returns its address and a PEM file with the certificate it presents
+func newTLSServer(t *testing.T) (string, string) {
+	t.Helper()
+
+	// httptest provides a certificate valid for 127.0.0.1
+	https := httptest.NewTLSServer(nil)
+	https.Close()
+	certificate := https.TLS.Certificates[0]
+
+	caCert := filepath.Join(t.TempDir(), "ca.pem")
+	block := &pem.Block{Type: "CERTIFICATE", Bytes: https.Certificate().Raw}
+	if err := os.WriteFile(caCert, pem.EncodeToMemory(block), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	server := grpc.NewServer(
+		grpc.Creds(credentials.NewServerTLSFromCert(&certificate)),
+		grpc.UnaryInterceptor(serviceauth.UnaryServerInterceptor(serviceTokens{})),
+	)
+	newTestServer(server)
+
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	return listener.Addr().String(), caCert
+}
+
+func TestDial(t *testing.T) {
+	addr, caCert := newTLSServer(t)
+
+	notPEM := filepath.Join(t.TempDir(), "ca.txt")
+	if err := os.WriteFile(notPEM, []byte("not a certificate"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name        string
+		opts        globalOptions
+		wantDialErr string
+		wantCode    codes.Code
+	}{
+		{name: "trusted CA", opts: globalOptions{caCert: caCert, token: "console-token"}},
+		{name: "skip verification", opts: globalOptions{insecureSkipVerify: true, token: "console-token"}},
+		{name: "unknown CA", opts: globalOptions{useTLS: true, token: "console-token"}, wantCode: codes.Unavailable},
+		{name: "plaintext", opts: globalOptions{token: "console-token"}, wantCode: codes.Unavailable},
+		{name: "no token", opts: globalOptions{caCert: caCert}, wantCode: codes.Unauthenticated},
+		{name: "invalid token", opts: globalOptions{caCert: caCert, token: "nonsense"}, wantCode: codes.Unauthenticated},
+		{name: "missing CA file", opts: globalOptions{caCert: filepath.Join(t.TempDir(), "missing.pem")}, wantDialErr: "failed to read CA certificate"},
+		{name: "CA file without certificates", opts: globalOptions{caCert: notPEM}, wantDialErr: "no certificates found in " + notPEM},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.opts.addr = addr
+			conn, err := dial(&tt.opts)
+			if tt.wantDialErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantDialErr) {
+					t.Errorf("dial() error = %v, want it to contain %q", err, tt.wantDialErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("dial() error = %v", err)
+			}
+			defer conn.Close()
+
+			_, err = permissionsv1.NewPermissionServiceClient(conn).GetAllRoles(context.Background(), &permissionsv1.GetAllRolesRequest{Page: 1, Limit: 10})
+			if got := status.Code(err); got != tt.wantCode {
+				t.Errorf("GetAllRoles() code = %s, want %s (error %v)", got, tt.wantCode, err)
+			}
+		})
+	}
+}
+
+func TestTokenCredentials(t *testing.T) {
+	creds := tokenCredentials{token: "secret", requireTLS: true}
+
+	md, err := creds.GetRequestMetadata(context.Background())
+	if err != nil {
+		t.Fatalf("GetRequestMetadata() error = %v", err)
+	}
+	if md["authorization"] != "Bearer secret" {
+		t.Errorf("authorization = %q, want %q", md["authorization"], "Bearer secret")
+	}
+	if !creds.RequireTransportSecurity() {
+		t.Error("RequireTransportSecurity() = false, want true")
+	}
+	if _, ok := md[serviceauth.UserTokenHeader]; ok {
+		t.Errorf("metadata = %v, want no user token when none is given", md)
+	}
+
+	creds.userToken = "alice"
+	md, err = creds.GetRequestMetadata(context.Background())
+	if err != nil || md[serviceauth.UserTokenHeader] != "alice" || md["authorization"] != "Bearer secret" {
+		t.Errorf("GetRequestMetadata() = %v, %v, want both tokens", md, err)
+	}
+}
+
+func TestRunRejectsUnknownOutputFormat(t *testing.T) {
+	err := run([]string{"-o", "xml", "roles", "list"})
+	if err == nil || !strings.Contains(err.Error(), "xml") {
+		t.Errorf("run() error = %v, want an unsupported format error", err)
+	}
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestPrinter(t *testing.T) {
+	created := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+	assignments := []assignment{
+		{Subject: "alice", Role: "editor", Tenant: "acme", CreatedAt: created},
+		{Subject: "bob", Role: "viewer", CreatedAt: created},
+	}
+	table := func(w io.Writer) {
+		row(w, "SUBJECT", "ROLE", "TENANT")
+		for _, a := range assignments {
+			row(w, a.Subject, a.Role, orDash(a.Tenant))
+		}
+	}
+
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{
+			format: "table",
+			want: "SUBJECT  ROLE    TENANT\n" +
+				"alice    editor  acme\n" +
+				"bob      viewer  -\n",
+		},
+		{
+			format: "json",
+			want: `[
+  {
+    "subject": "alice",
+    "role": "editor",
+    "tenant": "acme",
+    "created_at": "2024-05-01T10:00:00Z"
+  },
+  {
+    "subject": "bob",
+    "role": "viewer",
+    "tenant": "",
+    "created_at": "2024-05-01T10:00:00Z"
+  }
+]
+`,
+		},
+		{
+			format: "yaml",
+			want: `- subject: alice
+  role: editor
+  tenant: acme
+  created_at: "2024-05-01T10:00:00Z"
+- subject: bob
+  role: viewer
+  tenant: ""
+  created_at: "2024-05-01T10:00:00Z"
+`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.format, func(t *testing.T) {
+			var buf bytes.Buffer
+			p, err := newPrinter(tt.format, &buf)
+			if err != nil {
+				t.Fatalf("newPrinter() error = %v", err)
+			}
+			if err := p.print(assignments, table); err != nil {
+				t.Fatalf("print() error = %v", err)
+			}
+			if buf.String() != tt.want {
+				t.Errorf("print() =\n%s\nwant\n%s", buf.String(), tt.want)
+			}
+		})
+	}
+}
+
+func TestPrinterYAMLNesting(t *testing.T) {
+	value := map[string]any{
+		"roles":  []string{"editor", "viewer"},
+		"nested": map[string]int{"count": 2},
+	}
+
+	var buf bytes.Buffer
+	p, _ := newPrinter("yaml", &buf)
+	if err := p.print(value, nil); err != nil {
+		t.Fatalf("print() error = %v", err)
+	}
+
+	// Block style throughout, never the flow style of the JSON input
+	want := "nested:\n  count: 2\nroles:\n  - editor\n  - viewer\n"
+	if buf.String() != want {
+		t.Errorf("print() =\n%s\nwant\n%s", buf.String(), want)
+	}
+}
+
+func TestNewPrinterRejectsUnknownFormat(t *testing.T) {
+	if _, err := newPrinter("xml", io.Discard); err == nil {
+		t.Error("newPrinter(xml) error = nil, want error")
+	}
+}
+
+func TestOrDash(t *testing.T) {
+	for value, want := range map[string]string{"": "-", "acme": "acme"} {
+		if got := orDash(value); got != want {
+			t.Errorf("orDash(%q) = %q, want %q", value, got, want)
+		}
+	}
+}
@@ -0,0 +1,241 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"flag"
+	"fmt"
+	permissionsv1 "intellifinder/services/permissions/api/v1"
+	"intellifinder/services/permissions/pkg/models"
+	"io"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func (c *cli) createReview(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("reviews create", flag.ContinueOnError)
+	deadline := flags.String("deadline", "", "RFC 3339 time or duration from now, e.g. 336h")
+	var roles, tenants stringList
+	flags.Var(&roles, "role", "role to review (repeatable, every role when omitted)")
+	flags.Var(&tenants, "tenant", "tenant to review (repeatable, every tenant when omitted)")
+	positional, err := parseArgs(flags, args, 1)
+	if err != nil {
+		return err
+	}
+
+	at, err := parseDeadline(*deadline)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.client.CreateReviewCampaign(ctx, &permissionsv1.CreateReviewCampaignRequest{
+		Name:     positional[0],
+		Roles:    roles,
+		Tenants:  tenants,
+		Deadline: timestamppb.New(at),
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.printCampaigns([]models.ReviewCampaign{fromProtoCampaign(resp.Campaign)})
+}
+
+// parseDeadline accepts an absolute time or a duration from now
+func parseDeadline(value string) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, fmt.Errorf("-deadline is required")
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return time.Now().Add(d), nil
+	}
+	at, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid -deadline %q, expected an RFC 3339 time or a duration", value)
+	}
+	return at, nil
+}
+
+func (c *cli) listReviews(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("reviews list", flag.ContinueOnError)
+	status := flags.String("status", "", "only list campaigns with this status (open or closed)")
+	if _, err := parseArgs(flags, args, 0); err != nil {
+		return err
+	}
+
+	resp, err := c.client.GetReviewCampaigns(ctx, &permissionsv1.GetReviewCampaignsRequest{Status: *status})
+	if err != nil {
+		return err
+	}
+
+	campaigns := make([]models.ReviewCampaign, len(resp.Campaigns))
+	for i, campaign := range resp.Campaigns {
+		campaigns[i] = fromProtoCampaign(campaign)
+	}
+	return c.printCampaigns(campaigns)
+}
+
+func (c *cli) printCampaigns(campaigns []models.ReviewCampaign) error {
+	return c.out.print(campaigns, func(w io.Writer) {
+		row(w, "ID", "NAME", "STATUS", "DEADLINE", "PENDING", "APPROVED", "REVOKED", "AUTO-REVOKED")
+		for _, campaign := range campaigns {
+			row(w,
+				campaign.ID.String(),
+				campaign.Name,
+				campaign.Status,
+				campaign.Deadline.Format(time.RFC3339),
+				fmt.Sprint(campaign.Pending),
+				fmt.Sprint(campaign.Approved),
+				fmt.Sprint(campaign.Revoked),
+				fmt.Sprint(campaign.AutoRevoked),
+			)
+		}
+	})
+}
+
+func (c *cli) listReviewItems(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("reviews items", flag.ContinueOnError)
+	decision := flags.String("decision", "", "only list items with this decision (pending, approved, revoked or auto_revoked)")
+	positional, err := parseArgs(flags, args, 1)
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.client.GetReviewItems(ctx, &permissionsv1.GetReviewItemsRequest{
+		CampaignId: positional[0],
+		Decision:   *decision,
+	})
+	if err != nil {
+		return err
+	}
+
+	items := make([]models.ReviewItem, len(resp.Items))
+	for i, item := range resp.Items {
+		items[i] = fromProtoReviewItem(item)
+	}
+	return c.printReviewItems(items)
+}
+
+func (c *cli) approveReviewItem(ctx context.Context, args []string) error {
+	return c.decideReviewItem(ctx, "reviews approve", args, c.client.ApproveReviewItem)
+}
+
+func (c *cli) revokeReviewItem(ctx context.Context, args []string) error {
+	return c.decideReviewItem(ctx, "reviews revoke", args, c.client.RevokeReviewItem)
+}
+
+type reviewDecision func(ctx context.Context, req *permissionsv1.DecideReviewItemRequest, opts ...grpc.CallOption) (*permissionsv1.DecideReviewItemResponse, error)
+
+func (c *cli) decideReviewItem(ctx context.Context, name string, args []string, decide reviewDecision) error {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	comment := flags.String("comment", "", "reason for the decision")
+	positional, err := parseArgs(flags, args, 1)
+	if err != nil {
+		return err
+	}
+
+	resp, err := decide(ctx, &permissionsv1.DecideReviewItemRequest{
+		ItemId:  positional[0],
+		Comment: *comment,
+	})
+	if err != nil {
+		return err
+	}
+
+	return c.printReviewItems([]models.ReviewItem{fromProtoReviewItem(resp.Item)})
+}
+
+func (c *cli) printReviewItems(items []models.ReviewItem) error {
+	return c.out.print(items, func(w io.Writer) {
+		row(w, "ID", "SUBJECT", "ROLE", "TENANT", "DECISION", "REVIEWER", "DECIDED")
+		for _, item := range items {
+			decided := "-"
+			if item.DecidedAt != nil {
+				decided = item.DecidedAt.Format(time.RFC3339)
+			}
+			row(w, item.ID.String(), item.Subject, item.Role, orDash(item.Tenant), item.Decision, orDash(item.Reviewer), decided)
+		}
+	})
+}
+
+// exportReviewReport writes the report and, next to it, its base64-encoded
+// signature (.sig) and the public key that verifies it (.pub)
+func (c *cli) exportReviewReport(ctx context.Context, args []string) error {
+	flags := flag.NewFlagSet("reviews report", flag.ContinueOnError)
+	format := flags.String("format", "csv", "document format (csv or json)")
+	file := flags.String("file", "", "write the report to this file")
+	positional, err := parseArgs(flags, args, 1)
+	if err != nil {
+		return err
+	}
+
+	if *file == "" {
+		return fmt.Errorf("-file is required")
+	}
+
+	resp, err := c.client.ExportReviewReport(ctx, &permissionsv1.ExportReviewReportRequest{
+		CampaignId: positional[0],
+		Format:     *format,
+	})
+	if err != nil {
+		return err
+	}
+
+	files := map[string][]byte{
+		*file:          resp.Document,
+		*file + ".sig": []byte(base64.StdEncoding.EncodeToString(resp.Signature) + "\n"),
+		*file + ".pub": []byte(base64.StdEncoding.EncodeToString(resp.PublicKey) + "\n"),
+	}
+	for path, content := range files {
+		if err := os.WriteFile(path, content, 0o644); err != nil {
+			return err
+		}
+	}
+
+	fmt.Fprintf(os.Stderr, "Report written to %s, %s signature in %s.sig.\n", *file, resp.Algorithm, *file)
+	return nil
+}
+
+func fromProtoCampaign(c *permissionsv1.ReviewCampaign) models.ReviewCampaign {
+	return models.ReviewCampaign{
+		ID:        serverID(c.Id),
+		Name:      c.Name,
+		Roles:     c.Roles,
+		Tenants:   c.Tenants,
+		Deadline:  c.Deadline.AsTime(),
+		Status:    c.Status,
+		CreatedBy: c.CreatedBy,
+		CreatedAt: c.CreatedAt.AsTime(),
+		ClosedAt:  optionalTime(c.ClosedAt),
+		ReviewSummary: models.ReviewSummary{
+			Pending:     c.Pending,
+			Approved:    c.Approved,
+			Revoked:     c.Revoked,
+			AutoRevoked: c.AutoRevoked,
+		},
+	}
+}
+
+func fromProtoReviewItem(i *permissionsv1.ReviewItem) models.ReviewItem {
+	return models.ReviewItem{
+		ID:         serverID(i.Id),
+		CampaignID: serverID(i.CampaignId),
+		Subject:    i.Subject,
+		Role:       i.Role,
+		Tenant:     i.Tenant,
+		Decision:   i.Decision,
+		Reviewer:   i.Reviewer,
+		Comment:    i.Comment,
+		DecidedAt:  optionalTime(i.DecidedAt),
+	}
+}
+
+// serverID parses an ID returned by the server, which is always a valid UUID
+func serverID(value string) uuid.UUID {
+	id, _ := uuid.Parse(value)
+	return id
+}
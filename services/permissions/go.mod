@@ -4,18 +4,28 @@ go 1.25.3
 
 require (
 	github.com/google/uuid v1.6.0
+	github.com/intellifinder/v4/libs/auth v0.0.0
+	github.com/intellifinder/v4/libs/observability v0.0.0
 	github.com/jackc/pgx/v5 v5.5.1
+	go.uber.org/zap v1.26.0
 	google.golang.org/grpc v1.76.0
 	google.golang.org/protobuf v1.36.6
+	gopkg.in/yaml.v3 v3.0.1
 	intellifinder/libs/utils v0.0.0
 )
 
 replace intellifinder/libs/utils => ../../libs/utils
 
+replace github.com/intellifinder/v4/libs/observability => ../../libs/observability
+
+replace github.com/intellifinder/v4/libs/auth => ../../libs/auth
+
 require (
+	github.com/golang-jwt/jwt/v5 v5.2.0 // indirect
 	github.com/jackc/pgpassfile v1.0.0 // indirect
 	github.com/jackc/pgservicefile v0.0.0-20221227161230-091c0ba34f0a // indirect
 	github.com/jackc/puddle/v2 v2.2.1 // indirect
+	go.uber.org/multierr v1.10.0 // indirect
 	golang.org/x/crypto v0.40.0 // indirect
 	golang.org/x/net v0.42.0 // indirect
 	golang.org/x/sync v0.16.0 // indirect
@@ -0,0 +1,136 @@
+package permissions
+
+import (
+	"context"
+	"fmt"
+	"intellifinder/services/permissions/pkg/dto"
+	"intellifinder/services/permissions/pkg/models"
+	"slices"
+)
+
+func (s *Service) AssignRole(ctx context.Context, subject string, role string, tenant string) (*models.RoleAssignment, error) {
+	if subject == "" {
+		return nil, fmt.Errorf("subject is required")
+	}
+
+	if _, err := s.GetRole(ctx, role); err != nil {
+		return nil, err
+	}
+
+	assignment := &models.RoleAssignment{
+		Subject: subject,
+		Role:    role,
+		Tenant:  tenant,
+	}
+
+	if err := s.repo.CreateAssignment(ctx, assignment); err != nil {
+		return nil, fmt.Errorf("failed to assign role: %w", err)
+	}
+
+	return assignment, nil
+}
+
+func (s *Service) UnassignRole(ctx context.Context, subject string, role string, tenant string) error {
+	if subject == "" {
+		return fmt.Errorf("subject is required")
+	}
+
+	if role == "" {
+		return fmt.Errorf("role name is required")
+	}
+
+	deleted, err := s.repo.DeleteAssignment(ctx, subject, role, tenant)
+	if err != nil {
+		return fmt.Errorf("failed to unassign role: %w", err)
+	}
+
+	if !deleted {
+		return ErrAssignmentNotFound
+	}
+
+	return nil
+}
+
+func (s *Service) GetAssignments(ctx context.Context, filter dto.AssignmentFilter, page int32, limit int32) (*dto.PaginatedAssignments, error) {
+	if page <= 0 {
+		return nil, fmt.Errorf("page must be greater than 0")
+	}
+
+	if limit <= 0 {
+		return nil, fmt.Errorf("limit must be greater than 0")
+	}
+
+	assignments, err := s.repo.GetAssignments(ctx, filter, page, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get assignments: %w", err)
+	}
+
+	return assignments, nil
+}
+
+// Authorize decides whether subject holds permission through any role assigned
+// globally or within tenant. Aliases are checked as the permission they
+// resolve to. The decision lists every evaluated role so callers can explain
+// why access was granted or denied.
+func (s *Service) Authorize(ctx context.Context, subject string, permission string, tenant string) (*dto.AuthorizationDecision, error) {
+	decision, err := s.ExplainAuthorization(ctx, subject, permission, tenant)
+	if err != nil {
+		return nil, err
+	}
+
+	if s.usage != nil && decision.PermissionRegistered {
+		s.usage.Record(decision.CanonicalPermission, decision.MatchedRoles)
+	}
+
+	return decision, nil
+}
+
+// ExplainAuthorization evaluates a check like Authorize without counting it as
+// usage of the permission
+func (s *Service) ExplainAuthorization(ctx context.Context, subject string, permission string, tenant string) (*dto.AuthorizationDecision, error) {
+	if subject == "" {
+		return nil, fmt.Errorf("subject is required")
+	}
+
+	if err := s.validatePermission(permission); err != nil {
+		return nil, err
+	}
+
+	resolved, err := s.ResolvePermission(ctx, permission)
+	if err != nil {
+		return nil, err
+	}
+
+	canonical := permission
+	if resolved != nil {
+		canonical = resolved.Name()
+	}
+
+	roles, err := s.repo.GetSubjectRoles(ctx, subject, tenant)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get subject roles: %w", err)
+	}
+
+	decision := &dto.AuthorizationDecision{
+		Subject:              subject,
+		Permission:           permission,
+		Tenant:               tenant,
+		CanonicalPermission:  canonical,
+		PermissionRegistered: resolved != nil,
+		Deprecated:           resolved != nil && resolved.Deprecated(),
+		MatchedRoles:         []string{},
+		Evaluated:            make([]dto.RoleEvaluation, len(roles)),
+	}
+
+	for i, role := range roles {
+		granted := slices.Contains(role.Permissions, canonical)
+		decision.Evaluated[i] = dto.RoleEvaluation{Role: role.Name, Granted: granted}
+
+		if granted {
+			decision.MatchedRoles = append(decision.MatchedRoles, role.Name)
+		}
+	}
+	decision.Allowed = len(decision.MatchedRoles) > 0
+
+	return decision, nil
+}
@@ -0,0 +1,26 @@
+package permissions
+
+import "errors"
+
+var (
+	ErrPermissionNotFound = errors.New("permission not found")
+	ErrPermissionInUse    = errors.New("permission is granted by roles")
+	ErrInvalidAlias       = errors.New("invalid permission alias")
+
+	ErrRoleNotFound = errors.New("role not found")
+	ErrRoleExists   = errors.New("role already exists")
+	ErrRoleReadOnly = errors.New("role is managed by a role file and is read-only")
+	ErrInvalidRole  = errors.New("invalid role definition")
+
+	ErrAssignmentNotFound = errors.New("role assignment not found")
+
+	ErrInvalidModel = errors.New("invalid authorization model")
+
+	ErrCampaignNotFound   = errors.New("review campaign not found")
+	ErrCampaignClosed     = errors.New("review campaign is closed")
+	ErrInvalidCampaign    = errors.New("invalid review campaign")
+	ErrReviewItemNotFound = errors.New("review item not found")
+	ErrReviewItemDecided  = errors.New("review item has already been decided")
+	ErrSelfReview         = errors.New("reviewers can't review their own access")
+	ErrReportSigningKey   = errors.New("review report signing key is not configured")
+)
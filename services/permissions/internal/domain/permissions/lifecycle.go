@@ -0,0 +1,177 @@
+package permissions
+
+import (
+	"context"
+	"fmt"
+	"intellifinder/services/permissions/pkg/dto"
+	"intellifinder/services/permissions/pkg/models"
+	"strings"
+)
+
+// ResolvePermission returns the registered permission a name refers to,
+// following an alias if the name was renamed. It returns nil if the name is
+// neither a permission nor an alias.
+func (s *Service) ResolvePermission(ctx context.Context, permission string) (*models.Permission, error) {
+	if err := s.validatePermission(permission); err != nil {
+		return nil, err
+	}
+
+	service, action, _ := strings.Cut(permission, ":")
+	perm, err := s.repo.GetPermission(ctx, service, action)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get permission: %w", err)
+	}
+
+	if perm != nil {
+		return perm, nil
+	}
+
+	alias, err := s.repo.GetPermissionAlias(ctx, permission)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get permission alias: %w", err)
+	}
+
+	if alias == nil {
+		return nil, nil
+	}
+
+	service, action, _ = strings.Cut(alias.Permission, ":")
+	perm, err = s.repo.GetPermission(ctx, service, action)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get permission: %w", err)
+	}
+
+	return perm, nil
+}
+
+// DeprecatePermission flags a permission for removal. Deprecated permissions
+// keep working; listings mark them and checks against them are logged so the
+// remaining callers can be found.
+func (s *Service) DeprecatePermission(ctx context.Context, permission string, reason string) error {
+	if err := s.validatePermission(permission); err != nil {
+		return err
+	}
+
+	service, action, _ := strings.Cut(permission, ":")
+	found, err := s.repo.DeprecatePermission(ctx, service, action, reason)
+	if err != nil {
+		return fmt.Errorf("failed to deprecate permission: %w", err)
+	}
+
+	if !found {
+		return fmt.Errorf("%w: %s", ErrPermissionNotFound, permission)
+	}
+
+	return nil
+}
+
+// DeletePermission removes a permission from the catalogue. It refuses while
+// roles grant the permission unless force is set, in which case the bindings
+// are removed too.
+func (s *Service) DeletePermission(ctx context.Context, permission string, force bool) (*dto.PermissionDeletion, error) {
+	if err := s.validatePermission(permission); err != nil {
+		return nil, err
+	}
+
+	service, action, _ := strings.Cut(permission, ":")
+	roles, err := s.repo.GetPermissionRoles(ctx, service, action)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get permission roles: %w", err)
+	}
+
+	if len(roles) > 0 && !force {
+		return nil, fmt.Errorf("%w: %s is granted by %s", ErrPermissionInUse, permission, strings.Join(roles, ", "))
+	}
+
+	found, err := s.repo.DeletePermission(ctx, service, action, force)
+	if err != nil {
+		return nil, fmt.Errorf("failed to delete permission: %w", err)
+	}
+
+	if !found {
+		return nil, fmt.Errorf("%w: %s", ErrPermissionNotFound, permission)
+	}
+
+	return &dto.PermissionDeletion{
+		Permission:       permission,
+		RemovedFromRoles: roles,
+	}, nil
+}
+
+// CreatePermissionAlias renames alias to permission. Roles granting alias are
+// migrated to permission, and checks, role definitions and registrations
+// using the old name keep working through the alias.
+func (s *Service) CreatePermissionAlias(ctx context.Context, alias string, permission string) (*dto.PermissionAliasResult, error) {
+	if err := s.validatePermission(alias); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidAlias, err)
+	}
+
+	if err := s.validatePermission(permission); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidAlias, err)
+	}
+
+	if alias == permission {
+		return nil, fmt.Errorf("%w: %s can't be an alias of itself", ErrInvalidAlias, alias)
+	}
+
+	service, action, _ := strings.Cut(permission, ":")
+	target, err := s.repo.GetPermission(ctx, service, action)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get permission: %w", err)
+	}
+
+	if target == nil {
+		return nil, fmt.Errorf("%w: %s", ErrPermissionNotFound, permission)
+	}
+
+	existing, err := s.repo.GetPermissionAlias(ctx, alias)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get permission alias: %w", err)
+	}
+
+	if existing != nil {
+		if existing.Permission != permission {
+			return nil, fmt.Errorf("%w: %s is already an alias of %s", ErrInvalidAlias, alias, existing.Permission)
+		}
+		return &dto.PermissionAliasResult{Alias: *existing, MigratedRoles: []string{}}, nil
+	}
+
+	migrated, err := s.repo.CreatePermissionAlias(ctx, alias, permission)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create permission alias: %w", err)
+	}
+
+	created, err := s.repo.GetPermissionAlias(ctx, alias)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get permission alias: %w", err)
+	}
+
+	return &dto.PermissionAliasResult{
+		Alias:         *created,
+		MigratedRoles: migrated,
+	}, nil
+}
+
+func (s *Service) GetPermissionAliases(ctx context.Context) ([]models.PermissionAlias, error) {
+	aliases, err := s.repo.ListPermissionAliases(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list permission aliases: %w", err)
+	}
+
+	return aliases, nil
+}
+
+// aliasTargets maps every alias to the permission it resolves to
+func (s *Service) aliasTargets(ctx context.Context) (map[string]string, error) {
+	aliases, err := s.repo.ListPermissionAliases(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list permission aliases: %w", err)
+	}
+
+	targets := make(map[string]string, len(aliases))
+	for _, alias := range aliases {
+		targets[alias.Alias] = alias.Permission
+	}
+
+	return targets, nil
+}
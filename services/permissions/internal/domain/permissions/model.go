@@ -0,0 +1,527 @@
+package permissions
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"intellifinder/services/permissions/pkg/dto"
+	"intellifinder/services/permissions/pkg/models"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	FormatYAML = "yaml"
+	FormatJSON = "json"
+)
+
+// ImportOptions controls how ImportModel applies a document
+type ImportOptions struct {
+	// DryRun computes the diff without changing anything
+	DryRun bool
+	// Prune removes permissions, API-managed roles, assignments and
+	// deprecations that are not part of the document
+	Prune bool
+}
+
+// ExportModel builds a snapshot of the current authorization model
+func (s *Service) ExportModel(ctx context.Context) (*models.AuthorizationModel, error) {
+	permissions, err := s.repo.ListPermissions(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list permissions: %w", err)
+	}
+
+	keys := make([]string, len(permissions))
+	for i, perm := range permissions {
+		keys[i] = perm.Service + ":" + perm.Action
+	}
+	sort.Strings(keys)
+
+	deprecations := []models.ModelDeprecation{}
+	for _, perm := range permissions {
+		if perm.Deprecated() {
+			deprecations = append(deprecations, models.ModelDeprecation{Permission: perm.Name(), Reason: perm.DeprecationReason})
+		}
+	}
+	sort.Slice(deprecations, func(i, j int) bool {
+		return deprecations[i].Permission < deprecations[j].Permission
+	})
+
+	aliases, err := s.repo.ListPermissionAliases(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list permission aliases: %w", err)
+	}
+
+	modelAliases := make([]models.ModelAlias, len(aliases))
+	for i, alias := range aliases {
+		modelAliases[i] = models.ModelAlias{Alias: alias.Alias, Permission: alias.Permission}
+	}
+	sort.Slice(modelAliases, func(i, j int) bool {
+		return modelAliases[i].Alias < modelAliases[j].Alias
+	})
+
+	roles, err := s.repo.ListRoles(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list roles: %w", err)
+	}
+
+	modelRoles := []models.ModelRole{}
+	for _, role := range roles {
+		if role.ReadOnly() {
+			continue
+		}
+		modelRoles = append(modelRoles, models.ModelRole{
+			Name:        role.Name,
+			Description: role.Description,
+			Permissions: role.Permissions,
+		})
+	}
+
+	assignments, err := s.repo.ListAssignments(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list role assignments: %w", err)
+	}
+
+	modelAssignments := make([]models.ModelAssignment, len(assignments))
+	for i, assignment := range assignments {
+		modelAssignments[i] = modelAssignment(assignment)
+	}
+
+	return &models.AuthorizationModel{
+		Version:      models.ModelVersion,
+		ExportedAt:   time.Now().UTC(),
+		Permissions:  keys,
+		Aliases:      modelAliases,
+		Deprecations: deprecations,
+		Roles:        modelRoles,
+		Assignments:  modelAssignments,
+	}, nil
+}
+
+// ImportModel reconciles the stored model with the given document. All
+// changes are applied in a single transaction, so a failed import leaves the
+// model untouched. Version 1 documents only carry permissions and leave
+// roles and assignments alone; version 1 and 2 documents leave aliases and
+// deprecations alone. Aliases are never removed, as callers may still use
+// the old names.
+func (s *Service) ImportModel(ctx context.Context, model *models.AuthorizationModel, opts ImportOptions) (*dto.ModelDiff, error) {
+	if model.Version < 1 || model.Version > models.ModelVersion {
+		return nil, fmt.Errorf("%w: unsupported model version %d, expected %d", ErrInvalidModel, model.Version, models.ModelVersion)
+	}
+
+	targets, err := s.aliasTargets(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	diff := &dto.ModelDiff{
+		Added:               []string{},
+		Removed:             []string{},
+		AliasesAdded:        []models.ModelAlias{},
+		DeprecationsAdded:   []string{},
+		DeprecationsRemoved: []string{},
+		RolesAdded:          []string{},
+		RolesUpdated:        []string{},
+		RolesRemoved:        []string{},
+		AssignmentsAdded:    []models.ModelAssignment{},
+		AssignmentsRemoved:  []models.ModelAssignment{},
+	}
+
+	// renamed holds the permissions the document's new aliases rename
+	renamed := make(map[string]bool)
+	if model.Version > 2 {
+		if err := s.diffAliases(model.Aliases, targets, diff); err != nil {
+			return nil, err
+		}
+		for _, alias := range diff.AliasesAdded {
+			renamed[alias.Alias] = true
+		}
+	}
+
+	// Renamed permissions count as their replacement
+	desired := make(map[string]bool, len(model.Permissions))
+	for _, permission := range model.Permissions {
+		if err := s.validatePermission(permission); err != nil {
+			return nil, fmt.Errorf("%w: permission %s: %w", ErrInvalidModel, permission, err)
+		}
+		if target, ok := targets[permission]; ok {
+			permission = target
+		}
+		desired[permission] = true
+	}
+
+	current, err := s.repo.ListPermissions(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list permissions: %w", err)
+	}
+
+	// known holds the permissions that exist once the import is applied
+	known := make(map[string]bool, len(current)+len(desired))
+	existing := make(map[string]bool, len(current))
+	for _, perm := range current {
+		key := perm.Service + ":" + perm.Action
+		existing[key] = true
+
+		// A renamed permission's bindings move to the permission replacing it
+		if renamed[key] {
+			continue
+		}
+
+		if desired[key] {
+			diff.Unchanged++
+			known[key] = true
+		} else if opts.Prune {
+			diff.Removed = append(diff.Removed, key)
+		} else {
+			known[key] = true
+		}
+	}
+
+	for permission := range desired {
+		known[permission] = true
+		if !existing[permission] {
+			diff.Added = append(diff.Added, permission)
+		}
+	}
+	sort.Strings(diff.Added)
+	sort.Strings(diff.Removed)
+
+	var errs []error
+	for _, alias := range diff.AliasesAdded {
+		if !known[alias.Permission] {
+			errs = append(errs, fmt.Errorf("alias %s: permission %s is not registered", alias.Alias, alias.Permission))
+		}
+	}
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidModel, errors.Join(errs...))
+	}
+
+	changes := ModelChanges{AddPermissions: diff.Added, AddAliases: diff.AliasesAdded, RemovePermissions: diff.Removed}
+	if model.Version > 2 {
+		if err := s.diffDeprecations(model.Deprecations, current, known, targets, opts.Prune, diff, &changes); err != nil {
+			return nil, err
+		}
+	}
+
+	roles, err := s.repo.ListRoles(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list roles: %w", err)
+	}
+
+	// final holds the roles that exist once the import is applied
+	final := make(map[string]models.Role, len(roles))
+	for _, role := range roles {
+		final[role.Name] = role
+	}
+
+	if model.Version > 1 {
+		if err := s.diffRoles(model.Roles, known, targets, final, opts.Prune, diff, &changes); err != nil {
+			return nil, err
+		}
+
+		assignments, err := s.repo.ListAssignments(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list role assignments: %w", err)
+		}
+
+		if err := diffAssignments(model.Assignments, assignments, final, opts.Prune, diff, &changes); err != nil {
+			return nil, err
+		}
+	}
+
+	// Pruning a permission must not silently strip it from roles that stay,
+	// which can only be file-managed roles or roles a version 1 document
+	// leaves alone
+	for _, permission := range diff.Removed {
+		var granting []string
+		for _, role := range final {
+			if slices.Contains(role.Permissions, permission) {
+				granting = append(granting, role.Name)
+			}
+		}
+		if len(granting) > 0 {
+			sort.Strings(granting)
+			errs = append(errs, fmt.Errorf("%w: %s is granted by %s", ErrPermissionInUse, permission, strings.Join(granting, ", ")))
+		}
+	}
+	if len(errs) > 0 {
+		return nil, errors.Join(errs...)
+	}
+
+	if opts.DryRun || diff.Empty() {
+		return diff, nil
+	}
+
+	if err := s.repo.ApplyModelChanges(ctx, changes); err != nil {
+		return nil, fmt.Errorf("failed to apply model: %w", err)
+	}
+	diff.Applied = true
+
+	return diff, nil
+}
+
+// diffAliases plans the aliases an import adds and makes targets resolve them
+func (s *Service) diffAliases(modelAliases []models.ModelAlias, targets map[string]string, diff *dto.ModelDiff) error {
+	var errs []error
+	seen := make(map[string]bool, len(modelAliases))
+	for _, alias := range modelAliases {
+		if err := s.validatePermission(alias.Alias); err != nil {
+			errs = append(errs, fmt.Errorf("alias %s: %w", alias.Alias, err))
+			continue
+		}
+		if err := s.validatePermission(alias.Permission); err != nil {
+			errs = append(errs, fmt.Errorf("alias %s: %w", alias.Alias, err))
+			continue
+		}
+		if alias.Alias == alias.Permission {
+			errs = append(errs, fmt.Errorf("alias %s can't be an alias of itself", alias.Alias))
+			continue
+		}
+		if seen[alias.Alias] {
+			errs = append(errs, fmt.Errorf("alias %s is defined more than once", alias.Alias))
+			continue
+		}
+		seen[alias.Alias] = true
+
+		target, ok := targets[alias.Alias]
+		switch {
+		case !ok:
+			targets[alias.Alias] = alias.Permission
+			diff.AliasesAdded = append(diff.AliasesAdded, alias)
+		case target == alias.Permission:
+			diff.AliasesUnchanged++
+		default:
+			errs = append(errs, fmt.Errorf("alias %s already resolves to %s", alias.Alias, target))
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%w: %w", ErrInvalidModel, errors.Join(errs...))
+	}
+
+	sort.Slice(diff.AliasesAdded, func(i, j int) bool {
+		return diff.AliasesAdded[i].Alias < diff.AliasesAdded[j].Alias
+	})
+	return nil
+}
+
+// diffDeprecations plans the deprecation changes of an import. Every
+// deprecated permission must exist once the import is applied.
+func (s *Service) diffDeprecations(modelDeprecations []models.ModelDeprecation, current []models.Permission, known map[string]bool, targets map[string]string, prune bool, diff *dto.ModelDiff, changes *ModelChanges) error {
+	var errs []error
+	desired := make(map[string]string, len(modelDeprecations))
+	for _, deprecation := range modelDeprecations {
+		permission := deprecation.Permission
+		if target, ok := targets[permission]; ok {
+			permission = target
+		}
+
+		if err := s.validatePermission(permission); err != nil {
+			errs = append(errs, fmt.Errorf("deprecation of %s: %w", deprecation.Permission, err))
+			continue
+		}
+		if !known[permission] {
+			errs = append(errs, fmt.Errorf("deprecation of %s: permission is not registered", deprecation.Permission))
+			continue
+		}
+		if _, ok := desired[permission]; ok {
+			errs = append(errs, fmt.Errorf("permission %s is deprecated more than once", permission))
+			continue
+		}
+		desired[permission] = deprecation.Reason
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%w: %w", ErrInvalidModel, errors.Join(errs...))
+	}
+
+	deprecated := make(map[string]string, len(current))
+	for _, perm := range current {
+		if perm.Deprecated() {
+			deprecated[perm.Name()] = perm.DeprecationReason
+		}
+	}
+
+	for permission, reason := range desired {
+		if old, ok := deprecated[permission]; ok && old == reason {
+			diff.DeprecationsUnchanged++
+		} else {
+			diff.DeprecationsAdded = append(diff.DeprecationsAdded, permission)
+		}
+	}
+
+	if prune {
+		for permission := range deprecated {
+			if _, ok := desired[permission]; !ok && known[permission] {
+				diff.DeprecationsRemoved = append(diff.DeprecationsRemoved, permission)
+			}
+		}
+	}
+
+	sort.Strings(diff.DeprecationsAdded)
+	sort.Strings(diff.DeprecationsRemoved)
+
+	for _, permission := range diff.DeprecationsAdded {
+		changes.DeprecatePermissions = append(changes.DeprecatePermissions, models.ModelDeprecation{Permission: permission, Reason: desired[permission]})
+	}
+	changes.RestorePermissions = diff.DeprecationsRemoved
+
+	return nil
+}
+
+// diffRoles plans the role changes of an import and applies them to final
+func (s *Service) diffRoles(modelRoles []models.ModelRole, known map[string]bool, targets map[string]string, final map[string]models.Role, prune bool, diff *dto.ModelDiff, changes *ModelChanges) error {
+	roles := make([]models.Role, len(modelRoles))
+	for i, role := range modelRoles {
+		roles[i] = models.Role{
+			Name:        role.Name,
+			Description: role.Description,
+			Permissions: slices.Clone(role.Permissions),
+		}
+	}
+
+	if err := s.checkRoles(roles, known, targets); err != nil {
+		return err
+	}
+
+	var errs []error
+	desired := make(map[string]bool, len(roles))
+	for _, role := range roles {
+		desired[role.Name] = true
+
+		old, ok := final[role.Name]
+		switch {
+		case !ok:
+			changes.CreateRoles = append(changes.CreateRoles, role)
+			diff.RolesAdded = append(diff.RolesAdded, role.Name)
+		case old.ReadOnly():
+			errs = append(errs, fmt.Errorf("%w: role %s is defined in %s", ErrRoleReadOnly, role.Name, old.Source))
+			continue
+		case roleChanged(old, role):
+			changes.UpdateRoles = append(changes.UpdateRoles, role)
+			diff.RolesUpdated = append(diff.RolesUpdated, role.Name)
+		default:
+			diff.RolesUnchanged++
+		}
+		final[role.Name] = role
+	}
+
+	if len(errs) > 0 {
+		return errors.Join(errs...)
+	}
+
+	if prune {
+		for name, role := range final {
+			if !role.ReadOnly() && !desired[name] {
+				diff.RolesRemoved = append(diff.RolesRemoved, name)
+				delete(final, name)
+			}
+		}
+	}
+
+	sort.Strings(diff.RolesAdded)
+	sort.Strings(diff.RolesUpdated)
+	sort.Strings(diff.RolesRemoved)
+	changes.RemoveRoles = diff.RolesRemoved
+
+	return nil
+}
+
+// diffAssignments plans the assignment changes of an import. Every assignment
+// in the document must refer to a role that exists once the import is applied.
+func diffAssignments(modelAssignments []models.ModelAssignment, current []models.RoleAssignment, final map[string]models.Role, prune bool, diff *dto.ModelDiff, changes *ModelChanges) error {
+	var errs []error
+	desired := make(map[models.ModelAssignment]bool, len(modelAssignments))
+	for _, assignment := range modelAssignments {
+		if assignment.Subject == "" {
+			errs = append(errs, fmt.Errorf("assignment of role %q: subject is required", assignment.Role))
+			continue
+		}
+		if _, ok := final[assignment.Role]; !ok {
+			errs = append(errs, fmt.Errorf("assignment of %s: role %q does not exist", assignment.Subject, assignment.Role))
+			continue
+		}
+		desired[assignment] = true
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%w: %w", ErrInvalidModel, errors.Join(errs...))
+	}
+
+	existing := make(map[models.ModelAssignment]bool, len(current))
+	for _, assignment := range current {
+		key := modelAssignment(assignment)
+		existing[key] = true
+
+		if desired[key] {
+			diff.AssignmentsUnchanged++
+		} else if prune {
+			diff.AssignmentsRemoved = append(diff.AssignmentsRemoved, key)
+		}
+	}
+
+	for assignment := range desired {
+		if !existing[assignment] {
+			diff.AssignmentsAdded = append(diff.AssignmentsAdded, assignment)
+		}
+	}
+	sortModelAssignments(diff.AssignmentsAdded)
+	sortModelAssignments(diff.AssignmentsRemoved)
+
+	for _, assignment := range diff.AssignmentsAdded {
+		changes.AddAssignments = append(changes.AddAssignments, roleAssignment(assignment))
+	}
+	for _, assignment := range diff.AssignmentsRemoved {
+		changes.RemoveAssignments = append(changes.RemoveAssignments, roleAssignment(assignment))
+	}
+
+	return nil
+}
+
+func modelAssignment(assignment models.RoleAssignment) models.ModelAssignment {
+	return models.ModelAssignment{Subject: assignment.Subject, Role: assignment.Role, Tenant: assignment.Tenant}
+}
+
+func roleAssignment(assignment models.ModelAssignment) models.RoleAssignment {
+	return models.RoleAssignment{Subject: assignment.Subject, Role: assignment.Role, Tenant: assignment.Tenant}
+}
+
+func sortModelAssignments(assignments []models.ModelAssignment) {
+	sort.Slice(assignments, func(i, j int) bool {
+		a, b := assignments[i], assignments[j]
+		if a.Subject != b.Subject {
+			return a.Subject < b.Subject
+		}
+		if a.Role != b.Role {
+			return a.Role < b.Role
+		}
+		return a.Tenant < b.Tenant
+	})
+}
+
+// EncodeModel serialises a model document as YAML or JSON
+func EncodeModel(model *models.AuthorizationModel, format string) ([]byte, error) {
+	switch format {
+	case "", FormatYAML:
+		return yaml.Marshal(model)
+	case FormatJSON:
+		return json.MarshalIndent(model, "", "  ")
+	default:
+		return nil, fmt.Errorf("unsupported format %q, expected %q or %q", format, FormatYAML, FormatJSON)
+	}
+}
+
+// DecodeModel parses a model document. JSON is a subset of YAML, so both
+// formats are accepted.
+func DecodeModel(data []byte) (*models.AuthorizationModel, error) {
+	var model models.AuthorizationModel
+	if err := yaml.Unmarshal(data, &model); err != nil {
+		return nil, fmt.Errorf("failed to parse model document: %w", err)
+	}
+	return &model, nil
+}
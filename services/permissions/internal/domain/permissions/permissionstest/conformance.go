@@ -0,0 +1,1042 @@
+// Package permissionstest provides a conformance suite that every
+// permissions.Repository implementation must pass, so the in-memory fake and
+// the Postgres repository are guaranteed to behave the same.
+package permissionstest
+
+import (
+	"context"
+	"intellifinder/services/permissions/internal/domain/permissions"
+	"intellifinder/services/permissions/pkg/dto"
+	"intellifinder/services/permissions/pkg/models"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RepositoryConformance runs the suite. newRepository must return an empty
+// repository for every call.
+func RepositoryConformance(t *testing.T, newRepository func(t *testing.T) permissions.Repository) {
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, repo permissions.Repository)
+	}{
+		{"RegisterIsIdempotent", testRegisterIsIdempotent},
+		{"RegisterRejectsInvalidPermission", testRegisterRejectsInvalidPermission},
+		{"PermissionExists", testPermissionExists},
+		{"ServicePermissionsPagination", testServicePermissionsPagination},
+		{"PermissionsNewestFirst", testPermissionsNewestFirst},
+		{"ListPermissionsOrdered", testListPermissionsOrdered},
+		{"ApplyModelChanges", testApplyModelChanges},
+		{"ApplyModelChangesIsAtomic", testApplyModelChangesIsAtomic},
+		{"ApplyModelChangesAliasesAndDeprecations", testApplyModelChangesAliasesAndDeprecations},
+		{"DeprecatePermission", testDeprecatePermission},
+		{"DeletePermission", testDeletePermission},
+		{"CreatePermissionAliasMigratesBindings", testCreatePermissionAliasMigratesBindings},
+		{"DeletePermissionRemovesAliases", testDeletePermissionRemovesAliases},
+		{"CreateAndGetRole", testCreateAndGetRole},
+		{"CreateRoleRejectsDuplicates", testCreateRoleRejectsDuplicates},
+		{"CreateRoleRejectsUnknownPermission", testCreateRoleRejectsUnknownPermission},
+		{"UpdateRole", testUpdateRole},
+		{"DeleteRole", testDeleteRole},
+		{"RolesPagination", testRolesPagination},
+		{"ApplyRoleChangesIsAtomic", testApplyRoleChangesIsAtomic},
+		{"CreateAssignmentIsIdempotent", testCreateAssignmentIsIdempotent},
+		{"CreateAssignmentRequiresRole", testCreateAssignmentRequiresRole},
+		{"GetAssignmentsFilters", testGetAssignmentsFilters},
+		{"DeleteAssignment", testDeleteAssignment},
+		{"SubjectRolesByTenant", testSubjectRolesByTenant},
+		{"DeleteRoleRemovesAssignments", testDeleteRoleRemovesAssignments},
+		{"RecordUsageAccumulates", testRecordUsageAccumulates},
+		{"ListPermissionUsageIncludesUnused", testListPermissionUsageIncludesUnused},
+		{"ReviewCampaignSnapshotsScope", testReviewCampaignSnapshotsScope},
+		{"DecideReviewItem", testDecideReviewItem},
+		{"CloseReviewCampaignAutoRevokes", testCloseReviewCampaignAutoRevokes},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, newRepository(t))
+		})
+	}
+}
+
+func register(t *testing.T, repo permissions.Repository, perms ...string) {
+	t.Helper()
+	if err := repo.RegisterServicePermissions(context.Background(), "", perms); err != nil {
+		t.Fatalf("RegisterServicePermissions() error = %v", err)
+	}
+}
+
+func keys(perms []models.Permission) []string {
+	out := make([]string, len(perms))
+	for i, perm := range perms {
+		out[i] = perm.Service + ":" + perm.Action
+	}
+	return out
+}
+
+func testRegisterIsIdempotent(t *testing.T, repo permissions.Repository) {
+	ctx := context.Background()
+	register(t, repo, "tasks:read", "tasks:create")
+	register(t, repo, "tasks:read", "tasks:create", "tasks:read")
+
+	result, err := repo.GetAllPermissions(ctx, 1, 10)
+	if err != nil {
+		t.Fatalf("GetAllPermissions() error = %v", err)
+	}
+	if result.TotalCount != 2 {
+		t.Errorf("TotalCount = %v, want %v", result.TotalCount, 2)
+	}
+}
+
+func testRegisterRejectsInvalidPermission(t *testing.T, repo permissions.Repository) {
+	ctx := context.Background()
+	err := repo.RegisterServicePermissions(ctx, "tasks", []string{"tasks:read", "invalid"})
+	if err == nil {
+		t.Fatal("RegisterServicePermissions() error = nil, want error")
+	}
+
+	exists, err := repo.PermissionExistsByServiceAndAction(ctx, "tasks", "read")
+	if err != nil {
+		t.Fatalf("PermissionExistsByServiceAndAction() error = %v", err)
+	}
+	if exists {
+		t.Error("tasks:read was registered although the batch was rejected")
+	}
+}
+
+func testPermissionExists(t *testing.T, repo permissions.Repository) {
+	ctx := context.Background()
+	register(t, repo, "auth:read")
+
+	exists, err := repo.PermissionExistsByServiceAndAction(ctx, "auth", "read")
+	if err != nil || !exists {
+		t.Errorf("PermissionExistsByServiceAndAction(auth, read) = %v, %v, want true", exists, err)
+	}
+
+	exists, err = repo.PermissionExistsByServiceAndAction(ctx, "auth", "write")
+	if err != nil || exists {
+		t.Errorf("PermissionExistsByServiceAndAction(auth, write) = %v, %v, want false", exists, err)
+	}
+}
+
+func testServicePermissionsPagination(t *testing.T, repo permissions.Repository) {
+	ctx := context.Background()
+	register(t, repo, "tasks:read", "tasks:create", "tasks:delete", "auth:read")
+
+	first, err := repo.GetServicePermissions(ctx, "tasks", 1, 2)
+	if err != nil {
+		t.Fatalf("GetServicePermissions() error = %v", err)
+	}
+	if len(first.Permissions) != 2 || first.TotalCount != 3 || first.LastPage != 2 {
+		t.Errorf("page 1 = %d permissions, total %d, last page %d, want 2, 3, 2",
+			len(first.Permissions), first.TotalCount, first.LastPage)
+	}
+
+	second, err := repo.GetServicePermissions(ctx, "tasks", 2, 2)
+	if err != nil {
+		t.Fatalf("GetServicePermissions() error = %v", err)
+	}
+	if len(second.Permissions) != 1 {
+		t.Errorf("page 2 = %d permissions, want 1", len(second.Permissions))
+	}
+
+	all := append(keys(first.Permissions), keys(second.Permissions)...)
+	slices.Sort(all)
+	if want := []string{"tasks:create", "tasks:delete", "tasks:read"}; !slices.Equal(all, want) {
+		t.Errorf("permissions = %v, want %v", all, want)
+	}
+
+	empty, err := repo.GetServicePermissions(ctx, "tags", 1, 10)
+	if err != nil {
+		t.Fatalf("GetServicePermissions() error = %v", err)
+	}
+	if len(empty.Permissions) != 0 || empty.TotalCount != 0 || empty.LastPage != 1 {
+		t.Errorf("unknown service = %d permissions, total %d, last page %d, want 0, 0, 1",
+			len(empty.Permissions), empty.TotalCount, empty.LastPage)
+	}
+}
+
+func testPermissionsNewestFirst(t *testing.T, repo permissions.Repository) {
+	ctx := context.Background()
+	register(t, repo, "tasks:read")
+	time.Sleep(2 * time.Millisecond)
+	register(t, repo, "tasks:create")
+
+	result, err := repo.GetAllPermissions(ctx, 1, 10)
+	if err != nil {
+		t.Fatalf("GetAllPermissions() error = %v", err)
+	}
+	if got := keys(result.Permissions); !slices.Equal(got, []string{"tasks:create", "tasks:read"}) {
+		t.Errorf("permissions = %v, want newest first", got)
+	}
+}
+
+func testListPermissionsOrdered(t *testing.T, repo permissions.Repository) {
+	register(t, repo, "tasks:read", "auth:write", "auth:read")
+
+	perms, err := repo.ListPermissions(context.Background())
+	if err != nil {
+		t.Fatalf("ListPermissions() error = %v", err)
+	}
+	if got, want := keys(perms), []string{"auth:read", "auth:write", "tasks:read"}; !slices.Equal(got, want) {
+		t.Errorf("ListPermissions() = %v, want %v", got, want)
+	}
+}
+
+func testApplyModelChanges(t *testing.T, repo permissions.Repository) {
+	ctx := context.Background()
+	register(t, repo, "tasks:read", "tasks:legacy")
+	createRole(t, repo, "legacy", "tasks:legacy")
+	createRole(t, repo, "reader", "tasks:read")
+	assign(t, repo, "alice", "legacy", "")
+	assign(t, repo, "bob", "reader", "")
+
+	err := repo.ApplyModelChanges(ctx, permissions.ModelChanges{
+		AddPermissions:    []string{"tasks:create"},
+		CreateRoles:       []models.Role{{Name: "creator", Permissions: []string{"tasks:create"}}},
+		UpdateRoles:       []models.Role{{Name: "reader", Description: "Reads tasks", Permissions: []string{"tasks:create", "tasks:read"}}},
+		AddAssignments:    []models.RoleAssignment{{Subject: "alice", Role: "creator", Tenant: "acme"}},
+		RemoveAssignments: []models.RoleAssignment{{Subject: "bob", Role: "reader"}},
+		RemoveRoles:       []string{"legacy"},
+		RemovePermissions: []string{"tasks:legacy"},
+	})
+	if err != nil {
+		t.Fatalf("ApplyModelChanges() error = %v", err)
+	}
+
+	perms, err := repo.ListPermissions(ctx)
+	if err != nil {
+		t.Fatalf("ListPermissions() error = %v", err)
+	}
+	if got, want := keys(perms), []string{"tasks:create", "tasks:read"}; !slices.Equal(got, want) {
+		t.Errorf("ListPermissions() = %v, want %v", got, want)
+	}
+
+	roles, err := repo.ListRoles(ctx)
+	if err != nil {
+		t.Fatalf("ListRoles() error = %v", err)
+	}
+	if len(roles) != 2 || roles[0].Name != "creator" || roles[1].Description != "Reads tasks" || len(roles[1].Permissions) != 2 {
+		t.Errorf("ListRoles() = %+v, want creator and the updated reader", roles)
+	}
+
+	assignments, err := repo.ListAssignments(ctx)
+	if err != nil {
+		t.Fatalf("ListAssignments() error = %v", err)
+	}
+	if len(assignments) != 1 || assignments[0].Subject != "alice" || assignments[0].Role != "creator" || assignments[0].Tenant != "acme" {
+		t.Errorf("ListAssignments() = %+v, want alice's creator assignment in acme", assignments)
+	}
+}
+
+func testApplyModelChangesAliasesAndDeprecations(t *testing.T, repo permissions.Repository) {
+	ctx := context.Background()
+	register(t, repo, "tasks:read", "tasks:edit", "tasks:legacy")
+	createRole(t, repo, "editor", "tasks:edit")
+	if _, err := repo.DeprecatePermission(ctx, "tasks", "read", "use tasks:list"); err != nil {
+		t.Fatalf("DeprecatePermission() error = %v", err)
+	}
+
+	err := repo.ApplyModelChanges(ctx, permissions.ModelChanges{
+		AddPermissions:       []string{"tasks:update"},
+		AddAliases:           []models.ModelAlias{{Alias: "tasks:edit", Permission: "tasks:update"}},
+		DeprecatePermissions: []models.ModelDeprecation{{Permission: "tasks:legacy", Reason: "no longer checked"}},
+		RestorePermissions:   []string{"tasks:read"},
+	})
+	if err != nil {
+		t.Fatalf("ApplyModelChanges() error = %v", err)
+	}
+
+	perms, err := repo.ListPermissions(ctx)
+	if err != nil {
+		t.Fatalf("ListPermissions() error = %v", err)
+	}
+	if got, want := keys(perms), []string{"tasks:legacy", "tasks:read", "tasks:update"}; !slices.Equal(got, want) {
+		t.Errorf("ListPermissions() = %v, want %v", got, want)
+	}
+	for _, perm := range perms {
+		deprecated := perm.Name() == "tasks:legacy"
+		if perm.Deprecated() != deprecated || deprecated && perm.DeprecationReason != "no longer checked" || !deprecated && perm.DeprecationReason != "" {
+			t.Errorf("permission %s = %+v, want only tasks:legacy deprecated", perm.Name(), perm)
+		}
+	}
+
+	alias, err := repo.GetPermissionAlias(ctx, "tasks:edit")
+	if err != nil || alias == nil || alias.Permission != "tasks:update" {
+		t.Errorf("GetPermissionAlias() = %+v, %v, want tasks:edit -> tasks:update", alias, err)
+	}
+	if role, _ := repo.GetRoleByName(ctx, "editor"); role == nil || !slices.Equal(role.Permissions, []string{"tasks:update"}) {
+		t.Errorf("editor = %+v, want its binding moved to tasks:update", role)
+	}
+}
+
+func testApplyModelChangesIsAtomic(t *testing.T, repo permissions.Repository) {
+	ctx := context.Background()
+	register(t, repo, "tasks:read")
+	createRole(t, repo, "reader", "tasks:read")
+	assign(t, repo, "alice", "reader", "")
+
+	// tasks:read is still bound to a role, so the whole change must be rejected
+	err := repo.ApplyModelChanges(ctx, permissions.ModelChanges{
+		AddPermissions:    []string{"tasks:create"},
+		CreateRoles:       []models.Role{{Name: "creator", Permissions: []string{"tasks:create"}}},
+		RemoveAssignments: []models.RoleAssignment{{Subject: "alice", Role: "reader"}},
+		RemovePermissions: []string{"tasks:read"},
+	})
+	if err == nil {
+		t.Fatal("ApplyModelChanges() error = nil, want error")
+	}
+
+	perms, err := repo.ListPermissions(ctx)
+	if err != nil {
+		t.Fatalf("ListPermissions() error = %v", err)
+	}
+	if got, want := keys(perms), []string{"tasks:read"}; !slices.Equal(got, want) {
+		t.Errorf("ListPermissions() = %v, want %v", got, want)
+	}
+
+	if role, _ := repo.GetRoleByName(ctx, "creator"); role != nil {
+		t.Error("role created by a failed ApplyModelChanges()")
+	}
+	if assignments, _ := repo.ListAssignments(ctx); len(assignments) != 1 {
+		t.Errorf("ListAssignments() = %+v, want the assignment kept", assignments)
+	}
+}
+
+func createRole(t *testing.T, repo permissions.Repository, name string, perms ...string) *models.Role {
+	t.Helper()
+	role := &models.Role{Name: name, Description: name + " role", Permissions: perms}
+	if err := repo.CreateRole(context.Background(), role); err != nil {
+		t.Fatalf("CreateRole(%s) error = %v", name, err)
+	}
+	return role
+}
+
+func testCreateAndGetRole(t *testing.T, repo permissions.Repository) {
+	ctx := context.Background()
+	register(t, repo, "tasks:update", "tasks:read")
+	created := createRole(t, repo, "editor", "tasks:update", "tasks:read")
+
+	role, err := repo.GetRoleByName(ctx, "editor")
+	if err != nil {
+		t.Fatalf("GetRoleByName() error = %v", err)
+	}
+	if role == nil {
+		t.Fatal("GetRoleByName() = nil, want role")
+	}
+	if role.ID != created.ID {
+		t.Errorf("ID = %v, want %v", role.ID, created.ID)
+	}
+	if role.Description != "editor role" {
+		t.Errorf("Description = %v, want %v", role.Description, "editor role")
+	}
+	if want := []string{"tasks:read", "tasks:update"}; !slices.Equal(role.Permissions, want) {
+		t.Errorf("Permissions = %v, want %v", role.Permissions, want)
+	}
+
+	missing, err := repo.GetRoleByName(ctx, "missing")
+	if err != nil || missing != nil {
+		t.Errorf("GetRoleByName(missing) = %v, %v, want nil, nil", missing, err)
+	}
+}
+
+func testCreateRoleRejectsDuplicates(t *testing.T, repo permissions.Repository) {
+	createRole(t, repo, "viewer")
+
+	if err := repo.CreateRole(context.Background(), &models.Role{Name: "viewer"}); err == nil {
+		t.Error("CreateRole() error = nil, want error for duplicate name")
+	}
+}
+
+func testCreateRoleRejectsUnknownPermission(t *testing.T, repo permissions.Repository) {
+	ctx := context.Background()
+	register(t, repo, "tasks:read")
+
+	role := &models.Role{Name: "broken", Permissions: []string{"tasks:read", "tasks:missing"}}
+	if err := repo.CreateRole(ctx, role); err == nil {
+		t.Fatal("CreateRole() error = nil, want error")
+	}
+
+	if got, _ := repo.GetRoleByName(ctx, "broken"); got != nil {
+		t.Error("role was created although its permissions were rejected")
+	}
+}
+
+func testUpdateRole(t *testing.T, repo permissions.Repository) {
+	ctx := context.Background()
+	register(t, repo, "tasks:read", "tasks:update")
+	created := createRole(t, repo, "editor", "tasks:read")
+
+	update := &models.Role{Name: "editor", Description: "changed", Permissions: []string{"tasks:update"}, Source: "tasks/roles.yaml"}
+	if err := repo.UpdateRole(ctx, update); err != nil {
+		t.Fatalf("UpdateRole() error = %v", err)
+	}
+
+	role, err := repo.GetRoleByName(ctx, "editor")
+	if err != nil || role == nil {
+		t.Fatalf("GetRoleByName() = %v, %v", role, err)
+	}
+	if role.ID != created.ID || role.Description != "changed" || role.Source != "tasks/roles.yaml" {
+		t.Errorf("role = %+v, want updated description and source with same ID", role)
+	}
+	if want := []string{"tasks:update"}; !slices.Equal(role.Permissions, want) {
+		t.Errorf("Permissions = %v, want %v", role.Permissions, want)
+	}
+
+	if err := repo.UpdateRole(ctx, &models.Role{Name: "missing"}); err == nil {
+		t.Error("UpdateRole(missing) error = nil, want error")
+	}
+}
+
+func testDeleteRole(t *testing.T, repo permissions.Repository) {
+	ctx := context.Background()
+	register(t, repo, "tasks:read")
+	createRole(t, repo, "reader", "tasks:read")
+
+	if err := repo.DeleteRole(ctx, "reader"); err != nil {
+		t.Fatalf("DeleteRole() error = %v", err)
+	}
+	if role, _ := repo.GetRoleByName(ctx, "reader"); role != nil {
+		t.Error("role still exists after DeleteRole()")
+	}
+
+	// Once the role is gone its permissions can be removed
+	if err := repo.ApplyModelChanges(ctx, permissions.ModelChanges{RemovePermissions: []string{"tasks:read"}}); err != nil {
+		t.Errorf("ApplyModelChanges() error = %v", err)
+	}
+}
+
+func testRolesPagination(t *testing.T, repo permissions.Repository) {
+	ctx := context.Background()
+	for _, name := range []string{"charlie", "alpha", "bravo"} {
+		createRole(t, repo, name)
+	}
+
+	first, err := repo.GetAllRoles(ctx, 1, 2)
+	if err != nil {
+		t.Fatalf("GetAllRoles() error = %v", err)
+	}
+	if first.TotalCount != 3 || first.LastPage != 2 || len(first.Roles) != 2 {
+		t.Fatalf("page 1 = %d roles, total %d, last page %d, want 2, 3, 2", len(first.Roles), first.TotalCount, first.LastPage)
+	}
+	if first.Roles[0].Name != "alpha" || first.Roles[1].Name != "bravo" {
+		t.Errorf("page 1 = %v, %v, want alpha, bravo", first.Roles[0].Name, first.Roles[1].Name)
+	}
+
+	roles, err := repo.ListRoles(ctx)
+	if err != nil {
+		t.Fatalf("ListRoles() error = %v", err)
+	}
+	if len(roles) != 3 || roles[2].Name != "charlie" {
+		t.Errorf("ListRoles() = %v, want 3 roles ordered by name", roles)
+	}
+	if roles[0].Permissions == nil {
+		t.Error("Permissions = nil, want empty slice for roles without permissions")
+	}
+}
+
+func testApplyRoleChangesIsAtomic(t *testing.T, repo permissions.Repository) {
+	ctx := context.Background()
+	register(t, repo, "tasks:read")
+	createRole(t, repo, "old", "tasks:read")
+
+	create := []models.Role{
+		{Name: "valid", Permissions: []string{"tasks:read"}, Source: "roles.yaml"},
+		{Name: "invalid", Permissions: []string{"tasks:missing"}, Source: "roles.yaml"},
+	}
+	if err := repo.ApplyRoleChanges(ctx, create, nil, []string{"old"}); err == nil {
+		t.Fatal("ApplyRoleChanges() error = nil, want error")
+	}
+
+	roles, err := repo.ListRoles(ctx)
+	if err != nil {
+		t.Fatalf("ListRoles() error = %v", err)
+	}
+	if len(roles) != 1 || roles[0].Name != "old" {
+		t.Errorf("ListRoles() = %v, want only the original role", roles)
+	}
+
+	create = create[:1]
+	if err := repo.ApplyRoleChanges(ctx, create, nil, []string{"old"}); err != nil {
+		t.Fatalf("ApplyRoleChanges() error = %v", err)
+	}
+
+	roles, err = repo.ListRoles(ctx)
+	if err != nil {
+		t.Fatalf("ListRoles() error = %v", err)
+	}
+	if len(roles) != 1 || roles[0].Name != "valid" || roles[0].Source != "roles.yaml" {
+		t.Errorf("ListRoles() = %v, want only the new role", roles)
+	}
+}
+
+func assign(t *testing.T, repo permissions.Repository, subject, role, tenant string) *models.RoleAssignment {
+	t.Helper()
+	assignment := &models.RoleAssignment{Subject: subject, Role: role, Tenant: tenant}
+	if err := repo.CreateAssignment(context.Background(), assignment); err != nil {
+		t.Fatalf("CreateAssignment(%s, %s, %s) error = %v", subject, role, tenant, err)
+	}
+	return assignment
+}
+
+func roleNames(roles []models.Role) []string {
+	out := make([]string, len(roles))
+	for i, role := range roles {
+		out[i] = role.Name
+	}
+	return out
+}
+
+func testCreateAssignmentIsIdempotent(t *testing.T, repo permissions.Repository) {
+	createRole(t, repo, "viewer")
+	first := assign(t, repo, "user-1", "viewer", "")
+	second := assign(t, repo, "user-1", "viewer", "")
+
+	if first.ID != second.ID {
+		t.Errorf("second assignment ID = %v, want existing %v", second.ID, first.ID)
+	}
+
+	result, err := repo.GetAssignments(context.Background(), dto.AssignmentFilter{}, 1, 10)
+	if err != nil {
+		t.Fatalf("GetAssignments() error = %v", err)
+	}
+	if result.TotalCount != 1 {
+		t.Errorf("TotalCount = %v, want 1", result.TotalCount)
+	}
+}
+
+func testCreateAssignmentRequiresRole(t *testing.T, repo permissions.Repository) {
+	err := repo.CreateAssignment(context.Background(), &models.RoleAssignment{Subject: "user-1", Role: "missing"})
+	if err == nil {
+		t.Error("CreateAssignment() error = nil, want error for missing role")
+	}
+}
+
+func testGetAssignmentsFilters(t *testing.T, repo permissions.Repository) {
+	ctx := context.Background()
+	createRole(t, repo, "viewer")
+	createRole(t, repo, "editor")
+	assign(t, repo, "user-2", "viewer", "")
+	assign(t, repo, "user-1", "viewer", "acme")
+	assign(t, repo, "user-1", "editor", "")
+
+	all, err := repo.GetAssignments(ctx, dto.AssignmentFilter{}, 1, 2)
+	if err != nil {
+		t.Fatalf("GetAssignments() error = %v", err)
+	}
+	if all.TotalCount != 3 || all.LastPage != 2 || len(all.Assignments) != 2 {
+		t.Fatalf("page 1 = %d assignments, total %d, last page %d, want 2, 3, 2", len(all.Assignments), all.TotalCount, all.LastPage)
+	}
+	if a := all.Assignments[0]; a.Subject != "user-1" || a.Role != "editor" {
+		t.Errorf("first assignment = %+v, want user-1/editor", a)
+	}
+
+	filtered, err := repo.GetAssignments(ctx, dto.AssignmentFilter{Role: "viewer", Tenant: "acme"}, 1, 10)
+	if err != nil {
+		t.Fatalf("GetAssignments() error = %v", err)
+	}
+	if filtered.TotalCount != 1 || filtered.Assignments[0].Subject != "user-1" {
+		t.Errorf("filtered = %+v, want only user-1/viewer/acme", filtered.Assignments)
+	}
+}
+
+func testDeleteAssignment(t *testing.T, repo permissions.Repository) {
+	ctx := context.Background()
+	createRole(t, repo, "viewer")
+	assign(t, repo, "user-1", "viewer", "acme")
+
+	deleted, err := repo.DeleteAssignment(ctx, "user-1", "viewer", "")
+	if err != nil || deleted {
+		t.Errorf("DeleteAssignment(wrong tenant) = %v, %v, want false", deleted, err)
+	}
+
+	deleted, err = repo.DeleteAssignment(ctx, "user-1", "viewer", "acme")
+	if err != nil || !deleted {
+		t.Errorf("DeleteAssignment() = %v, %v, want true", deleted, err)
+	}
+}
+
+func testSubjectRolesByTenant(t *testing.T, repo permissions.Repository) {
+	ctx := context.Background()
+	register(t, repo, "tasks:read", "tasks:update")
+	createRole(t, repo, "viewer", "tasks:read")
+	createRole(t, repo, "editor", "tasks:update")
+	assign(t, repo, "user-1", "viewer", "")
+	assign(t, repo, "user-1", "editor", "acme")
+	assign(t, repo, "user-2", "editor", "")
+
+	tests := map[string][]string{
+		"acme":   {"editor", "viewer"},
+		"globex": {"viewer"},
+		"":       {"viewer"},
+	}
+	for tenant, want := range tests {
+		roles, err := repo.GetSubjectRoles(ctx, "user-1", tenant)
+		if err != nil {
+			t.Fatalf("GetSubjectRoles(%q) error = %v", tenant, err)
+		}
+		if got := roleNames(roles); !slices.Equal(got, want) {
+			t.Errorf("GetSubjectRoles(%q) = %v, want %v", tenant, got, want)
+		}
+	}
+
+	roles, err := repo.GetSubjectRoles(ctx, "user-1", "acme")
+	if err != nil {
+		t.Fatalf("GetSubjectRoles() error = %v", err)
+	}
+	if !slices.Equal(roles[0].Permissions, []string{"tasks:update"}) {
+		t.Errorf("Permissions = %v, want role permissions to be loaded", roles[0].Permissions)
+	}
+}
+
+func testDeleteRoleRemovesAssignments(t *testing.T, repo permissions.Repository) {
+	ctx := context.Background()
+	createRole(t, repo, "viewer")
+	assign(t, repo, "user-1", "viewer", "")
+
+	if err := repo.DeleteRole(ctx, "viewer"); err != nil {
+		t.Fatalf("DeleteRole() error = %v", err)
+	}
+	createRole(t, repo, "viewer")
+
+	roles, err := repo.GetSubjectRoles(ctx, "user-1", "")
+	if err != nil {
+		t.Fatalf("GetSubjectRoles() error = %v", err)
+	}
+	if len(roles) != 0 {
+		t.Errorf("GetSubjectRoles() = %v, want assignments removed with the role", roleNames(roles))
+	}
+}
+
+func testDeprecatePermission(t *testing.T, repo permissions.Repository) {
+	ctx := context.Background()
+	register(t, repo, "tasks:read")
+
+	found, err := repo.DeprecatePermission(ctx, "tasks", "read", "use tasks:view")
+	if err != nil || !found {
+		t.Fatalf("DeprecatePermission() = %v, %v, want true", found, err)
+	}
+
+	first, err := repo.GetPermission(ctx, "tasks", "read")
+	if err != nil {
+		t.Fatalf("GetPermission() error = %v", err)
+	}
+	if !first.Deprecated() || first.DeprecationReason != "use tasks:view" {
+		t.Errorf("GetPermission() = %+v, want deprecated with reason", first)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	if _, err := repo.DeprecatePermission(ctx, "tasks", "read", "gone soon"); err != nil {
+		t.Fatalf("DeprecatePermission() error = %v", err)
+	}
+
+	second, err := repo.GetPermission(ctx, "tasks", "read")
+	if err != nil {
+		t.Fatalf("GetPermission() error = %v", err)
+	}
+	if !second.DeprecatedAt.Equal(*first.DeprecatedAt) || second.DeprecationReason != "gone soon" {
+		t.Errorf("second deprecation = %v %q, want original date and new reason", second.DeprecatedAt, second.DeprecationReason)
+	}
+
+	found, err = repo.DeprecatePermission(ctx, "tasks", "missing", "")
+	if err != nil || found {
+		t.Errorf("DeprecatePermission() missing = %v, %v, want false", found, err)
+	}
+}
+
+func testDeletePermission(t *testing.T, repo permissions.Repository) {
+	ctx := context.Background()
+	register(t, repo, "tasks:read", "tasks:update")
+	createRole(t, repo, "viewer", "tasks:read", "tasks:update")
+
+	roles, err := repo.GetPermissionRoles(ctx, "tasks", "read")
+	if err != nil || !slices.Equal(roles, []string{"viewer"}) {
+		t.Fatalf("GetPermissionRoles() = %v, %v, want [viewer]", roles, err)
+	}
+
+	if _, err := repo.DeletePermission(ctx, "tasks", "read", false); err == nil {
+		t.Error("DeletePermission() error = nil, want error for bound permission")
+	}
+
+	found, err := repo.DeletePermission(ctx, "tasks", "read", true)
+	if err != nil || !found {
+		t.Fatalf("DeletePermission() force = %v, %v, want true", found, err)
+	}
+
+	role, err := repo.GetRoleByName(ctx, "viewer")
+	if err != nil {
+		t.Fatalf("GetRoleByName() error = %v", err)
+	}
+	if !slices.Equal(role.Permissions, []string{"tasks:update"}) {
+		t.Errorf("role permissions = %v, want [tasks:update]", role.Permissions)
+	}
+
+	found, err = repo.DeletePermission(ctx, "tasks", "read", false)
+	if err != nil || found {
+		t.Errorf("DeletePermission() missing = %v, %v, want false", found, err)
+	}
+}
+
+func testCreatePermissionAliasMigratesBindings(t *testing.T, repo permissions.Repository) {
+	ctx := context.Background()
+	register(t, repo, "tasks:edit", "tasks:update", "tasks:modify")
+	createRole(t, repo, "editor", "tasks:edit")
+	createRole(t, repo, "owner", "tasks:edit", "tasks:update")
+
+	if _, err := repo.CreatePermissionAlias(ctx, "tasks:modify", "tasks:edit"); err != nil {
+		t.Fatalf("CreatePermissionAlias() error = %v", err)
+	}
+
+	migrated, err := repo.CreatePermissionAlias(ctx, "tasks:edit", "tasks:update")
+	if err != nil {
+		t.Fatalf("CreatePermissionAlias() error = %v", err)
+	}
+	if !slices.Equal(migrated, []string{"editor", "owner"}) {
+		t.Errorf("migrated roles = %v, want [editor owner]", migrated)
+	}
+
+	for _, name := range []string{"editor", "owner"} {
+		role, err := repo.GetRoleByName(ctx, name)
+		if err != nil {
+			t.Fatalf("GetRoleByName() error = %v", err)
+		}
+		if !slices.Equal(role.Permissions, []string{"tasks:update"}) {
+			t.Errorf("role %s permissions = %v, want [tasks:update]", name, role.Permissions)
+		}
+	}
+
+	if perm, err := repo.GetPermission(ctx, "tasks", "edit"); err != nil || perm != nil {
+		t.Errorf("GetPermission(tasks:edit) = %v, %v, want nil", perm, err)
+	}
+
+	aliases, err := repo.ListPermissionAliases(ctx)
+	if err != nil {
+		t.Fatalf("ListPermissionAliases() error = %v", err)
+	}
+	if len(aliases) != 2 || aliases[0].Alias != "tasks:edit" || aliases[1].Alias != "tasks:modify" {
+		t.Fatalf("ListPermissionAliases() = %v, want tasks:edit and tasks:modify", aliases)
+	}
+	for _, alias := range aliases {
+		if alias.Permission != "tasks:update" {
+			t.Errorf("alias %s resolves to %s, want tasks:update", alias.Alias, alias.Permission)
+		}
+	}
+
+	alias, err := repo.GetPermissionAlias(ctx, "tasks:edit")
+	if err != nil || alias == nil || alias.Permission != "tasks:update" {
+		t.Errorf("GetPermissionAlias() = %v, %v", alias, err)
+	}
+
+	if alias, err := repo.GetPermissionAlias(ctx, "tasks:update"); err != nil || alias != nil {
+		t.Errorf("GetPermissionAlias(tasks:update) = %v, %v, want nil", alias, err)
+	}
+
+	if _, err := repo.CreatePermissionAlias(ctx, "tasks:other", "tasks:missing"); err == nil {
+		t.Error("CreatePermissionAlias() error = nil, want error for unknown permission")
+	}
+}
+
+func testDeletePermissionRemovesAliases(t *testing.T, repo permissions.Repository) {
+	ctx := context.Background()
+	register(t, repo, "tasks:update")
+
+	if _, err := repo.CreatePermissionAlias(ctx, "tasks:edit", "tasks:update"); err != nil {
+		t.Fatalf("CreatePermissionAlias() error = %v", err)
+	}
+
+	if _, err := repo.DeletePermission(ctx, "tasks", "update", false); err != nil {
+		t.Fatalf("DeletePermission() error = %v", err)
+	}
+
+	aliases, err := repo.ListPermissionAliases(ctx)
+	if err != nil {
+		t.Fatalf("ListPermissionAliases() error = %v", err)
+	}
+	if len(aliases) != 0 {
+		t.Errorf("ListPermissionAliases() = %v, want none", aliases)
+	}
+}
+
+func testRecordUsageAccumulates(t *testing.T, repo permissions.Repository) {
+	ctx := context.Background()
+	register(t, repo, "tasks:read")
+	createRole(t, repo, "viewer", "tasks:read")
+
+	earlier := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	later := earlier.Add(time.Hour)
+
+	err := repo.RecordUsage(ctx,
+		[]models.PermissionUsage{{Permission: "tasks:read", CheckCount: 3, LastCheckedAt: &later}},
+		[]models.RoleUsage{{Role: "viewer", Permission: "tasks:read", GrantCount: 2, LastGrantedAt: &later}},
+	)
+	if err != nil {
+		t.Fatalf("RecordUsage() error = %v", err)
+	}
+
+	// Older batches add to the counts without moving the timestamps back; unknown names are ignored
+	err = repo.RecordUsage(ctx,
+		[]models.PermissionUsage{
+			{Permission: "tasks:read", CheckCount: 1, LastCheckedAt: &earlier},
+			{Permission: "tasks:missing", CheckCount: 1, LastCheckedAt: &earlier},
+		},
+		[]models.RoleUsage{
+			{Role: "viewer", Permission: "tasks:read", GrantCount: 1, LastGrantedAt: &earlier},
+			{Role: "missing", Permission: "tasks:read", GrantCount: 1, LastGrantedAt: &earlier},
+		},
+	)
+	if err != nil {
+		t.Fatalf("RecordUsage() error = %v", err)
+	}
+
+	usage, err := repo.ListPermissionUsage(ctx, "")
+	if err != nil {
+		t.Fatalf("ListPermissionUsage() error = %v", err)
+	}
+	if len(usage) != 1 {
+		t.Fatalf("ListPermissionUsage() = %v, want one permission", usage)
+	}
+
+	got := usage[0]
+	if got.CheckCount != 4 || got.LastCheckedAt == nil || !got.LastCheckedAt.Equal(later) {
+		t.Errorf("permission usage = %d at %v, want 4 at %v", got.CheckCount, got.LastCheckedAt, later)
+	}
+	if len(got.Roles) != 1 || got.Roles[0].GrantCount != 3 || !got.Roles[0].LastGrantedAt.Equal(later) {
+		t.Errorf("role usage = %+v, want viewer granted 3 times", got.Roles)
+	}
+}
+
+func testListPermissionUsageIncludesUnused(t *testing.T, repo permissions.Repository) {
+	ctx := context.Background()
+	register(t, repo, "tasks:read", "tasks:delete", "users:read")
+	createRole(t, repo, "admin", "tasks:read", "tasks:delete")
+
+	at := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	err := repo.RecordUsage(ctx,
+		[]models.PermissionUsage{{Permission: "tasks:read", CheckCount: 1, LastCheckedAt: &at}},
+		[]models.RoleUsage{{Role: "admin", Permission: "tasks:read", GrantCount: 1, LastGrantedAt: &at}},
+	)
+	if err != nil {
+		t.Fatalf("RecordUsage() error = %v", err)
+	}
+
+	usage, err := repo.ListPermissionUsage(ctx, "tasks")
+	if err != nil {
+		t.Fatalf("ListPermissionUsage() error = %v", err)
+	}
+
+	names := make([]string, len(usage))
+	for i, u := range usage {
+		names[i] = u.Permission
+	}
+	if !slices.Equal(names, []string{"tasks:delete", "tasks:read"}) {
+		t.Fatalf("ListPermissionUsage() = %v, want tasks permissions only", names)
+	}
+
+	unused := usage[0]
+	if unused.CheckCount != 0 || unused.LastCheckedAt != nil {
+		t.Errorf("unused permission = %+v, want no checks", unused)
+	}
+	if len(unused.Roles) != 1 || unused.Roles[0].Role != "admin" || unused.Roles[0].GrantCount != 0 || unused.Roles[0].LastGrantedAt != nil {
+		t.Errorf("unused grant = %+v, want admin with no grants", unused.Roles)
+	}
+}
+
+func createCampaign(t *testing.T, repo permissions.Repository, roles []string, tenants []string) *models.ReviewCampaign {
+	t.Helper()
+	campaign := &models.ReviewCampaign{
+		Name:     "quarterly",
+		Roles:    roles,
+		Tenants:  tenants,
+		Deadline: time.Now().Add(time.Hour),
+		Status:   models.CampaignOpen,
+	}
+	if err := repo.CreateReviewCampaign(context.Background(), campaign); err != nil {
+		t.Fatalf("CreateReviewCampaign() error = %v", err)
+	}
+	return campaign
+}
+
+func reviewItems(t *testing.T, repo permissions.Repository, campaignID uuid.UUID, decision string) []models.ReviewItem {
+	t.Helper()
+	items, err := repo.GetReviewItems(context.Background(), campaignID, decision)
+	if err != nil {
+		t.Fatalf("GetReviewItems() error = %v", err)
+	}
+	return items
+}
+
+func testReviewCampaignSnapshotsScope(t *testing.T, repo permissions.Repository) {
+	ctx := context.Background()
+	createRole(t, repo, "viewer")
+	createRole(t, repo, "editor")
+	assign(t, repo, "user-2", "viewer", "acme")
+	assign(t, repo, "user-1", "viewer", "acme")
+	assign(t, repo, "user-1", "viewer", "")
+	assign(t, repo, "user-1", "editor", "acme")
+	assign(t, repo, "user-3", "viewer", "globex")
+
+	campaign := createCampaign(t, repo, []string{"viewer"}, []string{"acme"})
+	if campaign.ID == uuid.Nil || campaign.Pending != 2 {
+		t.Fatalf("campaign = %+v, want an ID and 2 pending items", campaign)
+	}
+
+	// Assignments made after creation are not part of the campaign
+	assign(t, repo, "user-4", "viewer", "acme")
+
+	items := reviewItems(t, repo, campaign.ID, "")
+	var subjects []string
+	for _, item := range items {
+		if item.Role != "viewer" || item.Tenant != "acme" || item.Decision != models.ReviewPending {
+			t.Errorf("item = %+v, want pending viewer/acme", item)
+		}
+		subjects = append(subjects, item.Subject)
+	}
+	if !slices.Equal(subjects, []string{"user-1", "user-2"}) {
+		t.Errorf("subjects = %v, want [user-1 user-2]", subjects)
+	}
+
+	time.Sleep(2 * time.Millisecond)
+	everything := createCampaign(t, repo, nil, nil)
+	if got := len(reviewItems(t, repo, everything.ID, "")); got != 6 {
+		t.Errorf("unscoped campaign items = %d, want 6", got)
+	}
+
+	stored, err := repo.GetReviewCampaign(ctx, campaign.ID)
+	if err != nil {
+		t.Fatalf("GetReviewCampaign() error = %v", err)
+	}
+	if stored == nil || stored.Name != "quarterly" || !slices.Equal(stored.Roles, []string{"viewer"}) || stored.Pending != 2 {
+		t.Errorf("GetReviewCampaign() = %+v, want the stored campaign", stored)
+	}
+
+	missing, err := repo.GetReviewCampaign(ctx, uuid.New())
+	if err != nil || missing != nil {
+		t.Errorf("GetReviewCampaign(missing) = %v, %v, want nil", missing, err)
+	}
+
+	campaigns, err := repo.ListReviewCampaigns(ctx, models.CampaignOpen)
+	if err != nil {
+		t.Fatalf("ListReviewCampaigns() error = %v", err)
+	}
+	if len(campaigns) != 2 || campaigns[0].ID != everything.ID {
+		t.Errorf("ListReviewCampaigns() = %v, want 2 campaigns newest first", campaigns)
+	}
+}
+
+func testDecideReviewItem(t *testing.T, repo permissions.Repository) {
+	ctx := context.Background()
+	createRole(t, repo, "viewer")
+	assign(t, repo, "user-1", "viewer", "acme")
+	assign(t, repo, "user-2", "viewer", "acme")
+	campaign := createCampaign(t, repo, nil, nil)
+
+	items := reviewItems(t, repo, campaign.ID, "")
+	decidedAt := time.Now()
+	decide := func(item models.ReviewItem, decision string) bool {
+		t.Helper()
+		item.Decision = decision
+		item.Reviewer = "auditor"
+		item.Comment = "checked"
+		item.DecidedAt = &decidedAt
+		updated, err := repo.DecideReviewItem(ctx, &item)
+		if err != nil {
+			t.Fatalf("DecideReviewItem() error = %v", err)
+		}
+		return updated
+	}
+
+	if !decide(items[0], models.ReviewApproved) {
+		t.Error("DecideReviewItem(approve) = false, want true")
+	}
+	if decide(items[0], models.ReviewRevoked) {
+		t.Error("DecideReviewItem(decided item) = true, want false")
+	}
+	if !decide(items[1], models.ReviewRevoked) {
+		t.Error("DecideReviewItem(revoke) = false, want true")
+	}
+
+	item, err := repo.GetReviewItem(ctx, items[0].ID)
+	if err != nil {
+		t.Fatalf("GetReviewItem() error = %v", err)
+	}
+	if item.Decision != models.ReviewApproved || item.Reviewer != "auditor" || item.Comment != "checked" || item.DecidedAt == nil {
+		t.Errorf("GetReviewItem() = %+v, want the approval", item)
+	}
+
+	roles, err := repo.GetSubjectRoles(ctx, "user-1", "acme")
+	if err != nil || len(roles) != 1 {
+		t.Errorf("approved subject roles = %v, %v, want viewer kept", roleNames(roles), err)
+	}
+	roles, err = repo.GetSubjectRoles(ctx, "user-2", "acme")
+	if err != nil || len(roles) != 0 {
+		t.Errorf("revoked subject roles = %v, %v, want none", roleNames(roles), err)
+	}
+
+	stored, err := repo.GetReviewCampaign(ctx, campaign.ID)
+	if err != nil {
+		t.Fatalf("GetReviewCampaign() error = %v", err)
+	}
+	want := models.ReviewSummary{Approved: 1, Revoked: 1}
+	if stored.ReviewSummary != want {
+		t.Errorf("summary = %+v, want %+v", stored.ReviewSummary, want)
+	}
+}
+
+func testCloseReviewCampaignAutoRevokes(t *testing.T, repo permissions.Repository) {
+	ctx := context.Background()
+	createRole(t, repo, "viewer")
+	assign(t, repo, "user-1", "viewer", "")
+	assign(t, repo, "user-2", "viewer", "")
+	campaign := createCampaign(t, repo, nil, nil)
+
+	items := reviewItems(t, repo, campaign.ID, "")
+	approved := items[0]
+	decidedAt := time.Now()
+	approved.Decision = models.ReviewApproved
+	approved.Reviewer = "auditor"
+	approved.DecidedAt = &decidedAt
+	if _, err := repo.DecideReviewItem(ctx, &approved); err != nil {
+		t.Fatalf("DecideReviewItem() error = %v", err)
+	}
+
+	closedAt := time.Now().UTC().Truncate(time.Second)
+	revoked, err := repo.CloseReviewCampaign(ctx, campaign.ID, closedAt)
+	if err != nil {
+		t.Fatalf("CloseReviewCampaign() error = %v", err)
+	}
+	if len(revoked) != 1 || revoked[0].Subject != "user-2" || revoked[0].Decision != models.ReviewAutoRevoked {
+		t.Fatalf("CloseReviewCampaign() = %+v, want user-2 auto-revoked", revoked)
+	}
+
+	roles, err := repo.GetSubjectRoles(ctx, "user-2", "")
+	if err != nil || len(roles) != 0 {
+		t.Errorf("auto-revoked subject roles = %v, %v, want none", roleNames(roles), err)
+	}
+
+	stored, err := repo.GetReviewCampaign(ctx, campaign.ID)
+	if err != nil {
+		t.Fatalf("GetReviewCampaign() error = %v", err)
+	}
+	if stored.Status != models.CampaignClosed || stored.ClosedAt == nil || !stored.ClosedAt.Equal(closedAt) {
+		t.Errorf("campaign = %s closed at %v, want closed at %v", stored.Status, stored.ClosedAt, closedAt)
+	}
+	if stored.Approved != 1 || stored.AutoRevoked != 1 || stored.Pending != 0 {
+		t.Errorf("summary = %+v, want 1 approved and 1 auto-revoked", stored.ReviewSummary)
+	}
+
+	again, err := repo.CloseReviewCampaign(ctx, campaign.ID, closedAt)
+	if err != nil || len(again) != 0 {
+		t.Errorf("CloseReviewCampaign(closed) = %v, %v, want nothing", again, err)
+	}
+
+	pending := items[1]
+	pending.Decision = models.ReviewApproved
+	pending.Reviewer = "auditor"
+	pending.DecidedAt = &decidedAt
+	if updated, err := repo.DecideReviewItem(ctx, &pending); err != nil || updated {
+		t.Errorf("DecideReviewItem(closed campaign) = %v, %v, want false", updated, err)
+	}
+}
@@ -3,11 +3,94 @@ package permissions
 import (
 	"context"
 	"intellifinder/services/permissions/pkg/dto"
+	"intellifinder/services/permissions/pkg/models"
+	"time"
+
+	"github.com/google/uuid"
 )
 
+// ModelChanges is the set of changes an authorization model import makes
+type ModelChanges struct {
+	AddPermissions []string // Format: "service:action"
+	// AddAliases renames permissions like CreatePermissionAlias
+	AddAliases           []models.ModelAlias
+	DeprecatePermissions []models.ModelDeprecation
+	// RestorePermissions clears the deprecation of permissions
+	RestorePermissions []string // Format: "service:action"
+	CreateRoles        []models.Role
+	UpdateRoles        []models.Role
+	AddAssignments     []models.RoleAssignment
+	RemoveAssignments  []models.RoleAssignment
+	RemoveRoles        []string
+	RemovePermissions  []string // Format: "service:action"
+}
+
 type Repository interface {
 	RegisterServicePermissions(ctx context.Context, serviceName string, permissions []string) error
 	GetServicePermissions(ctx context.Context, serviceName string, page int32, limit int32) (*dto.PaginatedPermissions, error)
 	GetAllPermissions(ctx context.Context, page int32, limit int32) (*dto.PaginatedPermissions, error)
 	PermissionExistsByServiceAndAction(ctx context.Context, service string, action string) (bool, error)
+	ListPermissions(ctx context.Context) ([]models.Permission, error)
+	// ApplyModelChanges applies every change in a single transaction, in the order the fields are declared
+	ApplyModelChanges(ctx context.Context, changes ModelChanges) error
+
+	// GetPermission returns nil if the permission doesn't exist
+	GetPermission(ctx context.Context, service string, action string) (*models.Permission, error)
+	// DeprecatePermission keeps the original deprecation time when called again and reports whether the permission exists
+	DeprecatePermission(ctx context.Context, service string, action string, reason string) (bool, error)
+	// GetPermissionRoles returns the names of the roles that grant the permission
+	GetPermissionRoles(ctx context.Context, service string, action string) ([]string, error)
+	// DeletePermission fails while roles grant the permission unless force also removes those bindings.
+	// It reports whether the permission existed.
+	DeletePermission(ctx context.Context, service string, action string, force bool) (bool, error)
+
+	// GetPermissionAlias returns nil if alias isn't an alias
+	GetPermissionAlias(ctx context.Context, alias string) (*models.PermissionAlias, error)
+	ListPermissionAliases(ctx context.Context) ([]models.PermissionAlias, error)
+	// CreatePermissionAlias makes alias resolve to permission. If alias is a registered permission,
+	// its role bindings and aliases move to permission and it is deleted, all in one transaction.
+	// It returns the roles whose bindings were moved.
+	CreatePermissionAlias(ctx context.Context, alias string, permission string) ([]string, error)
+
+	// GetRoleByName returns nil if the role doesn't exist
+	GetRoleByName(ctx context.Context, name string) (*models.Role, error)
+	GetAllRoles(ctx context.Context, page int32, limit int32) (*dto.PaginatedRoles, error)
+	ListRoles(ctx context.Context) ([]models.Role, error)
+	CreateRole(ctx context.Context, role *models.Role) error
+	UpdateRole(ctx context.Context, role *models.Role) error
+	DeleteRole(ctx context.Context, name string) error
+	ApplyRoleChanges(ctx context.Context, create []models.Role, update []models.Role, remove []string) error
+
+	// CreateAssignment is a no-op if the assignment already exists
+	CreateAssignment(ctx context.Context, assignment *models.RoleAssignment) error
+	// DeleteAssignment reports whether an assignment was deleted
+	DeleteAssignment(ctx context.Context, subject string, role string, tenant string) (bool, error)
+	GetAssignments(ctx context.Context, filter dto.AssignmentFilter, page int32, limit int32) (*dto.PaginatedAssignments, error)
+	ListAssignments(ctx context.Context) ([]models.RoleAssignment, error)
+	// GetSubjectRoles returns the roles assigned to subject globally or within tenant
+	GetSubjectRoles(ctx context.Context, subject string, tenant string) ([]models.Role, error)
+
+	// RecordUsage adds the counts to the stored totals and keeps the latest timestamps.
+	// Usage of permissions or roles that no longer exist is ignored.
+	RecordUsage(ctx context.Context, permissions []models.PermissionUsage, roles []models.RoleUsage) error
+	// ListPermissionUsage returns every permission of service (all services when empty) with
+	// its usage and the usage of each role that grants it, ordered by permission and role
+	ListPermissionUsage(ctx context.Context, service string) ([]models.PermissionUsage, error)
+
+	// CreateReviewCampaign stores the campaign with a pending item for every assignment in its scope
+	CreateReviewCampaign(ctx context.Context, campaign *models.ReviewCampaign) error
+	// GetReviewCampaign returns nil if the campaign doesn't exist
+	GetReviewCampaign(ctx context.Context, id uuid.UUID) (*models.ReviewCampaign, error)
+	// ListReviewCampaigns returns campaigns with the given status (all when empty), newest first
+	ListReviewCampaigns(ctx context.Context, status string) ([]models.ReviewCampaign, error)
+	// GetReviewItems returns a campaign's items with the given decision (all when empty)
+	GetReviewItems(ctx context.Context, campaignID uuid.UUID, decision string) ([]models.ReviewItem, error)
+	// GetReviewItem returns nil if the item doesn't exist
+	GetReviewItem(ctx context.Context, id uuid.UUID) (*models.ReviewItem, error)
+	// DecideReviewItem records the decision of a pending item in an open campaign and, for a
+	// revocation, removes the assignment in the same transaction. It reports whether the item was updated.
+	DecideReviewItem(ctx context.Context, item *models.ReviewItem) (bool, error)
+	// CloseReviewCampaign auto-revokes the open campaign's pending items, removing their
+	// assignments, and closes it. It returns the revoked items.
+	CloseReviewCampaign(ctx context.Context, id uuid.UUID, closedAt time.Time) ([]models.ReviewItem, error)
 }
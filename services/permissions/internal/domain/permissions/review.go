@@ -0,0 +1,186 @@
+package permissions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"intellifinder/services/permissions/pkg/models"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// CreateReviewCampaign starts an access review of every assignment of roles
+// within tenants (every role or tenant when empty). The items are a snapshot:
+// assignments made later are not part of the campaign. Global assignments
+// (empty tenant) are only reviewed by campaigns without a tenant scope.
+func (s *Service) CreateReviewCampaign(ctx context.Context, campaign *models.ReviewCampaign) error {
+	var errs []error
+
+	if campaign.Name == "" {
+		errs = append(errs, fmt.Errorf("campaign name is required"))
+	}
+
+	if !campaign.Deadline.After(time.Now()) {
+		errs = append(errs, fmt.Errorf("deadline must be in the future"))
+	}
+
+	for _, role := range campaign.Roles {
+		existing, err := s.repo.GetRoleByName(ctx, role)
+		if err != nil {
+			return fmt.Errorf("failed to get role: %w", err)
+		}
+		if existing == nil {
+			errs = append(errs, fmt.Errorf("role %s does not exist", role))
+		}
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%w: %w", ErrInvalidCampaign, errors.Join(errs...))
+	}
+
+	campaign.Roles = compactStrings(campaign.Roles)
+	campaign.Tenants = compactStrings(campaign.Tenants)
+	campaign.Status = models.CampaignOpen
+
+	if err := s.repo.CreateReviewCampaign(ctx, campaign); err != nil {
+		return fmt.Errorf("failed to create review campaign: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Service) GetReviewCampaign(ctx context.Context, id uuid.UUID) (*models.ReviewCampaign, error) {
+	campaign, err := s.repo.GetReviewCampaign(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get review campaign: %w", err)
+	}
+
+	if campaign == nil {
+		return nil, ErrCampaignNotFound
+	}
+
+	return campaign, nil
+}
+
+func (s *Service) GetReviewCampaigns(ctx context.Context, status string) ([]models.ReviewCampaign, error) {
+	switch status {
+	case "", models.CampaignOpen, models.CampaignClosed:
+	default:
+		return nil, fmt.Errorf("%w: unknown status %q", ErrInvalidCampaign, status)
+	}
+
+	campaigns, err := s.repo.ListReviewCampaigns(ctx, status)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list review campaigns: %w", err)
+	}
+
+	return campaigns, nil
+}
+
+func (s *Service) GetReviewItems(ctx context.Context, campaignID uuid.UUID, decision string) ([]models.ReviewItem, error) {
+	if _, err := s.GetReviewCampaign(ctx, campaignID); err != nil {
+		return nil, err
+	}
+
+	items, err := s.repo.GetReviewItems(ctx, campaignID, decision)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get review items: %w", err)
+	}
+
+	return items, nil
+}
+
+// ApproveReviewItem keeps the reviewed assignment
+func (s *Service) ApproveReviewItem(ctx context.Context, id uuid.UUID, reviewer string, comment string) (*models.ReviewItem, error) {
+	return s.decideReviewItem(ctx, id, models.ReviewApproved, reviewer, comment)
+}
+
+// RevokeReviewItem removes the reviewed assignment
+func (s *Service) RevokeReviewItem(ctx context.Context, id uuid.UUID, reviewer string, comment string) (*models.ReviewItem, error) {
+	return s.decideReviewItem(ctx, id, models.ReviewRevoked, reviewer, comment)
+}
+
+func (s *Service) decideReviewItem(ctx context.Context, id uuid.UUID, decision string, reviewer string, comment string) (*models.ReviewItem, error) {
+	if reviewer == "" {
+		return nil, fmt.Errorf("reviewer is required")
+	}
+
+	item, err := s.repo.GetReviewItem(ctx, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get review item: %w", err)
+	}
+
+	if item == nil {
+		return nil, ErrReviewItemNotFound
+	}
+
+	if item.Subject == reviewer {
+		return nil, ErrSelfReview
+	}
+
+	campaign, err := s.GetReviewCampaign(ctx, item.CampaignID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	if campaign.Status != models.CampaignOpen || !now.Before(campaign.Deadline) {
+		return nil, ErrCampaignClosed
+	}
+
+	if item.Decision != models.ReviewPending {
+		return nil, ErrReviewItemDecided
+	}
+
+	item.Decision = decision
+	item.Reviewer = reviewer
+	item.Comment = comment
+	item.DecidedAt = &now
+
+	updated, err := s.repo.DecideReviewItem(ctx, item)
+	if err != nil {
+		return nil, fmt.Errorf("failed to record review decision: %w", err)
+	}
+
+	// Another reviewer or the deadline got there first
+	if !updated {
+		return nil, ErrReviewItemDecided
+	}
+
+	return item, nil
+}
+
+// CloseExpiredReviewCampaigns auto-revokes every item still pending in open
+// campaigns whose deadline has passed and closes those campaigns. It returns
+// the number of revoked assignments.
+func (s *Service) CloseExpiredReviewCampaigns(ctx context.Context, now time.Time) (int, error) {
+	campaigns, err := s.repo.ListReviewCampaigns(ctx, models.CampaignOpen)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list review campaigns: %w", err)
+	}
+
+	revoked := 0
+	for _, campaign := range campaigns {
+		if now.Before(campaign.Deadline) {
+			continue
+		}
+
+		items, err := s.repo.CloseReviewCampaign(ctx, campaign.ID, now)
+		if err != nil {
+			return revoked, fmt.Errorf("failed to close review campaign %s: %w", campaign.ID, err)
+		}
+		revoked += len(items)
+	}
+
+	return revoked, nil
+}
+
+func compactStrings(values []string) []string {
+	values = slices.DeleteFunc(slices.Clone(values), func(value string) bool {
+		return value == ""
+	})
+	slices.Sort(values)
+	return slices.Compact(values)
+}
@@ -0,0 +1,144 @@
+package permissions
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"intellifinder/services/permissions/pkg/dto"
+	"intellifinder/services/permissions/pkg/models"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	FormatCSV = "csv"
+
+	// ReportSignatureAlgorithm signs the exact bytes of a report document
+	ReportSignatureAlgorithm = "Ed25519"
+)
+
+// ParseReportSigningKey decodes a base64-encoded 32 byte Ed25519 seed
+func ParseReportSigningKey(encoded string) (ed25519.PrivateKey, error) {
+	seed, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode signing key: %w", err)
+	}
+
+	if len(seed) != ed25519.SeedSize {
+		return nil, fmt.Errorf("signing key must be %d bytes, got %d", ed25519.SeedSize, len(seed))
+	}
+
+	return ed25519.NewKeyFromSeed(seed), nil
+}
+
+// SetReportSigningKey enables ExportReviewReport
+func (s *Service) SetReportSigningKey(key ed25519.PrivateKey) {
+	s.reportKey = key
+}
+
+// ExportReviewReport renders the outcome of a campaign as CSV or JSON and
+// signs the document, so auditors can check it wasn't altered using only the
+// public key.
+func (s *Service) ExportReviewReport(ctx context.Context, id uuid.UUID, format string) (*dto.ReviewReport, error) {
+	if s.reportKey == nil {
+		return nil, ErrReportSigningKey
+	}
+
+	campaign, err := s.GetReviewCampaign(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	items, err := s.repo.GetReviewItems(ctx, id, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to get review items: %w", err)
+	}
+
+	var document []byte
+	switch format {
+	case "", FormatCSV:
+		format = FormatCSV
+		document, err = encodeReviewCSV(campaign, items)
+	case FormatJSON:
+		document, err = json.MarshalIndent(dto.ReviewReportDocument{
+			Campaign:    *campaign,
+			GeneratedAt: time.Now().UTC(),
+			Items:       items,
+		}, "", "  ")
+	default:
+		return nil, fmt.Errorf("unsupported format %q, expected %q or %q", format, FormatCSV, FormatJSON)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode review report: %w", err)
+	}
+
+	return &dto.ReviewReport{
+		Document:  document,
+		Format:    format,
+		Algorithm: ReportSignatureAlgorithm,
+		Signature: ed25519.Sign(s.reportKey, document),
+		PublicKey: s.reportKey.Public().(ed25519.PublicKey),
+	}, nil
+}
+
+// VerifyReviewReport reports whether signature is a valid signature of document
+func VerifyReviewReport(document []byte, signature []byte, publicKey []byte) bool {
+	if len(publicKey) != ed25519.PublicKeySize {
+		return false
+	}
+	return ed25519.Verify(publicKey, document, signature)
+}
+
+func encodeReviewCSV(campaign *models.ReviewCampaign, items []models.ReviewItem) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+
+	header := []string{"campaign_id", "campaign", "subject", "role", "tenant", "decision", "reviewer", "comment", "decided_at"}
+	if err := w.Write(header); err != nil {
+		return nil, err
+	}
+
+	for _, item := range items {
+		decidedAt := ""
+		if item.DecidedAt != nil {
+			decidedAt = item.DecidedAt.UTC().Format(time.RFC3339)
+		}
+
+		record := []string{
+			campaign.ID.String(),
+			campaign.Name,
+			item.Subject,
+			item.Role,
+			item.Tenant,
+			item.Decision,
+			item.Reviewer,
+			item.Comment,
+			decidedAt,
+		}
+		for i := range record {
+			record[i] = escapeFormula(record[i])
+		}
+
+		if err := w.Write(record); err != nil {
+			return nil, err
+		}
+	}
+
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
+
+// escapeFormula stops spreadsheets from evaluating free-text fields such as
+// reviewer comments as formulas
+func escapeFormula(value string) string {
+	if value != "" && strings.ContainsRune("=+-@\t\r", rune(value[0])) {
+		return "'" + value
+	}
+	return value
+}
@@ -0,0 +1,295 @@
+package permissions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"intellifinder/services/permissions/pkg/dto"
+	"intellifinder/services/permissions/pkg/models"
+	"slices"
+	"sort"
+)
+
+func (s *Service) GetAllRoles(ctx context.Context, page int32, limit int32) (*dto.PaginatedRoles, error) {
+	if page <= 0 {
+		return nil, fmt.Errorf("page must be greater than 0")
+	}
+
+	if limit <= 0 {
+		return nil, fmt.Errorf("limit must be greater than 0")
+	}
+
+	roles, err := s.repo.GetAllRoles(ctx, page, limit)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get all roles: %w", err)
+	}
+
+	return roles, nil
+}
+
+func (s *Service) GetRole(ctx context.Context, name string) (*models.Role, error) {
+	if name == "" {
+		return nil, fmt.Errorf("role name is required")
+	}
+
+	role, err := s.repo.GetRoleByName(ctx, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get role: %w", err)
+	}
+
+	if role == nil {
+		return nil, ErrRoleNotFound
+	}
+
+	return role, nil
+}
+
+func (s *Service) CreateRole(ctx context.Context, role *models.Role) error {
+	// Roles created through the API are never file-managed
+	role.Source = ""
+
+	if err := s.validateRole(ctx, role); err != nil {
+		return err
+	}
+
+	existing, err := s.repo.GetRoleByName(ctx, role.Name)
+	if err != nil {
+		return fmt.Errorf("failed to get role: %w", err)
+	}
+
+	if existing != nil {
+		return ErrRoleExists
+	}
+
+	if err := s.repo.CreateRole(ctx, role); err != nil {
+		return fmt.Errorf("failed to create role: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Service) UpdateRole(ctx context.Context, role *models.Role) error {
+	existing, err := s.GetRole(ctx, role.Name)
+	if err != nil {
+		return err
+	}
+
+	if existing.ReadOnly() {
+		return ErrRoleReadOnly
+	}
+
+	role.Source = ""
+	if err := s.validateRole(ctx, role); err != nil {
+		return err
+	}
+
+	if err := s.repo.UpdateRole(ctx, role); err != nil {
+		return fmt.Errorf("failed to update role: %w", err)
+	}
+
+	return nil
+}
+
+func (s *Service) DeleteRole(ctx context.Context, name string) error {
+	existing, err := s.GetRole(ctx, name)
+	if err != nil {
+		return err
+	}
+
+	if existing.ReadOnly() {
+		return ErrRoleReadOnly
+	}
+
+	if err := s.repo.DeleteRole(ctx, name); err != nil {
+		return fmt.Errorf("failed to delete role: %w", err)
+	}
+
+	return nil
+}
+
+// SyncRoles declaratively reconciles the roles defined in the role files
+// named by sources with the given definitions: missing roles are created,
+// changed roles updated and roles of those files that are no longer defined
+// deleted. Roles of other files and roles created through the API are never
+// touched; defining a role with the same name is an error.
+func (s *Service) SyncRoles(ctx context.Context, sources []string, roles []models.Role, dryRun bool) (*dto.RoleSyncResult, error) {
+	synced := make(map[string]bool, len(sources))
+	for _, source := range sources {
+		synced[source] = true
+	}
+
+	for _, role := range roles {
+		if !synced[role.Source] {
+			return nil, fmt.Errorf("%w: role %s is defined in %s, which is not being synced", ErrInvalidRole, role.Name, role.Source)
+		}
+	}
+
+	if err := s.validateRoles(ctx, roles); err != nil {
+		return nil, err
+	}
+
+	current, err := s.repo.ListRoles(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list roles: %w", err)
+	}
+
+	existing := make(map[string]models.Role, len(current))
+	for _, role := range current {
+		existing[role.Name] = role
+	}
+
+	result := &dto.RoleSyncResult{Created: []string{}, Updated: []string{}, Deleted: []string{}}
+	var create, update []models.Role
+	var errs []error
+	desired := make(map[string]bool, len(roles))
+
+	for _, role := range roles {
+		desired[role.Name] = true
+
+		old, ok := existing[role.Name]
+		switch {
+		case !ok:
+			create = append(create, role)
+			result.Created = append(result.Created, role.Name)
+		case !old.ReadOnly():
+			errs = append(errs, fmt.Errorf("role %s defined in %s already exists and is managed through the API", role.Name, role.Source))
+		case !synced[old.Source]:
+			errs = append(errs, fmt.Errorf("role %s defined in %s is already defined in %s", role.Name, role.Source, old.Source))
+		case roleChanged(old, role):
+			update = append(update, role)
+			result.Updated = append(result.Updated, role.Name)
+		default:
+			result.Unchanged++
+		}
+	}
+
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidRole, errors.Join(errs...))
+	}
+
+	for _, role := range current {
+		if role.ReadOnly() && synced[role.Source] && !desired[role.Name] {
+			result.Deleted = append(result.Deleted, role.Name)
+		}
+	}
+	sort.Strings(result.Deleted)
+
+	if dryRun || (len(create) == 0 && len(update) == 0 && len(result.Deleted) == 0) {
+		return result, nil
+	}
+
+	if err := s.repo.ApplyRoleChanges(ctx, create, update, result.Deleted); err != nil {
+		return nil, fmt.Errorf("failed to apply role changes: %w", err)
+	}
+	result.Applied = true
+
+	return result, nil
+}
+
+// SyncRoleDir reconciles roles with the role files in dir. Roles of files
+// that were removed from dir are deleted too, while uploaded role files are
+// left alone.
+func (s *Service) SyncRoleDir(ctx context.Context, dir string, dryRun bool) (*dto.RoleSyncResult, error) {
+	sources, roles, err := LoadRoleDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	current, err := s.repo.ListRoles(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list roles: %w", err)
+	}
+
+	for _, role := range current {
+		if role.ReadOnly() && !IsUploadedRoleSource(role.Source) && !slices.Contains(sources, role.Source) {
+			sources = append(sources, role.Source)
+		}
+	}
+
+	return s.SyncRoles(ctx, sources, roles, dryRun)
+}
+
+func roleChanged(old, new models.Role) bool {
+	return old.Description != new.Description ||
+		old.Source != new.Source ||
+		!slices.Equal(old.Permissions, new.Permissions)
+}
+
+func (s *Service) validateRole(ctx context.Context, role *models.Role) error {
+	roles := []models.Role{*role}
+	if err := s.validateRoles(ctx, roles); err != nil {
+		return err
+	}
+
+	*role = roles[0]
+	return nil
+}
+
+// validateRoles checks every role and reports all problems at once. It also
+// normalises each role's permissions: aliases are replaced by the permission
+// they resolve to, then the list is sorted and de-duplicated.
+func (s *Service) validateRoles(ctx context.Context, roles []models.Role) error {
+	catalogue, err := s.repo.ListPermissions(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list permissions: %w", err)
+	}
+
+	known := make(map[string]bool, len(catalogue))
+	for _, perm := range catalogue {
+		known[perm.Name()] = true
+	}
+
+	targets, err := s.aliasTargets(ctx)
+	if err != nil {
+		return err
+	}
+
+	return s.checkRoles(roles, known, targets)
+}
+
+// checkRoles validates and normalises roles against the permissions in known
+func (s *Service) checkRoles(roles []models.Role, known map[string]bool, targets map[string]string) error {
+	var errs []error
+	seen := make(map[string]string, len(roles))
+
+	for i := range roles {
+		role := &roles[i]
+
+		if err := validateRoleName(role.Name); err != nil {
+			errs = append(errs, fmt.Errorf("role %q: %w", role.Name, err))
+			continue
+		}
+
+		if source, ok := seen[role.Name]; ok {
+			if role.Source == "" {
+				errs = append(errs, fmt.Errorf("role %s is defined more than once", role.Name))
+			} else {
+				errs = append(errs, fmt.Errorf("role %s is defined in both %s and %s", role.Name, source, role.Source))
+			}
+			continue
+		}
+		seen[role.Name] = role.Source
+
+		for j, permission := range role.Permissions {
+			if target, ok := targets[permission]; ok {
+				permission = target
+				role.Permissions[j] = target
+			}
+
+			if err := s.validatePermission(permission); err != nil {
+				errs = append(errs, fmt.Errorf("role %s: %w", role.Name, err))
+			} else if !known[permission] {
+				errs = append(errs, fmt.Errorf("role %s: permission %s is not registered", role.Name, permission))
+			}
+		}
+
+		slices.Sort(role.Permissions)
+		role.Permissions = slices.Compact(role.Permissions)
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("%w: %w", ErrInvalidRole, errors.Join(errs...))
+	}
+
+	return nil
+}
@@ -0,0 +1,135 @@
+package permissions
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"intellifinder/services/permissions/pkg/models"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// RoleFile is the format of the roles.yaml files kept next to each service's
+// permission registry:
+//
+//	roles:
+//	  - name: task-editor
+//	    description: Can read and edit tasks
+//	    permissions:
+//	      - tasks:read
+//	      - tasks:update
+type RoleFile struct {
+	Roles []RoleDefinition `yaml:"roles"`
+}
+
+type RoleDefinition struct {
+	Name        string   `yaml:"name"`
+	Description string   `yaml:"description"`
+	Permissions []string `yaml:"permissions"`
+}
+
+// ParseRoleFile parses a single role file. Source is recorded on every role so
+// the API can tell which file owns it.
+func ParseRoleFile(source string, data []byte) ([]models.Role, error) {
+	var file RoleFile
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&file); err != nil {
+		// An empty file defines no roles
+		if errors.Is(err, io.EOF) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to parse role file %s: %w", source, err)
+	}
+
+	roles := make([]models.Role, len(file.Roles))
+	for i, def := range file.Roles {
+		roles[i] = models.Role{
+			Name:        def.Name,
+			Description: def.Description,
+			Permissions: def.Permissions,
+			Source:      source,
+		}
+	}
+
+	return roles, nil
+}
+
+// UploadedRoleSource returns the source recorded for a role file a service
+// uploads. It's prefixed with the service's name, so services can't take
+// over each other's role files or those of the roles directory.
+func UploadedRoleSource(service string, path string) (string, error) {
+	if service == "" {
+		return "", fmt.Errorf("service is required")
+	}
+
+	if path == "" || !fs.ValidPath(path) || path == "." {
+		return "", fmt.Errorf("role file path %q must be a clean relative path", path)
+	}
+
+	return service + ":" + path, nil
+}
+
+// IsUploadedRoleSource reports whether source names an uploaded role file
+// rather than one in the roles directory
+func IsUploadedRoleSource(source string) bool {
+	return strings.Contains(source, ":")
+}
+
+// LoadRoleDir reads every *.yaml and *.yml file below dir and returns the
+// files' sources along with their roles. Sources are recorded relative to dir
+// so they stay stable across deployments.
+func LoadRoleDir(dir string) ([]string, []models.Role, error) {
+	var sources []string
+	var roles []models.Role
+
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			return nil
+		}
+
+		ext := filepath.Ext(path)
+		if ext != ".yaml" && ext != ".yml" {
+			return nil
+		}
+
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to read role file: %w", err)
+		}
+
+		source, err := filepath.Rel(dir, path)
+		if err != nil {
+			source = path
+		}
+		source = filepath.ToSlash(source)
+
+		// Colons mark uploaded role files
+		if IsUploadedRoleSource(source) {
+			return fmt.Errorf("role file name %s must not contain ':'", source)
+		}
+
+		fileRoles, err := ParseRoleFile(source, data)
+		if err != nil {
+			return err
+		}
+
+		sources = append(sources, source)
+		roles = append(roles, fileRoles...)
+		return nil
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to load role directory %s: %w", dir, err)
+	}
+
+	return sources, roles, nil
+}
@@ -2,12 +2,16 @@ package permissions
 
 import (
 	"context"
+	"crypto/ed25519"
 	"fmt"
 	"intellifinder/services/permissions/pkg/dto"
+	"slices"
 )
 
 type Service struct {
-	repo Repository
+	repo      Repository
+	usage     *UsageRecorder
+	reportKey ed25519.PrivateKey
 }
 
 func NewService(repo Repository) *Service {
@@ -31,6 +35,17 @@ func (s *Service) RegisterServicePermissions(ctx context.Context, serviceName st
 		}
 	}
 
+	targets, err := s.aliasTargets(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Services that still publish a renamed permission must not re-create it
+	permissions = slices.DeleteFunc(slices.Clone(permissions), func(permission string) bool {
+		_, aliased := targets[permission]
+		return aliased
+	})
+
 	if err := s.repo.RegisterServicePermissions(ctx, serviceName, permissions); err != nil {
 		return fmt.Errorf("failed to register service permissions: %w", err)
 	}
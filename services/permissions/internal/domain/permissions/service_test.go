@@ -0,0 +1,858 @@
+package permissions_test
+
+import (
+	"context"
+	"errors"
+	"intellifinder/services/permissions/internal/domain/permissions"
+	"intellifinder/services/permissions/internal/infrastructure/memory"
+	"intellifinder/services/permissions/pkg/models"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newService(t *testing.T, perms ...string) *permissions.Service {
+	t.Helper()
+	service := permissions.NewService(memory.NewRepository())
+	if len(perms) > 0 {
+		if err := service.RegisterServicePermissions(context.Background(), "test", perms); err != nil {
+			t.Fatalf("RegisterServicePermissions() error = %v", err)
+		}
+	}
+	return service
+}
+
+func TestRegisterServicePermissionsValidation(t *testing.T) {
+	service := newService(t)
+	ctx := context.Background()
+
+	tests := []struct {
+		name        string
+		serviceName string
+		permissions []string
+	}{
+		{"missing service name", "", []string{"tasks:read"}},
+		{"no permissions", "tasks", nil},
+		{"missing separator", "tasks", []string{"tasksread"}},
+		{"too many separators", "tasks", []string{"tasks:read:all"}},
+		{"empty action", "tasks", []string{"tasks:"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := service.RegisterServicePermissions(ctx, tt.serviceName, tt.permissions); err == nil {
+				t.Error("RegisterServicePermissions() error = nil, want error")
+			}
+		})
+	}
+}
+
+func TestPaginationValidation(t *testing.T) {
+	service := newService(t)
+	ctx := context.Background()
+
+	if _, err := service.GetAllPermissions(ctx, 0, 10); err == nil {
+		t.Error("GetAllPermissions(page 0) error = nil, want error")
+	}
+	if _, err := service.GetServicePermissions(ctx, "tasks", 1, 0); err == nil {
+		t.Error("GetServicePermissions(limit 0) error = nil, want error")
+	}
+	if _, err := service.GetAllRoles(ctx, 1, -1); err == nil {
+		t.Error("GetAllRoles(limit -1) error = nil, want error")
+	}
+}
+
+func TestImportModel(t *testing.T) {
+	service := newService(t, "tasks:read", "tasks:legacy")
+	ctx := context.Background()
+
+	model := &models.AuthorizationModel{
+		Version:     models.ModelVersion,
+		Permissions: []string{"tasks:read", "tasks:create"},
+	}
+
+	diff, err := service.ImportModel(ctx, model, permissions.ImportOptions{DryRun: true, Prune: true})
+	if err != nil {
+		t.Fatalf("ImportModel() error = %v", err)
+	}
+	if diff.Applied || !slices.Equal(diff.Added, []string{"tasks:create"}) || !slices.Equal(diff.Removed, []string{"tasks:legacy"}) || diff.Unchanged != 1 {
+		t.Errorf("dry run diff = %+v", diff)
+	}
+
+	diff, err = service.ImportModel(ctx, model, permissions.ImportOptions{})
+	if err != nil {
+		t.Fatalf("ImportModel() error = %v", err)
+	}
+	if !diff.Applied || len(diff.Removed) != 0 {
+		t.Errorf("diff = %+v, want applied without removals", diff)
+	}
+
+	exported, err := service.ExportModel(ctx)
+	if err != nil {
+		t.Fatalf("ExportModel() error = %v", err)
+	}
+	if want := []string{"tasks:create", "tasks:legacy", "tasks:read"}; !slices.Equal(exported.Permissions, want) {
+		t.Errorf("exported permissions = %v, want %v", exported.Permissions, want)
+	}
+
+	model.Version = 99
+	if _, err := service.ImportModel(ctx, model, permissions.ImportOptions{}); err == nil {
+		t.Error("ImportModel() error = nil, want error for unsupported version")
+	}
+}
+
+func TestImportModelRolesAndAssignments(t *testing.T) {
+	ctx := context.Background()
+
+	source := newService(t, "tasks:read", "tasks:create")
+	syncRole(t, source, models.Role{Name: "viewer", Permissions: []string{"tasks:read"}, Source: "tasks/roles.yaml"})
+	if err := source.CreateRole(ctx, &models.Role{Name: "editor", Description: "Edits tasks", Permissions: []string{"tasks:read", "tasks:create"}}); err != nil {
+		t.Fatalf("CreateRole() error = %v", err)
+	}
+	assignRole(t, source, "alice", "editor", "")
+	assignRole(t, source, "bob", "viewer", "acme")
+
+	model, err := source.ExportModel(ctx)
+	if err != nil {
+		t.Fatalf("ExportModel() error = %v", err)
+	}
+	if len(model.Roles) != 1 || model.Roles[0].Name != "editor" || len(model.Assignments) != 2 {
+		t.Fatalf("ExportModel() = %+v, want the API-managed role and both assignments", model)
+	}
+
+	target := newService(t, "tasks:read", "tasks:legacy")
+	syncRole(t, target, models.Role{Name: "viewer", Permissions: []string{"tasks:read"}, Source: "tasks/roles.yaml"})
+	if err := target.CreateRole(ctx, &models.Role{Name: "legacy", Permissions: []string{"tasks:legacy"}}); err != nil {
+		t.Fatalf("CreateRole() error = %v", err)
+	}
+	assignRole(t, target, "carol", "legacy", "")
+	assignRole(t, target, "bob", "viewer", "acme")
+
+	diff, err := target.ImportModel(ctx, model, permissions.ImportOptions{Prune: true})
+	if err != nil {
+		t.Fatalf("ImportModel() error = %v", err)
+	}
+	if !diff.Applied ||
+		!slices.Equal(diff.Added, []string{"tasks:create"}) || !slices.Equal(diff.Removed, []string{"tasks:legacy"}) ||
+		!slices.Equal(diff.RolesAdded, []string{"editor"}) || !slices.Equal(diff.RolesRemoved, []string{"legacy"}) ||
+		!slices.Equal(diff.AssignmentsAdded, []models.ModelAssignment{{Subject: "alice", Role: "editor"}}) ||
+		!slices.Equal(diff.AssignmentsRemoved, []models.ModelAssignment{{Subject: "carol", Role: "legacy"}}) ||
+		diff.AssignmentsUnchanged != 1 {
+		t.Errorf("ImportModel() = %+v", diff)
+	}
+
+	imported, err := target.ExportModel(ctx)
+	if err != nil {
+		t.Fatalf("ExportModel() error = %v", err)
+	}
+	if !slices.Equal(imported.Permissions, model.Permissions) ||
+		!slices.EqualFunc(imported.Roles, model.Roles, func(a, b models.ModelRole) bool {
+			return a.Name == b.Name && a.Description == b.Description && slices.Equal(a.Permissions, b.Permissions)
+		}) ||
+		!slices.Equal(imported.Assignments, model.Assignments) {
+		t.Errorf("ExportModel() after import = %+v, want %+v", imported, model)
+	}
+
+	// Importing the same document again changes nothing
+	diff, err = target.ImportModel(ctx, model, permissions.ImportOptions{Prune: true})
+	if err != nil || diff.Applied || diff.RolesUnchanged != 1 || diff.AssignmentsUnchanged != 2 {
+		t.Errorf("ImportModel() again = %+v, %v, want nothing to apply", diff, err)
+	}
+}
+
+func TestImportModelAliasesAndDeprecations(t *testing.T) {
+	ctx := context.Background()
+
+	source := newService(t, "tasks:read", "tasks:edit", "tasks:update", "tasks:legacy")
+	if err := source.CreateRole(ctx, &models.Role{Name: "editor", Permissions: []string{"tasks:edit"}}); err != nil {
+		t.Fatalf("CreateRole() error = %v", err)
+	}
+	if _, err := source.CreatePermissionAlias(ctx, "tasks:edit", "tasks:update"); err != nil {
+		t.Fatalf("CreatePermissionAlias() error = %v", err)
+	}
+	if err := source.DeprecatePermission(ctx, "tasks:legacy", "use tasks:read"); err != nil {
+		t.Fatalf("DeprecatePermission() error = %v", err)
+	}
+
+	exported, err := source.ExportModel(ctx)
+	if err != nil {
+		t.Fatalf("ExportModel() error = %v", err)
+	}
+	document, err := permissions.EncodeModel(exported, permissions.FormatYAML)
+	if err != nil {
+		t.Fatalf("EncodeModel() error = %v", err)
+	}
+	model, err := permissions.DecodeModel(document)
+	if err != nil {
+		t.Fatalf("DecodeModel() error = %v", err)
+	}
+	if !slices.Equal(model.Aliases, []models.ModelAlias{{Alias: "tasks:edit", Permission: "tasks:update"}}) ||
+		!slices.Equal(model.Deprecations, []models.ModelDeprecation{{Permission: "tasks:legacy", Reason: "use tasks:read"}}) {
+		t.Fatalf("exported document = %s, want the alias and the deprecation", document)
+	}
+
+	// The target still has the old name, granted by a file-managed role,
+	// and a deprecation the source doesn't have
+	target := newService(t, "tasks:read", "tasks:edit", "tasks:update", "tasks:legacy")
+	syncRole(t, target, models.Role{Name: "viewer", Permissions: []string{"tasks:edit"}, Source: "tasks/roles.yaml"})
+	if err := target.DeprecatePermission(ctx, "tasks:read", "replaced by tasks:list"); err != nil {
+		t.Fatalf("DeprecatePermission() error = %v", err)
+	}
+
+	// Older documents leave aliases and deprecations alone
+	older := *model
+	older.Version = 2
+	diff, err := target.ImportModel(ctx, &older, permissions.ImportOptions{DryRun: true})
+	if err != nil || len(diff.AliasesAdded) != 0 || len(diff.DeprecationsAdded) != 0 {
+		t.Errorf("ImportModel() of a version 2 document = %+v, %v, want no alias or deprecation changes", diff, err)
+	}
+
+	diff, err = target.ImportModel(ctx, model, permissions.ImportOptions{Prune: true})
+	if err != nil {
+		t.Fatalf("ImportModel() error = %v", err)
+	}
+	if !diff.Applied || len(diff.Removed) != 0 ||
+		!slices.Equal(diff.AliasesAdded, model.Aliases) ||
+		!slices.Equal(diff.DeprecationsAdded, []string{"tasks:legacy"}) ||
+		!slices.Equal(diff.DeprecationsRemoved, []string{"tasks:read"}) {
+		t.Errorf("ImportModel() = %+v, want the alias and deprecation applied without removing the renamed permission", diff)
+	}
+
+	imported, err := target.ExportModel(ctx)
+	if err != nil {
+		t.Fatalf("ExportModel() error = %v", err)
+	}
+	if !slices.Equal(imported.Permissions, model.Permissions) || !slices.Equal(imported.Aliases, model.Aliases) ||
+		!slices.Equal(imported.Deprecations, model.Deprecations) {
+		t.Errorf("ExportModel() after import = %+v, want %+v", imported, model)
+	}
+	if viewer, err := target.GetRole(ctx, "viewer"); err != nil || !slices.Equal(viewer.Permissions, []string{"tasks:update"}) {
+		t.Errorf("viewer = %+v, %v, want its binding moved to tasks:update", viewer, err)
+	}
+	if resolved, err := target.ResolvePermission(ctx, "tasks:edit"); err != nil || resolved == nil || resolved.Name() != "tasks:update" {
+		t.Errorf("ResolvePermission(tasks:edit) = %v, %v, want tasks:update", resolved, err)
+	}
+
+	// Importing the same document again changes nothing
+	diff, err = target.ImportModel(ctx, model, permissions.ImportOptions{Prune: true})
+	if err != nil || diff.Applied || diff.AliasesUnchanged != 1 || diff.DeprecationsUnchanged != 1 {
+		t.Errorf("ImportModel() again = %+v, %v, want nothing to apply", diff, err)
+	}
+}
+
+func TestImportModelValidation(t *testing.T) {
+	ctx := context.Background()
+	service := newService(t, "tasks:read", "tasks:legacy")
+	syncRole(t, service, models.Role{Name: "viewer", Permissions: []string{"tasks:read", "tasks:legacy"}, Source: "tasks/roles.yaml"})
+
+	tests := []struct {
+		name  string
+		model models.AuthorizationModel
+		prune bool
+		want  error
+	}{
+		{
+			name:  "pruned permission granted by a file-managed role",
+			model: models.AuthorizationModel{Version: models.ModelVersion, Permissions: []string{"tasks:read"}},
+			prune: true,
+			want:  permissions.ErrPermissionInUse,
+		},
+		{
+			name:  "role grants a pruned permission",
+			model: models.AuthorizationModel{Version: models.ModelVersion, Permissions: []string{"tasks:read"}, Roles: []models.ModelRole{{Name: "legacy", Permissions: []string{"tasks:legacy"}}}},
+			prune: true,
+			want:  permissions.ErrInvalidRole,
+		},
+		{
+			name:  "role grants an unknown permission",
+			model: models.AuthorizationModel{Version: models.ModelVersion, Roles: []models.ModelRole{{Name: "creator", Permissions: []string{"tasks:create"}}}},
+			want:  permissions.ErrInvalidRole,
+		},
+		{
+			name:  "role is file-managed",
+			model: models.AuthorizationModel{Version: models.ModelVersion, Roles: []models.ModelRole{{Name: "viewer", Permissions: []string{"tasks:read"}}}},
+			want:  permissions.ErrRoleReadOnly,
+		},
+		{
+			name:  "assignment of an unknown role",
+			model: models.AuthorizationModel{Version: models.ModelVersion, Assignments: []models.ModelAssignment{{Subject: "alice", Role: "editor"}}},
+			want:  permissions.ErrInvalidModel,
+		},
+		{
+			name:  "alias of an unknown permission",
+			model: models.AuthorizationModel{Version: models.ModelVersion, Aliases: []models.ModelAlias{{Alias: "tasks:edit", Permission: "tasks:update"}}},
+			want:  permissions.ErrInvalidModel,
+		},
+		{
+			name:  "alias of itself",
+			model: models.AuthorizationModel{Version: models.ModelVersion, Aliases: []models.ModelAlias{{Alias: "tasks:read", Permission: "tasks:read"}}},
+			want:  permissions.ErrInvalidModel,
+		},
+		{
+			name:  "deprecation of an unknown permission",
+			model: models.AuthorizationModel{Version: models.ModelVersion, Deprecations: []models.ModelDeprecation{{Permission: "tasks:create"}}},
+			want:  permissions.ErrInvalidModel,
+		},
+		{
+			name:  "unsupported version",
+			model: models.AuthorizationModel{Version: 99},
+			want:  permissions.ErrInvalidModel,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := service.ImportModel(ctx, &tt.model, permissions.ImportOptions{Prune: tt.prune})
+			if !errors.Is(err, tt.want) {
+				t.Errorf("ImportModel() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+
+	// The in-use error names the roles, and nothing was removed
+	_, err := service.ImportModel(ctx, &tests[0].model, permissions.ImportOptions{Prune: true})
+	if err == nil || !strings.Contains(err.Error(), "tasks:legacy is granted by viewer") {
+		t.Errorf("ImportModel() error = %v, want it to name the role", err)
+	}
+	exported, err := service.ExportModel(ctx)
+	if err != nil || !slices.Equal(exported.Permissions, []string{"tasks:legacy", "tasks:read"}) {
+		t.Errorf("ExportModel() = %+v, %v, want the permissions kept", exported, err)
+	}
+}
+
+func syncRole(t *testing.T, service *permissions.Service, role models.Role) {
+	t.Helper()
+	if _, err := service.SyncRoles(context.Background(), []string{role.Source}, []models.Role{role}, false); err != nil {
+		t.Fatalf("SyncRoles() error = %v", err)
+	}
+}
+
+func assignRole(t *testing.T, service *permissions.Service, subject, role, tenant string) {
+	t.Helper()
+	if _, err := service.AssignRole(context.Background(), subject, role, tenant); err != nil {
+		t.Fatalf("AssignRole() error = %v", err)
+	}
+}
+
+func TestModelEncodingRoundTrip(t *testing.T) {
+	model := &models.AuthorizationModel{Version: models.ModelVersion, Permissions: []string{"auth:read"}}
+
+	for _, format := range []string{permissions.FormatYAML, permissions.FormatJSON} {
+		data, err := permissions.EncodeModel(model, format)
+		if err != nil {
+			t.Fatalf("EncodeModel(%s) error = %v", format, err)
+		}
+
+		decoded, err := permissions.DecodeModel(data)
+		if err != nil {
+			t.Fatalf("DecodeModel(%s) error = %v", format, err)
+		}
+		if decoded.Version != model.Version || !slices.Equal(decoded.Permissions, model.Permissions) {
+			t.Errorf("DecodeModel(%s) = %+v, want %+v", format, decoded, model)
+		}
+	}
+
+	if _, err := permissions.EncodeModel(model, "xml"); err == nil {
+		t.Error("EncodeModel(xml) error = nil, want error")
+	}
+}
+
+func TestSyncRoles(t *testing.T) {
+	service := newService(t, "tasks:read", "tasks:update")
+	ctx := context.Background()
+
+	roles, err := permissions.ParseRoleFile("tasks/roles.yaml", []byte(`
+roles:
+  - name: task-viewer
+    permissions: [tasks:read]
+  - name: task-editor
+    description: Can edit tasks
+    permissions: [tasks:update, tasks:read, tasks:read]
+`))
+	if err != nil {
+		t.Fatalf("ParseRoleFile() error = %v", err)
+	}
+
+	result, err := service.SyncRoles(ctx, []string{"tasks/roles.yaml"}, roles, false)
+	if err != nil {
+		t.Fatalf("SyncRoles() error = %v", err)
+	}
+	if !result.Applied || len(result.Created) != 2 {
+		t.Errorf("result = %+v, want 2 created", result)
+	}
+
+	editor, err := service.GetRole(ctx, "task-editor")
+	if err != nil {
+		t.Fatalf("GetRole() error = %v", err)
+	}
+	if !editor.ReadOnly() || editor.Source != "tasks/roles.yaml" {
+		t.Errorf("role = %+v, want read-only role from tasks/roles.yaml", editor)
+	}
+	if want := []string{"tasks:read", "tasks:update"}; !slices.Equal(editor.Permissions, want) {
+		t.Errorf("Permissions = %v, want %v", editor.Permissions, want)
+	}
+
+	// Dropping a role from the files deletes it; unchanged roles are left alone
+	result, err = service.SyncRoles(ctx, []string{"tasks/roles.yaml"}, roles[1:], false)
+	if err != nil {
+		t.Fatalf("SyncRoles() error = %v", err)
+	}
+	if !slices.Equal(result.Deleted, []string{"task-viewer"}) || result.Unchanged != 1 {
+		t.Errorf("result = %+v, want task-viewer deleted and task-editor unchanged", result)
+	}
+}
+
+func TestSyncRolesOnlyTouchesSyncedFiles(t *testing.T) {
+	service := newService(t, "tasks:read", "auth:read")
+	ctx := context.Background()
+
+	syncRole(t, service, models.Role{Name: "task-viewer", Permissions: []string{"tasks:read"}, Source: "tasks/roles.yaml"})
+	syncRole(t, service, models.Role{Name: "auth-viewer", Permissions: []string{"auth:read"}, Source: "auth/roles.yaml"})
+	assignRole(t, service, "user-1", "auth-viewer", "")
+
+	// Syncing an emptied file deletes its roles, and only its roles
+	result, err := service.SyncRoles(ctx, []string{"tasks/roles.yaml"}, nil, false)
+	if err != nil {
+		t.Fatalf("SyncRoles() error = %v", err)
+	}
+	if !slices.Equal(result.Deleted, []string{"task-viewer"}) {
+		t.Errorf("SyncRoles() = %+v, want only task-viewer deleted", result)
+	}
+	if _, err := service.GetRole(ctx, "auth-viewer"); err != nil {
+		t.Errorf("GetRole(auth-viewer) error = %v, want the role of the other file kept", err)
+	}
+	decision, err := service.Authorize(ctx, "user-1", "auth:read", "")
+	if err != nil || !decision.Allowed {
+		t.Errorf("Authorize() = %+v, %v, want the assignment kept", decision, err)
+	}
+
+	// A file can't take over a role defined in another one
+	_, err = service.SyncRoles(ctx, []string{"tasks/roles.yaml"}, []models.Role{{Name: "auth-viewer", Source: "tasks/roles.yaml"}}, false)
+	if !errors.Is(err, permissions.ErrInvalidRole) {
+		t.Errorf("SyncRoles() error = %v, want ErrInvalidRole", err)
+	}
+
+	// Roles must come from the files being synced
+	_, err = service.SyncRoles(ctx, []string{"tasks/roles.yaml"}, []models.Role{{Name: "other", Source: "auth/roles.yaml"}}, false)
+	if !errors.Is(err, permissions.ErrInvalidRole) {
+		t.Errorf("SyncRoles() error = %v, want ErrInvalidRole", err)
+	}
+}
+
+func TestSyncRoleDir(t *testing.T) {
+	service := newService(t, "tasks:read", "auth:read")
+	ctx := context.Background()
+
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "tasks"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	file := filepath.Join(dir, "tasks", "roles.yaml")
+	if err := os.WriteFile(file, []byte("roles:\n  - name: task-viewer\n    permissions: [tasks:read]\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	source, err := permissions.UploadedRoleSource("auth", "roles.yaml")
+	if err != nil {
+		t.Fatalf("UploadedRoleSource() error = %v", err)
+	}
+	syncRole(t, service, models.Role{Name: "auth-viewer", Permissions: []string{"auth:read"}, Source: source})
+
+	result, err := service.SyncRoleDir(ctx, dir, false)
+	if err != nil {
+		t.Fatalf("SyncRoleDir() error = %v", err)
+	}
+	if !slices.Equal(result.Created, []string{"task-viewer"}) {
+		t.Errorf("SyncRoleDir() = %+v, want task-viewer created", result)
+	}
+
+	// Removing the file removes its roles but leaves uploaded ones alone
+	if err := os.Remove(file); err != nil {
+		t.Fatal(err)
+	}
+	result, err = service.SyncRoleDir(ctx, dir, false)
+	if err != nil {
+		t.Fatalf("SyncRoleDir() error = %v", err)
+	}
+	if !slices.Equal(result.Deleted, []string{"task-viewer"}) {
+		t.Errorf("SyncRoleDir() = %+v, want only task-viewer deleted", result)
+	}
+
+	for _, path := range []string{"", ".", "/etc/roles.yaml", "../roles.yaml", "tasks//roles.yaml"} {
+		if _, err := permissions.UploadedRoleSource("auth", path); err == nil {
+			t.Errorf("UploadedRoleSource(%q) error = nil, want error", path)
+		}
+	}
+}
+
+func TestSyncRolesValidation(t *testing.T) {
+	service := newService(t, "tasks:read")
+	ctx := context.Background()
+
+	if err := service.CreateRole(ctx, &models.Role{Name: "manual", Permissions: []string{"tasks:read"}}); err != nil {
+		t.Fatalf("CreateRole() error = %v", err)
+	}
+
+	roles := []models.Role{
+		{Name: "unknown-permission", Permissions: []string{"tasks:delete"}, Source: "a.yaml"},
+		{Name: "Bad Name", Source: "a.yaml"},
+		{Name: "manual", Source: "b.yaml"},
+	}
+
+	_, err := service.SyncRoles(ctx, []string{"a.yaml", "b.yaml"}, roles, false)
+	if !errors.Is(err, permissions.ErrInvalidRole) {
+		t.Fatalf("SyncRoles() error = %v, want ErrInvalidRole", err)
+	}
+
+	// Nothing may have been applied
+	all, err := service.GetAllRoles(ctx, 1, 10)
+	if err != nil {
+		t.Fatalf("GetAllRoles() error = %v", err)
+	}
+	if all.TotalCount != 1 {
+		t.Errorf("TotalCount = %v, want 1", all.TotalCount)
+	}
+}
+
+func TestFileManagedRolesAreReadOnly(t *testing.T) {
+	service := newService(t, "tasks:read")
+	ctx := context.Background()
+
+	roles := []models.Role{{Name: "task-viewer", Permissions: []string{"tasks:read"}, Source: "roles.yaml"}}
+	if _, err := service.SyncRoles(ctx, []string{"roles.yaml"}, roles, false); err != nil {
+		t.Fatalf("SyncRoles() error = %v", err)
+	}
+
+	err := service.UpdateRole(ctx, &models.Role{Name: "task-viewer"})
+	if !errors.Is(err, permissions.ErrRoleReadOnly) {
+		t.Errorf("UpdateRole() error = %v, want ErrRoleReadOnly", err)
+	}
+
+	err = service.DeleteRole(ctx, "task-viewer")
+	if !errors.Is(err, permissions.ErrRoleReadOnly) {
+		t.Errorf("DeleteRole() error = %v, want ErrRoleReadOnly", err)
+	}
+
+	err = service.CreateRole(ctx, &models.Role{Name: "task-viewer"})
+	if !errors.Is(err, permissions.ErrRoleExists) {
+		t.Errorf("CreateRole() error = %v, want ErrRoleExists", err)
+	}
+
+	_, err = service.GetRole(ctx, "missing")
+	if !errors.Is(err, permissions.ErrRoleNotFound) {
+		t.Errorf("GetRole() error = %v, want ErrRoleNotFound", err)
+	}
+}
+
+func TestPermissionAlias(t *testing.T) {
+	service := newService(t, "tasks:edit", "tasks:update")
+	ctx := context.Background()
+
+	roles, err := permissions.ParseRoleFile("tasks/roles.yaml", []byte(`
+roles:
+  - name: task-editor
+    permissions: [tasks:edit]
+`))
+	if err != nil {
+		t.Fatalf("ParseRoleFile() error = %v", err)
+	}
+	if _, err := service.SyncRoles(ctx, []string{"tasks/roles.yaml"}, roles, false); err != nil {
+		t.Fatalf("SyncRoles() error = %v", err)
+	}
+	if _, err := service.AssignRole(ctx, "user-1", "task-editor", ""); err != nil {
+		t.Fatalf("AssignRole() error = %v", err)
+	}
+
+	if _, err := service.CreatePermissionAlias(ctx, "tasks:update", "tasks:update"); !errors.Is(err, permissions.ErrInvalidAlias) {
+		t.Errorf("CreatePermissionAlias() self error = %v, want ErrInvalidAlias", err)
+	}
+	if _, err := service.CreatePermissionAlias(ctx, "tasks:edit", "tasks:missing"); !errors.Is(err, permissions.ErrPermissionNotFound) {
+		t.Errorf("CreatePermissionAlias() unknown error = %v, want ErrPermissionNotFound", err)
+	}
+
+	result, err := service.CreatePermissionAlias(ctx, "tasks:edit", "tasks:update")
+	if err != nil {
+		t.Fatalf("CreatePermissionAlias() error = %v", err)
+	}
+	if !slices.Equal(result.MigratedRoles, []string{"task-editor"}) {
+		t.Errorf("MigratedRoles = %v, want [task-editor]", result.MigratedRoles)
+	}
+
+	// Checks with the old name resolve to the new permission
+	decision, err := service.Authorize(ctx, "user-1", "tasks:edit", "")
+	if err != nil {
+		t.Fatalf("Authorize() error = %v", err)
+	}
+	if !decision.Allowed || decision.CanonicalPermission != "tasks:update" {
+		t.Errorf("Authorize() = %+v, want allowed through tasks:update", decision)
+	}
+
+	// Role files that still use the old name are unchanged, not invalid
+	sync, err := service.SyncRoles(ctx, []string{"tasks/roles.yaml"}, roles, false)
+	if err != nil {
+		t.Fatalf("SyncRoles() error = %v", err)
+	}
+	if sync.Unchanged != 1 {
+		t.Errorf("SyncRoles() = %+v, want the role unchanged", sync)
+	}
+
+	// Services still publishing the old name don't bring it back
+	if err := service.RegisterServicePermissions(ctx, "tasks", []string{"tasks:edit", "tasks:update"}); err != nil {
+		t.Fatalf("RegisterServicePermissions() error = %v", err)
+	}
+	all, err := service.GetAllPermissions(ctx, 1, 10)
+	if err != nil {
+		t.Fatalf("GetAllPermissions() error = %v", err)
+	}
+	if all.TotalCount != 1 {
+		t.Errorf("TotalCount = %v, want only tasks:update", all.TotalCount)
+	}
+}
+
+func TestDeprecateAndDeletePermission(t *testing.T) {
+	service := newService(t, "tasks:read", "tasks:archive")
+	ctx := context.Background()
+
+	if err := service.CreateRole(ctx, &models.Role{Name: "viewer", Permissions: []string{"tasks:read"}}); err != nil {
+		t.Fatalf("CreateRole() error = %v", err)
+	}
+	if _, err := service.AssignRole(ctx, "user-1", "viewer", ""); err != nil {
+		t.Fatalf("AssignRole() error = %v", err)
+	}
+
+	if err := service.DeprecatePermission(ctx, "tasks:missing", ""); !errors.Is(err, permissions.ErrPermissionNotFound) {
+		t.Errorf("DeprecatePermission() error = %v, want ErrPermissionNotFound", err)
+	}
+	if err := service.DeprecatePermission(ctx, "tasks:read", "use tasks:view"); err != nil {
+		t.Fatalf("DeprecatePermission() error = %v", err)
+	}
+
+	// Deprecated permissions keep working
+	decision, err := service.Authorize(ctx, "user-1", "tasks:read", "")
+	if err != nil {
+		t.Fatalf("Authorize() error = %v", err)
+	}
+	if !decision.Allowed || !decision.Deprecated {
+		t.Errorf("Authorize() = %+v, want allowed and deprecated", decision)
+	}
+
+	if _, err := service.DeletePermission(ctx, "tasks:read", false); !errors.Is(err, permissions.ErrPermissionInUse) {
+		t.Errorf("DeletePermission() error = %v, want ErrPermissionInUse", err)
+	}
+
+	deletion, err := service.DeletePermission(ctx, "tasks:read", true)
+	if err != nil {
+		t.Fatalf("DeletePermission() force error = %v", err)
+	}
+	if !slices.Equal(deletion.RemovedFromRoles, []string{"viewer"}) {
+		t.Errorf("RemovedFromRoles = %v, want [viewer]", deletion.RemovedFromRoles)
+	}
+
+	if _, err := service.DeletePermission(ctx, "tasks:archive", false); err != nil {
+		t.Errorf("DeletePermission() unused error = %v", err)
+	}
+	if _, err := service.DeletePermission(ctx, "tasks:archive", false); !errors.Is(err, permissions.ErrPermissionNotFound) {
+		t.Errorf("DeletePermission() missing error = %v, want ErrPermissionNotFound", err)
+	}
+}
+
+func TestUsageRecorder(t *testing.T) {
+	repo := memory.NewRepository()
+	service := permissions.NewService(repo)
+	ctx := context.Background()
+
+	if err := service.RegisterServicePermissions(ctx, "tasks", []string{"tasks:read", "tasks:delete"}); err != nil {
+		t.Fatalf("RegisterServicePermissions() error = %v", err)
+	}
+	if err := service.CreateRole(ctx, &models.Role{Name: "viewer", Permissions: []string{"tasks:read"}}); err != nil {
+		t.Fatalf("CreateRole() error = %v", err)
+	}
+	if _, err := service.AssignRole(ctx, "user-1", "viewer", ""); err != nil {
+		t.Fatalf("AssignRole() error = %v", err)
+	}
+
+	recorder := permissions.NewUsageRecorder(repo, time.Hour, 100, func(err error) {
+		t.Errorf("usage recorder error = %v", err)
+	})
+	recorder.Start()
+	service.SetUsageRecorder(recorder)
+
+	for _, subject := range []string{"user-1", "user-1", "user-2"} {
+		if _, err := service.Authorize(ctx, subject, "tasks:read", ""); err != nil {
+			t.Fatalf("Authorize() error = %v", err)
+		}
+	}
+
+	// Explanations and checks of unknown permissions are not usage
+	if _, err := service.ExplainAuthorization(ctx, "user-1", "tasks:read", ""); err != nil {
+		t.Fatalf("ExplainAuthorization() error = %v", err)
+	}
+	if _, err := service.Authorize(ctx, "user-1", "tasks:missing", ""); err != nil {
+		t.Fatalf("Authorize() error = %v", err)
+	}
+
+	recorder.Close()
+
+	usage, err := service.GetPermissionUsage(ctx, "", nil)
+	if err != nil {
+		t.Fatalf("GetPermissionUsage() error = %v", err)
+	}
+	if len(usage) != 2 || usage[1].Permission != "tasks:read" {
+		t.Fatalf("GetPermissionUsage() = %+v, want tasks:delete and tasks:read", usage)
+	}
+
+	read := usage[1]
+	if read.CheckCount != 3 || len(read.Roles) != 1 || read.Roles[0].GrantCount != 2 {
+		t.Errorf("tasks:read usage = %+v, want 3 checks and 2 grants by viewer", read)
+	}
+
+	since := time.Now().Add(-time.Hour)
+	unused, err := service.GetPermissionUsage(ctx, "", &since)
+	if err != nil {
+		t.Fatalf("GetPermissionUsage() error = %v", err)
+	}
+	if len(unused) != 1 || unused[0].Permission != "tasks:delete" {
+		t.Errorf("GetPermissionUsage() unused = %+v, want only tasks:delete", unused)
+	}
+}
+
+func TestReviewCampaign(t *testing.T) {
+	service := newService(t, "tasks:read")
+	ctx := context.Background()
+
+	if err := service.CreateRole(ctx, &models.Role{Name: "viewer", Permissions: []string{"tasks:read"}}); err != nil {
+		t.Fatalf("CreateRole() error = %v", err)
+	}
+	for _, subject := range []string{"alice", "bob", "carol"} {
+		if _, err := service.AssignRole(ctx, subject, "viewer", ""); err != nil {
+			t.Fatalf("AssignRole() error = %v", err)
+		}
+	}
+
+	err := service.CreateReviewCampaign(ctx, &models.ReviewCampaign{Name: "q1", Roles: []string{"missing"}, Deadline: time.Now().Add(-time.Hour)})
+	if !errors.Is(err, permissions.ErrInvalidCampaign) {
+		t.Errorf("CreateReviewCampaign(invalid) error = %v, want ErrInvalidCampaign", err)
+	}
+
+	campaign := &models.ReviewCampaign{Name: "q1", Roles: []string{"viewer", "viewer"}, Deadline: time.Now().Add(time.Hour)}
+	if err := service.CreateReviewCampaign(ctx, campaign); err != nil {
+		t.Fatalf("CreateReviewCampaign() error = %v", err)
+	}
+	if !slices.Equal(campaign.Roles, []string{"viewer"}) || campaign.Pending != 3 {
+		t.Fatalf("campaign = %+v, want 3 pending viewer items", campaign)
+	}
+
+	items, err := service.GetReviewItems(ctx, campaign.ID, models.ReviewPending)
+	if err != nil {
+		t.Fatalf("GetReviewItems() error = %v", err)
+	}
+	alice, bob := items[0], items[1]
+
+	if _, err := service.ApproveReviewItem(ctx, alice.ID, "alice", ""); !errors.Is(err, permissions.ErrSelfReview) {
+		t.Errorf("ApproveReviewItem(self) error = %v, want ErrSelfReview", err)
+	}
+	if _, err := service.ApproveReviewItem(ctx, alice.ID, "auditor", "needed"); err != nil {
+		t.Fatalf("ApproveReviewItem() error = %v", err)
+	}
+	if _, err := service.RevokeReviewItem(ctx, alice.ID, "auditor", ""); !errors.Is(err, permissions.ErrReviewItemDecided) {
+		t.Errorf("RevokeReviewItem(decided) error = %v, want ErrReviewItemDecided", err)
+	}
+
+	revoked, err := service.RevokeReviewItem(ctx, bob.ID, "auditor", "left the team")
+	if err != nil {
+		t.Fatalf("RevokeReviewItem() error = %v", err)
+	}
+	if revoked.Decision != models.ReviewRevoked || revoked.DecidedAt == nil {
+		t.Errorf("RevokeReviewItem() = %+v, want a revoked decision", revoked)
+	}
+
+	decision, err := service.Authorize(ctx, "bob", "tasks:read", "")
+	if err != nil {
+		t.Fatalf("Authorize() error = %v", err)
+	}
+	if decision.Allowed {
+		t.Error("Authorize(revoked) allowed, want denied")
+	}
+
+	closed, err := service.CloseExpiredReviewCampaigns(ctx, time.Now())
+	if err != nil || closed != 0 {
+		t.Errorf("CloseExpiredReviewCampaigns(before deadline) = %d, %v, want 0", closed, err)
+	}
+
+	closed, err = service.CloseExpiredReviewCampaigns(ctx, time.Now().Add(2*time.Hour))
+	if err != nil || closed != 1 {
+		t.Fatalf("CloseExpiredReviewCampaigns() = %d, %v, want carol auto-revoked", closed, err)
+	}
+
+	stored, err := service.GetReviewCampaign(ctx, campaign.ID)
+	if err != nil {
+		t.Fatalf("GetReviewCampaign() error = %v", err)
+	}
+	want := models.ReviewSummary{Approved: 1, Revoked: 1, AutoRevoked: 1}
+	if stored.Status != models.CampaignClosed || stored.ReviewSummary != want {
+		t.Errorf("campaign = %s %+v, want closed with %+v", stored.Status, stored.ReviewSummary, want)
+	}
+
+	if _, err := service.ApproveReviewItem(ctx, items[2].ID, "auditor", ""); !errors.Is(err, permissions.ErrCampaignClosed) {
+		t.Errorf("ApproveReviewItem(closed) error = %v, want ErrCampaignClosed", err)
+	}
+}
+
+func TestExportReviewReport(t *testing.T) {
+	service := newService(t)
+	ctx := context.Background()
+
+	if err := service.CreateRole(ctx, &models.Role{Name: "viewer"}); err != nil {
+		t.Fatalf("CreateRole() error = %v", err)
+	}
+	if _, err := service.AssignRole(ctx, "alice", "viewer", "acme"); err != nil {
+		t.Fatalf("AssignRole() error = %v", err)
+	}
+
+	campaign := &models.ReviewCampaign{Name: "q1", Deadline: time.Now().Add(time.Hour)}
+	if err := service.CreateReviewCampaign(ctx, campaign); err != nil {
+		t.Fatalf("CreateReviewCampaign() error = %v", err)
+	}
+
+	if _, err := service.ExportReviewReport(ctx, campaign.ID, permissions.FormatCSV); !errors.Is(err, permissions.ErrReportSigningKey) {
+		t.Errorf("ExportReviewReport(no key) error = %v, want ErrReportSigningKey", err)
+	}
+
+	key, err := permissions.ParseReportSigningKey("AAECAwQFBgcICQoLDA0ODxAREhMUFRYXGBkaGxwdHh8=")
+	if err != nil {
+		t.Fatalf("ParseReportSigningKey() error = %v", err)
+	}
+	service.SetReportSigningKey(key)
+
+	items, err := service.GetReviewItems(ctx, campaign.ID, "")
+	if err != nil {
+		t.Fatalf("GetReviewItems() error = %v", err)
+	}
+	if _, err := service.ApproveReviewItem(ctx, items[0].ID, "auditor", "=HYPERLINK(\"http://evil\")"); err != nil {
+		t.Fatalf("ApproveReviewItem() error = %v", err)
+	}
+
+	report, err := service.ExportReviewReport(ctx, campaign.ID, permissions.FormatCSV)
+	if err != nil {
+		t.Fatalf("ExportReviewReport() error = %v", err)
+	}
+	if !strings.Contains(string(report.Document), `"'=HYPERLINK(""http://evil"")"`) {
+		t.Errorf("document = %s, want the comment escaped", report.Document)
+	}
+	if !permissions.VerifyReviewReport(report.Document, report.Signature, report.PublicKey) {
+		t.Error("VerifyReviewReport() = false, want a valid signature")
+	}
+
+	tampered := slices.Clone(report.Document)
+	tampered[len(tampered)-2] ^= 1
+	if permissions.VerifyReviewReport(tampered, report.Signature, report.PublicKey) {
+		t.Error("VerifyReviewReport(tampered) = true, want false")
+	}
+
+	if _, err := service.ExportReviewReport(ctx, campaign.ID, "xml"); err == nil {
+		t.Error("ExportReviewReport(xml) error = nil, want error")
+	}
+}
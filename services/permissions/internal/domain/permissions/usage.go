@@ -0,0 +1,215 @@
+package permissions
+
+import (
+	"context"
+	"fmt"
+	"intellifinder/services/permissions/pkg/models"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// usageWriteTimeout bounds a single batch write
+const usageWriteTimeout = 10 * time.Second
+
+type usageEvent struct {
+	permission string
+	roles      []string
+	at         time.Time
+}
+
+type roleUsageKey struct {
+	role       string
+	permission string
+}
+
+// UsageRecorder collects permission and role usage from authorization checks
+// and writes it in batches from a background goroutine, so checks never wait
+// on the database. Events are dropped rather than blocking when the buffer is
+// full; the number dropped is reported through onError.
+type UsageRecorder struct {
+	repo      Repository
+	interval  time.Duration
+	batchSize int
+	onError   func(error)
+
+	events  chan usageEvent
+	stop    chan struct{}
+	done    chan struct{}
+	once    sync.Once
+	dropped atomic.Int64
+}
+
+// NewUsageRecorder creates a recorder that writes every interval or once
+// batchSize checks are pending. onError receives write failures and may be nil.
+func NewUsageRecorder(repo Repository, interval time.Duration, batchSize int, onError func(error)) *UsageRecorder {
+	if onError == nil {
+		onError = func(error) {}
+	}
+
+	return &UsageRecorder{
+		repo:      repo,
+		interval:  interval,
+		batchSize: batchSize,
+		onError:   onError,
+		events:    make(chan usageEvent, batchSize),
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+}
+
+// Start runs the background writer until Close is called
+func (r *UsageRecorder) Start() {
+	go r.run()
+}
+
+// Record notes a check of permission that was allowed by roles (empty when
+// denied). It never blocks.
+func (r *UsageRecorder) Record(permission string, roles []string) {
+	select {
+	case r.events <- usageEvent{permission: permission, roles: roles, at: time.Now()}:
+	default:
+		r.dropped.Add(1)
+	}
+}
+
+// Close stops the writer after flushing pending usage
+func (r *UsageRecorder) Close() {
+	r.once.Do(func() {
+		close(r.stop)
+	})
+	<-r.done
+}
+
+func (r *UsageRecorder) run() {
+	defer close(r.done)
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	batch := newUsageBatch()
+	for {
+		select {
+		case event := <-r.events:
+			batch.add(event)
+			if batch.events >= r.batchSize {
+				r.flush(batch)
+				batch = newUsageBatch()
+			}
+		case <-ticker.C:
+			r.flush(batch)
+			batch = newUsageBatch()
+		case <-r.stop:
+			for {
+				select {
+				case event := <-r.events:
+					batch.add(event)
+				default:
+					r.flush(batch)
+					return
+				}
+			}
+		}
+	}
+}
+
+func (r *UsageRecorder) flush(batch *usageBatch) {
+	if dropped := r.dropped.Swap(0); dropped > 0 {
+		r.onError(fmt.Errorf("dropped %d usage events because the buffer was full", dropped))
+	}
+
+	if batch.events == 0 {
+		return
+	}
+
+	permissions := make([]models.PermissionUsage, 0, len(batch.permissions))
+	for _, usage := range batch.permissions {
+		permissions = append(permissions, *usage)
+	}
+
+	roles := make([]models.RoleUsage, 0, len(batch.roles))
+	for _, usage := range batch.roles {
+		roles = append(roles, *usage)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), usageWriteTimeout)
+	defer cancel()
+
+	if err := r.repo.RecordUsage(ctx, permissions, roles); err != nil {
+		r.onError(fmt.Errorf("failed to record usage of %d checks: %w", batch.events, err))
+	}
+}
+
+// usageBatch aggregates events so a batch writes one row per permission and role
+type usageBatch struct {
+	events      int
+	permissions map[string]*models.PermissionUsage
+	roles       map[roleUsageKey]*models.RoleUsage
+}
+
+func newUsageBatch() *usageBatch {
+	return &usageBatch{
+		permissions: make(map[string]*models.PermissionUsage),
+		roles:       make(map[roleUsageKey]*models.RoleUsage),
+	}
+}
+
+func (b *usageBatch) add(event usageEvent) {
+	b.events++
+
+	usage, ok := b.permissions[event.permission]
+	if !ok {
+		usage = &models.PermissionUsage{Permission: event.permission}
+		b.permissions[event.permission] = usage
+	}
+	usage.CheckCount++
+	usage.LastCheckedAt = latest(usage.LastCheckedAt, event.at)
+
+	for _, role := range event.roles {
+		key := roleUsageKey{role: role, permission: event.permission}
+		roleUsage, ok := b.roles[key]
+		if !ok {
+			roleUsage = &models.RoleUsage{Role: role, Permission: event.permission}
+			b.roles[key] = roleUsage
+		}
+		roleUsage.GrantCount++
+		roleUsage.LastGrantedAt = latest(roleUsage.LastGrantedAt, event.at)
+	}
+}
+
+func latest(current *time.Time, at time.Time) *time.Time {
+	if current != nil && current.After(at) {
+		return current
+	}
+	return &at
+}
+
+// SetUsageRecorder makes Authorize record the usage of every registered
+// permission it checks
+func (s *Service) SetUsageRecorder(recorder *UsageRecorder) {
+	s.usage = recorder
+}
+
+// GetPermissionUsage reports how often each permission of service (every
+// service when empty) was checked and which granting roles allowed those
+// checks. With unusedSince set, only permissions not checked since then are
+// returned.
+func (s *Service) GetPermissionUsage(ctx context.Context, service string, unusedSince *time.Time) ([]models.PermissionUsage, error) {
+	usage, err := s.repo.ListPermissionUsage(ctx, service)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get permission usage: %w", err)
+	}
+
+	if unusedSince == nil {
+		return usage, nil
+	}
+
+	unused := []models.PermissionUsage{}
+	for _, permission := range usage {
+		if permission.LastCheckedAt == nil || permission.LastCheckedAt.Before(*unusedSince) {
+			unused = append(unused, permission)
+		}
+	}
+
+	return unused, nil
+}
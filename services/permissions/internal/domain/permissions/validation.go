@@ -27,3 +27,21 @@ func (s *Service) validatePermission(permission string) error {
 
 	return nil
 }
+
+func validateRoleName(name string) error {
+	if name == "" {
+		return fmt.Errorf("role name is required")
+	}
+
+	if len(name) > 255 {
+		return fmt.Errorf("role name must be at most 255 characters")
+	}
+
+	for _, r := range name {
+		if !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-' || r == '_' || r == '.') {
+			return fmt.Errorf("role name may only contain lowercase letters, digits, '-', '_' and '.'")
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,85 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"intellifinder/services/permissions/pkg/dto"
+	"intellifinder/services/permissions/pkg/models"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+func (r *PermissionRepository) CreateAssignment(ctx context.Context, assignment *models.RoleAssignment) error {
+	err := r.db.QueryRow(ctx, insertRoleAssignment, assignment.Subject, assignment.Role, assignment.Tenant, time.Now()).
+		Scan(&assignment.ID, &assignment.CreatedAt)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return fmt.Errorf("failed to create role assignment: role %s does not exist", assignment.Role)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create role assignment: %w", err)
+	}
+	return nil
+}
+
+func (r *PermissionRepository) DeleteAssignment(ctx context.Context, subject string, role string, tenant string) (bool, error) {
+	tag, err := r.db.Exec(ctx, deleteRoleAssignment, subject, role, tenant)
+	if err != nil {
+		return false, fmt.Errorf("failed to delete role assignment: %w", err)
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+func (r *PermissionRepository) GetAssignments(ctx context.Context, filter dto.AssignmentFilter, page int32, limit int32) (*dto.PaginatedAssignments, error) {
+	offset := (page - 1) * limit
+
+	rows, err := r.db.Query(ctx, getRoleAssignments, filter.Subject, filter.Role, filter.Tenant, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get role assignments: %w", err)
+	}
+	defer rows.Close()
+
+	assignments, err := pgx.CollectRows(rows, pgx.RowToStructByName[models.RoleAssignment])
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect role assignment rows: %w", err)
+	}
+
+	var totalCount int32
+	err = r.db.QueryRow(ctx, countRoleAssignments, filter.Subject, filter.Role, filter.Tenant).Scan(&totalCount)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count role assignments: %w", err)
+	}
+
+	return dto.NewPaginatedAssignments(assignments, page, limit, totalCount), nil
+}
+
+func (r *PermissionRepository) ListAssignments(ctx context.Context) ([]models.RoleAssignment, error) {
+	rows, err := r.db.Query(ctx, listRoleAssignments)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list role assignments: %w", err)
+	}
+	defer rows.Close()
+
+	assignments, err := pgx.CollectRows(rows, pgx.RowToStructByName[models.RoleAssignment])
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect role assignment rows: %w", err)
+	}
+
+	return assignments, nil
+}
+
+func (r *PermissionRepository) GetSubjectRoles(ctx context.Context, subject string, tenant string) ([]models.Role, error) {
+	rows, err := r.db.Query(ctx, getSubjectRoles, subject, tenant)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get subject roles: %w", err)
+	}
+	defer rows.Close()
+
+	roles, err := pgx.CollectRows(rows, pgx.RowToStructByName[models.Role])
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect role rows: %w", err)
+	}
+
+	return roles, nil
+}
@@ -0,0 +1,177 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"intellifinder/services/permissions/pkg/models"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+func (r *PermissionRepository) GetPermission(ctx context.Context, service string, action string) (*models.Permission, error) {
+	rows, err := r.db.Query(ctx, getPermissionByServiceAndAction, service, action)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get permission: %w", err)
+	}
+	defer rows.Close()
+
+	permission, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[models.Permission])
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect permission row: %w", err)
+	}
+
+	return &permission, nil
+}
+
+func (r *PermissionRepository) DeprecatePermission(ctx context.Context, service string, action string, reason string) (bool, error) {
+	tag, err := r.db.Exec(ctx, deprecatePermission, service, action, time.Now(), reason)
+	if err != nil {
+		return false, fmt.Errorf("failed to deprecate permission: %w", err)
+	}
+	return tag.RowsAffected() > 0, nil
+}
+
+func (r *PermissionRepository) GetPermissionRoles(ctx context.Context, service string, action string) ([]string, error) {
+	rows, err := r.db.Query(ctx, getPermissionRoles, service, action)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get permission roles: %w", err)
+	}
+	defer rows.Close()
+
+	roles, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect role names: %w", err)
+	}
+
+	return roles, nil
+}
+
+func (r *PermissionRepository) DeletePermission(ctx context.Context, service string, action string, force bool) (bool, error) {
+	var found bool
+	err := pgx.BeginFunc(ctx, r.db, func(tx pgx.Tx) error {
+		if force {
+			if _, err := tx.Exec(ctx, deletePermissionBindings, service, action); err != nil {
+				return fmt.Errorf("failed to remove permission from roles: %w", err)
+			}
+		}
+
+		// Without force the foreign key rejects permissions that are still bound to a role
+		tag, err := tx.Exec(ctx, deletePermissionByServiceAndAction, service, action)
+		if err != nil {
+			return fmt.Errorf("failed to delete permission: %w", err)
+		}
+
+		found = tag.RowsAffected() > 0
+		return nil
+	})
+
+	return found, err
+}
+
+func (r *PermissionRepository) GetPermissionAlias(ctx context.Context, alias string) (*models.PermissionAlias, error) {
+	rows, err := r.db.Query(ctx, getPermissionAlias, alias)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get permission alias: %w", err)
+	}
+	defer rows.Close()
+
+	permissionAlias, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[models.PermissionAlias])
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect permission alias row: %w", err)
+	}
+
+	return &permissionAlias, nil
+}
+
+func (r *PermissionRepository) ListPermissionAliases(ctx context.Context) ([]models.PermissionAlias, error) {
+	rows, err := r.db.Query(ctx, listPermissionAliases)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list permission aliases: %w", err)
+	}
+	defer rows.Close()
+
+	aliases, err := pgx.CollectRows(rows, pgx.RowToStructByName[models.PermissionAlias])
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect permission alias rows: %w", err)
+	}
+
+	return aliases, nil
+}
+
+func (r *PermissionRepository) CreatePermissionAlias(ctx context.Context, alias string, permission string) ([]string, error) {
+	var migrated []string
+	err := pgx.BeginFunc(ctx, r.db, func(tx pgx.Tx) error {
+		var err error
+		migrated, err = createPermissionAlias(ctx, tx, alias, permission)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return migrated, nil
+}
+
+// createPermissionAlias makes alias resolve to permission and returns the
+// roles whose bindings were moved from alias
+func createPermissionAlias(ctx context.Context, tx pgx.Tx, alias string, permission string) ([]string, error) {
+	var targetID uuid.UUID
+	err := tx.QueryRow(ctx, getPermissionIDByName, permission).Scan(&targetID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("permission %s does not exist", permission)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get permission %s: %w", permission, err)
+	}
+
+	var aliasID uuid.UUID
+	err = tx.QueryRow(ctx, getPermissionIDByName, alias).Scan(&aliasID)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return nil, fmt.Errorf("failed to get permission %s: %w", alias, err)
+	}
+
+	// The old name is still registered: move everything that points at it
+	migrated := []string{}
+	if err == nil {
+		rows, err := tx.Query(ctx, getRoleNamesByPermissionID, aliasID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get roles granting %s: %w", alias, err)
+		}
+
+		migrated, err = pgx.CollectRows(rows, pgx.RowTo[string])
+		if err != nil {
+			return nil, fmt.Errorf("failed to collect role names: %w", err)
+		}
+
+		if _, err := tx.Exec(ctx, migrateRolePermissions, aliasID, targetID); err != nil {
+			return nil, fmt.Errorf("failed to migrate role bindings: %w", err)
+		}
+
+		if _, err := tx.Exec(ctx, deleteRolePermissionsByPermissionID, aliasID); err != nil {
+			return nil, fmt.Errorf("failed to remove role bindings of %s: %w", alias, err)
+		}
+
+		if _, err := tx.Exec(ctx, repointPermissionAliases, aliasID, targetID); err != nil {
+			return nil, fmt.Errorf("failed to migrate aliases of %s: %w", alias, err)
+		}
+
+		if _, err := tx.Exec(ctx, deletePermissionByID, aliasID); err != nil {
+			return nil, fmt.Errorf("failed to delete permission %s: %w", alias, err)
+		}
+	}
+
+	if _, err := tx.Exec(ctx, insertPermissionAlias, alias, targetID, time.Now()); err != nil {
+		return nil, fmt.Errorf("failed to create permission alias: %w", err)
+	}
+
+	return migrated, nil
+}
@@ -19,5 +19,82 @@ func CreatePermissionTable(ctx context.Context, db *pgxpool.Pool) error {
 		return fmt.Errorf("failed to create permission indexes: %w", err)
 	}
 
+	_, err = db.Exec(ctx, alterPermissionTableDeprecation)
+	if err != nil {
+		return fmt.Errorf("failed to add permission deprecation columns: %w", err)
+	}
+
+	_, err = db.Exec(ctx, createPermissionAliasTable)
+	if err != nil {
+		return fmt.Errorf("failed to create permission alias table: %w", err)
+	}
+
+	_, err = db.Exec(ctx, createPermissionAliasIndex)
+	if err != nil {
+		return fmt.Errorf("failed to create permission alias indexes: %w", err)
+	}
+
+	return nil
+}
+
+func CreateRoleTables(ctx context.Context, db *pgxpool.Pool) error {
+	_, err := db.Exec(ctx, createRoleTable)
+	if err != nil {
+		return fmt.Errorf("failed to create role table: %w", err)
+	}
+
+	_, err = db.Exec(ctx, createRolePermissionTable)
+	if err != nil {
+		return fmt.Errorf("failed to create role permission table: %w", err)
+	}
+
+	_, err = db.Exec(ctx, createRolePermissionIndex)
+	if err != nil {
+		return fmt.Errorf("failed to create role permission indexes: %w", err)
+	}
+
+	_, err = db.Exec(ctx, createRoleAssignmentTable)
+	if err != nil {
+		return fmt.Errorf("failed to create role assignment table: %w", err)
+	}
+
+	_, err = db.Exec(ctx, createRoleAssignmentIndex)
+	if err != nil {
+		return fmt.Errorf("failed to create role assignment indexes: %w", err)
+	}
+
+	return nil
+}
+
+func CreateUsageTables(ctx context.Context, db *pgxpool.Pool) error {
+	_, err := db.Exec(ctx, createPermissionUsageTable)
+	if err != nil {
+		return fmt.Errorf("failed to create permission usage table: %w", err)
+	}
+
+	_, err = db.Exec(ctx, createRolePermissionUsageTable)
+	if err != nil {
+		return fmt.Errorf("failed to create role permission usage table: %w", err)
+	}
+
+	return nil
+}
+
+func CreateReviewTables(ctx context.Context, db *pgxpool.Pool) error {
+	_, err := db.Exec(ctx, createReviewCampaignTable)
+	if err != nil {
+		return fmt.Errorf("failed to create review campaign table: %w", err)
+	}
+
+	_, err = db.Exec(ctx, createReviewItemTable)
+	if err != nil {
+		return fmt.Errorf("failed to create review item table: %w", err)
+	}
+
+	_, err = db.Exec(ctx, createReviewIndex)
+	if err != nil {
+		return fmt.Errorf("failed to create review indexes: %w", err)
+	}
+
 	return nil
 }
@@ -17,6 +17,84 @@ const (
 		CREATE INDEX IF NOT EXISTS idx_permissions_action ON permissions (action);
 	`
 
+	alterPermissionTableDeprecation = `
+		ALTER TABLE permissions
+			ADD COLUMN IF NOT EXISTS deprecated_at TIMESTAMP,
+			ADD COLUMN IF NOT EXISTS deprecation_reason TEXT NOT NULL DEFAULT ''
+	`
+
+	// An alias is removed with the permission it resolves to
+	createPermissionAliasTable = `
+		CREATE TABLE IF NOT EXISTS permission_aliases (
+			alias VARCHAR(511) PRIMARY KEY,
+			permission_id UUID NOT NULL REFERENCES permissions (id) ON DELETE CASCADE,
+			created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)
+	`
+
+	createPermissionAliasIndex = `
+		CREATE INDEX IF NOT EXISTS idx_permission_aliases_permission ON permission_aliases (permission_id);
+	`
+
+	createRoleTable = `
+		CREATE TABLE IF NOT EXISTS roles (
+			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			name VARCHAR(255) NOT NULL,
+			description TEXT NOT NULL DEFAULT '',
+			source VARCHAR(1024) NOT NULL DEFAULT '',
+			created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			CONSTRAINT unique_role_name UNIQUE (name)
+		)
+	`
+
+	// Permissions referenced by a role can't be deleted until the binding is removed
+	createRolePermissionTable = `
+		CREATE TABLE IF NOT EXISTS role_permissions (
+			role_id UUID NOT NULL REFERENCES roles (id) ON DELETE CASCADE,
+			permission_id UUID NOT NULL REFERENCES permissions (id),
+			PRIMARY KEY (role_id, permission_id)
+		)
+	`
+
+	createRolePermissionIndex = `
+		CREATE INDEX IF NOT EXISTS idx_role_permissions_permission ON role_permissions (permission_id);
+	`
+
+	createRoleAssignmentTable = `
+		CREATE TABLE IF NOT EXISTS role_assignments (
+			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			subject VARCHAR(255) NOT NULL,
+			role_id UUID NOT NULL REFERENCES roles (id) ON DELETE CASCADE,
+			tenant VARCHAR(255) NOT NULL DEFAULT '',
+			created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			CONSTRAINT unique_role_assignment UNIQUE (subject, role_id, tenant)
+		)
+	`
+
+	createRoleAssignmentIndex = `
+		CREATE INDEX IF NOT EXISTS idx_role_assignments_role ON role_assignments (role_id);
+		CREATE INDEX IF NOT EXISTS idx_role_assignments_tenant ON role_assignments (tenant);
+	`
+
+	createPermissionUsageTable = `
+		CREATE TABLE IF NOT EXISTS permission_usage (
+			permission_id UUID PRIMARY KEY REFERENCES permissions (id) ON DELETE CASCADE,
+			check_count BIGINT NOT NULL DEFAULT 0,
+			last_checked_at TIMESTAMP NOT NULL
+		)
+	`
+
+	createRolePermissionUsageTable = `
+		CREATE TABLE IF NOT EXISTS role_permission_usage (
+			role_id UUID NOT NULL REFERENCES roles (id) ON DELETE CASCADE,
+			permission_id UUID NOT NULL REFERENCES permissions (id) ON DELETE CASCADE,
+			grant_count BIGINT NOT NULL DEFAULT 0,
+			last_granted_at TIMESTAMP NOT NULL,
+			PRIMARY KEY (role_id, permission_id)
+		)
+	`
+
 	getPermissionsByService = `
 		SELECT * FROM permissions
 		WHERE service = $1
@@ -50,7 +128,365 @@ const (
 		ON CONFLICT (service, action) DO NOTHING
 	`
 
+	listPermissions = `
+		SELECT * FROM permissions
+		ORDER BY service, action
+	`
+
+	deletePermissionByServiceAndAction = `
+		DELETE FROM permissions
+		WHERE service = $1 AND action = $2
+	`
+
+	getPermissionByServiceAndAction = `
+		SELECT * FROM permissions
+		WHERE service = $1 AND action = $2
+	`
+
+	// Deprecating again updates the reason but keeps the original date
+	deprecatePermission = `
+		UPDATE permissions
+		SET deprecated_at = COALESCE(deprecated_at, $3), deprecation_reason = $4, updated_at = $3
+		WHERE service = $1 AND action = $2
+	`
+
+	restorePermission = `
+		UPDATE permissions
+		SET deprecated_at = NULL, deprecation_reason = '', updated_at = $3
+		WHERE service = $1 AND action = $2
+	`
+
+	getPermissionRoles = `
+		SELECT r.name FROM roles r
+		JOIN role_permissions rp ON rp.role_id = r.id
+		JOIN permissions p ON p.id = rp.permission_id
+		WHERE p.service = $1 AND p.action = $2
+		ORDER BY r.name
+	`
+
+	deletePermissionBindings = `
+		DELETE FROM role_permissions rp
+		USING permissions p
+		WHERE rp.permission_id = p.id AND p.service = $1 AND p.action = $2
+	`
+
+	selectPermissionAliases = `
+		SELECT a.alias, p.service || ':' || p.action AS permission, a.created_at
+		FROM permission_aliases a
+		JOIN permissions p ON p.id = a.permission_id
+	`
+
+	getPermissionAlias = selectPermissionAliases + `
+		WHERE a.alias = $1
+	`
+
+	listPermissionAliases = selectPermissionAliases + `
+		ORDER BY a.alias
+	`
+
+	getPermissionIDByName = `
+		SELECT id FROM permissions
+		WHERE service || ':' || action = $1
+	`
+
+	// $1 is the old permission, $2 the one replacing it
+	migrateRolePermissions = `
+		INSERT INTO role_permissions (role_id, permission_id)
+		SELECT role_id, $2::uuid FROM role_permissions
+		WHERE permission_id = $1
+		ON CONFLICT DO NOTHING
+	`
+
+	getRoleNamesByPermissionID = `
+		SELECT r.name FROM roles r
+		JOIN role_permissions rp ON rp.role_id = r.id
+		WHERE rp.permission_id = $1
+		ORDER BY r.name
+	`
+
+	deleteRolePermissionsByPermissionID = `
+		DELETE FROM role_permissions
+		WHERE permission_id = $1
+	`
+
+	repointPermissionAliases = `
+		UPDATE permission_aliases
+		SET permission_id = $2
+		WHERE permission_id = $1
+	`
+
+	deletePermissionByID = `
+		DELETE FROM permissions
+		WHERE id = $1
+	`
+
+	insertPermissionAlias = `
+		INSERT INTO permission_aliases (alias, permission_id, created_at)
+		VALUES ($1, $2, $3)
+	`
+
 	checkPermissionExistsByServiceAndAction = `
 		SELECT EXISTS(SELECT 1 FROM permissions WHERE service = $1 AND action = $2)
 	`
+
+	selectRoles = `
+		SELECT r.id, r.name, r.description, r.source, r.created_at, r.updated_at,
+			COALESCE(
+				array_agg(p.service || ':' || p.action ORDER BY p.service || ':' || p.action) FILTER (WHERE p.id IS NOT NULL),
+				'{}'
+			) AS permissions
+		FROM roles r
+		LEFT JOIN role_permissions rp ON rp.role_id = r.id
+		LEFT JOIN permissions p ON p.id = rp.permission_id
+	`
+
+	getRoleByName = selectRoles + `
+		WHERE r.name = $1
+		GROUP BY r.id
+	`
+
+	getAllRoles = selectRoles + `
+		GROUP BY r.id
+		ORDER BY r.name
+		LIMIT $1 OFFSET $2
+	`
+
+	listRoles = selectRoles + `
+		GROUP BY r.id
+		ORDER BY r.name
+	`
+
+	countAllRoles = `
+		SELECT COUNT(*) FROM roles
+	`
+
+	insertRole = `
+		INSERT INTO roles (name, description, source, created_at, updated_at)
+		VALUES ($1, $2, $3, $4, $5)
+		RETURNING id
+	`
+
+	updateRole = `
+		UPDATE roles
+		SET description = $2, source = $3, updated_at = $4
+		WHERE name = $1
+		RETURNING id
+	`
+
+	deleteRoleByName = `
+		DELETE FROM roles
+		WHERE name = $1
+	`
+
+	deleteRolePermissions = `
+		DELETE FROM role_permissions
+		WHERE role_id = $1
+	`
+
+	// $2 is a list of "service:action" strings
+	insertRolePermissions = `
+		INSERT INTO role_permissions (role_id, permission_id)
+		SELECT $1::uuid, p.id FROM permissions p
+		WHERE p.service || ':' || p.action = ANY($2::text[])
+	`
+
+	// The no-op update makes RETURNING yield the existing row on conflict
+	insertRoleAssignment = `
+		INSERT INTO role_assignments (subject, role_id, tenant, created_at)
+		SELECT $1, r.id, $3, $4 FROM roles r
+		WHERE r.name = $2
+		ON CONFLICT (subject, role_id, tenant) DO UPDATE SET subject = EXCLUDED.subject
+		RETURNING id, created_at
+	`
+
+	deleteRoleAssignment = `
+		DELETE FROM role_assignments ra
+		USING roles r
+		WHERE ra.role_id = r.id AND ra.subject = $1 AND r.name = $2 AND ra.tenant = $3
+	`
+
+	// Empty filter values match every row
+	filterRoleAssignments = `
+		FROM role_assignments ra
+		JOIN roles r ON r.id = ra.role_id
+		WHERE ($1::text = '' OR ra.subject = $1)
+			AND ($2::text = '' OR r.name = $2)
+			AND ($3::text = '' OR ra.tenant = $3)
+	`
+
+	getRoleAssignments = `
+		SELECT ra.id, ra.subject, r.name AS role, ra.tenant, ra.created_at
+	` + filterRoleAssignments + `
+		ORDER BY ra.subject, r.name, ra.tenant
+		LIMIT $4 OFFSET $5
+	`
+
+	listRoleAssignments = `
+		SELECT ra.id, ra.subject, r.name AS role, ra.tenant, ra.created_at
+		FROM role_assignments ra
+		JOIN roles r ON r.id = ra.role_id
+		ORDER BY ra.subject, r.name, ra.tenant
+	`
+
+	countRoleAssignments = `
+		SELECT COUNT(*)
+	` + filterRoleAssignments
+
+	getSubjectRoles = selectRoles + `
+		WHERE r.id IN (
+			SELECT role_id FROM role_assignments
+			WHERE subject = $1 AND tenant IN ('', $2)
+		)
+		GROUP BY r.id
+		ORDER BY r.name
+	`
+
+	// $1, $2 and $3 are parallel arrays of permissions, counts and timestamps
+	recordPermissionUsage = `
+		INSERT INTO permission_usage (permission_id, check_count, last_checked_at)
+		SELECT p.id, u.count, u.last_checked_at
+		FROM unnest($1::text[], $2::bigint[], $3::timestamp[]) AS u (permission, count, last_checked_at)
+		JOIN permissions p ON p.service || ':' || p.action = u.permission
+		ON CONFLICT (permission_id) DO UPDATE SET
+			check_count = permission_usage.check_count + EXCLUDED.check_count,
+			last_checked_at = GREATEST(permission_usage.last_checked_at, EXCLUDED.last_checked_at)
+	`
+
+	// $1 to $4 are parallel arrays of roles, permissions, counts and timestamps
+	recordRolePermissionUsage = `
+		INSERT INTO role_permission_usage (role_id, permission_id, grant_count, last_granted_at)
+		SELECT r.id, p.id, u.count, u.last_granted_at
+		FROM unnest($1::text[], $2::text[], $3::bigint[], $4::timestamp[]) AS u (role, permission, count, last_granted_at)
+		JOIN roles r ON r.name = u.role
+		JOIN permissions p ON p.service || ':' || p.action = u.permission
+		ON CONFLICT (role_id, permission_id) DO UPDATE SET
+			grant_count = role_permission_usage.grant_count + EXCLUDED.grant_count,
+			last_granted_at = GREATEST(role_permission_usage.last_granted_at, EXCLUDED.last_granted_at)
+	`
+
+	// Empty $1 matches every service
+	listPermissionUsage = `
+		SELECT p.service || ':' || p.action AS permission, COALESCE(u.check_count, 0) AS check_count, u.last_checked_at
+		FROM permissions p
+		LEFT JOIN permission_usage u ON u.permission_id = p.id
+		WHERE $1::text = '' OR p.service = $1
+		ORDER BY p.service, p.action
+	`
+
+	// Usage of every current role binding, including bindings that never allowed a check
+	listRolePermissionUsage = `
+		SELECT r.name AS role, p.service || ':' || p.action AS permission,
+			COALESCE(u.grant_count, 0) AS grant_count, u.last_granted_at
+		FROM role_permissions rp
+		JOIN roles r ON r.id = rp.role_id
+		JOIN permissions p ON p.id = rp.permission_id
+		LEFT JOIN role_permission_usage u ON u.role_id = rp.role_id AND u.permission_id = rp.permission_id
+		WHERE $1::text = '' OR p.service = $1
+		ORDER BY r.name
+	`
+
+	createReviewCampaignTable = `
+		CREATE TABLE IF NOT EXISTS review_campaigns (
+			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			name VARCHAR(255) NOT NULL,
+			roles TEXT[] NOT NULL DEFAULT '{}',
+			tenants TEXT[] NOT NULL DEFAULT '{}',
+			deadline TIMESTAMP NOT NULL,
+			status VARCHAR(32) NOT NULL DEFAULT 'open',
+			created_by VARCHAR(255) NOT NULL DEFAULT '',
+			created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+			closed_at TIMESTAMP
+		)
+	`
+
+	// Items keep the role name so the report survives role deletion
+	createReviewItemTable = `
+		CREATE TABLE IF NOT EXISTS review_items (
+			id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+			campaign_id UUID NOT NULL REFERENCES review_campaigns (id) ON DELETE CASCADE,
+			subject VARCHAR(255) NOT NULL,
+			role VARCHAR(255) NOT NULL,
+			tenant VARCHAR(255) NOT NULL DEFAULT '',
+			decision VARCHAR(32) NOT NULL DEFAULT 'pending',
+			reviewer VARCHAR(255) NOT NULL DEFAULT '',
+			comment TEXT NOT NULL DEFAULT '',
+			decided_at TIMESTAMP,
+			CONSTRAINT unique_review_item UNIQUE (campaign_id, subject, role, tenant)
+		)
+	`
+
+	createReviewIndex = `
+		CREATE INDEX IF NOT EXISTS idx_review_campaigns_status ON review_campaigns (status);
+		CREATE INDEX IF NOT EXISTS idx_review_items_campaign_decision ON review_items (campaign_id, decision);
+	`
+
+	insertReviewCampaign = `
+		INSERT INTO review_campaigns (name, roles, tenants, deadline, status, created_by, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		RETURNING id
+	`
+
+	// Empty role ($2) or tenant ($3) lists match every assignment
+	insertReviewItems = `
+		INSERT INTO review_items (campaign_id, subject, role, tenant)
+		SELECT $1, ra.subject, r.name, ra.tenant
+		FROM role_assignments ra
+		JOIN roles r ON r.id = ra.role_id
+		WHERE (cardinality($2::text[]) = 0 OR r.name = ANY($2))
+			AND (cardinality($3::text[]) = 0 OR ra.tenant = ANY($3))
+	`
+
+	selectReviewCampaigns = `
+		SELECT c.id, c.name, c.roles, c.tenants, c.deadline, c.status, c.created_by, c.created_at, c.closed_at,
+			COUNT(i.id) FILTER (WHERE i.decision = 'pending')::int AS pending,
+			COUNT(i.id) FILTER (WHERE i.decision = 'approved')::int AS approved,
+			COUNT(i.id) FILTER (WHERE i.decision = 'revoked')::int AS revoked,
+			COUNT(i.id) FILTER (WHERE i.decision = 'auto_revoked')::int AS auto_revoked
+		FROM review_campaigns c
+		LEFT JOIN review_items i ON i.campaign_id = c.id
+	`
+
+	getReviewCampaign = selectReviewCampaigns + `
+		WHERE c.id = $1
+		GROUP BY c.id
+	`
+
+	// Empty $1 matches every status
+	listReviewCampaigns = selectReviewCampaigns + `
+		WHERE $1::text = '' OR c.status = $1
+		GROUP BY c.id
+		ORDER BY c.created_at DESC
+	`
+
+	// Empty $2 matches every decision
+	getReviewItems = `
+		SELECT * FROM review_items
+		WHERE campaign_id = $1 AND ($2::text = '' OR decision = $2)
+		ORDER BY subject, role, tenant
+	`
+
+	getReviewItem = `
+		SELECT * FROM review_items
+		WHERE id = $1
+	`
+
+	decideReviewItem = `
+		UPDATE review_items i
+		SET decision = $2, reviewer = $3, comment = $4, decided_at = $5
+		FROM review_campaigns c
+		WHERE i.id = $1 AND i.decision = 'pending' AND c.id = i.campaign_id AND c.status = 'open'
+	`
+
+	autoRevokeReviewItems = `
+		UPDATE review_items
+		SET decision = 'auto_revoked', decided_at = $2
+		WHERE campaign_id = $1 AND decision = 'pending'
+		RETURNING *
+	`
+
+	closeReviewCampaign = `
+		UPDATE review_campaigns
+		SET status = 'closed', closed_at = $2
+		WHERE id = $1 AND status = 'open'
+	`
 )
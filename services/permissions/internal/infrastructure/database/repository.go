@@ -2,7 +2,9 @@ package database
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"intellifinder/services/permissions/internal/domain/permissions"
 	"intellifinder/services/permissions/pkg/dto"
 	"intellifinder/services/permissions/pkg/models"
 	"strings"
@@ -122,6 +124,114 @@ func (r *PermissionRepository) RegisterServicePermissions(ctx context.Context, s
 	return nil
 }
 
+func (r *PermissionRepository) ListPermissions(ctx context.Context) ([]models.Permission, error) {
+	rows, err := r.db.Query(ctx, listPermissions)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list permissions: %w", err)
+	}
+	defer rows.Close()
+
+	permissions, err := pgx.CollectRows(rows, pgx.RowToStructByName[models.Permission])
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect permission rows: %w", err)
+	}
+
+	return permissions, nil
+}
+
+// ApplyModelChanges applies an authorization model import in a single transaction
+func (r *PermissionRepository) ApplyModelChanges(ctx context.Context, changes permissions.ModelChanges) error {
+	return pgx.BeginFunc(ctx, r.db, func(tx pgx.Tx) error {
+		now := time.Now()
+		for _, permissionStr := range changes.AddPermissions {
+			service, action, err := parsePermissionString(permissionStr)
+			if err != nil {
+				return fmt.Errorf("failed to parse permission string %s: %w", permissionStr, err)
+			}
+
+			if _, err := tx.Exec(ctx, insertPermission, service, action, now, now); err != nil {
+				return fmt.Errorf("failed to insert permission %s: %w", permissionStr, err)
+			}
+		}
+
+		for _, alias := range changes.AddAliases {
+			if _, err := createPermissionAlias(ctx, tx, alias.Alias, alias.Permission); err != nil {
+				return err
+			}
+		}
+
+		for _, deprecation := range changes.DeprecatePermissions {
+			service, action, err := parsePermissionString(deprecation.Permission)
+			if err != nil {
+				return fmt.Errorf("failed to parse permission string %s: %w", deprecation.Permission, err)
+			}
+
+			if _, err := tx.Exec(ctx, deprecatePermission, service, action, now, deprecation.Reason); err != nil {
+				return fmt.Errorf("failed to deprecate permission %s: %w", deprecation.Permission, err)
+			}
+		}
+
+		for _, permissionStr := range changes.RestorePermissions {
+			service, action, err := parsePermissionString(permissionStr)
+			if err != nil {
+				return fmt.Errorf("failed to parse permission string %s: %w", permissionStr, err)
+			}
+
+			if _, err := tx.Exec(ctx, restorePermission, service, action, now); err != nil {
+				return fmt.Errorf("failed to restore permission %s: %w", permissionStr, err)
+			}
+		}
+
+		for i := range changes.CreateRoles {
+			if err := createRole(ctx, tx, &changes.CreateRoles[i]); err != nil {
+				return err
+			}
+		}
+
+		for i := range changes.UpdateRoles {
+			if err := updateRoleWithPermissions(ctx, tx, &changes.UpdateRoles[i]); err != nil {
+				return err
+			}
+		}
+
+		for _, assignment := range changes.AddAssignments {
+			err := tx.QueryRow(ctx, insertRoleAssignment, assignment.Subject, assignment.Role, assignment.Tenant, now).
+				Scan(&assignment.ID, &assignment.CreatedAt)
+			if errors.Is(err, pgx.ErrNoRows) {
+				return fmt.Errorf("failed to create role assignment: role %s does not exist", assignment.Role)
+			}
+			if err != nil {
+				return fmt.Errorf("failed to create role assignment: %w", err)
+			}
+		}
+
+		for _, assignment := range changes.RemoveAssignments {
+			if _, err := tx.Exec(ctx, deleteRoleAssignment, assignment.Subject, assignment.Role, assignment.Tenant); err != nil {
+				return fmt.Errorf("failed to delete role assignment: %w", err)
+			}
+		}
+
+		for _, name := range changes.RemoveRoles {
+			if _, err := tx.Exec(ctx, deleteRoleByName, name); err != nil {
+				return fmt.Errorf("failed to delete role %s: %w", name, err)
+			}
+		}
+
+		for _, permissionStr := range changes.RemovePermissions {
+			service, action, err := parsePermissionString(permissionStr)
+			if err != nil {
+				return fmt.Errorf("failed to parse permission string %s: %w", permissionStr, err)
+			}
+
+			if _, err := tx.Exec(ctx, deletePermissionByServiceAndAction, service, action); err != nil {
+				return fmt.Errorf("failed to delete permission %s: %w", permissionStr, err)
+			}
+		}
+
+		return nil
+	})
+}
+
 func parsePermissionString(permissionStr string) (service, action string, err error) {
 	parts := strings.Split(permissionStr, ":")
 	if len(parts) != 2 {
@@ -0,0 +1,47 @@
+package database
+
+import (
+	"context"
+	"intellifinder/services/permissions/internal/domain/permissions"
+	"intellifinder/services/permissions/internal/domain/permissions/permissionstest"
+	"os"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// TestRepositoryConformance runs against the database in TEST_DATABASE_URL.
+// All permission and role data in that database is deleted.
+func TestRepositoryConformance(t *testing.T) {
+	url := os.Getenv("TEST_DATABASE_URL")
+	if url == "" {
+		t.Skip("TEST_DATABASE_URL not set")
+	}
+
+	ctx := context.Background()
+	db, err := pgxpool.New(ctx, url)
+	if err != nil {
+		t.Fatalf("failed to create database pool: %v", err)
+	}
+	defer db.Close()
+
+	if err := CreatePermissionTable(ctx, db); err != nil {
+		t.Fatal(err)
+	}
+	if err := CreateRoleTables(ctx, db); err != nil {
+		t.Fatal(err)
+	}
+	if err := CreateUsageTables(ctx, db); err != nil {
+		t.Fatal(err)
+	}
+	if err := CreateReviewTables(ctx, db); err != nil {
+		t.Fatal(err)
+	}
+
+	permissionstest.RepositoryConformance(t, func(t *testing.T) permissions.Repository {
+		if _, err := db.Exec(ctx, "TRUNCATE review_items, review_campaigns, role_permission_usage, permission_usage, role_assignments, role_permissions, roles, permission_aliases, permissions CASCADE"); err != nil {
+			t.Fatalf("failed to truncate tables: %v", err)
+		}
+		return NewPermissionRepository(db)
+	})
+}
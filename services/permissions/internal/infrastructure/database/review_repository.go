@@ -0,0 +1,172 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"intellifinder/services/permissions/pkg/models"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+func (r *PermissionRepository) CreateReviewCampaign(ctx context.Context, campaign *models.ReviewCampaign) error {
+	if campaign.Roles == nil {
+		campaign.Roles = []string{}
+	}
+	if campaign.Tenants == nil {
+		campaign.Tenants = []string{}
+	}
+	campaign.CreatedAt = time.Now()
+
+	return pgx.BeginFunc(ctx, r.db, func(tx pgx.Tx) error {
+		err := tx.QueryRow(ctx, insertReviewCampaign,
+			campaign.Name,
+			campaign.Roles,
+			campaign.Tenants,
+			campaign.Deadline,
+			campaign.Status,
+			campaign.CreatedBy,
+			campaign.CreatedAt,
+		).Scan(&campaign.ID)
+		if err != nil {
+			return fmt.Errorf("failed to insert review campaign: %w", err)
+		}
+
+		tag, err := tx.Exec(ctx, insertReviewItems, campaign.ID, campaign.Roles, campaign.Tenants)
+		if err != nil {
+			return fmt.Errorf("failed to insert review items: %w", err)
+		}
+
+		campaign.ReviewSummary = models.ReviewSummary{Pending: int32(tag.RowsAffected())}
+		return nil
+	})
+}
+
+func (r *PermissionRepository) GetReviewCampaign(ctx context.Context, id uuid.UUID) (*models.ReviewCampaign, error) {
+	rows, err := r.db.Query(ctx, getReviewCampaign, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get review campaign: %w", err)
+	}
+	defer rows.Close()
+
+	campaign, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[models.ReviewCampaign])
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect review campaign row: %w", err)
+	}
+
+	return &campaign, nil
+}
+
+func (r *PermissionRepository) ListReviewCampaigns(ctx context.Context, status string) ([]models.ReviewCampaign, error) {
+	rows, err := r.db.Query(ctx, listReviewCampaigns, status)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list review campaigns: %w", err)
+	}
+	defer rows.Close()
+
+	campaigns, err := pgx.CollectRows(rows, pgx.RowToStructByName[models.ReviewCampaign])
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect review campaign rows: %w", err)
+	}
+
+	return campaigns, nil
+}
+
+func (r *PermissionRepository) GetReviewItems(ctx context.Context, campaignID uuid.UUID, decision string) ([]models.ReviewItem, error) {
+	rows, err := r.db.Query(ctx, getReviewItems, campaignID, decision)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get review items: %w", err)
+	}
+	defer rows.Close()
+
+	items, err := pgx.CollectRows(rows, pgx.RowToStructByName[models.ReviewItem])
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect review item rows: %w", err)
+	}
+
+	return items, nil
+}
+
+func (r *PermissionRepository) GetReviewItem(ctx context.Context, id uuid.UUID) (*models.ReviewItem, error) {
+	rows, err := r.db.Query(ctx, getReviewItem, id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get review item: %w", err)
+	}
+	defer rows.Close()
+
+	item, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[models.ReviewItem])
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect review item row: %w", err)
+	}
+
+	return &item, nil
+}
+
+func (r *PermissionRepository) DecideReviewItem(ctx context.Context, item *models.ReviewItem) (bool, error) {
+	var updated bool
+	err := pgx.BeginFunc(ctx, r.db, func(tx pgx.Tx) error {
+		tag, err := tx.Exec(ctx, decideReviewItem, item.ID, item.Decision, item.Reviewer, item.Comment, item.DecidedAt)
+		if err != nil {
+			return fmt.Errorf("failed to update review item: %w", err)
+		}
+
+		updated = tag.RowsAffected() > 0
+		if !updated || item.Decision != models.ReviewRevoked {
+			return nil
+		}
+
+		if _, err := tx.Exec(ctx, deleteRoleAssignment, item.Subject, item.Role, item.Tenant); err != nil {
+			return fmt.Errorf("failed to delete role assignment: %w", err)
+		}
+
+		return nil
+	})
+
+	return updated, err
+}
+
+func (r *PermissionRepository) CloseReviewCampaign(ctx context.Context, id uuid.UUID, closedAt time.Time) ([]models.ReviewItem, error) {
+	var revoked []models.ReviewItem
+	err := pgx.BeginFunc(ctx, r.db, func(tx pgx.Tx) error {
+		tag, err := tx.Exec(ctx, closeReviewCampaign, id, closedAt)
+		if err != nil {
+			return fmt.Errorf("failed to close review campaign: %w", err)
+		}
+
+		// Already closed by another instance
+		if tag.RowsAffected() == 0 {
+			return nil
+		}
+
+		rows, err := tx.Query(ctx, autoRevokeReviewItems, id, closedAt)
+		if err != nil {
+			return fmt.Errorf("failed to auto-revoke review items: %w", err)
+		}
+
+		revoked, err = pgx.CollectRows(rows, pgx.RowToStructByName[models.ReviewItem])
+		if err != nil {
+			return fmt.Errorf("failed to collect review item rows: %w", err)
+		}
+
+		for _, item := range revoked {
+			if _, err := tx.Exec(ctx, deleteRoleAssignment, item.Subject, item.Role, item.Tenant); err != nil {
+				return fmt.Errorf("failed to delete role assignment: %w", err)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return revoked, nil
+}
@@ -0,0 +1,167 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"intellifinder/services/permissions/pkg/dto"
+	"intellifinder/services/permissions/pkg/models"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/jackc/pgx/v5"
+)
+
+func (r *PermissionRepository) GetRoleByName(ctx context.Context, name string) (*models.Role, error) {
+	rows, err := r.db.Query(ctx, getRoleByName, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get role by name: %w", err)
+	}
+	defer rows.Close()
+
+	role, err := pgx.CollectOneRow(rows, pgx.RowToStructByName[models.Role])
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect role row: %w", err)
+	}
+
+	return &role, nil
+}
+
+func (r *PermissionRepository) GetAllRoles(ctx context.Context, page int32, limit int32) (*dto.PaginatedRoles, error) {
+	offset := (page - 1) * limit
+
+	rows, err := r.db.Query(ctx, getAllRoles, limit, offset)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get all roles: %w", err)
+	}
+	defer rows.Close()
+
+	roles, err := pgx.CollectRows(rows, pgx.RowToStructByName[models.Role])
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect role rows: %w", err)
+	}
+
+	var totalCount int32
+	err = r.db.QueryRow(ctx, countAllRoles).Scan(&totalCount)
+	if err != nil {
+		return nil, fmt.Errorf("failed to count all roles: %w", err)
+	}
+
+	return dto.NewPaginatedRoles(roles, page, limit, totalCount), nil
+}
+
+func (r *PermissionRepository) ListRoles(ctx context.Context) ([]models.Role, error) {
+	rows, err := r.db.Query(ctx, listRoles)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list roles: %w", err)
+	}
+	defer rows.Close()
+
+	roles, err := pgx.CollectRows(rows, pgx.RowToStructByName[models.Role])
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect role rows: %w", err)
+	}
+
+	return roles, nil
+}
+
+func (r *PermissionRepository) CreateRole(ctx context.Context, role *models.Role) error {
+	return pgx.BeginFunc(ctx, r.db, func(tx pgx.Tx) error {
+		return createRole(ctx, tx, role)
+	})
+}
+
+func (r *PermissionRepository) UpdateRole(ctx context.Context, role *models.Role) error {
+	return pgx.BeginFunc(ctx, r.db, func(tx pgx.Tx) error {
+		return updateRoleWithPermissions(ctx, tx, role)
+	})
+}
+
+func (r *PermissionRepository) DeleteRole(ctx context.Context, name string) error {
+	_, err := r.db.Exec(ctx, deleteRoleByName, name)
+	if err != nil {
+		return fmt.Errorf("failed to delete role: %w", err)
+	}
+	return nil
+}
+
+// ApplyRoleChanges creates, updates and deletes roles in a single transaction
+func (r *PermissionRepository) ApplyRoleChanges(ctx context.Context, create []models.Role, update []models.Role, remove []string) error {
+	return pgx.BeginFunc(ctx, r.db, func(tx pgx.Tx) error {
+		for i := range create {
+			if err := createRole(ctx, tx, &create[i]); err != nil {
+				return err
+			}
+		}
+
+		for i := range update {
+			if err := updateRoleWithPermissions(ctx, tx, &update[i]); err != nil {
+				return err
+			}
+		}
+
+		for _, name := range remove {
+			if _, err := tx.Exec(ctx, deleteRoleByName, name); err != nil {
+				return fmt.Errorf("failed to delete role %s: %w", name, err)
+			}
+		}
+
+		return nil
+	})
+}
+
+func createRole(ctx context.Context, tx pgx.Tx, role *models.Role) error {
+	now := time.Now()
+
+	var id uuid.UUID
+	err := tx.QueryRow(ctx, insertRole, role.Name, role.Description, role.Source, now, now).Scan(&id)
+	if err != nil {
+		return fmt.Errorf("failed to create role %s: %w", role.Name, err)
+	}
+
+	role.ID = id
+	role.CreatedAt = now
+	role.UpdatedAt = now
+
+	return insertPermissionsForRole(ctx, tx, role)
+}
+
+func updateRoleWithPermissions(ctx context.Context, tx pgx.Tx, role *models.Role) error {
+	now := time.Now()
+
+	var id uuid.UUID
+	err := tx.QueryRow(ctx, updateRole, role.Name, role.Description, role.Source, now).Scan(&id)
+	if err != nil {
+		return fmt.Errorf("failed to update role %s: %w", role.Name, err)
+	}
+
+	role.ID = id
+	role.UpdatedAt = now
+
+	if _, err := tx.Exec(ctx, deleteRolePermissions, id); err != nil {
+		return fmt.Errorf("failed to clear permissions of role %s: %w", role.Name, err)
+	}
+
+	return insertPermissionsForRole(ctx, tx, role)
+}
+
+func insertPermissionsForRole(ctx context.Context, tx pgx.Tx, role *models.Role) error {
+	if len(role.Permissions) == 0 {
+		return nil
+	}
+
+	tag, err := tx.Exec(ctx, insertRolePermissions, role.ID, role.Permissions)
+	if err != nil {
+		return fmt.Errorf("failed to bind permissions to role %s: %w", role.Name, err)
+	}
+
+	// Every permission must resolve to a catalogue entry
+	if tag.RowsAffected() != int64(len(role.Permissions)) {
+		return fmt.Errorf("role %s references unregistered permissions", role.Name)
+	}
+
+	return nil
+}
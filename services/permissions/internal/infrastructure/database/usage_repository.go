@@ -0,0 +1,90 @@
+package database
+
+import (
+	"context"
+	"fmt"
+	"intellifinder/services/permissions/pkg/models"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// RecordUsage upserts a whole batch with two statements in one transaction
+func (r *PermissionRepository) RecordUsage(ctx context.Context, permissions []models.PermissionUsage, roles []models.RoleUsage) error {
+	names := make([]string, len(permissions))
+	counts := make([]int64, len(permissions))
+	checkedAt := make([]time.Time, len(permissions))
+	for i, usage := range permissions {
+		names[i] = usage.Permission
+		counts[i] = usage.CheckCount
+		checkedAt[i] = usageTime(usage.LastCheckedAt)
+	}
+
+	roleNames := make([]string, len(roles))
+	rolePermissions := make([]string, len(roles))
+	grantCounts := make([]int64, len(roles))
+	grantedAt := make([]time.Time, len(roles))
+	for i, usage := range roles {
+		roleNames[i] = usage.Role
+		rolePermissions[i] = usage.Permission
+		grantCounts[i] = usage.GrantCount
+		grantedAt[i] = usageTime(usage.LastGrantedAt)
+	}
+
+	return pgx.BeginFunc(ctx, r.db, func(tx pgx.Tx) error {
+		if _, err := tx.Exec(ctx, recordPermissionUsage, names, counts, checkedAt); err != nil {
+			return fmt.Errorf("failed to record permission usage: %w", err)
+		}
+
+		if _, err := tx.Exec(ctx, recordRolePermissionUsage, roleNames, rolePermissions, grantCounts, grantedAt); err != nil {
+			return fmt.Errorf("failed to record role usage: %w", err)
+		}
+
+		return nil
+	})
+}
+
+func (r *PermissionRepository) ListPermissionUsage(ctx context.Context, service string) ([]models.PermissionUsage, error) {
+	rows, err := r.db.Query(ctx, listPermissionUsage, service)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list permission usage: %w", err)
+	}
+	defer rows.Close()
+
+	usage, err := pgx.CollectRows(rows, pgx.RowToStructByName[models.PermissionUsage])
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect permission usage rows: %w", err)
+	}
+
+	rows, err = r.db.Query(ctx, listRolePermissionUsage, service)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list role usage: %w", err)
+	}
+	defer rows.Close()
+
+	roleUsage, err := pgx.CollectRows(rows, pgx.RowToStructByName[models.RoleUsage])
+	if err != nil {
+		return nil, fmt.Errorf("failed to collect role usage rows: %w", err)
+	}
+
+	byPermission := make(map[string][]models.RoleUsage, len(usage))
+	for _, role := range roleUsage {
+		byPermission[role.Permission] = append(byPermission[role.Permission], role)
+	}
+
+	for i := range usage {
+		usage[i].Roles = byPermission[usage[i].Permission]
+		if usage[i].Roles == nil {
+			usage[i].Roles = []models.RoleUsage{}
+		}
+	}
+
+	return usage, nil
+}
+
+func usageTime(at *time.Time) time.Time {
+	if at == nil {
+		return time.Now()
+	}
+	return *at
+}
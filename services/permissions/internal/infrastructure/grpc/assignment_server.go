@@ -0,0 +1,121 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	permissionsv1 "intellifinder/services/permissions/api/v1"
+	"intellifinder/services/permissions/internal/domain/permissions"
+	"intellifinder/services/permissions/pkg/dto"
+	"intellifinder/services/permissions/pkg/models"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func (s *PermissionServer) AssignRole(ctx context.Context, req *permissionsv1.AssignRoleRequest) (*permissionsv1.AssignRoleResponse, error) {
+	if err := s.requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+
+	assignment, err := s.service.AssignRole(ctx, req.Subject, req.Role, req.Tenant)
+	if err != nil {
+		return nil, roleError(err)
+	}
+
+	return &permissionsv1.AssignRoleResponse{
+		Assignment: toProtoAssignment(assignment),
+	}, nil
+}
+
+func (s *PermissionServer) UnassignRole(ctx context.Context, req *permissionsv1.UnassignRoleRequest) (*permissionsv1.UnassignRoleResponse, error) {
+	if err := s.requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+
+	err := s.service.UnassignRole(ctx, req.Subject, req.Role, req.Tenant)
+	if errors.Is(err, permissions.ErrAssignmentNotFound) {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return &permissionsv1.UnassignRoleResponse{
+		Success: true,
+	}, nil
+}
+
+func (s *PermissionServer) GetAssignments(ctx context.Context, req *permissionsv1.GetAssignmentsRequest) (*permissionsv1.GetAssignmentsResponse, error) {
+	filter := dto.AssignmentFilter{
+		Subject: req.Subject,
+		Role:    req.Role,
+		Tenant:  req.Tenant,
+	}
+
+	result, err := s.service.GetAssignments(ctx, filter, req.Page, req.Limit)
+	if err != nil {
+		return nil, err
+	}
+
+	assignments := make([]*permissionsv1.RoleAssignment, len(result.Assignments))
+	for i := range result.Assignments {
+		assignments[i] = toProtoAssignment(&result.Assignments[i])
+	}
+
+	return &permissionsv1.GetAssignmentsResponse{
+		Assignments: assignments,
+		Page:        result.Page,
+		Limit:       result.Limit,
+		TotalCount:  result.TotalCount,
+		LastPage:    result.LastPage,
+	}, nil
+}
+
+func (s *PermissionServer) Authorize(ctx context.Context, req *permissionsv1.AuthorizeRequest) (*permissionsv1.AuthorizeResponse, error) {
+	decision, err := s.service.Authorize(ctx, req.Subject, req.Permission, req.Tenant)
+	if err != nil {
+		return nil, err
+	}
+	warnLegacyPermission(ctx, decision.Permission, decision.CanonicalPermission, decision.Deprecated)
+
+	return &permissionsv1.AuthorizeResponse{
+		Allowed:      decision.Allowed,
+		MatchedRoles: decision.MatchedRoles,
+		Deprecated:   decision.Deprecated,
+	}, nil
+}
+
+func (s *PermissionServer) ExplainAuthorization(ctx context.Context, req *permissionsv1.AuthorizeRequest) (*permissionsv1.ExplainAuthorizationResponse, error) {
+	decision, err := s.service.ExplainAuthorization(ctx, req.Subject, req.Permission, req.Tenant)
+	if err != nil {
+		return nil, err
+	}
+	warnLegacyPermission(ctx, decision.Permission, decision.CanonicalPermission, decision.Deprecated)
+
+	evaluated := make([]*permissionsv1.RoleEvaluation, len(decision.Evaluated))
+	for i, evaluation := range decision.Evaluated {
+		evaluated[i] = &permissionsv1.RoleEvaluation{
+			Role:    evaluation.Role,
+			Granted: evaluation.Granted,
+		}
+	}
+
+	return &permissionsv1.ExplainAuthorizationResponse{
+		Allowed:              decision.Allowed,
+		MatchedRoles:         decision.MatchedRoles,
+		PermissionRegistered: decision.PermissionRegistered,
+		Evaluated:            evaluated,
+		CanonicalPermission:  decision.CanonicalPermission,
+		Deprecated:           decision.Deprecated,
+	}, nil
+}
+
+func toProtoAssignment(assignment *models.RoleAssignment) *permissionsv1.RoleAssignment {
+	return &permissionsv1.RoleAssignment{
+		Subject:   assignment.Subject,
+		Role:      assignment.Role,
+		Tenant:    assignment.Tenant,
+		CreatedAt: timestamppb.New(assignment.CreatedAt),
+	}
+}
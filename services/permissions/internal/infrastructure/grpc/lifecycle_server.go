@@ -0,0 +1,121 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	permissionsv1 "intellifinder/services/permissions/api/v1"
+	"intellifinder/services/permissions/internal/domain/permissions"
+	"intellifinder/services/permissions/pkg/models"
+
+	"github.com/intellifinder/v4/libs/observability"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func (s *PermissionServer) DeprecatePermission(ctx context.Context, req *permissionsv1.DeprecatePermissionRequest) (*permissionsv1.DeprecatePermissionResponse, error) {
+	if err := s.requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+
+	if err := s.service.DeprecatePermission(ctx, req.Permission, req.Reason); err != nil {
+		return nil, permissionError(err)
+	}
+
+	return &permissionsv1.DeprecatePermissionResponse{
+		Success: true,
+	}, nil
+}
+
+func (s *PermissionServer) DeletePermission(ctx context.Context, req *permissionsv1.DeletePermissionRequest) (*permissionsv1.DeletePermissionResponse, error) {
+	if err := s.requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+
+	deletion, err := s.service.DeletePermission(ctx, req.Permission, req.Force)
+	if err != nil {
+		return nil, permissionError(err)
+	}
+
+	if len(deletion.RemovedFromRoles) > 0 {
+		observability.Logger(ctx).Warn("permission force-deleted from roles",
+			zap.String("permission", deletion.Permission),
+			zap.Strings("roles", deletion.RemovedFromRoles),
+		)
+	}
+
+	return &permissionsv1.DeletePermissionResponse{
+		RemovedFromRoles: deletion.RemovedFromRoles,
+	}, nil
+}
+
+func (s *PermissionServer) CreatePermissionAlias(ctx context.Context, req *permissionsv1.CreatePermissionAliasRequest) (*permissionsv1.CreatePermissionAliasResponse, error) {
+	if err := s.requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+
+	result, err := s.service.CreatePermissionAlias(ctx, req.Alias, req.Permission)
+	if err != nil {
+		return nil, permissionError(err)
+	}
+
+	return &permissionsv1.CreatePermissionAliasResponse{
+		Alias:         toProtoAlias(&result.Alias),
+		MigratedRoles: result.MigratedRoles,
+	}, nil
+}
+
+func (s *PermissionServer) GetPermissionAliases(ctx context.Context, req *permissionsv1.GetPermissionAliasesRequest) (*permissionsv1.GetPermissionAliasesResponse, error) {
+	aliases, err := s.service.GetPermissionAliases(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*permissionsv1.PermissionAlias, len(aliases))
+	for i := range aliases {
+		result[i] = toProtoAlias(&aliases[i])
+	}
+
+	return &permissionsv1.GetPermissionAliasesResponse{
+		Aliases: result,
+	}, nil
+}
+
+// warnLegacyPermission logs checks of deprecated permissions and of aliases so
+// the services still using them can be found and migrated
+func warnLegacyPermission(ctx context.Context, requested string, canonical string, deprecated bool) {
+	if requested != canonical {
+		observability.Logger(ctx).Warn("permission alias used",
+			zap.String("permission", requested),
+			zap.String("resolved_to", canonical),
+		)
+	}
+
+	if deprecated {
+		observability.Logger(ctx).Warn("deprecated permission used",
+			zap.String("permission", canonical),
+		)
+	}
+}
+
+func toProtoAlias(alias *models.PermissionAlias) *permissionsv1.PermissionAlias {
+	return &permissionsv1.PermissionAlias{
+		Alias:      alias.Alias,
+		Permission: alias.Permission,
+		CreatedAt:  timestamppb.New(alias.CreatedAt),
+	}
+}
+
+func permissionError(err error) error {
+	switch {
+	case errors.Is(err, permissions.ErrPermissionNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, permissions.ErrPermissionInUse):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, permissions.ErrInvalidAlias):
+		return status.Error(codes.InvalidArgument, err.Error())
+	default:
+		return err
+	}
+}
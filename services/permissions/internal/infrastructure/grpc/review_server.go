@@ -0,0 +1,242 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	permissionsv1 "intellifinder/services/permissions/api/v1"
+	"intellifinder/services/permissions/internal/domain/permissions"
+	"intellifinder/services/permissions/pkg/models"
+
+	"github.com/google/uuid"
+	serviceauth "github.com/intellifinder/v4/libs/auth"
+	"github.com/intellifinder/v4/libs/observability"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func (s *PermissionServer) CreateReviewCampaign(ctx context.Context, req *permissionsv1.CreateReviewCampaignRequest) (*permissionsv1.CreateReviewCampaignResponse, error) {
+	if err := s.requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+
+	if req.Deadline == nil {
+		return nil, status.Error(codes.InvalidArgument, "deadline is required")
+	}
+
+	createdBy, err := s.callerIdentity(ctx, "created_by", req.CreatedBy, false)
+	if err != nil {
+		return nil, err
+	}
+
+	campaign := &models.ReviewCampaign{
+		Name:      req.Name,
+		Roles:     req.Roles,
+		Tenants:   req.Tenants,
+		Deadline:  req.Deadline.AsTime(),
+		CreatedBy: createdBy,
+	}
+	if err := s.service.CreateReviewCampaign(ctx, campaign); err != nil {
+		return nil, reviewError(err)
+	}
+
+	observability.Logger(ctx).Info("access review campaign created",
+		zap.String("campaign_id", campaign.ID.String()),
+		zap.String("name", campaign.Name),
+		zap.Int32("items", campaign.Pending),
+	)
+
+	return &permissionsv1.CreateReviewCampaignResponse{
+		Campaign: toProtoCampaign(campaign),
+	}, nil
+}
+
+func (s *PermissionServer) GetReviewCampaigns(ctx context.Context, req *permissionsv1.GetReviewCampaignsRequest) (*permissionsv1.GetReviewCampaignsResponse, error) {
+	campaigns, err := s.service.GetReviewCampaigns(ctx, req.Status)
+	if err != nil {
+		return nil, reviewError(err)
+	}
+
+	result := make([]*permissionsv1.ReviewCampaign, len(campaigns))
+	for i := range campaigns {
+		result[i] = toProtoCampaign(&campaigns[i])
+	}
+
+	return &permissionsv1.GetReviewCampaignsResponse{
+		Campaigns: result,
+	}, nil
+}
+
+func (s *PermissionServer) GetReviewItems(ctx context.Context, req *permissionsv1.GetReviewItemsRequest) (*permissionsv1.GetReviewItemsResponse, error) {
+	campaignID, err := parseID("campaign_id", req.CampaignId)
+	if err != nil {
+		return nil, err
+	}
+
+	items, err := s.service.GetReviewItems(ctx, campaignID, req.Decision)
+	if err != nil {
+		return nil, reviewError(err)
+	}
+
+	result := make([]*permissionsv1.ReviewItem, len(items))
+	for i := range items {
+		result[i] = toProtoReviewItem(&items[i])
+	}
+
+	return &permissionsv1.GetReviewItemsResponse{
+		Items: result,
+	}, nil
+}
+
+func (s *PermissionServer) ApproveReviewItem(ctx context.Context, req *permissionsv1.DecideReviewItemRequest) (*permissionsv1.DecideReviewItemResponse, error) {
+	return s.decideReviewItem(ctx, req, s.service.ApproveReviewItem)
+}
+
+func (s *PermissionServer) RevokeReviewItem(ctx context.Context, req *permissionsv1.DecideReviewItemRequest) (*permissionsv1.DecideReviewItemResponse, error) {
+	return s.decideReviewItem(ctx, req, s.service.RevokeReviewItem)
+}
+
+type reviewDecision func(ctx context.Context, id uuid.UUID, reviewer string, comment string) (*models.ReviewItem, error)
+
+func (s *PermissionServer) decideReviewItem(ctx context.Context, req *permissionsv1.DecideReviewItemRequest, decide reviewDecision) (*permissionsv1.DecideReviewItemResponse, error) {
+	itemID, err := parseID("item_id", req.ItemId)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only a person can attest that an assignment is still needed
+	reviewer, err := s.callerIdentity(ctx, "reviewer", req.Reviewer, true)
+	if err != nil {
+		return nil, err
+	}
+
+	item, err := decide(ctx, itemID, reviewer, req.Comment)
+	if err != nil {
+		return nil, reviewError(err)
+	}
+
+	observability.Logger(ctx).Info("access review decision recorded",
+		zap.String("campaign_id", item.CampaignID.String()),
+		zap.String("subject", item.Subject),
+		zap.String("role", item.Role),
+		zap.String("tenant", item.Tenant),
+		zap.String("decision", item.Decision),
+		zap.String("reviewer", item.Reviewer),
+	)
+
+	return &permissionsv1.DecideReviewItemResponse{
+		Item: toProtoReviewItem(item),
+	}, nil
+}
+
+func (s *PermissionServer) ExportReviewReport(ctx context.Context, req *permissionsv1.ExportReviewReportRequest) (*permissionsv1.ExportReviewReportResponse, error) {
+	campaignID, err := parseID("campaign_id", req.CampaignId)
+	if err != nil {
+		return nil, err
+	}
+
+	report, err := s.service.ExportReviewReport(ctx, campaignID, req.Format)
+	if err != nil {
+		return nil, reviewError(err)
+	}
+
+	return &permissionsv1.ExportReviewReportResponse{
+		Document:  report.Document,
+		Format:    report.Format,
+		Algorithm: report.Algorithm,
+		Signature: report.Signature,
+		PublicKey: report.PublicKey,
+	}, nil
+}
+
+// callerIdentity returns who is making a call: the subject of the user token
+// sent with it, or, unless userRequired, the calling service for calls
+// without one. An identity claimed in the request must match it.
+func (s *PermissionServer) callerIdentity(ctx context.Context, field string, claimed string, userRequired bool) (string, error) {
+	if s.users == nil && userRequired {
+		return "", status.Error(codes.FailedPrecondition, "user tokens can't be verified: no user token audience configured")
+	}
+
+	var identity string
+	if s.users != nil {
+		user, ok, err := serviceauth.VerifiedUser(ctx, s.users)
+		if err != nil {
+			return "", err
+		}
+		if ok {
+			identity = user
+		}
+	}
+
+	if identity == "" {
+		if userRequired {
+			return "", status.Errorf(codes.Unauthenticated, "%s must be a user; send their access token in %s", field, serviceauth.UserTokenHeader)
+		}
+		service, ok := serviceauth.ServiceFromContext(ctx)
+		if !ok {
+			return "", status.Error(codes.Unauthenticated, "caller identity is required")
+		}
+		identity = "service:" + service
+	}
+
+	if claimed != "" && claimed != identity {
+		return "", status.Errorf(codes.PermissionDenied, "%s %q does not match the authenticated caller %q", field, claimed, identity)
+	}
+	return identity, nil
+}
+
+func parseID(field string, value string) (uuid.UUID, error) {
+	id, err := uuid.Parse(value)
+	if err != nil {
+		return uuid.Nil, status.Errorf(codes.InvalidArgument, "invalid %s: %v", field, err)
+	}
+	return id, nil
+}
+
+func toProtoCampaign(campaign *models.ReviewCampaign) *permissionsv1.ReviewCampaign {
+	return &permissionsv1.ReviewCampaign{
+		Id:          campaign.ID.String(),
+		Name:        campaign.Name,
+		Roles:       campaign.Roles,
+		Tenants:     campaign.Tenants,
+		Deadline:    timestamppb.New(campaign.Deadline),
+		Status:      campaign.Status,
+		CreatedBy:   campaign.CreatedBy,
+		CreatedAt:   timestamppb.New(campaign.CreatedAt),
+		ClosedAt:    optionalTimestamp(campaign.ClosedAt),
+		Pending:     campaign.Pending,
+		Approved:    campaign.Approved,
+		Revoked:     campaign.Revoked,
+		AutoRevoked: campaign.AutoRevoked,
+	}
+}
+
+func toProtoReviewItem(item *models.ReviewItem) *permissionsv1.ReviewItem {
+	return &permissionsv1.ReviewItem{
+		Id:         item.ID.String(),
+		CampaignId: item.CampaignID.String(),
+		Subject:    item.Subject,
+		Role:       item.Role,
+		Tenant:     item.Tenant,
+		Decision:   item.Decision,
+		Reviewer:   item.Reviewer,
+		Comment:    item.Comment,
+		DecidedAt:  optionalTimestamp(item.DecidedAt),
+	}
+}
+
+func reviewError(err error) error {
+	switch {
+	case errors.Is(err, permissions.ErrCampaignNotFound), errors.Is(err, permissions.ErrReviewItemNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, permissions.ErrCampaignClosed), errors.Is(err, permissions.ErrReviewItemDecided), errors.Is(err, permissions.ErrReportSigningKey):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, permissions.ErrInvalidCampaign):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, permissions.ErrSelfReview):
+		return status.Error(codes.PermissionDenied, err.Error())
+	default:
+		return err
+	}
+}
@@ -0,0 +1,184 @@
+package grpc
+
+import (
+	"context"
+	"errors"
+	permissionsv1 "intellifinder/services/permissions/api/v1"
+	"intellifinder/services/permissions/internal/domain/permissions"
+	"intellifinder/services/permissions/pkg/dto"
+	"intellifinder/services/permissions/pkg/models"
+
+	serviceauth "github.com/intellifinder/v4/libs/auth"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func (s *PermissionServer) GetRole(ctx context.Context, req *permissionsv1.GetRoleRequest) (*permissionsv1.GetRoleResponse, error) {
+	role, err := s.service.GetRole(ctx, req.Name)
+	if err != nil {
+		return nil, roleError(err)
+	}
+
+	return &permissionsv1.GetRoleResponse{
+		Role: toProtoRole(role),
+	}, nil
+}
+
+func (s *PermissionServer) GetAllRoles(ctx context.Context, req *permissionsv1.GetAllRolesRequest) (*permissionsv1.GetAllRolesResponse, error) {
+	result, err := s.service.GetAllRoles(ctx, req.Page, req.Limit)
+	if err != nil {
+		return nil, err
+	}
+
+	roles := make([]*permissionsv1.Role, len(result.Roles))
+	for i := range result.Roles {
+		roles[i] = toProtoRole(&result.Roles[i])
+	}
+
+	return &permissionsv1.GetAllRolesResponse{
+		Roles:      roles,
+		Page:       result.Page,
+		Limit:      result.Limit,
+		TotalCount: result.TotalCount,
+		LastPage:   result.LastPage,
+	}, nil
+}
+
+func (s *PermissionServer) CreateRole(ctx context.Context, req *permissionsv1.CreateRoleRequest) (*permissionsv1.CreateRoleResponse, error) {
+	if err := s.requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+
+	role := &models.Role{
+		Name:        req.Name,
+		Description: req.Description,
+		Permissions: req.Permissions,
+	}
+
+	if err := s.service.CreateRole(ctx, role); err != nil {
+		return nil, roleError(err)
+	}
+
+	return &permissionsv1.CreateRoleResponse{
+		Role: toProtoRole(role),
+	}, nil
+}
+
+func (s *PermissionServer) UpdateRole(ctx context.Context, req *permissionsv1.UpdateRoleRequest) (*permissionsv1.UpdateRoleResponse, error) {
+	if err := s.requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+
+	role := &models.Role{
+		Name:        req.Name,
+		Description: req.Description,
+		Permissions: req.Permissions,
+	}
+
+	if err := s.service.UpdateRole(ctx, role); err != nil {
+		return nil, roleError(err)
+	}
+
+	return &permissionsv1.UpdateRoleResponse{
+		Role: toProtoRole(role),
+	}, nil
+}
+
+func (s *PermissionServer) DeleteRole(ctx context.Context, req *permissionsv1.DeleteRoleRequest) (*permissionsv1.DeleteRoleResponse, error) {
+	if err := s.requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+
+	if err := s.service.DeleteRole(ctx, req.Name); err != nil {
+		return nil, roleError(err)
+	}
+
+	return &permissionsv1.DeleteRoleResponse{
+		Success: true,
+	}, nil
+}
+
+// SyncRoles reconciles the uploaded role files, or the roles directory when
+// none are given. Uploaded files are recorded under the calling service's
+// name, so a caller can only replace role files it uploaded itself.
+func (s *PermissionServer) SyncRoles(ctx context.Context, req *permissionsv1.SyncRolesRequest) (*permissionsv1.SyncRolesResponse, error) {
+	var result *dto.RoleSyncResult
+	var err error
+
+	if len(req.Files) == 0 {
+		if s.rolesDir == "" {
+			return nil, status.Error(codes.FailedPrecondition, "no role files given and no roles directory configured")
+		}
+
+		result, err = s.service.SyncRoleDir(ctx, s.rolesDir, req.DryRun)
+		if err != nil {
+			return nil, roleError(err)
+		}
+	} else {
+		caller, ok := serviceauth.ServiceFromContext(ctx)
+		if !ok {
+			return nil, status.Error(codes.Unauthenticated, "call was not made by an authenticated service")
+		}
+
+		var sources []string
+		var roles []models.Role
+		for _, file := range req.Files {
+			source, err := permissions.UploadedRoleSource(caller, file.Path)
+			if err != nil {
+				return nil, status.Error(codes.InvalidArgument, err.Error())
+			}
+
+			fileRoles, err := permissions.ParseRoleFile(source, file.Content)
+			if err != nil {
+				return nil, status.Error(codes.InvalidArgument, err.Error())
+			}
+			sources = append(sources, source)
+			roles = append(roles, fileRoles...)
+		}
+
+		result, err = s.service.SyncRoles(ctx, sources, roles, req.DryRun)
+		if err != nil {
+			return nil, roleError(err)
+		}
+	}
+
+	return &permissionsv1.SyncRolesResponse{
+		Created:   result.Created,
+		Updated:   result.Updated,
+		Deleted:   result.Deleted,
+		Unchanged: result.Unchanged,
+		Applied:   result.Applied,
+	}, nil
+}
+
+// SyncRoleDir reconciles roles with the configured roles directory
+func (s *PermissionServer) SyncRoleDir(ctx context.Context) error {
+	_, err := s.service.SyncRoleDir(ctx, s.rolesDir, false)
+	return err
+}
+
+func toProtoRole(role *models.Role) *permissionsv1.Role {
+	return &permissionsv1.Role{
+		Name:        role.Name,
+		Description: role.Description,
+		Permissions: role.Permissions,
+		ReadOnly:    role.ReadOnly(),
+		Source:      role.Source,
+	}
+}
+
+// roleError maps domain errors to gRPC status codes
+func roleError(err error) error {
+	switch {
+	case errors.Is(err, permissions.ErrRoleNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, permissions.ErrRoleExists):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, permissions.ErrRoleReadOnly):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, permissions.ErrInvalidRole):
+		return status.Error(codes.InvalidArgument, err.Error())
+	default:
+		return err
+	}
+}
@@ -2,23 +2,59 @@ package grpc
 
 import (
 	"context"
+	"errors"
 	permissionsv1 "intellifinder/services/permissions/api/v1"
 	"intellifinder/services/permissions/internal/domain/permissions"
+	"intellifinder/services/permissions/pkg/models"
 	"strings"
+
+	serviceauth "github.com/intellifinder/v4/libs/auth"
+	"github.com/intellifinder/v4/libs/observability"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
 )
 
 type PermissionServer struct {
 	permissionsv1.UnimplementedPermissionServiceServer
-	service *permissions.Service
+	service  *permissions.Service
+	rolesDir string
+	admins   []string
+	users    serviceauth.UserTokenVerifier
 }
 
-func NewPermissionServer(service *permissions.Service) *PermissionServer {
+// NewPermissionServer creates the gRPC server. rolesDir is the directory
+// SyncRoles re-reads when called without files; it may be empty.
+func NewPermissionServer(service *permissions.Service, rolesDir string) *PermissionServer {
 	return &PermissionServer{
-		service: service,
+		service:  service,
+		rolesDir: rolesDir,
 	}
 }
 
+// SetAdminServices names the services that may change permissions, roles,
+// assignments and access reviews. Until it is called, none may.
+func (s *PermissionServer) SetAdminServices(names ...string) {
+	s.admins = names
+}
+
+// SetUserVerifier sets the verifier of the user tokens sent with calls made
+// for a user. Without one, review items can't be decided.
+func (s *PermissionServer) SetUserVerifier(users serviceauth.UserTokenVerifier) {
+	s.users = users
+}
+
+// requireAdmin rejects calls that weren't made by an admin service
+func (s *PermissionServer) requireAdmin(ctx context.Context) error {
+	return serviceauth.RequireService(ctx, s.admins...)
+}
+
+// RegisterService only lets a service register its own permissions
 func (s *PermissionServer) RegisterService(ctx context.Context, req *permissionsv1.RegisterServiceRequest) (*permissionsv1.RegisterServiceResponse, error) {
+	if err := serviceauth.RequireService(ctx, req.ServiceName); err != nil {
+		return nil, err
+	}
+
 	err := s.service.RegisterServicePermissions(ctx, req.ServiceName, req.Permissions)
 	if err != nil {
 		return &permissionsv1.RegisterServiceResponse{
@@ -27,6 +63,13 @@ func (s *PermissionServer) RegisterService(ctx context.Context, req *permissions
 		}, nil
 	}
 
+	// Role files referencing the new permissions can be applied now
+	if s.rolesDir != "" {
+		if err := s.SyncRoleDir(ctx); err != nil {
+			observability.Logger(ctx).Warn("failed to sync role files", zap.Error(err))
+		}
+	}
+
 	return &permissionsv1.RegisterServiceResponse{
 		Success: true,
 		Message: "Service permissions registered successfully",
@@ -42,14 +85,17 @@ func (s *PermissionServer) CheckPermission(ctx context.Context, req *permissions
 		}, nil
 	}
 
-	service, action := parts[0], parts[1]
-	exists, err := s.service.PermissionExistsByServiceAndAction(ctx, service, action)
+	permission, err := s.service.ResolvePermission(ctx, req.Permission)
 	if err != nil {
 		return nil, err
 	}
 
+	if permission != nil {
+		warnLegacyPermission(ctx, req.Permission, permission.Name(), permission.Deprecated())
+	}
+
 	return &permissionsv1.CheckPermissionResponse{
-		Exists: exists,
+		Exists: permission != nil,
 	}, nil
 }
 
@@ -61,8 +107,12 @@ func (s *PermissionServer) GetServicePermissions(ctx context.Context, req *permi
 
 	// Convert models.Permission to strings
 	permissionStrings := make([]string, len(result.Permissions))
+	deprecated := []string{}
 	for i, perm := range result.Permissions {
 		permissionStrings[i] = perm.Service + ":" + perm.Action
+		if perm.Deprecated() {
+			deprecated = append(deprecated, permissionStrings[i])
+		}
 	}
 
 	return &permissionsv1.GetServicePermissionsResponse{
@@ -71,6 +121,7 @@ func (s *PermissionServer) GetServicePermissions(ctx context.Context, req *permi
 		Limit:       result.Limit,
 		TotalCount:  result.TotalCount,
 		LastPage:    result.LastPage,
+		Deprecated:  deprecated,
 	}, nil
 }
 
@@ -82,8 +133,12 @@ func (s *PermissionServer) GetAllPermissions(ctx context.Context, req *permissio
 
 	// Convert models.Permission to strings
 	permissionStrings := make([]string, len(result.Permissions))
+	deprecated := []string{}
 	for i, perm := range result.Permissions {
 		permissionStrings[i] = perm.Service + ":" + perm.Action
+		if perm.Deprecated() {
+			deprecated = append(deprecated, permissionStrings[i])
+		}
 	}
 
 	return &permissionsv1.GetAllPermissionsResponse{
@@ -92,5 +147,98 @@ func (s *PermissionServer) GetAllPermissions(ctx context.Context, req *permissio
 		Limit:       result.Limit,
 		TotalCount:  result.TotalCount,
 		LastPage:    result.LastPage,
+		Deprecated:  deprecated,
 	}, nil
 }
+
+func (s *PermissionServer) ExportModel(ctx context.Context, req *permissionsv1.ExportModelRequest) (*permissionsv1.ExportModelResponse, error) {
+	model, err := s.service.ExportModel(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	format := req.Format
+	if format == "" {
+		format = permissions.FormatYAML
+	}
+
+	document, err := permissions.EncodeModel(model, format)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	return &permissionsv1.ExportModelResponse{
+		Document: document,
+		Format:   format,
+	}, nil
+}
+
+func (s *PermissionServer) ImportModel(ctx context.Context, req *permissionsv1.ImportModelRequest) (*permissionsv1.ImportModelResponse, error) {
+	if err := s.requireAdmin(ctx); err != nil {
+		return nil, err
+	}
+
+	model, err := permissions.DecodeModel(req.Document)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	diff, err := s.service.ImportModel(ctx, model, permissions.ImportOptions{
+		DryRun: req.DryRun,
+		Prune:  req.Prune,
+	})
+	if err != nil {
+		return nil, modelError(err)
+	}
+
+	return &permissionsv1.ImportModelResponse{
+		Added:                 diff.Added,
+		Removed:               diff.Removed,
+		Unchanged:             diff.Unchanged,
+		Applied:               diff.Applied,
+		RolesAdded:            diff.RolesAdded,
+		RolesUpdated:          diff.RolesUpdated,
+		RolesRemoved:          diff.RolesRemoved,
+		RolesUnchanged:        diff.RolesUnchanged,
+		AssignmentsAdded:      toProtoModelAssignments(diff.AssignmentsAdded),
+		AssignmentsRemoved:    toProtoModelAssignments(diff.AssignmentsRemoved),
+		AssignmentsUnchanged:  diff.AssignmentsUnchanged,
+		AliasesAdded:          toProtoModelAliases(diff.AliasesAdded),
+		AliasesUnchanged:      diff.AliasesUnchanged,
+		DeprecationsAdded:     diff.DeprecationsAdded,
+		DeprecationsRemoved:   diff.DeprecationsRemoved,
+		DeprecationsUnchanged: diff.DeprecationsUnchanged,
+	}, nil
+}
+
+func toProtoModelAliases(aliases []models.ModelAlias) []*permissionsv1.PermissionAlias {
+	result := make([]*permissionsv1.PermissionAlias, len(aliases))
+	for i, alias := range aliases {
+		result[i] = &permissionsv1.PermissionAlias{Alias: alias.Alias, Permission: alias.Permission}
+	}
+	return result
+}
+
+func toProtoModelAssignments(assignments []models.ModelAssignment) []*permissionsv1.RoleAssignment {
+	result := make([]*permissionsv1.RoleAssignment, len(assignments))
+	for i, assignment := range assignments {
+		result[i] = &permissionsv1.RoleAssignment{
+			Subject: assignment.Subject,
+			Role:    assignment.Role,
+			Tenant:  assignment.Tenant,
+		}
+	}
+	return result
+}
+
+// modelError maps domain errors of a model import to gRPC status codes
+func modelError(err error) error {
+	switch {
+	case errors.Is(err, permissions.ErrInvalidModel), errors.Is(err, permissions.ErrInvalidRole):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, permissions.ErrPermissionInUse), errors.Is(err, permissions.ErrRoleReadOnly):
+		return status.Error(codes.FailedPrecondition, err.Error())
+	default:
+		return err
+	}
+}
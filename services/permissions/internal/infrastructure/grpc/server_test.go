@@ -0,0 +1,603 @@
+package grpc
+
+import (
+	"context"
+	permissionsv1 "intellifinder/services/permissions/api/v1"
+	"intellifinder/services/permissions/internal/domain/permissions"
+	"intellifinder/services/permissions/internal/infrastructure/memory"
+	"net"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	serviceauth "github.com/intellifinder/v4/libs/auth"
+	"github.com/intellifinder/v4/libs/observability"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// serviceTokens accepts tokens of the form "<service>-token"
+type serviceTokens struct{}
+
+func (serviceTokens) VerifyServiceToken(_ context.Context, token string) (string, error) {
+	name, ok := strings.CutSuffix(token, "-token")
+	if !ok {
+		return "", serviceauth.ErrInvalidToken
+	}
+	return name, nil
+}
+
+// asService makes the calls with ctx on behalf of the named service
+func asService(ctx context.Context, name string) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+name+"-token")
+}
+
+// userTokens accepts user tokens of the form "<subject>-user-token"
+type userTokens struct{}
+
+func (userTokens) VerifyUserToken(_ context.Context, token string) (string, error) {
+	subject, ok := strings.CutSuffix(token, "-user-token")
+	if !ok {
+		return "", serviceauth.ErrInvalidToken
+	}
+	return subject, nil
+}
+
+// asUser makes calls on behalf of a user, sending their access token
+func asUser(ctx context.Context, id string) context.Context {
+	return metadata.AppendToOutgoingContext(ctx, serviceauth.UserTokenHeader, id+"-user-token")
+}
+
+// defaultService makes calls on behalf of the console service unless the
+// context names another one
+func defaultService(ctx context.Context, method string, req any, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	if md, _ := metadata.FromOutgoingContext(ctx); len(md.Get("authorization")) == 0 {
+		ctx = asService(ctx, "console")
+	}
+	return invoker(ctx, method, req, reply, cc, opts...)
+}
+
+// newTestClient serves a PermissionServer backed by the in-memory repository
+// over bufconn. Calls are made by the console service, the only admin
+// service, unless asService names another one.
+func newTestClient(t *testing.T) permissionsv1.PermissionServiceClient {
+	t.Helper()
+
+	listener := bufconn.Listen(1024 * 1024)
+	server := grpc.NewServer(grpc.UnaryInterceptor(serviceauth.UnaryServerInterceptor(serviceTokens{})))
+	service := permissions.NewService(memory.NewRepository())
+	permissionServer := NewPermissionServer(service, "")
+	permissionServer.SetAdminServices("console")
+	permissionServer.SetUserVerifier(userTokens{})
+	permissionsv1.RegisterPermissionServiceServer(server, permissionServer)
+
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithUnaryInterceptor(defaultService),
+	)
+	if err != nil {
+		t.Fatalf("failed to dial bufconn: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	return permissionsv1.NewPermissionServiceClient(conn)
+}
+
+func registerService(t *testing.T, client permissionsv1.PermissionServiceClient, name string, perms ...string) {
+	t.Helper()
+	resp, err := client.RegisterService(asService(context.Background(), name), &permissionsv1.RegisterServiceRequest{
+		ServiceName: name,
+		Permissions: perms,
+	})
+	if err != nil || !resp.Success {
+		t.Fatalf("RegisterService() = %v, %v", resp, err)
+	}
+}
+
+func TestRegisterAndCheckPermission(t *testing.T) {
+	client := newTestClient(t)
+	ctx := context.Background()
+	registerService(t, client, "tasks", "tasks:read", "tasks:create")
+
+	tests := map[string]bool{
+		"tasks:read":   true,
+		"tasks:delete": false,
+		"malformed":    false,
+	}
+	for permission, want := range tests {
+		resp, err := client.CheckPermission(ctx, &permissionsv1.CheckPermissionRequest{Permission: permission})
+		if err != nil {
+			t.Fatalf("CheckPermission(%s) error = %v", permission, err)
+		}
+		if resp.Exists != want {
+			t.Errorf("CheckPermission(%s) = %v, want %v", permission, resp.Exists, want)
+		}
+	}
+
+	all, err := client.GetAllPermissions(ctx, &permissionsv1.GetAllPermissionsRequest{Page: 1, Limit: 10})
+	if err != nil {
+		t.Fatalf("GetAllPermissions() error = %v", err)
+	}
+	if all.TotalCount != 2 || all.LastPage != 1 {
+		t.Errorf("GetAllPermissions() = %v", all)
+	}
+}
+
+func TestRegisterServiceReportsValidationErrors(t *testing.T) {
+	client := newTestClient(t)
+
+	resp, err := client.RegisterService(asService(context.Background(), "tasks"), &permissionsv1.RegisterServiceRequest{
+		ServiceName: "tasks",
+		Permissions: []string{"invalid"},
+	})
+	if err != nil {
+		t.Fatalf("RegisterService() error = %v", err)
+	}
+	if resp.Success || resp.Message == "" {
+		t.Errorf("RegisterService() = %v, want failure with message", resp)
+	}
+}
+
+func TestRegisterServiceRequiresTheService(t *testing.T) {
+	client := newTestClient(t)
+	req := &permissionsv1.RegisterServiceRequest{ServiceName: "tasks", Permissions: []string{"tasks:read"}}
+
+	_, err := client.RegisterService(asService(context.Background(), "notes"), req)
+	if status.Code(err) != codes.PermissionDenied {
+		t.Errorf("RegisterService() by another service error = %v, want %v", err, codes.PermissionDenied)
+	}
+
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer forged")
+	_, err = client.RegisterService(ctx, req)
+	if status.Code(err) != codes.Unauthenticated {
+		t.Errorf("RegisterService() with invalid token error = %v, want %v", err, codes.Unauthenticated)
+	}
+
+	resp, err := client.CheckPermission(context.Background(), &permissionsv1.CheckPermissionRequest{Permission: "tasks:read"})
+	if err != nil || resp.Exists {
+		t.Errorf("CheckPermission() = %v, %v, want permission not registered", resp, err)
+	}
+}
+
+func TestAdminCallsRequireAnAdminService(t *testing.T) {
+	client := newTestClient(t)
+	registerService(t, client, "tasks", "tasks:read")
+	ctx := asService(context.Background(), "tasks")
+
+	calls := map[string]func() error{
+		"CreateRole": func() error {
+			_, err := client.CreateRole(ctx, &permissionsv1.CreateRoleRequest{Name: "reader", Permissions: []string{"tasks:read"}})
+			return err
+		},
+		"AssignRole": func() error {
+			_, err := client.AssignRole(ctx, &permissionsv1.AssignRoleRequest{Subject: "alice", Role: "reader"})
+			return err
+		},
+		"DeletePermission": func() error {
+			_, err := client.DeletePermission(ctx, &permissionsv1.DeletePermissionRequest{Permission: "tasks:read"})
+			return err
+		},
+		"ImportModel": func() error {
+			_, err := client.ImportModel(ctx, &permissionsv1.ImportModelRequest{Document: []byte("version: 1\n")})
+			return err
+		},
+	}
+	for name, call := range calls {
+		if err := call(); status.Code(err) != codes.PermissionDenied {
+			t.Errorf("%s() by a service that isn't an admin error = %v, want %v", name, err, codes.PermissionDenied)
+		}
+	}
+
+	resp, err := client.CheckPermission(context.Background(), &permissionsv1.CheckPermissionRequest{Permission: "tasks:read"})
+	if err != nil || !resp.Exists {
+		t.Errorf("CheckPermission() = %v, %v, want the permission kept", resp, err)
+	}
+}
+
+func TestRoleLifecycle(t *testing.T) {
+	client := newTestClient(t)
+	ctx := context.Background()
+	registerService(t, client, "tasks", "tasks:read", "tasks:update")
+
+	_, err := client.CreateRole(ctx, &permissionsv1.CreateRoleRequest{
+		Name:        "editor",
+		Permissions: []string{"tasks:update", "tasks:read"},
+	})
+	if err != nil {
+		t.Fatalf("CreateRole() error = %v", err)
+	}
+
+	_, err = client.CreateRole(ctx, &permissionsv1.CreateRoleRequest{Name: "editor"})
+	if status.Code(err) != codes.AlreadyExists {
+		t.Errorf("CreateRole() duplicate code = %v, want AlreadyExists", status.Code(err))
+	}
+
+	_, err = client.CreateRole(ctx, &permissionsv1.CreateRoleRequest{Name: "broken", Permissions: []string{"tasks:missing"}})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("CreateRole() unknown permission code = %v, want InvalidArgument", status.Code(err))
+	}
+
+	got, err := client.GetRole(ctx, &permissionsv1.GetRoleRequest{Name: "editor"})
+	if err != nil {
+		t.Fatalf("GetRole() error = %v", err)
+	}
+	if got.Role.ReadOnly || !slices.Equal(got.Role.Permissions, []string{"tasks:read", "tasks:update"}) {
+		t.Errorf("GetRole() = %v", got.Role)
+	}
+
+	if _, err := client.DeleteRole(ctx, &permissionsv1.DeleteRoleRequest{Name: "editor"}); err != nil {
+		t.Fatalf("DeleteRole() error = %v", err)
+	}
+
+	_, err = client.GetRole(ctx, &permissionsv1.GetRoleRequest{Name: "editor"})
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("GetRole() code = %v, want NotFound", status.Code(err))
+	}
+}
+
+func TestSyncRolesMakesRolesReadOnly(t *testing.T) {
+	client := newTestClient(t)
+	ctx := context.Background()
+	registerService(t, client, "tasks", "tasks:read")
+
+	_, err := client.SyncRoles(ctx, &permissionsv1.SyncRolesRequest{})
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("SyncRoles() without files code = %v, want FailedPrecondition", status.Code(err))
+	}
+
+	resp, err := client.SyncRoles(ctx, &permissionsv1.SyncRolesRequest{
+		Files: []*permissionsv1.RoleFile{{
+			Path:    "tasks/roles.yaml",
+			Content: []byte("roles:\n  - name: task-viewer\n    permissions: [tasks:read]\n"),
+		}},
+	})
+	if err != nil {
+		t.Fatalf("SyncRoles() error = %v", err)
+	}
+	if !resp.Applied || !slices.Equal(resp.Created, []string{"task-viewer"}) {
+		t.Errorf("SyncRoles() = %v", resp)
+	}
+
+	roles, err := client.GetAllRoles(ctx, &permissionsv1.GetAllRolesRequest{Page: 1, Limit: 10})
+	if err != nil {
+		t.Fatalf("GetAllRoles() error = %v", err)
+	}
+	if len(roles.Roles) != 1 || !roles.Roles[0].ReadOnly || roles.Roles[0].Source != "console:tasks/roles.yaml" {
+		t.Errorf("GetAllRoles() = %v", roles.Roles)
+	}
+
+	_, err = client.UpdateRole(ctx, &permissionsv1.UpdateRoleRequest{Name: "task-viewer"})
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("UpdateRole() code = %v, want FailedPrecondition", status.Code(err))
+	}
+
+	// Another service uploading the same path doesn't touch the console's roles
+	resp, err = client.SyncRoles(asService(ctx, "tasks"), &permissionsv1.SyncRolesRequest{
+		Files: []*permissionsv1.RoleFile{{Path: "tasks/roles.yaml"}},
+	})
+	if err != nil {
+		t.Fatalf("SyncRoles() as tasks error = %v", err)
+	}
+	if len(resp.Deleted) != 0 {
+		t.Errorf("SyncRoles() as tasks = %v, want nothing deleted", resp)
+	}
+
+	_, err = client.SyncRoles(ctx, &permissionsv1.SyncRolesRequest{
+		Files: []*permissionsv1.RoleFile{{Path: "../roles.yaml"}},
+	})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("SyncRoles() with an unclean path code = %v, want InvalidArgument", status.Code(err))
+	}
+}
+
+func TestExportImportModel(t *testing.T) {
+	source := newTestClient(t)
+	target := newTestClient(t)
+	ctx := context.Background()
+	registerService(t, source, "tasks", "tasks:read", "tasks:create", "tasks:view")
+	registerService(t, target, "auth", "auth:read")
+
+	if _, err := source.CreateRole(ctx, &permissionsv1.CreateRoleRequest{Name: "reader", Permissions: []string{"tasks:read"}}); err != nil {
+		t.Fatalf("CreateRole() error = %v", err)
+	}
+	if _, err := source.CreatePermissionAlias(ctx, &permissionsv1.CreatePermissionAliasRequest{Alias: "tasks:view", Permission: "tasks:read"}); err != nil {
+		t.Fatalf("CreatePermissionAlias() error = %v", err)
+	}
+	if _, err := source.DeprecatePermission(ctx, &permissionsv1.DeprecatePermissionRequest{Permission: "tasks:create", Reason: "use tasks:write"}); err != nil {
+		t.Fatalf("DeprecatePermission() error = %v", err)
+	}
+	if _, err := source.AssignRole(ctx, &permissionsv1.AssignRoleRequest{Subject: "user-1", Role: "reader", Tenant: "acme"}); err != nil {
+		t.Fatalf("AssignRole() error = %v", err)
+	}
+
+	exported, err := source.ExportModel(ctx, &permissionsv1.ExportModelRequest{Format: "json"})
+	if err != nil {
+		t.Fatalf("ExportModel() error = %v", err)
+	}
+	if exported.Format != "json" {
+		t.Errorf("Format = %v, want json", exported.Format)
+	}
+
+	resp, err := target.ImportModel(ctx, &permissionsv1.ImportModelRequest{Document: exported.Document, Prune: true})
+	if err != nil {
+		t.Fatalf("ImportModel() error = %v", err)
+	}
+	if !resp.Applied || len(resp.Added) != 2 || !slices.Equal(resp.Removed, []string{"auth:read"}) {
+		t.Errorf("ImportModel() = %v", resp)
+	}
+	if !slices.Equal(resp.RolesAdded, []string{"reader"}) || len(resp.AssignmentsAdded) != 1 || resp.AssignmentsAdded[0].Tenant != "acme" {
+		t.Errorf("ImportModel() = %v, want the role and its assignment added", resp)
+	}
+	if len(resp.AliasesAdded) != 1 || resp.AliasesAdded[0].Alias != "tasks:view" || resp.AliasesAdded[0].Permission != "tasks:read" ||
+		!slices.Equal(resp.DeprecationsAdded, []string{"tasks:create"}) {
+		t.Errorf("ImportModel() = %v, want the alias and deprecation added", resp)
+	}
+	aliases, err := target.GetPermissionAliases(ctx, &permissionsv1.GetPermissionAliasesRequest{})
+	if err != nil || len(aliases.Aliases) != 1 || aliases.Aliases[0].Alias != "tasks:view" {
+		t.Errorf("GetPermissionAliases() = %v, %v, want the imported alias", aliases, err)
+	}
+
+	// A version 1 document leaves the roles alone, so tasks:read can't be pruned
+	_, err = target.ImportModel(ctx, &permissionsv1.ImportModelRequest{Document: []byte("version: 1\npermissions: [tasks:create]\n"), Prune: true})
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("ImportModel() code = %v, want FailedPrecondition", status.Code(err))
+	}
+
+	_, err = target.ImportModel(ctx, &permissionsv1.ImportModelRequest{Document: []byte("{not yaml")})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("ImportModel() code = %v, want InvalidArgument", status.Code(err))
+	}
+}
+
+func TestAssignmentsAndAuthorize(t *testing.T) {
+	client := newTestClient(t)
+	ctx := context.Background()
+	registerService(t, client, "tasks", "tasks:read", "tasks:update")
+
+	_, err := client.CreateRole(ctx, &permissionsv1.CreateRoleRequest{Name: "reader", Permissions: []string{"tasks:read"}})
+	if err != nil {
+		t.Fatalf("CreateRole() error = %v", err)
+	}
+
+	_, err = client.AssignRole(ctx, &permissionsv1.AssignRoleRequest{Subject: "user-1", Role: "missing"})
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("AssignRole() unknown role code = %v, want NotFound", status.Code(err))
+	}
+
+	_, err = client.AssignRole(ctx, &permissionsv1.AssignRoleRequest{Subject: "user-1", Role: "reader", Tenant: "acme"})
+	if err != nil {
+		t.Fatalf("AssignRole() error = %v", err)
+	}
+
+	allowed, err := client.Authorize(ctx, &permissionsv1.AuthorizeRequest{Subject: "user-1", Permission: "tasks:read", Tenant: "acme"})
+	if err != nil {
+		t.Fatalf("Authorize() error = %v", err)
+	}
+	if !allowed.Allowed || !slices.Equal(allowed.MatchedRoles, []string{"reader"}) {
+		t.Errorf("Authorize() = %v, want allowed through reader", allowed)
+	}
+
+	otherTenant, err := client.Authorize(ctx, &permissionsv1.AuthorizeRequest{Subject: "user-1", Permission: "tasks:read", Tenant: "globex"})
+	if err != nil {
+		t.Fatalf("Authorize() error = %v", err)
+	}
+	if otherTenant.Allowed {
+		t.Errorf("Authorize() in another tenant = %v, want denied", otherTenant)
+	}
+
+	explained, err := client.ExplainAuthorization(ctx, &permissionsv1.AuthorizeRequest{Subject: "user-1", Permission: "tasks:update", Tenant: "acme"})
+	if err != nil {
+		t.Fatalf("ExplainAuthorization() error = %v", err)
+	}
+	if explained.Allowed || !explained.PermissionRegistered || len(explained.Evaluated) != 1 || explained.Evaluated[0].Granted {
+		t.Errorf("ExplainAuthorization() = %v", explained)
+	}
+
+	if _, err := client.UnassignRole(ctx, &permissionsv1.UnassignRoleRequest{Subject: "user-1", Role: "reader", Tenant: "acme"}); err != nil {
+		t.Fatalf("UnassignRole() error = %v", err)
+	}
+
+	_, err = client.UnassignRole(ctx, &permissionsv1.UnassignRoleRequest{Subject: "user-1", Role: "reader", Tenant: "acme"})
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("UnassignRole() code = %v, want NotFound", status.Code(err))
+	}
+}
+
+func TestPermissionLifecycle(t *testing.T) {
+	client := newTestClient(t)
+	ctx := context.Background()
+	registerService(t, client, "tasks", "tasks:read", "tasks:edit", "tasks:update")
+
+	_, err := client.CreateRole(ctx, &permissionsv1.CreateRoleRequest{Name: "editor", Permissions: []string{"tasks:edit"}})
+	if err != nil {
+		t.Fatalf("CreateRole() error = %v", err)
+	}
+
+	if _, err := client.DeprecatePermission(ctx, &permissionsv1.DeprecatePermissionRequest{Permission: "tasks:read"}); err != nil {
+		t.Fatalf("DeprecatePermission() error = %v", err)
+	}
+
+	all, err := client.GetAllPermissions(ctx, &permissionsv1.GetAllPermissionsRequest{Page: 1, Limit: 10})
+	if err != nil {
+		t.Fatalf("GetAllPermissions() error = %v", err)
+	}
+	if !slices.Equal(all.Deprecated, []string{"tasks:read"}) {
+		t.Errorf("Deprecated = %v, want [tasks:read]", all.Deprecated)
+	}
+
+	_, err = client.DeletePermission(ctx, &permissionsv1.DeletePermissionRequest{Permission: "tasks:edit"})
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("DeletePermission() code = %v, want FailedPrecondition", status.Code(err))
+	}
+
+	alias, err := client.CreatePermissionAlias(ctx, &permissionsv1.CreatePermissionAliasRequest{Alias: "tasks:edit", Permission: "tasks:update"})
+	if err != nil {
+		t.Fatalf("CreatePermissionAlias() error = %v", err)
+	}
+	if alias.Alias.Permission != "tasks:update" || !slices.Equal(alias.MigratedRoles, []string{"editor"}) {
+		t.Errorf("CreatePermissionAlias() = %v", alias)
+	}
+
+	check, err := client.CheckPermission(ctx, &permissionsv1.CheckPermissionRequest{Permission: "tasks:edit"})
+	if err != nil || !check.Exists {
+		t.Errorf("CheckPermission(alias) = %v, %v, want exists", check, err)
+	}
+
+	_, err = client.CreatePermissionAlias(ctx, &permissionsv1.CreatePermissionAliasRequest{Alias: "tasks:edit", Permission: "tasks:read"})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("CreatePermissionAlias() re-point code = %v, want InvalidArgument", status.Code(err))
+	}
+
+	_, err = client.DeletePermission(ctx, &permissionsv1.DeletePermissionRequest{Permission: "tasks:missing"})
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("DeletePermission() code = %v, want NotFound", status.Code(err))
+	}
+}
+
+func TestAccessReview(t *testing.T) {
+	client := newTestClient(t)
+	ctx := context.Background()
+
+	if _, err := client.CreateRole(ctx, &permissionsv1.CreateRoleRequest{Name: "reader"}); err != nil {
+		t.Fatalf("CreateRole() error = %v", err)
+	}
+	if _, err := client.AssignRole(ctx, &permissionsv1.AssignRoleRequest{Subject: "user-1", Role: "reader"}); err != nil {
+		t.Fatalf("AssignRole() error = %v", err)
+	}
+
+	_, err := client.CreateReviewCampaign(ctx, &permissionsv1.CreateReviewCampaignRequest{Name: "q1", Deadline: timestamppb.New(time.Now().Add(-time.Hour))})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("CreateReviewCampaign() past deadline code = %v, want InvalidArgument", status.Code(err))
+	}
+
+	auditor := asUser(ctx, "auditor")
+	_, err = client.CreateReviewCampaign(auditor, &permissionsv1.CreateReviewCampaignRequest{
+		Name:      "q1",
+		Deadline:  timestamppb.New(time.Now().Add(time.Hour)),
+		CreatedBy: "someone-else",
+	})
+	if status.Code(err) != codes.PermissionDenied {
+		t.Errorf("CreateReviewCampaign() for another creator code = %v, want PermissionDenied", status.Code(err))
+	}
+
+	created, err := client.CreateReviewCampaign(auditor, &permissionsv1.CreateReviewCampaignRequest{
+		Name:     "q1",
+		Roles:    []string{"reader"},
+		Deadline: timestamppb.New(time.Now().Add(time.Hour)),
+	})
+	if err != nil {
+		t.Fatalf("CreateReviewCampaign() error = %v", err)
+	}
+	if created.Campaign.Status != "open" || created.Campaign.Pending != 1 || created.Campaign.CreatedBy != "auditor" {
+		t.Errorf("campaign = %v, want open with 1 pending item created by auditor", created.Campaign)
+	}
+
+	items, err := client.GetReviewItems(ctx, &permissionsv1.GetReviewItemsRequest{CampaignId: created.Campaign.Id})
+	if err != nil || len(items.Items) != 1 {
+		t.Fatalf("GetReviewItems() = %v, %v, want 1 item", items, err)
+	}
+	itemID := items.Items[0].Id
+
+	_, err = client.GetReviewItems(ctx, &permissionsv1.GetReviewItemsRequest{CampaignId: "not-a-uuid"})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("GetReviewItems() invalid ID code = %v, want InvalidArgument", status.Code(err))
+	}
+
+	_, err = client.ApproveReviewItem(asUser(ctx, "user-1"), &permissionsv1.DecideReviewItemRequest{ItemId: itemID})
+	if status.Code(err) != codes.PermissionDenied {
+		t.Errorf("ApproveReviewItem() self-review code = %v, want PermissionDenied", status.Code(err))
+	}
+
+	// The reviewer in the request can't override the authenticated caller
+	_, err = client.ApproveReviewItem(asUser(ctx, "user-1"), &permissionsv1.DecideReviewItemRequest{ItemId: itemID, Reviewer: "auditor"})
+	if status.Code(err) != codes.PermissionDenied {
+		t.Errorf("ApproveReviewItem() as another reviewer code = %v, want PermissionDenied", status.Code(err))
+	}
+
+	revoked, err := client.RevokeReviewItem(auditor, &permissionsv1.DecideReviewItemRequest{ItemId: itemID, Comment: "unused"})
+	if err != nil {
+		t.Fatalf("RevokeReviewItem() error = %v", err)
+	}
+	if revoked.Item.Decision != "revoked" || revoked.Item.DecidedAt == nil || revoked.Item.Reviewer != "auditor" {
+		t.Errorf("RevokeReviewItem() = %v, want revoked by auditor", revoked.Item)
+	}
+
+	_, err = client.ApproveReviewItem(auditor, &permissionsv1.DecideReviewItemRequest{ItemId: itemID, Reviewer: "auditor"})
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("ApproveReviewItem() decided code = %v, want FailedPrecondition", status.Code(err))
+	}
+
+	assignments, err := client.GetAssignments(ctx, &permissionsv1.GetAssignmentsRequest{Subject: "user-1", Page: 1, Limit: 10})
+	if err != nil || len(assignments.Assignments) != 0 {
+		t.Errorf("GetAssignments() = %v, %v, want the revoked assignment removed", assignments, err)
+	}
+
+	campaigns, err := client.GetReviewCampaigns(ctx, &permissionsv1.GetReviewCampaignsRequest{Status: "open"})
+	if err != nil || len(campaigns.Campaigns) != 1 || campaigns.Campaigns[0].Revoked != 1 {
+		t.Errorf("GetReviewCampaigns() = %v, %v, want 1 campaign with 1 revocation", campaigns, err)
+	}
+
+	_, err = client.ExportReviewReport(ctx, &permissionsv1.ExportReviewReportRequest{CampaignId: created.Campaign.Id})
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("ExportReviewReport() without signing key code = %v, want FailedPrecondition", status.Code(err))
+	}
+}
+
+// Campaigns created without a user token are attributed to the calling
+// service, but only users can decide review items
+func TestAccessReviewCallerIsService(t *testing.T) {
+	client := newTestClient(t)
+	ctx := context.Background()
+	if _, err := client.CreateRole(ctx, &permissionsv1.CreateRoleRequest{Name: "reader"}); err != nil {
+		t.Fatalf("CreateRole() error = %v", err)
+	}
+	if _, err := client.AssignRole(ctx, &permissionsv1.AssignRoleRequest{Subject: "user-1", Role: "reader"}); err != nil {
+		t.Fatalf("AssignRole() error = %v", err)
+	}
+
+	created, err := client.CreateReviewCampaign(ctx, &permissionsv1.CreateReviewCampaignRequest{
+		Name:     "q1",
+		Deadline: timestamppb.New(time.Now().Add(time.Hour)),
+	})
+	if err != nil {
+		t.Fatalf("CreateReviewCampaign() error = %v", err)
+	}
+	if created.Campaign.CreatedBy != "service:console" {
+		t.Errorf("CreatedBy = %q, want service:console", created.Campaign.CreatedBy)
+	}
+	items, err := client.GetReviewItems(ctx, &permissionsv1.GetReviewItemsRequest{CampaignId: created.Campaign.Id})
+	if err != nil || len(items.Items) != 1 {
+		t.Fatalf("GetReviewItems() = %v, %v, want 1 item", items, err)
+	}
+	itemID := items.Items[0].Id
+
+	_, err = client.ApproveReviewItem(ctx, &permissionsv1.DecideReviewItemRequest{ItemId: itemID})
+	if status.Code(err) != codes.Unauthenticated {
+		t.Errorf("ApproveReviewItem() by a service code = %v, want Unauthenticated", status.Code(err))
+	}
+
+	// The user ID set by the gateway for logging is not proof of identity
+	forged := metadata.AppendToOutgoingContext(ctx, observability.UserIDHeader, "auditor")
+	_, err = client.RevokeReviewItem(forged, &permissionsv1.DecideReviewItemRequest{ItemId: itemID})
+	if status.Code(err) != codes.Unauthenticated {
+		t.Errorf("RevokeReviewItem() with a forged user ID code = %v, want Unauthenticated", status.Code(err))
+	}
+
+	invalid := metadata.AppendToOutgoingContext(ctx, serviceauth.UserTokenHeader, "forged")
+	_, err = client.RevokeReviewItem(invalid, &permissionsv1.DecideReviewItemRequest{ItemId: itemID})
+	if status.Code(err) != codes.Unauthenticated {
+		t.Errorf("RevokeReviewItem() with an invalid user token code = %v, want Unauthenticated", status.Code(err))
+	}
+}
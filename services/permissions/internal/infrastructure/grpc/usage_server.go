@@ -0,0 +1,57 @@
+package grpc
+
+import (
+	"context"
+	permissionsv1 "intellifinder/services/permissions/api/v1"
+	"intellifinder/services/permissions/pkg/models"
+	"time"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+func (s *PermissionServer) GetPermissionUsage(ctx context.Context, req *permissionsv1.GetPermissionUsageRequest) (*permissionsv1.GetPermissionUsageResponse, error) {
+	var unusedSince *time.Time
+	if req.UnusedSince != nil {
+		since := req.UnusedSince.AsTime()
+		unusedSince = &since
+	}
+
+	usage, err := s.service.GetPermissionUsage(ctx, req.ServiceName, unusedSince)
+	if err != nil {
+		return nil, err
+	}
+
+	permissions := make([]*permissionsv1.PermissionUsage, len(usage))
+	for i := range usage {
+		permissions[i] = toProtoUsage(&usage[i])
+	}
+
+	return &permissionsv1.GetPermissionUsageResponse{
+		Permissions: permissions,
+	}, nil
+}
+
+func toProtoUsage(usage *models.PermissionUsage) *permissionsv1.PermissionUsage {
+	roles := make([]*permissionsv1.RoleUsage, len(usage.Roles))
+	for i, role := range usage.Roles {
+		roles[i] = &permissionsv1.RoleUsage{
+			Role:          role.Role,
+			GrantCount:    role.GrantCount,
+			LastGrantedAt: optionalTimestamp(role.LastGrantedAt),
+		}
+	}
+
+	return &permissionsv1.PermissionUsage{
+		Permission:    usage.Permission,
+		CheckCount:    usage.CheckCount,
+		LastCheckedAt: optionalTimestamp(usage.LastCheckedAt),
+		Roles:         roles,
+	}
+}
+
+func optionalTimestamp(t *time.Time) *timestamppb.Timestamp {
+	if t == nil {
+		return nil
+	}
+	return timestamppb.New(*t)
+}
@@ -0,0 +1,135 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"intellifinder/services/permissions/pkg/dto"
+	"intellifinder/services/permissions/pkg/models"
+	"sort"
+
+	"github.com/google/uuid"
+)
+
+func assignmentKey(subject, role, tenant string) string {
+	return subject + "\x00" + role + "\x00" + tenant
+}
+
+func (r *Repository) CreateAssignment(ctx context.Context, assignment *models.RoleAssignment) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.createAssignmentLocked(assignment)
+}
+
+func (r *Repository) createAssignmentLocked(assignment *models.RoleAssignment) error {
+	if _, ok := r.roles[assignment.Role]; !ok {
+		return fmt.Errorf("failed to create role assignment: role %s does not exist", assignment.Role)
+	}
+
+	key := assignmentKey(assignment.Subject, assignment.Role, assignment.Tenant)
+	if existing, ok := r.assignments[key]; ok {
+		assignment.ID = existing.ID
+		assignment.CreatedAt = existing.CreatedAt
+		return nil
+	}
+
+	assignment.ID = uuid.New()
+	assignment.CreatedAt = now()
+	r.assignments[key] = *assignment
+	return nil
+}
+
+func (r *Repository) DeleteAssignment(ctx context.Context, subject string, role string, tenant string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := assignmentKey(subject, role, tenant)
+	if _, ok := r.assignments[key]; !ok {
+		return false, nil
+	}
+
+	delete(r.assignments, key)
+	return true, nil
+}
+
+func (r *Repository) GetAssignments(ctx context.Context, filter dto.AssignmentFilter, page int32, limit int32) (*dto.PaginatedAssignments, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var matching []models.RoleAssignment
+	for _, assignment := range r.assignments {
+		if filter.Subject != "" && assignment.Subject != filter.Subject {
+			continue
+		}
+		if filter.Role != "" && assignment.Role != filter.Role {
+			continue
+		}
+		if filter.Tenant != "" && assignment.Tenant != filter.Tenant {
+			continue
+		}
+		matching = append(matching, assignment)
+	}
+
+	sortAssignments(matching)
+
+	totalCount := int32(len(matching))
+	return dto.NewPaginatedAssignments(pageOf(matching, page, limit), page, limit, totalCount), nil
+}
+
+func (r *Repository) ListAssignments(ctx context.Context) ([]models.RoleAssignment, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	assignments := make([]models.RoleAssignment, 0, len(r.assignments))
+	for _, assignment := range r.assignments {
+		assignments = append(assignments, assignment)
+	}
+
+	sortAssignments(assignments)
+	return assignments, nil
+}
+
+func (r *Repository) GetSubjectRoles(ctx context.Context, subject string, tenant string) ([]models.Role, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	names := make(map[string]bool)
+	for _, assignment := range r.assignments {
+		if assignment.Subject == subject && (assignment.Tenant == "" || assignment.Tenant == tenant) {
+			names[assignment.Role] = true
+		}
+	}
+
+	roles := make([]models.Role, 0, len(names))
+	for name := range names {
+		roles = append(roles, copyRole(r.roles[name]))
+	}
+
+	sort.Slice(roles, func(i, j int) bool {
+		return roles[i].Name < roles[j].Name
+	})
+	return roles, nil
+}
+
+// deleteRoleAssignmentsLocked mirrors ON DELETE CASCADE from roles to assignments
+func (r *Repository) deleteRoleAssignmentsLocked(role string) {
+	for key, assignment := range r.assignments {
+		if assignment.Role == role {
+			delete(r.assignments, key)
+		}
+	}
+}
+
+// sortAssignments orders like ORDER BY subject, role, tenant
+func sortAssignments(assignments []models.RoleAssignment) {
+	sort.Slice(assignments, func(i, j int) bool {
+		a, b := assignments[i], assignments[j]
+		if a.Subject != b.Subject {
+			return a.Subject < b.Subject
+		}
+		if a.Role != b.Role {
+			return a.Role < b.Role
+		}
+		return a.Tenant < b.Tenant
+	})
+}
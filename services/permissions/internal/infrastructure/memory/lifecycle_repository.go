@@ -0,0 +1,176 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"intellifinder/services/permissions/pkg/models"
+	"slices"
+	"sort"
+)
+
+func (r *Repository) GetPermission(ctx context.Context, service string, action string) (*models.Permission, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	perm, ok := r.permissions[service+":"+action]
+	if !ok {
+		return nil, nil
+	}
+	return &perm, nil
+}
+
+func (r *Repository) DeprecatePermission(ctx context.Context, service string, action string, reason string) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := service + ":" + action
+	perm, ok := r.permissions[key]
+	if !ok {
+		return false, nil
+	}
+
+	r.deprecateLocked(perm, reason)
+	return true, nil
+}
+
+// deprecateLocked keeps the original deprecation time of perm
+func (r *Repository) deprecateLocked(perm models.Permission, reason string) {
+	ts := now()
+	if perm.DeprecatedAt == nil {
+		perm.DeprecatedAt = &ts
+	}
+	perm.DeprecationReason = reason
+	perm.UpdatedAt = ts
+	r.permissions[perm.Name()] = perm
+}
+
+func (r *Repository) GetPermissionRoles(ctx context.Context, service string, action string) ([]string, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	return r.rolesGrantingLocked(service + ":" + action), nil
+}
+
+func (r *Repository) DeletePermission(ctx context.Context, service string, action string, force bool) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := service + ":" + action
+	if _, ok := r.permissions[key]; !ok {
+		return false, nil
+	}
+
+	roles := r.rolesGrantingLocked(key)
+	if len(roles) > 0 && !force {
+		return false, fmt.Errorf("failed to delete permission: still referenced by role %s", roles[0])
+	}
+
+	for _, name := range roles {
+		role := r.roles[name]
+		role.Permissions = slices.DeleteFunc(slices.Clone(role.Permissions), func(permission string) bool {
+			return permission == key
+		})
+		r.roles[name] = role
+	}
+
+	r.deletePermissionLocked(key)
+	return true, nil
+}
+
+func (r *Repository) GetPermissionAlias(ctx context.Context, alias string) (*models.PermissionAlias, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	permissionAlias, ok := r.aliases[alias]
+	if !ok {
+		return nil, nil
+	}
+	return &permissionAlias, nil
+}
+
+func (r *Repository) ListPermissionAliases(ctx context.Context) ([]models.PermissionAlias, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	aliases := make([]models.PermissionAlias, 0, len(r.aliases))
+	for _, alias := range r.aliases {
+		aliases = append(aliases, alias)
+	}
+
+	sort.Slice(aliases, func(i, j int) bool {
+		return aliases[i].Alias < aliases[j].Alias
+	})
+	return aliases, nil
+}
+
+func (r *Repository) CreatePermissionAlias(ctx context.Context, alias string, permission string) ([]string, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.createAliasLocked(alias, permission)
+}
+
+func (r *Repository) createAliasLocked(alias string, permission string) ([]string, error) {
+	if _, ok := r.permissions[permission]; !ok {
+		return nil, fmt.Errorf("permission %s does not exist", permission)
+	}
+
+	if _, ok := r.aliases[alias]; ok {
+		return nil, fmt.Errorf("failed to create permission alias: duplicate alias %s", alias)
+	}
+
+	migrated := []string{}
+	if _, ok := r.permissions[alias]; ok {
+		migrated = r.rolesGrantingLocked(alias)
+		for _, name := range migrated {
+			role := r.roles[name]
+			perms := slices.DeleteFunc(slices.Clone(role.Permissions), func(p string) bool {
+				return p == alias || p == permission
+			})
+			role.Permissions = append(perms, permission)
+			r.roles[name] = copyRole(role)
+		}
+
+		for name, existing := range r.aliases {
+			if existing.Permission == alias {
+				existing.Permission = permission
+				r.aliases[name] = existing
+			}
+		}
+
+		delete(r.permissions, alias)
+		r.deletePermissionUsageLocked(alias)
+	}
+
+	r.aliases[alias] = models.PermissionAlias{
+		Alias:      alias,
+		Permission: permission,
+		CreatedAt:  now(),
+	}
+	return migrated, nil
+}
+
+// rolesGrantingLocked returns the sorted names of the roles that grant permission
+func (r *Repository) rolesGrantingLocked(permission string) []string {
+	roles := []string{}
+	for name, role := range r.roles {
+		if slices.Contains(role.Permissions, permission) {
+			roles = append(roles, name)
+		}
+	}
+
+	sort.Strings(roles)
+	return roles
+}
+
+// deletePermissionLocked removes a permission and, like the cascade in Postgres, its aliases
+func (r *Repository) deletePermissionLocked(permission string) {
+	delete(r.permissions, permission)
+	r.deletePermissionUsageLocked(permission)
+
+	for name, alias := range r.aliases {
+		if alias.Permission == permission {
+			delete(r.aliases, name)
+		}
+	}
+}
@@ -0,0 +1,482 @@
+// Package memory provides an in-memory permissions.Repository for tests and
+// local development. It mirrors the Postgres implementation's semantics:
+// unique (service, action) pairs and role names, the same ordering and
+// pagination, referential integrity between roles and permissions, and
+// all-or-nothing batch changes.
+package memory
+
+import (
+	"context"
+	"fmt"
+	"intellifinder/services/permissions/internal/domain/permissions"
+	"intellifinder/services/permissions/pkg/dto"
+	"intellifinder/services/permissions/pkg/models"
+	"maps"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Repository struct {
+	mu          sync.RWMutex
+	permissions map[string]models.Permission      // keyed by "service:action"
+	aliases     map[string]models.PermissionAlias // keyed by alias
+	roles       map[string]models.Role            // keyed by name
+	assignments map[string]models.RoleAssignment  // keyed by assignmentKey
+
+	permissionUsage map[string]models.PermissionUsage // keyed by "service:action"
+	roleUsage       map[roleUsageKey]models.RoleUsage
+
+	campaigns   map[uuid.UUID]models.ReviewCampaign
+	reviewItems map[uuid.UUID]models.ReviewItem
+}
+
+// NewRepository creates an empty in-memory repository
+func NewRepository() *Repository {
+	return &Repository{
+		permissions: make(map[string]models.Permission),
+		aliases:     make(map[string]models.PermissionAlias),
+		roles:       make(map[string]models.Role),
+		assignments: make(map[string]models.RoleAssignment),
+
+		permissionUsage: make(map[string]models.PermissionUsage),
+		roleUsage:       make(map[roleUsageKey]models.RoleUsage),
+
+		campaigns:   make(map[uuid.UUID]models.ReviewCampaign),
+		reviewItems: make(map[uuid.UUID]models.ReviewItem),
+	}
+}
+
+// now matches the microsecond precision of Postgres timestamps
+func now() time.Time {
+	return time.Now().UTC().Truncate(time.Microsecond)
+}
+
+func (r *Repository) CreatePermission(ctx context.Context, permission *models.Permission) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := permission.Service + ":" + permission.Action
+	if _, ok := r.permissions[key]; ok {
+		return nil
+	}
+
+	stored := *permission
+	stored.ID = uuid.New()
+	r.permissions[key] = stored
+	return nil
+}
+
+func (r *Repository) RegisterServicePermissions(ctx context.Context, serviceName string, permissions []string) error {
+	if len(permissions) == 0 {
+		return nil
+	}
+
+	// Parse everything first so an invalid entry inserts nothing, like the bulk insert
+	parsed := make([]models.Permission, 0, len(permissions))
+	ts := now()
+	for _, permissionStr := range permissions {
+		service, action, err := parsePermissionString(permissionStr)
+		if err != nil {
+			return fmt.Errorf("failed to parse permission string %s: %w", permissionStr, err)
+		}
+		parsed = append(parsed, models.Permission{Service: service, Action: action, CreatedAt: ts, UpdatedAt: ts})
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, perm := range parsed {
+		r.insertLocked(perm)
+	}
+	return nil
+}
+
+func (r *Repository) PermissionExistsByServiceAndAction(ctx context.Context, service string, action string) (bool, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	_, ok := r.permissions[service+":"+action]
+	return ok, nil
+}
+
+func (r *Repository) GetServicePermissions(ctx context.Context, service string, page int32, limit int32) (*dto.PaginatedPermissions, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var matching []models.Permission
+	for _, perm := range r.permissions {
+		if perm.Service == service {
+			matching = append(matching, perm)
+		}
+	}
+
+	return paginatePermissions(matching, page, limit), nil
+}
+
+func (r *Repository) GetAllPermissions(ctx context.Context, page int32, limit int32) (*dto.PaginatedPermissions, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	all := make([]models.Permission, 0, len(r.permissions))
+	for _, perm := range r.permissions {
+		all = append(all, perm)
+	}
+
+	return paginatePermissions(all, page, limit), nil
+}
+
+func (r *Repository) ListPermissions(ctx context.Context) ([]models.Permission, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	all := make([]models.Permission, 0, len(r.permissions))
+	for _, perm := range r.permissions {
+		all = append(all, perm)
+	}
+
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].Service != all[j].Service {
+			return all[i].Service < all[j].Service
+		}
+		return all[i].Action < all[j].Action
+	})
+	return all, nil
+}
+
+func (r *Repository) ApplyModelChanges(ctx context.Context, changes permissions.ModelChanges) error {
+	// Parse everything first so an invalid entry changes nothing
+	toAdd := make([]models.Permission, 0, len(changes.AddPermissions))
+	ts := now()
+	for _, permissionStr := range changes.AddPermissions {
+		service, action, err := parsePermissionString(permissionStr)
+		if err != nil {
+			return fmt.Errorf("failed to parse permission string %s: %w", permissionStr, err)
+		}
+		toAdd = append(toAdd, models.Permission{Service: service, Action: action, CreatedAt: ts, UpdatedAt: ts})
+	}
+	for _, permissionStr := range changes.RemovePermissions {
+		if _, _, err := parsePermissionString(permissionStr); err != nil {
+			return fmt.Errorf("failed to parse permission string %s: %w", permissionStr, err)
+		}
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	// Apply the changes in order, like the transaction in Postgres, and roll
+	// back to the snapshot when one fails
+	snapshot := r.snapshotLocked()
+	if err := r.applyModelLocked(toAdd, changes); err != nil {
+		r.restoreLocked(snapshot)
+		return err
+	}
+	return nil
+}
+
+func (r *Repository) applyModelLocked(toAdd []models.Permission, changes permissions.ModelChanges) error {
+	for _, perm := range toAdd {
+		r.insertLocked(perm)
+	}
+
+	for _, alias := range changes.AddAliases {
+		if _, err := r.createAliasLocked(alias.Alias, alias.Permission); err != nil {
+			return err
+		}
+	}
+
+	for _, deprecation := range changes.DeprecatePermissions {
+		perm, ok := r.permissions[deprecation.Permission]
+		if !ok {
+			return fmt.Errorf("failed to deprecate permission %s: it does not exist", deprecation.Permission)
+		}
+		r.deprecateLocked(perm, deprecation.Reason)
+	}
+	for _, permission := range changes.RestorePermissions {
+		if perm, ok := r.permissions[permission]; ok {
+			perm.DeprecatedAt, perm.DeprecationReason, perm.UpdatedAt = nil, "", now()
+			r.permissions[permission] = perm
+		}
+	}
+
+	create := make([]*models.Role, len(changes.CreateRoles))
+	for i := range changes.CreateRoles {
+		create[i] = &changes.CreateRoles[i]
+	}
+	update := make([]*models.Role, len(changes.UpdateRoles))
+	for i := range changes.UpdateRoles {
+		update[i] = &changes.UpdateRoles[i]
+	}
+	if err := r.applyRolesLocked(create, update, nil); err != nil {
+		return err
+	}
+
+	for i := range changes.AddAssignments {
+		if err := r.createAssignmentLocked(&changes.AddAssignments[i]); err != nil {
+			return err
+		}
+	}
+	for _, assignment := range changes.RemoveAssignments {
+		delete(r.assignments, assignmentKey(assignment.Subject, assignment.Role, assignment.Tenant))
+	}
+
+	if err := r.applyRolesLocked(nil, nil, changes.RemoveRoles); err != nil {
+		return err
+	}
+
+	// Permissions bound to a role can't be deleted (foreign key in Postgres)
+	for _, permission := range changes.RemovePermissions {
+		if roles := r.rolesGrantingLocked(permission); len(roles) > 0 {
+			return fmt.Errorf("failed to delete permission %s: still referenced by role %s", permission, roles[0])
+		}
+		r.deletePermissionLocked(permission)
+	}
+	return nil
+}
+
+type snapshot struct {
+	permissions     map[string]models.Permission
+	aliases         map[string]models.PermissionAlias
+	roles           map[string]models.Role
+	assignments     map[string]models.RoleAssignment
+	permissionUsage map[string]models.PermissionUsage
+	roleUsage       map[roleUsageKey]models.RoleUsage
+}
+
+// snapshotLocked copies the state a model import changes. Stored values are
+// never modified in place, so copying the maps is enough.
+func (r *Repository) snapshotLocked() snapshot {
+	return snapshot{
+		permissions:     maps.Clone(r.permissions),
+		aliases:         maps.Clone(r.aliases),
+		roles:           maps.Clone(r.roles),
+		assignments:     maps.Clone(r.assignments),
+		permissionUsage: maps.Clone(r.permissionUsage),
+		roleUsage:       maps.Clone(r.roleUsage),
+	}
+}
+
+func (r *Repository) restoreLocked(s snapshot) {
+	r.permissions = s.permissions
+	r.aliases = s.aliases
+	r.roles = s.roles
+	r.assignments = s.assignments
+	r.permissionUsage = s.permissionUsage
+	r.roleUsage = s.roleUsage
+}
+
+func (r *Repository) GetRoleByName(ctx context.Context, name string) (*models.Role, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	role, ok := r.roles[name]
+	if !ok {
+		return nil, nil
+	}
+
+	role = copyRole(role)
+	return &role, nil
+}
+
+func (r *Repository) GetAllRoles(ctx context.Context, page int32, limit int32) (*dto.PaginatedRoles, error) {
+	roles := r.sortedRoles()
+	totalCount := int32(len(roles))
+
+	return dto.NewPaginatedRoles(pageOf(roles, page, limit), page, limit, totalCount), nil
+}
+
+func (r *Repository) ListRoles(ctx context.Context) ([]models.Role, error) {
+	return r.sortedRoles(), nil
+}
+
+func (r *Repository) CreateRole(ctx context.Context, role *models.Role) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.applyRolesLocked([]*models.Role{role}, nil, nil)
+}
+
+func (r *Repository) UpdateRole(ctx context.Context, role *models.Role) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return r.applyRolesLocked(nil, []*models.Role{role}, nil)
+}
+
+func (r *Repository) DeleteRole(ctx context.Context, name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	delete(r.roles, name)
+	r.deleteRoleAssignmentsLocked(name)
+	r.deleteRoleUsageLocked(name)
+	return nil
+}
+
+func (r *Repository) ApplyRoleChanges(ctx context.Context, create []models.Role, update []models.Role, remove []string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	createPtrs := make([]*models.Role, len(create))
+	for i := range create {
+		createPtrs[i] = &create[i]
+	}
+	updatePtrs := make([]*models.Role, len(update))
+	for i := range update {
+		updatePtrs[i] = &update[i]
+	}
+
+	return r.applyRolesLocked(createPtrs, updatePtrs, remove)
+}
+
+// applyRolesLocked validates every change against a scratch copy before
+// committing it, so a failing change leaves the roles untouched.
+func (r *Repository) applyRolesLocked(create []*models.Role, update []*models.Role, remove []string) error {
+	next := make(map[string]models.Role, len(r.roles)+len(create))
+	for name, role := range r.roles {
+		next[name] = role
+	}
+
+	ts := now()
+	for _, role := range create {
+		if _, ok := next[role.Name]; ok {
+			return fmt.Errorf("failed to create role %s: duplicate role name", role.Name)
+		}
+		if err := r.checkPermissionsLocked(role); err != nil {
+			return err
+		}
+
+		stored := copyRole(*role)
+		stored.ID = uuid.New()
+		stored.CreatedAt = ts
+		stored.UpdatedAt = ts
+		next[role.Name] = stored
+	}
+
+	for _, role := range update {
+		old, ok := next[role.Name]
+		if !ok {
+			return fmt.Errorf("failed to update role %s: role does not exist", role.Name)
+		}
+		if err := r.checkPermissionsLocked(role); err != nil {
+			return err
+		}
+
+		stored := copyRole(*role)
+		stored.ID = old.ID
+		stored.CreatedAt = old.CreatedAt
+		stored.UpdatedAt = ts
+		next[role.Name] = stored
+	}
+
+	for _, name := range remove {
+		delete(next, name)
+	}
+
+	r.roles = next
+	for _, name := range remove {
+		r.deleteRoleAssignmentsLocked(name)
+		r.deleteRoleUsageLocked(name)
+	}
+
+	// Reflect generated fields back to the caller, like the Postgres implementation
+	for _, role := range create {
+		role.ID = next[role.Name].ID
+		role.CreatedAt = ts
+		role.UpdatedAt = ts
+	}
+	for _, role := range update {
+		role.ID = next[role.Name].ID
+		role.UpdatedAt = ts
+	}
+	return nil
+}
+
+func (r *Repository) checkPermissionsLocked(role *models.Role) error {
+	found := make(map[string]bool, len(role.Permissions))
+	for _, permission := range role.Permissions {
+		if _, ok := r.permissions[permission]; ok {
+			found[permission] = true
+		}
+	}
+
+	// Duplicates count as a mismatch, just like the rows-affected check in Postgres
+	if len(found) != len(role.Permissions) {
+		return fmt.Errorf("role %s references unregistered permissions", role.Name)
+	}
+	return nil
+}
+
+func (r *Repository) insertLocked(perm models.Permission) {
+	key := perm.Service + ":" + perm.Action
+	if _, ok := r.permissions[key]; ok {
+		return
+	}
+
+	perm.ID = uuid.New()
+	r.permissions[key] = perm
+}
+
+func (r *Repository) sortedRoles() []models.Role {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	roles := make([]models.Role, 0, len(r.roles))
+	for _, role := range r.roles {
+		roles = append(roles, copyRole(role))
+	}
+
+	sort.Slice(roles, func(i, j int) bool {
+		return roles[i].Name < roles[j].Name
+	})
+	return roles
+}
+
+// paginatePermissions orders newest first, like ORDER BY created_at DESC
+func paginatePermissions(permissions []models.Permission, page int32, limit int32) *dto.PaginatedPermissions {
+	sort.Slice(permissions, func(i, j int) bool {
+		if !permissions[i].CreatedAt.Equal(permissions[j].CreatedAt) {
+			return permissions[i].CreatedAt.After(permissions[j].CreatedAt)
+		}
+		if permissions[i].Service != permissions[j].Service {
+			return permissions[i].Service < permissions[j].Service
+		}
+		return permissions[i].Action < permissions[j].Action
+	})
+
+	totalCount := int32(len(permissions))
+	return dto.NewPaginatedPermissions(pageOf(permissions, page, limit), page, limit, totalCount)
+}
+
+func pageOf[T any](items []T, page int32, limit int32) []T {
+	offset := int((page - 1) * limit)
+	if offset < 0 || offset >= len(items) {
+		return []T{}
+	}
+
+	end := offset + int(limit)
+	if end > len(items) {
+		end = len(items)
+	}
+	return items[offset:end]
+}
+
+// copyRole sorts the permissions like the Postgres array_agg and detaches the slice
+func copyRole(role models.Role) models.Role {
+	perms := make([]string, len(role.Permissions))
+	copy(perms, role.Permissions)
+	sort.Strings(perms)
+	role.Permissions = perms
+	return role
+}
+
+func parsePermissionString(permissionStr string) (service, action string, err error) {
+	parts := strings.Split(permissionStr, ":")
+	if len(parts) != 2 {
+		return "", "", fmt.Errorf("invalid permission format, expected 'service:action', got: %s", permissionStr)
+	}
+	return parts[0], parts[1], nil
+}
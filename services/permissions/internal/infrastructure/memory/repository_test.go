@@ -0,0 +1,13 @@
+package memory
+
+import (
+	"intellifinder/services/permissions/internal/domain/permissions"
+	"intellifinder/services/permissions/internal/domain/permissions/permissionstest"
+	"testing"
+)
+
+func TestRepositoryConformance(t *testing.T) {
+	permissionstest.RepositoryConformance(t, func(t *testing.T) permissions.Repository {
+		return NewRepository()
+	})
+}
@@ -0,0 +1,215 @@
+package memory
+
+import (
+	"context"
+	"intellifinder/services/permissions/pkg/models"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+func (r *Repository) CreateReviewCampaign(ctx context.Context, campaign *models.ReviewCampaign) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if campaign.Roles == nil {
+		campaign.Roles = []string{}
+	}
+	if campaign.Tenants == nil {
+		campaign.Tenants = []string{}
+	}
+	campaign.ID = uuid.New()
+	campaign.CreatedAt = now()
+	campaign.Deadline = campaign.Deadline.UTC().Truncate(time.Microsecond)
+	campaign.ReviewSummary = models.ReviewSummary{}
+
+	for _, assignment := range r.assignments {
+		if len(campaign.Roles) > 0 && !slices.Contains(campaign.Roles, assignment.Role) {
+			continue
+		}
+		if len(campaign.Tenants) > 0 && !slices.Contains(campaign.Tenants, assignment.Tenant) {
+			continue
+		}
+
+		item := models.ReviewItem{
+			ID:         uuid.New(),
+			CampaignID: campaign.ID,
+			Subject:    assignment.Subject,
+			Role:       assignment.Role,
+			Tenant:     assignment.Tenant,
+			Decision:   models.ReviewPending,
+		}
+		r.reviewItems[item.ID] = item
+		campaign.Pending++
+	}
+
+	stored := *campaign
+	stored.Roles = slices.Clone(campaign.Roles)
+	stored.Tenants = slices.Clone(campaign.Tenants)
+	stored.ReviewSummary = models.ReviewSummary{}
+	r.campaigns[campaign.ID] = stored
+	return nil
+}
+
+func (r *Repository) GetReviewCampaign(ctx context.Context, id uuid.UUID) (*models.ReviewCampaign, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if _, ok := r.campaigns[id]; !ok {
+		return nil, nil
+	}
+
+	campaign := r.reviewCampaign(id)
+	return &campaign, nil
+}
+
+func (r *Repository) ListReviewCampaigns(ctx context.Context, status string) ([]models.ReviewCampaign, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	campaigns := []models.ReviewCampaign{}
+	for id, campaign := range r.campaigns {
+		if status != "" && campaign.Status != status {
+			continue
+		}
+		campaigns = append(campaigns, r.reviewCampaign(id))
+	}
+
+	sort.Slice(campaigns, func(i, j int) bool {
+		return campaigns[i].CreatedAt.After(campaigns[j].CreatedAt)
+	})
+
+	return campaigns, nil
+}
+
+func (r *Repository) GetReviewItems(ctx context.Context, campaignID uuid.UUID, decision string) ([]models.ReviewItem, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	items := []models.ReviewItem{}
+	for _, item := range r.reviewItems {
+		if item.CampaignID != campaignID {
+			continue
+		}
+		if decision != "" && item.Decision != decision {
+			continue
+		}
+		items = append(items, item)
+	}
+
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].Subject != items[j].Subject {
+			return items[i].Subject < items[j].Subject
+		}
+		if items[i].Role != items[j].Role {
+			return items[i].Role < items[j].Role
+		}
+		return items[i].Tenant < items[j].Tenant
+	})
+
+	return items, nil
+}
+
+func (r *Repository) GetReviewItem(ctx context.Context, id uuid.UUID) (*models.ReviewItem, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	item, ok := r.reviewItems[id]
+	if !ok {
+		return nil, nil
+	}
+
+	return &item, nil
+}
+
+func (r *Repository) DecideReviewItem(ctx context.Context, item *models.ReviewItem) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	stored, ok := r.reviewItems[item.ID]
+	if !ok || stored.Decision != models.ReviewPending {
+		return false, nil
+	}
+
+	if r.campaigns[stored.CampaignID].Status != models.CampaignOpen {
+		return false, nil
+	}
+
+	stored.Decision = item.Decision
+	stored.Reviewer = item.Reviewer
+	stored.Comment = item.Comment
+	stored.DecidedAt = truncateTime(item.DecidedAt)
+	r.reviewItems[item.ID] = stored
+
+	if item.Decision == models.ReviewRevoked {
+		delete(r.assignments, assignmentKey(stored.Subject, stored.Role, stored.Tenant))
+	}
+
+	return true, nil
+}
+
+func (r *Repository) CloseReviewCampaign(ctx context.Context, id uuid.UUID, closedAt time.Time) ([]models.ReviewItem, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	campaign, ok := r.campaigns[id]
+	if !ok || campaign.Status != models.CampaignOpen {
+		return nil, nil
+	}
+
+	closedAt = closedAt.UTC().Truncate(time.Microsecond)
+	campaign.Status = models.CampaignClosed
+	campaign.ClosedAt = &closedAt
+	r.campaigns[id] = campaign
+
+	var revoked []models.ReviewItem
+	for itemID, item := range r.reviewItems {
+		if item.CampaignID != id || item.Decision != models.ReviewPending {
+			continue
+		}
+
+		item.Decision = models.ReviewAutoRevoked
+		item.DecidedAt = &closedAt
+		r.reviewItems[itemID] = item
+		delete(r.assignments, assignmentKey(item.Subject, item.Role, item.Tenant))
+		revoked = append(revoked, item)
+	}
+
+	return revoked, nil
+}
+
+// reviewCampaign returns a copy of the campaign with its summary computed from its items
+func (r *Repository) reviewCampaign(id uuid.UUID) models.ReviewCampaign {
+	campaign := r.campaigns[id]
+	campaign.Roles = slices.Clone(campaign.Roles)
+	campaign.Tenants = slices.Clone(campaign.Tenants)
+
+	for _, item := range r.reviewItems {
+		if item.CampaignID != id {
+			continue
+		}
+
+		switch item.Decision {
+		case models.ReviewPending:
+			campaign.Pending++
+		case models.ReviewApproved:
+			campaign.Approved++
+		case models.ReviewRevoked:
+			campaign.Revoked++
+		case models.ReviewAutoRevoked:
+			campaign.AutoRevoked++
+		}
+	}
+
+	return campaign
+}
+
+func truncateTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	truncated := t.UTC().Truncate(time.Microsecond)
+	return &truncated
+}
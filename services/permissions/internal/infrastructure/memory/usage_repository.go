@@ -0,0 +1,112 @@
+package memory
+
+import (
+	"context"
+	"intellifinder/services/permissions/pkg/models"
+	"sort"
+	"time"
+)
+
+type roleUsageKey struct {
+	role       string
+	permission string
+}
+
+func (r *Repository) RecordUsage(ctx context.Context, permissions []models.PermissionUsage, roles []models.RoleUsage) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for _, usage := range permissions {
+		if _, ok := r.permissions[usage.Permission]; !ok {
+			continue
+		}
+
+		stored := r.permissionUsage[usage.Permission]
+		stored.Permission = usage.Permission
+		stored.CheckCount += usage.CheckCount
+		stored.LastCheckedAt = laterOf(stored.LastCheckedAt, usage.LastCheckedAt)
+		r.permissionUsage[usage.Permission] = stored
+	}
+
+	for _, usage := range roles {
+		if _, ok := r.permissions[usage.Permission]; !ok {
+			continue
+		}
+		if _, ok := r.roles[usage.Role]; !ok {
+			continue
+		}
+
+		key := roleUsageKey{role: usage.Role, permission: usage.Permission}
+		stored := r.roleUsage[key]
+		stored.Role = usage.Role
+		stored.Permission = usage.Permission
+		stored.GrantCount += usage.GrantCount
+		stored.LastGrantedAt = laterOf(stored.LastGrantedAt, usage.LastGrantedAt)
+		r.roleUsage[key] = stored
+	}
+
+	return nil
+}
+
+func (r *Repository) ListPermissionUsage(ctx context.Context, service string) ([]models.PermissionUsage, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	usage := []models.PermissionUsage{}
+	for key, perm := range r.permissions {
+		if service != "" && perm.Service != service {
+			continue
+		}
+
+		stored := r.permissionUsage[key]
+		stored.Permission = key
+		stored.Roles = []models.RoleUsage{}
+
+		// Only current bindings are reported, like the join in Postgres
+		for _, name := range r.rolesGrantingLocked(key) {
+			roleUsage := r.roleUsage[roleUsageKey{role: name, permission: key}]
+			roleUsage.Role = name
+			roleUsage.Permission = key
+			stored.Roles = append(stored.Roles, roleUsage)
+		}
+
+		usage = append(usage, stored)
+	}
+
+	sort.Slice(usage, func(i, j int) bool {
+		return usage[i].Permission < usage[j].Permission
+	})
+	return usage, nil
+}
+
+// deletePermissionUsageLocked mirrors ON DELETE CASCADE from permissions to usage
+func (r *Repository) deletePermissionUsageLocked(permission string) {
+	delete(r.permissionUsage, permission)
+	for key := range r.roleUsage {
+		if key.permission == permission {
+			delete(r.roleUsage, key)
+		}
+	}
+}
+
+// deleteRoleUsageLocked mirrors ON DELETE CASCADE from roles to usage
+func (r *Repository) deleteRoleUsageLocked(role string) {
+	for key := range r.roleUsage {
+		if key.role == role {
+			delete(r.roleUsage, key)
+		}
+	}
+}
+
+// laterOf keeps the most recent timestamp at the microsecond precision of Postgres
+func laterOf(current *time.Time, at *time.Time) *time.Time {
+	if at == nil {
+		return current
+	}
+
+	truncated := at.UTC().Truncate(time.Microsecond)
+	if current != nil && current.After(truncated) {
+		return current
+	}
+	return &truncated
+}
@@ -0,0 +1,28 @@
+package dto
+
+// AssignmentFilter narrows assignment listings; empty fields match everything
+type AssignmentFilter struct {
+	Subject string `json:"subject"`
+	Role    string `json:"role"`
+	Tenant  string `json:"tenant"`
+}
+
+// AuthorizationDecision is the result of an authorization check together with
+// the evaluation that led to it.
+type AuthorizationDecision struct {
+	Subject              string           `json:"subject"`
+	Permission           string           `json:"permission"`
+	Tenant               string           `json:"tenant"`
+	CanonicalPermission  string           `json:"canonical_permission"`
+	Allowed              bool             `json:"allowed"`
+	PermissionRegistered bool             `json:"permission_registered"`
+	Deprecated           bool             `json:"deprecated"`
+	MatchedRoles         []string         `json:"matched_roles"`
+	Evaluated            []RoleEvaluation `json:"evaluated"`
+}
+
+// RoleEvaluation records whether one of the subject's roles grants the permission
+type RoleEvaluation struct {
+	Role    string `json:"role"`
+	Granted bool   `json:"granted"`
+}
@@ -0,0 +1,38 @@
+package dto
+
+import "intellifinder/services/permissions/pkg/models"
+
+// ModelDiff describes the changes an import makes (or would make, in a dry run)
+type ModelDiff struct {
+	Added     []string `json:"added"`
+	Removed   []string `json:"removed"`
+	Unchanged int32    `json:"unchanged"`
+
+	AliasesAdded     []models.ModelAlias `json:"aliases_added"`
+	AliasesUnchanged int32               `json:"aliases_unchanged"`
+
+	// DeprecationsAdded are the permissions that become deprecated or whose
+	// deprecation reason changes; DeprecationsRemoved those that no longer are
+	DeprecationsAdded     []string `json:"deprecations_added"`
+	DeprecationsRemoved   []string `json:"deprecations_removed"`
+	DeprecationsUnchanged int32    `json:"deprecations_unchanged"`
+
+	RolesAdded     []string `json:"roles_added"`
+	RolesUpdated   []string `json:"roles_updated"`
+	RolesRemoved   []string `json:"roles_removed"`
+	RolesUnchanged int32    `json:"roles_unchanged"`
+
+	AssignmentsAdded     []models.ModelAssignment `json:"assignments_added"`
+	AssignmentsRemoved   []models.ModelAssignment `json:"assignments_removed"`
+	AssignmentsUnchanged int32                    `json:"assignments_unchanged"`
+
+	Applied bool `json:"applied"`
+}
+
+// Empty reports whether the import doesn't change anything
+func (d *ModelDiff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.AliasesAdded) == 0 &&
+		len(d.DeprecationsAdded) == 0 && len(d.DeprecationsRemoved) == 0 &&
+		len(d.RolesAdded) == 0 && len(d.RolesUpdated) == 0 && len(d.RolesRemoved) == 0 &&
+		len(d.AssignmentsAdded) == 0 && len(d.AssignmentsRemoved) == 0
+}
@@ -27,3 +27,55 @@ func NewPaginatedPermissions(permissions []models.Permission, page, limit, total
 		LastPage:    lastPage,
 	}
 }
+
+type PaginatedRoles struct {
+	Roles      []models.Role `json:"roles"`
+	Page       int32         `json:"page"`
+	Limit      int32         `json:"limit"`
+	TotalCount int32         `json:"total_count"`
+	LastPage   int32         `json:"last_page"`
+}
+
+func NewPaginatedRoles(roles []models.Role, page, limit, totalCount int32) *PaginatedRoles {
+	lastPage := int32(1)
+	if limit > 0 {
+		lastPage = (totalCount + limit - 1) / limit // Ceiling division
+		if lastPage == 0 {
+			lastPage = 1
+		}
+	}
+
+	return &PaginatedRoles{
+		Roles:      roles,
+		Page:       page,
+		Limit:      limit,
+		TotalCount: totalCount,
+		LastPage:   lastPage,
+	}
+}
+
+type PaginatedAssignments struct {
+	Assignments []models.RoleAssignment `json:"assignments"`
+	Page        int32                   `json:"page"`
+	Limit       int32                   `json:"limit"`
+	TotalCount  int32                   `json:"total_count"`
+	LastPage    int32                   `json:"last_page"`
+}
+
+func NewPaginatedAssignments(assignments []models.RoleAssignment, page, limit, totalCount int32) *PaginatedAssignments {
+	lastPage := int32(1)
+	if limit > 0 {
+		lastPage = (totalCount + limit - 1) / limit // Ceiling division
+		if lastPage == 0 {
+			lastPage = 1
+		}
+	}
+
+	return &PaginatedAssignments{
+		Assignments: assignments,
+		Page:        page,
+		Limit:       limit,
+		TotalCount:  totalCount,
+		LastPage:    lastPage,
+	}
+}
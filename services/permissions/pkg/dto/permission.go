@@ -0,0 +1,15 @@
+package dto
+
+import "intellifinder/services/permissions/pkg/models"
+
+// PermissionAliasResult describes a declared alias and the role bindings it migrated
+type PermissionAliasResult struct {
+	Alias         models.PermissionAlias `json:"alias"`
+	MigratedRoles []string               `json:"migrated_roles"`
+}
+
+// PermissionDeletion describes a deleted permission and the role bindings removed with it
+type PermissionDeletion struct {
+	Permission       string   `json:"permission"`
+	RemovedFromRoles []string `json:"removed_from_roles"`
+}
@@ -0,0 +1,22 @@
+package dto
+
+import (
+	"intellifinder/services/permissions/pkg/models"
+	"time"
+)
+
+// ReviewReport is the signed outcome of an access review campaign
+type ReviewReport struct {
+	Document  []byte `json:"document"`
+	Format    string `json:"format"`
+	Algorithm string `json:"algorithm"`
+	Signature []byte `json:"signature"`
+	PublicKey []byte `json:"public_key"`
+}
+
+// ReviewReportDocument is the JSON form of a review report
+type ReviewReportDocument struct {
+	Campaign    models.ReviewCampaign `json:"campaign"`
+	GeneratedAt time.Time             `json:"generated_at"`
+	Items       []models.ReviewItem   `json:"items"`
+}
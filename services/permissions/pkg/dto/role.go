@@ -0,0 +1,10 @@
+package dto
+
+// RoleSyncResult describes the changes a role file sync makes (or would make, in a dry run)
+type RoleSyncResult struct {
+	Created   []string `json:"created"`
+	Updated   []string `json:"updated"`
+	Deleted   []string `json:"deleted"`
+	Unchanged int32    `json:"unchanged"`
+	Applied   bool     `json:"applied"`
+}
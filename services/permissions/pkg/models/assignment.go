@@ -0,0 +1,17 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// RoleAssignment grants a role to a subject, either globally (empty tenant)
+// or within a single tenant.
+type RoleAssignment struct {
+	ID        uuid.UUID `json:"id" db:"id"`
+	Subject   string    `json:"subject" db:"subject"` // User ID from the identity provider
+	Role      string    `json:"role" db:"role"`
+	Tenant    string    `json:"tenant" db:"tenant"`
+	CreatedAt time.Time `json:"created_at" db:"created_at"`
+}
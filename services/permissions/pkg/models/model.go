@@ -0,0 +1,61 @@
+package models
+
+import "time"
+
+// ModelVersion is the version written to exported authorization model documents.
+// Version 1 documents only carry permissions and version 2 documents no aliases
+// or deprecations; both are still accepted on import.
+const ModelVersion = 3
+
+// AuthorizationModel is a portable snapshot of the authorization model that
+// can be exported from one environment and imported into another.
+type AuthorizationModel struct {
+	Version     int       `json:"version" yaml:"version"`
+	ExportedAt  time.Time `json:"exported_at" yaml:"exported_at"`
+	Permissions []string  `json:"permissions" yaml:"permissions"` // Format: "service:action"
+	// Aliases are the old names of renamed permissions
+	Aliases []ModelAlias `json:"aliases" yaml:"aliases"`
+	// Deprecations are the permissions scheduled for removal
+	Deprecations []ModelDeprecation `json:"deprecations" yaml:"deprecations"`
+	// Roles are the roles managed through the API. File-managed roles are
+	// left to the role files of each environment.
+	Roles       []ModelRole       `json:"roles" yaml:"roles"`
+	Assignments []ModelAssignment `json:"assignments" yaml:"assignments"`
+}
+
+// ModelAlias makes an old permission name resolve to the permission that replaced it
+type ModelAlias struct {
+	Alias      string `json:"alias" yaml:"alias"`           // Format: "service:action"
+	Permission string `json:"permission" yaml:"permission"` // Format: "service:action"
+}
+
+func (a ModelAlias) String() string {
+	return a.Alias + " -> " + a.Permission
+}
+
+// ModelDeprecation flags a permission for removal
+type ModelDeprecation struct {
+	Permission string `json:"permission" yaml:"permission"` // Format: "service:action"
+	Reason     string `json:"reason,omitempty" yaml:"reason,omitempty"`
+}
+
+// ModelRole is a role and the permissions it grants
+type ModelRole struct {
+	Name        string   `json:"name" yaml:"name"`
+	Description string   `json:"description,omitempty" yaml:"description,omitempty"`
+	Permissions []string `json:"permissions" yaml:"permissions"` // Format: "service:action"
+}
+
+// ModelAssignment grants a role to a subject, globally when Tenant is empty
+type ModelAssignment struct {
+	Subject string `json:"subject" yaml:"subject"`
+	Role    string `json:"role" yaml:"role"`
+	Tenant  string `json:"tenant,omitempty" yaml:"tenant,omitempty"`
+}
+
+func (a ModelAssignment) String() string {
+	if a.Tenant == "" {
+		return a.Subject + " -> " + a.Role
+	}
+	return a.Subject + " -> " + a.Role + " in " + a.Tenant
+}
@@ -7,9 +7,28 @@ import (
 )
 
 type Permission struct {
-	ID        uuid.UUID `json:"id" db:"id"`
-	Service   string    `json:"service" db:"service"`
-	Action    string    `json:"action" db:"action"`
-	CreatedAt time.Time `json:"created_at" db:"created_at"`
-	UpdatedAt time.Time `json:"updated_at" db:"updated_at"`
+	ID                uuid.UUID  `json:"id" db:"id"`
+	Service           string     `json:"service" db:"service"`
+	Action            string     `json:"action" db:"action"`
+	DeprecatedAt      *time.Time `json:"deprecated_at,omitempty" db:"deprecated_at"`
+	DeprecationReason string     `json:"deprecation_reason,omitempty" db:"deprecation_reason"`
+	CreatedAt         time.Time  `json:"created_at" db:"created_at"`
+	UpdatedAt         time.Time  `json:"updated_at" db:"updated_at"`
+}
+
+// Name returns the permission in "service:action" format
+func (p Permission) Name() string {
+	return p.Service + ":" + p.Action
+}
+
+// Deprecated reports whether the permission is still honoured but scheduled for removal
+func (p Permission) Deprecated() bool {
+	return p.DeprecatedAt != nil
+}
+
+// PermissionAlias maps an old permission name to the permission that replaced it
+type PermissionAlias struct {
+	Alias      string    `json:"alias" db:"alias"`
+	Permission string    `json:"permission" db:"permission"`
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
 }
@@ -0,0 +1,53 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	CampaignOpen   = "open"
+	CampaignClosed = "closed"
+
+	ReviewPending     = "pending"
+	ReviewApproved    = "approved"
+	ReviewRevoked     = "revoked"
+	ReviewAutoRevoked = "auto_revoked" // Still pending when the deadline passed
+)
+
+// ReviewCampaign is an access review of the role assignments in scope when it
+// was created. Empty Roles or Tenants mean every role or tenant.
+type ReviewCampaign struct {
+	ID        uuid.UUID  `json:"id" db:"id"`
+	Name      string     `json:"name" db:"name"`
+	Roles     []string   `json:"roles" db:"roles"`
+	Tenants   []string   `json:"tenants" db:"tenants"`
+	Deadline  time.Time  `json:"deadline" db:"deadline"`
+	Status    string     `json:"status" db:"status"`
+	CreatedBy string     `json:"created_by" db:"created_by"`
+	CreatedAt time.Time  `json:"created_at" db:"created_at"`
+	ClosedAt  *time.Time `json:"closed_at,omitempty" db:"closed_at"`
+	ReviewSummary
+}
+
+// ReviewSummary counts a campaign's items by decision
+type ReviewSummary struct {
+	Pending     int32 `json:"pending" db:"pending"`
+	Approved    int32 `json:"approved" db:"approved"`
+	Revoked     int32 `json:"revoked" db:"revoked"`
+	AutoRevoked int32 `json:"auto_revoked" db:"auto_revoked"`
+}
+
+// ReviewItem is the review of a single role assignment
+type ReviewItem struct {
+	ID         uuid.UUID  `json:"id" db:"id"`
+	CampaignID uuid.UUID  `json:"campaign_id" db:"campaign_id"`
+	Subject    string     `json:"subject" db:"subject"`
+	Role       string     `json:"role" db:"role"`
+	Tenant     string     `json:"tenant" db:"tenant"`
+	Decision   string     `json:"decision" db:"decision"`
+	Reviewer   string     `json:"reviewer" db:"reviewer"`
+	Comment    string     `json:"comment" db:"comment"`
+	DecidedAt  *time.Time `json:"decided_at,omitempty" db:"decided_at"`
+}
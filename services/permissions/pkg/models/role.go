@@ -0,0 +1,23 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Role struct {
+	ID          uuid.UUID `json:"id" db:"id"`
+	Name        string    `json:"name" db:"name"`
+	Description string    `json:"description" db:"description"`
+	Permissions []string  `json:"permissions" db:"permissions"` // Format: "service:action"
+	Source      string    `json:"source" db:"source"`           // Role file a file-managed role was loaded from
+	CreatedAt   time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at" db:"updated_at"`
+}
+
+// ReadOnly reports whether the role is managed through a role file and
+// therefore can't be changed through the API.
+func (r *Role) ReadOnly() bool {
+	return r.Source != ""
+}
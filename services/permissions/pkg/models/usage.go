@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// PermissionUsage records how often a permission was checked and, through
+// Roles, how often each role that grants it was what allowed the check
+type PermissionUsage struct {
+	Permission    string      `json:"permission" db:"permission"`
+	CheckCount    int64       `json:"check_count" db:"check_count"`
+	LastCheckedAt *time.Time  `json:"last_checked_at,omitempty" db:"last_checked_at"`
+	Roles         []RoleUsage `json:"roles" db:"-"`
+}
+
+// RoleUsage records how often a role's grant of a permission allowed a check
+type RoleUsage struct {
+	Role          string     `json:"role" db:"role"`
+	Permission    string     `json:"permission" db:"permission"`
+	GrantCount    int64      `json:"grant_count" db:"grant_count"`
+	LastGrantedAt *time.Time `json:"last_granted_at,omitempty" db:"last_granted_at"`
+}